@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/internal/configcheck"
+	"github.com/arijanluiken/mercantile/internal/history"
+	"github.com/arijanluiken/mercantile/internal/report"
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+	"github.com/arijanluiken/mercantile/pkg/vault"
+)
+
+const (
+	reportUsage = "usage: report tax [-year 2026] [-method fifo|lifo|average] [-format csv|json] [-output file]"
+	klinesUsage = "usage: klines download -symbol BTCUSDT -interval 1h -from 2024-01-01 [-to 2024-06-30] [-exchange bybit] [-account main]"
+	configUsage = "usage: config validate [-file config.yaml]"
+	vaultUsage  = "usage: vault add|rotate|remove|list -exchange bybit -account main [-testnet] [-file mercantile.vault]"
+)
+
+// runCommand runs a command line subcommand instead of starting the trading bot
+func runCommand(name string, args []string) error {
+	switch name {
+	case "report":
+		return runReport(args)
+	case "klines":
+		return runKlines(args)
+	case "config":
+		return runConfig(args)
+	case "vault":
+		return runVault(args)
+	}
+	return fmt.Errorf("unknown command %q, available commands: report, klines, config, vault", name)
+}
+
+// runReport writes a report from the trading history in the configured database
+func runReport(args []string) error {
+	if len(args) == 0 || args[0] != "tax" {
+		return fmt.Errorf(reportUsage)
+	}
+
+	flags := flag.NewFlagSet("report tax", flag.ContinueOnError)
+	year := flags.Int("year", time.Now().Year(), "fiscal year to report")
+	methodName := flags.String("method", "fifo", "cost basis method: fifo, lifo or average")
+	format := flags.String("format", "csv", "output format: csv or json")
+	output := flags.String("output", "", "file to write to instead of stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	method, err := report.ParseMethod(*methodName)
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("invalid format %q, use csv or json", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db, err := database.New(cfg.Database.Path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	taxReport, err := report.GenerateTaxReport(db, *year, method, cfg.Portfolio.BaseCurrency)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	for _, warning := range taxReport.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(taxReport)
+	}
+	return taxReport.WriteCSV(w)
+}
+
+// runKlines downloads historical klines into the kline store of the configured database, repairing gaps
+func runKlines(args []string) error {
+	if len(args) == 0 || args[0] != "download" {
+		return fmt.Errorf(klinesUsage)
+	}
+
+	flags := flag.NewFlagSet("klines download", flag.ContinueOnError)
+	exchangeName := flags.String("exchange", "bybit", "exchange or exchange account to download from")
+	account := flags.String("account", "", "vault account to use instead of the configured credentials")
+	symbol := flags.String("symbol", "", "symbol to download")
+	interval := flags.String("interval", "", "kline interval such as 1m, 1h or 1d")
+	fromValue := flags.String("from", "", "first day to download, YYYY-MM-DD or RFC3339")
+	toValue := flags.String("to", "", "last day to download, defaults to now")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *symbol == "" || *interval == "" || *fromValue == "" {
+		return fmt.Errorf(klinesUsage)
+	}
+
+	from, err := parseDay(*fromValue)
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	if *toValue != "" {
+		if to, err = parseDay(*toValue); err != nil {
+			return err
+		}
+		// A day includes every kline that opens on it
+		if len(*toValue) == len("2006-01-02") {
+			to = to.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	// Exchange credentials usually come from .env
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db, err := database.New(cfg.Database.Path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *account != "" {
+		exchangeConfig := cfg.Exchanges[*exchangeName]
+		exchangeConfig.Account = *account
+		cfg.Exchanges[*exchangeName] = exchangeConfig
+	}
+	var v *vault.Vault
+	if cfg.Exchanges[*exchangeName].Account != "" {
+		if v, err = vault.Unlock(cfg.Vault); err != nil {
+			return fmt.Errorf("failed to open vault %s: %w", cfg.Vault.Path, err)
+		}
+	}
+	credentials, err := vault.ForExchange(cfg, v, *exchangeName)
+	if err != nil {
+		return err
+	}
+
+	// Klines are stored per exchange type, shared by its accounts
+	exchangeType := cfg.ExchangeType(*exchangeName)
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	exchange, err := exchanges.NewFactory(zerolog.Nop()).CreateExchange(exchangeType, credentials.ExchangeConfig())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := history.NewDownloader(db, exchangeType, exchange, logger).Download(ctx, *symbol, *interval, from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("stored %d klines\n", result.Stored)
+	for _, gap := range result.Gaps {
+		fmt.Printf("missing %s to %s\n", gap.From.Format(time.RFC3339), gap.To.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// runConfig validates a configuration file against the strategy scripts and exchanges it refers to,
+// printing every problem with its line number
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf(configUsage)
+	}
+
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	file := flags.String("file", config.File, "configuration file to validate")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", *file, err)
+	}
+
+	var errs config.ValidationErrors
+	if err := configcheck.Check(cfg); errors.As(err, &errs) {
+		for _, problem := range errs {
+			if problem.Line > 0 {
+				fmt.Fprintf(os.Stderr, "%s:%d: %s: %s\n", *file, problem.Line, problem.Path, problem.Message)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", *file, problem.Path, problem.Message)
+			}
+		}
+		return fmt.Errorf("%s is invalid, %d problems found", *file, len(errs))
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid\n", *file)
+	return nil
+}
+
+// runVault manages the accounts in the encrypted credential vault. Keys and secrets are read from stdin,
+// never from flags, so they do not end up in the shell history.
+func runVault(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(vaultUsage)
+	}
+	action := args[0]
+	switch action {
+	case "add", "rotate", "remove", "list":
+	default:
+		return fmt.Errorf(vaultUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	flags := flag.NewFlagSet("vault "+action, flag.ContinueOnError)
+	exchangeName := flags.String("exchange", "", "exchange of the account")
+	account := flags.String("account", "", "name of the account")
+	testnet := flags.Bool("testnet", false, "the account trades on the testnet")
+	file := flags.String("file", cfg.Vault.Path, "vault file")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if action != "list" && (*exchangeName == "" || *account == "") {
+		return fmt.Errorf(vaultUsage)
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	passphrase, err := vault.Passphrase(cfg.Vault.KeyFile)
+	prompted := errors.Is(err, vault.ErrLocked)
+	if prompted {
+		passphrase, err = prompt(input, "Vault passphrase: ")
+	}
+	if err != nil {
+		return err
+	}
+
+	v, err := vault.Open(*file, passphrase)
+	if errors.Is(err, os.ErrNotExist) && action == "add" {
+		if prompted {
+			repeated, err := prompt(input, "Repeat passphrase: ")
+			if err != nil {
+				return err
+			}
+			if repeated != passphrase {
+				return fmt.Errorf("passphrases do not match")
+			}
+		}
+		v, err = vault.Create(*file, passphrase)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open vault %s: %w", *file, err)
+	}
+
+	switch action {
+	case "list":
+		for _, a := range v.Accounts() {
+			network := "mainnet"
+			if a.Testnet {
+				network = "testnet"
+			}
+			fmt.Printf("%s\t%s\t%s\trotated %s\n", a.Exchange, a.Name, network, a.UpdatedAt.Format(time.RFC3339))
+		}
+		return nil
+	case "add", "rotate":
+		apiKey, err := prompt(input, "API key: ")
+		if err != nil {
+			return err
+		}
+		secret, err := prompt(input, "Secret: ")
+		if err != nil {
+			return err
+		}
+		if action == "add" {
+			err = v.Add(*exchangeName, *account, vault.Credentials{APIKey: apiKey, Secret: secret, Testnet: *testnet})
+		} else {
+			err = v.Rotate(*exchangeName, *account, apiKey, secret)
+		}
+		if err != nil {
+			return err
+		}
+	case "remove":
+		if err := v.Remove(*exchangeName, *account); err != nil {
+			return err
+		}
+	}
+
+	if err := v.Save(); err != nil {
+		return err
+	}
+	done := map[string]string{"add": "Added", "rotate": "Rotated", "remove": "Removed"}[action]
+	fmt.Printf("%s %s account %s in %s\n", done, *exchangeName, *account, *file)
+	return nil
+}
+
+// prompt reads one line from input, writing the question to stderr so stdout stays clean
+func prompt(input *bufio.Reader, question string) (string, error) {
+	fmt.Fprint(os.Stderr, question)
+	line, err := input.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseDay parses a date as YYYY-MM-DD or RFC3339, in UTC
+func parseDay(value string) (time.Time, error) {
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+	}
+	return parsed.UTC(), nil
+}
@@ -0,0 +1,373 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/internal/portfolio"
+	"github.com/arijanluiken/mercantile/internal/risk"
+	"github.com/arijanluiken/mercantile/internal/valuation"
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Messages for aggregator actor communication
+type (
+	// RegisterExchangeMsg adds the portfolio and risk manager of an exchange to the consolidated view
+	RegisterExchangeMsg struct {
+		Exchange       string
+		PortfolioPID   *actor.PID
+		RiskManagerPID *actor.PID
+	}
+
+	// UpdateConfigMsg replaces the configuration the account-wide limits are read from after a reload
+	UpdateConfigMsg struct {
+		Config *config.Config
+	}
+
+	GetConsolidatedPortfolioMsg struct{}
+	GetConsolidatedRiskMsg      struct{}
+	StatusMsg                   struct{}
+)
+
+// ExchangeSummary is the share of a single exchange in the consolidated portfolio
+type ExchangeSummary struct {
+	TotalValue    float64   `json:"total_value"`
+	AvailableCash float64   `json:"available_cash"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	RealizedPnL   float64   `json:"realized_pnl"`
+	DailyPnL      float64   `json:"daily_pnl"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AssetExposure is the amount of one asset held across all exchanges
+type AssetExposure struct {
+	Asset     string             `json:"asset"`
+	Quantity  float64            `json:"quantity"`
+	Value     float64            `json:"value"`
+	Share     float64            `json:"share"`     // fraction of the consolidated value
+	Exchanges map[string]float64 `json:"exchanges"` // exchange -> units
+}
+
+// Portfolio is the consolidated view of all exchange portfolios in the base currency
+type Portfolio struct {
+	BaseCurrency  string                     `json:"base_currency"`
+	TotalValue    float64                    `json:"total_value"`
+	AvailableCash float64                    `json:"available_cash"`
+	UnrealizedPnL float64                    `json:"unrealized_pnl"`
+	RealizedPnL   float64                    `json:"realized_pnl"`
+	DailyPnL      float64                    `json:"daily_pnl"`
+	Assets        []AssetExposure            `json:"assets"`
+	Positions     []portfolio.Position       `json:"positions"`
+	Exchanges     map[string]ExchangeSummary `json:"exchanges"`
+	Unpriced      []string                   `json:"unpriced,omitempty"`
+	UpdatedAt     time.Time                  `json:"updated_at"`
+}
+
+// LimitStatus reports an account-wide limit against the current consolidated holdings
+type LimitStatus struct {
+	Limit    string  `json:"limit"`
+	Asset    string  `json:"asset"`
+	Current  float64 `json:"current"`
+	Max      float64 `json:"max"`
+	Breached bool    `json:"breached"`
+}
+
+// Risk is the consolidated risk view across all exchanges
+type Risk struct {
+	BaseCurrency  string             `json:"base_currency"`
+	TotalValue    float64            `json:"total_value"`
+	Exposure      map[string]float64 `json:"exposure"`      // asset -> units
+	Concentration map[string]float64 `json:"concentration"` // non-cash asset -> fraction of total value
+	Limits        []LimitStatus      `json:"limits"`
+	Breaches      int                `json:"breaches"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// AggregatorActor merges the portfolios of all exchanges and enforces account-wide limits
+// by sharing the consolidated exposure with every risk manager
+type AggregatorActor struct {
+	config       *config.Config
+	logger       zerolog.Logger
+	baseCurrency string
+
+	portfolioPIDs   map[string]*actor.PID // exchange -> portfolio PID
+	riskManagerPIDs map[string]*actor.PID // exchange -> risk manager PID
+	snapshots       map[string]portfolio.SnapshotMsg
+	breached        map[string]bool // limit:asset -> breached at the previous snapshot
+}
+
+// New creates a new aggregator actor
+func New(cfg *config.Config, logger zerolog.Logger) *AggregatorActor {
+	baseCurrency := ""
+	if cfg != nil {
+		baseCurrency = cfg.Portfolio.BaseCurrency
+	}
+
+	return &AggregatorActor{
+		config:          cfg,
+		logger:          logger,
+		baseCurrency:    valuation.New(baseCurrency).BaseCurrency(),
+		portfolioPIDs:   make(map[string]*actor.PID),
+		riskManagerPIDs: make(map[string]*actor.PID),
+		snapshots:       make(map[string]portfolio.SnapshotMsg),
+		breached:        make(map[string]bool),
+	}
+}
+
+// Receive handles incoming messages
+func (a *AggregatorActor) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case actor.Started:
+		a.logger.Debug().Msg("Aggregator actor started")
+	case actor.Stopped:
+		a.logger.Debug().Msg("Aggregator actor stopped")
+	case RegisterExchangeMsg:
+		a.onRegisterExchange(ctx, msg)
+	case portfolio.SnapshotMsg:
+		a.onSnapshot(ctx, msg)
+	case UpdateConfigMsg:
+		if msg.Config != nil {
+			a.config = msg.Config
+		}
+	case GetConsolidatedPortfolioMsg:
+		ctx.Respond(a.consolidatedPortfolio())
+	case GetConsolidatedRiskMsg:
+		ctx.Respond(a.consolidatedRisk())
+	case StatusMsg:
+		a.onStatus(ctx)
+	default:
+		a.logger.Debug().
+			Str("message_type", fmt.Sprintf("%T", msg)).
+			Msg("Received message")
+	}
+}
+
+func (a *AggregatorActor) onRegisterExchange(ctx *actor.Context, msg RegisterExchangeMsg) {
+	if msg.PortfolioPID != nil {
+		a.portfolioPIDs[msg.Exchange] = msg.PortfolioPID
+		ctx.Send(msg.PortfolioPID, portfolio.SetAggregatorMsg{AggregatorPID: ctx.PID()})
+	}
+	if msg.RiskManagerPID != nil {
+		a.riskManagerPIDs[msg.Exchange] = msg.RiskManagerPID
+	}
+
+	a.logger.Info().
+		Str("exchange", msg.Exchange).
+		Msg("Exchange registered for consolidation")
+}
+
+func (a *AggregatorActor) onSnapshot(ctx *actor.Context, msg portfolio.SnapshotMsg) {
+	a.snapshots[msg.Exchange] = msg
+
+	// Every risk manager checks account-wide limits against the same consolidated holdings
+	consolidated := a.consolidatedPortfolio()
+	exposure := make(map[string]float64, len(consolidated.Assets))
+	values := make(map[string]float64, len(consolidated.Assets))
+	for _, asset := range consolidated.Assets {
+		exposure[asset.Asset] = asset.Quantity
+		values[asset.Asset] = asset.Value
+	}
+
+	for _, riskPID := range a.riskManagerPIDs {
+		ctx.Send(riskPID, risk.UpdateAccountExposureMsg{
+			Exposure:   exposure,
+			Values:     values,
+			TotalValue: consolidated.TotalValue,
+		})
+	}
+
+	// Log breaches when they start and end, not on every snapshot
+	for _, limit := range EvaluateLimits(consolidated, a.accountLimits()) {
+		key := limit.Limit + ":" + limit.Asset
+		if limit.Breached == a.breached[key] {
+			continue
+		}
+		a.breached[key] = limit.Breached
+
+		event := a.logger.Info()
+		message := "Account-wide risk limit back within bounds"
+		if limit.Breached {
+			event = a.logger.Warn()
+			message = "Account-wide risk limit breached"
+		}
+		event.
+			Str("limit", limit.Limit).
+			Str("asset", limit.Asset).
+			Float64("current", limit.Current).
+			Float64("max", limit.Max).
+			Msg(message)
+	}
+}
+
+func (a *AggregatorActor) onStatus(ctx *actor.Context) {
+	status := map[string]interface{}{
+		"base_currency": a.baseCurrency,
+		"exchanges":     len(a.portfolioPIDs),
+		"snapshots":     len(a.snapshots),
+		"risk_managers": len(a.riskManagerPIDs),
+		"timestamp":     time.Now(),
+	}
+
+	ctx.Respond(status)
+}
+
+func (a *AggregatorActor) accountLimits() config.AccountRiskConfig {
+	if a.config == nil {
+		return config.AccountRiskConfig{}
+	}
+	return a.config.Risk.Account
+}
+
+func (a *AggregatorActor) consolidatedPortfolio() Portfolio {
+	return Consolidate(a.baseCurrency, a.snapshots)
+}
+
+func (a *AggregatorActor) consolidatedRisk() Risk {
+	consolidated := a.consolidatedPortfolio()
+	limits := EvaluateLimits(consolidated, a.accountLimits())
+
+	result := Risk{
+		BaseCurrency:  consolidated.BaseCurrency,
+		TotalValue:    consolidated.TotalValue,
+		Exposure:      make(map[string]float64),
+		Concentration: make(map[string]float64),
+		Limits:        limits,
+		UpdatedAt:     consolidated.UpdatedAt,
+	}
+
+	for _, asset := range consolidated.Assets {
+		result.Exposure[asset.Asset] = asset.Quantity
+		if !valuation.IsCash(asset.Asset) {
+			result.Concentration[asset.Asset] = asset.Share
+		}
+	}
+	for _, limit := range limits {
+		if limit.Breached {
+			result.Breaches++
+		}
+	}
+
+	return result
+}
+
+// Consolidate merges exchange snapshots into one portfolio. Each snapshot is already valued in the base currency.
+func Consolidate(baseCurrency string, snapshots map[string]portfolio.SnapshotMsg) Portfolio {
+	result := Portfolio{
+		BaseCurrency: baseCurrency,
+		Positions:    make([]portfolio.Position, 0),
+		Exchanges:    make(map[string]ExchangeSummary),
+	}
+
+	assets := make(map[string]*AssetExposure)
+	unpriced := make(map[string]bool)
+
+	for exchangeName, snapshot := range snapshots {
+		current := snapshot.Valuation
+
+		result.TotalValue += current.TotalValue
+		result.AvailableCash += current.AvailableCash
+		result.UnrealizedPnL += snapshot.UnrealizedPnL
+		result.RealizedPnL += snapshot.RealizedPnL
+		result.DailyPnL += snapshot.DailyPnL
+		result.Positions = append(result.Positions, snapshot.Positions...)
+		if snapshot.Timestamp.After(result.UpdatedAt) {
+			result.UpdatedAt = snapshot.Timestamp
+		}
+
+		result.Exchanges[exchangeName] = ExchangeSummary{
+			TotalValue:    current.TotalValue,
+			AvailableCash: current.AvailableCash,
+			UnrealizedPnL: snapshot.UnrealizedPnL,
+			RealizedPnL:   snapshot.RealizedPnL,
+			DailyPnL:      snapshot.DailyPnL,
+			UpdatedAt:     snapshot.Timestamp,
+		}
+
+		for _, holding := range snapshot.Holdings {
+			exposure, exists := assets[holding.Asset]
+			if !exists {
+				exposure = &AssetExposure{Asset: holding.Asset, Exchanges: make(map[string]float64)}
+				assets[holding.Asset] = exposure
+			}
+			exposure.Quantity += holding.Total
+			exposure.Exchanges[exchangeName] += holding.Total
+			exposure.Value += current.Values[holding.Asset]
+		}
+
+		for _, asset := range current.Unpriced {
+			unpriced[asset] = true
+		}
+	}
+
+	for _, exposure := range assets {
+		if result.TotalValue > 0 {
+			exposure.Share = exposure.Value / result.TotalValue
+		}
+		result.Assets = append(result.Assets, *exposure)
+	}
+	sort.Slice(result.Assets, func(i, j int) bool {
+		if result.Assets[i].Value != result.Assets[j].Value {
+			return result.Assets[i].Value > result.Assets[j].Value
+		}
+		return result.Assets[i].Asset < result.Assets[j].Asset
+	})
+
+	for asset := range unpriced {
+		result.Unpriced = append(result.Unpriced, asset)
+	}
+	sort.Strings(result.Unpriced)
+
+	return result
+}
+
+// EvaluateLimits checks the consolidated portfolio against the account-wide limits
+func EvaluateLimits(consolidated Portfolio, limits config.AccountRiskConfig) []LimitStatus {
+	statuses := make([]LimitStatus, 0)
+
+	exposure := make(map[string]AssetExposure, len(consolidated.Assets))
+	for _, asset := range consolidated.Assets {
+		exposure[asset.Asset] = asset
+	}
+
+	assets := make([]string, 0, len(limits.MaxAssetExposure))
+	for asset := range limits.MaxAssetExposure {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		maxExposure := limits.MaxAssetExposure[asset]
+		if maxExposure <= 0 {
+			continue
+		}
+		current := exposure[asset].Quantity
+		statuses = append(statuses, LimitStatus{
+			Limit:    "max_asset_exposure",
+			Asset:    asset,
+			Current:  current,
+			Max:      maxExposure,
+			Breached: current > maxExposure,
+		})
+	}
+
+	if limits.MaxConcentration > 0 {
+		for _, asset := range consolidated.Assets {
+			if valuation.IsCash(asset.Asset) {
+				continue
+			}
+			statuses = append(statuses, LimitStatus{
+				Limit:    "max_concentration",
+				Asset:    asset.Asset,
+				Current:  asset.Share,
+				Max:      limits.MaxConcentration,
+				Breached: asset.Share > limits.MaxConcentration,
+			})
+		}
+	}
+
+	return statuses
+}
@@ -0,0 +1,132 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/internal/portfolio"
+	"github.com/arijanluiken/mercantile/internal/valuation"
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+func testSnapshots() map[string]portfolio.SnapshotMsg {
+	now := time.Now()
+	return map[string]portfolio.SnapshotMsg{
+		"bybit": {
+			Exchange: "bybit",
+			Valuation: valuation.Valuation{
+				BaseCurrency:  "USDT",
+				TotalValue:    35000,
+				AvailableCash: 5000,
+				Values:        map[string]float64{"USDT": 5000, "BTC": 30000},
+			},
+			Holdings: []valuation.Holding{
+				{Asset: "USDT", Total: 5000, Available: 5000},
+				{Asset: "BTC", Total: 0.5, Available: 0.5},
+			},
+			Positions:     []portfolio.Position{{Exchange: "bybit", Symbol: "BTCUSDT", Quantity: 0.5}},
+			UnrealizedPnL: 100,
+			Timestamp:     now.Add(-time.Minute),
+		},
+		"bitvavo": {
+			Exchange: "bitvavo",
+			Valuation: valuation.Valuation{
+				BaseCurrency:  "USDT",
+				TotalValue:    25000,
+				AvailableCash: 1000,
+				Values:        map[string]float64{"EUR": 1000, "BTC": 24000},
+				Unpriced:      []string{"XYZ"},
+			},
+			Holdings: []valuation.Holding{
+				{Asset: "EUR", Total: 900, Available: 900},
+				{Asset: "BTC", Total: 0.4, Available: 0.4},
+				{Asset: "XYZ", Total: 10, Available: 10},
+			},
+			UnrealizedPnL: -40,
+			Timestamp:     now,
+		},
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	snapshots := testSnapshots()
+	consolidated := Consolidate("USDT", snapshots)
+
+	if consolidated.TotalValue != 60000 || consolidated.AvailableCash != 6000 {
+		t.Errorf("expected total 60000 and cash 6000, got %f and %f", consolidated.TotalValue, consolidated.AvailableCash)
+	}
+	if consolidated.UnrealizedPnL != 60 {
+		t.Errorf("expected unrealized PnL 60, got %f", consolidated.UnrealizedPnL)
+	}
+	if !consolidated.UpdatedAt.Equal(snapshots["bitvavo"].Timestamp) {
+		t.Errorf("expected latest snapshot time, got %v", consolidated.UpdatedAt)
+	}
+	if len(consolidated.Positions) != 1 || len(consolidated.Exchanges) != 2 {
+		t.Errorf("expected 1 position and 2 exchanges, got %d and %d", len(consolidated.Positions), len(consolidated.Exchanges))
+	}
+
+	btc := consolidated.Assets[0]
+	if btc.Asset != "BTC" {
+		t.Fatalf("expected largest asset BTC first, got %s", btc.Asset)
+	}
+	if btc.Quantity < 0.8999 || btc.Quantity > 0.9001 || btc.Value != 54000 || btc.Share != 0.9 {
+		t.Errorf("unexpected BTC exposure %+v", btc)
+	}
+	if btc.Exchanges["bybit"] != 0.5 || btc.Exchanges["bitvavo"] != 0.4 {
+		t.Errorf("expected per-exchange BTC units, got %v", btc.Exchanges)
+	}
+	if len(consolidated.Unpriced) != 1 || consolidated.Unpriced[0] != "XYZ" {
+		t.Errorf("expected XYZ to be unpriced, got %v", consolidated.Unpriced)
+	}
+}
+
+func TestEvaluateLimits(t *testing.T) {
+	consolidated := Consolidate("USDT", testSnapshots())
+
+	limits := EvaluateLimits(consolidated, config.AccountRiskConfig{
+		MaxAssetExposure: map[string]float64{"BTC": 0.75, "ETH": 2},
+		MaxConcentration: 0.95,
+	})
+
+	// BTC and ETH exposure, then concentration for the non-cash assets BTC and XYZ
+	if len(limits) != 4 {
+		t.Fatalf("expected 4 limit statuses, got %+v", limits)
+	}
+	if limits[0].Asset != "BTC" || !limits[0].Breached {
+		t.Errorf("expected BTC exposure across exchanges to breach 0.75, got %+v", limits[0])
+	}
+	if limits[1].Asset != "ETH" || limits[1].Breached {
+		t.Errorf("expected ETH exposure within limit, got %+v", limits[1])
+	}
+	if limits[2].Limit != "max_concentration" || limits[2].Breached {
+		t.Errorf("expected BTC concentration 0.9 within 0.95, got %+v", limits[2])
+	}
+
+	if limits := EvaluateLimits(consolidated, config.AccountRiskConfig{}); len(limits) != 0 {
+		t.Errorf("expected no limits when disabled, got %+v", limits)
+	}
+}
+
+func TestConsolidatedRisk(t *testing.T) {
+	cfg := &config.Config{Risk: config.RiskConfig{Account: config.AccountRiskConfig{
+		MaxAssetExposure: map[string]float64{"BTC": 0.75},
+	}}}
+	aggregator := New(cfg, zerolog.Nop())
+	aggregator.snapshots = testSnapshots()
+
+	result := aggregator.consolidatedRisk()
+	if result.BaseCurrency != valuation.DefaultBaseCurrency {
+		t.Errorf("expected default base currency, got %s", result.BaseCurrency)
+	}
+	if result.Breaches != 1 {
+		t.Errorf("expected 1 breach, got %d", result.Breaches)
+	}
+	if _, ok := result.Concentration["USDT"]; ok {
+		t.Error("expected cash to be left out of concentration")
+	}
+	if result.Concentration["BTC"] != 0.9 {
+		t.Errorf("expected BTC concentration 0.9, got %f", result.Concentration["BTC"])
+	}
+}
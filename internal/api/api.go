@@ -1,10 +1,15 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
@@ -13,8 +18,12 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/audit"
 	"github.com/arijanluiken/mercantile/internal/exchange"
+	"github.com/arijanluiken/mercantile/internal/strategy"
 	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/metrics"
 )
 
 // Messages for API actor communication
@@ -30,6 +39,16 @@ type (
 		Exchange    string
 		ExchangePID *actor.PID
 	}
+	SetAggregatorActorMsg struct {
+		AggregatorPID *actor.PID
+	}
+
+	// ShutdownProgressMsg reports the progress of a shutdown, served by the health endpoint
+	ShutdownProgressMsg struct {
+		Phase     string                         `json:"phase"`
+		StartedAt time.Time                      `json:"started_at"`
+		Results   []exchange.ShutdownPhaseResult `json:"results,omitempty"` // Of the phases completed so far
+	}
 )
 
 // APIActor provides REST API and WebSocket endpoints
@@ -42,11 +61,18 @@ type APIActor struct {
 	supervisorPID   *actor.PID
 	portfolioPIDs   map[string]*actor.PID               // exchange name -> portfolio PID
 	exchangePIDs    map[string]*actor.PID               // exchange name -> exchange PID
+	aggregatorPID   *actor.PID                          // consolidated portfolio and risk across exchanges
 	strategiesCache map[string][]map[string]interface{} // exchange name -> strategies
 	portfolioCache  map[string]map[string]interface{}   // exchange name -> portfolio data
 	ordersCache     map[string][]map[string]interface{} // exchange name -> orders
 	logsCache       map[string][]map[string]interface{} // strategy ID -> logs
 	db              *sql.DB                             // database connection
+	store           *database.DB                        // typed queries such as portfolio history
+	scripts         *strategy.ScriptStore               // strategy scripts edited through the API
+
+	// Set once shutdown began, read by the health endpoint
+	shutdown   *ShutdownProgressMsg
+	shutdownMu sync.RWMutex
 }
 
 // New creates a new API actor
@@ -60,6 +86,7 @@ func New(cfg *config.Config, logger zerolog.Logger) *APIActor {
 		portfolioCache:  make(map[string]map[string]interface{}),
 		ordersCache:     make(map[string][]map[string]interface{}),
 		logsCache:       make(map[string][]map[string]interface{}),
+		scripts:         strategy.NewScriptStore(),
 		wsUpgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				// Allow all origins for development
@@ -79,6 +106,11 @@ func (a *APIActor) SetDatabase(db *sql.DB) {
 	a.db = db
 }
 
+// SetStore sets the database used for typed queries such as portfolio history
+func (a *APIActor) SetStore(store *database.DB) {
+	a.store = store
+}
+
 // Receive handles incoming messages
 func (a *APIActor) Receive(ctx *actor.Context) {
 	switch msg := ctx.Message().(type) {
@@ -96,6 +128,11 @@ func (a *APIActor) Receive(ctx *actor.Context) {
 		a.onSetPortfolioActor(ctx, msg)
 	case SetExchangeActorMsg:
 		a.onSetExchangeActor(ctx, msg)
+	case ShutdownProgressMsg:
+		a.onShutdownProgress(msg)
+	case SetAggregatorActorMsg:
+		a.aggregatorPID = msg.AggregatorPID
+		a.logger.Info().Msg("Aggregator actor reference set")
 	case exchange.StrategyDataUpdateMsg:
 		a.onStrategyDataUpdate(ctx, msg)
 	case exchange.StrategyLogsUpdateMsg:
@@ -185,6 +222,19 @@ func (a *APIActor) onStatus(ctx *actor.Context) {
 	ctx.Respond(status)
 }
 
+func (a *APIActor) onShutdownProgress(msg ShutdownProgressMsg) {
+	a.shutdownMu.Lock()
+	a.shutdown = &msg
+	a.shutdownMu.Unlock()
+}
+
+// shutdownProgress returns the progress of the shutdown, nil while running
+func (a *APIActor) shutdownProgress() *ShutdownProgressMsg {
+	a.shutdownMu.RLock()
+	defer a.shutdownMu.RUnlock()
+	return a.shutdown
+}
+
 func (a *APIActor) onSetPortfolioActor(ctx *actor.Context, msg SetPortfolioActorMsg) {
 	a.portfolioPIDs[msg.Exchange] = msg.PortfolioPID
 	a.logger.Info().
@@ -324,6 +374,15 @@ func (a *APIActor) setupRouter(ctx *actor.Context) {
 		})
 	})
 
+	// Journal every request that changes state
+	r.Use(a.auditRequests(ctx.Engine()))
+
+	// No orders, settings or risk changes once shutdown began
+	r.Use(a.rejectDuringShutdown)
+
+	// Prometheus scrape endpoint, outside the versioned API
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+
 	// Routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Health check
@@ -361,6 +420,10 @@ func (a *APIActor) setupRouter(ctx *actor.Context) {
 		r.Route("/portfolio", func(r chi.Router) {
 			r.Get("/", a.handleGetPortfolio(ctx))
 			r.Get("/performance", a.handleGetPerformance(ctx))
+			r.Get("/history", a.handleGetPortfolioHistory(ctx))
+			r.Get("/cashflows", a.handleGetCashFlows(ctx))
+			r.Post("/cashflows", a.handleRecordCashFlow(ctx))
+			r.Get("/consolidated", a.handleGetConsolidatedPortfolio(ctx))
 		})
 
 		// Risk management routes
@@ -369,6 +432,18 @@ func (a *APIActor) setupRouter(ctx *actor.Context) {
 			r.Post("/parameters", a.handleSetRiskParameter(ctx))
 			r.Get("/parameters/{parameter}", a.handleGetRiskParameter(ctx))
 			r.Get("/metrics", a.handleGetRiskMetrics(ctx))
+			r.Get("/consolidated", a.handleGetConsolidatedRisk(ctx))
+		})
+
+		// Typed settings with history
+		r.Route("/settings", func(r chi.Router) {
+			r.Get("/", a.handleGetSettings)
+			r.Get("/schema", a.handleGetSettingsSchema)
+			r.Get("/history", a.handleGetSettingsHistory)
+			r.Get("/diff", a.handleGetSettingsDiff)
+			r.Post("/rollback", a.handleRollbackSettings(ctx))
+			r.Put("/{key}", a.handleSetSetting(ctx))
+			r.Delete("/{key}", a.handleUnsetSetting(ctx))
 		})
 
 		// Rebalancing routes
@@ -379,6 +454,35 @@ func (a *APIActor) setupRouter(ctx *actor.Context) {
 			r.Post("/trigger", a.handleTriggerRebalance(ctx))
 			r.Post("/load-script", a.handleLoadRebalanceScript(ctx))
 		})
+
+		// Strategy script editor routes
+		r.Route("/scripts", func(r chi.Router) {
+			r.Get("/", a.handleListScripts)
+			r.Post("/", a.handleCreateScript)
+			r.Get("/{name}", a.handleGetScript)
+			r.Put("/{name}", a.handleUpdateScript)
+			r.Get("/{name}/versions", a.handleGetScriptVersions)
+			r.Get("/{name}/versions/{version}", a.handleGetScriptVersion)
+			r.Post("/{name}/validate", a.handleValidateScript(ctx))
+			r.Post("/{name}/deploy", a.handleDeployScript(ctx))
+		})
+
+		// Chart data
+		r.Get("/chart", a.handleGetChart(ctx))
+
+		// Kline store
+		r.Route("/klines", func(r chi.Router) {
+			r.Get("/", a.handleGetKlines)
+			r.Post("/download", a.handleDownloadKlines(ctx))
+		})
+
+		// Audit journal
+		r.Get("/audit", a.handleGetAudit)
+
+		// Report endpoints
+		r.Route("/reports", func(r chi.Router) {
+			r.Get("/tax", a.handleGetTaxReport)
+		})
 	})
 
 	// WebSocket endpoint
@@ -387,4 +491,142 @@ func (a *APIActor) setupRouter(ctx *actor.Context) {
 	a.router = r
 }
 
+// rejectDuringShutdown answers POST, PUT and DELETE requests with 503 once shutdown has started,
+// as the actors they would reach are being stopped
+func (a *APIActor) rejectDuringShutdown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.shutdownProgress() != nil {
+			a.writeError(w, "Shutting down, no changes accepted", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditBodyLimit is the largest request body recorded in the audit journal, longer bodies are left out
+const auditBodyLimit = 16 << 10
+
+// auditRedacted lists parts of JSON field names whose values are never written to the audit journal
+var auditRedacted = []string{"secret", "password", "passphrase", "token", "api_key", "apikey", "private_key", "credential"}
+
+// auditRequests records every POST, PUT and DELETE request in the audit journal once it has been handled.
+// The caller is taken from the X-Actor header, falling back to the remote address. JSON bodies are recorded
+// with secrets redacted, and name the exchange, symbol and strategy when the query string does not.
+func (a *APIActor) auditRequests(engine *actor.Engine) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Read the start of the body and hand the handler all of it
+			var body []byte
+			if r.Body != nil {
+				body, _ = io.ReadAll(io.LimitReader(r.Body, auditBodyLimit+1))
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			caller := r.Header.Get("X-Actor")
+			if caller == "" {
+				caller = r.RemoteAddr
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := audit.Entry{
+				Type:     audit.TypeAPICall,
+				Exchange: r.URL.Query().Get("exchange"),
+				Symbol:   r.URL.Query().Get("symbol"),
+				Strategy: r.URL.Query().Get("strategy"),
+				Actor:    caller,
+				Message:  fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status),
+				Data: map[string]interface{}{
+					"method":      r.Method,
+					"path":        r.URL.Path,
+					"query":       r.URL.RawQuery,
+					"status":      status,
+					"remote_addr": r.RemoteAddr,
+					"request_id":  middleware.GetReqID(r.Context()),
+				},
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Data["route"] = rctx.RoutePattern()
+				if strings.HasPrefix(rctx.RoutePattern(), "/api/v1/orders/") {
+					entry.OrderID = rctx.URLParam("id")
+				}
+			}
+			auditBody(&entry, body)
+			audit.Record(engine, entry)
+		})
+	}
+}
+
+// auditBody adds a request body to an audit entry, filling in the exchange, symbol and strategy it names
+func auditBody(entry *audit.Entry, body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+	if len(body) > auditBodyLimit {
+		entry.Data["body_truncated"] = true
+		return
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		entry.Data["body_size"] = len(body)
+		return
+	}
+	entry.Data["body"] = redact(decoded)
+
+	fields, ok := decoded.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, target := range map[string]*string{"exchange": &entry.Exchange, "symbol": &entry.Symbol, "strategy": &entry.Strategy} {
+		if value, ok := fields[name].(string); ok && *target == "" {
+			*target = value
+		}
+	}
+}
+
+// redact replaces the values of secret fields in a decoded JSON value, at any depth
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			lower := strings.ToLower(name)
+			secret := false
+			for _, part := range auditRedacted {
+				if strings.Contains(lower, part) {
+					secret = true
+					break
+				}
+			}
+			if secret {
+				v[name] = "[redacted]"
+			} else {
+				v[name] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
+
 // Response helpers
@@ -4,11 +4,18 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthdm/hollywood/actor"
+	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/audit"
+	"github.com/arijanluiken/mercantile/internal/exchange"
+	"github.com/arijanluiken/mercantile/internal/settings"
+	"github.com/arijanluiken/mercantile/internal/strategy"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 )
@@ -32,6 +39,7 @@ func setupTestAPI(t *testing.T) *APIActor {
 
 	api := New(cfg, logger)
 	api.SetDatabase(db.Conn())
+	api.SetStore(db)
 	return api
 }
 
@@ -239,4 +247,723 @@ func TestNew(t *testing.T) {
 	if api.config.API.Timeout != 30*time.Second {
 		t.Errorf("expected API timeout 30s, got %v", api.config.API.Timeout)
 	}
-}
\ No newline at end of file
+}
+func TestHandleCashFlows(t *testing.T) {
+	api := setupTestAPI(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := api.store.SaveCashFlow(&database.CashFlow{
+		Exchange:     "bitvavo",
+		ExternalID:   "manual-1",
+		Asset:        "EUR",
+		Amount:       500,
+		Value:        500,
+		BaseCurrency: "EUR",
+		Source:       "manual",
+		Note:         "bank transfer",
+		CreatedAt:    start,
+	})
+	if err != nil {
+		t.Fatalf("failed to save cash flow: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/portfolio/cashflows?from=2023-12-31T00:00:00Z&to=2024-01-02T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+	api.handleGetCashFlows(nil)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		CashFlows []struct {
+			Asset  string  `json:"asset"`
+			Amount float64 `json:"amount"`
+			Note   string  `json:"note"`
+		} `json:"cash_flows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal cash flows response: %v", err)
+	}
+	if len(response.CashFlows) != 1 || response.CashFlows[0].Amount != 500 || response.CashFlows[0].Note != "bank transfer" {
+		t.Errorf("expected the recorded cash flow, got %+v", response.CashFlows)
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"exchange": "bitvavo", "asset": "EUR"}`, http.StatusBadRequest},
+		{`{"exchange": "bitvavo", "asset": "EUR", "amount": 100, "timestamp": "yesterday"}`, http.StatusBadRequest},
+		{`{"exchange": "unknown", "asset": "EUR", "amount": 100}`, http.StatusNotFound},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/portfolio/cashflows", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		api.handleRecordCashFlow(nil)(w, req)
+		if w.Code != tt.status {
+			t.Errorf("expected status %d for %s, got %d", tt.status, tt.body, w.Code)
+		}
+	}
+}
+
+func TestHandleGetPortfolioHistory(t *testing.T) {
+	api := setupTestAPI(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []float64{1000, 1100, 1200} {
+		err := api.store.SavePortfolioSnapshot(&database.PortfolioSnapshot{
+			Exchange:     "bybit",
+			BaseCurrency: "USDT",
+			TotalValue:   value,
+			CreatedAt:    start.Add(time.Duration(i*30) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+	}
+
+	handler := api.handleGetPortfolioHistory(nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/portfolio/history?from=2024-01-01T00:00:00Z&to=2024-01-01T03:00:00Z&resolution=1h", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		BaseCurrency string `json:"base_currency"`
+		Points       []struct {
+			TotalValue float64 `json:"total_value"`
+		} `json:"points"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal history response: %v", err)
+	}
+	if response.BaseCurrency != "USDT" || len(response.Points) != 2 {
+		t.Fatalf("expected 2 USDT points, got %+v", response)
+	}
+	if response.Points[0].TotalValue != 1100 || response.Points[1].TotalValue != 1200 {
+		t.Errorf("expected the last value per hour, got %+v", response.Points)
+	}
+
+	for _, query := range []string{"resolution=1x", "from=yesterday", "from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", "from=0&resolution=1m"} {
+		req := httptest.NewRequest("GET", "/api/v1/portfolio/history?"+query, nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+func TestHandleGetTaxReport(t *testing.T) {
+	api := setupTestAPI(t)
+
+	fills := []*database.Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Exchange: "bybit", OrderID: "2", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 150, CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, fill := range fills {
+		if err := api.store.SaveFill(fill); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/reports/tax?year=2026&method=fifo", nil)
+	w := httptest.NewRecorder()
+	api.handleGetTaxReport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Summary struct {
+			Gain float64 `json:"gain"`
+		} `json:"summary"`
+		Disposals []map[string]interface{} `json:"disposals"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal tax report: %v", err)
+	}
+	if len(response.Disposals) != 1 || response.Summary.Gain != 50 {
+		t.Errorf("expected one disposal with a gain of 50, got %+v", response)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/reports/tax?year=2026&format=csv", nil)
+	w = httptest.NewRecorder()
+	api.handleGetTaxReport(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" || !strings.HasPrefix(w.Body.String(), "type,asset") {
+		t.Errorf("expected a CSV export, got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, query := range []string{"year=last", "method=hifo", "format=xml"} {
+		req := httptest.NewRequest("GET", "/api/v1/reports/tax?"+query, nil)
+		w := httptest.NewRecorder()
+		api.handleGetTaxReport(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+func TestHandleGetPerformance(t *testing.T) {
+	api := setupTestAPI(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fills := []*database.Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: start},
+		{Exchange: "bybit", OrderID: "2", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 120, Strategy: "sma", CreatedAt: start.Add(time.Hour)},
+	}
+	for _, fill := range fills {
+		if err := api.store.SaveFill(fill); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/portfolio/performance?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+	api.handleGetPerformance(nil)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Resolution string `json:"resolution"`
+		Strategies map[string]struct {
+			Trades      int     `json:"trades"`
+			WinRate     float64 `json:"win_rate"`
+			RealizedPnL float64 `json:"realized_pnl"`
+		} `json:"strategies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal performance response: %v", err)
+	}
+	if response.Resolution != "1d" {
+		t.Errorf("expected default resolution 1d, got %q", response.Resolution)
+	}
+	sma := response.Strategies["sma"]
+	if sma.Trades != 1 || sma.WinRate != 1 || sma.RealizedPnL != 20 {
+		t.Errorf("expected one winning sma trade, got %+v", sma)
+	}
+}
+
+func TestHandleGetChart(t *testing.T) {
+	api := setupTestAPI(t)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"exchange=bybit&interval=1h", http.StatusBadRequest},
+		{"exchange=bybit&symbol=BTCUSDT", http.StatusBadRequest},
+		{"exchange=bybit&symbol=BTCUSDT&interval=1h&limit=0", http.StatusBadRequest},
+		{"exchange=bybit&symbol=BTCUSDT&interval=1h&limit=5000", http.StatusBadRequest},
+		{"exchange=unknown&symbol=BTCUSDT&interval=1h", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/chart?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		api.handleGetChart(nil)(w, req)
+		if w.Code != tt.status {
+			t.Errorf("expected status %d for %q, got %d", tt.status, tt.query, w.Code)
+		}
+	}
+}
+
+func TestHandleGetKlines(t *testing.T) {
+	api := setupTestAPI(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := []*database.Kline{
+		{Exchange: "bybit", Symbol: "BTCUSDT", Interval: "1h", OpenTime: start, Close: 100},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Interval: "1h", OpenTime: start.Add(3 * time.Hour), Close: 103},
+	}
+	if err := api.store.SaveKlines(klines); err != nil {
+		t.Fatalf("failed to save klines: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/klines?exchange=bybit&symbol=BTCUSDT&interval=1h&from=2024-01-01T00:00:00Z&to=2024-01-01T03:00:00Z", nil)
+	w := httptest.NewRecorder()
+	api.handleGetKlines(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Klines []struct {
+			Close float64 `json:"close"`
+		} `json:"klines"`
+		Gaps []struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		} `json:"gaps"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal klines response: %v", err)
+	}
+	if len(response.Klines) != 2 || response.Klines[1].Close != 103 {
+		t.Errorf("expected 2 stored klines, got %+v", response.Klines)
+	}
+	if len(response.Gaps) != 1 || !response.Gaps[0].From.Equal(start.Add(time.Hour)) || !response.Gaps[0].To.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("expected a gap from 01:00 to 02:00, got %+v", response.Gaps)
+	}
+
+	for _, query := range []string{
+		"symbol=BTCUSDT&interval=1h&from=2024-01-01T00:00:00Z",
+		"exchange=bybit&symbol=BTCUSDT&interval=1h",
+		"exchange=bybit&symbol=BTCUSDT&interval=1x&from=2024-01-01T00:00:00Z",
+		"exchange=bybit&symbol=BTCUSDT&interval=1h&from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+	} {
+		req := httptest.NewRequest("GET", "/api/v1/klines?"+query, nil)
+		w := httptest.NewRecorder()
+		api.handleGetKlines(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+func TestHandleGetExchanges(t *testing.T) {
+	api := setupTestAPI(t)
+	api.config.Exchanges = map[string]config.ExchangeConfig{
+		"bybit":       {Enabled: true, Account: "main"},
+		"bybit-hedge": {Enabled: true, Type: "bybit", Account: "hedge"},
+		"bitvavo":     {Enabled: false},
+	}
+	api.exchangePIDs["bybit"] = actor.NewPID("local", "exchange_bybit")
+	api.exchangePIDs["bybit-hedge"] = actor.NewPID("local", "exchange_bybit-hedge")
+
+	w := httptest.NewRecorder()
+	api.handleGetExchanges(nil)(w, httptest.NewRequest("GET", "/api/v1/exchanges", nil))
+
+	var response struct {
+		Exchanges []struct {
+			Name    string `json:"name"`
+			Type    string `json:"type"`
+			Account string `json:"account"`
+			Running bool   `json:"running"`
+		} `json:"exchanges"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal exchanges response: %v", err)
+	}
+	if len(response.Exchanges) != 3 {
+		t.Fatalf("expected 3 exchange accounts, got %+v", response.Exchanges)
+	}
+	hedge := response.Exchanges[2]
+	if hedge.Name != "bybit-hedge" || hedge.Type != "bybit" || hedge.Account != "hedge" || !hedge.Running {
+		t.Errorf("unexpected hedge account %+v", hedge)
+	}
+	if response.Exchanges[0].Name != "bitvavo" || response.Exchanges[0].Running {
+		t.Errorf("expected the disabled exchange not to run, got %+v", response.Exchanges[0])
+	}
+
+	// Accounts read the klines of their exchange
+	if err := api.store.SaveKlines([]*database.Kline{{Exchange: "bybit", Symbol: "BTCUSDT", Interval: "1h", OpenTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Close: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	api.handleGetKlines(w, httptest.NewRequest("GET", "/api/v1/klines?exchange=bybit-hedge&symbol=BTCUSDT&interval=1h&from=2024-01-01T00:00:00Z&to=2024-01-01T00:00:00Z", nil))
+	if !strings.Contains(w.Body.String(), `"close":100`) {
+		t.Errorf("expected the bybit klines for the hedge account, got %s", w.Body.String())
+	}
+}
+
+func TestChartTrades(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fills := []*database.Fill{
+		{OrderID: "1", Symbol: "BTCUSDT", Side: "BUY", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: start},
+		{OrderID: "2", Symbol: "ETHUSDT", Side: "buy", Quantity: 1, Price: 10, Strategy: "sma", CreatedAt: start},
+		{OrderID: "3", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 110, Strategy: "rsi", CreatedAt: start.Add(time.Hour)},
+	}
+
+	if trades := chartTrades(fills, "BTCUSDT", ""); len(trades) != 2 {
+		t.Errorf("expected both BTCUSDT fills, got %+v", trades)
+	}
+
+	trades := chartTrades(fills, "BTCUSDT", "sma")
+	if len(trades) != 1 || trades[0]["order_id"] != "1" || trades[0]["side"] != "buy" {
+		t.Errorf("expected the sma buy as a lower-case marker, got %+v", trades)
+	}
+}
+
+func TestScriptEndpoints(t *testing.T) {
+	api := setupTestAPI(t)
+	api.scripts = strategy.NewScriptStore(t.TempDir())
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/scripts", api.handleListScripts)
+	r.Post("/api/v1/scripts", api.handleCreateScript)
+	r.Get("/api/v1/scripts/{name}", api.handleGetScript)
+	r.Put("/api/v1/scripts/{name}", api.handleUpdateScript)
+	r.Get("/api/v1/scripts/{name}/versions/{version}", api.handleGetScriptVersion)
+	r.Post("/api/v1/scripts/{name}/validate", api.handleValidateScript(nil))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	source := `def on_kline(kline):\n    return {\"action\": \"hold\"}\n`
+	if w := do("POST", "/api/v1/scripts", `{"name": "editor_test", "source": "`+source+`", "note": "first"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected script to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/v1/scripts", `{"name": "editor_test", "source": "`+source+`"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an existing script, got %d", w.Code)
+	}
+	if w := do("POST", "/api/v1/scripts", `{"name": "../escape", "source": "`+source+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid name, got %d", w.Code)
+	}
+
+	// Scripts that do not compile are refused with their errors
+	w := do("PUT", "/api/v1/scripts/editor_test", `{"source": "def on_kline(kline):\n    return undefined_signal\n"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid script, got %d", w.Code)
+	}
+	var refused struct {
+		Validation strategy.ValidationResult `json:"validation"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&refused); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(refused.Validation.Errors) != 1 || refused.Validation.Errors[0].Line != 2 {
+		t.Errorf("expected an error on line 2, got %+v", refused.Validation.Errors)
+	}
+
+	updated := `def on_kline(kline):\n    return {\"action\": \"buy\", \"quantity\": 1.0}\n`
+	if w := do("PUT", "/api/v1/scripts/editor_test", `{"source": "`+updated+`", "note": "buy"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected script to be updated, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/api/v1/scripts/editor_test", "")
+	var script struct {
+		Source   string                      `json:"source"`
+		Versions []*database.StrategyVersion `json:"versions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&script); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.Contains(script.Source, "buy") || len(script.Versions) != 2 || script.Versions[0].Version != 2 {
+		t.Errorf("expected updated source with two versions, got %+v", script)
+	}
+
+	w = do("GET", "/api/v1/scripts/editor_test/versions/1", "")
+	var version database.StrategyVersion
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if version.Note != "first" || strings.Contains(version.Source, "buy") {
+		t.Errorf("expected the first version, got %+v", version)
+	}
+	if w := do("GET", "/api/v1/scripts/editor_test/versions/9", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing version, got %d", w.Code)
+	}
+	if w := do("GET", "/api/v1/scripts/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing script, got %d", w.Code)
+	}
+
+	// Unsaved source is validated without touching the saved script
+	w = do("POST", "/api/v1/scripts/editor_test/validate", `{"source": "def on_kline(kline):\n    size = 1 +* 2\n"}`)
+	var validation strategy.ValidationResult
+	if err := json.NewDecoder(w.Body).Decode(&validation); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if validation.Valid || len(validation.Errors) != 1 || validation.Errors[0].Stage != strategy.StageSyntax || validation.Errors[0].Line != 2 {
+		t.Errorf("expected a syntax error on line 2, got %+v", validation)
+	}
+	if w := do("POST", "/api/v1/scripts/editor_test/validate", `{"exchange": "missing", "symbol": "BTCUSDT"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown exchange, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/v1/scripts", "")
+	var list struct {
+		Scripts []strategy.ScriptInfo `json:"scripts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Scripts) != 1 || list.Scripts[0].Name != "editor_test" {
+		t.Errorf("expected one script, got %+v", list.Scripts)
+	}
+}
+
+func TestSettingsEndpoints(t *testing.T) {
+	api := setupTestAPI(t)
+	api.config.Exchanges = map[string]config.ExchangeConfig{"bybit": {Enabled: true}}
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/settings", api.handleGetSettings)
+	r.Get("/api/v1/settings/schema", api.handleGetSettingsSchema)
+	r.Get("/api/v1/settings/history", api.handleGetSettingsHistory)
+	r.Get("/api/v1/settings/diff", api.handleGetSettingsDiff)
+	r.Post("/api/v1/settings/rollback", api.handleRollbackSettings(nil))
+	r.Put("/api/v1/settings/{key}", api.handleSetSetting(nil))
+	r.Delete("/api/v1/settings/{key}", api.handleUnsetSetting(nil))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/v1/settings/schema", ""); !strings.Contains(w.Body.String(), `"risk.max_position_size"`) {
+		t.Errorf("expected the schema to list risk.max_position_size, got %s", w.Body.String())
+	}
+
+	if w := do("PUT", "/api/v1/settings/risk.max_position_size", `{"value": "0.05", "exchange": "bybit", "symbol": "BTCUSDT", "actor": "alice"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the setting to be stored, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/v1/settings/risk.max_position_size", `{"value": "5"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an out of range value, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/v1/settings/risk.max_daily_loss", `{"value": "500", "exchange": "missing"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown exchange, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/v1/settings/risk.max_daily_loss", `{"value": "500", "exchange": "bybit"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the setting to be stored, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var effective struct {
+		Settings []settings.Value `json:"settings"`
+	}
+	w := do("GET", "/api/v1/settings?exchange=bybit&symbol=BTCUSDT", "")
+	if err := json.NewDecoder(w.Body).Decode(&effective); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, value := range effective.Settings {
+		if value.Key == "risk.max_position_size" && (value.Value != "0.05" || value.Scope.Symbol != "BTCUSDT") {
+			t.Errorf("expected the symbol value, got %+v", value)
+		}
+		if value.Key == "risk.max_daily_loss" && (value.Value != "500" || value.Scope.Exchange != "bybit") {
+			t.Errorf("expected the value inherited from the exchange, got %+v", value)
+		}
+	}
+
+	var history struct {
+		Changes []settings.Change `json:"changes"`
+	}
+	w = do("GET", "/api/v1/settings/history?key=risk.max_position_size", "")
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(history.Changes) != 1 || history.Changes[0].Actor != "alice" || history.Changes[0].OldValue != nil {
+		t.Errorf("expected one change by alice, got %+v", history.Changes)
+	}
+
+	w = do("GET", "/api/v1/settings/diff?from=1", "")
+	if !strings.Contains(w.Body.String(), `"key":"risk.max_daily_loss"`) || strings.Contains(w.Body.String(), `"key":"risk.max_position_size"`) {
+		t.Errorf("expected only max_daily_loss to differ since version 1, got %s", w.Body.String())
+	}
+
+	if w := do("POST", "/api/v1/settings/rollback", `{"version": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the rollback to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/v1/settings/rollback", `{"version": 99}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing version, got %d", w.Code)
+	}
+	w = do("GET", "/api/v1/settings/history?limit=1", "")
+	if !strings.Contains(w.Body.String(), `"note":"rollback to version 1"`) || !strings.Contains(w.Body.String(), `"actor":"api"`) {
+		t.Errorf("expected the rollback in the history, got %s", w.Body.String())
+	}
+
+	if w := do("DELETE", "/api/v1/settings/risk.max_position_size?exchange=bybit&symbol=BTCUSDT", ""); !strings.Contains(w.Body.String(), `"changed":true`) {
+		t.Errorf("expected the symbol value to be removed, got %s", w.Body.String())
+	}
+}
+
+func TestAuditEndpoints(t *testing.T) {
+	api := setupTestAPI(t)
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	pid := engine.Spawn(func() actor.Receiver { return audit.New(api.config, api.store, zerolog.Nop()) }, "audit")
+	defer func() { <-engine.Poison(pid).Done() }()
+	if _, err := engine.Request(pid, audit.StatusMsg{}, 5*time.Second).Result(); err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(api.auditRequests(engine))
+	r.Get("/api/v1/audit", api.handleGetAudit)
+	r.Get("/api/v1/orders", func(w http.ResponseWriter, r *http.Request) {})
+	r.Delete("/api/v1/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Actor", "alice")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	do("GET", "/api/v1/orders")
+	do("DELETE", "/api/v1/orders/42?exchange=bybit&symbol=BTCUSDT")
+
+	var response struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(response.Entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w := do("GET", "/api/v1/audit?order_id=42&from=2024-01-01T00:00:00Z")
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	if len(response.Entries) != 1 {
+		t.Fatalf("expected the cancel request to be journaled, got %+v", response.Entries)
+	}
+	entry := response.Entries[0]
+	if entry.Type != audit.TypeAPICall || entry.Actor != "alice" || entry.Exchange != "bybit" || entry.Symbol != "BTCUSDT" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry.Data["status"] != float64(http.StatusNotFound) || entry.Data["route"] != "/api/v1/orders/{id}" {
+		t.Errorf("expected the status and route in the details, got %v", entry.Data)
+	}
+
+	// Reads are not journaled
+	w := do("GET", "/api/v1/audit?type=api_call")
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Entries) != 1 {
+		t.Errorf("expected only the cancel request, got %+v", response.Entries)
+	}
+
+	if w := do("GET", "/api/v1/audit?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid from, got %d", w.Code)
+	}
+}
+
+func TestAuditRequestBody(t *testing.T) {
+	api := setupTestAPI(t)
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	pid := engine.Spawn(func() actor.Receiver { return audit.New(api.config, api.store, zerolog.Nop()) }, "audit")
+	defer func() { <-engine.Poison(pid).Done() }()
+	if _, err := engine.Request(pid, audit.StatusMsg{}, 5*time.Second).Result(); err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+
+	var handled map[string]interface{}
+	r := chi.NewRouter()
+	r.Use(api.auditRequests(engine))
+	r.Get("/api/v1/audit", api.handleGetAudit)
+	r.Post("/api/v1/orders", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&handled); err != nil {
+			t.Errorf("handler failed to read the body: %v", err)
+		}
+	})
+
+	body := `{"exchange":"bybit","symbol":"BTCUSDT","strategy":"sma","side":"buy","quantity":0.1,"credentials":{"api_key":"k"},"auth":{"api_secret":"s"}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body)))
+	if handled["quantity"] != 0.1 {
+		t.Errorf("expected the handler to receive the whole body, got %v", handled)
+	}
+
+	var response struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(response.Entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/audit?type=api_call&from=2024-01-01T00:00:00Z", nil))
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	if len(response.Entries) != 1 {
+		t.Fatalf("expected the order request to be journaled, got %+v", response.Entries)
+	}
+
+	entry := response.Entries[0]
+	if entry.Exchange != "bybit" || entry.Symbol != "BTCUSDT" || entry.Strategy != "sma" {
+		t.Errorf("expected the exchange, symbol and strategy from the body, got %+v", entry)
+	}
+	recorded, ok := entry.Data["body"].(map[string]interface{})
+	if !ok || recorded["quantity"] != 0.1 || recorded["side"] != "buy" {
+		t.Fatalf("expected the body in the details, got %v", entry.Data)
+	}
+	if recorded["credentials"] != "[redacted]" || recorded["auth"].(map[string]interface{})["api_secret"] != "[redacted]" {
+		t.Errorf("expected secrets to be redacted, got %v", recorded)
+	}
+}
+
+func TestHandleHealthDuringShutdown(t *testing.T) {
+	api := setupTestAPI(t)
+	api.onShutdownProgress(ShutdownProgressMsg{
+		Phase:     exchange.PhaseOrderPolicy,
+		StartedAt: time.Now(),
+		Results: []exchange.ShutdownPhaseResult{
+			{Exchange: "bybit", Phase: exchange.PhaseStopStrategies, Detail: map[string]interface{}{"strategies_stopped": 2}},
+		},
+	})
+
+	w := httptest.NewRecorder()
+	api.handleHealth(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d while shutting down, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	var response struct {
+		Status   string              `json:"status"`
+		Shutdown ShutdownProgressMsg `json:"shutdown"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Status != "shutting_down" || response.Shutdown.Phase != exchange.PhaseOrderPolicy {
+		t.Errorf("expected the current shutdown phase, got %+v", response)
+	}
+	if len(response.Shutdown.Results) != 1 || response.Shutdown.Results[0].Exchange != "bybit" {
+		t.Errorf("expected the completed phase of bybit, got %+v", response.Shutdown.Results)
+	}
+}
+
+func TestRejectWritesDuringShutdown(t *testing.T) {
+	api := setupTestAPI(t)
+
+	r := chi.NewRouter()
+	r.Use(api.rejectDuringShutdown)
+	r.Get("/api/v1/orders", func(w http.ResponseWriter, r *http.Request) {})
+	r.Post("/api/v1/orders", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected writes to be accepted while running, got %d", w.Code)
+	}
+
+	api.onShutdownProgress(ShutdownProgressMsg{Phase: exchange.PhaseStopStrategies, StartedAt: time.Now()})
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d for a write while shutting down, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/orders", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected reads to be served while shutting down, got %d", w.Code)
+	}
+}
@@ -3,16 +3,42 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/go-chi/chi/v5"
 
+	"github.com/arijanluiken/mercantile/internal/aggregator"
+	"github.com/arijanluiken/mercantile/internal/audit"
 	"github.com/arijanluiken/mercantile/internal/exchange"
+	"github.com/arijanluiken/mercantile/internal/history"
+	"github.com/arijanluiken/mercantile/internal/portfolio"
+	"github.com/arijanluiken/mercantile/internal/report"
+	"github.com/arijanluiken/mercantile/internal/sandbox"
+	"github.com/arijanluiken/mercantile/internal/settings"
+	"github.com/arijanluiken/mercantile/internal/strategy"
+	"github.com/arijanluiken/mercantile/pkg/database"
 )
 
+// maxHistoryPoints bounds the equity curve returned by /portfolio/history
+const maxHistoryPoints = 5000
+
+// Default and maximum number of klines returned by /chart
+const (
+	defaultChartKlines = 200
+	maxChartKlines     = 1000
+)
+
+// defaultValidationKlines is the number of recent klines a script is dry run over
+const defaultValidationKlines = 200
+
 // Response helpers
 func (a *APIActor) writeJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -26,7 +52,21 @@ func (a *APIActor) writeError(w http.ResponseWriter, message string, code int) {
 }
 
 // Basic handlers
+
+// handleHealth reports the bot as ok, or as unavailable with the shutdown progress once it is stopping
 func (a *APIActor) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if progress := a.shutdownProgress(); progress != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "shutting_down",
+			"timestamp": time.Now().Format(time.RFC3339),
+			"version":   "1.0.0",
+			"shutdown":  progress,
+		})
+		return
+	}
+
 	a.writeJSON(w, map[string]interface{}{
 		"status":    "ok",
 		"timestamp": time.Now().Format(time.RFC3339),
@@ -97,16 +137,39 @@ func (a *APIActor) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
 }
 
 // Handler generators that capture context
+
+// handleGetExchanges lists the configured exchange accounts. Routes under /exchanges/{exchange} take the
+// account name, such as "bybit-hedge".
 func (a *APIActor) handleGetExchanges(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Get actual exchange status from supervisor
-		exchanges := []map[string]interface{}{
-			{
-				"name":    "bybit",
-				"status":  "connected",
-				"enabled": true,
-				"testnet": true,
-			},
+		names := make([]string, 0, len(a.exchangePIDs))
+		for name := range a.exchangePIDs {
+			names = append(names, name)
+		}
+		if a.config != nil {
+			for name := range a.config.Exchanges {
+				if _, running := a.exchangePIDs[name]; !running {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+
+		exchanges := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			_, running := a.exchangePIDs[name]
+			exchange := map[string]interface{}{
+				"name":    name,
+				"type":    name,
+				"running": running,
+			}
+			if a.config != nil {
+				exchangeConfig := a.config.Exchanges[name]
+				exchange["type"] = a.config.ExchangeType(name)
+				exchange["account"] = exchangeConfig.Account
+				exchange["enabled"] = exchangeConfig.Enabled
+			}
+			exchanges = append(exchanges, exchange)
 		}
 		a.writeJSON(w, map[string]interface{}{"exchanges": exchanges})
 	}
@@ -450,224 +513,1476 @@ func (a *APIActor) handleGetPortfolio(ctx *actor.Context) http.HandlerFunc {
 
 func (a *APIActor) handleGetPerformance(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Get actual performance data
-		a.writeJSON(w, map[string]interface{}{
-			"daily_pnl":   125.50,
-			"weekly_pnl":  625.75,
-			"monthly_pnl": 2150.25,
-			"total_pnl":   5000.00,
-		})
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		period, err := parseHistoryRange(r, "1d")
+		if err != nil {
+			a.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		exchangeName := r.URL.Query().Get("exchange")
+		snapshots, err := a.store.GetPortfolioSnapshots(exchangeName, period.From, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get portfolio snapshots")
+			a.writeError(w, "Failed to get performance", http.StatusInternalServerError)
+			return
+		}
+		// Earlier fills open the positions that fills in the period close
+		fills, err := a.store.GetFills(exchangeName, time.Time{}, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get fills")
+			a.writeError(w, "Failed to get performance", http.StatusInternalServerError)
+			return
+		}
+		flows, err := a.store.GetCashFlows(exchangeName, period.From, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get cash flows")
+			a.writeError(w, "Failed to get performance", http.StatusInternalServerError)
+			return
+		}
+
+		report := portfolio.BuildPerformanceReport(snapshots, fills, flows, period.From, period.To, period.Bucket)
+		report.Resolution = period.Resolution
+		a.writeJSON(w, report)
 	}
 }
 
-func (a *APIActor) handleWebSocket(ctx *actor.Context) http.HandlerFunc {
+func (a *APIActor) handleGetPortfolioHistory(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := a.wsUpgrader.Upgrade(w, r, nil)
-		if err != nil {
-			a.logger.Error().Err(err).Msg("WebSocket upgrade failed")
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
 			return
 		}
-		defer conn.Close()
 
-		a.logger.Info().Str("remote", r.RemoteAddr).Msg("WebSocket connection established")
+		period, err := parseHistoryRange(r, "1h")
+		if err != nil {
+			a.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// Handle WebSocket connection
-		for {
-			messageType, p, err := conn.ReadMessage()
-			if err != nil {
-				a.logger.Debug().Err(err).Msg("WebSocket read error")
-				break
-			}
+		exchangeName := r.URL.Query().Get("exchange")
+		snapshots, err := a.store.GetPortfolioSnapshots(exchangeName, period.From, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get portfolio snapshots")
+			a.writeError(w, "Failed to get portfolio history", http.StatusInternalServerError)
+			return
+		}
+		flows, err := a.store.GetCashFlows(exchangeName, period.From, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get cash flows")
+			a.writeError(w, "Failed to get portfolio history", http.StatusInternalServerError)
+			return
+		}
 
-			// Echo back for now (TODO: implement real-time updates)
-			if err := conn.WriteMessage(messageType, p); err != nil {
-				a.logger.Debug().Err(err).Msg("WebSocket write error")
-				break
-			}
+		baseCurrency := ""
+		if len(snapshots) > 0 {
+			baseCurrency = snapshots[len(snapshots)-1].BaseCurrency
 		}
 
-		a.logger.Info().Str("remote", r.RemoteAddr).Msg("WebSocket connection closed")
+		a.writeJSON(w, map[string]interface{}{
+			"exchange":      exchangeName,
+			"base_currency": baseCurrency,
+			"from":          period.From.UTC(),
+			"to":            period.To.UTC(),
+			"resolution":    period.Resolution,
+			"points":        portfolio.EquityCurve(snapshots, flows, period.Bucket),
+		})
 	}
 }
 
-// Risk management handlers
-
-func (a *APIActor) handleGetRiskParameters(ctx *actor.Context) http.HandlerFunc {
+func (a *APIActor) handleGetCashFlows(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		a.logger.Info().Msg("Getting all risk parameters")
-
-		// Get all risk parameters
-		parameters := []string{
-			"max_position_size", "max_daily_loss", "max_portfolio_risk",
-			"max_correlation", "max_leverage", "max_daily_trades",
-			"max_hourly_trades", "var_limit", "max_drawdown_limit",
-			"concentration_limit",
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+			return
 		}
 
-		result := make(map[string]interface{})
-
-		// For now, get from the first available exchange's risk manager
-		for _, exchangePID := range a.exchangePIDs {
-			for _, param := range parameters {
-				// Send request to exchange actor to get risk parameter
-				// Exchange actor will forward to risk manager
-				msg := map[string]interface{}{
-					"type":      "get_risk_parameter",
-					"parameter": param,
-				}
+		period, err := parseHistoryRange(r, "1d")
+		if err != nil {
+			a.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-				response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
-				if err != nil {
-					a.logger.Error().Err(err).Str("parameter", param).Msg("Failed to get risk parameter")
-					continue
-				}
+		exchangeName := r.URL.Query().Get("exchange")
+		flows, err := a.store.GetCashFlows(exchangeName, period.From, period.To)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get cash flows")
+			a.writeError(w, "Failed to get cash flows", http.StatusInternalServerError)
+			return
+		}
 
-				result[param] = response
-			}
-			break // Only use first exchange for now
+		cashFlows := make([]map[string]interface{}, 0, len(flows))
+		for _, flow := range flows {
+			cashFlows = append(cashFlows, cashFlowJSON(flow))
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"exchange":   "all", // or specific exchange
-			"parameters": result,
+		a.writeJSON(w, map[string]interface{}{
+			"exchange":   exchangeName,
+			"from":       period.From.UTC(),
+			"to":         period.To.UTC(),
+			"cash_flows": cashFlows,
 		})
 	}
 }
 
-func (a *APIActor) handleSetRiskParameter(ctx *actor.Context) http.HandlerFunc {
+func (a *APIActor) handleRecordCashFlow(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
-			Parameter string `json:"parameter"`
-			Value     string `json:"value"`
-			Exchange  string `json:"exchange,omitempty"`
+			Exchange  string  `json:"exchange"`
+			Asset     string  `json:"asset"`
+			Amount    float64 `json:"amount"` // positive for a deposit, negative for a withdrawal
+			Note      string  `json:"note,omitempty"`
+			Timestamp string  `json:"timestamp,omitempty"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			a.writeError(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		if req.Parameter == "" || req.Value == "" {
-			http.Error(w, "Parameter and value are required", http.StatusBadRequest)
+		if req.Asset == "" || req.Amount == 0 {
+			a.writeError(w, "Asset and a non-zero amount are required", http.StatusBadRequest)
 			return
 		}
 
-		a.logger.Info().
-			Str("parameter", req.Parameter).
-			Str("value", req.Value).
-			Str("exchange", req.Exchange).
-			Msg("Setting risk parameter")
-
-		// If no exchange specified, apply to all exchanges
-		exchanges := []string{}
-		if req.Exchange != "" {
-			exchanges = append(exchanges, req.Exchange)
-		} else {
-			for exchangeName := range a.exchangePIDs {
-				exchanges = append(exchanges, exchangeName)
+		var timestamp time.Time
+		if req.Timestamp != "" {
+			parsed, err := parseTime(req.Timestamp)
+			if err != nil {
+				a.writeError(w, "Invalid timestamp: use RFC3339 or unix seconds", http.StatusBadRequest)
+				return
 			}
+			timestamp = parsed
 		}
 
-		results := make(map[string]interface{})
-		for _, exchangeName := range exchanges {
-			if exchangePID, exists := a.exchangePIDs[exchangeName]; exists {
-				msg := map[string]interface{}{
-					"type":      "set_risk_parameter",
-					"parameter": req.Parameter,
-					"value":     req.Value,
-				}
+		portfolioPID, exists := a.portfolioPIDs[req.Exchange]
+		if !exists {
+			a.writeError(w, "Exchange not found", http.StatusNotFound)
+			return
+		}
 
-				response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
-				if err != nil {
-					a.logger.Error().
-						Err(err).
-						Str("exchange", exchangeName).
-						Str("parameter", req.Parameter).
-						Msg("Failed to set risk parameter")
-					results[exchangeName] = map[string]interface{}{
-						"success": false,
-						"error":   err.Error(),
-					}
-				} else {
-					results[exchangeName] = map[string]interface{}{
-						"success": true,
-						"result":  response,
-					}
-				}
-			}
+		response, err := ctx.Request(portfolioPID, portfolio.RecordCashFlowMsg{
+			Asset:     req.Asset,
+			Amount:    req.Amount,
+			Note:      req.Note,
+			Timestamp: timestamp,
+		}, 5*time.Second).Result()
+		if err != nil {
+			a.logger.Error().Err(err).Str("exchange", req.Exchange).Msg("Failed to record cash flow")
+			a.writeError(w, "Failed to record cash flow", http.StatusInternalServerError)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(results)
+		switch result := response.(type) {
+		case *database.CashFlow:
+			a.writeJSON(w, cashFlowJSON(result))
+		case error:
+			a.writeError(w, result.Error(), http.StatusBadRequest)
+		default:
+			a.writeError(w, "Unexpected response from portfolio", http.StatusInternalServerError)
+		}
 	}
 }
 
-func (a *APIActor) handleGetRiskParameter(ctx *actor.Context) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		parameter := chi.URLParam(r, "parameter")
-		if parameter == "" {
-			http.Error(w, "Parameter name is required", http.StatusBadRequest)
+// cashFlowJSON converts a stored cash flow into its API representation
+func cashFlowJSON(flow *database.CashFlow) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            flow.ID,
+		"exchange":      flow.Exchange,
+		"external_id":   flow.ExternalID,
+		"asset":         flow.Asset,
+		"amount":        flow.Amount,
+		"value":         flow.Value,
+		"base_currency": flow.BaseCurrency,
+		"source":        flow.Source,
+		"note":          flow.Note,
+		"timestamp":     flow.CreatedAt.UTC(),
+	}
+}
+
+// handleGetTaxReport exports the acquisitions and disposals of a fiscal year as JSON or, with format=csv, as CSV
+func (a *APIActor) handleGetTaxReport(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	year := time.Now().Year()
+	if value := query.Get("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1970 || parsed > 9999 {
+			a.writeError(w, "Invalid year", http.StatusBadRequest)
 			return
 		}
+		year = parsed
+	}
 
-		a.logger.Info().Str("parameter", parameter).Msg("Getting risk parameter")
+	method, err := report.ParseMethod(query.Get("method"))
+	if err != nil {
+		a.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		// Get from first available exchange
-		for exchangeName, exchangePID := range a.exchangePIDs {
-			msg := map[string]interface{}{
-				"type":      "get_risk_parameter",
-				"parameter": parameter,
-			}
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		a.writeError(w, "Invalid format, use json or csv", http.StatusBadRequest)
+		return
+	}
 
-			response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
-			if err != nil {
-				a.logger.Error().Err(err).Str("parameter", parameter).Msg("Failed to get risk parameter")
-				http.Error(w, "Failed to get risk parameter", http.StatusInternalServerError)
-				return
-			}
+	baseCurrency := ""
+	if a.config != nil {
+		baseCurrency = a.config.Portfolio.BaseCurrency
+	}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"exchange":  exchangeName,
-				"parameter": parameter,
-				"result":    response,
-			})
-			return
-		}
+	taxReport, err := report.GenerateTaxReport(a.store, year, method, baseCurrency)
+	if err != nil {
+		a.logger.Error().Err(err).Int("year", year).Msg("Failed to generate tax report")
+		a.writeError(w, "Failed to generate tax report", http.StatusInternalServerError)
+		return
+	}
 
-		http.Error(w, "No exchanges available", http.StatusServiceUnavailable)
+	if format != "csv" {
+		a.writeJSON(w, taxReport)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tax-%d-%s.csv\"", year, method))
+	if err := taxReport.WriteCSV(w); err != nil {
+		a.logger.Error().Err(err).Msg("Failed to write tax report")
 	}
 }
 
-func (a *APIActor) handleGetRiskMetrics(ctx *actor.Context) http.HandlerFunc {
+// handleGetChart returns the klines of a symbol with the series plotted by a strategy and the fills it traded
+func (a *APIActor) handleGetChart(ctx *actor.Context) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		a.logger.Info().Msg("Getting risk metrics")
-
-		results := make(map[string]interface{})
+		query := r.URL.Query()
+		exchangeName := query.Get("exchange")
+		symbol := query.Get("symbol")
+		interval := query.Get("interval")
+		strategyName := query.Get("strategy")
+		if symbol == "" {
+			a.writeError(w, "Symbol is required", http.StatusBadRequest)
+			return
+		}
+		if interval == "" && strategyName == "" {
+			a.writeError(w, "Interval or strategy is required", http.StatusBadRequest)
+			return
+		}
 
-		// Get risk metrics from all exchanges
-		for exchangeName, exchangePID := range a.exchangePIDs {
-			msg := map[string]interface{}{
-				"type": "get_risk_metrics",
+		limit := defaultChartKlines
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 || parsed > maxChartKlines {
+				a.writeError(w, fmt.Sprintf("Invalid limit, use 1 to %d", maxChartKlines), http.StatusBadRequest)
+				return
 			}
+			limit = parsed
+		}
 
-			response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
+		exchangePID, exists := a.exchangePIDs[exchangeName]
+		if !exists {
+			a.writeError(w, "Exchange not found", http.StatusNotFound)
+			return
+		}
+
+		response, err := ctx.Request(exchangePID, exchange.GetChartDataMsg{
+			Symbol:   symbol,
+			Interval: interval,
+			Strategy: strategyName,
+			Limit:    limit,
+		}, 15*time.Second).Result()
+		if err != nil {
+			a.logger.Error().Err(err).Str("exchange", exchangeName).Str("symbol", symbol).Msg("Failed to get chart data")
+			a.writeError(w, "Failed to get chart data", http.StatusInternalServerError)
+			return
+		}
+
+		var chart exchange.ChartDataMsg
+		switch result := response.(type) {
+		case exchange.ChartDataMsg:
+			chart = result
+		case error:
+			a.writeError(w, result.Error(), http.StatusBadRequest)
+			return
+		default:
+			a.writeError(w, "Unexpected response from exchange", http.StatusInternalServerError)
+			return
+		}
+
+		klines := make([]map[string]interface{}, 0, len(chart.Klines))
+		for _, kline := range chart.Klines {
+			klines = append(klines, map[string]interface{}{
+				"timestamp": kline.Timestamp.UTC(),
+				"open":      kline.Open,
+				"high":      kline.High,
+				"low":       kline.Low,
+				"close":     kline.Close,
+				"volume":    kline.Volume,
+			})
+		}
+
+		trades := make([]map[string]interface{}, 0)
+		if a.store != nil && len(chart.Klines) > 0 {
+			fills, err := a.store.GetFills(exchangeName, chart.Klines[0].Timestamp, time.Now())
 			if err != nil {
-				a.logger.Error().
-					Err(err).
-					Str("exchange", exchangeName).
-					Msg("Failed to get risk metrics")
-				results[exchangeName] = map[string]interface{}{
-					"error": err.Error(),
-				}
-			} else {
-				results[exchangeName] = response
+				a.logger.Error().Err(err).Msg("Failed to get fills for chart")
+				a.writeError(w, "Failed to get chart data", http.StatusInternalServerError)
+				return
 			}
+			trades = chartTrades(fills, symbol, strategyName)
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(results)
+		a.writeJSON(w, map[string]interface{}{
+			"exchange": exchangeName,
+			"symbol":   symbol,
+			"interval": chart.Interval,
+			"strategy": strategyName,
+			"klines":   klines,
+			"plots":    chart.Plots,
+			"trades":   trades,
+		})
+	}
+}
+
+// chartTrades converts the fills of a symbol, and of one strategy when named, into chart markers
+func chartTrades(fills []*database.Fill, symbol, strategyName string) []map[string]interface{} {
+	trades := make([]map[string]interface{}, 0)
+	for _, fill := range fills {
+		if fill.Symbol != symbol || (strategyName != "" && fill.Strategy != strategyName) {
+			continue
+		}
+		trades = append(trades, map[string]interface{}{
+			"timestamp": fill.CreatedAt.UTC(),
+			"side":      strings.ToLower(fill.Side),
+			"price":     fill.Price,
+			"quantity":  fill.Quantity,
+			"order_id":  fill.OrderID,
+			"strategy":  fill.Strategy,
+		})
+	}
+	return trades
+}
+
+// handleGetKlines returns the stored klines of a symbol between from and to with the gaps the store has there
+func (a *APIActor) handleGetKlines(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	exchangeName := query.Get("exchange")
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if exchangeName == "" || symbol == "" || interval == "" {
+		a.writeError(w, "Exchange, symbol and interval are required", http.StatusBadRequest)
+		return
+	}
+	from, to, ok := a.parseKlineRange(w, query.Get("from"), query.Get("to"))
+	if !ok {
+		return
+	}
+
+	// Klines are stored per exchange type, shared by its accounts
+	exchangeType := exchangeName
+	if a.config != nil {
+		exchangeType = a.config.ExchangeType(exchangeName)
+	}
+	gaps, err := history.Gaps(a.store, exchangeType, symbol, interval, from, to)
+	if err != nil {
+		a.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stored, err := history.Load(a.store, exchangeType, symbol, interval, from, to)
+	if err != nil {
+		a.logger.Error().Err(err).Str("exchange", exchangeName).Str("symbol", symbol).Msg("Failed to get stored klines")
+		a.writeError(w, "Failed to get klines", http.StatusInternalServerError)
+		return
+	}
+
+	klines := make([]map[string]interface{}, 0, len(stored))
+	for _, kline := range stored {
+		klines = append(klines, map[string]interface{}{
+			"timestamp": kline.Timestamp.UTC(),
+			"open":      kline.Open,
+			"high":      kline.High,
+			"low":       kline.Low,
+			"close":     kline.Close,
+			"volume":    kline.Volume,
+		})
+	}
+	if gaps == nil {
+		gaps = []history.Gap{}
+	}
+
+	a.writeJSON(w, map[string]interface{}{
+		"exchange": exchangeName,
+		"symbol":   symbol,
+		"interval": interval,
+		"klines":   klines,
+		"gaps":     gaps,
+	})
+}
+
+// handleDownloadKlines starts filling the kline store of an exchange for a range, returning the gaps being filled
+func (a *APIActor) handleDownloadKlines(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Exchange string `json:"exchange"`
+			Symbol   string `json:"symbol"`
+			Interval string `json:"interval"`
+			From     string `json:"from"`
+			To       string `json:"to,omitempty"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Symbol == "" || req.Interval == "" {
+			a.writeError(w, "Symbol and interval are required", http.StatusBadRequest)
+			return
+		}
+		from, to, ok := a.parseKlineRange(w, req.From, req.To)
+		if !ok {
+			return
+		}
+
+		exchangePID, exists := a.exchangePIDs[req.Exchange]
+		if !exists {
+			a.writeError(w, "Exchange not found", http.StatusNotFound)
+			return
+		}
+
+		response, err := ctx.Request(exchangePID, exchange.DownloadKlinesMsg{
+			Symbol:   req.Symbol,
+			Interval: req.Interval,
+			From:     from,
+			To:       to,
+		}, 5*time.Second).Result()
+		if err != nil {
+			a.logger.Error().Err(err).Str("exchange", req.Exchange).Str("symbol", req.Symbol).Msg("Failed to start kline download")
+			a.writeError(w, "Failed to start kline download", http.StatusInternalServerError)
+			return
+		}
+
+		switch result := response.(type) {
+		case exchange.KlineDownloadMsg:
+			gaps := result.Gaps
+			if gaps == nil {
+				gaps = []history.Gap{}
+			}
+			a.writeJSON(w, map[string]interface{}{
+				"exchange": req.Exchange,
+				"symbol":   req.Symbol,
+				"interval": req.Interval,
+				"gaps":     gaps,
+			})
+		case error:
+			a.writeError(w, result.Error(), http.StatusBadRequest)
+		default:
+			a.writeError(w, "Unexpected response from exchange", http.StatusInternalServerError)
+		}
+	}
+}
+
+// parseKlineRange parses a required start and an optional end defaulting to now, writing an error when invalid
+func (a *APIActor) parseKlineRange(w http.ResponseWriter, fromValue, toValue string) (time.Time, time.Time, bool) {
+	if fromValue == "" {
+		a.writeError(w, "From is required", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := parseTime(fromValue)
+	if err != nil {
+		a.writeError(w, "Invalid from: use RFC3339 or unix seconds", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+
+	to := time.Now()
+	if toValue != "" {
+		if to, err = parseTime(toValue); err != nil {
+			a.writeError(w, "Invalid to: use RFC3339 or unix seconds", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if to.Before(from) {
+		a.writeError(w, "From must be before to", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// handleListScripts lists the strategy scripts on disk
+func (a *APIActor) handleListScripts(w http.ResponseWriter, r *http.Request) {
+	scripts, err := a.scripts.List()
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to list strategy scripts")
+		a.writeError(w, "Failed to list scripts", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"scripts": scripts})
+}
+
+// handleGetScript returns the source of a strategy script with its saved versions
+func (a *APIActor) handleGetScript(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	source, ok := a.readScript(w, name)
+	if !ok {
+		return
+	}
+
+	versions := make([]*database.StrategyVersion, 0)
+	if a.store != nil {
+		stored, err := a.store.GetStrategyVersions(name)
+		if err != nil {
+			a.logger.Error().Err(err).Str("script", name).Msg("Failed to get strategy versions")
+			a.writeError(w, "Failed to get script versions", http.StatusInternalServerError)
+			return
+		}
+		versions = stored
+	}
+
+	a.writeJSON(w, map[string]interface{}{
+		"name":     name,
+		"source":   source,
+		"interval": strategy.ScriptInterval(source),
+		"versions": versions,
+	})
+}
+
+// handleCreateScript creates a new strategy script, failing when one with the same name exists
+func (a *APIActor) handleCreateScript(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string `json:"name"`
+		Source string `json:"source"`
+		Note   string `json:"note,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !strategy.ValidScriptName(req.Name) {
+		a.writeError(w, "Invalid script name, use letters, digits and underscores", http.StatusBadRequest)
+		return
+	}
+	if a.scripts.Exists(req.Name) {
+		a.writeError(w, "Script already exists", http.StatusConflict)
+		return
+	}
+
+	a.saveScript(w, req.Name, req.Source, req.Note)
+}
+
+// handleUpdateScript replaces the source of an existing strategy script
+func (a *APIActor) handleUpdateScript(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if _, ok := a.readScript(w, name); !ok {
+		return
+	}
+
+	var req struct {
+		Source string `json:"source"`
+		Note   string `json:"note,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	a.saveScript(w, name, req.Source, req.Note)
+}
+
+// saveScript writes a script and records it as a new version. Scripts that do not compile are refused,
+// the script watcher would otherwise hand them to the strategies running the script.
+func (a *APIActor) saveScript(w http.ResponseWriter, name, source, note string) {
+	if strings.TrimSpace(source) == "" {
+		a.writeError(w, "Source is required", http.StatusBadRequest)
+		return
+	}
+
+	validation := a.newScriptEngine().ValidateScript(name, source, "", nil)
+	if !validation.Valid {
+		a.writeValidationError(w, "Script failed validation", validation)
+		return
+	}
+
+	path, err := a.scripts.Write(name, source)
+	if err != nil {
+		a.logger.Error().Err(err).Str("script", name).Msg("Failed to write strategy script")
+		a.writeError(w, "Failed to save script", http.StatusInternalServerError)
+		return
+	}
+
+	var version *database.StrategyVersion
+	if a.store != nil {
+		version, err = a.store.SaveStrategyVersion(name, source, note)
+		if err != nil {
+			a.logger.Error().Err(err).Str("script", name).Msg("Failed to save strategy version")
+			a.writeError(w, "Script saved but its version was not recorded", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	a.logger.Info().Str("script", name).Str("path", path).Msg("Strategy script saved")
+	a.writeJSON(w, map[string]interface{}{
+		"name":       name,
+		"path":       path,
+		"version":    version,
+		"validation": validation,
+	})
+}
+
+// handleGetScriptVersions lists the saved versions of a strategy script, newest first
+func (a *APIActor) handleGetScriptVersions(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	versions, err := a.store.GetStrategyVersions(name)
+	if err != nil {
+		a.logger.Error().Err(err).Str("script", name).Msg("Failed to get strategy versions")
+		a.writeError(w, "Failed to get script versions", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"name": name, "versions": versions})
+}
+
+// handleGetScriptVersion returns one saved version of a strategy script with its source
+func (a *APIActor) handleGetScriptVersion(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	number, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || number <= 0 {
+		a.writeError(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	version, err := a.store.GetStrategyVersion(name, number)
+	if err != nil {
+		a.logger.Error().Err(err).Str("script", name).Int("version", number).Msg("Failed to get strategy version")
+		a.writeError(w, "Failed to get script version", http.StatusInternalServerError)
+		return
+	}
+	if version == nil {
+		a.writeError(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	a.writeJSON(w, version)
+}
+
+// handleValidateScript validates a script, the saved one unless source is given. With an exchange and
+// symbol its callbacks also run over recent klines of that pair.
+func (a *APIActor) handleValidateScript(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if !strategy.ValidScriptName(name) {
+			a.writeError(w, "Invalid script name, use letters, digits and underscores", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Source   string `json:"source,omitempty"`
+			Exchange string `json:"exchange,omitempty"`
+			Symbol   string `json:"symbol,omitempty"`
+			Limit    int    `json:"limit,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		source := req.Source
+		if source == "" {
+			saved, ok := a.readScript(w, name)
+			if !ok {
+				return
+			}
+			source = saved
+		}
+
+		validation, ok := a.validateScript(ctx, w, name, source, req.Exchange, req.Symbol, req.Limit)
+		if !ok {
+			return
+		}
+		a.writeJSON(w, validation)
+	}
+}
+
+// handleDeployScript validates the saved script on a pair and starts it there, or reloads it when it
+// already runs on that pair
+func (a *APIActor) handleDeployScript(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		var req struct {
+			Exchange string                 `json:"exchange"`
+			Symbol   string                 `json:"symbol"`
+			Legs     []string               `json:"legs,omitempty"`
+			Config   map[string]interface{} `json:"config,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Exchange == "" || req.Symbol == "" {
+			a.writeError(w, "Exchange and symbol are required", http.StatusBadRequest)
+			return
+		}
+
+		source, ok := a.readScript(w, name)
+		if !ok {
+			return
+		}
+
+		validation, ok := a.validateScript(ctx, w, name, source, req.Exchange, req.Symbol, 0)
+		if !ok {
+			return
+		}
+		if !validation.Valid {
+			a.writeValidationError(w, "Script failed validation", validation)
+			return
+		}
+
+		response, err := ctx.Request(a.exchangePIDs[req.Exchange], exchange.DeployStrategyMsg{
+			Strategy: name,
+			Symbol:   req.Symbol,
+			Legs:     req.Legs,
+			Config:   req.Config,
+		}, 5*time.Second).Result()
+		if err != nil {
+			a.logger.Error().Err(err).Str("script", name).Str("exchange", req.Exchange).Msg("Failed to deploy strategy")
+			a.writeError(w, "Failed to deploy strategy", http.StatusInternalServerError)
+			return
+		}
+
+		switch result := response.(type) {
+		case exchange.StrategyDeployedMsg:
+			status := "started"
+			if result.Reloaded {
+				status = "reloaded"
+			}
+			a.writeJSON(w, map[string]interface{}{
+				"id":         fmt.Sprintf("%s:%s:%s", req.Exchange, result.Symbol, result.Strategy),
+				"status":     status,
+				"validation": validation,
+			})
+		case error:
+			a.writeError(w, result.Error(), http.StatusBadRequest)
+		default:
+			a.writeError(w, "Unexpected response from exchange", http.StatusInternalServerError)
+		}
+	}
+}
+
+// validateScript validates source, replaying recent closed klines of symbol when an exchange is given. A dry run
+// that cannot fetch klines is reported as a warning rather than failing the validation. Invalid
+// parameters are written as an error.
+func (a *APIActor) validateScript(ctx *actor.Context, w http.ResponseWriter, name, source, exchangeName, symbol string, limit int) (*strategy.ValidationResult, bool) {
+	if limit <= 0 {
+		limit = defaultValidationKlines
+	}
+	if limit > maxChartKlines {
+		a.writeError(w, fmt.Sprintf("Invalid limit, use 1 to %d", maxChartKlines), http.StatusBadRequest)
+		return nil, false
+	}
+
+	var klines []*strategy.KlineData
+	var warnings []string
+	if exchangeName != "" {
+		exchangePID, exists := a.exchangePIDs[exchangeName]
+		if !exists {
+			a.writeError(w, "Exchange not found", http.StatusNotFound)
+			return nil, false
+		}
+		if symbol == "" {
+			a.writeError(w, "Symbol is required with an exchange", http.StatusBadRequest)
+			return nil, false
+		}
+
+		response, err := ctx.Request(exchangePID, exchange.GetChartDataMsg{
+			Symbol:   symbol,
+			Interval: strategy.ScriptInterval(source),
+			Limit:    limit,
+			Closed:   true,
+		}, 15*time.Second).Result()
+		switch result := response.(type) {
+		case exchange.ChartDataMsg:
+			for _, kline := range result.Klines {
+				klines = append(klines, &strategy.KlineData{
+					Timestamp: kline.Timestamp,
+					Open:      kline.Open,
+					High:      kline.High,
+					Low:       kline.Low,
+					Close:     kline.Close,
+					Volume:    kline.Volume,
+				})
+			}
+		case error:
+			err = result
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Dry run skipped: %v", err))
+		}
+	}
+
+	validation := a.newScriptEngine().ValidateScript(name, source, symbol, klines)
+	validation.Warnings = append(validation.Warnings, warnings...)
+	return validation, true
+}
+
+// newScriptEngine creates a strategy engine with the configured sandbox limits for validating scripts
+func (a *APIActor) newScriptEngine() *strategy.StrategyEngine {
+	engine := strategy.NewStrategyEngine(a.logger)
+	if a.config != nil {
+		engine.SetSandboxLimits(sandbox.NewLimits(a.config.Strategies.Sandbox))
+	}
+	return engine
+}
+
+// readScript reads a saved script, writing a not found or bad request error when it cannot
+func (a *APIActor) readScript(w http.ResponseWriter, name string) (string, bool) {
+	if !strategy.ValidScriptName(name) {
+		a.writeError(w, "Invalid script name, use letters, digits and underscores", http.StatusBadRequest)
+		return "", false
+	}
+
+	source, err := a.scripts.Read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		a.writeError(w, "Script not found", http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		a.logger.Error().Err(err).Str("script", name).Msg("Failed to read strategy script")
+		a.writeError(w, "Failed to read script", http.StatusInternalServerError)
+		return "", false
+	}
+	return source, true
+}
+
+// writeValidationError responds with a bad request carrying the validation errors
+func (a *APIActor) writeValidationError(w http.ResponseWriter, message string, validation *strategy.ValidationResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      message,
+		"validation": validation,
+	})
+}
+
+// historyRange is the period and bucket size requested from a history endpoint
+type historyRange struct {
+	From       time.Time
+	To         time.Time
+	Resolution string
+	Bucket     time.Duration
+}
+
+// parseHistoryRange reads the from, to and resolution query parameters, defaulting to the last 30 days
+func parseHistoryRange(r *http.Request, defaultResolution string) (historyRange, error) {
+	query := r.URL.Query()
+	period := historyRange{To: time.Now(), Resolution: defaultResolution}
+
+	if value := query.Get("to"); value != "" {
+		parsed, err := parseTime(value)
+		if err != nil {
+			return period, fmt.Errorf("invalid to: use RFC3339 or unix seconds")
+		}
+		period.To = parsed
+	}
+
+	period.From = period.To.AddDate(0, 0, -30)
+	if value := query.Get("from"); value != "" {
+		parsed, err := parseTime(value)
+		if err != nil {
+			return period, fmt.Errorf("invalid from: use RFC3339 or unix seconds")
+		}
+		period.From = parsed
+	}
+	if !period.From.Before(period.To) {
+		return period, fmt.Errorf("from must be before to")
+	}
+
+	if value := query.Get("resolution"); value != "" {
+		period.Resolution = value
+	}
+	bucket, err := portfolio.ParseResolution(period.Resolution)
+	if err != nil {
+		return period, err
+	}
+	if period.To.Sub(period.From)/bucket > maxHistoryPoints {
+		return period, fmt.Errorf("range too large for resolution %s, at most %d points", period.Resolution, maxHistoryPoints)
+	}
+	period.Bucket = bucket
+
+	return period, nil
+}
+
+// parseTime accepts RFC3339 timestamps and unix seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (a *APIActor) handleGetConsolidatedPortfolio(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.requestAggregator(ctx, w, aggregator.GetConsolidatedPortfolioMsg{})
+	}
+}
+
+// requestAggregator forwards a query to the aggregator actor and writes its response
+func (a *APIActor) requestAggregator(ctx *actor.Context, w http.ResponseWriter, msg interface{}) {
+	if a.aggregatorPID == nil {
+		a.writeError(w, "Aggregator not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	response, err := ctx.Request(a.aggregatorPID, msg, 5*time.Second).Result()
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to query aggregator")
+		a.writeError(w, "Failed to get consolidated data", http.StatusInternalServerError)
+		return
+	}
+
+	a.writeJSON(w, response)
+}
+
+func (a *APIActor) handleWebSocket(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := a.wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			a.logger.Error().Err(err).Msg("WebSocket upgrade failed")
+			return
+		}
+		defer conn.Close()
+
+		a.logger.Info().Str("remote", r.RemoteAddr).Msg("WebSocket connection established")
+
+		// Handle WebSocket connection
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				a.logger.Debug().Err(err).Msg("WebSocket read error")
+				break
+			}
+
+			// Echo back for now (TODO: implement real-time updates)
+			if err := conn.WriteMessage(messageType, p); err != nil {
+				a.logger.Debug().Err(err).Msg("WebSocket write error")
+				break
+			}
+		}
+
+		a.logger.Info().Str("remote", r.RemoteAddr).Msg("WebSocket connection closed")
+	}
+}
+
+// Risk management handlers
+
+func (a *APIActor) handleGetRiskParameters(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.logger.Info().Msg("Getting all risk parameters")
+
+		// Get all risk parameters
+		parameters := []string{
+			"max_position_size", "max_daily_loss", "max_portfolio_risk",
+			"max_correlation", "max_leverage", "max_daily_trades",
+			"max_hourly_trades", "var_limit", "max_drawdown_limit",
+			"concentration_limit",
+		}
+
+		result := make(map[string]interface{})
+
+		// For now, get from the first available exchange's risk manager
+		for _, exchangePID := range a.exchangePIDs {
+			for _, param := range parameters {
+				// Send request to exchange actor to get risk parameter
+				// Exchange actor will forward to risk manager
+				msg := map[string]interface{}{
+					"type":      "get_risk_parameter",
+					"parameter": param,
+				}
+
+				response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
+				if err != nil {
+					a.logger.Error().Err(err).Str("parameter", param).Msg("Failed to get risk parameter")
+					continue
+				}
+
+				result[param] = response
+			}
+			break // Only use first exchange for now
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"exchange":   "all", // or specific exchange
+			"parameters": result,
+		})
+	}
+}
+
+func (a *APIActor) handleSetRiskParameter(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Parameter string `json:"parameter"`
+			Value     string `json:"value"`
+			Exchange  string `json:"exchange,omitempty"`
+			Symbol    string `json:"symbol,omitempty"`
+			Strategy  string `json:"strategy,omitempty"`
+			Actor     string `json:"actor,omitempty"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.Parameter == "" || req.Value == "" {
+			http.Error(w, "Parameter and value are required", http.StatusBadRequest)
+			return
+		}
+		if req.Actor == "" {
+			req.Actor = "api"
+		}
+
+		a.logger.Info().
+			Str("parameter", req.Parameter).
+			Str("value", req.Value).
+			Str("exchange", req.Exchange).
+			Msg("Setting risk parameter")
+
+		// If no exchange specified, apply to all exchanges
+		exchanges := []string{}
+		if req.Exchange != "" {
+			exchanges = append(exchanges, req.Exchange)
+		} else {
+			for exchangeName := range a.exchangePIDs {
+				exchanges = append(exchanges, exchangeName)
+			}
+		}
+
+		results := make(map[string]interface{})
+		for _, exchangeName := range exchanges {
+			if exchangePID, exists := a.exchangePIDs[exchangeName]; exists {
+				msg := map[string]interface{}{
+					"type":      "set_risk_parameter",
+					"parameter": req.Parameter,
+					"value":     req.Value,
+					"symbol":    req.Symbol,
+					"strategy":  req.Strategy,
+					"actor":     req.Actor,
+				}
+
+				response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
+				if err != nil {
+					a.logger.Error().
+						Err(err).
+						Str("exchange", exchangeName).
+						Str("parameter", req.Parameter).
+						Msg("Failed to set risk parameter")
+					results[exchangeName] = map[string]interface{}{
+						"success": false,
+						"error":   err.Error(),
+					}
+				} else {
+					results[exchangeName] = map[string]interface{}{
+						"success": true,
+						"result":  response,
+					}
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+func (a *APIActor) handleGetRiskParameter(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parameter := chi.URLParam(r, "parameter")
+		if parameter == "" {
+			http.Error(w, "Parameter name is required", http.StatusBadRequest)
+			return
+		}
+
+		a.logger.Info().Str("parameter", parameter).Msg("Getting risk parameter")
+
+		// Get from first available exchange
+		for exchangeName, exchangePID := range a.exchangePIDs {
+			msg := map[string]interface{}{
+				"type":      "get_risk_parameter",
+				"parameter": parameter,
+			}
+
+			response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
+			if err != nil {
+				a.logger.Error().Err(err).Str("parameter", parameter).Msg("Failed to get risk parameter")
+				http.Error(w, "Failed to get risk parameter", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"exchange":  exchangeName,
+				"parameter": parameter,
+				"result":    response,
+			})
+			return
+		}
+
+		http.Error(w, "No exchanges available", http.StatusServiceUnavailable)
+	}
+}
+
+func (a *APIActor) handleGetRiskMetrics(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.logger.Info().Msg("Getting risk metrics")
+
+		results := make(map[string]interface{})
+
+		// Get risk metrics from all exchanges
+		for exchangeName, exchangePID := range a.exchangePIDs {
+			msg := map[string]interface{}{
+				"type": "get_risk_metrics",
+			}
+
+			response, err := ctx.Request(exchangePID, msg, 5*time.Second).Result()
+			if err != nil {
+				a.logger.Error().
+					Err(err).
+					Str("exchange", exchangeName).
+					Msg("Failed to get risk metrics")
+				results[exchangeName] = map[string]interface{}{
+					"error": err.Error(),
+				}
+			} else {
+				results[exchangeName] = response
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+func (a *APIActor) handleGetConsolidatedRisk(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.requestAggregator(ctx, w, aggregator.GetConsolidatedRiskMsg{})
+	}
+}
+
+// Settings handlers
+
+// handleGetSettingsSchema lists the typed setting definitions
+func (a *APIActor) handleGetSettingsSchema(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, map[string]interface{}{"settings": settings.Definitions()})
+}
+
+// settingsScope reads the exchange, strategy and symbol of a scope, the exchange must be configured
+func (a *APIActor) settingsScope(exchangeName, strategyName, symbol string) (settings.Scope, error) {
+	scope := settings.Scope{Exchange: exchangeName, Strategy: strategyName, Symbol: symbol}
+	if err := scope.Validate(); err != nil {
+		return scope, err
+	}
+	if scope.Exchange != "" && a.config != nil {
+		if _, ok := a.config.Exchanges[scope.Exchange]; !ok {
+			return scope, fmt.Errorf("unknown exchange %s", scope.Exchange)
+		}
+	}
+	return scope, nil
+}
+
+// handleGetSettings returns the effective value of every setting at a scope with where it is inherited from
+func (a *APIActor) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	scope, err := a.settingsScope(query.Get("exchange"), query.Get("strategy"), query.Get("symbol"))
+	if err != nil {
+		a.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values, err := settings.NewStore(a.store).Effective(scope)
+	if err != nil {
+		a.logger.Error().Err(err).Str("scope", scope.String()).Msg("Failed to get settings")
+		a.writeError(w, "Failed to get settings", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"scope": scope, "settings": values})
+}
+
+// handleSetSetting stores a setting at a scope and records the change in the history
+func (a *APIActor) handleSetSetting(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req struct {
+			Value    string `json:"value"`
+			Exchange string `json:"exchange"`
+			Strategy string `json:"strategy"`
+			Symbol   string `json:"symbol"`
+			Actor    string `json:"actor"`
+			Note     string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			a.writeError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Actor == "" {
+			req.Actor = "api"
+		}
+
+		key := chi.URLParam(r, "key")
+		scope, err := a.settingsScope(req.Exchange, req.Strategy, req.Symbol)
+		if err == nil {
+			err = settings.Validate(scope, key, req.Value)
+		}
+		if err != nil {
+			a.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		change, err := settings.NewStore(a.store).Set(scope, key, req.Value, req.Actor, req.Note)
+		if err != nil {
+			a.logger.Error().Err(err).Str("key", key).Str("scope", scope.String()).Msg("Failed to store setting")
+			a.writeError(w, "Failed to store setting", http.StatusInternalServerError)
+			return
+		}
+		if change != nil {
+			a.reloadSettings(ctx)
+		}
+		a.writeJSON(w, map[string]interface{}{"changed": change != nil, "change": change})
+	}
+}
+
+// handleUnsetSetting removes a setting from a scope, so the value of a wider scope applies again
+func (a *APIActor) handleUnsetSetting(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		key := chi.URLParam(r, "key")
+		if _, ok := settings.Lookup(key); !ok {
+			a.writeError(w, fmt.Sprintf("unknown setting %s", key), http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		scope, err := a.settingsScope(query.Get("exchange"), query.Get("strategy"), query.Get("symbol"))
+		if err != nil {
+			a.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		actorName := query.Get("actor")
+		if actorName == "" {
+			actorName = "api"
+		}
+
+		change, err := settings.NewStore(a.store).Unset(scope, key, actorName, query.Get("note"))
+		if err != nil {
+			a.logger.Error().Err(err).Str("key", key).Str("scope", scope.String()).Msg("Failed to remove setting")
+			a.writeError(w, "Failed to remove setting", http.StatusInternalServerError)
+			return
+		}
+		if change != nil {
+			a.reloadSettings(ctx)
+		}
+		a.writeJSON(w, map[string]interface{}{"changed": change != nil, "change": change})
+	}
+}
+
+// handleGetSettingsHistory returns the recorded setting changes, newest first
+func (a *APIActor) handleGetSettingsHistory(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			a.writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	key := r.URL.Query().Get("key")
+	changes, err := settings.NewStore(a.store).History(key, limit)
+	if err != nil {
+		a.logger.Error().Err(err).Str("key", key).Msg("Failed to get setting history")
+		a.writeError(w, "Failed to get setting history", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"changes": changes})
+}
+
+// handleGetAudit returns audit journal entries newest first, filtered by time, type, exchange, symbol,
+// strategy and order ID
+func (a *APIActor) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := database.AuditFilter{
+		Type:     query.Get("type"),
+		Exchange: query.Get("exchange"),
+		Symbol:   query.Get("symbol"),
+		Strategy: query.Get("strategy"),
+		OrderID:  query.Get("order_id"),
+		Limit:    100,
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := parseTime(value)
+		if err != nil {
+			a.writeError(w, "Invalid from: use RFC3339 or unix seconds", http.StatusBadRequest)
+			return
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := parseTime(value)
+		if err != nil {
+			a.writeError(w, "Invalid to: use RFC3339 or unix seconds", http.StatusBadRequest)
+			return
+		}
+		filter.To = to
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			a.writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	entries, err := audit.Query(a.store, filter)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to query audit journal")
+		a.writeError(w, "Failed to query audit journal", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"entries": entries})
+}
+
+// handleGetSettingsDiff compares the settings at two versions, to defaults to the latest version
+func (a *APIActor) handleGetSettingsDiff(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	store := settings.NewStore(a.store)
+	latest, err := store.Version()
+	if err != nil {
+		a.logger.Error().Err(err).Msg("Failed to get settings version")
+		a.writeError(w, "Failed to compare settings", http.StatusInternalServerError)
+		return
+	}
+
+	from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil || from < 0 {
+		a.writeError(w, "Invalid from version", http.StatusBadRequest)
+		return
+	}
+	to := latest
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = strconv.ParseInt(value, 10, 64); err != nil || to < 0 {
+			a.writeError(w, "Invalid to version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	differences, err := store.Diff(from, to)
+	if err != nil {
+		a.logger.Error().Err(err).Int64("from", from).Int64("to", to).Msg("Failed to compare settings")
+		a.writeError(w, "Failed to compare settings", http.StatusInternalServerError)
+		return
+	}
+	a.writeJSON(w, map[string]interface{}{"from": from, "to": to, "differences": differences})
+}
+
+// handleRollbackSettings restores the settings of a prior version, recorded as new changes
+func (a *APIActor) handleRollbackSettings(ctx *actor.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.store == nil {
+			a.writeError(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req struct {
+			Version *int64 `json:"version"`
+			Actor   string `json:"actor"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == nil {
+			a.writeError(w, "A version is required", http.StatusBadRequest)
+			return
+		}
+		if req.Actor == "" {
+			req.Actor = "api"
+		}
+
+		store := settings.NewStore(a.store)
+		latest, err := store.Version()
+		if err != nil {
+			a.logger.Error().Err(err).Msg("Failed to get settings version")
+			a.writeError(w, "Failed to roll back settings", http.StatusInternalServerError)
+			return
+		}
+		if *req.Version < 0 || *req.Version > latest {
+			a.writeError(w, fmt.Sprintf("version %d does not exist, the latest version is %d", *req.Version, latest), http.StatusNotFound)
+			return
+		}
+
+		changes, err := store.Rollback(*req.Version, req.Actor)
+		if err != nil {
+			a.logger.Error().Err(err).Int64("version", *req.Version).Msg("Failed to roll back settings")
+			a.writeError(w, "Failed to roll back settings", http.StatusInternalServerError)
+			return
+		}
+		if len(changes) > 0 {
+			a.reloadSettings(ctx)
+		}
+
+		a.logger.Info().
+			Int64("version", *req.Version).
+			Int("changes", len(changes)).
+			Str("actor", req.Actor).
+			Msg("Settings rolled back")
+		a.writeJSON(w, map[string]interface{}{"version": *req.Version, "changes": changes})
+	}
+}
+
+// reloadSettings tells every exchange that the stored settings changed
+func (a *APIActor) reloadSettings(ctx *actor.Context) {
+	for _, exchangePID := range a.exchangePIDs {
+		ctx.Send(exchangePID, exchange.ReloadSettingsMsg{})
 	}
 }
 
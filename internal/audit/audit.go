@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// Entry types
+const (
+	TypeSignal        = "signal"         // A strategy signal with the kline and plotted values it was computed from
+	TypeRiskCheck     = "risk_check"     // An order validated by the risk manager and the result
+	TypeOrderRequest  = "order_request"  // An order sent to the exchange
+	TypeOrderResponse = "order_response" // The exchange response to an order request, or its error
+	TypeOrderCancel   = "order_cancel"   // An order cancelled and the result
+	TypeOrderAmend    = "order_amend"    // An order modified and the result
+	TypeAPICall       = "api_call"       // An API request that changes state, with the caller
+)
+
+// Entry is one record of the audit journal
+type Entry struct {
+	ID       int64                  `json:"id,omitempty"`
+	Time     time.Time              `json:"time"`
+	Type     string                 `json:"type"`
+	Exchange string                 `json:"exchange,omitempty"`
+	Symbol   string                 `json:"symbol,omitempty"`
+	Strategy string                 `json:"strategy,omitempty"`
+	OrderID  string                 `json:"order_id,omitempty"`
+	Actor    string                 `json:"actor,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Record broadcasts an entry on the engine's event stream, where the journal actor appends it.
+// A nil engine, as in tests that never start the actor system, drops the entry.
+func Record(engine *actor.Engine, entry Entry) {
+	if engine == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	engine.BroadcastEvent(entry)
+}
+
+// StatusMsg returns the number of entries written
+type StatusMsg struct{}
+
+// JournalActor appends every recorded entry to the audit_log table and, when configured, to a JSON Lines file.
+// Entries are never changed or removed.
+type JournalActor struct {
+	config *config.Config
+	db     *database.DB
+	logger zerolog.Logger
+
+	file    *os.File
+	written int
+	failed  int
+}
+
+// New creates a new journal actor
+func New(cfg *config.Config, db *database.DB, logger zerolog.Logger) *JournalActor {
+	return &JournalActor{
+		config: cfg,
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Receive handles incoming messages
+func (j *JournalActor) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case actor.Started:
+		j.onStarted(ctx)
+	case actor.Stopped:
+		j.onStopped(ctx)
+	case Entry:
+		j.onEntry(msg)
+	case StatusMsg:
+		j.onStatus(ctx)
+	default:
+		// The event stream also carries notifications and the engine's own events
+	}
+}
+
+func (j *JournalActor) onStarted(ctx *actor.Context) {
+	if j.config != nil && j.config.Audit.File != "" {
+		file, err := os.OpenFile(j.config.Audit.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			j.logger.Error().Err(err).Str("file", j.config.Audit.File).Msg("Audit file disabled")
+		} else {
+			j.file = file
+		}
+	}
+
+	ctx.Engine().Subscribe(ctx.PID())
+
+	j.logger.Info().
+		Bool("file", j.file != nil).
+		Msg("Audit journal started")
+}
+
+func (j *JournalActor) onStopped(ctx *actor.Context) {
+	ctx.Engine().Unsubscribe(ctx.PID())
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.logger.Debug().
+		Int("written", j.written).
+		Msg("Audit journal stopped")
+}
+
+func (j *JournalActor) onEntry(entry Entry) {
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		// Keep the entry, only its details could not be encoded
+		entry.Data = map[string]interface{}{"encoding_error": err.Error()}
+		data, _ = json.Marshal(entry.Data)
+	}
+
+	if j.db != nil {
+		record := &database.AuditEntry{
+			Time:     entry.Time,
+			Type:     entry.Type,
+			Exchange: entry.Exchange,
+			Symbol:   entry.Symbol,
+			Strategy: entry.Strategy,
+			OrderID:  entry.OrderID,
+			Actor:    entry.Actor,
+			Message:  entry.Message,
+			Data:     string(data),
+		}
+		if err := j.db.AppendAuditEntry(record); err != nil {
+			j.failed++
+			j.logger.Error().Err(err).Str("type", entry.Type).Msg("Failed to store audit entry")
+		} else {
+			entry.ID = record.ID
+		}
+	}
+
+	if j.file != nil {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = j.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			j.failed++
+			j.logger.Error().Err(err).Str("type", entry.Type).Msg("Failed to write audit entry")
+		}
+	}
+
+	j.written++
+}
+
+func (j *JournalActor) onStatus(ctx *actor.Context) {
+	ctx.Respond(map[string]interface{}{
+		"written": j.written,
+		"failed":  j.failed,
+		"file":    j.file != nil,
+	})
+}
+
+// Query returns the stored entries matching filter, newest first
+func Query(db *database.DB, filter database.AuditFilter) ([]Entry, error) {
+	records, err := db.GetAuditEntries(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, record := range records {
+		entry := Entry{
+			ID:       record.ID,
+			Time:     record.Time,
+			Type:     record.Type,
+			Exchange: record.Exchange,
+			Symbol:   record.Symbol,
+			Strategy: record.Strategy,
+			OrderID:  record.OrderID,
+			Actor:    record.Actor,
+			Message:  record.Message,
+		}
+		if err := json.Unmarshal([]byte(record.Data), &entry.Data); err != nil {
+			return nil, fmt.Errorf("audit entry %d: %w", record.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+func TestJournalRecordsEntries(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	cfg := &config.Config{Audit: config.AuditConfig{File: filepath.Join(dir, "audit.jsonl")}}
+	pid := engine.Spawn(func() actor.Receiver { return New(cfg, db, zerolog.Nop()) }, "audit")
+
+	// Wait for the subscription before recording
+	if _, err := engine.Request(pid, StatusMsg{}, 5*time.Second).Result(); err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+
+	Record(engine, Entry{
+		Type:     TypeSignal,
+		Exchange: "bybit",
+		Symbol:   "BTCUSDT",
+		Strategy: "sma",
+		Data:     map[string]interface{}{"action": "buy", "indicators": map[string]float64{"sma": 101.5}},
+	})
+	Record(engine, Entry{Type: TypeOrderCancel, Exchange: "bybit", Symbol: "ETHUSDT", OrderID: "42"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		response, err := engine.Request(pid, StatusMsg{}, 5*time.Second).Result()
+		if err != nil {
+			t.Fatalf("status request failed: %v", err)
+		}
+		if response.(map[string]interface{})["written"].(int) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entries were not written, status %v", response)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	<-engine.Poison(pid).Done()
+
+	entries, err := Query(db, database.AuditFilter{Strategy: "sma"})
+	if err != nil {
+		t.Fatalf("failed to query journal: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Type != TypeSignal || entries[0].Time.IsZero() {
+		t.Fatalf("expected the signal, got %+v", entries)
+	}
+	if indicators, ok := entries[0].Data["indicators"].(map[string]interface{}); !ok || indicators["sma"] != 101.5 {
+		t.Errorf("expected the indicator values in the details, got %v", entries[0].Data)
+	}
+
+	file, err := os.Open(cfg.Audit.File)
+	if err != nil {
+		t.Fatalf("failed to open audit file: %v", err)
+	}
+	defer file.Close()
+
+	var lines []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 2 || lines[1].OrderID != "42" || lines[1].ID == 0 {
+		t.Errorf("expected both entries in the file with their IDs, got %+v", lines)
+	}
+}
@@ -0,0 +1,236 @@
+package configcheck
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/internal/exchange"
+	"github.com/arijanluiken/mercantile/internal/notifier"
+	"github.com/arijanluiken/mercantile/internal/sandbox"
+	"github.com/arijanluiken/mercantile/internal/strategy"
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// Check validates a configuration against what it refers to: on top of Config.Validate every exchange
+// must be supported, exchange accounts need a vault, every strategy script must exist, strategy parameters
+// must match the keys and types of the script's settings(), intervals must be streamed or derivable on the
+// exchange and notification channels must be complete. All problems are returned together as config.ValidationErrors.
+func Check(cfg *config.Config) error {
+	var errs config.ValidationErrors
+	if err := cfg.Validate(); err != nil {
+		validationErrs, ok := err.(config.ValidationErrors)
+		if !ok {
+			return err
+		}
+		errs = append(errs, validationErrs...)
+	}
+
+	factory := exchanges.NewFactory(zerolog.Nop())
+	engine := strategy.NewStrategyEngine(zerolog.Nop())
+	engine.SetSandboxLimits(sandbox.NewLimits(cfg.Strategies.Sandbox))
+
+	names := make([]string, 0, len(cfg.Exchanges))
+	for name := range cfg.Exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := "exchanges." + name
+		exchangeType := cfg.ExchangeType(name)
+		if supported := factory.GetSupportedExchanges(); !slices.Contains(supported, exchangeType) {
+			if cfg.Exchanges[name].Type != "" {
+				errs = append(errs, cfg.Invalid(path+".type", "unknown exchange %s, supported are %s", exchangeType, strings.Join(supported, ", ")))
+			} else {
+				errs = append(errs, cfg.Invalid(path, "unknown exchange, set type to one of %s", strings.Join(supported, ", ")))
+			}
+			continue
+		}
+		// The account itself is only readable once the vault is unlocked on startup
+		if cfg.Exchanges[name].Account != "" {
+			if _, err := os.Stat(cfg.Vault.Path); err != nil {
+				errs = append(errs, cfg.Invalid(path+".account", "vault %s not found, add the account with the vault command", cfg.Vault.Path))
+			}
+		}
+
+		for i, pair := range cfg.Exchanges[name].Pairs {
+			for j, strategyConfig := range pair.Strategies {
+				if strategyConfig.Name == "" {
+					continue
+				}
+				strategyPath := fmt.Sprintf("%s.pairs[%d].strategies[%d]", path, i, j)
+				errs = append(errs, checkStrategy(cfg, factory, engine, exchangeType, strategyPath, strategyConfig)...)
+			}
+		}
+	}
+
+	for i, channel := range cfg.Notifications.Channels {
+		if _, err := notifier.NewChannel(channel); err != nil {
+			errs = append(errs, cfg.Invalid(fmt.Sprintf("notifications.channels[%d]", i), "%v", err))
+		}
+	}
+	if cfg.Notifications.DailySummary != "" {
+		if _, err := notifier.ParseTimeOfDay(cfg.Notifications.DailySummary); err != nil {
+			errs = append(errs, cfg.Invalid("notifications.daily_summary", "%v", err))
+		}
+	}
+
+	return errs.Err()
+}
+
+// checkStrategy checks one configured strategy against its script
+func checkStrategy(cfg *config.Config, factory *exchanges.Factory, engine *strategy.StrategyEngine, exchangeType, path string, strategyConfig config.StrategyConfig) config.ValidationErrors {
+	var errs config.ValidationErrors
+
+	types, err := engine.GetStrategySettingTypes(strategyConfig.Name)
+	if err != nil {
+		return append(errs, cfg.Invalid(path+".name", "%v", err))
+	}
+	errs = append(errs, checkParameters(cfg, path+".config", strategyConfig, types)...)
+
+	// The primary interval may be overridden in config, further timeframes come from settings()
+	interval, err := engine.GetStrategyInterval(strategyConfig.Name)
+	if err != nil {
+		return append(errs, cfg.Invalid(path+".name", "%v", err))
+	}
+	intervalPath := path + ".name"
+	if override, ok := strategyConfig.Config["interval"].(string); ok && override != "" {
+		interval, intervalPath = override, path+".config.interval"
+	}
+	if err := checkInterval(factory, exchangeType, interval); err != nil {
+		errs = append(errs, cfg.Invalid(intervalPath, "interval %s is not available on %s: %v", interval, exchangeType, err))
+	}
+
+	timeframes, err := engine.GetStrategyTimeframes(strategyConfig.Name)
+	if err != nil {
+		return append(errs, cfg.Invalid(path+".name", "%v", err))
+	}
+	declared := make([]string, 0, len(timeframes))
+	for timeframe := range timeframes {
+		declared = append(declared, timeframe)
+	}
+	sort.Strings(declared)
+	for _, timeframe := range declared {
+		if err := checkInterval(factory, exchangeType, timeframe); err != nil {
+			errs = append(errs, cfg.Invalid(path+".name", "timeframe %s of %s is not available on %s: %v", timeframe, strategyConfig.Name, exchangeType, err))
+		}
+	}
+
+	return errs
+}
+
+// checkParameters compares the parameters of a strategy with the keys and types its settings() declares.
+// Scripts without settings() accept any parameter.
+func checkParameters(cfg *config.Config, path string, strategyConfig config.StrategyConfig, types map[string]string) config.ValidationErrors {
+	var errs config.ValidationErrors
+
+	keys := make([]string, 0, len(strategyConfig.Config))
+	for key := range strategyConfig.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	declared := make([]string, 0, len(types))
+	for key := range types {
+		declared = append(declared, key)
+	}
+	sort.Strings(declared)
+
+	for _, key := range keys {
+		value := strategyConfig.Config[key]
+		if types != nil {
+			expected, ok := types[key]
+			if !ok {
+				errs = append(errs, cfg.Invalid(path+"."+key, "unknown parameter of %s, settings() declares %s", strategyConfig.Name, strings.Join(declared, ", ")))
+				continue
+			}
+			if !compatible(expected, value) {
+				errs = append(errs, cfg.Invalid(path+"."+key, "expected %s like settings() of %s, got %s", expected, strategyConfig.Name, typeName(value)))
+				continue
+			}
+		}
+
+		// Position sizes are a share of the available balance
+		if key == "position_size" {
+			if size, ok := number(value); ok && (size <= 0 || size > 1) {
+				errs = append(errs, cfg.Invalid(path+"."+key, "%g must be greater than 0 and at most 1", size))
+			}
+		}
+	}
+	return errs
+}
+
+// checkInterval returns an error unless klines of interval are streamed by the exchange or can be built
+// from klines it streams
+func checkInterval(factory *exchanges.Factory, exchangeName, interval string) error {
+	for !factory.SupportsInterval(exchangeName, interval) {
+		source, err := exchange.DerivedSource(interval)
+		if err != nil {
+			return err
+		}
+		interval = source
+	}
+	return nil
+}
+
+// compatible reports whether a YAML value can replace a settings() value of the given Starlark type
+func compatible(starlarkType string, value interface{}) bool {
+	switch starlarkType {
+	case "int":
+		_, ok := value.(int)
+		return ok
+	case "float":
+		_, ok := number(value)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "list", "tuple":
+		_, ok := value.([]interface{})
+		return ok
+	case "dict":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// typeName names a YAML value in the terms of Starlark types
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "dict"
+	}
+	return fmt.Sprintf("%T", value)
+}
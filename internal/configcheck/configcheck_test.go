@@ -0,0 +1,98 @@
+package configcheck
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+const script = `
+def settings():
+    return {
+        "interval": "1h",
+        "timeframes": {"4h": 50},
+        "period": 14,
+        "threshold": 0.5,
+        "position_size": 0.01,
+    }
+
+def on_kline(kline):
+    return {"action": "hold"}
+`
+
+// withScripts runs the test in a directory holding a strategy/trend.star script
+func withScripts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "strategy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "strategy", "trend.star"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+}
+
+func configWith(exchange string, strategies ...config.StrategyConfig) *config.Config {
+	return &config.Config{
+		API:     config.APIConfig{Port: 8080},
+		UI:      config.UIConfig{Port: 8081},
+		Logging: config.LoggingConfig{Level: "info"},
+		Exchanges: map[string]config.ExchangeConfig{
+			exchange: {Enabled: true, Pairs: []config.PairConfig{{Symbol: "BTCUSDT", Strategies: strategies}}},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	withScripts(t)
+
+	valid := []map[string]interface{}{
+		nil,
+		{"period": 21, "threshold": 1, "position_size": 0.5},
+		{"interval": "7m"},
+		{"interval": "ha:renko:50"},
+	}
+	for _, parameters := range valid {
+		if err := Check(configWith("bybit", config.StrategyConfig{Name: "trend", Config: parameters})); err != nil {
+			t.Errorf("%v: unexpected error: %v", parameters, err)
+		}
+	}
+
+	withAccount := configWith("bybit", config.StrategyConfig{Name: "trend"})
+	withAccount.Exchanges["bybit"] = config.ExchangeConfig{Enabled: true, Account: "main"}
+	withAccount.Vault.Path = "missing.vault"
+
+	hedge := configWith("bybit", config.StrategyConfig{Name: "trend", Config: map[string]interface{}{"interval": "7m"}})
+	hedge.Exchanges["bybit-hedge"] = config.ExchangeConfig{Enabled: true, Type: "bybit", Pairs: hedge.Exchanges["bybit"].Pairs}
+	hedge.Exchanges["bybit"] = config.ExchangeConfig{Enabled: false}
+	if err := Check(hedge); err != nil {
+		t.Errorf("expected an account of a supported exchange to be valid, got %v", err)
+	}
+	unknownType := configWith("bybit-hedge", config.StrategyConfig{Name: "trend"})
+
+	invalid := map[string]*config.Config{
+		"account without type": unknownType,
+		"missing vault":        withAccount,
+		"unknown parameter":    configWith("bybit", config.StrategyConfig{Name: "trend", Config: map[string]interface{}{"periods": 21}}),
+		"wrong type":           configWith("bybit", config.StrategyConfig{Name: "trend", Config: map[string]interface{}{"period": 14.5}}),
+		"position size":        configWith("bybit", config.StrategyConfig{Name: "trend", Config: map[string]interface{}{"position_size": 10}}),
+		"interval":             configWith("bybit", config.StrategyConfig{Name: "trend", Config: map[string]interface{}{"interval": "7x"}}),
+		"missing script":       configWith("bybit", config.StrategyConfig{Name: "missing"}),
+		"unknown exchange":     configWith("kraken", config.StrategyConfig{Name: "trend"}),
+	}
+	for name, cfg := range invalid {
+		err := Check(cfg)
+		var errs config.ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: expected one validation error, got %v", name, err)
+			continue
+		}
+		if !strings.HasPrefix(errs[0].Path, "exchanges.") {
+			t.Errorf("%s: unexpected path %s", name, errs[0].Path)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+const (
+	baseInterval    = "1m"  // Stream that derived time and alternative bars are built from
+	aggregatedBars  = 1000  // Closed bars kept per aggregator for strategy warm-up, the maximum buffer depth
+	maxReplayKlines = 10000 // Source klines replayed to build the history of a new aggregator
+)
+
+// barBuilder turns updates of source klines into derived bars
+type barBuilder interface {
+	// add processes an update of a source kline received at, returning the bars it closed or changed
+	add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline
+	// replay returns how many source klines to replay to build up to aggregatedBars bars
+	replay() int
+}
+
+// DerivedSource returns the interval a derived interval is built from, or an error when it cannot be built locally
+func DerivedSource(interval string) (string, error) {
+	_, source, err := newBarBuilder(interval)
+	return source, err
+}
+
+// newBarBuilder parses a derived interval, returning its builder and the interval it is built from.
+// Supported are N-minute time bars such as 7m, 2h or 3d, volume:X, tick:N, range:X, renko:X and
+// ha:<interval> for Heikin-Ashi bars of any other interval.
+func newBarBuilder(interval string) (barBuilder, string, error) {
+	kind, value, found := strings.Cut(interval, ":")
+	if !found {
+		minutes, err := intervalMinutes(interval)
+		if err != nil {
+			return nil, "", err
+		}
+		if minutes == 1 {
+			return nil, "", fmt.Errorf("interval %s is the base interval and cannot be derived", interval)
+		}
+		return &timeBars{period: time.Duration(minutes) * time.Minute}, baseInterval, nil
+	}
+
+	if kind == "ha" {
+		if value == "" {
+			return nil, "", fmt.Errorf("invalid interval %q: ha needs a source interval such as ha:1h", interval)
+		}
+		return &heikinAshiBars{}, value, nil
+	}
+
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size <= 0 || math.IsInf(size, 0) {
+		return nil, "", fmt.Errorf("invalid interval %q: %s needs a positive size", interval, kind)
+	}
+	switch kind {
+	case "volume":
+		return &thresholdBars{done: func(bar *exchanges.Kline, ticks int) bool { return bar.Volume >= size }}, baseInterval, nil
+	case "tick":
+		if size != math.Trunc(size) {
+			return nil, "", fmt.Errorf("invalid interval %q: tick needs a whole number of ticks", interval)
+		}
+		return &thresholdBars{done: func(bar *exchanges.Kline, ticks int) bool { return ticks >= int(size) }}, baseInterval, nil
+	case "range":
+		return &thresholdBars{done: func(bar *exchanges.Kline, ticks int) bool { return bar.High-bar.Low >= size }}, baseInterval, nil
+	case "renko":
+		return &renkoBars{size: size}, baseInterval, nil
+	}
+	return nil, "", fmt.Errorf("invalid interval %q: unknown bar type %s", interval, kind)
+}
+
+// intervalMinutes parses a time interval in minutes, hours or days into whole minutes
+func intervalMinutes(interval string) (int, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	count, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	switch interval[len(interval)-1] {
+	case 'm':
+		return count, nil
+	case 'h':
+		return count * 60, nil
+	case 'd':
+		return count * 24 * 60, nil
+	}
+	return 0, fmt.Errorf("invalid interval %q: only minutes, hours and days can be derived", interval)
+}
+
+// timeBars merges 1m klines into bars of a longer period, aligned to the Unix epoch in UTC
+type timeBars struct {
+	period     time.Duration
+	start      time.Time        // Open time of the bar in progress, zero before the first kline
+	closed     *exchanges.Kline // Closed source klines of the bar in progress merged
+	partial    *exchanges.Kline // Latest update of the source kline in progress
+	lastClosed time.Time        // Open time of the last closed source kline, to skip repeated updates
+}
+
+func (t *timeBars) add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline {
+	if !kline.Timestamp.After(t.lastClosed) && !t.lastClosed.IsZero() {
+		return nil
+	}
+
+	var bars []*exchanges.Kline
+	start := time.Unix(0, 0).UTC().Add(kline.Timestamp.Sub(time.Unix(0, 0)).Truncate(t.period))
+	if !t.start.IsZero() && start.Before(t.start) {
+		return nil
+	}
+	if !t.start.IsZero() && start.After(t.start) {
+		// The last source kline of the previous bar was missed, close it with what it has
+		if bar := t.bar(true); bar != nil {
+			bars = append(bars, bar)
+		}
+		t.closed, t.partial = nil, nil
+	}
+	t.start = start
+
+	if kline.IsClosed {
+		t.closed = mergeKlines(t.closed, kline)
+		t.partial = nil
+		t.lastClosed = kline.Timestamp
+		if !kline.Timestamp.Add(time.Minute).Before(start.Add(t.period)) {
+			bars = append(bars, t.bar(true))
+			t.start, t.closed = time.Time{}, nil
+			return bars
+		}
+	} else {
+		t.partial = kline
+	}
+	return append(bars, t.bar(false))
+}
+
+// bar returns the bar in progress from the closed and partial source klines, nil without either
+func (t *timeBars) bar(closed bool) *exchanges.Kline {
+	merged := mergeKlines(t.closed, t.partial)
+	if merged == nil {
+		return nil
+	}
+	merged.Timestamp = t.start
+	merged.IsClosed = closed
+	return merged
+}
+
+func (t *timeBars) replay() int {
+	return min(int(t.period/time.Minute)*aggregatedBars, maxReplayKlines)
+}
+
+// mergeKlines returns a new kline spanning a followed by b, either may be nil
+func mergeKlines(a, b *exchanges.Kline) *exchanges.Kline {
+	if a == nil && b == nil {
+		return nil
+	}
+	if a == nil {
+		only := *b
+		return &only
+	}
+	merged := *a
+	if b == nil {
+		return &merged
+	}
+	merged.High = math.Max(a.High, b.High)
+	merged.Low = math.Min(a.Low, b.Low)
+	merged.Close = b.Close
+	merged.Volume = a.Volume + b.Volume
+	return &merged
+}
+
+// heikinAshiBars smooths bars of the source interval into Heikin-Ashi bars with the same open times
+type heikinAshiBars struct {
+	prevOpen  float64
+	prevClose float64
+	started   bool
+}
+
+func (h *heikinAshiBars) add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline {
+	haClose := (kline.Open + kline.High + kline.Low + kline.Close) / 4
+	haOpen := (kline.Open + kline.Close) / 2
+	if h.started {
+		haOpen = (h.prevOpen + h.prevClose) / 2
+	}
+	if kline.IsClosed {
+		h.prevOpen, h.prevClose, h.started = haOpen, haClose, true
+	}
+
+	return []*exchanges.Kline{{
+		Timestamp: kline.Timestamp,
+		Open:      haOpen,
+		High:      math.Max(kline.High, math.Max(haOpen, haClose)),
+		Low:       math.Min(kline.Low, math.Min(haOpen, haClose)),
+		Close:     haClose,
+		Volume:    kline.Volume,
+		IsClosed:  kline.IsClosed,
+	}}
+}
+
+func (h *heikinAshiBars) replay() int {
+	return aggregatedBars
+}
+
+// tickFeed turns updates of 1m klines into ticks: the latest price and the volume traded since the
+// previous update. Updates that change neither, and updates of klines that already closed, are skipped.
+type tickFeed struct {
+	open    time.Time
+	volume  float64
+	price   float64
+	closed  bool
+	started bool
+}
+
+func (f *tickFeed) tick(kline *exchanges.Kline) (price, volume float64, ok bool) {
+	if f.started && kline.Timestamp.Before(f.open) {
+		return 0, 0, false
+	}
+	if f.started && kline.Timestamp.Equal(f.open) {
+		if f.closed || (kline.Close == f.price && kline.Volume == f.volume) {
+			return 0, 0, false
+		}
+		volume = math.Max(kline.Volume-f.volume, 0)
+	} else {
+		volume = kline.Volume
+	}
+	f.open, f.volume, f.price, f.closed, f.started = kline.Timestamp, kline.Volume, kline.Close, kline.IsClosed, true
+	return kline.Close, volume, true
+}
+
+// openTimes hands out strictly increasing open times, so bars formed by the same update stay separate
+type openTimes struct {
+	last time.Time
+}
+
+func (o *openTimes) next(at time.Time) time.Time {
+	if !at.After(o.last) {
+		at = o.last.Add(time.Millisecond)
+	}
+	o.last = at
+	return at
+}
+
+// thresholdBars builds a bar from ticks until done reports it complete, which makes volume, tick and range bars
+type thresholdBars struct {
+	feed    tickFeed
+	times   openTimes
+	current *exchanges.Kline
+	ticks   int
+	done    func(bar *exchanges.Kline, ticks int) bool
+}
+
+func (b *thresholdBars) add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline {
+	price, volume, ok := b.feed.tick(kline)
+	if !ok {
+		return nil
+	}
+
+	if b.current == nil {
+		b.current = &exchanges.Kline{Timestamp: b.times.next(at), Open: price, High: price, Low: price}
+		b.ticks = 0
+	}
+	b.current.High = math.Max(b.current.High, price)
+	b.current.Low = math.Min(b.current.Low, price)
+	b.current.Close = price
+	b.current.Volume += volume
+	b.ticks++
+
+	bar := *b.current
+	if b.done(&bar, b.ticks) {
+		bar.IsClosed = true
+		b.current = nil
+	}
+	return []*exchanges.Kline{&bar}
+}
+
+func (b *thresholdBars) replay() int {
+	return maxReplayKlines
+}
+
+// renkoBars adds a brick each time the price moves size beyond the last brick, a reversal needs twice that.
+// Bricks are only emitted once complete, the volume traded while a brick formed goes to its first brick.
+type renkoBars struct {
+	size    float64
+	feed    tickFeed
+	times   openTimes
+	top     float64 // Upper and lower edge of the last brick
+	bottom  float64
+	since   time.Time // When the next brick started forming
+	volume  float64
+	started bool
+}
+
+func (r *renkoBars) add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline {
+	price, volume, ok := r.feed.tick(kline)
+	if !ok {
+		return nil
+	}
+	if !r.started {
+		r.top, r.bottom, r.since, r.started = price, price, at, true
+	}
+	r.volume += volume
+
+	var bricks []*exchanges.Kline
+	for {
+		var open, close float64
+		switch {
+		case price >= r.top+r.size:
+			open, close = r.top, r.top+r.size
+			r.bottom, r.top = r.top, close
+		case price <= r.bottom-r.size:
+			open, close = r.bottom, r.bottom-r.size
+			r.top, r.bottom = r.bottom, close
+		default:
+			return bricks
+		}
+		bricks = append(bricks, &exchanges.Kline{
+			Timestamp: r.times.next(r.since),
+			Open:      open,
+			High:      math.Max(open, close),
+			Low:       math.Min(open, close),
+			Close:     close,
+			Volume:    r.volume,
+			IsClosed:  true,
+		})
+		r.volume = 0
+		r.since = at
+	}
+}
+
+func (r *renkoBars) replay() int {
+	return maxReplayKlines
+}
+
+// aggregator builds the bars of one derived interval for a symbol and keeps its recent closed bars
+type aggregator struct {
+	symbol     string
+	interval   string
+	source     string
+	builder    barBuilder
+	bars       []*exchanges.Kline // Closed bars, oldest first
+	lastSource time.Time          // Open time of the last closed source kline, older updates are skipped
+}
+
+// add feeds an update of a source kline, returning the derived bars it produced. Updates of source klines
+// that already closed are skipped, so klines both in the history and buffered while it downloaded count once.
+func (a *aggregator) add(kline *exchanges.Kline, at time.Time) []*exchanges.Kline {
+	if !a.lastSource.IsZero() && !kline.Timestamp.After(a.lastSource) {
+		return nil
+	}
+	if kline.IsClosed {
+		a.lastSource = kline.Timestamp
+	}
+
+	bars := a.builder.add(kline, at)
+	for _, bar := range bars {
+		bar.Symbol = a.symbol
+		bar.Interval = a.interval
+		if bar.IsClosed {
+			a.bars = append(a.bars, bar)
+		}
+	}
+	if len(a.bars) > aggregatedBars {
+		a.bars = a.bars[len(a.bars)-aggregatedBars:]
+	}
+	return bars
+}
+
+// recent returns up to limit of the latest closed bars, oldest first
+func (a *aggregator) recent(limit int) []*exchanges.Kline {
+	bars := a.bars
+	if limit > 0 && len(bars) > limit {
+		bars = bars[len(bars)-limit:]
+	}
+	return append([]*exchanges.Kline(nil), bars...)
+}
+
+// isNative reports whether the exchange streams klines of interval. Exchanges that do not say are
+// assumed to stream every time interval, leaving only alternative bars to be derived.
+func (e *ExchangeActor) isNative(interval string) bool {
+	if provider, ok := e.exchange.(exchanges.IntervalProvider); ok {
+		return provider.SupportsInterval(interval)
+	}
+	return !strings.Contains(interval, ":")
+}
+
+// pendingAggregator buffers the source klines of an aggregator whose history is still downloading
+type pendingAggregator struct {
+	symbol string
+	source string
+	klines []pendingKline
+}
+
+type pendingKline struct {
+	kline *exchanges.Kline
+	at    time.Time
+}
+
+// ensureAggregator starts building the bars of a derived interval for a symbol, subscribing to its source stream
+func (e *ExchangeActor) ensureAggregator(ctx *actor.Context, symbol, interval string) error {
+	key := fmt.Sprintf("%s:%s", symbol, interval)
+
+	e.aggregatorsMu.Lock()
+	_, exists := e.aggregators[key]
+	_, pending := e.pendingAggregators[key]
+	e.aggregatorsMu.Unlock()
+	if exists || pending {
+		return nil
+	}
+
+	_, source, err := newBarBuilder(interval)
+	if err != nil {
+		return err
+	}
+	if e.isNative(source) {
+		if !e.subscribedKlines[fmt.Sprintf("%s:%s", symbol, source)] {
+			e.onSubscribeKlines(ctx, SubscribeKlinesMsg{Symbols: []string{symbol}, Interval: source})
+		}
+	} else if err := e.ensureAggregator(ctx, symbol, source); err != nil {
+		return err
+	}
+
+	// The history is downloaded without the lock, which would otherwise stall the WebSocket goroutine.
+	// Source klines arriving meanwhile are buffered and merged once the history is in.
+	e.aggregatorsMu.Lock()
+	e.pendingAggregators[key] = &pendingAggregator{symbol: symbol, source: source}
+	e.aggregatorsMu.Unlock()
+
+	agg, err := e.newAggregator(symbol, interval)
+
+	e.aggregatorsMu.Lock()
+	defer e.aggregatorsMu.Unlock()
+	buffered := e.pendingAggregators[key].klines
+	delete(e.pendingAggregators, key)
+	if err != nil {
+		return err
+	}
+	e.aggregators[key] = agg
+	for _, pending := range buffered {
+		for _, bar := range agg.add(pending.kline, pending.at) {
+			e.routeKline(bar)
+			e.aggregateLocked(bar, pending.at)
+		}
+	}
+
+	e.logger.Info().
+		Str("symbol", symbol).
+		Str("interval", interval).
+		Str("source", source).
+		Int("history", len(agg.bars)).
+		Int("buffered", len(buffered)).
+		Msg("Building derived bars")
+	return nil
+}
+
+// newAggregator creates an aggregator with its history built from the recent klines of its source interval.
+// Without source history it starts empty and only builds bars from live klines. Must not hold aggregatorsMu.
+func (e *ExchangeActor) newAggregator(symbol, interval string) (*aggregator, error) {
+	builder, source, err := newBarBuilder(interval)
+	if err != nil {
+		return nil, err
+	}
+	agg := &aggregator{symbol: symbol, interval: interval, source: source, builder: builder}
+
+	var klines []*exchanges.Kline
+	if e.isNative(source) {
+		klines, err = e.historicalKlines(symbol, source, builder.replay())
+	} else if sourceAgg := e.aggregator(symbol, source); sourceAgg != nil {
+		e.aggregatorsMu.Lock()
+		klines = sourceAgg.recent(0)
+		e.aggregatorsMu.Unlock()
+	} else {
+		var sourceAgg *aggregator
+		if sourceAgg, err = e.newAggregator(symbol, source); err == nil {
+			klines = sourceAgg.recent(0)
+		}
+	}
+	if err != nil {
+		e.logger.Warn().Err(err).
+			Str("symbol", symbol).
+			Str("interval", interval).
+			Msg("No history for derived bars, building from live klines only")
+	}
+
+	sort.Slice(klines, func(i, j int) bool {
+		return klines[i].Timestamp.Before(klines[j].Timestamp)
+	})
+	for _, kline := range klines {
+		agg.add(kline, kline.Timestamp)
+	}
+	return agg, nil
+}
+
+// aggregator returns the running aggregator of a derived interval, nil when there is none
+func (e *ExchangeActor) aggregator(symbol, interval string) *aggregator {
+	e.aggregatorsMu.Lock()
+	defer e.aggregatorsMu.Unlock()
+	return e.aggregators[fmt.Sprintf("%s:%s", symbol, interval)]
+}
+
+// aggregatedKlines returns the latest closed bars of a derived interval, oldest first. Intervals no strategy
+// subscribed to are built from history on the spot.
+func (e *ExchangeActor) aggregatedKlines(symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	if agg := e.aggregator(symbol, interval); agg != nil {
+		e.aggregatorsMu.Lock()
+		defer e.aggregatorsMu.Unlock()
+		return agg.recent(limit), nil
+	}
+
+	agg, err := e.newAggregator(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	return agg.recent(limit), nil
+}
+
+// aggregate feeds a kline to the aggregators built from its interval and routes the bars they produce
+func (e *ExchangeActor) aggregate(kline *exchanges.Kline) {
+	e.aggregatorsMu.Lock()
+	defer e.aggregatorsMu.Unlock()
+
+	e.aggregateLocked(kline, time.Now())
+}
+
+func (e *ExchangeActor) aggregateLocked(kline *exchanges.Kline, at time.Time) {
+	for _, pending := range e.pendingAggregators {
+		if pending.symbol == kline.Symbol && pending.source == kline.Interval {
+			pending.klines = append(pending.klines, pendingKline{kline: kline, at: at})
+		}
+	}
+	for _, agg := range e.aggregators {
+		if agg.symbol != kline.Symbol || agg.source != kline.Interval {
+			continue
+		}
+		for _, bar := range agg.add(kline, at) {
+			e.routeKline(bar)
+			e.aggregateLocked(bar, at)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package exchange
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minute returns the 1m kline opening i minutes after start
+func minute(i int, open, high, low, close, volume float64, closed bool) *exchanges.Kline {
+	return &exchanges.Kline{
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		Timestamp: start.Add(time.Duration(i) * time.Minute),
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    volume,
+		IsClosed:  closed,
+	}
+}
+
+// price returns a closed 1m kline that traded at one price
+func price(i int, value, volume float64) *exchanges.Kline {
+	return minute(i, value, value, value, value, volume, true)
+}
+
+func closedBars(bars []*exchanges.Kline) []*exchanges.Kline {
+	var closed []*exchanges.Kline
+	for _, bar := range bars {
+		if bar.IsClosed {
+			closed = append(closed, bar)
+		}
+	}
+	return closed
+}
+
+func TestNewBarBuilder(t *testing.T) {
+	valid := map[string]string{
+		"3m":          "1m",
+		"90m":         "1m",
+		"2h":          "1m",
+		"3d":          "1m",
+		"volume:10":   "1m",
+		"tick:50":     "1m",
+		"range:2.5":   "1m",
+		"renko:50":    "1m",
+		"ha:1h":       "1h",
+		"ha:renko:50": "renko:50",
+	}
+	for interval, expected := range valid {
+		_, source, err := newBarBuilder(interval)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", interval, err)
+			continue
+		}
+		if source != expected {
+			t.Errorf("%s: expected source %s, got %s", interval, expected, source)
+		}
+	}
+
+	for _, interval := range []string{"1m", "1w", "1M", "0m", "renko", "renko:0", "renko:-5", "tick:2.5", "volume:abc", "ha:", "point:10"} {
+		if _, _, err := newBarBuilder(interval); err == nil {
+			t.Errorf("expected an error for %q", interval)
+		}
+	}
+}
+
+func TestTimeBars(t *testing.T) {
+	builder, _, _ := newBarBuilder("3m")
+
+	var bars []*exchanges.Kline
+	bars = append(bars, builder.add(minute(0, 10, 12, 9, 11, 1, true), start)...)
+	bars = append(bars, builder.add(minute(1, 11, 11, 11, 11, 0.5, false), start)...)
+	bars = append(bars, builder.add(minute(1, 11, 15, 10, 14, 2, true), start)...)
+	// Repeated update of a closed kline is ignored
+	if repeated := builder.add(minute(1, 11, 15, 10, 14, 2, true), start); len(repeated) != 0 {
+		t.Errorf("expected a repeated kline to be ignored, got %d bars", len(repeated))
+	}
+	bars = append(bars, builder.add(minute(2, 14, 14, 8, 13, 3, true), start)...)
+
+	if len(bars) != 4 {
+		t.Fatalf("expected 3 updates and the closed bar, got %d bars", len(bars))
+	}
+	if bars[1].IsClosed || bars[1].Close != 11 || bars[1].Volume != 1.5 {
+		t.Errorf("expected the bar in progress to include the minute in progress, got %+v", bars[1])
+	}
+	bar := bars[3]
+	if !bar.IsClosed || !bar.Timestamp.Equal(start) || bar.Open != 10 || bar.High != 15 || bar.Low != 8 || bar.Close != 13 || bar.Volume != 6 {
+		t.Errorf("unexpected closed bar %+v", bar)
+	}
+
+	// A bar whose last minute was missed closes when the next bar starts
+	builder.add(price(3, 20, 1), start)
+	bars = builder.add(price(6, 30, 1), start)
+	if len(bars) != 2 || !bars[0].IsClosed || !bars[0].Timestamp.Equal(start.Add(3*time.Minute)) || bars[0].Close != 20 {
+		t.Errorf("expected the previous bar to close, got %+v", bars)
+	}
+	if bars[1].IsClosed || !bars[1].Timestamp.Equal(start.Add(6*time.Minute)) {
+		t.Errorf("expected the next bar in progress, got %+v", bars[1])
+	}
+}
+
+func TestHeikinAshiBars(t *testing.T) {
+	builder, _, _ := newBarBuilder("ha:1h")
+
+	first := builder.add(&exchanges.Kline{Timestamp: start, Open: 10, High: 14, Low: 8, Close: 12, IsClosed: true}, start)[0]
+	if first.Open != 11 || first.Close != 11 || first.High != 14 || first.Low != 8 {
+		t.Errorf("unexpected first bar %+v", first)
+	}
+
+	second := builder.add(&exchanges.Kline{Timestamp: start.Add(time.Hour), Open: 12, High: 20, Low: 12, Close: 20, IsClosed: true}, start)[0]
+	if second.Open != 11 || second.Close != 16 || second.High != 20 || second.Low != 11 {
+		t.Errorf("unexpected second bar %+v", second)
+	}
+}
+
+func TestThresholdBars(t *testing.T) {
+	volume, _, _ := newBarBuilder("volume:5")
+	var bars []*exchanges.Kline
+	for i, v := range []float64{2, 2, 2, 1, 1, 3} {
+		bars = append(bars, volume.add(price(i, float64(100+i), v), start.Add(time.Duration(i)*time.Minute))...)
+	}
+	closed := closedBars(bars)
+	if len(closed) != 2 || closed[0].Volume != 6 || closed[0].Close != 102 || closed[1].Volume != 5 || closed[1].Open != 103 {
+		t.Errorf("unexpected volume bars %+v", closed)
+	}
+	if !closed[1].Timestamp.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("expected a bar to open at its first tick, got %v", closed[1].Timestamp)
+	}
+
+	ticks, _, _ := newBarBuilder("tick:3")
+	bars = nil
+	// Updates of one minute count as separate ticks, repeats without a change do not
+	for _, kline := range []*exchanges.Kline{
+		minute(0, 10, 10, 10, 10, 1, false),
+		minute(0, 10, 11, 10, 11, 2, false),
+		minute(0, 10, 11, 10, 11, 2, false),
+		minute(0, 10, 12, 10, 12, 4, true),
+	} {
+		bars = append(bars, ticks.add(kline, start)...)
+	}
+	closed = closedBars(bars)
+	if len(closed) != 1 || closed[0].Close != 12 || closed[0].High != 12 || closed[0].Volume != 4 {
+		t.Errorf("unexpected tick bars %+v", closed)
+	}
+
+	ranges, _, _ := newBarBuilder("range:5")
+	bars = nil
+	for i, p := range []float64{100, 103, 98, 99, 102} {
+		bars = append(bars, ranges.add(price(i, p, 1), start)...)
+	}
+	closed = closedBars(bars)
+	if len(closed) != 1 || closed[0].High != 103 || closed[0].Low != 98 || closed[0].Close != 98 {
+		t.Errorf("unexpected range bars %+v", closed)
+	}
+	if next := bars[len(bars)-1]; next.IsClosed || next.Open != 99 || next.Close != 102 {
+		t.Errorf("expected the next bar in progress, got %+v", next)
+	}
+	// Bars opened by updates with the same time still get increasing open times
+	if !bars[len(bars)-1].Timestamp.After(closed[0].Timestamp) {
+		t.Errorf("expected increasing open times, got %v and %v", closed[0].Timestamp, bars[len(bars)-1].Timestamp)
+	}
+}
+
+func TestRenkoBars(t *testing.T) {
+	builder, _, _ := newBarBuilder("renko:10")
+
+	var bricks []*exchanges.Kline
+	for i, p := range []float64{100, 105, 125, 115, 95} {
+		bricks = append(bricks, builder.add(price(i, p, 1), start.Add(time.Duration(i)*time.Minute))...)
+	}
+
+	// Two up bricks from 100 to 120, 115 is no reversal, 95 is two bricks below 120
+	expected := [][2]float64{{100, 110}, {110, 120}, {110, 100}}
+	if len(bricks) != len(expected) {
+		t.Fatalf("expected %d bricks, got %+v", len(expected), bricks)
+	}
+	for i, brick := range bricks {
+		if brick.Open != expected[i][0] || brick.Close != expected[i][1] || !brick.IsClosed {
+			t.Errorf("brick %d: expected %v, got %+v", i, expected[i], brick)
+		}
+		if i > 0 && !brick.Timestamp.After(bricks[i-1].Timestamp) {
+			t.Errorf("brick %d: expected increasing open times", i)
+		}
+	}
+	if bricks[0].Volume != 3 || bricks[1].Volume != 0 || bricks[2].Volume != 2 {
+		t.Errorf("expected volume on the first brick of each move, got %v, %v and %v", bricks[0].Volume, bricks[1].Volume, bricks[2].Volume)
+	}
+	if math.Abs(bricks[2].High-110) > 1e-9 || bricks[2].Low != 100 {
+		t.Errorf("unexpected down brick %+v", bricks[2])
+	}
+}
+
+// minuteExchange streams 1m klines natively and returns closing prices rising by one per minute
+type minuteExchange struct {
+	exchanges.Exchange
+	now time.Time
+}
+
+func (m *minuteExchange) SupportsInterval(interval string) bool {
+	return interval == "1m" || interval == "1h"
+}
+
+func (m *minuteExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	klines := make([]*exchanges.Kline, 0, limit)
+	for i := 1; i <= limit; i++ {
+		open := m.now.Add(-time.Duration(i) * time.Minute)
+		value := float64(open.Sub(start) / time.Minute)
+		klines = append(klines, &exchanges.Kline{Symbol: symbol, Interval: interval, Timestamp: open, Open: value, High: value, Low: value, Close: value, Volume: 1, IsClosed: true})
+	}
+	return klines, nil
+}
+
+func TestAggregatedKlines(t *testing.T) {
+	e := New("test", nil, nil, nil, zerolog.Nop())
+	e.exchange = &minuteExchange{now: start.Add(30 * time.Minute)}
+
+	if !e.isNative("1h") || !e.isNative("1m") || e.isNative("7m") {
+		t.Fatal("expected native intervals from the exchange")
+	}
+
+	bars, err := e.aggregatedKlines("BTCUSDT", "7m", 2)
+	if err != nil {
+		t.Fatalf("failed to build bars: %v", err)
+	}
+	if len(bars) != 2 {
+		t.Fatalf("expected 2 bars, got %d", len(bars))
+	}
+	// 7m bars aligned to the epoch open at 00:22 and 00:29, the latter is still in progress
+	last := bars[1]
+	if last.Interval != "7m" || last.Symbol != "BTCUSDT" || !last.Timestamp.Equal(start.Add(22*time.Minute)) || last.Close != 28 || last.Volume != 7 {
+		t.Errorf("unexpected last bar %+v", last)
+	}
+	if last.Timestamp.Sub(time.Unix(0, 0))%(7*time.Minute) != 0 {
+		t.Errorf("expected bars aligned to the epoch, got %v", last.Timestamp)
+	}
+
+	// Chained bars use the history of their source
+	ha, err := e.aggregatedKlines("BTCUSDT", "ha:7m", 1)
+	if err != nil || len(ha) != 1 || ha[0].Interval != "ha:7m" || !ha[0].Timestamp.Equal(last.Timestamp) {
+		t.Errorf("unexpected Heikin-Ashi bars %+v: %v", ha, err)
+	}
+}
+
+// slowExchange holds the kline history back until released, like a long paged download
+type slowExchange struct {
+	minuteExchange
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	close(s.started)
+	<-s.release
+	return s.minuteExchange.GetKlines(ctx, symbol, interval, limit)
+}
+
+func TestEnsureAggregatorBuffersKlines(t *testing.T) {
+	e := New("test", nil, nil, nil, zerolog.Nop())
+	slow := &slowExchange{minuteExchange: minuteExchange{now: start.Add(30 * time.Minute)}, started: make(chan struct{}), release: make(chan struct{})}
+	e.exchange = slow
+	e.subscribedKlines["BTCUSDT:1m"] = true
+
+	done := make(chan error)
+	go func() { done <- e.ensureAggregator(nil, "BTCUSDT", "7m") }()
+	<-slow.started
+
+	// Live klines must not wait for the history, the last one of it arrives again and counts once
+	aggregated := make(chan struct{})
+	go func() {
+		e.aggregate(minute(29, 29, 29, 29, 29, 1, true))
+		for i := 30; i <= 35; i++ {
+			e.aggregate(minute(i, float64(i), float64(i), float64(i), float64(i), 1, true))
+		}
+		close(aggregated)
+	}()
+	select {
+	case <-aggregated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("klines blocked while the history downloaded")
+	}
+
+	close(slow.release)
+	if err := <-done; err != nil {
+		t.Fatalf("failed to build the aggregator: %v", err)
+	}
+
+	bars, err := e.aggregatedKlines("BTCUSDT", "7m", 2)
+	if err != nil || len(bars) != 2 {
+		t.Fatalf("expected 2 bars, got %d: %v", len(bars), err)
+	}
+	if last := bars[1]; !last.Timestamp.Equal(start.Add(29*time.Minute)) || last.Open != 29 || last.Close != 35 || last.Volume != 7 {
+		t.Errorf("expected the buffered klines merged into the bar at 00:29, got %+v", last)
+	}
+	if len(e.pendingAggregators) != 0 {
+		t.Errorf("expected no pending aggregators, got %d", len(e.pendingAggregators))
+	}
+}
@@ -3,12 +3,19 @@ package exchange
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/history"
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/internal/notifier"
 	"github.com/arijanluiken/mercantile/internal/order"
 	"github.com/arijanluiken/mercantile/internal/portfolio"
 	"github.com/arijanluiken/mercantile/internal/rebalance"
@@ -18,6 +25,7 @@ import (
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
+	"github.com/arijanluiken/mercantile/pkg/vault"
 )
 
 // Messages for exchange actor communication
@@ -41,8 +49,9 @@ type (
 
 	// Notification messages
 	PortfolioActorCreatedMsg struct {
-		Exchange     string
-		PortfolioPID *actor.PID
+		Exchange       string
+		PortfolioPID   *actor.PID
+		RiskManagerPID *actor.PID
 	}
 	SetAPIActorMsg struct {
 		APIActorPID *actor.PID
@@ -54,6 +63,53 @@ type (
 		Interval string
 		Limit    int
 	}
+	CheckScriptsMsg struct{} // Poll strategy and rebalance scripts for changes
+
+	// Chart data for a symbol, with the series plotted by a strategy when one is named
+	GetChartDataMsg struct {
+		Symbol   string
+		Interval string // Defaults to the strategy's primary interval
+		Strategy string
+		Limit    int
+		Closed   bool // Only closed klines, without the bar still forming on the exchange
+	}
+	ChartDataMsg struct {
+		Interval string
+		Klines   []*exchanges.Kline // Oldest first
+		Plots    map[string][]strategy.PlotPoint
+	}
+
+	// Start a strategy on a symbol, or reload its script when it already runs there
+	DeployStrategyMsg struct {
+		Strategy string
+		Symbol   string
+		Legs     []string
+		Config   map[string]interface{} // Only applied when the strategy is started
+	}
+	StrategyDeployedMsg struct {
+		Strategy string
+		Symbol   string
+		Reloaded bool // The strategy was already running and reloads its script at the next bar
+	}
+
+	// Fill the kline store between From and To in the background, responding with the gaps being filled
+	DownloadKlinesMsg struct {
+		Symbol   string
+		Interval string
+		From     time.Time
+		To       time.Time
+	}
+	KlineDownloadMsg struct {
+		Gaps []history.Gap
+	}
+
+	// ApplyConfigMsg carries a reloaded configuration whose pairs and strategies replace the running ones
+	ApplyConfigMsg struct {
+		Config *config.Config
+	}
+
+	// ReloadSettingsMsg tells the exchange that stored settings changed, so its risk manager reloads them
+	ReloadSettingsMsg struct{}
 )
 
 type (
@@ -92,15 +148,19 @@ type (
 
 // ExchangeActor manages exchange connections and child actors
 type ExchangeActor struct {
-	exchangeName string
+	exchangeName string // Account name, such as "bybit-hedge"
+	exchangeType string // Exchange implementation, such as "bybit"
 	config       *config.Config
 	db           *database.DB
 	logger       zerolog.Logger
 	exchange     exchanges.Exchange
 	factory      *exchanges.Factory
+	history      *history.Downloader // Kline store, nil without a database
+	credentials  vault.Credentials
 
 	// Child actors
 	strategyActors  map[string]*actor.PID
+	strategyLegs    map[string][]string // Symbols traded per strategy key, primary symbol first
 	orderManagerPID *actor.PID
 	riskManagerPID  *actor.PID
 	portfolioPID    *actor.PID
@@ -116,6 +176,18 @@ type ExchangeActor struct {
 	// Strategy subscriptions: map[symbol:interval] -> []strategyPID for efficient routing
 	strategySubscriptions map[string][]*actor.PID
 
+	// Bars of derived intervals, keyed by symbol:interval. Guarded by aggregatorsMu because
+	// their source klines arrive on the exchange's WebSocket goroutine.
+	aggregators        map[string]*aggregator
+	pendingAggregators map[string]*pendingAggregator // History still downloading
+	aggregatorsMu      sync.Mutex
+
+	// Hot reload of changed strategy and rebalance scripts
+	scriptWatcher *strategy.ScriptWatcher
+
+	// Set by the first shutdown phase, strategies are no longer started or reloaded
+	shuttingDown bool
+
 	// Store actor system for sending messages from callbacks
 	actorSystem *actor.Engine
 }
@@ -123,20 +195,33 @@ type ExchangeActor struct {
 // New creates a new exchange actor
 func New(exchangeName string, exchangeConfig map[string]interface{}, cfg *config.Config, db *database.DB, logger zerolog.Logger) *ExchangeActor {
 	factory := exchanges.NewFactory(logger)
+	exchangeType := exchangeName
+	if cfg != nil {
+		exchangeType = cfg.ExchangeType(exchangeName)
+	}
 
 	return &ExchangeActor{
 		exchangeName:          exchangeName,
+		exchangeType:          exchangeType,
 		config:                cfg,
 		db:                    db,
 		logger:                logger,
 		factory:               factory,
 		strategyActors:        make(map[string]*actor.PID),
+		strategyLegs:          make(map[string][]string),
 		subscribedKlines:      make(map[string]bool),
 		subscribedOrderBooks:  make(map[string]bool),
 		strategySubscriptions: make(map[string][]*actor.PID),
+		aggregators:           make(map[string]*aggregator),
+		pendingAggregators:    make(map[string]*pendingAggregator),
 	}
 }
 
+// SetCredentials sets the API credentials used to connect, before the actor is spawned
+func (e *ExchangeActor) SetCredentials(credentials vault.Credentials) {
+	e.credentials = credentials
+}
+
 // Receive handles incoming messages
 func (e *ExchangeActor) Receive(ctx *actor.Context) {
 	switch msg := ctx.Message().(type) {
@@ -173,12 +258,30 @@ func (e *ExchangeActor) Receive(ctx *actor.Context) {
 		e.onPortfolioRequestBalances(ctx)
 	case portfolio.GetPositionsMsg:
 		e.onPortfolioRequestPositions(ctx)
+	case portfolio.GetTransfersMsg:
+		e.onPortfolioRequestTransfers(ctx)
 	case SetAPIActorMsg:
 		e.onSetAPIActor(ctx, msg)
 	case strategy.StrategySubscriptionMsg:
 		e.onStrategySubscription(ctx, msg)
 	case FetchHistoricalKlinesMsg:
 		e.onFetchHistoricalKlines(ctx, msg)
+	case GetChartDataMsg:
+		e.onGetChartData(ctx, msg)
+	case DeployStrategyMsg:
+		e.onDeployStrategy(ctx, msg)
+	case DownloadKlinesMsg:
+		e.onDownloadKlines(ctx, msg)
+	case CheckScriptsMsg:
+		e.onCheckScripts(ctx)
+	case ApplyConfigMsg:
+		e.onApplyConfig(ctx, msg)
+	case ShutdownPhaseMsg:
+		e.onShutdownPhase(ctx, msg)
+	case ReloadSettingsMsg:
+		if e.riskManagerPID != nil {
+			ctx.Send(e.riskManagerPID, risk.ReloadSettingsMsg{})
+		}
 	case map[string]interface{}:
 		e.onGenericMessage(ctx, msg)
 	default:
@@ -202,6 +305,41 @@ func (e *ExchangeActor) onStarted(ctx *actor.Context) {
 
 	// Start configured strategies
 	e.startConfiguredStrategies(ctx)
+
+	// Watch strategy and rebalance scripts so changes are picked up without a restart
+	e.scriptWatcher = strategy.NewScriptWatcher(e.config.Strategies.Directory, "strategy", "rebalance")
+	ctx.SendRepeat(ctx.PID(), CheckScriptsMsg{}, strategy.ScriptPollInterval)
+}
+
+// onCheckScripts forwards changed scripts to the strategy actors running them and to the rebalance actor
+func (e *ExchangeActor) onCheckScripts(ctx *actor.Context) {
+	if e.scriptWatcher == nil {
+		return
+	}
+
+	for _, path := range e.scriptWatcher.Scan() {
+		if filepath.Base(filepath.Dir(path)) == "rebalance" {
+			if e.rebalancePID != nil {
+				ctx.Send(e.rebalancePID, rebalance.ReloadScriptMsg{ScriptPath: path})
+			}
+			continue
+		}
+
+		strategyName := strategy.ScriptName(path)
+		reloaded := 0
+		for strategyKey, strategyPID := range e.strategyActors {
+			if strings.HasPrefix(strategyKey, strategyName+":") {
+				ctx.Send(strategyPID, strategy.ReloadStrategyMsg{})
+				reloaded++
+			}
+		}
+
+		e.logger.Info().
+			Str("script", path).
+			Str("strategy", strategyName).
+			Int("strategy_actors", reloaded).
+			Msg("Strategy script changed")
+	}
 }
 
 func (e *ExchangeActor) onInitialized(ctx *actor.Context) {
@@ -221,7 +359,15 @@ func (e *ExchangeActor) startConfiguredStrategies(ctx *actor.Context) {
 
 	// Start strategies for each configured pair
 	for _, pairConfig := range exchangeConfig.Pairs {
+		// Include the extra legs of multi-symbol strategies so their data is streamed too
 		symbols := []string{pairConfig.Symbol}
+		for _, strategyConfig := range pairConfig.Strategies {
+			for _, leg := range strategyConfig.Legs(pairConfig.Symbol) {
+				if !slices.Contains(symbols, leg) {
+					symbols = append(symbols, leg)
+				}
+			}
+		}
 
 		// Collect unique intervals from all strategies for this symbol
 		intervals := make(map[string]bool)
@@ -253,7 +399,7 @@ func (e *ExchangeActor) startConfiguredStrategies(ctx *actor.Context) {
 
 		// Start each strategy for this pair
 		for _, strategyConfig := range pairConfig.Strategies {
-			err := e.StartStrategy(ctx, strategyConfig.Name, pairConfig.Symbol, strategyConfig.Config)
+			err := e.StartStrategy(ctx, strategyConfig.Name, pairConfig.Symbol, strategyConfig.Legs(pairConfig.Symbol), strategyConfig.Config)
 			if err != nil {
 				e.logger.Error().
 					Err(err).
@@ -299,8 +445,9 @@ func (e *ExchangeActor) startChildActors(ctx *actor.Context) {
 	// Notify supervisor about portfolio actor creation
 	if ctx.Parent() != nil {
 		ctx.Send(ctx.Parent(), PortfolioActorCreatedMsg{
-			Exchange:     e.exchangeName,
-			PortfolioPID: portfolioPID,
+			Exchange:       e.exchangeName,
+			PortfolioPID:   portfolioPID,
+			RiskManagerPID: riskManagerPID,
 		})
 	}
 
@@ -333,6 +480,12 @@ func (e *ExchangeActor) startChildActors(ctx *actor.Context) {
 		PortfolioPID:    portfolioPID,
 	})
 
+	// Portfolio publishes its valuation to risk and rebalancing so both use the same numbers
+	ctx.Send(portfolioPID, portfolio.SetValuationTargetsMsg{
+		RiskManagerPID: riskManagerPID,
+		RebalancePID:   rebalancePID,
+	})
+
 	e.logger.Debug().Msg("Child actors started and wired successfully")
 }
 
@@ -343,29 +496,17 @@ func (e *ExchangeActor) onConnect(ctx *actor.Context) {
 	}
 
 	// Create exchange instance
-	exchangeConfig := map[string]interface{}{
-		"api_key": "", // These would come from config
-		"secret":  "",
-		"testnet": false,
-	}
-
-	if e.exchangeName == "bybit" {
-		exchangeConfig["api_key"] = e.config.BybitAPIKey
-		exchangeConfig["secret"] = e.config.BybitSecret
-		exchangeConfig["testnet"] = e.config.BybitTestnet
-	} else if e.exchangeName == "bitvavo" {
-		exchangeConfig["api_key"] = e.config.BitvavoAPIKey
-		exchangeConfig["secret"] = e.config.BitvavoSecret
-		exchangeConfig["testnet"] = e.config.BitvavoTestnet
-	}
-
-	exchange, err := e.factory.CreateExchange(e.exchangeName, exchangeConfig)
+	exchange, err := e.factory.CreateExchange(e.exchangeType, e.credentials.ExchangeConfig())
 	if err != nil {
 		e.logger.Error().Err(err).Msg("Failed to create exchange instance")
 		return
 	}
 
 	e.exchange = exchange
+	if e.db != nil {
+		// Accounts of one exchange share its market data
+		e.history = history.NewDownloader(e.db, e.exchangeType, exchange, e.logger)
+	}
 
 	// Connect to exchange
 	connectCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -459,7 +600,37 @@ func (e *ExchangeActor) OnKline(kline *exchanges.Kline) {
 		Time("timestamp", kline.Timestamp).
 		Msg("Received kline data")
 
-	// Send klines only to strategies that have subscribed to this symbol:interval combination
+	// Append closed klines to the store so it stays complete up to the latest bar
+	if e.db != nil {
+		if err := history.Record(e.db, e.exchangeType, kline); err != nil {
+			e.logger.Error().Err(err).
+				Str("symbol", kline.Symbol).
+				Str("interval", kline.Interval).
+				Msg("Failed to store kline")
+		}
+	}
+
+	e.routeKline(kline)
+	e.aggregate(kline)
+
+	// Update portfolio with current market prices
+	if e.portfolioPID != nil && e.actorSystem != nil {
+		priceUpdate := portfolio.UpdateMarketPricesMsg{
+			Prices: map[string]float64{
+				kline.Symbol: kline.Close,
+			},
+		}
+		mailbox.Send(e.actorSystem, e.portfolioPID, priceUpdate)
+	}
+
+	// Send price update to order manager for stop/trailing orders
+	if e.orderManagerPID != nil && e.actorSystem != nil {
+		mailbox.Send(e.actorSystem, e.orderManagerPID, order.PriceUpdateMsg{Symbol: kline.Symbol, Price: kline.Close})
+	}
+}
+
+// routeKline sends a kline only to strategies that have subscribed to its symbol:interval combination
+func (e *ExchangeActor) routeKline(kline *exchanges.Kline) {
 	subscriptionKey := fmt.Sprintf("%s:%s", kline.Symbol, kline.Interval)
 	subscribers := e.strategySubscriptions[subscriptionKey]
 
@@ -472,7 +643,7 @@ func (e *ExchangeActor) OnKline(kline *exchanges.Kline) {
 
 		for _, strategyPID := range subscribers {
 			if strategyPID != nil && e.actorSystem != nil {
-				e.actorSystem.Send(strategyPID, strategy.KlineDataMsg{Kline: kline})
+				strategy.SendMarketData(e.actorSystem, strategyPID, strategy.KlineDataMsg{Kline: kline})
 			}
 		}
 	} else {
@@ -482,26 +653,6 @@ func (e *ExchangeActor) OnKline(kline *exchanges.Kline) {
 			Str("interval", kline.Interval).
 			Msg("No strategies subscribed to this symbol:interval, skipping")
 	}
-
-	// Update portfolio with current market prices
-	if e.portfolioPID != nil && e.actorSystem != nil {
-		priceUpdate := portfolio.UpdateMarketPricesMsg{
-			Prices: map[string]float64{
-				kline.Symbol: kline.Close,
-			},
-		}
-		e.actorSystem.Send(e.portfolioPID, priceUpdate)
-	}
-
-	// Send price update to order manager for stop/trailing orders
-	if e.orderManagerPID != nil && e.actorSystem != nil {
-		priceUpdate := map[string]interface{}{
-			"type":   "price_update",
-			"symbol": kline.Symbol,
-			"price":  kline.Close,
-		}
-		e.actorSystem.Send(e.orderManagerPID, priceUpdate)
-	}
 }
 
 func (e *ExchangeActor) OnOrderBook(orderBook *exchanges.OrderBook) {
@@ -526,7 +677,7 @@ func (e *ExchangeActor) OnOrderBook(orderBook *exchanges.OrderBook) {
 	// Let strategies decide how to handle partial order book data
 	for _, strategyPID := range e.strategyActors {
 		if strategyPID != nil && e.actorSystem != nil {
-			e.actorSystem.Send(strategyPID, strategy.OrderBookDataMsg{OrderBook: orderBook})
+			strategy.SendMarketData(e.actorSystem, strategyPID, strategy.OrderBookDataMsg{OrderBook: orderBook})
 		}
 	}
 
@@ -546,12 +697,7 @@ func (e *ExchangeActor) OnOrderBook(orderBook *exchanges.OrderBook) {
 		}
 
 		if priceForUpdate > 0 {
-			priceUpdate := map[string]interface{}{
-				"type":   "price_update",
-				"symbol": orderBook.Symbol,
-				"price":  priceForUpdate,
-			}
-			e.actorSystem.Send(e.orderManagerPID, priceUpdate)
+			mailbox.Send(e.actorSystem, e.orderManagerPID, order.PriceUpdateMsg{Symbol: orderBook.Symbol, Price: priceForUpdate})
 		}
 	}
 }
@@ -563,21 +709,47 @@ func (e *ExchangeActor) OnTicker(ticker *exchanges.Ticker) {
 	msg := strategy.TickerDataMsg{Ticker: ticker}
 	for _, strategyPID := range e.strategyActors {
 		if strategyPID != nil && e.actorSystem != nil {
-			e.actorSystem.Send(strategyPID, msg)
+			strategy.SendMarketData(e.actorSystem, strategyPID, msg)
 		}
 	}
 
+	// Tickers also provide the cross rates used for portfolio valuation
+	if e.portfolioPID != nil && e.actorSystem != nil && ticker.Price > 0 {
+		mailbox.Send(e.actorSystem, e.portfolioPID, portfolio.UpdateMarketPricesMsg{
+			Prices: map[string]float64{
+				ticker.Symbol: ticker.Price,
+			},
+		})
+	}
+
 	// Send price update to order manager for stop/trailing orders
 	if e.orderManagerPID != nil && e.actorSystem != nil {
-		priceUpdate := map[string]interface{}{
-			"type":   "price_update",
-			"symbol": ticker.Symbol,
-			"price":  ticker.Price,
-		}
-		e.actorSystem.Send(e.orderManagerPID, priceUpdate)
+		mailbox.Send(e.actorSystem, e.orderManagerPID, order.PriceUpdateMsg{Symbol: ticker.Symbol, Price: ticker.Price})
 	}
 }
 
+// OnDisconnect publishes a notification when the market data connection drops
+func (e *ExchangeActor) OnDisconnect(exchange string, err error) {
+	notifier.Publish(e.actorSystem, notifier.Event{
+		Type:     notifier.EventDisconnected,
+		Severity: notifier.SeverityCritical,
+		Title:    fmt.Sprintf("%s market data disconnected", exchange),
+		Message:  fmt.Sprintf("WebSocket connection lost, reconnecting: %v", err),
+		Exchange: exchange,
+	})
+}
+
+// OnReconnect publishes a notification when the market data connection is restored
+func (e *ExchangeActor) OnReconnect(exchange string) {
+	notifier.Publish(e.actorSystem, notifier.Event{
+		Type:     notifier.EventReconnected,
+		Severity: notifier.SeverityInfo,
+		Title:    fmt.Sprintf("%s market data reconnected", exchange),
+		Message:  "WebSocket connection restored and subscriptions renewed",
+		Exchange: exchange,
+	})
+}
+
 func (e *ExchangeActor) onGetBalances(ctx *actor.Context) {
 	e.logger.Debug().Bool("connected", e.connected).Msg("GetBalances request received")
 
@@ -790,9 +962,8 @@ func (e *ExchangeActor) onStatus(ctx *actor.Context) {
 func (e *ExchangeActor) onKlineData(ctx *actor.Context, msg KlineDataMsg) {
 	// Forward kline data to strategy actors for the same symbol
 	for strategyKey, strategyPID := range e.strategyActors {
-		// Extract symbol from strategy key (format: "strategy:symbol")
-		if len(strategyKey) > 0 && fmt.Sprintf(":%s", msg.Kline.Symbol) == strategyKey[len(strategyKey)-len(msg.Kline.Symbol)-1:] {
-			ctx.Send(strategyPID, strategy.KlineDataMsg{Kline: msg.Kline})
+		if e.strategyTradesSymbol(strategyKey, msg.Kline.Symbol) {
+			strategy.SendMarketData(ctx.Engine(), strategyPID, strategy.KlineDataMsg{Kline: msg.Kline})
 		}
 	}
 }
@@ -800,15 +971,24 @@ func (e *ExchangeActor) onKlineData(ctx *actor.Context, msg KlineDataMsg) {
 func (e *ExchangeActor) onOrderBookData(ctx *actor.Context, msg OrderBookDataMsg) {
 	// Forward order book data to strategy actors for the same symbol
 	for strategyKey, strategyPID := range e.strategyActors {
-		// Extract symbol from strategy key (format: "strategy:symbol")
-		if len(strategyKey) > 0 && fmt.Sprintf(":%s", msg.OrderBook.Symbol) == strategyKey[len(strategyKey)-len(msg.OrderBook.Symbol)-1:] {
-			ctx.Send(strategyPID, strategy.OrderBookDataMsg{OrderBook: msg.OrderBook})
+		if e.strategyTradesSymbol(strategyKey, msg.OrderBook.Symbol) {
+			strategy.SendMarketData(ctx.Engine(), strategyPID, strategy.OrderBookDataMsg{OrderBook: msg.OrderBook})
 		}
 	}
 }
 
-// StartStrategy starts a new strategy actor for a symbol
-func (e *ExchangeActor) StartStrategy(ctx *actor.Context, strategyName, symbol string, config map[string]interface{}) error {
+// strategyTradesSymbol reports whether the strategy registered under strategyKey trades symbol on any leg
+func (e *ExchangeActor) strategyTradesSymbol(strategyKey, symbol string) bool {
+	if legs, exists := e.strategyLegs[strategyKey]; exists {
+		return slices.Contains(legs, symbol)
+	}
+	// Fall back to the key format "strategy:symbol"
+	return strings.HasSuffix(strategyKey, ":"+symbol)
+}
+
+// StartStrategy spawns a strategy actor keyed by its primary symbol. legs lists every symbol a
+// multi-symbol strategy trades and may be nil for single-symbol strategies.
+func (e *ExchangeActor) StartStrategy(ctx *actor.Context, strategyName, symbol string, legs []string, config map[string]interface{}) error {
 	strategyKey := fmt.Sprintf("%s:%s", strategyName, symbol)
 
 	if _, exists := e.strategyActors[strategyKey]; exists {
@@ -827,10 +1007,18 @@ func (e *ExchangeActor) StartStrategy(ctx *actor.Context, strategyName, symbol s
 		)
 		// Set parent actor references for communication
 		strategyActor.SetParentActors(e.orderManagerPID, e.riskManagerPID, ctx.PID())
+		strategyActor.SetSymbols(legs)
 		return strategyActor
 	}, strategyKey)
 
 	e.strategyActors[strategyKey] = strategyPID
+	strategyLegs := []string{symbol}
+	for _, leg := range legs {
+		if !slices.Contains(strategyLegs, leg) {
+			strategyLegs = append(strategyLegs, leg)
+		}
+	}
+	e.strategyLegs[strategyKey] = strategyLegs
 
 	// Send start message to the strategy actor to trigger initialization
 	ctx.Send(strategyPID, strategy.StartStrategyMsg{})
@@ -838,6 +1026,7 @@ func (e *ExchangeActor) StartStrategy(ctx *actor.Context, strategyName, symbol s
 	e.logger.Info().
 		Str("strategy", strategyName).
 		Str("symbol", symbol).
+		Strs("legs", legs).
 		Msg("Strategy actor started")
 
 	return nil
@@ -946,6 +1135,28 @@ func (e *ExchangeActor) onPortfolioRequestPositions(ctx *actor.Context) {
 	}
 }
 
+// onPortfolioRequestTransfers sends recent deposits and withdrawals to the portfolio actor.
+// Exchanges that do not report transfers are skipped; their cash flows are entered manually.
+func (e *ExchangeActor) onPortfolioRequestTransfers(ctx *actor.Context) {
+	provider, ok := e.exchange.(exchanges.TransferProvider)
+	if !ok || !e.connected || e.portfolioPID == nil {
+		return
+	}
+
+	transferCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	transfers, err := provider.GetTransfers(transferCtx, time.Time{})
+	if err != nil {
+		e.logger.Error().Err(err).Msg("Failed to get transfers for portfolio")
+		return
+	}
+
+	if len(transfers) > 0 {
+		ctx.Send(e.portfolioPID, portfolio.TransfersMsg{Transfers: transfers})
+	}
+}
+
 func (e *ExchangeActor) onSetAPIActor(ctx *actor.Context, msg SetAPIActorMsg) {
 	e.apiActorPID = msg.APIActorPID
 	e.logger.Debug().Msg("API actor reference set")
@@ -1087,8 +1298,11 @@ func (e *ExchangeActor) handleSetRiskParameter(ctx *actor.Context, msg map[strin
 		return
 	}
 
-	// Forward to risk manager
-	riskMsg := risk.SetRiskParameterMsg{Key: parameter, Value: value}
+	// Forward to risk manager, the strategy, symbol and actor are optional
+	symbol, _ := msg["symbol"].(string)
+	strategyName, _ := msg["strategy"].(string)
+	actorName, _ := msg["actor"].(string)
+	riskMsg := risk.SetRiskParameterMsg{Key: parameter, Value: value, Symbol: symbol, Strategy: strategyName, Actor: actorName}
 	response, err := ctx.Request(e.riskManagerPID, riskMsg, 5*time.Second).Result()
 	if err != nil {
 		ctx.Respond(map[string]interface{}{"error": err.Error()})
@@ -1247,7 +1461,20 @@ func (e *ExchangeActor) handleLoadRebalanceScript(ctx *actor.Context, msg map[st
 
 // onStrategySubscription handles strategy subscription registration for efficient routing
 func (e *ExchangeActor) onStrategySubscription(ctx *actor.Context, msg strategy.StrategySubscriptionMsg) {
-	subscriptionKey := fmt.Sprintf("%s:%s", msg.Symbol, msg.Interval)
+	// Multi-symbol strategies list all their legs, every leg routes to the same strategy actor
+	symbols := msg.Symbols
+	if len(symbols) == 0 {
+		symbols = []string{msg.Symbol}
+	}
+
+	for _, symbol := range symbols {
+		e.subscribeStrategy(ctx, ctx.Sender(), symbol, msg.Interval)
+	}
+}
+
+// subscribeStrategy routes klines for one symbol:interval to a strategy actor
+func (e *ExchangeActor) subscribeStrategy(ctx *actor.Context, strategyPID *actor.PID, symbol, interval string) {
+	subscriptionKey := fmt.Sprintf("%s:%s", symbol, interval)
 
 	// Add the strategy PID to the subscription list for this symbol:interval
 	if e.strategySubscriptions[subscriptionKey] == nil {
@@ -1255,12 +1482,11 @@ func (e *ExchangeActor) onStrategySubscription(ctx *actor.Context, msg strategy.
 	}
 
 	// Check if strategy PID is already subscribed to avoid duplicates
-	strategyPID := ctx.Sender()
 	for _, existingPID := range e.strategySubscriptions[subscriptionKey] {
 		if existingPID == strategyPID {
 			e.logger.Debug().
-				Str("symbol", msg.Symbol).
-				Str("interval", msg.Interval).
+				Str("symbol", symbol).
+				Str("interval", interval).
 				Msg("Strategy already subscribed to this symbol:interval")
 			return
 		}
@@ -1269,10 +1495,29 @@ func (e *ExchangeActor) onStrategySubscription(ctx *actor.Context, msg strategy.
 	e.strategySubscriptions[subscriptionKey] = append(e.strategySubscriptions[subscriptionKey], strategyPID)
 
 	e.logger.Info().
-		Str("symbol", msg.Symbol).
-		Str("interval", msg.Interval).
+		Str("symbol", symbol).
+		Str("interval", interval).
 		Int("total_subscribers", len(e.strategySubscriptions[subscriptionKey])).
 		Msg("Strategy subscribed to symbol:interval")
+
+	// Derived intervals are built locally from the stream of their source interval
+	if !e.isNative(interval) {
+		if err := e.ensureAggregator(ctx, symbol, interval); err != nil {
+			e.logger.Error().Err(err).
+				Str("symbol", symbol).
+				Str("interval", interval).
+				Msg("Failed to build derived bars")
+		}
+		return
+	}
+
+	// Strategies may declare timeframes or legs beyond the configured pair, make sure the stream exists
+	if !e.subscribedKlines[subscriptionKey] {
+		e.onSubscribeKlines(ctx, SubscribeKlinesMsg{
+			Symbols:  []string{symbol},
+			Interval: interval,
+		})
+	}
 }
 
 // onFetchHistoricalKlines handles historical klines requests from strategies
@@ -1288,8 +1533,7 @@ func (e *ExchangeActor) onFetchHistoricalKlines(ctx *actor.Context, msg FetchHis
 		Int("limit", msg.Limit).
 		Msg("Fetching historical klines")
 
-	// Use the GetKlines method we know exists
-	klines, err := e.exchange.GetKlines(context.Background(), msg.Symbol, msg.Interval, msg.Limit)
+	klines, err := e.historicalKlines(msg.Symbol, msg.Interval, msg.Limit)
 	if err != nil {
 		e.logger.Error().Err(err).
 			Str("symbol", msg.Symbol).
@@ -1304,21 +1548,204 @@ func (e *ExchangeActor) onFetchHistoricalKlines(ctx *actor.Context, msg FetchHis
 		Int("klines_count", len(klines)).
 		Msg("Successfully fetched historical klines")
 
+	// Exchanges may return newest first, deliver oldest first so buffers fill in order
+	sort.Slice(klines, func(i, j int) bool {
+		return klines[i].Timestamp.Before(klines[j].Timestamp)
+	})
+
 	// Send each historical kline to the requesting strategy
 	strategyPID := ctx.Sender()
 	for _, kline := range klines {
 		if e.actorSystem != nil {
-			e.actorSystem.Send(strategyPID, strategy.KlineDataMsg{Kline: kline})
+			strategy.SendMarketData(e.actorSystem, strategyPID, strategy.KlineDataMsg{Kline: kline})
 		}
 	}
 }
 
+// historicalKlines returns the latest closed klines from the store, downloading any it is missing.
+// Without a store, or when it cannot be filled, they are fetched from the exchange directly.
+// Derived intervals come from their aggregator.
+func (e *ExchangeActor) historicalKlines(symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	if !e.isNative(interval) {
+		return e.aggregatedKlines(symbol, interval, limit)
+	}
+	if e.history != nil {
+		fetchCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		klines, err := e.history.Latest(fetchCtx, symbol, interval, limit)
+		if err == nil {
+			return klines, nil
+		}
+		e.logger.Warn().Err(err).
+			Str("symbol", symbol).
+			Str("interval", interval).
+			Msg("Failed to read klines from the store, fetching from the exchange")
+	}
+	return e.exchange.GetKlines(context.Background(), symbol, interval, limit)
+}
+
+// onDownloadKlines starts filling the kline store for a range and responds with the gaps it fills, or an error
+func (e *ExchangeActor) onDownloadKlines(ctx *actor.Context, msg DownloadKlinesMsg) {
+	if e.history == nil || !e.connected {
+		ctx.Respond(fmt.Errorf("exchange %s is not connected to a kline store", e.exchangeName))
+		return
+	}
+	if _, ok := e.exchange.(exchanges.KlineRangeProvider); !ok {
+		ctx.Respond(fmt.Errorf("exchange %s cannot fetch klines by time range", e.exchangeName))
+		return
+	}
+
+	gaps, err := history.Gaps(e.db, e.exchangeType, msg.Symbol, msg.Interval, msg.From, msg.To)
+	if err != nil {
+		ctx.Respond(err)
+		return
+	}
+
+	// Long ranges take many requests, download outside the actor so it keeps handling market data
+	if len(gaps) > 0 {
+		downloader := e.history
+		logger := e.logger
+		go func() {
+			if _, err := downloader.Download(context.Background(), msg.Symbol, msg.Interval, msg.From, msg.To); err != nil {
+				logger.Error().Err(err).
+					Str("symbol", msg.Symbol).
+					Str("interval", msg.Interval).
+					Msg("Failed to download historical klines")
+			}
+		}()
+	}
+
+	ctx.Respond(KlineDownloadMsg{Gaps: gaps})
+}
+
+// onGetChartData responds with the klines of a symbol and the series its strategy plotted, or an error
+func (e *ExchangeActor) onGetChartData(ctx *actor.Context, msg GetChartDataMsg) {
+	if e.exchange == nil || !e.connected {
+		ctx.Respond(fmt.Errorf("exchange %s is not connected", e.exchangeName))
+		return
+	}
+
+	chart := ChartDataMsg{Interval: msg.Interval, Plots: map[string][]strategy.PlotPoint{}}
+	if msg.Strategy != "" {
+		strategyPID, exists := e.strategyActors[fmt.Sprintf("%s:%s", msg.Strategy, msg.Symbol)]
+		if !exists || strategyPID == nil {
+			ctx.Respond(fmt.Errorf("strategy %s is not running on %s", msg.Strategy, msg.Symbol))
+			return
+		}
+
+		response, err := ctx.Request(strategyPID, strategy.GetPlotsMsg{}, 5*time.Second).Result()
+		if err != nil {
+			e.logger.Error().Err(err).
+				Str("strategy", msg.Strategy).
+				Str("symbol", msg.Symbol).
+				Msg("Failed to get plots from strategy actor")
+		} else if plots, ok := response.(strategy.PlotsResponseMsg); ok {
+			chart.Plots = plots.Series
+			if chart.Interval == "" {
+				chart.Interval = plots.Interval
+			}
+		}
+	}
+	if chart.Interval == "" {
+		ctx.Respond(fmt.Errorf("interval is required without a running strategy"))
+		return
+	}
+
+	klines, err := e.historicalKlines(msg.Symbol, chart.Interval, msg.Limit)
+	if err == nil && !msg.Closed && e.isNative(chart.Interval) {
+		klines = e.withFormingKline(msg.Symbol, chart.Interval, klines, msg.Limit)
+	}
+	if err != nil {
+		e.logger.Error().Err(err).
+			Str("symbol", msg.Symbol).
+			Str("interval", chart.Interval).
+			Msg("Failed to fetch klines for chart")
+		ctx.Respond(fmt.Errorf("failed to fetch klines: %w", err))
+		return
+	}
+
+	sort.Slice(klines, func(i, j int) bool {
+		return klines[i].Timestamp.Before(klines[j].Timestamp)
+	})
+	chart.Klines = klines
+
+	ctx.Respond(chart)
+}
+
+// withFormingKline appends the bar still forming on the exchange to closed klines from the store,
+// keeping at most limit klines. Closed klines are returned unchanged when it cannot be fetched.
+func (e *ExchangeActor) withFormingKline(symbol, interval string, klines []*exchanges.Kline, limit int) []*exchanges.Kline {
+	klineCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	latest, err := e.exchange.GetKlines(klineCtx, symbol, interval, 1)
+	if err != nil || len(latest) == 0 {
+		e.logger.Debug().Err(err).Str("symbol", symbol).Str("interval", interval).Msg("Chart without the forming kline")
+		return klines
+	}
+
+	forming := latest[len(latest)-1]
+	for _, kline := range klines {
+		if !kline.Timestamp.Before(forming.Timestamp) {
+			return klines
+		}
+	}
+	klines = append(klines, forming)
+	if limit > 0 && len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines
+}
+
+// onDeployStrategy starts a strategy on a symbol or reloads it when it already runs there, responding
+// with a StrategyDeployedMsg or an error. Deployed strategies are not written to the configuration.
+func (e *ExchangeActor) onDeployStrategy(ctx *actor.Context, msg DeployStrategyMsg) {
+	if e.shuttingDown {
+		ctx.Respond(fmt.Errorf("exchange %s is shutting down", e.exchangeName))
+		return
+	}
+
+	strategyKey := fmt.Sprintf("%s:%s", msg.Strategy, msg.Symbol)
+	if strategyPID, exists := e.strategyActors[strategyKey]; exists {
+		ctx.Send(strategyPID, strategy.ReloadStrategyMsg{})
+		e.logger.Info().
+			Str("strategy", msg.Strategy).
+			Str("symbol", msg.Symbol).
+			Msg("Deployed strategy reloading")
+		ctx.Respond(StrategyDeployedMsg{Strategy: msg.Strategy, Symbol: msg.Symbol, Reloaded: true})
+		return
+	}
+
+	config := msg.Config
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	if err := e.StartStrategy(ctx, msg.Strategy, msg.Symbol, msg.Legs, config); err != nil {
+		ctx.Respond(err)
+		return
+	}
+
+	// Strategy actors subscribe to their own klines but not to order books
+	var symbols []string
+	for _, symbol := range e.strategyLegs[strategyKey] {
+		if !e.subscribedOrderBooks[symbol] {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) > 0 {
+		ctx.Send(ctx.PID(), SubscribeOrderBookMsg{Symbols: symbols})
+	}
+
+	ctx.Respond(StrategyDeployedMsg{Strategy: msg.Strategy, Symbol: msg.Symbol})
+}
+
 // removeStrategyFromSubscriptions removes a strategy from all subscription lists (useful for cleanup)
 func (e *ExchangeActor) removeStrategyFromSubscriptions(strategyPID *actor.PID) {
 	for subscriptionKey, subscribers := range e.strategySubscriptions {
 		// Remove the strategy PID from this subscription
 		for i, pid := range subscribers {
-			if pid == strategyPID {
+			if pid.Equals(strategyPID) {
 				// Remove element at index i
 				e.strategySubscriptions[subscriptionKey] = append(subscribers[:i], subscribers[i+1:]...)
 				e.logger.Debug().
@@ -0,0 +1,28 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestWithFormingKline(t *testing.T) {
+	e := New("test", nil, nil, nil, zerolog.Nop())
+	e.exchange = &minuteExchange{now: start.Add(10 * time.Minute)}
+
+	// The exchange reports the bar opened at 00:09 as the latest
+	stored := []*exchanges.Kline{price(7, 7, 1), price(8, 8, 1)}
+	klines := e.withFormingKline("BTCUSDT", "1m", stored, 2)
+	if len(klines) != 2 || !klines[0].Timestamp.Equal(start.Add(8*time.Minute)) || !klines[1].Timestamp.Equal(start.Add(9*time.Minute)) {
+		t.Errorf("expected the stored 00:08 kline followed by the forming 00:09 kline, got %+v", klines)
+	}
+
+	// A store that already holds the latest bar is left as it is
+	stored = []*exchanges.Kline{price(8, 8, 1), price(9, 9, 1)}
+	if klines := e.withFormingKline("BTCUSDT", "1m", stored, 2); len(klines) != 2 || klines[1] != stored[1] {
+		t.Errorf("expected the stored klines unchanged, got %+v", klines)
+	}
+}
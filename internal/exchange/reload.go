@@ -0,0 +1,203 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/internal/risk"
+	"github.com/arijanluiken/mercantile/internal/strategy"
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// strategyStopTimeout bounds the wait for a stopped strategy to run on_stop and shut down
+const strategyStopTimeout = 10 * time.Second
+
+// configuredStrategy is one strategy instance from the configuration of an exchange
+type configuredStrategy struct {
+	Key    string
+	Name   string
+	Symbol string
+	Legs   []string
+	Config map[string]interface{}
+}
+
+// configuredStrategies lists the strategies an exchange configuration runs, keyed like the strategy actors
+func configuredStrategies(exchangeConfig config.ExchangeConfig) map[string]configuredStrategy {
+	strategies := make(map[string]configuredStrategy)
+	if !exchangeConfig.Enabled {
+		return strategies
+	}
+
+	for _, pairConfig := range exchangeConfig.Pairs {
+		for _, strategyConfig := range pairConfig.Strategies {
+			key := fmt.Sprintf("%s:%s", strategyConfig.Name, pairConfig.Symbol)
+			strategies[key] = configuredStrategy{
+				Key:    key,
+				Name:   strategyConfig.Name,
+				Symbol: pairConfig.Symbol,
+				Legs:   strategyConfig.Legs(pairConfig.Symbol),
+				Config: strategyConfig.Config,
+			}
+		}
+	}
+	return strategies
+}
+
+// diffStrategies returns the running strategies to stop and the configured ones to start, both sorted
+// by key. A strategy whose parameters or legs changed is in both lists so it restarts with the new ones.
+func diffStrategies(running, configured map[string]configuredStrategy) (stop, start []configuredStrategy) {
+	for key, current := range running {
+		if next, exists := configured[key]; !exists || !reflect.DeepEqual(current, next) {
+			stop = append(stop, current)
+		}
+	}
+	for key, next := range configured {
+		if current, exists := running[key]; !exists || !reflect.DeepEqual(current, next) {
+			start = append(start, next)
+		}
+	}
+
+	sort.Slice(stop, func(i, j int) bool { return stop[i].Key < stop[j].Key })
+	sort.Slice(start, func(i, j int) bool { return start[i].Key < start[j].Key })
+	return stop, start
+}
+
+// onApplyConfig moves the running strategies to a reloaded configuration. Only strategies that were
+// added, removed or changed are touched, strategies deployed through the API are left running.
+func (e *ExchangeActor) onApplyConfig(ctx *actor.Context, msg ApplyConfigMsg) {
+	if msg.Config == nil || e.shuttingDown {
+		return
+	}
+
+	running := configuredStrategies(e.config.Exchanges[e.exchangeName])
+	configured := configuredStrategies(msg.Config.Exchanges[e.exchangeName])
+	stop, start := diffStrategies(running, configured)
+
+	e.config = msg.Config
+
+	for _, s := range stop {
+		e.stopStrategy(ctx, s.Key)
+	}
+	e.unsubscribeUnused()
+
+	// Strategy actors subscribe to their own klines but not to order books
+	var symbols []string
+	for _, s := range start {
+		if err := e.StartStrategy(ctx, s.Name, s.Symbol, s.Legs, s.Config); err != nil {
+			e.logger.Error().
+				Err(err).
+				Str("strategy", s.Name).
+				Str("symbol", s.Symbol).
+				Msg("Failed to start strategy")
+			continue
+		}
+		for _, symbol := range e.strategyLegs[s.Key] {
+			if !e.subscribedOrderBooks[symbol] && !slices.Contains(symbols, symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	if len(symbols) > 0 {
+		ctx.Send(ctx.PID(), SubscribeOrderBookMsg{Symbols: symbols})
+	}
+
+	if e.riskManagerPID != nil {
+		ctx.Send(e.riskManagerPID, risk.UpdateConfigMsg{Config: msg.Config})
+		// Pairs may have been added that have their own position size in the settings
+		ctx.Send(e.riskManagerPID, risk.ReloadSettingsMsg{})
+	}
+
+	e.logger.Info().
+		Str("exchange", e.exchangeName).
+		Int("stopped", len(stop)).
+		Int("started", len(start)).
+		Msg("Configuration applied")
+}
+
+// stopStrategy runs on_stop of a strategy and waits for its actor to shut down, so a strategy
+// started again under the same key does not collide with the one still stopping
+func (e *ExchangeActor) stopStrategy(ctx *actor.Context, strategyKey string) {
+	strategyPID, exists := e.strategyActors[strategyKey]
+	if !exists {
+		return
+	}
+
+	ctx.Send(strategyPID, strategy.StopStrategyMsg{})
+	e.removeStrategyFromSubscriptions(strategyPID)
+	delete(e.strategyActors, strategyKey)
+	delete(e.strategyLegs, strategyKey)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), strategyStopTimeout)
+	defer cancel()
+	<-ctx.Engine().PoisonCtx(stopCtx, strategyPID).Done()
+	if stopCtx.Err() != nil {
+		e.logger.Warn().Str("strategy", strategyKey).Msg("Strategy did not stop in time")
+	}
+
+	e.logger.Info().Str("strategy", strategyKey).Msg("Strategy actor stopped")
+}
+
+// unsubscribeUnused drops the streams and derived bars of symbols no running strategy trades anymore
+func (e *ExchangeActor) unsubscribeUnused() {
+	traded := make(map[string]bool)
+	for _, legs := range e.strategyLegs {
+		for _, symbol := range legs {
+			traded[symbol] = true
+		}
+	}
+
+	var klines []string
+	for key := range e.subscribedKlines {
+		symbol, _, _ := strings.Cut(key, ":")
+		if !traded[symbol] {
+			delete(e.subscribedKlines, key)
+			if !slices.Contains(klines, symbol) {
+				klines = append(klines, symbol)
+			}
+		}
+	}
+	for key, subscribers := range e.strategySubscriptions {
+		if len(subscribers) == 0 {
+			delete(e.strategySubscriptions, key)
+		}
+	}
+
+	var orderBooks []string
+	for symbol := range e.subscribedOrderBooks {
+		if !traded[symbol] {
+			delete(e.subscribedOrderBooks, symbol)
+			orderBooks = append(orderBooks, symbol)
+		}
+	}
+
+	e.aggregatorsMu.Lock()
+	for key, agg := range e.aggregators {
+		if !traded[agg.symbol] {
+			delete(e.aggregators, key)
+		}
+	}
+	e.aggregatorsMu.Unlock()
+
+	if e.exchange == nil || !e.connected {
+		return
+	}
+	if len(klines) > 0 {
+		sort.Strings(klines)
+		if err := e.exchange.UnsubscribeKlines(klines); err != nil {
+			e.logger.Error().Err(err).Strs("symbols", klines).Msg("Failed to unsubscribe from klines")
+		}
+	}
+	if len(orderBooks) > 0 {
+		sort.Strings(orderBooks)
+		if err := e.exchange.UnsubscribeOrderBook(orderBooks); err != nil {
+			e.logger.Error().Err(err).Strs("symbols", orderBooks).Msg("Failed to unsubscribe from order book")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+func keys(strategies []configuredStrategy) []string {
+	var result []string
+	for _, s := range strategies {
+		result = append(result, s.Key)
+	}
+	return result
+}
+
+func TestDiffStrategies(t *testing.T) {
+	running := configuredStrategies(config.ExchangeConfig{
+		Enabled: true,
+		Pairs: []config.PairConfig{
+			{Symbol: "BTCUSDT", Strategies: []config.StrategyConfig{
+				{Name: "simple_sma", Config: map[string]interface{}{"period": 20}},
+				{Name: "rsi_strategy"},
+			}},
+			{Symbol: "ETHUSDT", Strategies: []config.StrategyConfig{{Name: "simple_sma"}}},
+		},
+	})
+	if len(running) != 3 || running["simple_sma:BTCUSDT"].Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected configured strategies %+v", running)
+	}
+
+	configured := configuredStrategies(config.ExchangeConfig{
+		Enabled: true,
+		Pairs: []config.PairConfig{
+			// Changed parameters restart the strategy, the unchanged one keeps running
+			{Symbol: "BTCUSDT", Strategies: []config.StrategyConfig{
+				{Name: "simple_sma", Config: map[string]interface{}{"period": 50}},
+				{Name: "rsi_strategy"},
+			}},
+			// An extra leg restarts the strategy too
+			{Symbol: "ETHUSDT", Strategies: []config.StrategyConfig{{Name: "simple_sma", Symbols: []string{"BTCUSDT"}}}},
+			{Symbol: "SOLUSDT", Strategies: []config.StrategyConfig{{Name: "macd_strategy"}}},
+		},
+	})
+
+	stop, start := diffStrategies(running, configured)
+	if got := keys(stop); len(got) != 2 || got[0] != "simple_sma:BTCUSDT" || got[1] != "simple_sma:ETHUSDT" {
+		t.Errorf("unexpected strategies to stop %v", got)
+	}
+	if got := keys(start); len(got) != 3 || got[0] != "macd_strategy:SOLUSDT" || got[1] != "simple_sma:BTCUSDT" || got[2] != "simple_sma:ETHUSDT" {
+		t.Errorf("unexpected strategies to start %v", got)
+	}
+
+	// Disabling the exchange stops everything
+	stop, start = diffStrategies(running, configuredStrategies(config.ExchangeConfig{Pairs: []config.PairConfig{{Symbol: "BTCUSDT"}}}))
+	if len(stop) != 3 || len(start) != 0 {
+		t.Errorf("expected all strategies to stop, got %v and %v", keys(stop), keys(start))
+	}
+
+	if stop, start := diffStrategies(running, running); len(stop) != 0 || len(start) != 0 {
+		t.Errorf("expected no changes, got %v and %v", keys(stop), keys(start))
+	}
+}
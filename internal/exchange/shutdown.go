@@ -0,0 +1,190 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/internal/order"
+	"github.com/arijanluiken/mercantile/internal/portfolio"
+	"github.com/arijanluiken/mercantile/internal/strategy"
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Shutdown phases the supervisor runs on every exchange, in this order
+const (
+	PhaseStopStrategies = "stop_strategies" // Run on_stop of every strategy and stop rebalancing
+	PhaseOrderPolicy    = "order_policy"    // Leave or cancel open orders
+	PhaseFlush          = "flush"           // Persist orders and a final portfolio snapshot
+	PhaseDisconnect     = "disconnect"      // Close the exchange connections
+)
+
+// ShutdownPhases lists the phases in the order they run
+var ShutdownPhases = []string{PhaseStopStrategies, PhaseOrderPolicy, PhaseFlush, PhaseDisconnect}
+
+type (
+	// ShutdownPhaseMsg runs one shutdown phase, responding with a ShutdownPhaseResult
+	ShutdownPhaseMsg struct {
+		Phase       string
+		OrderPolicy string        // Open-order policy of PhaseOrderPolicy
+		Timeout     time.Duration // Time left for the phase
+	}
+	ShutdownPhaseResult struct {
+		Exchange string                 `json:"exchange"`
+		Phase    string                 `json:"phase"`
+		Detail   map[string]interface{} `json:"detail,omitempty"`
+		Errors   []string               `json:"errors,omitempty"`
+		Warnings []string               `json:"warnings,omitempty"`
+	}
+)
+
+func (e *ExchangeActor) onShutdownPhase(ctx *actor.Context, msg ShutdownPhaseMsg) {
+	// No strategies are started or reloaded once shutdown began
+	e.shuttingDown = true
+
+	result := ShutdownPhaseResult{
+		Exchange: e.exchangeName,
+		Phase:    msg.Phase,
+		Detail:   make(map[string]interface{}),
+	}
+
+	timeout := msg.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultShutdownTimeout
+	}
+	switch msg.Phase {
+	case PhaseStopStrategies:
+		e.stopAllStrategies(ctx, timeout, &result)
+	case PhaseOrderPolicy:
+		e.applyOrderPolicy(ctx, msg.OrderPolicy, timeout, &result)
+	case PhaseFlush:
+		e.flushState(ctx, timeout, &result)
+	case PhaseDisconnect:
+		e.disconnect(&result)
+	default:
+		result.Errors = append(result.Errors, fmt.Sprintf("unknown shutdown phase %q", msg.Phase))
+	}
+
+	e.logger.Info().
+		Str("phase", msg.Phase).
+		Interface("detail", result.Detail).
+		Strs("errors", result.Errors).
+		Strs("warnings", result.Warnings).
+		Msg("Shutdown phase completed")
+
+	ctx.Respond(result)
+}
+
+// stopAllStrategies stops every strategy at once, so each runs on_stop, and waits for them within timeout
+func (e *ExchangeActor) stopAllStrategies(ctx *actor.Context, timeout time.Duration, result *ShutdownPhaseResult) {
+	stopCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stopping := make(map[string]context.Context, len(e.strategyActors))
+	for strategyKey, strategyPID := range e.strategyActors {
+		ctx.Send(strategyPID, strategy.StopStrategyMsg{})
+		e.removeStrategyFromSubscriptions(strategyPID)
+		stopping[strategyKey] = ctx.Engine().PoisonCtx(stopCtx, strategyPID)
+	}
+	if e.rebalancePID != nil {
+		stopping["rebalance"] = ctx.Engine().PoisonCtx(stopCtx, e.rebalancePID)
+		e.rebalancePID = nil
+	}
+
+	stopped := 0
+	for strategyKey, done := range stopping {
+		<-done.Done()
+		// Stopped actors cancel their context, the deadline passing expires it
+		if errors.Is(done.Err(), context.DeadlineExceeded) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s did not stop in time", strategyKey))
+			continue
+		}
+		if strategyKey != "rebalance" {
+			stopped++
+		}
+	}
+
+	e.strategyActors = make(map[string]*actor.PID)
+	e.strategyLegs = make(map[string][]string)
+	e.scriptWatcher = nil
+	result.Detail["strategies_stopped"] = stopped
+}
+
+// applyOrderPolicy has the order manager leave or cancel open orders. Even when orders are left, the stop
+// orders the bot holds locally are dropped, so the order manager is always asked and warns about them.
+func (e *ExchangeActor) applyOrderPolicy(ctx *actor.Context, policy string, timeout time.Duration, result *ShutdownPhaseResult) {
+	if policy == "" {
+		policy = config.OrderPolicyLeave
+	}
+	result.Detail["policy"] = policy
+	if e.orderManagerPID == nil {
+		return
+	}
+
+	response, err := ctx.Request(e.orderManagerPID, order.ApplyOrderPolicyMsg{Policy: policy}, timeout).Result()
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("apply order policy: %v", err))
+		return
+	}
+	applied, ok := response.(order.OrderPolicyResult)
+	if !ok {
+		result.Errors = append(result.Errors, fmt.Sprintf("apply order policy: unexpected response %T", response))
+		return
+	}
+	result.Detail["orders_cancelled"] = applied.Cancelled
+	result.Detail["orders_kept"] = applied.Kept
+	result.Detail["orders_dropped"] = applied.Dropped
+	result.Errors = append(result.Errors, applied.Errors...)
+	result.Warnings = append(result.Warnings, applied.Warnings...)
+}
+
+// flushState has the order manager save its orders and the portfolio a final snapshot
+func (e *ExchangeActor) flushState(ctx *actor.Context, timeout time.Duration, result *ShutdownPhaseResult) {
+	deadline := time.Now().Add(timeout)
+
+	if e.orderManagerPID != nil {
+		response, err := ctx.Request(e.orderManagerPID, order.FlushOrdersMsg{}, time.Until(deadline)).Result()
+		if err == nil {
+			if flushErr, ok := response.(error); ok {
+				err = flushErr
+			}
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("flush orders: %v", err))
+		} else {
+			result.Detail["orders_written"] = response
+		}
+	}
+
+	if e.portfolioPID != nil {
+		response, err := ctx.Request(e.portfolioPID, portfolio.FlushMsg{}, time.Until(deadline)).Result()
+		if err == nil {
+			if flushErr, ok := response.(error); ok {
+				err = flushErr
+			}
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("flush portfolio: %v", err))
+		} else {
+			result.Detail["portfolio_snapshot"] = true
+		}
+	}
+}
+
+// disconnect closes the market data and trading connections of the exchange
+func (e *ExchangeActor) disconnect(result *ShutdownPhaseResult) {
+	result.Detail["was_connected"] = e.connected
+	if e.exchange == nil || !e.connected {
+		return
+	}
+
+	if err := e.exchange.Disconnect(); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("disconnect: %v", err))
+	}
+	e.connected = false
+	e.subscribedKlines = make(map[string]bool)
+	e.subscribedOrderBooks = make(map[string]bool)
+}
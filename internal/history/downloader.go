@@ -0,0 +1,194 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// Paging defaults
+const (
+	PageSize               = 1000                   // Klines requested per page, the Bybit maximum
+	DefaultRequestInterval = 200 * time.Millisecond // Pause between page requests
+)
+
+// Result reports what a download stored and what it could not fill
+type Result struct {
+	Stored int   `json:"stored"`
+	Gaps   []Gap `json:"gaps"` // Ranges still missing afterwards, usually before a symbol was listed
+}
+
+// Downloader fills the kline store of one exchange, paging through history and repairing gaps.
+// Requests are spaced by RequestInterval across all callers to stay within exchange rate limits.
+type Downloader struct {
+	db           *database.DB
+	exchangeName string
+	exchange     exchanges.Exchange
+	logger       zerolog.Logger
+
+	RequestInterval time.Duration
+
+	mu          sync.Mutex // Serializes requests for the rate limit
+	lastRequest time.Time
+	now         func() time.Time
+}
+
+// NewDownloader creates a downloader that stores klines of exchange under exchangeName
+func NewDownloader(db *database.DB, exchangeName string, exchange exchanges.Exchange, logger zerolog.Logger) *Downloader {
+	return &Downloader{
+		db:              db,
+		exchangeName:    exchangeName,
+		exchange:        exchange,
+		logger:          logger,
+		RequestInterval: DefaultRequestInterval,
+		now:             time.Now,
+	}
+}
+
+// Download fetches the closed klines missing from the store between from and to
+func (d *Downloader) Download(ctx context.Context, symbol, interval string, from, to time.Time) (*Result, error) {
+	iv, err := parseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := d.exchange.(exchanges.KlineRangeProvider)
+	if !ok {
+		return nil, fmt.Errorf("exchange %s cannot fetch klines by time range", d.exchangeName)
+	}
+
+	gaps, err := gapsAt(d.db, d.exchangeName, symbol, interval, from, to, d.now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, gap := range gaps {
+		stored, err := d.fill(ctx, provider, symbol, interval, iv, gap)
+		result.Stored += stored
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if result.Gaps, err = gapsAt(d.db, d.exchangeName, symbol, interval, from, to, d.now()); err != nil {
+		return result, err
+	}
+
+	d.logger.Info().
+		Str("symbol", symbol).
+		Str("interval", interval).
+		Time("from", from).
+		Time("to", to).
+		Int("stored", result.Stored).
+		Int("gaps", len(result.Gaps)).
+		Msg("Downloaded historical klines")
+
+	return result, nil
+}
+
+// fill pages through a gap until it is covered or the exchange has nothing more for it. Each page
+// narrows the remaining range from whichever end it covered, so it works whether the exchange
+// returns the oldest or the newest klines of a range that does not fit in one page.
+func (d *Downloader) fill(ctx context.Context, provider exchanges.KlineRangeProvider, symbol, interval string, iv interval, gap Gap) (int, error) {
+	stored := 0
+	from, to := gap.From, gap.To
+	for !to.Before(from) {
+		if err := d.wait(ctx); err != nil {
+			return stored, err
+		}
+		page, err := provider.GetKlinesRange(ctx, symbol, interval, from, to, PageSize)
+		if err != nil {
+			return stored, fmt.Errorf("failed to fetch klines from %s to %s: %w", from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+		}
+
+		var records []*database.Kline
+		for _, kline := range page {
+			if !kline.IsClosed || kline.Timestamp.Before(from) || kline.Timestamp.After(to) {
+				continue
+			}
+			records = append(records, toRecord(d.exchangeName, kline))
+		}
+		if len(records) == 0 {
+			break
+		}
+		if err := d.db.SaveKlines(records); err != nil {
+			return stored, fmt.Errorf("failed to store klines: %w", err)
+		}
+		stored += len(records)
+
+		sort.Slice(records, func(i, j int) bool { return records[i].OpenTime.Before(records[j].OpenTime) })
+		if oldest := records[0].OpenTime; oldest.After(from) {
+			to = iv.prev(oldest)
+		} else {
+			from = iv.next(records[len(records)-1].OpenTime)
+		}
+	}
+	return stored, nil
+}
+
+// wait blocks until the next request is allowed
+func (d *Downloader) wait(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if delay := d.RequestInterval - d.now().Sub(d.lastRequest); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	d.lastRequest = d.now()
+	return nil
+}
+
+// Latest returns the limit most recent closed klines, oldest first, downloading any the store is missing.
+// Exchanges without KlineRangeProvider are asked for their latest klines instead, which are stored as well.
+func (d *Downloader) Latest(ctx context.Context, symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	iv, err := parseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	if _, ok := d.exchange.(exchanges.KlineRangeProvider); ok {
+		// One interval extra, now is usually part way through the kline in progress
+		from := now
+		for i := 0; i <= limit; i++ {
+			from = iv.prev(from)
+		}
+		if _, err := d.Download(ctx, symbol, interval, from, now); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := d.wait(ctx); err != nil {
+			return nil, err
+		}
+		klines, err := d.exchange.GetKlines(ctx, symbol, interval, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		var records []*database.Kline
+		for _, kline := range klines {
+			if kline.IsClosed {
+				records = append(records, toRecord(d.exchangeName, kline))
+			}
+		}
+		if err := d.db.SaveKlines(records); err != nil {
+			return nil, fmt.Errorf("failed to store klines: %w", err)
+		}
+	}
+
+	records, err := d.db.GetRecentKlines(d.exchangeName, symbol, interval, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored klines: %w", err)
+	}
+	return toKlines(records), nil
+}
@@ -0,0 +1,170 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// fakeExchange serves hourly klines from listed up to now, newest first like Bybit, with hours in missing left out
+type fakeExchange struct {
+	exchanges.Exchange
+	listed   time.Time
+	now      time.Time
+	missing  map[time.Time]bool
+	requests int
+}
+
+func (f *fakeExchange) GetKlinesRange(ctx context.Context, symbol, interval string, start, end time.Time, limit int) ([]*exchanges.Kline, error) {
+	f.requests++
+	var klines []*exchanges.Kline
+	for open := end.Truncate(time.Hour); !open.Before(start) && !open.Before(f.listed) && len(klines) < limit; open = open.Add(-time.Hour) {
+		if open.After(f.now) || f.missing[open] {
+			continue
+		}
+		klines = append(klines, &exchanges.Kline{
+			Symbol:    symbol,
+			Interval:  interval,
+			Timestamp: open,
+			Close:     float64(open.Hour()),
+			IsClosed:  !open.Add(time.Hour).After(f.now),
+		})
+	}
+	return klines, nil
+}
+
+func newTestDownloader(t *testing.T, exchange exchanges.Exchange, now time.Time) (*Downloader, *database.DB) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	d := NewDownloader(db, "bybit", exchange, zerolog.Nop())
+	d.RequestInterval = 0
+	d.now = func() time.Time { return now }
+	return d, db
+}
+
+func TestDownloadPagesAndRepairsGaps(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	listed := now.Add(-3000 * time.Hour).Truncate(time.Hour)
+	exchange := &fakeExchange{listed: listed, now: now}
+	d, db := newTestDownloader(t, exchange, now)
+
+	// A live kline is already stored in the middle of the range
+	live := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1h", Timestamp: now.Add(-10 * time.Hour).Truncate(time.Hour), IsClosed: true}
+	if err := Record(db, "bybit", live); err != nil {
+		t.Fatalf("failed to record kline: %v", err)
+	}
+
+	// The range starts before the symbol was listed
+	from := listed.Add(-48 * time.Hour)
+	result, err := d.Download(context.Background(), "BTCUSDT", "1h", from, now)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	// 3000 closed hourly klines from the listing up to 11:00, less the one already stored
+	if result.Stored != 2999 {
+		t.Errorf("expected 2999 klines stored, got %d", result.Stored)
+	}
+	if exchange.requests < 3 {
+		t.Errorf("expected the download to take several pages, got %d requests", exchange.requests)
+	}
+	if len(result.Gaps) != 1 || !result.Gaps[0].From.Equal(from) || !result.Gaps[0].To.Equal(listed.Add(-time.Hour)) {
+		t.Errorf("expected only the range before the listing to stay missing, got %v", result.Gaps)
+	}
+
+	stored, err := Load(db, "bybit", "BTCUSDT", "1h", from, now)
+	if err != nil {
+		t.Fatalf("failed to load klines: %v", err)
+	}
+	if len(stored) != 3000 || !stored[len(stored)-1].Timestamp.Equal(now.Truncate(time.Hour).Add(-time.Hour)) {
+		t.Fatalf("expected 3000 klines up to the last closed hour, got %d", len(stored))
+	}
+	if !sort.SliceIsSorted(stored, func(i, j int) bool { return stored[i].Timestamp.Before(stored[j].Timestamp) }) {
+		t.Error("expected klines oldest first")
+	}
+
+	// A second download only asks for the range the exchange has no data for
+	exchange.requests = 0
+	result, err = d.Download(context.Background(), "BTCUSDT", "1h", from, now)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if result.Stored != 0 || exchange.requests != 1 {
+		t.Errorf("expected nothing new from 1 request, got %d stored from %d requests", result.Stored, exchange.requests)
+	}
+}
+
+func TestDownloadLeavesMissingExchangeData(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	outage := now.Add(-5 * time.Hour)
+	exchange := &fakeExchange{listed: now.Add(-24 * time.Hour), now: now, missing: map[time.Time]bool{outage: true}}
+	d, _ := newTestDownloader(t, exchange, now)
+
+	result, err := d.Download(context.Background(), "BTCUSDT", "1h", now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if result.Stored != 23 || len(result.Gaps) != 1 || !result.Gaps[0].From.Equal(outage) || !result.Gaps[0].To.Equal(outage) {
+		t.Errorf("expected 23 klines and the outage as a gap, got %d and %v", result.Stored, result.Gaps)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	exchange := &fakeExchange{listed: now.Add(-1000 * time.Hour), now: now}
+	d, _ := newTestDownloader(t, exchange, now)
+
+	klines, err := d.Latest(context.Background(), "BTCUSDT", "1h", 100)
+	if err != nil {
+		t.Fatalf("failed to get latest klines: %v", err)
+	}
+	if len(klines) != 100 {
+		t.Fatalf("expected 100 klines, got %d", len(klines))
+	}
+	if last := klines[len(klines)-1]; !last.Timestamp.Equal(time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)) || !last.IsClosed {
+		t.Errorf("expected the last closed hour, got %+v", last)
+	}
+}
+
+// latestOnlyExchange has no KlineRangeProvider, it only returns its latest klines
+type latestOnlyExchange struct {
+	exchanges.Exchange
+	klines []*exchanges.Kline
+}
+
+func (e *latestOnlyExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchanges.Kline, error) {
+	return e.klines, nil
+}
+
+func TestLatestWithoutRangeSupport(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	exchange := &latestOnlyExchange{klines: []*exchanges.Kline{
+		{Symbol: "BTCUSDT", Interval: "1h", Timestamp: now.Add(-90 * time.Minute), Close: 1, IsClosed: true},
+		{Symbol: "BTCUSDT", Interval: "1h", Timestamp: now.Add(-30 * time.Minute), Close: 2},
+	}}
+	d, _ := newTestDownloader(t, exchange, now)
+
+	klines, err := d.Latest(context.Background(), "BTCUSDT", "1h", 10)
+	if err != nil {
+		t.Fatalf("failed to get latest klines: %v", err)
+	}
+	if len(klines) != 1 || klines[0].Close != 1 {
+		t.Errorf("expected only the closed kline, got %+v", klines)
+	}
+
+	if _, err := d.Download(context.Background(), "BTCUSDT", "1h", now.Add(-time.Hour), now); err == nil {
+		t.Error("expected an error downloading a range from an exchange without range support")
+	}
+}
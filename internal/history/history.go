@@ -0,0 +1,157 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// Gap is a range of open times the store has no klines for, both ends inclusive
+type Gap struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// interval is the distance between kline open times, months for 1M and a fixed duration otherwise
+type interval struct {
+	months   int
+	duration time.Duration
+}
+
+// parseInterval parses the intervals used in configuration and strategies, such as 15m, 4h, 1d, 1w and 1M
+func parseInterval(value string) (interval, error) {
+	if len(value) < 2 {
+		return interval{}, fmt.Errorf("invalid interval %q", value)
+	}
+	count, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || count <= 0 {
+		return interval{}, fmt.Errorf("invalid interval %q", value)
+	}
+
+	switch value[len(value)-1] {
+	case 'm':
+		return interval{duration: time.Duration(count) * time.Minute}, nil
+	case 'h':
+		return interval{duration: time.Duration(count) * time.Hour}, nil
+	case 'd':
+		return interval{duration: time.Duration(count) * 24 * time.Hour}, nil
+	case 'w':
+		return interval{duration: time.Duration(count) * 7 * 24 * time.Hour}, nil
+	case 'M':
+		return interval{months: count}, nil
+	}
+	return interval{}, fmt.Errorf("invalid interval %q", value)
+}
+
+// next returns the open time of the kline after the one that opened at open
+func (i interval) next(open time.Time) time.Time {
+	if i.months > 0 {
+		return open.UTC().AddDate(0, i.months, 0)
+	}
+	return open.Add(i.duration)
+}
+
+// prev returns the open time of the kline before the one that opened at open
+func (i interval) prev(open time.Time) time.Time {
+	if i.months > 0 {
+		return open.UTC().AddDate(0, -i.months, 0)
+	}
+	return open.Add(-i.duration)
+}
+
+// findGaps returns the ranges between from and to that have no kline in times, which must be sorted.
+// Klines that would still be open at now are not missing.
+func findGaps(times []time.Time, iv interval, from, to, now time.Time) []Gap {
+	if lastClosed := iv.prev(now); to.After(lastClosed) {
+		to = lastClosed
+	}
+	if to.Before(from) {
+		return nil
+	}
+	if len(times) == 0 {
+		return []Gap{{From: from, To: to}}
+	}
+
+	var gaps []Gap
+	if before := iv.prev(times[0]); !before.Before(from) {
+		gaps = append(gaps, Gap{From: from, To: before})
+	}
+	for i := 1; i < len(times); i++ {
+		if expected := iv.next(times[i-1]); expected.Before(times[i]) {
+			gaps = append(gaps, Gap{From: expected, To: iv.prev(times[i])})
+		}
+	}
+	if after := iv.next(times[len(times)-1]); !after.After(to) {
+		gaps = append(gaps, Gap{From: after, To: to})
+	}
+	return gaps
+}
+
+// Gaps returns the ranges between from and to that have no closed kline in the store
+func Gaps(db *database.DB, exchangeName, symbol, interval string, from, to time.Time) ([]Gap, error) {
+	return gapsAt(db, exchangeName, symbol, interval, from, to, time.Now())
+}
+
+func gapsAt(db *database.DB, exchangeName, symbol, interval string, from, to, now time.Time) ([]Gap, error) {
+	iv, err := parseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	times, err := db.GetKlineTimes(exchangeName, symbol, interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored klines: %w", err)
+	}
+	return findGaps(times, iv, from, to, now), nil
+}
+
+// Record stores a closed kline received from a live stream, klines still in progress are ignored
+func Record(db *database.DB, exchangeName string, kline *exchanges.Kline) error {
+	if !kline.IsClosed {
+		return nil
+	}
+	return db.SaveKlines([]*database.Kline{toRecord(exchangeName, kline)})
+}
+
+// Load returns the stored klines that opened between from and to, oldest first
+func Load(db *database.DB, exchangeName, symbol, interval string, from, to time.Time) ([]*exchanges.Kline, error) {
+	records, err := db.GetKlines(exchangeName, symbol, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return toKlines(records), nil
+}
+
+func toRecord(exchangeName string, kline *exchanges.Kline) *database.Kline {
+	return &database.Kline{
+		Exchange: exchangeName,
+		Symbol:   kline.Symbol,
+		Interval: kline.Interval,
+		OpenTime: kline.Timestamp,
+		Open:     kline.Open,
+		High:     kline.High,
+		Low:      kline.Low,
+		Close:    kline.Close,
+		Volume:   kline.Volume,
+	}
+}
+
+func toKlines(records []*database.Kline) []*exchanges.Kline {
+	klines := make([]*exchanges.Kline, len(records))
+	for i, record := range records {
+		klines[i] = &exchanges.Kline{
+			Symbol:    record.Symbol,
+			Interval:  record.Interval,
+			Timestamp: record.OpenTime,
+			Open:      record.Open,
+			High:      record.High,
+			Low:       record.Low,
+			Close:     record.Close,
+			Volume:    record.Volume,
+			IsClosed:  true,
+		}
+	}
+	return klines
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	open := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval string
+		next     time.Time
+	}{
+		{"1m", open.Add(time.Minute)},
+		{"15m", open.Add(15 * time.Minute)},
+		{"4h", open.Add(4 * time.Hour)},
+		{"1d", open.Add(24 * time.Hour)},
+		{"1w", open.Add(7 * 24 * time.Hour)},
+		{"1M", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		iv, err := parseInterval(tt.interval)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.interval, err)
+		}
+		if next := iv.next(open); !next.Equal(tt.next) {
+			t.Errorf("%s: expected next %v, got %v", tt.interval, tt.next, next)
+		}
+		if prev := iv.prev(tt.next); !prev.Equal(open) {
+			t.Errorf("%s: expected prev %v, got %v", tt.interval, open, prev)
+		}
+	}
+
+	for _, invalid := range []string{"", "m", "0m", "5x", "h1"} {
+		if _, err := parseInterval(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	iv, _ := parseInterval("1h")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+	now := hour(100)
+
+	tests := []struct {
+		name     string
+		times    []time.Time
+		from, to time.Time
+		expected []Gap
+	}{
+		{"empty store", nil, hour(0), hour(5), []Gap{{hour(0), hour(5)}}},
+		{"complete", []time.Time{hour(0), hour(1), hour(2)}, hour(0), hour(2), nil},
+		{"leading, inner and trailing", []time.Time{hour(2), hour(3), hour(6)}, hour(0), hour(8),
+			[]Gap{{hour(0), hour(1)}, {hour(4), hour(5)}, {hour(7), hour(8)}}},
+		{"unaligned range", []time.Time{hour(1), hour(2)}, hour(0).Add(30 * time.Minute), hour(2).Add(30 * time.Minute), nil},
+		{"bar in progress is not missing", []time.Time{hour(98), hour(99)}, hour(98), now.Add(30 * time.Minute), nil},
+		{"range in the future", nil, hour(100), hour(110), nil},
+	}
+	for _, tt := range tests {
+		gaps := findGaps(tt.times, iv, tt.from, tt.to, now)
+		if len(gaps) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, gaps)
+			continue
+		}
+		for i := range gaps {
+			if !gaps[i].From.Equal(tt.expected[i].From) || !gaps[i].To.Equal(tt.expected[i].To) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, gaps)
+			}
+		}
+	}
+}
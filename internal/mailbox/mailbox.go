@@ -0,0 +1,31 @@
+// Package mailbox counts the messages queued for an actor. Hollywood does not expose its inbox,
+// so senders of frequent messages go through Send or Request, and the receiving actor calls Received.
+package mailbox
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/pkg/metrics"
+)
+
+var depth = metrics.NewGauge("mercantile_actor_mailbox_depth",
+	"Messages sent through mailbox.Send or mailbox.Request that the actor has not received yet.", "actor")
+
+// Send sends msg to pid, counting it as queued until the actor receives it
+func Send(engine *actor.Engine, pid *actor.PID, msg interface{}) {
+	depth.With(pid.ID).Inc()
+	engine.Send(pid, msg)
+}
+
+// Request sends msg to pid as a request, counting it as queued until the actor receives it
+func Request(ctx *actor.Context, pid *actor.PID, msg interface{}, timeout time.Duration) *actor.Response {
+	depth.With(pid.ID).Inc()
+	return ctx.Request(pid, msg, timeout)
+}
+
+// Received takes a message sent through Send or Request off the depth of the receiving actor
+func Received(ctx *actor.Context) {
+	depth.With(ctx.PID().ID).Dec()
+}
@@ -0,0 +1,80 @@
+package mailbox
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/pkg/metrics"
+)
+
+// blockingReceiver receives counted strings, blocking on the first until released
+type blockingReceiver struct {
+	started  chan struct{}
+	release  chan struct{}
+	received chan string
+}
+
+func (r *blockingReceiver) Receive(ctx *actor.Context) {
+	msg, ok := ctx.Message().(string)
+	if !ok {
+		return
+	}
+	Received(ctx)
+	if msg == "first" {
+		close(r.started)
+		<-r.release
+	}
+	r.received <- msg
+}
+
+// depthOf reads the gauge of an actor from the metrics endpoint
+func depthOf(t *testing.T, id string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	prefix := fmt.Sprintf("mercantile_actor_mailbox_depth{actor=%q} ", id)
+	for _, line := range strings.Split(string(body), "\n") {
+		if value, found := strings.CutPrefix(line, prefix); found {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestMailboxDepth(t *testing.T) {
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	receiver := &blockingReceiver{started: make(chan struct{}), release: make(chan struct{}), received: make(chan string, 3)}
+	pid := engine.Spawn(func() actor.Receiver { return receiver }, "mailbox")
+	defer func() { <-engine.Poison(pid).Done() }()
+
+	Send(engine, pid, "first")
+	<-receiver.started
+	Send(engine, pid, "second")
+	Send(engine, pid, "third")
+
+	if depth := depthOf(t, pid.ID); depth != "2" {
+		t.Errorf("expected 2 messages queued behind the one being handled, got %q", depth)
+	}
+
+	close(receiver.release)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-receiver.received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+	if depth := depthOf(t, pid.ID); depth != "0" {
+		t.Errorf("expected an empty mailbox, got %q", depth)
+	}
+}
@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Channel types
+const (
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelDiscord = "discord"
+	ChannelSMTP    = "smtp"
+)
+
+// discordMaxContent is the longest message in characters Discord accepts in a webhook
+const discordMaxContent = 2000
+
+// Channel delivers a single event, returning an error when the delivery should be retried
+type Channel interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// permanentError marks a failed delivery that retrying will not fix, such as a rejected request
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// isPermanent reports whether err should not be retried
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// NewChannel creates the channel described by the configuration
+func NewChannel(cfg config.NotificationChannelConfig) (Channel, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Type {
+	case ChannelWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook channel requires a url")
+		}
+		var body *template.Template
+		if cfg.Template != "" {
+			var err error
+			body, err = template.New(cfg.Name).Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webhook template: %w", err)
+			}
+		}
+		return &webhookChannel{url: cfg.URL, headers: cfg.Headers, template: body, client: client}, nil
+	case ChannelSlack, ChannelDiscord:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("%s channel requires a url", cfg.Type)
+		}
+		return &chatChannel{url: cfg.URL, discord: cfg.Type == ChannelDiscord, client: client}, nil
+	case ChannelSMTP:
+		if cfg.SMTP.Host == "" || cfg.SMTP.From == "" || len(cfg.SMTP.To) == 0 {
+			return nil, fmt.Errorf("smtp channel requires host, from and to")
+		}
+		return &smtpChannel{config: cfg.SMTP}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", cfg.Type)
+	}
+}
+
+// webhookChannel posts the event as JSON, or the rendered template when one is configured
+type webhookChannel struct {
+	url      string
+	headers  map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+func (c *webhookChannel) Send(ctx context.Context, event Event) error {
+	var body []byte
+	if c.template == nil {
+		var err error
+		if body, err = json.Marshal(event); err != nil {
+			return permanentError{err}
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := c.template.Execute(&buf, event); err != nil {
+			return permanentError{fmt.Errorf("failed to render webhook template: %w", err)}
+		}
+		body = buf.Bytes()
+	}
+
+	return postJSON(ctx, c.client, c.url, c.headers, body)
+}
+
+// chatChannel posts to Slack incoming webhooks, or Discord webhooks using their own content field
+type chatChannel struct {
+	url     string
+	discord bool
+	client  *http.Client
+}
+
+func (c *chatChannel) Send(ctx context.Context, event Event) error {
+	var payload map[string]string
+	if c.discord {
+		content := "**" + event.Title + "**\n" + formatBody(event)
+		if utf8.RuneCountInString(content) > discordMaxContent {
+			content = string([]rune(content)[:discordMaxContent-3]) + "..."
+		}
+		payload = map[string]string{"content": content}
+	} else {
+		payload = map[string]string{"text": "*" + event.Title + "*\n" + formatBody(event)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return permanentError{err}
+	}
+	return postJSON(ctx, c.client, c.url, nil, body)
+}
+
+// smtpChannel emails the event as plain text
+type smtpChannel struct {
+	config config.SMTPConfig
+}
+
+func (c *smtpChannel) Send(ctx context.Context, event Event) error {
+	port := c.config.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [mercantile] %s\r\n", strings.ReplaceAll(event.Title, "\n", " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(formatBody(event), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	// net/smtp has no context support, so the deadline only applies between attempts
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(addr, auth, c.config.From, c.config.To, msg.Bytes())
+}
+
+// postJSON posts body and treats any non-2xx response as a failure, retryable for 429 and 5xx
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return permanentError{err}
+}
+
+// formatBody renders the message and fields of an event as plain text
+func formatBody(event Event) string {
+	var b strings.Builder
+	b.WriteString(event.Message)
+
+	keys := make([]string, 0, len(event.Fields))
+	for key := range event.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0 {
+		b.WriteString("\n")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n%s: %v", key, event.Fields[key])
+	}
+	return b.String()
+}
+
+// toJSON is the json template function, it renders a value as a JSON literal
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
@@ -0,0 +1,273 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+func testEvent() Event {
+	return Event{
+		Type:     EventOrderFilled,
+		Severity: SeverityInfo,
+		Title:    "Order filled: buy 0.5 BTCUSDT",
+		Message:  "buy 0.5 BTCUSDT at 50000.00 on bybit",
+		Exchange: "bybit",
+		Symbol:   "BTCUSDT",
+		Fields:   map[string]interface{}{"price": 50000.0, "quantity": 0.5},
+		Time:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// capture returns a server that records request bodies and answers with status
+func capture(t *testing.T, status int) (*httptest.Server, chan *http.Request, chan []byte) {
+	t.Helper()
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests, bodies
+}
+
+func TestWebhookChannel(t *testing.T) {
+	server, requests, bodies := capture(t, http.StatusNoContent)
+
+	channel, err := NewChannel(config.NotificationChannelConfig{
+		Type:    ChannelWebhook,
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	if err := channel.Send(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	req := <-requests
+	if req.Header.Get("Authorization") != "Bearer token" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	var received Event
+	if err := json.Unmarshal(<-bodies, &received); err != nil {
+		t.Fatalf("body is not an event: %v", err)
+	}
+	if received.Type != EventOrderFilled || received.Symbol != "BTCUSDT" || received.Fields["price"] != 50000.0 {
+		t.Errorf("unexpected event %+v", received)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, _, bodies := capture(t, http.StatusOK)
+
+	channel, err := NewChannel(config.NotificationChannelConfig{
+		Type:     ChannelWebhook,
+		URL:      server.URL,
+		Template: `{"alert": {{json .Title}}, "level": "{{.Severity}}", "price": {{index .Fields "price"}}}`,
+	})
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	if err := channel.Send(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	expected := `{"alert": "Order filled: buy 0.5 BTCUSDT", "level": "info", "price": 50000}`
+	if body := string(<-bodies); body != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	if _, err := NewChannel(config.NotificationChannelConfig{Type: ChannelWebhook, URL: server.URL, Template: "{{.Title"}); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
+
+func TestChatChannels(t *testing.T) {
+	server, _, bodies := capture(t, http.StatusOK)
+
+	tests := []struct {
+		channelType string
+		key         string
+		prefix      string
+	}{
+		{ChannelSlack, "text", "*Order filled: buy 0.5 BTCUSDT*\nbuy 0.5 BTCUSDT at 50000.00 on bybit\n\nprice: 50000\nquantity: 0.5"},
+		{ChannelDiscord, "content", "**Order filled: buy 0.5 BTCUSDT**\n"},
+	}
+	for _, tt := range tests {
+		channel, err := NewChannel(config.NotificationChannelConfig{Type: tt.channelType, URL: server.URL})
+		if err != nil {
+			t.Fatalf("failed to create %s channel: %v", tt.channelType, err)
+		}
+		if err := channel.Send(context.Background(), testEvent()); err != nil {
+			t.Fatalf("failed to send to %s: %v", tt.channelType, err)
+		}
+
+		var payload map[string]string
+		if err := json.Unmarshal(<-bodies, &payload); err != nil {
+			t.Fatalf("invalid %s payload: %v", tt.channelType, err)
+		}
+		if !strings.HasPrefix(payload[tt.key], tt.prefix) {
+			t.Errorf("%s: expected %q to start with %q", tt.channelType, payload[tt.key], tt.prefix)
+		}
+	}
+
+	// Discord rejects content over 2000 characters
+	channel, _ := NewChannel(config.NotificationChannelConfig{Type: ChannelDiscord, URL: server.URL})
+	event := testEvent()
+	event.Message = strings.Repeat("x", 3000)
+	if err := channel.Send(context.Background(), event); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	var payload map[string]string
+	json.Unmarshal(<-bodies, &payload)
+	if len(payload["content"]) != discordMaxContent {
+		t.Errorf("expected content truncated to %d characters, got %d", discordMaxContent, len(payload["content"]))
+	}
+
+	// Multi-byte characters are counted and cut whole
+	event.Message = strings.Repeat("€", 3000)
+	if err := channel.Send(context.Background(), event); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	json.Unmarshal(<-bodies, &payload)
+	if content := payload["content"]; !utf8.ValidString(content) || utf8.RuneCountInString(content) != discordMaxContent {
+		t.Errorf("expected valid content truncated to %d characters, got %d", discordMaxContent, utf8.RuneCountInString(content))
+	}
+}
+
+func TestPostJSONErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		server, _, _ := capture(t, tt.status)
+		channel, _ := NewChannel(config.NotificationChannelConfig{Type: ChannelSlack, URL: server.URL})
+
+		err := channel.Send(context.Background(), testEvent())
+		if err == nil {
+			t.Fatalf("expected an error for status %d", tt.status)
+		}
+		if isPermanent(err) != tt.permanent {
+			t.Errorf("status %d: expected permanent=%v, got %v", tt.status, tt.permanent, isPermanent(err))
+		}
+	}
+}
+
+func TestNewChannelValidation(t *testing.T) {
+	for _, cfg := range []config.NotificationChannelConfig{
+		{Type: ChannelWebhook},
+		{Type: ChannelDiscord},
+		{Type: ChannelSMTP, SMTP: config.SMTPConfig{Host: "localhost"}},
+		{Type: "pager", URL: "http://localhost"},
+	} {
+		if _, err := NewChannel(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+// fakeSMTP accepts one unauthenticated message and returns the DATA section
+func fakeSMTP(t *testing.T) (string, int, chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 OK")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+func TestSMTPChannel(t *testing.T) {
+	host, port, messages := fakeSMTP(t)
+
+	channel, err := NewChannel(config.NotificationChannelConfig{
+		Type: ChannelSMTP,
+		SMTP: config.SMTPConfig{Host: host, Port: port, From: "bot@example.com", To: []string{"ops@example.com", "me@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	if err := channel.Send(context.Background(), testEvent()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	select {
+	case message := <-messages:
+		for _, want := range []string{
+			"From: bot@example.com\r\n",
+			"To: ops@example.com, me@example.com\r\n",
+			"Subject: [mercantile] Order filled: buy 0.5 BTCUSDT\r\n",
+			"buy 0.5 BTCUSDT at 50000.00 on bybit\r\n\r\nprice: 50000\r\nquantity: 0.5",
+		} {
+			if !strings.Contains(message, want) {
+				t.Errorf("expected %q in message:\n%s", want, message)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
@@ -0,0 +1,193 @@
+package notifier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Delivery defaults for channels that leave them unset
+const (
+	defaultRetryDelay = 2 * time.Second
+	defaultTimeout    = 10 * time.Second
+	queueSize         = 100
+	rateWindow        = time.Minute
+)
+
+// Delivery results, used as the result label and in the channel status
+const (
+	resultSent        = "sent"
+	resultFailed      = "failed"
+	resultRateLimited = "rate_limited"
+	resultDropped     = "dropped" // Queue full
+)
+
+// dispatcher filters, rate limits and queues events for one channel and delivers them with retries
+type dispatcher struct {
+	name       string
+	channel    Channel
+	events     map[string]bool // Delivered event types, all when empty
+	rateLimit  int
+	maxRetries int
+	retryDelay time.Duration
+	timeout    time.Duration
+	logger     zerolog.Logger
+
+	queue  chan Event
+	sent   []time.Time // Accepted events within the rate window, only touched by enqueue
+	counts map[string]*uint64
+	now    func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func newDispatcher(cfg config.NotificationChannelConfig, logger zerolog.Logger) (*dispatcher, error) {
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	channel, err := NewChannel(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dispatcher{
+		name:       cfg.Name,
+		channel:    channel,
+		events:     make(map[string]bool, len(cfg.Events)),
+		rateLimit:  cfg.RateLimit,
+		maxRetries: cfg.MaxRetries,
+		retryDelay: cfg.RetryDelay,
+		timeout:    cfg.Timeout,
+		logger:     logger.With().Str("channel", cfg.Name).Logger(),
+		queue:      make(chan Event, queueSize),
+		counts:     make(map[string]*uint64),
+		now:        time.Now,
+	}
+	if d.retryDelay <= 0 {
+		d.retryDelay = defaultRetryDelay
+	}
+	for _, eventType := range cfg.Events {
+		d.events[eventType] = true
+	}
+	for _, result := range []string{resultSent, resultFailed, resultRateLimited, resultDropped} {
+		d.counts[result] = new(uint64)
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	return d, nil
+}
+
+// enqueue queues an event the channel subscribes to, unless the rate limit is reached or the queue is full
+func (d *dispatcher) enqueue(event Event) {
+	if len(d.events) > 0 && !d.events[event.Type] {
+		return
+	}
+
+	if d.rateLimit > 0 {
+		now := d.now()
+		recent := d.sent[:0]
+		for _, sentAt := range d.sent {
+			if now.Sub(sentAt) < rateWindow {
+				recent = append(recent, sentAt)
+			}
+		}
+		d.sent = recent
+
+		if len(d.sent) >= d.rateLimit {
+			d.count(resultRateLimited)
+			d.logger.Warn().Str("event", event.Type).Int("rate_limit", d.rateLimit).Msg("Notification rate limit reached, dropping event")
+			return
+		}
+		d.sent = append(d.sent, now)
+	}
+
+	select {
+	case d.queue <- event:
+	default:
+		d.count(resultDropped)
+		d.logger.Warn().Str("event", event.Type).Msg("Notification queue full, dropping event")
+	}
+}
+
+func (d *dispatcher) start() {
+	d.done.Add(1)
+	go func() {
+		defer d.done.Done()
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+			case event := <-d.queue:
+				d.deliver(event)
+			}
+		}
+	}()
+}
+
+// stop cancels pending retries and waits for the delivery goroutine to exit
+func (d *dispatcher) stop() {
+	d.cancel()
+	d.done.Wait()
+}
+
+// deliver sends an event, retrying with exponential backoff until it succeeds, fails permanently or retries run out
+func (d *dispatcher) deliver(event Event) {
+	delay := d.retryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+		err := d.channel.Send(ctx, event)
+		cancel()
+
+		if err == nil {
+			d.count(resultSent)
+			return
+		}
+		if isPermanent(err) || attempt >= d.maxRetries {
+			d.count(resultFailed)
+			d.logger.Error().Err(err).Str("event", event.Type).Int("attempts", attempt+1).Msg("Failed to deliver notification")
+			return
+		}
+
+		d.logger.Warn().Err(err).Str("event", event.Type).Dur("retry_in", delay).Msg("Notification delivery failed, retrying")
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (d *dispatcher) count(result string) {
+	atomic.AddUint64(d.counts[result], 1)
+	notifications.With(d.name, result).Inc()
+}
+
+func (d *dispatcher) status() map[string]interface{} {
+	events := make([]string, 0, len(d.events))
+	for eventType := range d.events {
+		events = append(events, eventType)
+	}
+	sort.Strings(events)
+
+	status := map[string]interface{}{
+		"name":   d.name,
+		"events": events,
+		"queued": len(d.queue),
+	}
+	for result, count := range d.counts {
+		status[result] = atomic.LoadUint64(count)
+	}
+	return status
+}
@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// stubChannel fails the first failures sends with err and records the event types it delivers
+type stubChannel struct {
+	failures int32
+	err      error
+	attempts int32
+	sent     chan string
+}
+
+func (c *stubChannel) Send(ctx context.Context, event Event) error {
+	if atomic.AddInt32(&c.attempts, 1) <= c.failures {
+		return c.err
+	}
+	c.sent <- event.Type
+	return nil
+}
+
+func newTestDispatcher(t *testing.T, cfg config.NotificationChannelConfig, channel Channel) *dispatcher {
+	t.Helper()
+	cfg.Type = ChannelWebhook
+	cfg.URL = "http://localhost"
+	d, err := newDispatcher(cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create dispatcher: %v", err)
+	}
+	d.channel = channel
+	return d
+}
+
+func expectSent(t *testing.T, channel *stubChannel, eventType string) {
+	t.Helper()
+	select {
+	case sent := <-channel.sent:
+		if sent != eventType {
+			t.Errorf("expected %s, got %s", eventType, sent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not delivered", eventType)
+	}
+}
+
+func TestDispatcherEventFilter(t *testing.T) {
+	channel := &stubChannel{sent: make(chan string, 10)}
+	d := newTestDispatcher(t, config.NotificationChannelConfig{Events: []string{EventKillSwitch, EventDailySummary}}, channel)
+	d.start()
+	defer d.stop()
+
+	d.enqueue(Event{Type: EventOrderFilled})
+	d.enqueue(Event{Type: EventKillSwitch})
+	d.enqueue(Event{Type: EventRiskRejected})
+	d.enqueue(Event{Type: EventDailySummary})
+
+	expectSent(t, channel, EventKillSwitch)
+	expectSent(t, channel, EventDailySummary)
+	if attempts := atomic.LoadInt32(&channel.attempts); attempts != 2 {
+		t.Errorf("expected 2 deliveries, got %d", attempts)
+	}
+}
+
+func TestDispatcherRateLimit(t *testing.T) {
+	d := newTestDispatcher(t, config.NotificationChannelConfig{RateLimit: 2}, &stubChannel{})
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		d.enqueue(Event{Type: EventOrderFilled})
+	}
+	if len(d.queue) != 2 || *d.counts[resultRateLimited] != 1 {
+		t.Fatalf("expected 2 queued and 1 rate limited, got %d and %d", len(d.queue), *d.counts[resultRateLimited])
+	}
+
+	// The window slides, so the oldest events stop counting after a minute
+	now = now.Add(rateWindow)
+	d.enqueue(Event{Type: EventOrderFilled})
+	d.enqueue(Event{Type: EventOrderFilled})
+	d.enqueue(Event{Type: EventOrderFilled})
+	if len(d.queue) != 4 || *d.counts[resultRateLimited] != 2 {
+		t.Errorf("expected 4 queued and 2 rate limited, got %d and %d", len(d.queue), *d.counts[resultRateLimited])
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	channel := &stubChannel{failures: 2, err: errors.New("connection refused"), sent: make(chan string, 1)}
+	d := newTestDispatcher(t, config.NotificationChannelConfig{MaxRetries: 2, RetryDelay: time.Millisecond}, channel)
+
+	d.deliver(Event{Type: EventOrderFilled})
+	expectSent(t, channel, EventOrderFilled)
+	if attempts := atomic.LoadInt32(&channel.attempts); attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	if *d.counts[resultSent] != 1 || *d.counts[resultFailed] != 0 {
+		t.Errorf("unexpected counts sent=%d failed=%d", *d.counts[resultSent], *d.counts[resultFailed])
+	}
+
+	// Retries run out
+	channel = &stubChannel{failures: 5, err: errors.New("connection refused")}
+	d = newTestDispatcher(t, config.NotificationChannelConfig{MaxRetries: 1, RetryDelay: time.Millisecond}, channel)
+	d.deliver(Event{Type: EventOrderFilled})
+	if attempts := atomic.LoadInt32(&channel.attempts); attempts != 2 || *d.counts[resultFailed] != 1 {
+		t.Errorf("expected 2 attempts and a failure, got %d attempts", attempts)
+	}
+
+	// Permanent errors are not retried
+	channel = &stubChannel{failures: 5, err: permanentError{errors.New("400 Bad Request")}}
+	d = newTestDispatcher(t, config.NotificationChannelConfig{MaxRetries: 3, RetryDelay: time.Millisecond}, channel)
+	d.deliver(Event{Type: EventOrderFilled})
+	if attempts := atomic.LoadInt32(&channel.attempts); attempts != 1 || *d.counts[resultFailed] != 1 {
+		t.Errorf("expected 1 attempt and a failure, got %d attempts", attempts)
+	}
+}
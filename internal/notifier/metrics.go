@@ -0,0 +1,6 @@
+package notifier
+
+import "github.com/arijanluiken/mercantile/pkg/metrics"
+
+var notifications = metrics.NewCounter("mercantile_notifications_total",
+	"Notifications per channel and delivery result.", "channel", "result")
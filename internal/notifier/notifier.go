@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// Event types channels can filter on
+const (
+	EventOrderFilled   = "order_filled"
+	EventRiskRejected  = "risk_rejected"
+	EventKillSwitch    = "kill_switch"    // Trading halted or resumed by the drawdown limit, or a strategy disabled by its sandbox
+	EventStrategyError = "strategy_error" // A strategy callback failed
+	EventDisconnected  = "disconnected"   // Market data connection dropped
+	EventReconnected   = "reconnected"    // Market data connection restored
+	EventDailySummary  = "daily_summary"
+)
+
+// Event severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// summaryCheckInterval is how often the notifier checks whether the daily summary is due
+const summaryCheckInterval = time.Minute
+
+// Event is a trading event delivered to the configured channels
+type Event struct {
+	Type     string                 `json:"type"`
+	Severity string                 `json:"severity"`
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Exchange string                 `json:"exchange,omitempty"`
+	Symbol   string                 `json:"symbol,omitempty"`
+	Strategy string                 `json:"strategy,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Time     time.Time              `json:"time"`
+}
+
+// Publish broadcasts an event on the engine's event stream, where the notifier actor picks it up.
+// A nil engine, as in tests that never start the actor system, drops the event.
+func Publish(engine *actor.Engine, event Event) {
+	if engine == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	engine.BroadcastEvent(event)
+}
+
+// Messages for notifier actor communication
+type (
+	// StatusMsg returns delivery counts per channel
+	StatusMsg struct{}
+
+	checkSummaryMsg struct{}
+)
+
+// NotifierActor delivers events published with Publish to webhooks, chat and email.
+// Each channel delivers from its own goroutine so a slow endpoint never blocks the actor.
+type NotifierActor struct {
+	config *config.Config
+	db     *database.DB
+	logger zerolog.Logger
+
+	dispatchers []*dispatcher
+	summaryAt   time.Duration // Offset of the daily summary from midnight UTC, negative when disabled
+	nextSummary time.Time
+}
+
+// New creates a new notifier actor
+func New(cfg *config.Config, db *database.DB, logger zerolog.Logger) *NotifierActor {
+	return &NotifierActor{
+		config:    cfg,
+		db:        db,
+		logger:    logger,
+		summaryAt: -1,
+	}
+}
+
+// Receive handles incoming messages
+func (n *NotifierActor) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case actor.Started:
+		n.onStarted(ctx)
+	case actor.Stopped:
+		n.onStopped(ctx)
+	case Event:
+		n.onEvent(msg)
+	case checkSummaryMsg:
+		n.onCheckSummary(time.Now().UTC())
+	case StatusMsg:
+		n.onStatus(ctx)
+	default:
+		// The event stream also carries the engine's own events, which are not notifications
+	}
+}
+
+func (n *NotifierActor) onStarted(ctx *actor.Context) {
+	if n.config != nil {
+		for _, channelConfig := range n.config.Notifications.Channels {
+			d, err := newDispatcher(channelConfig, n.logger)
+			if err != nil {
+				n.logger.Error().Err(err).Str("channel", channelConfig.Name).Msg("Skipping notification channel")
+				continue
+			}
+			d.start()
+			n.dispatchers = append(n.dispatchers, d)
+		}
+
+		if n.config.Notifications.DailySummary != "" {
+			at, err := ParseTimeOfDay(n.config.Notifications.DailySummary)
+			if err != nil {
+				n.logger.Error().Err(err).Msg("Daily summary disabled")
+			} else {
+				n.summaryAt = at
+				n.nextSummary = nextOccurrence(time.Now().UTC(), at)
+				ctx.SendRepeat(ctx.PID(), checkSummaryMsg{}, summaryCheckInterval)
+			}
+		}
+	}
+
+	ctx.Engine().Subscribe(ctx.PID())
+
+	n.logger.Info().
+		Int("channels", len(n.dispatchers)).
+		Msg("Notifier actor started")
+}
+
+func (n *NotifierActor) onStopped(ctx *actor.Context) {
+	ctx.Engine().Unsubscribe(ctx.PID())
+	for _, d := range n.dispatchers {
+		d.stop()
+	}
+	n.logger.Debug().Msg("Notifier actor stopped")
+}
+
+func (n *NotifierActor) onEvent(event Event) {
+	for _, d := range n.dispatchers {
+		d.enqueue(event)
+	}
+}
+
+// onCheckSummary sends the daily summary once the configured time of day has passed
+func (n *NotifierActor) onCheckSummary(now time.Time) {
+	if n.summaryAt < 0 || now.Before(n.nextSummary) {
+		return
+	}
+	n.nextSummary = nextOccurrence(now, n.summaryAt)
+
+	if n.db == nil {
+		return
+	}
+	event, err := DailySummary(n.db, now)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("Failed to build daily summary")
+		return
+	}
+	n.onEvent(event)
+}
+
+func (n *NotifierActor) onStatus(ctx *actor.Context) {
+	channels := make([]map[string]interface{}, 0, len(n.dispatchers))
+	for _, d := range n.dispatchers {
+		channels = append(channels, d.status())
+	}
+
+	ctx.Respond(map[string]interface{}{
+		"channels":     channels,
+		"next_summary": n.nextSummary,
+		"timestamp":    time.Now(),
+	})
+}
+
+// ParseTimeOfDay parses HH:MM into an offset from midnight
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, use HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// nextOccurrence returns the first time after now that is at the given offset from midnight UTC
+func nextOccurrence(now time.Time, at time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	next := midnight.Add(at)
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+func TestNotifierDeliversPublishedEvents(t *testing.T) {
+	server, _, bodies := capture(t, http.StatusOK)
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	cfg := &config.Config{Notifications: config.NotificationsConfig{
+		Channels: []config.NotificationChannelConfig{
+			{Name: "ops", Type: ChannelWebhook, URL: server.URL, Events: []string{EventRiskRejected}},
+			{Name: "broken", Type: ChannelWebhook}, // Skipped, no url
+		},
+	}}
+	pid := engine.Spawn(func() actor.Receiver { return New(cfg, nil, zerolog.Nop()) }, "notifier")
+	defer func() { <-engine.Poison(pid).Done() }()
+
+	// Wait for the subscription before publishing
+	response, err := engine.Request(pid, StatusMsg{}, 5*time.Second).Result()
+	if err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+	if channels := response.(map[string]interface{})["channels"].([]map[string]interface{}); len(channels) != 1 {
+		t.Fatalf("expected 1 channel, got %d", len(channels))
+	}
+
+	Publish(engine, Event{Type: EventOrderFilled, Title: "filtered out"})
+	Publish(engine, Event{Type: EventRiskRejected, Title: "Order rejected", Symbol: "BTCUSDT"})
+
+	select {
+	case body := <-bodies:
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatalf("invalid body: %v", err)
+		}
+		if event.Type != EventRiskRejected || event.Time.IsZero() {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	select {
+	case body := <-bodies:
+		t.Errorf("unexpected delivery %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
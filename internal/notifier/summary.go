@@ -0,0 +1,96 @@
+package notifier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// summaryPeriod is the window the daily summary reports on
+const summaryPeriod = 24 * time.Hour
+
+// DailySummary builds the daily_summary event for the 24 hours before end. PnL is the change in
+// portfolio value between the first and last snapshot of each exchange, net of deposits and withdrawals.
+func DailySummary(db *database.DB, end time.Time) (Event, error) {
+	start := end.Add(-summaryPeriod)
+
+	snapshots, err := db.GetPortfolioSnapshots("", start, end)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to get portfolio snapshots: %w", err)
+	}
+	fills, err := db.GetFills("", start, end)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to get fills: %w", err)
+	}
+
+	first := make(map[string]*database.PortfolioSnapshot)
+	last := make(map[string]*database.PortfolioSnapshot)
+	for _, snapshot := range snapshots {
+		if _, exists := first[snapshot.Exchange]; !exists {
+			first[snapshot.Exchange] = snapshot
+		}
+		last[snapshot.Exchange] = snapshot
+	}
+
+	exchanges := make([]string, 0, len(last))
+	for exchange := range last {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	fields := map[string]interface{}{"fills": len(fills)}
+	var baseCurrency string
+	var startValue, endValue, netFlows float64
+	for _, exchange := range exchanges {
+		opening, closing := first[exchange], last[exchange]
+		baseCurrency = closing.BaseCurrency
+
+		flows, err := db.GetCashFlows(exchange, opening.CreatedAt, closing.CreatedAt)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to get cash flows: %w", err)
+		}
+		exchangeFlows := 0.0
+		for _, flow := range flows {
+			// Flows recorded with the opening snapshot are already part of its value
+			if flow.CreatedAt.After(opening.CreatedAt) {
+				exchangeFlows += flow.Value
+			}
+		}
+
+		startValue += opening.TotalValue
+		endValue += closing.TotalValue
+		netFlows += exchangeFlows
+		fields[exchange+"_pnl"] = round2(closing.TotalValue - opening.TotalValue - exchangeFlows)
+	}
+
+	pnl := endValue - startValue - netFlows
+	fields["pnl"] = round2(pnl)
+	fields["start_value"] = round2(startValue)
+	fields["end_value"] = round2(endValue)
+	fields["net_flows"] = round2(netFlows)
+
+	message := "No portfolio snapshots in the last 24 hours"
+	if len(exchanges) > 0 {
+		message = fmt.Sprintf("PnL %+.2f %s over the last 24 hours, portfolio value %.2f %s, %d fills",
+			pnl, baseCurrency, endValue, baseCurrency, len(fills))
+		if startValue > 0 {
+			fields["return_pct"] = round2(pnl / startValue * 100)
+		}
+	}
+
+	return Event{
+		Type:     EventDailySummary,
+		Severity: SeverityInfo,
+		Title:    "Daily summary " + end.Format("2006-01-02"),
+		Message:  message,
+		Fields:   fields,
+		Time:     end,
+	}, nil
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
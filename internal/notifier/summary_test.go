@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+func TestDailySummary(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	end := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	snapshots := []*database.PortfolioSnapshot{
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 900, CreatedAt: end.Add(-30 * time.Hour)}, // Outside the window
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1000, CreatedAt: end.Add(-23 * time.Hour)},
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1650, CreatedAt: end.Add(-time.Hour)},
+		{Exchange: "bitvavo", BaseCurrency: "USDT", TotalValue: 2000, CreatedAt: end.Add(-20 * time.Hour)},
+		{Exchange: "bitvavo", BaseCurrency: "USDT", TotalValue: 1950, CreatedAt: end.Add(-2 * time.Hour)},
+	}
+	for _, snapshot := range snapshots {
+		if err := db.SavePortfolioSnapshot(snapshot); err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+	}
+
+	// A 500 deposit is not profit
+	if _, err := db.SaveCashFlow(&database.CashFlow{Exchange: "bybit", ExternalID: "d1", Asset: "USDT", Amount: 500, Value: 500, BaseCurrency: "USDT", Source: "manual", CreatedAt: end.Add(-10 * time.Hour)}); err != nil {
+		t.Fatalf("failed to save cash flow: %v", err)
+	}
+	for i, id := range []string{"o1", "o2"} {
+		if err := db.SaveFill(&database.Fill{Exchange: "bybit", OrderID: id, Symbol: "BTCUSDT", Side: "buy", Quantity: 0.01, Price: 50000, CreatedAt: end.Add(-time.Duration(i+3) * time.Hour)}); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+
+	event, err := DailySummary(db, end)
+	if err != nil {
+		t.Fatalf("failed to build summary: %v", err)
+	}
+
+	if event.Type != EventDailySummary || event.Title != "Daily summary 2024-03-02" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	expected := map[string]interface{}{
+		"fills":       2,
+		"pnl":         100.0,
+		"bybit_pnl":   150.0,
+		"bitvavo_pnl": -50.0,
+		"start_value": 3000.0,
+		"end_value":   3600.0,
+		"net_flows":   500.0,
+		"return_pct":  3.33,
+	}
+	for key, value := range expected {
+		if event.Fields[key] != value {
+			t.Errorf("%s: expected %v, got %v", key, value, event.Fields[key])
+		}
+	}
+	if event.Message != "PnL +100.00 USDT over the last 24 hours, portfolio value 3600.00 USDT, 2 fills" {
+		t.Errorf("unexpected message %q", event.Message)
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	at, err := ParseTimeOfDay("08:30")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	now := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	if next := nextOccurrence(now, at); !next.Equal(time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected today, got %v", next)
+	}
+	now = time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	if next := nextOccurrence(now, at); !next.Equal(time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected tomorrow, got %v", next)
+	}
+
+	if _, err := ParseTimeOfDay("25:00"); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+}
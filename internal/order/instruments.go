@@ -0,0 +1,192 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// instrumentRefreshInterval controls how often symbol filters are reloaded from the exchange
+const instrumentRefreshInterval = 1 * time.Hour
+
+// floatTolerance absorbs floating point noise when snapping values to a step
+const floatTolerance = 1e-9
+
+// InstrumentRegistry caches exchange symbol filters used to normalize orders
+type InstrumentRegistry struct {
+	symbols   map[string]*exchanges.Symbol
+	updatedAt time.Time
+	mutex     sync.RWMutex
+}
+
+// NewInstrumentRegistry creates an empty instrument registry
+func NewInstrumentRegistry() *InstrumentRegistry {
+	return &InstrumentRegistry{
+		symbols: make(map[string]*exchanges.Symbol),
+	}
+}
+
+// Load replaces the registry contents with the exchange's current symbol list
+func (r *InstrumentRegistry) Load(ctx context.Context, exchange exchanges.Exchange) error {
+	info, err := exchange.GetExchangeInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get exchange info: %w", err)
+	}
+	if info == nil {
+		return fmt.Errorf("exchange returned no instrument information")
+	}
+
+	r.Update(info.Symbols)
+	return nil
+}
+
+// Update replaces the registry contents with the given symbols
+func (r *InstrumentRegistry) Update(symbols []*exchanges.Symbol) {
+	updated := make(map[string]*exchanges.Symbol, len(symbols))
+	for _, symbol := range symbols {
+		if symbol == nil || symbol.Name == "" {
+			continue
+		}
+		updated[symbol.Name] = symbol
+	}
+
+	r.mutex.Lock()
+	r.symbols = updated
+	r.updatedAt = time.Now()
+	r.mutex.Unlock()
+}
+
+// Get returns the filters for a symbol
+func (r *InstrumentRegistry) Get(symbol string) (*exchanges.Symbol, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	info, exists := r.symbols[symbol]
+	return info, exists
+}
+
+// Count returns the number of known instruments
+func (r *InstrumentRegistry) Count() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.symbols)
+}
+
+// UpdatedAt returns when the registry was last loaded
+func (r *InstrumentRegistry) UpdatedAt() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.updatedAt
+}
+
+// Normalize rounds an order's quantity to the lot step and its price to the tick size,
+// then checks the result against the symbol's size and notional limits.
+// referencePrice is used for the notional check when the order has no price (market orders).
+// Symbols the registry does not know are passed through unchanged.
+func (r *InstrumentRegistry) Normalize(symbol, orderType string, quantity, price, referencePrice float64) (float64, float64, error) {
+	info, exists := r.Get(symbol)
+	if !exists {
+		return quantity, price, nil
+	}
+
+	if !isTradingStatus(info.Status) {
+		return 0, 0, fmt.Errorf("symbol %s is not trading (status: %s)", symbol, info.Status)
+	}
+
+	normalizedQty := roundDownToStep(quantity, info.StepSize, info.QuantityPrecision)
+	if normalizedQty <= 0 {
+		return 0, 0, fmt.Errorf("quantity %g for %s rounds to zero with lot step %g", quantity, symbol, info.StepSize)
+	}
+
+	if info.MinOrderSize > 0 && normalizedQty < info.MinOrderSize {
+		return 0, 0, fmt.Errorf("quantity %g for %s is below minimum order size %g", normalizedQty, symbol, info.MinOrderSize)
+	}
+	if info.MaxOrderSize > 0 && normalizedQty > info.MaxOrderSize {
+		return 0, 0, fmt.Errorf("quantity %g for %s exceeds maximum order size %g", normalizedQty, symbol, info.MaxOrderSize)
+	}
+
+	normalizedPrice := price
+	if price > 0 {
+		normalizedPrice = roundToStep(price, info.TickSize, info.PricePrecision)
+		if normalizedPrice <= 0 {
+			return 0, 0, fmt.Errorf("price %g for %s rounds to zero with tick size %g", price, symbol, info.TickSize)
+		}
+	} else if orderType == OrderTypeLimit {
+		return 0, 0, fmt.Errorf("limit order for %s requires a price", symbol)
+	}
+
+	notionalPrice := normalizedPrice
+	if notionalPrice <= 0 {
+		notionalPrice = referencePrice
+	}
+
+	if notionalPrice > 0 {
+		notional := normalizedQty * notionalPrice
+		if info.MinNotional > 0 && notional < info.MinNotional {
+			return 0, 0, fmt.Errorf("order value %.8g for %s is below minimum notional %g", notional, symbol, info.MinNotional)
+		}
+		if info.MaxNotional > 0 && notional > info.MaxNotional {
+			return 0, 0, fmt.Errorf("order value %.8g for %s exceeds maximum notional %g", notional, symbol, info.MaxNotional)
+		}
+	}
+
+	return normalizedQty, normalizedPrice, nil
+}
+
+// isTradingStatus reports whether an instrument status allows new orders
+func isTradingStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "", "trading", "active", "online":
+		return true
+	default:
+		return false
+	}
+}
+
+// roundDownToStep truncates a value to a multiple of step, falling back to decimal precision
+func roundDownToStep(value, step float64, precision int) float64 {
+	if step > 0 {
+		value = math.Floor(value/step+floatTolerance) * step
+		return roundToPrecision(value, decimalsOf(step))
+	}
+	if precision > 0 {
+		factor := math.Pow(10, float64(precision))
+		return math.Floor(value*factor+floatTolerance) / factor
+	}
+	return value
+}
+
+// roundToStep rounds a value to the nearest multiple of step, falling back to decimal precision
+func roundToStep(value, step float64, precision int) float64 {
+	if step > 0 {
+		value = math.Round(value/step) * step
+		return roundToPrecision(value, decimalsOf(step))
+	}
+	if precision > 0 {
+		return roundToPrecision(value, precision)
+	}
+	return value
+}
+
+// roundToPrecision removes floating point residue beyond the given number of decimals
+func roundToPrecision(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+// decimalsOf returns the number of decimals needed to represent a step size
+func decimalsOf(step float64) int {
+	decimals := 0
+	for decimals < 16 && math.Abs(step-math.Round(step)) > floatTolerance {
+		step *= 10
+		decimals++
+	}
+	return decimals
+}
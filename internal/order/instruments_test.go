@@ -0,0 +1,137 @@
+package order
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func testInstruments() *InstrumentRegistry {
+	registry := NewInstrumentRegistry()
+	registry.Update([]*exchanges.Symbol{
+		{
+			Name:              "BTCUSDT",
+			BaseAsset:         "BTC",
+			QuoteAsset:        "USDT",
+			Status:            "Trading",
+			MinOrderSize:      0.000048,
+			MaxOrderSize:      71.73956243,
+			PricePrecision:    2,
+			QuantityPrecision: 6,
+			TickSize:          0.01,
+			StepSize:          0.000001,
+			MinNotional:       1,
+			MaxNotional:       2000000,
+		},
+		{
+			Name:     "HALTUSDT",
+			Status:   "Closed",
+			StepSize: 1,
+		},
+	})
+	return registry
+}
+
+func TestInstrumentRegistryNormalize(t *testing.T) {
+	registry := testInstruments()
+
+	quantity, price, err := registry.Normalize("BTCUSDT", OrderTypeLimit, 0.0123456789, 50000.126, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quantity != 0.012345 {
+		t.Errorf("expected quantity rounded down to 0.012345, got %v", quantity)
+	}
+	if price != 50000.13 {
+		t.Errorf("expected price rounded to 50000.13, got %v", price)
+	}
+}
+
+func TestInstrumentRegistryRejects(t *testing.T) {
+	registry := testInstruments()
+
+	tests := []struct {
+		name      string
+		symbol    string
+		orderType string
+		quantity  float64
+		price     float64
+		reference float64
+		contains  string
+	}{
+		{"rounds to zero", "BTCUSDT", OrderTypeMarket, 0.0000001, 0, 50000, "rounds to zero"},
+		{"below min size", "BTCUSDT", OrderTypeMarket, 0.00001, 0, 50000, "below minimum order size"},
+		{"above max size", "BTCUSDT", OrderTypeMarket, 100, 0, 50000, "exceeds maximum order size"},
+		{"below min notional", "BTCUSDT", OrderTypeLimit, 0.0001, 5000, 0, "below minimum notional"},
+		{"market below min notional", "BTCUSDT", OrderTypeMarket, 0.00005, 0, 10000, "below minimum notional"},
+		{"limit without price", "BTCUSDT", OrderTypeLimit, 0.01, 0, 0, "requires a price"},
+		{"not trading", "HALTUSDT", OrderTypeMarket, 10, 0, 1, "not trading"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := registry.Normalize(tt.symbol, tt.orderType, tt.quantity, tt.price, tt.reference)
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.contains)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("expected error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+}
+
+func TestInstrumentRegistryUnknownSymbol(t *testing.T) {
+	registry := testInstruments()
+
+	quantity, price, err := registry.Normalize("ETHUSDT", OrderTypeLimit, 0.123456789, 3000.123456, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quantity != 0.123456789 || price != 3000.123456 {
+		t.Errorf("expected unknown symbol to pass through unchanged, got %v @ %v", quantity, price)
+	}
+}
+
+func TestInstrumentRegistryPrecisionFallback(t *testing.T) {
+	registry := NewInstrumentRegistry()
+	registry.Update([]*exchanges.Symbol{
+		{Name: "ETHUSDT", PricePrecision: 2, QuantityPrecision: 4},
+	})
+
+	quantity, price, err := registry.Normalize("ETHUSDT", OrderTypeLimit, 1.23456, 3000.456, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quantity != 1.2345 {
+		t.Errorf("expected quantity 1.2345, got %v", quantity)
+	}
+	if price != 3000.46 {
+		t.Errorf("expected price 3000.46, got %v", price)
+	}
+}
+
+func TestInstrumentRegistryLoad(t *testing.T) {
+	registry := NewInstrumentRegistry()
+
+	// mockExchange returns no exchange info
+	if err := registry.Load(context.Background(), &mockExchange{name: "test_exchange"}); err == nil {
+		t.Error("expected error when exchange returns no instrument information")
+	}
+	if registry.Count() != 0 {
+		t.Errorf("expected empty registry, got %d instruments", registry.Count())
+	}
+
+	registry = testInstruments()
+	if registry.Count() != 2 {
+		t.Errorf("expected 2 instruments, got %d", registry.Count())
+	}
+	if registry.UpdatedAt().IsZero() {
+		t.Error("expected update time to be set")
+	}
+	if _, ok := registry.Get("BTCUSDT"); !ok {
+		t.Error("expected BTCUSDT to be registered")
+	}
+}
@@ -0,0 +1,22 @@
+package order
+
+import "github.com/arijanluiken/mercantile/pkg/metrics"
+
+// Reasons an order is rejected, used as the reason label
+const (
+	rejectNoExchange      = "no_exchange"
+	rejectNoPrice         = "no_price"
+	rejectSymbolFilters   = "symbol_filters"
+	rejectRisk            = "risk"
+	rejectRiskUnavailable = "risk_unavailable"
+	rejectExchange        = "exchange"
+)
+
+var (
+	ordersPlaced = metrics.NewCounter("mercantile_orders_placed_total",
+		"Orders accepted by the exchange per order type.", "exchange", "type")
+	ordersFilled = metrics.NewCounter("mercantile_orders_filled_total",
+		"Orders the exchange reported filled, on placement or in an order update.", "exchange")
+	ordersRejected = metrics.NewCounter("mercantile_orders_rejected_total",
+		"Orders rejected before or by the exchange per reason.", "exchange", "reason")
+)
@@ -3,13 +3,18 @@ package order
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/audit"
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/internal/notifier"
 	"github.com/arijanluiken/mercantile/internal/risk"
+	"github.com/arijanluiken/mercantile/internal/valuation"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
@@ -34,6 +39,18 @@ const (
 	StatusRejected        = "rejected"
 )
 
+// orderPollInterval controls how often orders working on the exchange are checked for fills
+const orderPollInterval = 5 * time.Second
+
+// isFinal reports whether an order in status can no longer change on the exchange
+func isFinal(status string) bool {
+	switch status {
+	case StatusFilled, StatusCancelled, StatusRejected:
+		return true
+	}
+	return false
+}
+
 // Messages for order manager actor communication
 type (
 	PlaceOrderMsg struct {
@@ -47,6 +64,7 @@ type (
 		TrailPercent float64 // For trailing stops (percentage)
 		TimeInForce  string  // "GTC", "IOC", "FOK"
 		Reason       string
+		Strategy     string // Strategy that placed the order, empty for manual orders
 	}
 
 	PlaceTrailingStopMsg struct {
@@ -56,6 +74,7 @@ type (
 		TrailAmount  float64 // Absolute trail amount
 		TrailPercent float64 // Percentage trail amount
 		Reason       string
+		Strategy     string
 	}
 
 	PlaceStopOrderMsg struct {
@@ -65,6 +84,7 @@ type (
 		StopPrice  float64
 		LimitPrice float64 // Optional, for stop-limit orders
 		Reason     string
+		Strategy   string
 	}
 
 	CancelOrderMsg struct {
@@ -109,6 +129,7 @@ type EnhancedOrder struct {
 	TriggerPrice  float64 // Last trigger price for stop orders
 	IsTriggered   bool    // Whether stop order has been triggered
 	ParentOrderID string  // For stop orders created from other orders
+	Strategy      string  // Strategy the fill is attributed to
 }
 
 // OrderManagerActor manages order placement and advanced order types
@@ -119,6 +140,7 @@ type OrderManagerActor struct {
 	logger       zerolog.Logger
 	orders       map[string]*EnhancedOrder // Active orders by ID
 	exchange     exchanges.Exchange        // Reference to exchange interface
+	instruments  *InstrumentRegistry       // Symbol filters used to normalize orders
 
 	// Actor references
 	riskManagerPID *actor.PID
@@ -130,6 +152,10 @@ type OrderManagerActor struct {
 	priceCache    map[string]float64        // Latest prices by symbol
 	mutex         sync.RWMutex              // Thread safety
 
+	// Rates of quote assets in the base currency, recorded with fills for reporting
+	rates      *valuation.Service
+	ratesMutex sync.Mutex
+
 	// Monitoring
 	tickerTimer    *time.Ticker
 	monitoringDone chan struct{}
@@ -137,15 +163,22 @@ type OrderManagerActor struct {
 
 // New creates a new order manager actor
 func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolog.Logger) *OrderManagerActor {
+	baseCurrency := ""
+	if cfg != nil {
+		baseCurrency = cfg.Portfolio.BaseCurrency
+	}
+
 	return &OrderManagerActor{
 		exchangeName:   exchangeName,
 		config:         cfg,
 		db:             db,
 		logger:         logger,
 		orders:         make(map[string]*EnhancedOrder),
+		instruments:    NewInstrumentRegistry(),
 		stopOrders:     make(map[string]*EnhancedOrder),
 		trailingStops:  make(map[string]*EnhancedOrder),
 		priceCache:     make(map[string]float64),
+		rates:          valuation.New(baseCurrency),
 		monitoringDone: make(chan struct{}),
 	}
 }
@@ -156,6 +189,61 @@ func (o *OrderManagerActor) SetExchange(exchange exchanges.Exchange) {
 
 	// Trigger order sync now that we have an exchange interface
 	go o.syncOrdersFromExchange(nil)
+
+	// Load symbol filters so orders can be normalized before submission
+	go o.refreshInstruments()
+}
+
+// Instruments returns the instrument registry used for order normalization
+func (o *OrderManagerActor) Instruments() *InstrumentRegistry {
+	return o.instruments
+}
+
+// refreshInstruments reloads symbol filters from the exchange
+func (o *OrderManagerActor) refreshInstruments() {
+	if o.exchange == nil {
+		return
+	}
+
+	refreshCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := o.instruments.Load(refreshCtx, o.exchange); err != nil {
+		o.logger.Warn().Err(err).Msg("Failed to load instrument filters, orders will not be normalized")
+		return
+	}
+
+	o.logger.Info().
+		Int("instruments", o.instruments.Count()).
+		Msg("Instrument filters loaded")
+}
+
+// normalizeOrder applies the symbol's tick size, lot step and notional limits to an order
+func (o *OrderManagerActor) normalizeOrder(symbol, orderType string, quantity, price, referencePrice float64) (float64, float64, error) {
+	normalizedQty, normalizedPrice, err := o.instruments.Normalize(symbol, orderType, quantity, price, referencePrice)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if normalizedQty != quantity || normalizedPrice != price {
+		o.logger.Debug().
+			Str("symbol", symbol).
+			Float64("quantity", quantity).
+			Float64("normalized_quantity", normalizedQty).
+			Float64("price", price).
+			Float64("normalized_price", normalizedPrice).
+			Msg("Order normalized to symbol filters")
+	}
+
+	return normalizedQty, normalizedPrice, nil
+}
+
+// lastPrice returns the cached market price for a symbol
+func (o *OrderManagerActor) lastPrice(symbol string) float64 {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return o.priceCache[symbol]
 }
 
 // SetActorReferences sets references to other actors for communication
@@ -188,11 +276,16 @@ func (o *OrderManagerActor) Receive(ctx *actor.Context) {
 	case OrderUpdateMsg:
 		o.onOrderUpdate(ctx, msg)
 	case PriceUpdateMsg:
+		mailbox.Received(ctx)
 		o.onPriceUpdate(ctx, msg)
 	case SetActorReferencesMsg:
 		o.onSetActorReferences(ctx, msg)
 	case StatusMsg:
 		o.onStatus(ctx)
+	case ApplyOrderPolicyMsg:
+		o.onApplyOrderPolicy(ctx, msg)
+	case FlushOrdersMsg:
+		o.onFlushOrders(ctx)
 	case map[string]interface{}: // Handle strategy signals
 		o.onStrategySignal(ctx, msg)
 	default:
@@ -212,6 +305,12 @@ func (o *OrderManagerActor) onStarted(ctx *actor.Context) {
 
 	// Start price monitoring for stop and trailing orders
 	o.startPriceMonitoring(ctx)
+
+	// Keep instrument filters current
+	o.startInstrumentRefresh()
+
+	// Pick up fills of orders working on the exchange
+	o.startOrderPolling(ctx)
 }
 
 func (o *OrderManagerActor) onInitialized(ctx *actor.Context) {
@@ -372,6 +471,79 @@ func (o *OrderManagerActor) startPriceMonitoring(ctx *actor.Context) {
 	}()
 }
 
+func (o *OrderManagerActor) startInstrumentRefresh() {
+	refreshTicker := time.NewTicker(instrumentRefreshInterval)
+
+	go func() {
+		defer refreshTicker.Stop()
+		for {
+			select {
+			case <-refreshTicker.C:
+				o.refreshInstruments()
+			case <-o.monitoringDone:
+				return
+			}
+		}
+	}()
+}
+
+// startOrderPolling asks the exchange for the orders still working on it, as it does not push their
+// fills, and feeds the ones that changed back to the actor as OrderUpdateMsg
+func (o *OrderManagerActor) startOrderPolling(ctx *actor.Context) {
+	engine, pid := ctx.Engine(), ctx.PID()
+	pollTicker := time.NewTicker(orderPollInterval)
+
+	go func() {
+		defer pollTicker.Stop()
+		for {
+			select {
+			case <-pollTicker.C:
+				for _, update := range o.pollOrders() {
+					engine.Send(pid, OrderUpdateMsg{Order: update})
+				}
+			case <-o.monitoringDone:
+				return
+			}
+		}
+	}()
+}
+
+// pollOrders returns the orders whose status or executed quantity changed on the exchange
+func (o *OrderManagerActor) pollOrders() []*EnhancedOrder {
+	if o.exchange == nil {
+		return nil
+	}
+
+	o.mutex.RLock()
+	working := make([]EnhancedOrder, 0)
+	for id, order := range o.orders {
+		if id != "" && order.Order != nil && !isFinal(order.Status) {
+			working = append(working, *order)
+		}
+	}
+	o.mutex.RUnlock()
+
+	var updates []*EnhancedOrder
+	for i := range working {
+		order := working[i]
+		pollCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		current, err := o.exchange.GetOrder(pollCtx, order.Symbol, order.ID)
+		cancel()
+		if err != nil || current == nil {
+			o.logger.Debug().Err(err).Str("order_id", order.ID).Msg("Failed to poll order")
+			continue
+		}
+		if current.Status == order.Status && current.ExecutedQuantity == order.ExecutedQuantity {
+			continue
+		}
+
+		order.Order = current
+		order.UpdatedAt = time.Now()
+		updates = append(updates, &order)
+	}
+	return updates
+}
+
 func (o *OrderManagerActor) stopPriceMonitoring() {
 	if o.tickerTimer != nil {
 		o.tickerTimer.Stop()
@@ -406,6 +578,7 @@ func (o *OrderManagerActor) onStrategySignal(ctx *actor.Context, signal map[stri
 	quantity, _ := signal["quantity"].(float64)
 	price, _ := signal["price"].(float64)
 	reason, _ := signal["reason"].(string)
+	strategyName, _ := signal["strategy"].(string)
 
 	// Advanced order parameters
 	stopPrice, _ := signal["stop_price"].(float64)
@@ -438,6 +611,7 @@ func (o *OrderManagerActor) onStrategySignal(ctx *actor.Context, signal map[stri
 			TrailAmount:  trailAmount,
 			TrailPercent: trailPercent,
 			Reason:       reason,
+			Strategy:     strategyName,
 		}
 		o.onPlaceTrailingStop(ctx, trailMsg)
 
@@ -449,6 +623,7 @@ func (o *OrderManagerActor) onStrategySignal(ctx *actor.Context, signal map[stri
 			StopPrice:  stopPrice,
 			LimitPrice: price,
 			Reason:     reason,
+			Strategy:   strategyName,
 		}
 		o.onPlaceStopOrder(ctx, stopMsg)
 
@@ -465,6 +640,7 @@ func (o *OrderManagerActor) onStrategySignal(ctx *actor.Context, signal map[stri
 			TrailPercent: trailPercent,
 			TimeInForce:  timeInForce,
 			Reason:       reason,
+			Strategy:     strategyName,
 		}
 		o.onPlaceOrder(ctx, orderMsg)
 	}
@@ -482,10 +658,26 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 
 	if o.exchange == nil {
 		o.logger.Error().Msg("No exchange interface available")
+		ordersRejected.With(o.exchangeName, rejectNoExchange).Inc()
 		ctx.Respond(fmt.Errorf("no exchange interface"))
 		return
 	}
 
+	// Normalize against symbol filters before anything else sees the order
+	referencePrice := msg.StopPrice
+	if referencePrice <= 0 {
+		referencePrice = o.lastPrice(msg.Symbol)
+	}
+	quantity, price, err := o.normalizeOrder(msg.Symbol, msg.Type, msg.Quantity, msg.Price, referencePrice)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("symbol", msg.Symbol).Msg("Order rejected by symbol filters")
+		ordersRejected.With(o.exchangeName, rejectSymbolFilters).Inc()
+		ctx.Respond(fmt.Errorf("order rejected: %w", err))
+		return
+	}
+	msg.Quantity = quantity
+	msg.Price = price
+
 	// Validate order with risk manager first
 	if o.riskManagerPID != nil {
 		validateMsg := risk.ValidateOrderMsg{
@@ -494,11 +686,13 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 			Side:     msg.Side,
 			Quantity: msg.Quantity,
 			Price:    msg.Price,
+			Strategy: msg.Strategy,
 		}
 
-		resp, err := ctx.Request(o.riskManagerPID, validateMsg, 5*time.Second).Result()
+		resp, err := mailbox.Request(ctx, o.riskManagerPID, validateMsg, 5*time.Second).Result()
 		if err != nil {
 			o.logger.Error().Err(err).Msg("Failed to validate order with risk manager")
+			ordersRejected.With(o.exchangeName, rejectRiskUnavailable).Inc()
 			ctx.Respond(fmt.Errorf("risk validation failed: %w", err))
 			return
 		}
@@ -509,6 +703,7 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 					Str("reason", validation.Reason).
 					Strs("warnings", validation.Warnings).
 					Msg("Order rejected by risk manager")
+				ordersRejected.With(o.exchangeName, rejectRisk).Inc()
 				ctx.Respond(fmt.Errorf("order rejected: %s", validation.Reason))
 				return
 			}
@@ -534,6 +729,7 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 			TrailAmount:  msg.TrailAmount,
 			TrailPercent: msg.TrailPercent,
 			Reason:       msg.Reason,
+			Strategy:     msg.Strategy,
 		})
 		return
 	case OrderTypeStopMarket, OrderTypeStopLimit:
@@ -544,6 +740,7 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 			StopPrice:  msg.StopPrice,
 			LimitPrice: msg.Price, // For stop-limit orders
 			Reason:     msg.Reason,
+			Strategy:   msg.Strategy,
 		})
 		return
 	}
@@ -561,24 +758,46 @@ func (o *OrderManagerActor) onPlaceOrder(ctx *actor.Context, msg PlaceOrderMsg)
 		},
 		OriginalType: msg.Type,
 		TimeInForce:  msg.TimeInForce,
+		Strategy:     msg.Strategy,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
+	o.record(ctx, audit.Entry{
+		Type:     audit.TypeOrderRequest,
+		Symbol:   msg.Symbol,
+		Strategy: msg.Strategy,
+		Message:  fmt.Sprintf("%s %s %g %s", msg.Type, msg.Side, msg.Quantity, msg.Symbol),
+		Data: map[string]interface{}{
+			"side":          msg.Side,
+			"type":          msg.Type,
+			"quantity":      msg.Quantity,
+			"price":         msg.Price,
+			"time_in_force": msg.TimeInForce,
+			"reason":        msg.Reason,
+		},
+	})
+
 	// Place order through exchange
 	orderCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	placedOrder, err := o.exchange.PlaceOrder(orderCtx, enhancedOrder.Order)
+	o.recordResponse(ctx, enhancedOrder.Order, msg.Strategy, placedOrder, err)
 	if err != nil {
 		o.logger.Error().Err(err).Msg("Failed to place order")
+		ordersRejected.With(o.exchangeName, rejectExchange).Inc()
 		ctx.Respond(err)
 		return
 	}
+	ordersPlaced.With(o.exchangeName, msg.Type).Inc()
 
 	// Update enhanced order with exchange response
 	enhancedOrder.Order = placedOrder
 	enhancedOrder.UpdatedAt = time.Now()
+	if placedOrder.Status == StatusFilled {
+		o.markFilled(ctx, enhancedOrder)
+	}
 
 	// Store order
 	o.mutex.Lock()
@@ -621,6 +840,7 @@ func (o *OrderManagerActor) onCancelOrder(ctx *actor.Context, msg CancelOrderMsg
 			err := o.exchange.CancelOrder(cancelCtx, msg.Symbol, msg.OrderID)
 			if err != nil {
 				o.logger.Error().Err(err).Msg("Failed to cancel order")
+				o.recordCancel(ctx, msg, order, err)
 				ctx.Respond(err)
 				return
 			}
@@ -630,6 +850,7 @@ func (o *OrderManagerActor) onCancelOrder(ctx *actor.Context, msg CancelOrderMsg
 		order.Status = StatusCancelled
 		order.UpdatedAt = time.Now()
 		o.persistEnhancedOrder(order)
+		o.recordCancel(ctx, msg, order, nil)
 
 		o.logger.Info().Str("order_id", msg.OrderID).Msg("Order cancelled successfully")
 		ctx.Respond("cancelled")
@@ -642,6 +863,7 @@ func (o *OrderManagerActor) onCancelOrder(ctx *actor.Context, msg CancelOrderMsg
 		stopOrder.UpdatedAt = time.Now()
 		delete(o.stopOrders, msg.OrderID)
 		o.persistEnhancedOrder(stopOrder)
+		o.recordCancel(ctx, msg, stopOrder, nil)
 
 		o.logger.Info().Str("order_id", msg.OrderID).Msg("Stop order cancelled successfully")
 		ctx.Respond("cancelled")
@@ -654,6 +876,7 @@ func (o *OrderManagerActor) onCancelOrder(ctx *actor.Context, msg CancelOrderMsg
 		trailOrder.UpdatedAt = time.Now()
 		delete(o.trailingStops, msg.OrderID)
 		o.persistEnhancedOrder(trailOrder)
+		o.recordCancel(ctx, msg, trailOrder, nil)
 
 		o.logger.Info().Str("order_id", msg.OrderID).Msg("Trailing stop order cancelled successfully")
 		ctx.Respond("cancelled")
@@ -661,7 +884,9 @@ func (o *OrderManagerActor) onCancelOrder(ctx *actor.Context, msg CancelOrderMsg
 	}
 
 	// Order not found
-	ctx.Respond(fmt.Errorf("order not found: %s", msg.OrderID))
+	err := fmt.Errorf("order not found: %s", msg.OrderID)
+	o.recordCancel(ctx, msg, nil, err)
+	ctx.Respond(err)
 }
 
 func (o *OrderManagerActor) onGetOrders(ctx *actor.Context, msg GetOrdersMsg) {
@@ -681,9 +906,20 @@ func (o *OrderManagerActor) onGetOrders(ctx *actor.Context, msg GetOrdersMsg) {
 func (o *OrderManagerActor) onOrderUpdate(ctx *actor.Context, msg OrderUpdateMsg) {
 	// Update order status from exchange
 	o.mutex.Lock()
+	previous, known := o.orders[msg.Order.ID]
+	previousStatus := ""
+	if known {
+		previousStatus = previous.Status
+		msg.Order.inherit(previous)
+	}
 	o.orders[msg.Order.ID] = msg.Order
 	o.mutex.Unlock()
 
+	// Count fills on the transition only, updates repeat for the same order
+	if msg.Order.Status == StatusFilled && (!known || previousStatus != StatusFilled) {
+		o.markFilled(ctx, msg.Order)
+	}
+
 	o.persistEnhancedOrder(msg.Order)
 
 	o.logger.Info().
@@ -692,8 +928,47 @@ func (o *OrderManagerActor) onOrderUpdate(ctx *actor.Context, msg OrderUpdateMsg
 		Msg("Order status updated")
 }
 
+// inherit copies what an update from the exchange does not carry from the tracked order it replaces
+func (e *EnhancedOrder) inherit(previous *EnhancedOrder) {
+	if e == previous {
+		return
+	}
+	if e.Order != nil && previous.Order != nil {
+		if e.Symbol == "" {
+			e.Symbol = previous.Symbol
+		}
+		if e.Side == "" {
+			e.Side = previous.Side
+		}
+		if e.Quantity <= 0 {
+			e.Quantity = previous.Quantity
+		}
+	}
+	if e.OriginalType == "" {
+		e.OriginalType = previous.OriginalType
+	}
+	if e.Strategy == "" {
+		e.Strategy = previous.Strategy
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = previous.CreatedAt
+	}
+	if e.StopPrice == 0 {
+		e.StopPrice = previous.StopPrice
+	}
+	if e.TriggerPrice == 0 {
+		e.TriggerPrice = previous.TriggerPrice
+	}
+	if e.TimeInForce == "" {
+		e.TimeInForce = previous.TimeInForce
+	}
+	if e.ParentOrderID == "" {
+		e.ParentOrderID = previous.ParentOrderID
+	}
+}
+
+// persistEnhancedOrder saves the order and records its fill once filled
 func (o *OrderManagerActor) persistEnhancedOrder(order *EnhancedOrder) {
-	// TODO: Implement database persistence for enhanced orders
 	// Reduced chattiness - only log on errors or important state changes
 	if order.Status == StatusFilled || order.Status == StatusCancelled {
 		o.logger.Info().
@@ -702,6 +977,178 @@ func (o *OrderManagerActor) persistEnhancedOrder(order *EnhancedOrder) {
 			Str("type", order.OriginalType).
 			Msg("Order status updated")
 	}
+
+	if err := o.saveOrder(order); err != nil {
+		o.logger.Error().Err(err).Str("order_id", order.ID).Msg("Failed to save order")
+	}
+
+	if order.Status == StatusFilled {
+		o.recordFill(order)
+	}
+}
+
+// saveOrder writes the order to the database under its original type
+func (o *OrderManagerActor) saveOrder(order *EnhancedOrder) error {
+	if o.db == nil || order.Order == nil || order.ID == "" {
+		return nil
+	}
+
+	orderType := order.OriginalType
+	if orderType == "" {
+		orderType = order.Type
+	}
+	return o.db.SaveOrder(&database.Order{
+		ExchangeOrderID: order.ID,
+		Exchange:        o.exchangeName,
+		Symbol:          order.Symbol,
+		Side:            order.Side,
+		Type:            orderType,
+		Quantity:        order.Quantity,
+		Price:           order.Price,
+		Status:          order.Status,
+		CreatedAt:       order.CreatedAt,
+		UpdatedAt:       order.UpdatedAt,
+	})
+}
+
+// executedQuantity returns the quantity the exchange executed, the order quantity when it did not report it
+func executedQuantity(order *EnhancedOrder) float64 {
+	if order.ExecutedQuantity > 0 {
+		return order.ExecutedQuantity
+	}
+	return order.Quantity
+}
+
+// fillPrice returns the average execution price of an order. When the exchange did not report it,
+// the limit, trigger or last traded price is used, as market orders carry no price.
+func (o *OrderManagerActor) fillPrice(order *EnhancedOrder) float64 {
+	for _, price := range []float64{order.AveragePrice, order.Price, order.TriggerPrice} {
+		if price > 0 {
+			return price
+		}
+	}
+	return o.lastPrice(order.Symbol)
+}
+
+// markFilled counts an order that just reached the filled status and publishes it as a notification
+func (o *OrderManagerActor) markFilled(ctx *actor.Context, order *EnhancedOrder) {
+	ordersFilled.With(o.exchangeName).Inc()
+	if ctx == nil {
+		return
+	}
+
+	quantity, price := executedQuantity(order), o.fillPrice(order)
+	orderType := order.OriginalType
+	if orderType == "" {
+		orderType = order.Type
+	}
+
+	notifier.Publish(ctx.Engine(), notifier.Event{
+		Type:     notifier.EventOrderFilled,
+		Severity: notifier.SeverityInfo,
+		Title:    fmt.Sprintf("Order filled: %s %g %s", order.Side, quantity, order.Symbol),
+		Message:  fmt.Sprintf("%s %s order %s for %g %s filled at %g", o.exchangeName, orderType, order.ID, quantity, order.Symbol, price),
+		Exchange: o.exchangeName,
+		Symbol:   order.Symbol,
+		Strategy: order.Strategy,
+		Fields: map[string]interface{}{
+			"order_id": order.ID,
+			"side":     order.Side,
+			"type":     orderType,
+			"quantity": quantity,
+			"price":    price,
+		},
+	})
+}
+
+// recordFill stores a filled order for performance attribution. Repeated updates of the same order are ignored by the database.
+func (o *OrderManagerActor) recordFill(order *EnhancedOrder) {
+	if o.db == nil {
+		return
+	}
+
+	price := o.fillPrice(order)
+	fee, feeCurrency := o.executionFee(order)
+
+	fill := &database.Fill{
+		Exchange:    o.exchangeName,
+		OrderID:     order.ID,
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		Quantity:    executedQuantity(order),
+		Price:       price,
+		Fee:         o.quoteFee(order.Symbol, price, fee, feeCurrency),
+		FeeCurrency: feeCurrency,
+		Strategy:    order.Strategy,
+		CreatedAt:   order.UpdatedAt,
+	}
+	fill.BaseCurrency, fill.QuoteRate = o.quoteRate(order.Symbol)
+
+	if err := o.db.SaveFill(fill); err != nil {
+		o.logger.Error().Err(err).Str("order_id", order.ID).Msg("Failed to record fill")
+	}
+}
+
+// executionFee returns the fee the exchange charged for the order and its currency,
+// asking the exchange when the order update did not carry it
+func (o *OrderManagerActor) executionFee(order *EnhancedOrder) (float64, string) {
+	if order.FeeCurrency != "" || o.exchange == nil {
+		return order.Fee, order.FeeCurrency
+	}
+
+	feeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	executed, err := o.exchange.GetOrder(feeCtx, order.Symbol, order.ID)
+	if err != nil || executed == nil || executed.FeeCurrency == "" {
+		o.logger.Warn().Err(err).Str("order_id", order.ID).Msg("Fee of filled order unknown, recorded as 0")
+		return 0, ""
+	}
+
+	order.Fee, order.FeeCurrency = executed.Fee, executed.FeeCurrency
+	return executed.Fee, executed.FeeCurrency
+}
+
+// quoteFee converts a fee into the quote asset of symbol: fees in the base asset at the fill price,
+// other assets through their rates in the base currency. Fees that cannot be converted count as 0.
+func (o *OrderManagerActor) quoteFee(symbol string, price, fee float64, currency string) float64 {
+	if fee == 0 || currency == "" {
+		return 0
+	}
+	pair, ok := valuation.ParseSymbol(symbol)
+	if !ok {
+		return 0
+	}
+
+	switch currency = strings.ToUpper(currency); currency {
+	case pair.Quote:
+		return fee
+	case pair.Base:
+		return fee * price
+	}
+
+	o.ratesMutex.Lock()
+	feeRate, feeKnown := o.rates.Rate(currency)
+	quoteRate, quoteKnown := o.rates.Rate(pair.Quote)
+	o.ratesMutex.Unlock()
+	if !feeKnown || !quoteKnown || quoteRate <= 0 {
+		o.logger.Warn().Str("symbol", symbol).Str("fee_currency", currency).Msg("No rate for fee currency, fee recorded as 0")
+		return 0
+	}
+	return fee * feeRate / quoteRate
+}
+
+// quoteRate returns the base currency and the price of one unit of the symbol's quote asset in it, 0 if unknown
+func (o *OrderManagerActor) quoteRate(symbol string) (string, float64) {
+	o.ratesMutex.Lock()
+	defer o.ratesMutex.Unlock()
+
+	pair, ok := valuation.ParseSymbol(symbol)
+	if !ok {
+		return o.rates.BaseCurrency(), 0
+	}
+	rate, _ := o.rates.Rate(pair.Quote)
+	return o.rates.BaseCurrency(), rate
 }
 
 func (o *OrderManagerActor) onStatus(ctx *actor.Context) {
@@ -725,6 +1172,7 @@ func (o *OrderManagerActor) onStatus(ctx *actor.Context) {
 		"pending_stop_orders":    pendingStopOrders,
 		"pending_trailing_stops": pendingTrailingStops,
 		"symbols_tracked":        len(o.priceCache),
+		"instruments":            o.instruments.Count(),
 		"timestamp":              time.Now(),
 	}
 
@@ -766,10 +1214,20 @@ func (o *OrderManagerActor) onPlaceTrailingStop(ctx *actor.Context, msg PlaceTra
 	currentPrice, exists := o.priceCache[msg.Symbol]
 	if !exists {
 		o.logger.Error().Str("symbol", msg.Symbol).Msg("No current price available for trailing stop")
+		ordersRejected.With(o.exchangeName, rejectNoPrice).Inc()
 		ctx.Respond(fmt.Errorf("no current price available for %s", msg.Symbol))
 		return
 	}
 
+	quantity, _, err := o.normalizeOrder(msg.Symbol, OrderTypeMarket, msg.Quantity, 0, currentPrice)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("symbol", msg.Symbol).Msg("Trailing stop rejected by symbol filters")
+		ordersRejected.With(o.exchangeName, rejectSymbolFilters).Inc()
+		ctx.Respond(fmt.Errorf("order rejected: %w", err))
+		return
+	}
+	msg.Quantity = quantity
+
 	// Create trailing stop order
 	enhancedOrder := &EnhancedOrder{
 		Order: &exchanges.Order{
@@ -784,6 +1242,7 @@ func (o *OrderManagerActor) onPlaceTrailingStop(ctx *actor.Context, msg PlaceTra
 		TrailAmount:   msg.TrailAmount,
 		TrailPercent:  msg.TrailPercent,
 		HighWaterMark: currentPrice,
+		Strategy:      msg.Strategy,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -801,6 +1260,23 @@ func (o *OrderManagerActor) onPlaceTrailingStop(ctx *actor.Context, msg PlaceTra
 		Float64("initial_price", currentPrice).
 		Msg("Trailing stop order created")
 
+	o.record(ctx, audit.Entry{
+		Type:     audit.TypeOrderRequest,
+		Symbol:   msg.Symbol,
+		Strategy: msg.Strategy,
+		OrderID:  enhancedOrder.ID,
+		Message:  fmt.Sprintf("trailing stop %s %g %s held until triggered", msg.Side, msg.Quantity, msg.Symbol),
+		Data: map[string]interface{}{
+			"side":          msg.Side,
+			"type":          OrderTypeTrailing,
+			"quantity":      msg.Quantity,
+			"trail_amount":  msg.TrailAmount,
+			"trail_percent": msg.TrailPercent,
+			"initial_price": currentPrice,
+			"reason":        msg.Reason,
+		},
+	})
+
 	ctx.Respond(enhancedOrder)
 }
 
@@ -818,6 +1294,16 @@ func (o *OrderManagerActor) onPlaceStopOrder(ctx *actor.Context, msg PlaceStopOr
 		orderType = OrderTypeStopLimit
 	}
 
+	quantity, limitPrice, err := o.normalizeOrder(msg.Symbol, orderType, msg.Quantity, msg.LimitPrice, msg.StopPrice)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("symbol", msg.Symbol).Msg("Stop order rejected by symbol filters")
+		ordersRejected.With(o.exchangeName, rejectSymbolFilters).Inc()
+		ctx.Respond(fmt.Errorf("order rejected: %w", err))
+		return
+	}
+	msg.Quantity = quantity
+	msg.LimitPrice = limitPrice
+
 	// Create stop order
 	enhancedOrder := &EnhancedOrder{
 		Order: &exchanges.Order{
@@ -831,6 +1317,7 @@ func (o *OrderManagerActor) onPlaceStopOrder(ctx *actor.Context, msg PlaceStopOr
 		},
 		OriginalType: orderType,
 		StopPrice:    msg.StopPrice,
+		Strategy:     msg.Strategy,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
@@ -848,6 +1335,22 @@ func (o *OrderManagerActor) onPlaceStopOrder(ctx *actor.Context, msg PlaceStopOr
 		Float64("stop_price", msg.StopPrice).
 		Msg("Stop order created")
 
+	o.record(ctx, audit.Entry{
+		Type:     audit.TypeOrderRequest,
+		Symbol:   msg.Symbol,
+		Strategy: msg.Strategy,
+		OrderID:  enhancedOrder.ID,
+		Message:  fmt.Sprintf("%s %s %g %s held until triggered", orderType, msg.Side, msg.Quantity, msg.Symbol),
+		Data: map[string]interface{}{
+			"side":        msg.Side,
+			"type":        orderType,
+			"quantity":    msg.Quantity,
+			"stop_price":  msg.StopPrice,
+			"limit_price": msg.LimitPrice,
+			"reason":      msg.Reason,
+		},
+	})
+
 	ctx.Respond(enhancedOrder)
 }
 
@@ -861,9 +1364,16 @@ func (o *OrderManagerActor) onModifyOrder(ctx *actor.Context, msg ModifyOrderMsg
 	order, exists := o.orders[msg.OrderID]
 	if !exists {
 		o.mutex.Unlock()
-		ctx.Respond(fmt.Errorf("order not found: %s", msg.OrderID))
+		err := fmt.Errorf("order not found: %s", msg.OrderID)
+		o.recordAmend(ctx, msg, nil, nil, err)
+		ctx.Respond(err)
 		return
 	}
+	before := map[string]interface{}{
+		"quantity":   order.Quantity,
+		"price":      order.Price,
+		"stop_price": order.StopPrice,
+	}
 
 	// Update order fields
 	if msg.NewQuantity != nil {
@@ -886,6 +1396,7 @@ func (o *OrderManagerActor) onModifyOrder(ctx *actor.Context, msg ModifyOrderMsg
 	}
 
 	o.persistEnhancedOrder(order)
+	o.recordAmend(ctx, msg, order, before, nil)
 	ctx.Respond(order)
 }
 
@@ -893,6 +1404,10 @@ func (o *OrderManagerActor) onPriceUpdate(ctx *actor.Context, msg PriceUpdateMsg
 	o.mutex.Lock()
 	o.priceCache[msg.Symbol] = msg.Price
 	o.mutex.Unlock()
+
+	o.ratesMutex.Lock()
+	o.rates.UpdatePrice(msg.Symbol, msg.Price)
+	o.ratesMutex.Unlock()
 }
 
 func (o *OrderManagerActor) checkStopOrders(ctx *actor.Context) {
@@ -1005,19 +1520,36 @@ func (o *OrderManagerActor) triggerStopOrder(ctx *actor.Context, orderID string,
 		marketOrder.Price = stopOrder.Price
 	}
 
+	// Filters may have changed since the stop was accepted
+	quantity, price, err := o.instruments.Normalize(marketOrder.Symbol, marketOrder.Type, marketOrder.Quantity, marketOrder.Price, currentPrice)
+	if err != nil {
+		o.logger.Error().Err(err).Str("order_id", orderID).Msg("Stop order rejected by symbol filters")
+		ordersRejected.With(o.exchangeName, rejectSymbolFilters).Inc()
+		stopOrder.Status = StatusRejected
+		return
+	}
+	marketOrder.Quantity = quantity
+	marketOrder.Price = price
+
 	// Place the market order
 	orderCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	o.recordTrigger(ctx, orderID, stopOrder, marketOrder, currentPrice)
 	placedOrder, err := o.exchange.PlaceOrder(orderCtx, marketOrder)
+	o.recordResponse(ctx, marketOrder, stopOrder.Strategy, placedOrder, err)
 	if err != nil {
 		o.logger.Error().Err(err).Str("order_id", orderID).Msg("Failed to execute stop order")
+		ordersRejected.With(o.exchangeName, rejectExchange).Inc()
 		return
 	}
+	ordersPlaced.With(o.exchangeName, marketOrder.Type).Inc()
 
-	// Update stop order status
-	stopOrder.Status = StatusFilled
-	stopOrder.Order.ID = placedOrder.ID
+	// The market order takes over, polling reports its execution unless it filled already
+	stopOrder.Order = placedOrder
+	if placedOrder.Status == StatusFilled {
+		o.markFilled(ctx, stopOrder)
+	}
 
 	// Move from stopOrders to orders
 	delete(o.stopOrders, orderID)
@@ -1047,19 +1579,33 @@ func (o *OrderManagerActor) triggerTrailingStop(ctx *actor.Context, orderID stri
 		Time:     time.Now(),
 	}
 
-	// Place the market order
+	// Filters may have changed since the trailing stop was accepted
+	quantity, _, err := o.instruments.Normalize(marketOrder.Symbol, marketOrder.Type, marketOrder.Quantity, 0, currentPrice)
+	if err != nil {
+		o.logger.Error().Err(err).Str("order_id", orderID).Msg("Trailing stop rejected by symbol filters")
+		ordersRejected.With(o.exchangeName, rejectSymbolFilters).Inc()
+		trailOrder.Status = StatusRejected
+		return
+	}
+	marketOrder.Quantity = quantity
 	orderCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	o.recordTrigger(ctx, orderID, trailOrder, marketOrder, currentPrice)
 	placedOrder, err := o.exchange.PlaceOrder(orderCtx, marketOrder)
+	o.recordResponse(ctx, marketOrder, trailOrder.Strategy, placedOrder, err)
 	if err != nil {
 		o.logger.Error().Err(err).Str("order_id", orderID).Msg("Failed to execute trailing stop order")
+		ordersRejected.With(o.exchangeName, rejectExchange).Inc()
 		return
 	}
+	ordersPlaced.With(o.exchangeName, marketOrder.Type).Inc()
 
-	// Update trailing stop order status
-	trailOrder.Status = StatusFilled
-	trailOrder.Order.ID = placedOrder.ID
+	// The market order takes over, polling reports its execution unless it filled already
+	trailOrder.Order = placedOrder
+	if placedOrder.Status == StatusFilled {
+		o.markFilled(ctx, trailOrder)
+	}
 
 	// Move from trailingStops to orders
 	delete(o.trailingStops, orderID)
@@ -1067,3 +1613,101 @@ func (o *OrderManagerActor) triggerTrailingStop(ctx *actor.Context, orderID stri
 
 	o.persistEnhancedOrder(trailOrder)
 }
+
+// record adds an entry of this exchange to the audit journal. Without an actor context, as in tests, nothing is recorded.
+func (o *OrderManagerActor) record(ctx *actor.Context, entry audit.Entry) {
+	if ctx == nil {
+		return
+	}
+	entry.Exchange = o.exchangeName
+	if entry.Actor == "" {
+		entry.Actor = "order_manager"
+	}
+	audit.Record(ctx.Engine(), entry)
+}
+
+// recordResponse journals the exchange response to an order request, or the error it returned
+func (o *OrderManagerActor) recordResponse(ctx *actor.Context, request *exchanges.Order, strategy string, placed *exchanges.Order, err error) {
+	entry := audit.Entry{
+		Type:     audit.TypeOrderResponse,
+		Symbol:   request.Symbol,
+		Strategy: strategy,
+		Actor:    "exchange",
+	}
+	if err != nil {
+		entry.Message = fmt.Sprintf("%s %s %g %s failed: %v", request.Type, request.Side, request.Quantity, request.Symbol, err)
+		entry.Data = map[string]interface{}{"error": err.Error()}
+	} else {
+		entry.OrderID = placed.ID
+		entry.Message = fmt.Sprintf("%s %s %g %s %s", placed.Type, placed.Side, placed.Quantity, placed.Symbol, placed.Status)
+		entry.Data = map[string]interface{}{
+			"status":   placed.Status,
+			"side":     placed.Side,
+			"type":     placed.Type,
+			"quantity": placed.Quantity,
+			"price":    placed.Price,
+		}
+	}
+	o.record(ctx, entry)
+}
+
+// recordTrigger journals the order a triggered stop or trailing stop sends to the exchange
+func (o *OrderManagerActor) recordTrigger(ctx *actor.Context, orderID string, held *EnhancedOrder, marketOrder *exchanges.Order, currentPrice float64) {
+	o.record(ctx, audit.Entry{
+		Type:     audit.TypeOrderRequest,
+		Symbol:   marketOrder.Symbol,
+		Strategy: held.Strategy,
+		OrderID:  orderID,
+		Message:  fmt.Sprintf("%s triggered at %g, %s %s %g %s", held.OriginalType, currentPrice, marketOrder.Type, marketOrder.Side, marketOrder.Quantity, marketOrder.Symbol),
+		Data: map[string]interface{}{
+			"side":          marketOrder.Side,
+			"type":          marketOrder.Type,
+			"quantity":      marketOrder.Quantity,
+			"price":         marketOrder.Price,
+			"trigger_price": currentPrice,
+			"stop_price":    held.StopPrice,
+		},
+	})
+}
+
+// recordCancel journals a cancel request and its result, order is nil when it was not found
+func (o *OrderManagerActor) recordCancel(ctx *actor.Context, msg CancelOrderMsg, order *EnhancedOrder, err error) {
+	entry := audit.Entry{
+		Type:    audit.TypeOrderCancel,
+		Symbol:  msg.Symbol,
+		OrderID: msg.OrderID,
+		Message: fmt.Sprintf("cancel %s", msg.OrderID),
+		Data:    map[string]interface{}{"cancelled": err == nil},
+	}
+	if order != nil {
+		entry.Strategy = order.Strategy
+	}
+	if err != nil {
+		entry.Data["error"] = err.Error()
+	}
+	o.record(ctx, entry)
+}
+
+// recordAmend journals a modification with the values before and after it, order is nil when it was not found
+func (o *OrderManagerActor) recordAmend(ctx *actor.Context, msg ModifyOrderMsg, order *EnhancedOrder, before map[string]interface{}, err error) {
+	entry := audit.Entry{
+		Type:    audit.TypeOrderAmend,
+		Symbol:  msg.Symbol,
+		OrderID: msg.OrderID,
+		Message: fmt.Sprintf("amend %s", msg.OrderID),
+		Data:    map[string]interface{}{"amended": err == nil},
+	}
+	if order != nil {
+		entry.Strategy = order.Strategy
+		entry.Data["before"] = before
+		entry.Data["after"] = map[string]interface{}{
+			"quantity":   order.Quantity,
+			"price":      order.Price,
+			"stop_price": order.StopPrice,
+		}
+	}
+	if err != nil {
+		entry.Data["error"] = err.Error()
+	}
+	o.record(ctx, entry)
+}
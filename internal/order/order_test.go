@@ -6,8 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/notifier"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
@@ -380,4 +382,230 @@ func (m *mockExchange) GetPositions(ctx context.Context) ([]*exchanges.Position,
 func (m *mockExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchanges.Kline, error) { return nil, nil }
 func (m *mockExchange) GetOrderBook(ctx context.Context, symbol string, limit int) (*exchanges.OrderBook, error) { return nil, nil }
 func (m *mockExchange) GetTicker(ctx context.Context, symbol string) (*exchanges.Ticker, error) { return nil, nil }
-func (m *mockExchange) GetExchangeInfo(ctx context.Context) (*exchanges.ExchangeInfo, error) { return nil, nil }
\ No newline at end of file
+func (m *mockExchange) GetExchangeInfo(ctx context.Context) (*exchanges.ExchangeInfo, error) { return nil, nil }
+
+func TestFilledOrdersAreRecorded(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+
+	manager := New("bybit", &config.Config{}, db, zerolog.Nop())
+	manager.priceCache["BTCUSDT"] = 50000.0
+
+	filledAt := time.Now()
+	order := &EnhancedOrder{
+		Order: &exchanges.Order{
+			ID:       "order-1",
+			Symbol:   "BTCUSDT",
+			Side:     "buy",
+			Type:     OrderTypeMarket,
+			Quantity: 0.1,
+			Status:   StatusOpen,
+		},
+		Strategy:  "simple_sma",
+		UpdatedAt: filledAt,
+	}
+
+	// Open orders are not fills
+	manager.onOrderUpdate(nil, OrderUpdateMsg{Order: order})
+
+	order.Status = StatusFilled
+	manager.onOrderUpdate(nil, OrderUpdateMsg{Order: order})
+	manager.onOrderUpdate(nil, OrderUpdateMsg{Order: order})
+
+	fills, err := db.GetFills("bybit", filledAt.Add(-time.Minute), filledAt.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to get fills: %v", err)
+	}
+	if len(fills) != 1 {
+		t.Fatalf("expected 1 fill, got %d", len(fills))
+	}
+	if fills[0].Strategy != "simple_sma" || fills[0].Price != 50000.0 {
+		t.Errorf("expected fill attributed to simple_sma at the last price, got %+v", fills[0])
+	}
+	if fills[0].BaseCurrency != "USDT" || fills[0].QuoteRate != 1.0 {
+		t.Errorf("expected fill valued in USDT, got %+v", fills[0])
+	}
+}
+
+// pollExchange reports the execution of a filled market order
+type pollExchange struct {
+	mockExchange
+	polled int
+}
+
+func (m *pollExchange) GetOrder(ctx context.Context, symbol, orderID string) (*exchanges.Order, error) {
+	m.polled++
+	return &exchanges.Order{
+		ID:               orderID,
+		Symbol:           symbol,
+		Side:             "buy",
+		Type:             OrderTypeMarket,
+		Quantity:         0.1,
+		Status:           StatusFilled,
+		ExecutedQuantity: 0.09,
+		AveragePrice:     50100,
+		Fee:              0.09,
+		FeeCurrency:      "USDT",
+	}, nil
+}
+
+// PlaceOrder accepts the order without filling it, as Bybit does
+func (m *pollExchange) PlaceOrder(ctx context.Context, order *exchanges.Order) (*exchanges.Order, error) {
+	placed := *order
+	placed.ID = "order-1"
+	placed.Status = "submitted"
+	return &placed, nil
+}
+
+// eventRecorder passes the notification events on the event stream to a channel
+type eventRecorder struct{ events chan notifier.Event }
+
+func (r *eventRecorder) Receive(ctx *actor.Context) {
+	if event, ok := ctx.Message().(notifier.Event); ok {
+		r.events <- event
+	}
+}
+
+func TestPlacedOrderFillNotifies(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	recorder := &eventRecorder{events: make(chan notifier.Event, 1)}
+	recorderPID := engine.Spawn(func() actor.Receiver { return recorder }, "events")
+	engine.Subscribe(recorderPID)
+
+	manager := New("bybit", &config.Config{}, db, zerolog.Nop())
+	manager.exchange = &pollExchange{}
+	pid := engine.Spawn(func() actor.Receiver { return manager }, "order")
+	defer func() { <-engine.Poison(pid).Done() }()
+
+	response, err := engine.Request(pid, PlaceOrderMsg{Symbol: "BTCUSDT", Side: "buy", Type: OrderTypeMarket, Quantity: 0.1, Strategy: "simple_sma"}, 5*time.Second).Result()
+	if err != nil {
+		t.Fatalf("place order request failed: %v", err)
+	}
+	if placed, ok := response.(*EnhancedOrder); !ok || placed.Status != "submitted" {
+		t.Fatalf("expected the order to be working on the exchange, got %v", response)
+	}
+
+	for _, update := range manager.pollOrders() {
+		engine.Send(pid, OrderUpdateMsg{Order: update})
+	}
+
+	select {
+	case event := <-recorder.events:
+		if event.Type != notifier.EventOrderFilled || event.Strategy != "simple_sma" || event.Fields["quantity"] != 0.09 || event.Fields["price"] != 50100.0 {
+			t.Errorf("expected the fill of the executed quantity at the average price, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no order_filled notification")
+	}
+}
+
+func TestPolledOrderFill(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+
+	exchange := &pollExchange{}
+	manager := New("bybit", &config.Config{}, db, zerolog.Nop())
+	manager.exchange = exchange
+	manager.priceCache["BTCUSDT"] = 50000.0
+
+	// As returned by the exchange when the order is placed
+	placedAt := time.Now()
+	manager.orders["order-1"] = &EnhancedOrder{
+		Order:        &exchanges.Order{ID: "order-1", Symbol: "BTCUSDT", Side: "buy", Type: OrderTypeMarket, Quantity: 0.1, Status: "submitted"},
+		OriginalType: OrderTypeMarket,
+		Strategy:     "simple_sma",
+		CreatedAt:    placedAt,
+		UpdatedAt:    placedAt,
+	}
+
+	updates := manager.pollOrders()
+	if len(updates) != 1 || updates[0].Status != StatusFilled {
+		t.Fatalf("expected the fill of the order, got %d updates", len(updates))
+	}
+	for _, update := range updates {
+		manager.onOrderUpdate(nil, OrderUpdateMsg{Order: update})
+	}
+
+	fills, err := db.GetFills("bybit", placedAt.Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil || len(fills) != 1 {
+		t.Fatalf("expected 1 fill, got %d (%v)", len(fills), err)
+	}
+	if fills[0].Quantity != 0.09 || fills[0].Price != 50100 || fills[0].Fee != 0.09 {
+		t.Errorf("expected the executed quantity at the average price, got %+v", fills[0])
+	}
+	if fills[0].Strategy != "simple_sma" {
+		t.Errorf("expected the fill attributed to the strategy of the order, got %q", fills[0].Strategy)
+	}
+
+	orders, err := db.GetFilledOrders(placedAt.Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil || len(orders) != 1 || orders[0].ExchangeOrderID != "order-1" {
+		t.Errorf("expected the filled order to be saved, got %v (%v)", orders, err)
+	}
+
+	// Filled orders are no longer polled
+	if updates := manager.pollOrders(); len(updates) != 0 || exchange.polled != 1 {
+		t.Errorf("expected no more polling, got %d updates after %d polls", len(updates), exchange.polled)
+	}
+}
+
+// feeExchange reports the fee of filled orders
+type feeExchange struct {
+	mockExchange
+	fee         float64
+	feeCurrency string
+}
+
+func (m *feeExchange) GetOrder(ctx context.Context, symbol, orderID string) (*exchanges.Order, error) {
+	return &exchanges.Order{ID: orderID, Symbol: symbol, Status: StatusFilled, Fee: m.fee, FeeCurrency: m.feeCurrency}, nil
+}
+
+func TestFilledOrderFees(t *testing.T) {
+	tests := []struct {
+		name        string
+		fee         float64
+		feeCurrency string
+		expected    float64
+	}{
+		{"quote asset", 5, "USDT", 5},
+		{"base asset at the fill price", 0.0001, "BTC", 5},
+		{"unreported", 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDatabase(t)
+			defer db.Close()
+
+			manager := New("bybit", &config.Config{}, db, zerolog.Nop())
+			manager.exchange = &feeExchange{fee: tt.fee, feeCurrency: tt.feeCurrency}
+
+			filledAt := time.Now()
+			manager.recordFill(&EnhancedOrder{
+				Order: &exchanges.Order{
+					ID:       "order-1",
+					Symbol:   "BTCUSDT",
+					Side:     "buy",
+					Type:     OrderTypeLimit,
+					Quantity: 0.1,
+					Price:    50000,
+					Status:   StatusFilled,
+				},
+				UpdatedAt: filledAt,
+			})
+
+			fills, err := db.GetFills("bybit", filledAt.Add(-time.Minute), filledAt.Add(time.Minute))
+			if err != nil || len(fills) != 1 {
+				t.Fatalf("expected 1 fill, got %d (%v)", len(fills), err)
+			}
+			if fills[0].Fee < tt.expected-1e-9 || fills[0].Fee > tt.expected+1e-9 || fills[0].FeeCurrency != tt.feeCurrency {
+				t.Errorf("expected a fee of %g USDT charged in %q, got %g in %q", tt.expected, tt.feeCurrency, fills[0].Fee, fills[0].FeeCurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Messages sent by the exchange actor on shutdown
+type (
+	// ApplyOrderPolicyMsg applies a shutdown open-order policy of the configuration to the open orders
+	// on the exchange and the stop orders held locally, responding with an OrderPolicyResult
+	ApplyOrderPolicyMsg struct {
+		Policy string // config.OrderPolicyLeave when empty
+	}
+	OrderPolicyResult struct {
+		Cancelled int      `json:"cancelled"`
+		Kept      int      `json:"kept"`    // left open on the exchange
+		Dropped   int      `json:"dropped"` // held locally, lost when the process exits
+		Errors    []string `json:"errors,omitempty"`
+		Warnings  []string `json:"warnings,omitempty"`
+	}
+
+	// FlushOrdersMsg writes every tracked order to the database, responding with the number written or an error
+	FlushOrdersMsg struct{}
+)
+
+// cancelTimeout bounds a single cancel request on shutdown
+const cancelTimeout = 10 * time.Second
+
+// IsProtective reports whether an order only limits the loss of a position, such as a stop or trailing stop
+func IsProtective(orderType string) bool {
+	switch orderType {
+	case OrderTypeStopMarket, OrderTypeStopLimit, OrderTypeTrailing, "stop", "stop_loss", "take_profit":
+		return true
+	}
+	return false
+}
+
+func (o *OrderManagerActor) onApplyOrderPolicy(ctx *actor.Context, msg ApplyOrderPolicyMsg) {
+	result := o.applyOrderPolicy(ctx, msg.Policy)
+
+	o.logger.Info().
+		Str("policy", msg.Policy).
+		Int("cancelled", result.Cancelled).
+		Int("kept", result.Kept).
+		Int("dropped", result.Dropped).
+		Int("failed", len(result.Errors)).
+		Msg("Open-order policy applied for shutdown")
+
+	if ctx != nil {
+		ctx.Respond(result)
+	}
+}
+
+// applyOrderPolicy leaves or cancels what is open on the exchange, including orders placed before the bot
+// started. Stop and trailing stop orders waiting for a trigger only exist in this process, so they are
+// cancelled by cancel_all and otherwise dropped with a warning, since their positions lose the protection.
+func (o *OrderManagerActor) applyOrderPolicy(ctx *actor.Context, policy string) OrderPolicyResult {
+	var result OrderPolicyResult
+	if policy == "" {
+		policy = config.OrderPolicyLeave
+	}
+
+	if policy != config.OrderPolicyLeave {
+		if o.exchange == nil {
+			result.Errors = append(result.Errors, "not connected, orders on the exchange were left open")
+		} else {
+			o.cancelExchangeOrders(ctx, policy == config.OrderPolicyCancelNonProtective, &result)
+		}
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for _, held := range []map[string]*EnhancedOrder{o.stopOrders, o.trailingStops} {
+		for id, order := range held {
+			order.Status = StatusCancelled
+			order.UpdatedAt = time.Now()
+			delete(held, id)
+			o.persistEnhancedOrder(order)
+			o.recordCancel(ctx, CancelOrderMsg{OrderID: id, Symbol: order.Symbol}, order, nil)
+			if policy == config.OrderPolicyCancelAll {
+				result.Cancelled++
+				continue
+			}
+
+			result.Dropped++
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s %s %s of %g at %g is held by the bot and was dropped, the position is unprotected",
+				order.Symbol, order.OriginalType, order.Side, order.Quantity, order.StopPrice))
+			o.logger.Warn().
+				Str("order_id", id).
+				Str("symbol", order.Symbol).
+				Str("type", order.OriginalType).
+				Float64("quantity", order.Quantity).
+				Float64("stop_price", order.StopPrice).
+				Msg("Stop order held by the bot dropped on shutdown, the position is unprotected")
+		}
+	}
+
+	return result
+}
+
+// cancelExchangeOrders cancels the open orders listed by the exchange, keeping protective ones if asked
+func (o *OrderManagerActor) cancelExchangeOrders(ctx *actor.Context, keepProtective bool, result *OrderPolicyResult) {
+	listCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	open, err := o.exchange.GetOpenOrders(listCtx, "")
+	cancel()
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("list open orders: %v", err))
+	}
+
+	for _, exchangeOrder := range open {
+		o.mutex.RLock()
+		tracked := o.orders[exchangeOrder.ID]
+		o.mutex.RUnlock()
+
+		orderType := exchangeOrder.Type
+		if tracked != nil && tracked.OriginalType != "" {
+			orderType = tracked.OriginalType
+		}
+		if keepProtective && IsProtective(orderType) {
+			result.Kept++
+			continue
+		}
+
+		cancelCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+		err := o.exchange.CancelOrder(cancelCtx, exchangeOrder.Symbol, exchangeOrder.ID)
+		cancel()
+		o.recordCancel(ctx, CancelOrderMsg{OrderID: exchangeOrder.ID, Symbol: exchangeOrder.Symbol}, tracked, err)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("cancel %s: %v", exchangeOrder.ID, err))
+			continue
+		}
+		result.Cancelled++
+
+		if tracked != nil {
+			o.mutex.Lock()
+			tracked.Status = StatusCancelled
+			tracked.UpdatedAt = time.Now()
+			o.mutex.Unlock()
+			o.persistEnhancedOrder(tracked)
+		}
+	}
+}
+
+func (o *OrderManagerActor) onFlushOrders(ctx *actor.Context) {
+	written, err := o.flushOrders()
+	if err != nil {
+		o.logger.Error().Err(err).Int("written", written).Msg("Failed to flush orders")
+		ctx.Respond(err)
+		return
+	}
+
+	o.logger.Info().Int("written", written).Msg("Orders flushed")
+	ctx.Respond(written)
+}
+
+// flushOrders saves the tracked orders, stop and trailing stop orders waiting for a trigger included,
+// so their last status survives a restart
+func (o *OrderManagerActor) flushOrders() (int, error) {
+	if o.db == nil {
+		return 0, nil
+	}
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	written := 0
+	for _, tracked := range []map[string]*EnhancedOrder{o.orders, o.stopOrders, o.trailingStops} {
+		for _, order := range tracked {
+			if order.Order == nil || order.ID == "" {
+				continue
+			}
+			if err := o.saveOrder(order); err != nil {
+				return written, fmt.Errorf("save order %s: %w", order.ID, err)
+			}
+			written++
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,134 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// openOrdersExchange lists open orders and records the ones cancelled
+type openOrdersExchange struct {
+	mockExchange
+	open      []*exchanges.Order
+	cancelled []string
+}
+
+func (m *openOrdersExchange) GetOpenOrders(ctx context.Context, symbol string) ([]*exchanges.Order, error) {
+	return m.open, nil
+}
+
+func (m *openOrdersExchange) CancelOrder(ctx context.Context, symbol, orderID string) error {
+	m.cancelled = append(m.cancelled, orderID)
+	return nil
+}
+
+func shutdownManager(t *testing.T) (*OrderManagerActor, *openOrdersExchange) {
+	db := setupTestDatabase(t)
+	t.Cleanup(func() { db.Close() })
+
+	exchange := &openOrdersExchange{open: []*exchanges.Order{
+		{ID: "entry", Symbol: "BTCUSDT", Side: "buy", Type: OrderTypeLimit, Quantity: 0.1, Price: 50000, Status: StatusOpen},
+		{ID: "stop", Symbol: "BTCUSDT", Side: "sell", Type: OrderTypeStopMarket, Quantity: 0.1, Price: 45000, Status: StatusOpen},
+		{ID: "manual", Symbol: "ETHUSDT", Side: "sell", Type: OrderTypeLimit, Quantity: 1, Price: 4000, Status: StatusOpen},
+	}}
+	manager := New("bybit", &config.Config{}, db, zerolog.Nop())
+	manager.exchange = exchange
+
+	now := time.Now()
+	manager.orders["entry"] = &EnhancedOrder{Order: exchange.open[0], OriginalType: OrderTypeLimit, CreatedAt: now, UpdatedAt: now}
+	manager.stopOrders["held"] = &EnhancedOrder{
+		Order:        &exchanges.Order{ID: "held", Symbol: "BTCUSDT", Side: "sell", Type: OrderTypeMarket, Quantity: 0.1, Status: StatusPending},
+		OriginalType: OrderTypeStopMarket,
+		StopPrice:    44000,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	return manager, exchange
+}
+
+func TestApplyOrderPolicyKeepsProtective(t *testing.T) {
+	manager, exchange := shutdownManager(t)
+
+	result := manager.applyOrderPolicy(nil, config.OrderPolicyCancelNonProtective)
+	if result.Cancelled != 2 || result.Kept != 1 || result.Dropped != 1 || len(result.Errors) != 0 {
+		t.Errorf("expected 2 entry orders cancelled, 1 stop kept and 1 held stop dropped, got %+v", result)
+	}
+	if len(exchange.cancelled) != 2 || exchange.cancelled[0] != "entry" || exchange.cancelled[1] != "manual" {
+		t.Errorf("expected the limit orders to be cancelled on the exchange, got %v", exchange.cancelled)
+	}
+	if manager.orders["entry"].Status != StatusCancelled {
+		t.Errorf("expected the tracked order to be marked cancelled, got %s", manager.orders["entry"].Status)
+	}
+	if len(manager.stopOrders) != 0 || len(result.Warnings) != 1 {
+		t.Errorf("expected the held stop order dropped with a warning, got %d held and warnings %v", len(manager.stopOrders), result.Warnings)
+	}
+}
+
+func TestApplyOrderPolicyLeave(t *testing.T) {
+	manager, exchange := shutdownManager(t)
+
+	result := manager.applyOrderPolicy(nil, config.OrderPolicyLeave)
+	if result.Cancelled != 0 || result.Dropped != 1 || len(result.Warnings) != 1 || len(result.Errors) != 0 {
+		t.Errorf("expected only the held stop order dropped with a warning, got %+v", result)
+	}
+	if len(exchange.cancelled) != 0 {
+		t.Errorf("expected no orders cancelled on the exchange, got %v", exchange.cancelled)
+	}
+	if manager.orders["entry"].Status != StatusOpen {
+		t.Errorf("expected the tracked order to stay open, got %s", manager.orders["entry"].Status)
+	}
+}
+
+func TestApplyOrderPolicyNotConnected(t *testing.T) {
+	manager, _ := shutdownManager(t)
+	manager.exchange = nil
+
+	result := manager.applyOrderPolicy(nil, config.OrderPolicyCancelAll)
+	if len(result.Errors) != 1 || result.Cancelled != 1 {
+		t.Errorf("expected an error for the orders left on the exchange and the held stop cancelled, got %+v", result)
+	}
+}
+
+func TestApplyOrderPolicyCancelAll(t *testing.T) {
+	manager, exchange := shutdownManager(t)
+
+	result := manager.applyOrderPolicy(nil, config.OrderPolicyCancelAll)
+	if result.Cancelled != 4 || result.Kept != 0 || result.Dropped != 0 || len(result.Warnings) != 0 {
+		t.Errorf("expected every order cancelled, got %+v", result)
+	}
+	if len(exchange.cancelled) != 3 {
+		t.Errorf("expected 3 orders cancelled on the exchange, got %v", exchange.cancelled)
+	}
+	if len(manager.stopOrders) != 0 {
+		t.Errorf("expected no stop orders left, got %d", len(manager.stopOrders))
+	}
+}
+
+func TestFlushOrders(t *testing.T) {
+	manager, _ := shutdownManager(t)
+
+	written, err := manager.flushOrders()
+	if err != nil {
+		t.Fatalf("failed to flush orders: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("expected the tracked order and the held stop order to be written, got %d", written)
+	}
+
+	open, err := manager.db.GetAllOpenOrders()
+	if err != nil {
+		t.Fatalf("failed to get open orders: %v", err)
+	}
+	types := make(map[string]string)
+	for _, order := range open {
+		types[order.ExchangeOrderID] = order.Type
+	}
+	if len(open) != 2 || types["entry"] != OrderTypeLimit || types["held"] != OrderTypeStopMarket {
+		t.Errorf("expected both orders stored with their original type, got %v", types)
+	}
+}
@@ -0,0 +1,521 @@
+package portfolio
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+const (
+	// performanceWindow is the period covered by the metrics in PerformanceResponse
+	performanceWindow = 30 * 24 * time.Hour
+
+	// manualStrategy groups fills of orders that were not placed by a strategy
+	manualStrategy = "manual"
+
+	// crypto markets trade every day of the year
+	year = 365 * 24 * time.Hour
+
+	// minAnnualizedSpan is the shortest period whose return is annualized; compounding a few hours
+	// or days over a whole year gives meaningless, possibly infinite, numbers
+	minAnnualizedSpan = 30 * 24 * time.Hour
+
+	// dust is the quantity below which a lot counts as closed, absorbing float rounding
+	dust = 1e-12
+)
+
+// RoundTrip is a quantity opened and closed by fills on the same exchange, symbol and strategy, matched first in first out
+type RoundTrip struct {
+	Exchange   string    `json:"exchange"`
+	Symbol     string    `json:"symbol"`
+	Strategy   string    `json:"strategy"`
+	Side       string    `json:"side"` // "long" or "short"
+	Quantity   float64   `json:"quantity"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	PnL        float64   `json:"pnl"`    // after fees
+	Return     float64   `json:"return"` // PnL relative to the entry notional
+	Opened     time.Time `json:"opened"`
+	Closed     time.Time `json:"closed"`
+	Open       bool      `json:"open"` // not closed by any later fill
+}
+
+// Metrics are the performance statistics of a portfolio, exchange, symbol or strategy.
+// Ratios that are undefined, such as a profit factor without losing trades, are 0.
+type Metrics struct {
+	// From the equity curve, with deposits and withdrawals taken out
+	TotalReturn         float64 `json:"total_return"`          // time-weighted
+	MoneyWeightedReturn float64 `json:"money_weighted_return"` // Modified Dietz, weighs cash flows by how long they were invested
+	AnnualizedReturn    float64 `json:"annualized_return"`     // 0 for periods shorter than 30 days
+	Volatility          float64 `json:"volatility"`            // annualized standard deviation of returns
+	SharpeRatio         float64 `json:"sharpe_ratio"`
+	SortinoRatio        float64 `json:"sortino_ratio"`
+	CalmarRatio         float64 `json:"calmar_ratio"`
+	MaxDrawdown         float64 `json:"max_drawdown"`
+	MaxDrawdownDays     float64 `json:"max_drawdown_days"` // longest time from a peak until it was recovered
+	NetCashFlows        float64 `json:"net_cash_flows"`    // deposits minus withdrawals in the period
+
+	// From attributed fills
+	Trades       int     `json:"trades"`
+	WinRate      float64 `json:"win_rate"`
+	ProfitFactor float64 `json:"profit_factor"`
+	AverageWin   float64 `json:"average_win"`
+	AverageLoss  float64 `json:"average_loss"` // negative
+	RealizedPnL  float64 `json:"realized_pnl"`
+	Exposure     float64 `json:"exposure"` // share of the period with an open position
+}
+
+// PerformanceReport holds metrics for the whole account and broken down by exchange, symbol and strategy.
+// Account and exchange returns come from portfolio snapshots; symbol and strategy returns compound the
+// returns of their round trips, since no capital is allocated to them.
+type PerformanceReport struct {
+	BaseCurrency string             `json:"base_currency"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Resolution   string             `json:"resolution,omitempty"`
+	Total        Metrics            `json:"total"`
+	Exchanges    map[string]Metrics `json:"exchanges"`
+	Symbols      map[string]Metrics `json:"symbols"`
+	Strategies   map[string]Metrics `json:"strategies"`
+}
+
+// BuildPerformanceReport computes a performance report from snapshots, fills and cash flows between from and to.
+// Fills should reach back to the start of the history, so sells in the period close the buys made before it;
+// only round trips closed in the period, or still open, are counted. Equity returns are sampled once per resolution bucket.
+func BuildPerformanceReport(snapshots []*database.PortfolioSnapshot, fills []*database.Fill, flows []*database.CashFlow, from, to time.Time, resolution time.Duration) PerformanceReport {
+	report := PerformanceReport{
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Exchanges:  make(map[string]Metrics),
+		Symbols:    make(map[string]Metrics),
+		Strategies: make(map[string]Metrics),
+	}
+	if len(snapshots) > 0 {
+		report.BaseCurrency = snapshots[len(snapshots)-1].BaseCurrency
+	}
+
+	trips := TripsSince(MatchFills(fills), from)
+	report.Total = ComputeMetrics(EquityCurve(snapshots, flows, resolution), trips, from, to)
+
+	snapshotsByExchange := make(map[string][]*database.PortfolioSnapshot)
+	for _, snapshot := range snapshots {
+		snapshotsByExchange[snapshot.Exchange] = append(snapshotsByExchange[snapshot.Exchange], snapshot)
+	}
+	flowsByExchange := make(map[string][]*database.CashFlow)
+	for _, flow := range flows {
+		flowsByExchange[flow.Exchange] = append(flowsByExchange[flow.Exchange], flow)
+	}
+	tripsByExchange := groupTrips(trips, func(trip RoundTrip) string { return trip.Exchange })
+	for exchange := range tripsByExchange {
+		if _, exists := snapshotsByExchange[exchange]; !exists {
+			snapshotsByExchange[exchange] = nil
+		}
+	}
+	for exchange, exchangeSnapshots := range snapshotsByExchange {
+		curve := EquityCurve(exchangeSnapshots, flowsByExchange[exchange], resolution)
+		report.Exchanges[exchange] = ComputeMetrics(curve, tripsByExchange[exchange], from, to)
+	}
+
+	for symbol, symbolTrips := range groupTrips(trips, func(trip RoundTrip) string { return trip.Symbol }) {
+		report.Symbols[symbol] = ComputeMetrics(tripCurve(symbolTrips), symbolTrips, from, to)
+	}
+	for strategy, strategyTrips := range groupTrips(trips, func(trip RoundTrip) string { return trip.Strategy }) {
+		report.Strategies[strategy] = ComputeMetrics(tripCurve(strategyTrips), strategyTrips, from, to)
+	}
+
+	return report
+}
+
+// ComputeMetrics combines return statistics of an equity curve with trade statistics of round trips
+func ComputeMetrics(curve []EquityPoint, trips []RoundTrip, from, to time.Time) Metrics {
+	var metrics Metrics
+	applyReturns(&metrics, curve)
+	applyTrades(&metrics, trips, from, to)
+	return metrics
+}
+
+// lot is the open remainder of a fill waiting to be closed
+type lot struct {
+	quantity float64
+	price    float64
+	fee      float64 // per unit
+	opened   time.Time
+}
+
+// book holds the open lots of one exchange, symbol and strategy. All lots share a direction.
+type book struct {
+	long bool
+	lots []lot
+}
+
+// MatchFills pairs fills, oldest first, into round trips. A fill against the open direction closes lots
+// first in first out; any remainder opens a position the other way. Lots still open are returned with Open set.
+func MatchFills(fills []*database.Fill) []RoundTrip {
+	type key struct{ exchange, symbol, strategy string }
+
+	books := make(map[key]*book)
+	var trips []RoundTrip
+
+	for _, fill := range fills {
+		if fill.Quantity <= 0 || fill.Price <= 0 {
+			continue
+		}
+
+		strategy := fill.Strategy
+		if strategy == "" {
+			strategy = manualStrategy
+		}
+		k := key{fill.Exchange, fill.Symbol, strategy}
+		b, exists := books[k]
+		if !exists {
+			b = &book{}
+			books[k] = b
+		}
+
+		buy := fill.Side == "buy"
+		fee := fill.Fee / fill.Quantity
+		remaining := fill.Quantity
+
+		// Close open lots in the opposite direction
+		for len(b.lots) > 0 && b.long != buy && remaining > dust {
+			open := &b.lots[0]
+			matched := math.Min(open.quantity, remaining)
+
+			trip := RoundTrip{
+				Exchange:   fill.Exchange,
+				Symbol:     fill.Symbol,
+				Strategy:   strategy,
+				Side:       "long",
+				Quantity:   matched,
+				EntryPrice: open.price,
+				ExitPrice:  fill.Price,
+				Opened:     open.opened,
+				Closed:     fill.CreatedAt,
+			}
+			direction := 1.0
+			if !b.long {
+				trip.Side = "short"
+				direction = -1.0
+			}
+			trip.PnL = (fill.Price-open.price)*matched*direction - (open.fee+fee)*matched
+			trip.Return = trip.PnL / (open.price * matched)
+			trips = append(trips, trip)
+
+			open.quantity -= matched
+			remaining -= matched
+			if open.quantity <= dust {
+				b.lots = b.lots[1:]
+			}
+		}
+
+		if remaining > dust {
+			if len(b.lots) == 0 {
+				b.long = buy
+			}
+			b.lots = append(b.lots, lot{quantity: remaining, price: fill.Price, fee: fee, opened: fill.CreatedAt})
+		}
+	}
+
+	// Report open lots in a stable order
+	keys := make([]key, 0, len(books))
+	for k := range books {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].exchange != keys[j].exchange {
+			return keys[i].exchange < keys[j].exchange
+		}
+		if keys[i].symbol != keys[j].symbol {
+			return keys[i].symbol < keys[j].symbol
+		}
+		return keys[i].strategy < keys[j].strategy
+	})
+
+	for _, k := range keys {
+		b := books[k]
+		side := "long"
+		if !b.long {
+			side = "short"
+		}
+		for _, open := range b.lots {
+			trips = append(trips, RoundTrip{
+				Exchange:   k.exchange,
+				Symbol:     k.symbol,
+				Strategy:   k.strategy,
+				Side:       side,
+				Quantity:   open.quantity,
+				EntryPrice: open.price,
+				Opened:     open.opened,
+				Open:       true,
+			})
+		}
+	}
+
+	return trips
+}
+
+// TripsSince keeps the round trips closed at or after from and those still open
+func TripsSince(trips []RoundTrip, from time.Time) []RoundTrip {
+	kept := make([]RoundTrip, 0, len(trips))
+	for _, trip := range trips {
+		if trip.Open || !trip.Closed.Before(from) {
+			kept = append(kept, trip)
+		}
+	}
+	return kept
+}
+
+// applyReturns fills in the return statistics of an equity curve. Cash flows in a bucket are taken out of
+// its return, so drawdowns are measured on the time-weighted index rather than on the account value.
+// Returns are annualized using the number of samples per year implied by the span of the curve,
+// and the risk-free rate is taken as zero.
+func applyReturns(metrics *Metrics, curve []EquityPoint) {
+	if len(curve) < 2 {
+		return
+	}
+
+	returns := make([]float64, 0, len(curve)-1)
+	growth := 1.0
+	index := []EquityPoint{{Timestamp: curve[0].Timestamp, TotalValue: growth}}
+	for i := 1; i < len(curve); i++ {
+		previous := curve[i-1].TotalValue
+		if previous <= 0 {
+			continue
+		}
+		flow := curve[i].NetFlows - curve[i-1].NetFlows
+		r := (curve[i].TotalValue-flow)/previous - 1
+		returns = append(returns, r)
+		growth *= 1 + r
+		index = append(index, EquityPoint{Timestamp: curve[i].Timestamp, TotalValue: growth})
+	}
+	if len(returns) == 0 {
+		return
+	}
+
+	metrics.TotalReturn = growth - 1
+	metrics.MoneyWeightedReturn = moneyWeightedReturn(curve)
+	metrics.NetCashFlows = curve[len(curve)-1].NetFlows - curve[0].NetFlows
+	metrics.MaxDrawdown, metrics.MaxDrawdownDays = drawdown(index)
+
+	span := curve[len(curve)-1].Timestamp.Sub(curve[0].Timestamp)
+	if span <= 0 {
+		return
+	}
+	years := span.Hours() / year.Hours()
+	if growth > 0 && span >= minAnnualizedSpan {
+		metrics.AnnualizedReturn = math.Pow(growth, 1/years) - 1
+	}
+	if metrics.MaxDrawdown > 0 {
+		metrics.CalmarRatio = metrics.AnnualizedReturn / metrics.MaxDrawdown
+	}
+
+	if len(returns) < 2 {
+		return
+	}
+	annualize := math.Sqrt(float64(len(returns)) / years)
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	deviation := math.Sqrt(variance / float64(len(returns)-1))
+	downsideDeviation := math.Sqrt(downside / float64(len(returns)))
+
+	metrics.Volatility = deviation * annualize
+	if deviation > 0 {
+		metrics.SharpeRatio = mean / deviation * annualize
+	}
+	if downsideDeviation > 0 {
+		metrics.SortinoRatio = mean / downsideDeviation * annualize
+	}
+}
+
+// moneyWeightedReturn approximates the internal rate of return of the curve with the Modified Dietz method.
+// Each cash flow is weighted by the share of the period it was invested, so money added late counts less.
+func moneyWeightedReturn(curve []EquityPoint) float64 {
+	first, last := curve[0], curve[len(curve)-1]
+	period := last.Timestamp.Sub(first.Timestamp)
+	if period <= 0 {
+		return 0
+	}
+
+	invested := first.TotalValue
+	for i := 1; i < len(curve); i++ {
+		flow := curve[i].NetFlows - curve[i-1].NetFlows
+		weight := last.Timestamp.Sub(curve[i].Timestamp).Seconds() / period.Seconds()
+		invested += weight * flow
+	}
+	if invested <= 0 {
+		return 0
+	}
+
+	netFlows := last.NetFlows - first.NetFlows
+	return (last.TotalValue - first.TotalValue - netFlows) / invested
+}
+
+// drawdown returns the deepest fall from a peak and the longest time in days spent below a peak
+func drawdown(curve []EquityPoint) (float64, float64) {
+	maxDrawdown := 0.0
+	var longest time.Duration
+
+	peak := curve[0].TotalValue
+	peakTime := curve[0].Timestamp
+	underwater := false
+
+	for _, point := range curve[1:] {
+		if point.TotalValue >= peak {
+			// A drawdown lasts until the previous peak is reached again
+			if underwater && point.Timestamp.Sub(peakTime) > longest {
+				longest = point.Timestamp.Sub(peakTime)
+			}
+			underwater = false
+			peak = point.TotalValue
+			peakTime = point.Timestamp
+			continue
+		}
+
+		underwater = true
+		if peak > 0 {
+			maxDrawdown = math.Max(maxDrawdown, (peak-point.TotalValue)/peak)
+		}
+	}
+
+	// Still below the peak at the end of the curve
+	if last := curve[len(curve)-1]; underwater && last.Timestamp.Sub(peakTime) > longest {
+		longest = last.Timestamp.Sub(peakTime)
+	}
+
+	return maxDrawdown, longest.Hours() / 24
+}
+
+// applyTrades fills in trade statistics from closed round trips and exposure from all round trips
+func applyTrades(metrics *Metrics, trips []RoundTrip, from, to time.Time) {
+	wins, losses := 0, 0
+	grossProfit, grossLoss := 0.0, 0.0
+
+	for _, trip := range trips {
+		if trip.Open {
+			continue
+		}
+		metrics.Trades++
+		metrics.RealizedPnL += trip.PnL
+		if trip.PnL > 0 {
+			wins++
+			grossProfit += trip.PnL
+		} else if trip.PnL < 0 {
+			losses++
+			grossLoss -= trip.PnL
+		}
+	}
+
+	if metrics.Trades > 0 {
+		metrics.WinRate = float64(wins) / float64(metrics.Trades)
+	}
+	if grossLoss > 0 {
+		metrics.ProfitFactor = grossProfit / grossLoss
+	}
+	if wins > 0 {
+		metrics.AverageWin = grossProfit / float64(wins)
+	}
+	if losses > 0 {
+		metrics.AverageLoss = -grossLoss / float64(losses)
+	}
+
+	metrics.Exposure = exposure(trips, from, to)
+}
+
+// exposure returns the share of the period between from and to covered by at least one round trip
+func exposure(trips []RoundTrip, from, to time.Time) float64 {
+	period := to.Sub(from)
+	if period <= 0 || len(trips) == 0 {
+		return 0
+	}
+
+	type interval struct{ start, end time.Time }
+	intervals := make([]interval, 0, len(trips))
+	for _, trip := range trips {
+		start, end := trip.Opened, trip.Closed
+		if trip.Open {
+			end = to
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			intervals = append(intervals, interval{start, end})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+
+	var covered time.Duration
+	var current interval
+	for i, next := range intervals {
+		if i > 0 && !next.start.After(current.end) {
+			if next.end.After(current.end) {
+				current.end = next.end
+			}
+			continue
+		}
+		if i > 0 {
+			covered += current.end.Sub(current.start)
+		}
+		current = next
+	}
+	if len(intervals) > 0 {
+		covered += current.end.Sub(current.start)
+	}
+
+	return covered.Seconds() / period.Seconds()
+}
+
+// tripCurve compounds the returns of closed round trips into an equity curve starting at 1
+func tripCurve(trips []RoundTrip) []EquityPoint {
+	closed := make([]RoundTrip, 0, len(trips))
+	for _, trip := range trips {
+		if !trip.Open {
+			closed = append(closed, trip)
+		}
+	}
+	if len(closed) == 0 {
+		return nil
+	}
+	sort.SliceStable(closed, func(i, j int) bool { return closed[i].Closed.Before(closed[j].Closed) })
+
+	start := closed[0].Opened
+	for _, trip := range closed {
+		if trip.Opened.Before(start) {
+			start = trip.Opened
+		}
+	}
+
+	value := 1.0
+	curve := []EquityPoint{{Timestamp: start, TotalValue: value}}
+	for _, trip := range closed {
+		value *= 1 + trip.Return
+		curve = append(curve, EquityPoint{Timestamp: trip.Closed, TotalValue: value})
+	}
+	return curve
+}
+
+// groupTrips splits round trips by key
+func groupTrips(trips []RoundTrip, key func(RoundTrip) string) map[string][]RoundTrip {
+	groups := make(map[string][]RoundTrip)
+	for _, trip := range trips {
+		groups[key(trip)] = append(groups[key(trip)], trip)
+	}
+	return groups
+}
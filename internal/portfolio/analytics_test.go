@@ -0,0 +1,243 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMatchFills(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	fills := []*database.Fill{
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: at(0)},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 110, Strategy: "sma", CreatedAt: at(1)},
+		// Closes the first lot and half of the second
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "sell", Quantity: 1.5, Price: 120, Fee: 1.5, Strategy: "sma", CreatedAt: at(2)},
+		// Manual fills are kept apart from the strategy
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 120, CreatedAt: at(3)},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 130, CreatedAt: at(4)},
+	}
+
+	trips := MatchFills(fills)
+	if len(trips) != 4 {
+		t.Fatalf("expected 4 round trips, got %d: %+v", len(trips), trips)
+	}
+
+	first := trips[0]
+	if first.Side != "long" || first.Quantity != 1 || !almostEqual(first.PnL, 20-1) || !almostEqual(first.Return, 0.19) {
+		t.Errorf("unexpected first round trip %+v", first)
+	}
+	second := trips[1]
+	if second.Quantity != 0.5 || !almostEqual(second.PnL, 5-0.5) || !second.Opened.Equal(at(1)) {
+		t.Errorf("unexpected second round trip %+v", second)
+	}
+
+	short := trips[2]
+	if short.Strategy != manualStrategy || short.Side != "short" || !almostEqual(short.PnL, -10) {
+		t.Errorf("expected a losing manual short, got %+v", short)
+	}
+
+	open := trips[3]
+	if !open.Open || open.Strategy != "sma" || open.Quantity != 0.5 || open.EntryPrice != 110 {
+		t.Errorf("expected the rest of the second lot to stay open, got %+v", open)
+	}
+}
+
+func TestComputeMetricsReturns(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(days int) time.Time { return start.AddDate(0, 0, days*10) }
+
+	curve := []EquityPoint{
+		{Timestamp: day(0), TotalValue: 100},
+		{Timestamp: day(1), TotalValue: 110},
+		{Timestamp: day(2), TotalValue: 99},
+		{Timestamp: day(3), TotalValue: 105},
+		{Timestamp: day(4), TotalValue: 121},
+	}
+
+	metrics := ComputeMetrics(curve, nil, day(0), day(4))
+
+	if !almostEqual(metrics.TotalReturn, 0.21) {
+		t.Errorf("expected total return 0.21, got %f", metrics.TotalReturn)
+	}
+	if !almostEqual(metrics.MaxDrawdown, 0.1) {
+		t.Errorf("expected max drawdown 0.1, got %f", metrics.MaxDrawdown)
+	}
+	if !almostEqual(metrics.MaxDrawdownDays, 30) {
+		t.Errorf("expected drawdown to last 30 days until 110 was exceeded, got %f", metrics.MaxDrawdownDays)
+	}
+	if metrics.Volatility <= 0 || metrics.SharpeRatio <= 0 || metrics.SortinoRatio <= metrics.SharpeRatio {
+		t.Errorf("expected positive volatility and Sortino above Sharpe, got %+v", metrics)
+	}
+	if metrics.AnnualizedReturn <= metrics.TotalReturn || !almostEqual(metrics.CalmarRatio, metrics.AnnualizedReturn/metrics.MaxDrawdown) {
+		t.Errorf("expected Calmar to be annualized return over max drawdown, got %f / %f", metrics.AnnualizedReturn, metrics.CalmarRatio)
+	}
+
+	// A short period is not annualized
+	if short := ComputeMetrics(curve[:2], nil, day(0), day(1)); short.AnnualizedReturn != 0 || short.CalmarRatio != 0 {
+		t.Errorf("expected no annualized return over 10 days, got %+v", short)
+	}
+
+	// Too few points for returns
+	if flat := ComputeMetrics(curve[:1], nil, day(0), day(1)); flat.TotalReturn != 0 || flat.SharpeRatio != 0 {
+		t.Errorf("expected empty return metrics for a single point, got %+v", flat)
+	}
+}
+
+func TestComputeMetricsCashFlows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(days int) time.Time { return start.AddDate(0, 0, days*10) }
+
+	// A deposit of 1000 and a later withdrawal of 500 move the value without being gains or losses
+	curve := []EquityPoint{
+		{Timestamp: day(0), TotalValue: 1000},
+		{Timestamp: day(1), TotalValue: 2200, NetFlows: 1000},
+		{Timestamp: day(2), TotalValue: 1700, NetFlows: 500},
+		{Timestamp: day(3), TotalValue: 1870, NetFlows: 500},
+	}
+
+	metrics := ComputeMetrics(curve, nil, day(0), day(3))
+
+	// 1.2 * 1.0 * 1.1
+	if !almostEqual(metrics.TotalReturn, 0.32) {
+		t.Errorf("expected time-weighted return 0.32, got %f", metrics.TotalReturn)
+	}
+	if metrics.MaxDrawdown != 0 {
+		t.Errorf("expected the withdrawal not to count as a drawdown, got %f", metrics.MaxDrawdown)
+	}
+	if metrics.NetCashFlows != 500 {
+		t.Errorf("expected net cash flows of 500, got %f", metrics.NetCashFlows)
+	}
+
+	// (1870 - 1000 - 500) / (1000 + 2/3 * 1000 - 1/3 * 500)
+	if !almostEqual(metrics.MoneyWeightedReturn, 370.0/1500.0) {
+		t.Errorf("expected money-weighted return of 370/1500, got %f", metrics.MoneyWeightedReturn)
+	}
+}
+
+func TestComputeMetricsTrades(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	trips := []RoundTrip{
+		{PnL: 30, Opened: at(0), Closed: at(2)},
+		{PnL: 10, Opened: at(1), Closed: at(3)},
+		{PnL: -20, Opened: at(5), Closed: at(6)},
+		{Open: true, Opened: at(8)},
+	}
+
+	metrics := ComputeMetrics(nil, trips, start, at(10))
+
+	if metrics.Trades != 3 || !almostEqual(metrics.WinRate, 2.0/3) {
+		t.Errorf("expected 3 trades with 2 winners, got %d (%f)", metrics.Trades, metrics.WinRate)
+	}
+	if !almostEqual(metrics.ProfitFactor, 2) || !almostEqual(metrics.AverageWin, 20) || !almostEqual(metrics.AverageLoss, -20) {
+		t.Errorf("unexpected profit statistics %+v", metrics)
+	}
+	if !almostEqual(metrics.RealizedPnL, 20) {
+		t.Errorf("expected realized PnL 20, got %f", metrics.RealizedPnL)
+	}
+	// Hours 0-3, 5-6 and 8-10 are covered: 6 of 10 hours
+	if !almostEqual(metrics.Exposure, 0.6) {
+		t.Errorf("expected exposure 0.6, got %f", metrics.Exposure)
+	}
+}
+
+func TestBuildPerformanceReport(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(days int) time.Time { return start.AddDate(0, 0, days) }
+
+	snapshots := []*database.PortfolioSnapshot{
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1000, CreatedAt: day(0)},
+		{Exchange: "bitvavo", BaseCurrency: "USDT", TotalValue: 1000, CreatedAt: day(0)},
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1100, CreatedAt: day(1)},
+		{Exchange: "bitvavo", BaseCurrency: "USDT", TotalValue: 1100, CreatedAt: day(1)},
+	}
+	fills := []*database.Fill{
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: day(0)},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 110, Strategy: "sma", CreatedAt: day(1)},
+		{Exchange: "bitvavo", Symbol: "ETH-EUR", Side: "buy", Quantity: 1, Price: 100, CreatedAt: day(0)},
+	}
+
+	report := BuildPerformanceReport(snapshots, fills, nil, day(0), day(2), 24*time.Hour)
+
+	if report.BaseCurrency != "USDT" || !almostEqual(report.Total.TotalReturn, 0.1) {
+		t.Errorf("expected a 10%% USDT return, got %s %f", report.BaseCurrency, report.Total.TotalReturn)
+	}
+	if len(report.Exchanges) != 2 || !almostEqual(report.Exchanges["bybit"].TotalReturn, 0.1) {
+		t.Errorf("expected both exchanges in the report, got %+v", report.Exchanges)
+	}
+	if report.Strategies["sma"].Trades != 1 || !almostEqual(report.Strategies["sma"].TotalReturn, 0.1) {
+		t.Errorf("expected one sma round trip returning 10%%, got %+v", report.Strategies["sma"])
+	}
+	if manual := report.Strategies[manualStrategy]; manual.Trades != 0 || !almostEqual(manual.Exposure, 1) {
+		t.Errorf("expected an open manual position over the whole period, got %+v", manual)
+	}
+	if _, exists := report.Symbols["ETH-EUR"]; !exists {
+		t.Errorf("expected ETH-EUR in the symbol breakdown, got %v", report.Symbols)
+	}
+}
+
+func TestCalculateMetrics(t *testing.T) {
+	portfolio, db := setupTestPortfolio(t)
+	defer db.Close()
+
+	now := time.Now()
+	fills := []*database.Fill{
+		{Exchange: "test_exchange", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, CreatedAt: now.Add(-2 * time.Hour)},
+		{Exchange: "test_exchange", OrderID: "2", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 90, CreatedAt: now.Add(-time.Hour)},
+		{Exchange: "other_exchange", OrderID: "3", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 200, CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, fill := range fills {
+		if err := db.SaveFill(fill); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+
+	metrics := portfolio.calculateMetrics()
+	if metrics == nil {
+		t.Fatal("expected metrics with a database")
+	}
+	if metrics.Trades != 1 || !almostEqual(metrics.RealizedPnL, -10) {
+		t.Errorf("expected one losing trade on this exchange, got %+v", metrics)
+	}
+
+	portfolio.db = nil
+	if portfolio.calculateMetrics() != nil {
+		t.Error("expected no metrics without a database")
+	}
+}
+
+func TestBuildPerformanceReportBeforePeriod(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(days int) time.Time { return start.AddDate(0, 0, days) }
+
+	// Bought before the period, sold in it, plus a round trip that closed before the period
+	fills := []*database.Fill{
+		{Exchange: "bybit", Symbol: "ETHUSDT", Side: "buy", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: day(0)},
+		{Exchange: "bybit", Symbol: "ETHUSDT", Side: "sell", Quantity: 1, Price: 80, Strategy: "sma", CreatedAt: day(1)},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, Strategy: "sma", CreatedAt: day(2)},
+		{Exchange: "bybit", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 120, Strategy: "sma", CreatedAt: day(12)},
+	}
+
+	report := BuildPerformanceReport(nil, fills, nil, day(10), day(20), 24*time.Hour)
+
+	sma := report.Strategies["sma"]
+	if sma.Trades != 1 || !almostEqual(sma.RealizedPnL, 20) || !almostEqual(sma.WinRate, 1) {
+		t.Errorf("expected the long closed in the period as the only trade, got %+v", sma)
+	}
+	if !almostEqual(sma.Exposure, 0.2) {
+		t.Errorf("expected exposure 0.2 from the start of the period until the sell, got %f", sma.Exposure)
+	}
+	if _, exists := report.Symbols["ETHUSDT"]; exists {
+		t.Errorf("expected the round trip closed before the period to be left out, got %v", report.Symbols)
+	}
+}
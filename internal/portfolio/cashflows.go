@@ -0,0 +1,148 @@
+package portfolio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// cashFlowSyncInterval controls how often deposits and withdrawals are fetched from the exchange
+const cashFlowSyncInterval = 15 * time.Minute
+
+// Cash flow sources
+const (
+	cashFlowSourceExchange = "exchange"
+	cashFlowSourceManual   = "manual"
+)
+
+// loadNetFlows restores the deposits minus withdrawals recorded in the base currency
+func (p *PortfolioActor) loadNetFlows() {
+	if p.db == nil {
+		return
+	}
+
+	netFlows, err := p.db.GetNetCashFlows(p.exchangeName, p.valuation.BaseCurrency())
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Failed to load net cash flows")
+		return
+	}
+	p.netFlows = netFlows
+}
+
+// onSyncCashFlows asks the exchange for its recent deposits and withdrawals. Transfers that were
+// already recorded are ignored when they come back, so every sync can ask for the full history.
+func (p *PortfolioActor) onSyncCashFlows(ctx *actor.Context) {
+	if p.exchangeActorPID == nil || p.db == nil {
+		return
+	}
+	ctx.Send(p.exchangeActorPID, GetTransfersMsg{})
+}
+
+// onTransfers records deposits and withdrawals reported by the exchange. A transfer is valued at the
+// current rate; one that cannot be priced yet is skipped and picked up again on the next sync.
+func (p *PortfolioActor) onTransfers(msg TransfersMsg) {
+	if p.db == nil {
+		return
+	}
+
+	for _, transfer := range msg.Transfers {
+		amount := transfer.Amount
+		if transfer.Type == exchanges.TransferWithdrawal {
+			// The fee comes out of the account on top of the amount sent
+			amount = -(transfer.Amount + transfer.Fee)
+		}
+
+		value, ok := p.valuation.Convert(transfer.Asset, amount)
+		if !ok {
+			p.logger.Warn().
+				Str("asset", transfer.Asset).
+				Str("transfer_id", transfer.ID).
+				Msg("Transfer cannot be valued yet, retrying on next sync")
+			continue
+		}
+
+		flow := &database.CashFlow{
+			Exchange:     p.exchangeName,
+			ExternalID:   transfer.ID,
+			Asset:        strings.ToUpper(transfer.Asset),
+			Amount:       amount,
+			Value:        value,
+			BaseCurrency: p.valuation.BaseCurrency(),
+			Source:       cashFlowSourceExchange,
+			CreatedAt:    transfer.Timestamp,
+		}
+		if p.saveCashFlow(flow) {
+			p.logger.Info().
+				Str("type", transfer.Type).
+				Str("asset", flow.Asset).
+				Float64("amount", flow.Amount).
+				Float64("value", flow.Value).
+				Msg("Cash flow recorded from exchange")
+		}
+	}
+}
+
+// onRecordCashFlow stores a deposit or withdrawal entered by hand, for exchanges that do not report them
+func (p *PortfolioActor) onRecordCashFlow(ctx *actor.Context, msg RecordCashFlowMsg) {
+	if p.db == nil {
+		ctx.Respond(fmt.Errorf("cash flows require a database"))
+		return
+	}
+	if strings.TrimSpace(msg.Asset) == "" || msg.Amount == 0 {
+		ctx.Respond(fmt.Errorf("asset and a non-zero amount are required"))
+		return
+	}
+
+	value, ok := p.valuation.Convert(msg.Asset, msg.Amount)
+	if !ok {
+		ctx.Respond(fmt.Errorf("no price for %s in %s", msg.Asset, p.valuation.BaseCurrency()))
+		return
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	flow := &database.CashFlow{
+		Exchange:     p.exchangeName,
+		ExternalID:   fmt.Sprintf("manual-%d", time.Now().UnixNano()),
+		Asset:        strings.ToUpper(strings.TrimSpace(msg.Asset)),
+		Amount:       msg.Amount,
+		Value:        value,
+		BaseCurrency: p.valuation.BaseCurrency(),
+		Source:       cashFlowSourceManual,
+		Note:         msg.Note,
+		CreatedAt:    timestamp,
+	}
+	if !p.saveCashFlow(flow) {
+		ctx.Respond(fmt.Errorf("failed to save cash flow"))
+		return
+	}
+
+	p.logger.Info().
+		Str("asset", flow.Asset).
+		Float64("amount", flow.Amount).
+		Float64("value", flow.Value).
+		Msg("Cash flow recorded manually")
+
+	ctx.Respond(flow)
+}
+
+// saveCashFlow stores a cash flow and reports whether it was new
+func (p *PortfolioActor) saveCashFlow(flow *database.CashFlow) bool {
+	inserted, err := p.db.SaveCashFlow(flow)
+	if err != nil {
+		p.logger.Error().Err(err).Str("external_id", flow.ExternalID).Msg("Failed to save cash flow")
+		return false
+	}
+	if inserted {
+		p.netFlows += flow.Value
+	}
+	return inserted
+}
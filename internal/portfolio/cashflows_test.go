@@ -0,0 +1,52 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestTransfersRecordedAsCashFlows(t *testing.T) {
+	portfolio, db := setupTestPortfolio(t)
+	defer db.Close()
+
+	portfolio.valuation.UpdatePrice("BTCUSDT", 50000)
+
+	now := time.Now()
+	msg := TransfersMsg{Transfers: []*exchanges.Transfer{
+		{ID: "deposit:a", Type: exchanges.TransferDeposit, Asset: "USDT", Amount: 1000, Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "withdrawal:b", Type: exchanges.TransferWithdrawal, Asset: "BTC", Amount: 0.01, Fee: 0.0005, Timestamp: now.Add(-time.Hour)},
+		{ID: "deposit:c", Type: exchanges.TransferDeposit, Asset: "DOGE", Amount: 100, Timestamp: now.Add(-time.Hour)},
+	}}
+
+	portfolio.onTransfers(msg)
+	// Transfers are reported again on every sync and must only count once
+	portfolio.onTransfers(msg)
+
+	// 1000 USDT deposited, 0.0105 BTC including the fee withdrawn, DOGE has no price yet
+	if !almostEqual(portfolio.netFlows, 475) {
+		t.Errorf("expected net flows of 475, got %f", portfolio.netFlows)
+	}
+
+	flows, err := db.GetCashFlows("test_exchange", now.Add(-3*time.Hour), now)
+	if err != nil {
+		t.Fatalf("failed to get cash flows: %v", err)
+	}
+	if len(flows) != 2 {
+		t.Fatalf("expected 2 cash flows, got %d", len(flows))
+	}
+	if flows[1].Amount != -0.0105 || flows[1].Source != cashFlowSourceExchange || flows[1].BaseCurrency != "USDT" {
+		t.Errorf("expected a signed withdrawal from the exchange, got %+v", flows[1])
+	}
+
+	// A restart picks up the recorded flows
+	restarted := New("test_exchange", &config.Config{}, db, zerolog.New(nil))
+	restarted.loadNetFlows()
+	if !almostEqual(restarted.netFlows, 475) {
+		t.Errorf("expected net flows of 475 after a restart, got %f", restarted.netFlows)
+	}
+}
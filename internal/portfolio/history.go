@@ -0,0 +1,92 @@
+package portfolio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// snapshotInterval controls how often the valued portfolio is written to portfolio_snapshots
+const snapshotInterval = 5 * time.Minute
+
+// EquityPoint is one bucket of a downsampled equity curve, summed over exchanges
+type EquityPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	TotalValue    float64   `json:"total_value"`
+	Cash          float64   `json:"cash"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	RealizedPnL   float64   `json:"realized_pnl"`
+	NetFlows      float64   `json:"net_flows"` // deposits minus withdrawals up to this bucket, counted from the start of the curve
+}
+
+// ParseResolution parses a bucket size such as "5m", "1h", "1d" or "1w"
+func ParseResolution(resolution string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(resolution, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(resolution, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit > 0 {
+		count, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(resolution, "d"), "w"))
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid resolution %q", resolution)
+		}
+		return time.Duration(count) * unit, nil
+	}
+
+	duration, err := time.ParseDuration(resolution)
+	if err != nil || duration < time.Minute {
+		return 0, fmt.Errorf("invalid resolution %q, use at least 1m", resolution)
+	}
+	return duration, nil
+}
+
+// EquityCurve downsamples snapshots, oldest first, into one point per resolution bucket.
+// Each bucket sums the last snapshot of every exchange; an exchange without a snapshot in a bucket
+// carries its previous values forward so the total does not dip between writes.
+// Cash flows, oldest first, are accumulated into the bucket they fall in; flows before the first bucket count towards it.
+func EquityCurve(snapshots []*database.PortfolioSnapshot, flows []*database.CashFlow, resolution time.Duration) []EquityPoint {
+	points := make([]EquityPoint, 0)
+	if len(snapshots) == 0 || resolution <= 0 {
+		return points
+	}
+
+	latest := make(map[string]*database.PortfolioSnapshot)
+	var bucket time.Time
+	netFlows := 0.0
+	next := 0
+
+	emit := func() {
+		for next < len(flows) && !flows[next].CreatedAt.UTC().Truncate(resolution).After(bucket) {
+			netFlows += flows[next].Value
+			next++
+		}
+
+		point := EquityPoint{Timestamp: bucket, NetFlows: netFlows}
+		for _, snapshot := range latest {
+			point.TotalValue += snapshot.TotalValue
+			point.Cash += snapshot.Cash
+			point.UnrealizedPnL += snapshot.UnrealizedPnL
+			point.RealizedPnL += snapshot.RealizedPnL
+		}
+		points = append(points, point)
+	}
+
+	for i, snapshot := range snapshots {
+		current := snapshot.CreatedAt.UTC().Truncate(resolution)
+		if i > 0 && !current.Equal(bucket) {
+			emit()
+		}
+		bucket = current
+		latest[snapshot.Exchange] = snapshot
+	}
+	emit()
+
+	return points
+}
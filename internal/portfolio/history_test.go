@@ -0,0 +1,88 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+func TestParseResolution(t *testing.T) {
+	tests := []struct {
+		resolution string
+		expected   time.Duration
+		valid      bool
+	}{
+		{"5m", 5 * time.Minute, true},
+		{"1h", time.Hour, true},
+		{"1d", 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"30s", 0, false},
+		{"0d", 0, false},
+		{"xd", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		duration, err := ParseResolution(tt.resolution)
+		if (err == nil) != tt.valid || duration != tt.expected {
+			t.Errorf("ParseResolution(%q) = %v, %v; want %v, valid %t", tt.resolution, duration, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+func TestEquityCurve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	snapshots := []*database.PortfolioSnapshot{
+		{Exchange: "bybit", TotalValue: 1000, Cash: 500, CreatedAt: at(0)},
+		{Exchange: "bitvavo", TotalValue: 2000, Cash: 1000, CreatedAt: at(10)},
+		{Exchange: "bybit", TotalValue: 1100, Cash: 500, CreatedAt: at(50)},
+		// bitvavo has no snapshot in the second hour and is carried forward
+		{Exchange: "bybit", TotalValue: 1200, Cash: 600, RealizedPnL: 50, CreatedAt: at(70)},
+	}
+
+	points := EquityCurve(snapshots, nil, time.Hour)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 hourly points, got %d", len(points))
+	}
+
+	if !points[0].Timestamp.Equal(start) || points[0].TotalValue != 3100 || points[0].Cash != 1500 {
+		t.Errorf("expected first bucket to use the last snapshot per exchange, got %+v", points[0])
+	}
+	if !points[1].Timestamp.Equal(at(60)) || points[1].TotalValue != 3200 || points[1].RealizedPnL != 50 {
+		t.Errorf("expected second bucket to carry bitvavo forward, got %+v", points[1])
+	}
+
+	if points := EquityCurve(nil, nil, time.Hour); len(points) != 0 {
+		t.Errorf("expected no points without snapshots, got %d", len(points))
+	}
+}
+
+func TestEquityCurveCashFlows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	snapshots := []*database.PortfolioSnapshot{
+		{Exchange: "bybit", TotalValue: 1000, CreatedAt: at(0)},
+		{Exchange: "bybit", TotalValue: 1500, CreatedAt: at(70)},
+		{Exchange: "bybit", TotalValue: 1400, CreatedAt: at(130)},
+	}
+	flows := []*database.CashFlow{
+		{Exchange: "bybit", Value: 200, CreatedAt: at(-30)}, // before the curve, counts towards the first point
+		{Exchange: "bybit", Value: 500, CreatedAt: at(65)},
+		{Exchange: "bybit", Value: -100, CreatedAt: at(125)},
+		{Exchange: "bybit", Value: 1000, CreatedAt: at(300)}, // after the curve
+	}
+
+	points := EquityCurve(snapshots, flows, time.Hour)
+	if len(points) != 3 {
+		t.Fatalf("expected 3 hourly points, got %d", len(points))
+	}
+	for i, expected := range []float64{200, 700, 600} {
+		if points[i].NetFlows != expected {
+			t.Errorf("expected net flows %f at point %d, got %f", expected, i, points[i].NetFlows)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package portfolio
+
+import "github.com/arijanluiken/mercantile/pkg/metrics"
+
+var (
+	portfolioValue = metrics.NewGauge("mercantile_portfolio_value",
+		"Total portfolio value in the base currency.", "exchange", "currency")
+	portfolioExposure = metrics.NewGauge("mercantile_portfolio_exposure",
+		"Portfolio value held outside of cash in the base currency.", "exchange", "currency")
+)
@@ -7,8 +7,13 @@ import (
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/internal/rebalance"
+	"github.com/arijanluiken/mercantile/internal/risk"
+	"github.com/arijanluiken/mercantile/internal/valuation"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
 )
 
 // Messages for portfolio actor communication
@@ -45,10 +50,59 @@ type (
 		ExchangeActorPID *actor.PID
 	}
 
+	// SetValuationTargetsMsg sets the actors that receive the portfolio valuation
+	SetValuationTargetsMsg struct {
+		RiskManagerPID *actor.PID
+		RebalancePID   *actor.PID
+	}
+	PublishValuationMsg struct{}
+
+	// SaveSnapshotMsg writes the valued portfolio to portfolio_snapshots
+	SaveSnapshotMsg struct {
+		Reason string
+	}
+
+	// FlushMsg saves a final snapshot on shutdown, responding with "flushed" or an error
+	FlushMsg struct{}
+
+	// Cash flow messages keep deposits and withdrawals out of performance
+	SyncCashFlowsMsg struct{}
+	GetTransfersMsg  struct{}
+	TransfersMsg     struct {
+		Transfers []*exchanges.Transfer
+	}
+
+	// RecordCashFlowMsg enters a deposit (positive amount) or withdrawal (negative amount) by hand.
+	// It is answered with the stored *database.CashFlow or an error.
+	RecordCashFlowMsg struct {
+		Asset     string
+		Amount    float64
+		Note      string
+		Timestamp time.Time
+	}
+
+	// SetAggregatorMsg sets the actor that consolidates portfolios across exchanges
+	SetAggregatorMsg struct {
+		AggregatorPID *actor.PID
+	}
+
+	// SnapshotMsg is published to the aggregator with the valued holdings of this exchange
+	SnapshotMsg struct {
+		Exchange      string
+		Valuation     valuation.Valuation
+		Holdings      []valuation.Holding
+		Positions     []Position
+		UnrealizedPnL float64
+		RealizedPnL   float64
+		DailyPnL      float64
+		Timestamp     time.Time
+	}
+
 	// Portfolio queries
 	GetPositionsMsg   struct{}
 	GetBalancesMsg    struct{}
 	GetPerformanceMsg struct{}
+	GetValuationMsg   struct{}
 	StatusMsg         struct{}
 
 	// Portfolio responses
@@ -61,13 +115,15 @@ type (
 	}
 
 	PerformanceResponse struct {
-		TotalValue    float64 `json:"total_value"`
-		AvailableCash float64 `json:"available_cash"`
-		UnrealizedPnL float64 `json:"unrealized_pnl"`
-		RealizedPnL   float64 `json:"realized_pnl"`
-		DailyPnL      float64 `json:"daily_pnl"`
-		WeeklyPnL     float64 `json:"weekly_pnl"`
-		MonthlyPnL    float64 `json:"monthly_pnl"`
+		BaseCurrency  string   `json:"base_currency"`
+		TotalValue    float64  `json:"total_value"`
+		AvailableCash float64  `json:"available_cash"`
+		UnrealizedPnL float64  `json:"unrealized_pnl"`
+		RealizedPnL   float64  `json:"realized_pnl"`
+		DailyPnL      float64  `json:"daily_pnl"`
+		WeeklyPnL     float64  `json:"weekly_pnl"`
+		MonthlyPnL    float64  `json:"monthly_pnl"`
+		Metrics       *Metrics `json:"metrics,omitempty"` // over performanceWindow, requires a database
 	}
 )
 
@@ -116,16 +172,31 @@ type PortfolioActor struct {
 	// Exchange actor reference for real-time data
 	exchangeActorPID *actor.PID
 
+	// Actors that receive the valuation so risk and rebalancing use the same numbers
+	riskManagerPID *actor.PID
+	rebalancePID   *actor.PID
+	aggregatorPID  *actor.PID
+
 	// Market data for portfolio valuation
 	currentPrices map[string]float64 // symbol -> current price
+	valuation     *valuation.Service // converts holdings into the base currency
+	netFlows      float64            // deposits minus withdrawals recorded in the base currency
 
 	// Performance tracking
 	lastUpdateTime time.Time
 	syncInterval   time.Duration
 }
 
+// valuationInterval controls how often the valuation is published to the risk and rebalance actors
+const valuationInterval = 10 * time.Second
+
 // New creates a new portfolio actor
 func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolog.Logger) *PortfolioActor {
+	baseCurrency := ""
+	if cfg != nil {
+		baseCurrency = cfg.Portfolio.BaseCurrency
+	}
+
 	return &PortfolioActor{
 		exchangeName:  exchangeName,
 		config:        cfg,
@@ -136,6 +207,7 @@ func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolo
 		trades:        make([]Trade, 0),
 		pnlHistory:    make(map[string]float64),
 		currentPrices: make(map[string]float64),
+		valuation:     valuation.New(baseCurrency),
 		syncInterval:  time.Minute * 5, // Sync with exchange every 5 minutes
 	}
 }
@@ -166,9 +238,29 @@ func (p *PortfolioActor) Receive(ctx *actor.Context) {
 	case RequestPositionsMsg:
 		p.onRequestPositions(ctx)
 	case UpdateMarketPricesMsg:
+		mailbox.Received(ctx)
 		p.onUpdateMarketPrices(ctx, msg)
 	case SetExchangeActorMsg:
 		p.onSetExchangeActor(ctx, msg)
+	case SetValuationTargetsMsg:
+		p.onSetValuationTargets(ctx, msg)
+	case SetAggregatorMsg:
+		p.aggregatorPID = msg.AggregatorPID
+		p.logger.Info().Msg("Aggregator reference set")
+	case PublishValuationMsg:
+		p.onPublishValuation(ctx)
+	case SaveSnapshotMsg:
+		p.saveSnapshot(msg.Reason)
+	case FlushMsg:
+		p.onFlush(ctx)
+	case SyncCashFlowsMsg:
+		p.onSyncCashFlows(ctx)
+	case TransfersMsg:
+		p.onTransfers(msg)
+	case RecordCashFlowMsg:
+		p.onRecordCashFlow(ctx, msg)
+	case GetValuationMsg:
+		ctx.Respond(p.valuate())
 	case GetPositionsMsg:
 		p.onGetPositions(ctx)
 	case GetBalancesMsg:
@@ -197,6 +289,16 @@ func (p *PortfolioActor) onStarted(ctx *actor.Context) {
 
 	// Start periodic exchange synchronization
 	p.scheduleExchangeSync(ctx)
+
+	// Keep risk and rebalancing up to date with the valuation
+	ctx.SendRepeat(ctx.PID(), PublishValuationMsg{}, valuationInterval)
+
+	// Record the equity curve
+	ctx.SendRepeat(ctx.PID(), SaveSnapshotMsg{Reason: "periodic"}, snapshotInterval)
+
+	// Pick up deposits and withdrawals so they are not mistaken for profit or loss
+	p.loadNetFlows()
+	ctx.SendRepeat(ctx.PID(), SyncCashFlowsMsg{}, cashFlowSyncInterval)
 }
 
 func (p *PortfolioActor) onStopped(ctx *actor.Context) {
@@ -212,6 +314,7 @@ func (p *PortfolioActor) onStatus(ctx *actor.Context) {
 		"positions":      len(p.positions),
 		"balances":       len(p.balances),
 		"trades":         len(p.trades),
+		"base_currency":  p.valuation.BaseCurrency(),
 		"total_value":    p.calculateTotalValue(),
 		"unrealized_pnl": p.calculateUnrealizedPnL(),
 	}
@@ -258,6 +361,7 @@ func (p *PortfolioActor) onUpdatePosition(ctx *actor.Context, msg UpdatePosition
 
 	position.CurrentPrice = msg.Price
 	position.UpdatedAt = time.Now()
+	p.recordFallbackPrice(msg.Symbol, msg.Price)
 
 	// Calculate unrealized PnL
 	if position.Quantity > 0 {
@@ -354,6 +458,7 @@ func (p *PortfolioActor) onGetPerformance(ctx *actor.Context) {
 	realizedPnL := p.calculateRealizedPnL()
 
 	response := PerformanceResponse{
+		BaseCurrency:  p.valuation.BaseCurrency(),
 		TotalValue:    totalValue,
 		AvailableCash: availableCash,
 		UnrealizedPnL: unrealizedPnL,
@@ -361,41 +466,96 @@ func (p *PortfolioActor) onGetPerformance(ctx *actor.Context) {
 		DailyPnL:      p.calculateDailyPnL(),
 		WeeklyPnL:     p.calculateWeeklyPnL(),
 		MonthlyPnL:    p.calculateMonthlyPnL(),
+		Metrics:       p.calculateMetrics(),
 	}
 
 	ctx.Respond(response)
 }
 
+// calculateMetrics computes performance metrics for this exchange from stored snapshots and fills
+func (p *PortfolioActor) calculateMetrics() *Metrics {
+	if p.db == nil {
+		return nil
+	}
+
+	to := time.Now()
+	from := to.Add(-performanceWindow)
+
+	snapshots, err := p.db.GetPortfolioSnapshots(p.exchangeName, from, to)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Failed to load portfolio snapshots for metrics")
+		return nil
+	}
+	// Earlier fills open the positions that fills in the window close
+	fills, err := p.db.GetFills(p.exchangeName, time.Time{}, to)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Failed to load fills for metrics")
+		return nil
+	}
+	flows, err := p.db.GetCashFlows(p.exchangeName, from, to)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Failed to load cash flows for metrics")
+		return nil
+	}
+
+	metrics := ComputeMetrics(EquityCurve(snapshots, flows, 24*time.Hour), TripsSince(MatchFills(fills), from), from, to)
+	return &metrics
+}
+
 func (p *PortfolioActor) calculateTotalValue() float64 {
-	total := 0.0
+	return p.valuate().TotalValue
+}
 
-	// Add cash balances
+func (p *PortfolioActor) calculateAvailableCash() float64 {
+	return p.valuate().AvailableCash
+}
+
+// valuate converts all balances and positions into the configured base currency
+func (p *PortfolioActor) valuate() valuation.Valuation {
+	return p.valuation.Value(p.holdings())
+}
+
+// holdings collects the balance of every asset plus positions in assets without a balance.
+// On spot exchanges a filled buy shows up as both a position and a balance, so balances take precedence.
+func (p *PortfolioActor) holdings() []valuation.Holding {
+	byAsset := make(map[string]*valuation.Holding)
 	for _, balance := range p.balances {
-		if balance.Asset == "USDT" || balance.Asset == "USD" {
-			total += balance.Total
+		holding, exists := byAsset[balance.Asset]
+		if !exists {
+			holding = &valuation.Holding{Asset: balance.Asset}
+			byAsset[balance.Asset] = holding
 		}
+		holding.Total += balance.Total
+		holding.Available += balance.Available
 	}
 
-	// Add position values
 	for _, position := range p.positions {
-		if position.Quantity > 0 {
-			total += position.Quantity * position.CurrentPrice
+		if position.Quantity <= 0 {
+			continue
+		}
+
+		pair, ok := valuation.ParseSymbol(position.Symbol)
+		if !ok {
+			continue
+		}
+		if _, held := byAsset[pair.Base]; held {
+			continue
 		}
+		byAsset[pair.Base] = &valuation.Holding{Asset: pair.Base, Total: position.Quantity}
 	}
 
-	return total
+	holdings := make([]valuation.Holding, 0, len(byAsset))
+	for _, holding := range byAsset {
+		holdings = append(holdings, *holding)
+	}
+	return holdings
 }
 
-func (p *PortfolioActor) calculateAvailableCash() float64 {
-	cash := 0.0
-
-	for _, balance := range p.balances {
-		if balance.Asset == "USDT" || balance.Asset == "USD" {
-			cash += balance.Available
-		}
+// recordFallbackPrice uses a trade price for valuation until market data for the symbol arrives
+func (p *PortfolioActor) recordFallbackPrice(symbol string, price float64) {
+	if _, known := p.valuation.Price(symbol); !known {
+		p.valuation.UpdatePrice(symbol, price)
 	}
-
-	return cash
 }
 
 func (p *PortfolioActor) calculateUnrealizedPnL() float64 {
@@ -526,12 +686,16 @@ func (p *PortfolioActor) onTradeExecuted(ctx *actor.Context, msg TradeExecutedMs
 	}
 
 	position.UpdatedAt = time.Now()
+	p.recordFallbackPrice(msg.Trade.Symbol, msg.Trade.Price)
 
-	// Update balance (subtract fees and trade amount)
-	quoteAsset := "USDT" // Assuming USDT as quote asset for simplicity
-	balanceKey := fmt.Sprintf("%s:%s", msg.Trade.Exchange, quoteAsset)
+	// Update the balance of the quote asset (subtract fees and trade amount)
+	pair, parsed := valuation.ParseSymbol(msg.Trade.Symbol)
+	if !parsed {
+		p.logger.Warn().Str("symbol", msg.Trade.Symbol).Msg("Unknown quote asset, balance is updated on the next sync")
+	}
+	balanceKey := fmt.Sprintf("%s:%s", msg.Trade.Exchange, pair.Quote)
 	balance, exists := p.balances[balanceKey]
-	if exists {
+	if parsed && exists {
 		if msg.Trade.Side == "buy" {
 			balance.Available -= msg.Trade.Quantity * msg.Trade.Price
 		} else {
@@ -549,6 +713,8 @@ func (p *PortfolioActor) onTradeExecuted(ctx *actor.Context, msg TradeExecutedMs
 		Float64("quantity", msg.Trade.Quantity).
 		Float64("price", msg.Trade.Price).
 		Msg("Trade executed and portfolio updated")
+
+	p.saveSnapshot("trade")
 }
 
 func (p *PortfolioActor) onSyncWithExchange(ctx *actor.Context) {
@@ -596,6 +762,7 @@ func (p *PortfolioActor) onUpdateMarketPrices(ctx *actor.Context, msg UpdateMark
 	// Update current prices
 	for symbol, price := range msg.Prices {
 		p.currentPrices[symbol] = price
+		p.valuation.UpdatePrice(symbol, price)
 
 		// Update position current prices and unrealized PnL
 		key := fmt.Sprintf("%s:%s", p.exchangeName, symbol)
@@ -622,6 +789,133 @@ func (p *PortfolioActor) onSetExchangeActor(ctx *actor.Context, msg SetExchangeA
 
 	// Immediately sync with exchange
 	ctx.Send(ctx.PID(), SyncWithExchangeMsg{})
+	ctx.Send(ctx.PID(), SyncCashFlowsMsg{})
+}
+
+func (p *PortfolioActor) onSetValuationTargets(ctx *actor.Context, msg SetValuationTargetsMsg) {
+	p.riskManagerPID = msg.RiskManagerPID
+	p.rebalancePID = msg.RebalancePID
+	p.logger.Info().Msg("Valuation targets set")
+}
+
+// onPublishValuation sends the current valuation to the aggregator, risk and rebalance actors.
+// Risk and rebalancing get nothing until at least one holding could be priced, so risk keeps its defaults during startup.
+func (p *PortfolioActor) onPublishValuation(ctx *actor.Context) {
+	if p.riskManagerPID == nil && p.rebalancePID == nil && p.aggregatorPID == nil {
+		return
+	}
+
+	current := p.valuate()
+	if p.aggregatorPID != nil {
+		ctx.Send(p.aggregatorPID, p.snapshot(current))
+	}
+	if current.TotalValue <= 0 {
+		return
+	}
+	portfolioValue.With(p.exchangeName, current.BaseCurrency).Set(current.TotalValue)
+	portfolioExposure.With(p.exchangeName, current.BaseCurrency).Set(current.TotalValue - current.AvailableCash)
+
+	if p.riskManagerPID != nil {
+		mailbox.Send(ctx.Engine(), p.riskManagerPID, risk.UpdatePortfolioValueMsg{
+			TotalValue:   current.TotalValue,
+			Cash:         current.AvailableCash,
+			BaseCurrency: current.BaseCurrency,
+			Rates:        current.Rates,
+			NetCashFlows: p.netFlows,
+		})
+	}
+
+	if p.rebalancePID != nil {
+		balances := make(map[string]float64)
+		for _, holding := range p.holdings() {
+			balances[holding.Asset] += holding.Total
+		}
+		prices := make(map[string]float64, len(p.currentPrices))
+		for symbol, price := range p.currentPrices {
+			prices[symbol] = price
+		}
+
+		ctx.Send(p.rebalancePID, rebalance.PortfolioValueMsg{
+			TotalValue:   current.TotalValue,
+			Balances:     balances,
+			Prices:       prices,
+			BaseCurrency: current.BaseCurrency,
+			Cash:         current.AvailableCash,
+			Values:       current.Values,
+			Rates:        current.Rates,
+		})
+	}
+
+	if len(current.Unpriced) > 0 {
+		p.logger.Debug().
+			Strs("assets", current.Unpriced).
+			Str("base_currency", current.BaseCurrency).
+			Msg("Assets without a price route to the base currency were left out of the valuation")
+	}
+}
+
+// snapshot captures the holdings, open positions and PnL of this exchange for consolidation
+func (p *PortfolioActor) snapshot(current valuation.Valuation) SnapshotMsg {
+	positions := make([]Position, 0, len(p.positions))
+	for _, position := range p.positions {
+		if position.Quantity > 0 {
+			positions = append(positions, *position)
+		}
+	}
+
+	return SnapshotMsg{
+		Exchange:      p.exchangeName,
+		Valuation:     current,
+		Holdings:      p.holdings(),
+		Positions:     positions,
+		UnrealizedPnL: p.calculateUnrealizedPnL(),
+		RealizedPnL:   p.calculateRealizedPnL(),
+		DailyPnL:      p.calculateDailyPnL(),
+		Timestamp:     time.Now(),
+	}
+}
+
+func (p *PortfolioActor) onFlush(ctx *actor.Context) {
+	if err := p.saveSnapshot("shutdown"); err != nil {
+		ctx.Respond(err)
+		return
+	}
+	ctx.Respond("flushed")
+}
+
+// saveSnapshot persists the current valuation. Nothing is written until at least one holding could be priced.
+func (p *PortfolioActor) saveSnapshot(reason string) error {
+	if p.db == nil {
+		return nil
+	}
+
+	current := p.valuate()
+	if current.TotalValue <= 0 {
+		return nil
+	}
+
+	snapshot := &database.PortfolioSnapshot{
+		Exchange:      p.exchangeName,
+		BaseCurrency:  current.BaseCurrency,
+		TotalValue:    current.TotalValue,
+		Cash:          current.AvailableCash,
+		UnrealizedPnL: p.calculateUnrealizedPnL(),
+		RealizedPnL:   p.calculateRealizedPnL(),
+		Assets:        current.Values,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := p.db.SavePortfolioSnapshot(snapshot); err != nil {
+		p.logger.Error().Err(err).Str("reason", reason).Msg("Failed to save portfolio snapshot")
+		return err
+	}
+
+	p.logger.Debug().
+		Str("reason", reason).
+		Float64("total_value", snapshot.TotalValue).
+		Msg("Portfolio snapshot saved")
+	return nil
 }
 
 func (p *PortfolioActor) updateDailyPnL() {
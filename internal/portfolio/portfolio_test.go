@@ -465,4 +465,57 @@ func TestPortfolioCalculations(t *testing.T) {
 	if expectedPositionValue != 50000.0 {
 		t.Errorf("expected position value 50000.0, got %f", expectedPositionValue)
 	}
-}
\ No newline at end of file
+}
+func TestMultiCurrencyValuation(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+
+	cfg := &config.Config{Portfolio: config.PortfolioConfig{BaseCurrency: "EUR"}}
+	portfolio := New("bitvavo", cfg, db, zerolog.New(nil))
+
+	portfolio.balances["bitvavo:EUR"] = &Balance{Exchange: "bitvavo", Asset: "EUR", Available: 1000, Total: 1000}
+	portfolio.balances["bitvavo:USDT"] = &Balance{Exchange: "bitvavo", Asset: "USDT", Available: 200, Total: 200}
+	portfolio.balances["bitvavo:BTC"] = &Balance{Exchange: "bitvavo", Asset: "BTC", Available: 0.1, Total: 0.1}
+
+	// The BTC position is already held as a balance and must not be counted twice
+	portfolio.positions["bitvavo:BTC-EUR"] = &Position{Exchange: "bitvavo", Symbol: "BTC-EUR", Quantity: 0.1, CurrentPrice: 50000}
+	portfolio.positions["bitvavo:ETH-EUR"] = &Position{Exchange: "bitvavo", Symbol: "ETH-EUR", Quantity: 1, CurrentPrice: 3000}
+
+	// BTC-EUR and BTCUSDT give the USDT cross rate, ETH is priced from its trade
+	portfolio.valuation.UpdatePrice("BTC-EUR", 50000)
+	portfolio.valuation.UpdatePrice("BTCUSDT", 62500)
+	portfolio.recordFallbackPrice("ETH-EUR", 3000)
+
+	// 1000 EUR + 200 USDT * 0.8 + 0.1 BTC * 50000 + 1 ETH * 3000
+	if total := portfolio.calculateTotalValue(); total < 9159.99 || total > 9160.01 {
+		t.Errorf("expected total value 9160 EUR, got %f", total)
+	}
+	if cash := portfolio.calculateAvailableCash(); cash < 1159.99 || cash > 1160.01 {
+		t.Errorf("expected available cash 1160 EUR, got %f", cash)
+	}
+}
+
+func TestTradeExecutedDebitsQuoteAsset(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+
+	portfolio := New("bitvavo", &config.Config{}, db, zerolog.New(nil))
+	portfolio.balances["bitvavo:EUR"] = &Balance{Exchange: "bitvavo", Asset: "EUR", Available: 1000, Total: 1000}
+	portfolio.balances["bitvavo:USDT"] = &Balance{Exchange: "bitvavo", Asset: "USDT", Available: 500, Total: 500}
+
+	portfolio.onTradeExecuted(nil, TradeExecutedMsg{Trade: Trade{
+		Exchange: "bitvavo",
+		Symbol:   "BTC-EUR",
+		Side:     "buy",
+		Quantity: 0.01,
+		Price:    50000,
+		Fee:      1,
+	}})
+
+	if eur := portfolio.balances["bitvavo:EUR"].Available; eur < 498.99 || eur > 499.01 {
+		t.Errorf("expected 499 EUR left after the trade and fee, got %f", eur)
+	}
+	if usdt := portfolio.balances["bitvavo:USDT"].Available; usdt != 500 {
+		t.Errorf("expected the USDT balance to be untouched, got %f", usdt)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/rs/zerolog"
 	"go.starlark.net/starlark"
 
+	"github.com/arijanluiken/mercantile/internal/sandbox"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 )
@@ -23,6 +24,10 @@ type (
 	LoadScriptMsg       struct {
 		ScriptPath string
 	}
+	// ReloadScriptMsg reports a changed script on disk, only the active script is reloaded
+	ReloadScriptMsg struct {
+		ScriptPath string
+	}
 	StatusMsg struct{}
 
 	// Actor reference messages
@@ -40,11 +45,15 @@ type (
 		Prices map[string]float64
 	}
 
-	// Portfolio information
+	// Portfolio information, values are in BaseCurrency
 	PortfolioValueMsg struct {
-		TotalValue float64
-		Balances   map[string]float64
-		Prices     map[string]float64
+		TotalValue   float64
+		Balances     map[string]float64
+		Prices       map[string]float64
+		BaseCurrency string
+		Cash         float64
+		Values       map[string]float64 // asset -> value in BaseCurrency
+		Rates        map[string]float64 // asset -> price of one unit in BaseCurrency
 	}
 
 	// Order placement request
@@ -80,15 +89,27 @@ type RebalanceActor struct {
 	balances       map[string]float64
 	prices         map[string]float64
 	portfolioValue float64
+	baseCurrency   string
+	cash           float64
+	assetValues    map[string]float64 // asset -> value in base currency
+	rates          map[string]float64 // asset -> price in base currency
 
 	// Starlark execution
 	starlarkGlobals starlark.StringDict
 	scriptPath      string
 	rebalanceFunc   *starlark.Function
+	limits          sandbox.Limits // Step and time limits for script execution
+	violations      int            // Sandbox violations since the script was loaded
+	disabled        bool           // Disabled after too many sandbox violations
 }
 
 // New creates a new rebalance actor
 func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolog.Logger) *RebalanceActor {
+	limits := sandbox.DefaultLimits()
+	if cfg != nil {
+		limits = sandbox.NewLimits(cfg.Strategies.Sandbox)
+	}
+
 	return &RebalanceActor{
 		exchangeName: exchangeName,
 		config:       cfg,
@@ -96,7 +117,10 @@ func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolo
 		logger:       logger,
 		balances:     make(map[string]float64),
 		prices:       make(map[string]float64),
+		assetValues:  make(map[string]float64),
+		rates:        make(map[string]float64),
 		scriptConfig: make(map[string]interface{}),
+		limits:       limits,
 	}
 }
 
@@ -115,6 +139,8 @@ func (r *RebalanceActor) Receive(ctx *actor.Context) {
 		r.onTriggerRebalance(ctx, msg)
 	case LoadScriptMsg:
 		r.onLoadScript(ctx, msg)
+	case ReloadScriptMsg:
+		r.onReloadScript(ctx, msg)
 	case UpdateBalancesMsg:
 		r.onUpdateBalances(ctx, msg)
 	case UpdatePricesMsg:
@@ -205,6 +231,20 @@ func (r *RebalanceActor) onLoadScript(ctx *actor.Context, msg LoadScriptMsg) {
 	ctx.Respond("Script loaded successfully")
 }
 
+func (r *RebalanceActor) onReloadScript(ctx *actor.Context, msg ReloadScriptMsg) {
+	if r.currentScript == "" || filepath.Clean(msg.ScriptPath) != filepath.Clean(r.currentScript) {
+		return
+	}
+
+	// loadScript only replaces the active script once the new version loads cleanly
+	if err := r.loadScript(msg.ScriptPath); err != nil {
+		r.logger.Error().Err(err).Str("script", msg.ScriptPath).Msg("Failed to reload rebalancing script, keeping previous version")
+		return
+	}
+
+	r.logger.Info().Str("script", msg.ScriptPath).Msg("Rebalancing script reloaded")
+}
+
 func (r *RebalanceActor) onUpdateBalances(ctx *actor.Context, msg UpdateBalancesMsg) {
 	r.balances = msg.Balances
 	r.logger.Debug().
@@ -223,8 +263,13 @@ func (r *RebalanceActor) onPortfolioValue(ctx *actor.Context, msg PortfolioValue
 	r.portfolioValue = msg.TotalValue
 	r.balances = msg.Balances
 	r.prices = msg.Prices
+	r.baseCurrency = msg.BaseCurrency
+	r.cash = msg.Cash
+	r.assetValues = msg.Values
+	r.rates = msg.Rates
 
 	r.logger.Debug().
+		Str("base_currency", r.baseCurrency).
 		Float64("total_value", r.portfolioValue).
 		Msg("Updated portfolio data")
 }
@@ -235,10 +280,13 @@ func (r *RebalanceActor) onStatus(ctx *actor.Context) {
 		"is_running":      r.isRunning,
 		"current_script":  r.currentScript,
 		"last_rebalance":  r.lastRebalance,
+		"base_currency":   r.baseCurrency,
 		"portfolio_value": r.portfolioValue,
 		"balance_count":   len(r.balances),
 		"price_count":     len(r.prices),
 		"has_script":      r.rebalanceFunc != nil,
+		"disabled":        r.disabled,
+		"violations":      r.violations,
 		"timestamp":       time.Now(),
 	}
 
@@ -296,6 +344,13 @@ func (r *RebalanceActor) executeRebalancing(ctx *actor.Context) map[string]inter
 		}
 	}
 
+	if r.disabled {
+		return map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Rebalancing script disabled after %d sandbox violations", r.violations),
+		}
+	}
+
 	r.logger.Info().Msg("Executing rebalancing logic")
 
 	// Update global variables for the script
@@ -306,9 +361,15 @@ func (r *RebalanceActor) executeRebalancing(ctx *actor.Context) map[string]inter
 	args := starlark.Tuple{}
 	kwargs := []starlark.Tuple{}
 
-	result, err := starlark.Call(thread, r.rebalanceFunc, args, kwargs)
+	var result starlark.Value
+	err := sandbox.Run(thread, r.limits, func() error {
+		var callErr error
+		result, callErr = starlark.Call(thread, r.rebalanceFunc, args, kwargs)
+		return callErr
+	})
 	if err != nil {
 		r.logger.Error().Err(err).Msg("Failed to execute rebalancing function")
+		r.recordViolation(err)
 		return map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
@@ -336,6 +397,36 @@ func (r *RebalanceActor) executeRebalancing(ctx *actor.Context) map[string]inter
 	}
 }
 
+// recordViolation counts sandbox violations and disables rebalancing once the configured maximum is reached
+func (r *RebalanceActor) recordViolation(err error) {
+	violation, ok := sandbox.IsViolation(err)
+	if !ok {
+		return
+	}
+
+	r.violations++
+	r.logger.Warn().
+		Str("script", r.currentScript).
+		Str("kind", violation.Kind).
+		Int("violations", r.violations).
+		Int("max_violations", r.limits.MaxViolations).
+		Msg("Rebalancing script exceeded sandbox limit")
+
+	if r.limits.MaxViolations > 0 && r.violations >= r.limits.MaxViolations && !r.disabled {
+		r.disabled = true
+		r.isRunning = false
+		if r.rebalanceTimer != nil {
+			r.rebalanceTimer.Stop()
+			r.rebalanceTimer = nil
+		}
+
+		r.logger.Error().
+			Str("script", r.currentScript).
+			Int("violations", r.violations).
+			Msg("Rebalancing disabled after repeated sandbox violations")
+	}
+}
+
 func (r *RebalanceActor) loadScript(scriptPath string) error {
 	r.logger.Info().Str("path", scriptPath).Msg("Loading rebalancing script")
 
@@ -347,27 +438,38 @@ func (r *RebalanceActor) loadScript(scriptPath string) error {
 
 	// Parse and execute the script
 	thread := &starlark.Thread{Name: "rebalance_load"}
-	globals, err := starlark.ExecFile(thread, scriptPath, content, r.starlarkGlobals)
+	var globals starlark.StringDict
+	err = sandbox.Run(thread, r.limits, func() error {
+		var execErr error
+		globals, execErr = starlark.ExecFile(thread, scriptPath, content, r.starlarkGlobals)
+		return execErr
+	})
 	if err != nil {
 		return fmt.Errorf("failed to execute script: %w", err)
 	}
 
 	// Extract the settings function
+	scriptConfig := r.scriptConfig
 	if settingsFunc, ok := globals["settings"]; ok {
 		if fn, ok := settingsFunc.(*starlark.Function); ok {
 			// Call settings() to get configuration
 			args := starlark.Tuple{}
 			kwargs := []starlark.Tuple{}
 
-			result, err := starlark.Call(thread, fn, args, kwargs)
+			var result starlark.Value
+			err := sandbox.Run(thread, r.limits, func() error {
+				var callErr error
+				result, callErr = starlark.Call(thread, fn, args, kwargs)
+				return callErr
+			})
 			if err != nil {
 				return fmt.Errorf("failed to call settings(): %w", err)
 			}
 
 			if settingsDict, ok := result.(*starlark.Dict); ok {
-				r.scriptConfig = r.starlarkDictToGo(settingsDict)
+				scriptConfig = r.starlarkDictToGo(settingsDict)
 				r.logger.Info().
-					Interface("config", r.scriptConfig).
+					Interface("config", scriptConfig).
 					Msg("Loaded script configuration")
 			}
 		}
@@ -377,7 +479,10 @@ func (r *RebalanceActor) loadScript(scriptPath string) error {
 	if rebalanceFunc, ok := globals["on_rebalance"]; ok {
 		if fn, ok := rebalanceFunc.(*starlark.Function); ok {
 			r.rebalanceFunc = fn
+			r.scriptConfig = scriptConfig
 			r.currentScript = scriptPath
+			r.violations = 0
+			r.disabled = false
 			r.logger.Info().Msg("Rebalancing function loaded successfully")
 		} else {
 			return fmt.Errorf("on_rebalance is not a function")
@@ -395,6 +500,7 @@ func (r *RebalanceActor) initializeStarlarkGlobals() {
 		"get_balances":        starlark.NewBuiltin("get_balances", r.starlarkGetBalances),
 		"get_current_prices":  starlark.NewBuiltin("get_current_prices", r.starlarkGetCurrentPrices),
 		"get_portfolio_value": starlark.NewBuiltin("get_portfolio_value", r.starlarkGetPortfolioValue),
+		"get_valuation":       starlark.NewBuiltin("get_valuation", r.starlarkGetValuation),
 		"place_order":         starlark.NewBuiltin("place_order", r.starlarkPlaceOrder),
 		"log":                 starlark.NewBuiltin("log", r.starlarkLog),
 		"print":               starlark.NewBuiltin("print", r.starlarkPrint),
@@ -439,6 +545,20 @@ func (r *RebalanceActor) starlarkGetPortfolioValue(thread *starlark.Thread, b *s
 	return starlark.Float(r.portfolioValue), nil
 }
 
+func (r *RebalanceActor) starlarkGetValuation(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("get_valuation() takes no arguments")
+	}
+
+	return r.goToStarlarkDict(map[string]interface{}{
+		"base_currency": r.baseCurrency,
+		"total_value":   r.portfolioValue,
+		"cash":          r.cash,
+		"values":        r.assetValues,
+		"rates":         r.rates,
+	}), nil
+}
+
 func (r *RebalanceActor) starlarkPlaceOrder(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var symbol, side, orderType, reason string
 	var quantity float64
@@ -0,0 +1,468 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arijanluiken/mercantile/internal/valuation"
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// Method selects which lots a disposal is matched against
+type Method string
+
+// Cost basis methods
+const (
+	MethodFIFO    Method = "fifo"
+	MethodLIFO    Method = "lifo"
+	MethodAverage Method = "average"
+)
+
+// Holding period terms
+const (
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+const (
+	// longTermPeriod is the holding period after which a gain counts as long term
+	longTermPeriod = 365 * 24 * time.Hour
+
+	// dust is the quantity below which a lot counts as used up, absorbing float rounding
+	dust = 1e-12
+)
+
+// ParseMethod parses a cost basis method. "avg" is accepted for average cost.
+func ParseMethod(method string) (Method, error) {
+	switch strings.ToLower(strings.TrimSpace(method)) {
+	case "", "fifo":
+		return MethodFIFO, nil
+	case "lifo":
+		return MethodLIFO, nil
+	case "average", "avg":
+		return MethodAverage, nil
+	}
+	return "", fmt.Errorf("invalid method %q, use fifo, lifo or average", method)
+}
+
+// Acquisition is a buy in the fiscal year. Amounts are in the base currency.
+type Acquisition struct {
+	Date     time.Time `json:"date"`
+	Exchange string    `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Asset    string    `json:"asset"`
+	OrderID  string    `json:"order_id"`
+	Quantity float64   `json:"quantity"`
+	Cost     float64   `json:"cost"`
+	Fee      float64   `json:"fee"`
+}
+
+// Disposal is the part of a sell in the fiscal year matched against one acquired lot.
+// Amounts are in the base currency; Gain is Proceeds minus CostBasis minus Fees.
+type Disposal struct {
+	Asset       string    `json:"asset"`
+	Exchange    string    `json:"exchange"`
+	Symbol      string    `json:"symbol"`
+	OrderID     string    `json:"order_id"`
+	Quantity    float64   `json:"quantity"`
+	Acquired    time.Time `json:"acquired"` // zero when unmatched
+	Disposed    time.Time `json:"disposed"`
+	HoldingDays int       `json:"holding_days"`
+	Term        string    `json:"term"`
+	Proceeds    float64   `json:"proceeds"`
+	CostBasis   float64   `json:"cost_basis"`
+	Fees        float64   `json:"fees"` // acquisition and disposal fees of the matched quantity
+	Gain        float64   `json:"gain"`
+	Unmatched   bool      `json:"unmatched,omitempty"` // sold more than recorded buys, the cost basis is unknown
+}
+
+// Summary totals the disposals of a fiscal year
+type Summary struct {
+	Disposals     int     `json:"disposals"`
+	Proceeds      float64 `json:"proceeds"`
+	CostBasis     float64 `json:"cost_basis"`
+	Fees          float64 `json:"fees"`
+	Gain          float64 `json:"gain"`
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+}
+
+// TaxReport lists the acquisitions and disposals of one fiscal year, valued in the base currency
+type TaxReport struct {
+	Year         int           `json:"year"`
+	Method       Method        `json:"method"`
+	BaseCurrency string        `json:"base_currency"`
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	Summary      Summary       `json:"summary"`
+	Acquisitions []Acquisition `json:"acquisitions"`
+	Disposals    []Disposal    `json:"disposals"`
+	Warnings     []string      `json:"warnings,omitempty"`
+}
+
+// GenerateTaxReport loads the trade history up to the end of year and builds its tax report.
+// Filled orders without a recorded fill, such as those from before fills were tracked, are included too.
+func GenerateTaxReport(db *database.DB, year int, method Method, baseCurrency string) (*TaxReport, error) {
+	_, to := yearRange(year)
+
+	fills, err := db.GetFills("", time.Time{}, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fills: %w", err)
+	}
+	orders, err := db.GetFilledOrders(time.Time{}, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load filled orders: %w", err)
+	}
+
+	return BuildTaxReport(mergeOrders(fills, orders), year, method, baseCurrency), nil
+}
+
+// mergeOrders adds filled orders that have no fill of their own, keeping the result oldest first
+func mergeOrders(fills []*database.Fill, orders []*database.Order) []*database.Fill {
+	recorded := make(map[string]bool, len(fills))
+	for _, fill := range fills {
+		recorded[fill.Exchange+":"+fill.OrderID] = true
+	}
+
+	merged := append([]*database.Fill{}, fills...)
+	for _, order := range orders {
+		if recorded[order.Exchange+":"+order.ExchangeOrderID] {
+			continue
+		}
+		merged = append(merged, &database.Fill{
+			Exchange:  order.Exchange,
+			OrderID:   order.ExchangeOrderID,
+			Symbol:    order.Symbol,
+			Side:      strings.ToLower(order.Side),
+			Quantity:  order.Quantity,
+			Price:     order.Price,
+			CreatedAt: order.UpdatedAt,
+		})
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].CreatedAt.Before(merged[j].CreatedAt) })
+	return merged
+}
+
+// yearRange returns the first and last instant of a fiscal year in UTC
+func yearRange(year int) (time.Time, time.Time) {
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(1, 0, 0).Add(-time.Nanosecond)
+}
+
+// taxLot is the unsold remainder of an acquisition
+type taxLot struct {
+	quantity float64
+	cost     float64 // per unit in the base currency
+	fee      float64 // per unit in the base currency
+	acquired time.Time
+}
+
+// pool holds the lots of one asset across all exchanges, with the totals of the average cost method
+type pool struct {
+	lots     []taxLot
+	quantity float64
+	cost     float64 // total in the base currency
+	fee      float64 // total acquisition fees in the base currency
+}
+
+// BuildTaxReport matches sells against earlier buys of the same asset, pooled across exchanges, and
+// reports the buys and matched sells that fall in year. Fills must be ordered oldest first.
+// A quote asset other than fiat or a stablecoin, such as BTC in ETHBTC, is an asset of its own: a buy
+// disposes of the quote asset spent and a sell acquires the quote asset received, both at the fill's rate.
+// With the average method the cost basis is the average cost of the pool, which a disposal reduces in
+// proportion to the quantity sold, while lots are still consumed first in first out to date the holding period.
+func BuildTaxReport(fills []*database.Fill, year int, method Method, baseCurrency string) *TaxReport {
+	from, to := yearRange(year)
+	baseCurrency = strings.ToUpper(baseCurrency)
+	if baseCurrency == "" {
+		baseCurrency = valuation.DefaultBaseCurrency
+	}
+
+	report := &TaxReport{
+		Year:         year,
+		Method:       method,
+		BaseCurrency: baseCurrency,
+		From:         from,
+		To:           to,
+		Acquisitions: make([]Acquisition, 0),
+		Disposals:    make([]Disposal, 0),
+	}
+
+	pools := make(map[string]*pool)
+	poolOf := func(asset string) *pool {
+		p, exists := pools[asset]
+		if !exists {
+			p = &pool{}
+			pools[asset] = p
+		}
+		return p
+	}
+	unpriced := make(map[string]bool)
+
+	for _, fill := range fills {
+		if fill.CreatedAt.After(to) || fill.Quantity <= 0 || fill.Price <= 0 {
+			continue
+		}
+
+		pair, ok := valuation.ParseSymbol(fill.Symbol)
+		if !ok {
+			report.warn(fmt.Sprintf("skipped %s order %s: unknown symbol %s", fill.Exchange, fill.OrderID, fill.Symbol))
+			continue
+		}
+
+		rate, ok := fillRate(fill, pair.Quote, baseCurrency)
+		if !ok {
+			if !unpriced[pair.Quote] {
+				unpriced[pair.Quote] = true
+				report.warn(fmt.Sprintf("no %s rate recorded for %s, its trades are left out", baseCurrency, pair.Quote))
+			}
+			continue
+		}
+
+		p := poolOf(pair.Base)
+		price := fill.Price * rate
+		fee := fill.Fee * rate / fill.Quantity
+		inYear := !fill.CreatedAt.Before(from)
+		buy := strings.ToLower(fill.Side) == "buy"
+
+		// The trade fee is part of the base leg, the quote leg moves the quote amount net of it
+		if !valuation.IsCash(pair.Quote) {
+			q := poolOf(pair.Quote)
+			if buy {
+				spent := fill.Quantity*fill.Price + fill.Fee
+				disposals := q.dispose(fill, pair.Quote, spent, rate, 0, method)
+				if inYear {
+					report.Disposals = append(report.Disposals, disposals...)
+				}
+			} else if received := fill.Quantity*fill.Price - fill.Fee; received > dust {
+				q.acquire(taxLot{quantity: received, cost: rate, acquired: fill.CreatedAt})
+				if inYear {
+					report.Acquisitions = append(report.Acquisitions, Acquisition{
+						Date:     fill.CreatedAt.UTC(),
+						Exchange: fill.Exchange,
+						Symbol:   fill.Symbol,
+						Asset:    pair.Quote,
+						OrderID:  fill.OrderID,
+						Quantity: received,
+						Cost:     received * rate,
+					})
+				}
+			}
+		}
+
+		if buy {
+			p.acquire(taxLot{quantity: fill.Quantity, cost: price, fee: fee, acquired: fill.CreatedAt})
+			if inYear {
+				report.Acquisitions = append(report.Acquisitions, Acquisition{
+					Date:     fill.CreatedAt.UTC(),
+					Exchange: fill.Exchange,
+					Symbol:   fill.Symbol,
+					Asset:    pair.Base,
+					OrderID:  fill.OrderID,
+					Quantity: fill.Quantity,
+					Cost:     fill.Quantity * price,
+					Fee:      fill.Quantity * fee,
+				})
+			}
+			continue
+		}
+
+		disposals := p.dispose(fill, pair.Base, fill.Quantity, price, fee, method)
+		if inYear {
+			report.Disposals = append(report.Disposals, disposals...)
+		}
+	}
+
+	for _, disposal := range report.Disposals {
+		report.Summary.add(disposal)
+		if disposal.Unmatched {
+			report.warn(fmt.Sprintf("%s order %s sold %g %s more than recorded buys, its cost basis is 0",
+				disposal.Exchange, disposal.OrderID, disposal.Quantity, disposal.Asset))
+		}
+	}
+
+	return report
+}
+
+// fillRate returns the price of one unit of the quote asset in the base currency at the time of the fill
+func fillRate(fill *database.Fill, quote, baseCurrency string) (float64, bool) {
+	if fill.QuoteRate > 0 && strings.EqualFold(fill.BaseCurrency, baseCurrency) {
+		return fill.QuoteRate, true
+	}
+	if quote == baseCurrency {
+		return 1, true
+	}
+	// Dollar stablecoins are pegged to USD and each other, as in the portfolio valuation
+	isDollar := func(asset string) bool { return asset == "USD" || valuation.IsStablecoin(asset) }
+	if isDollar(quote) && isDollar(baseCurrency) {
+		return 1, true
+	}
+	return 0, false
+}
+
+// acquire adds a bought lot to the pool
+func (p *pool) acquire(lot taxLot) {
+	p.lots = append(p.lots, lot)
+	p.quantity += lot.quantity
+	p.cost += lot.quantity * lot.cost
+	p.fee += lot.quantity * lot.fee
+}
+
+// dispose removes quantity from the pool and returns one disposal per matched lot.
+// Quantity beyond the recorded lots is returned as an unmatched disposal with no cost basis.
+func (p *pool) dispose(fill *database.Fill, asset string, quantity, price, fee float64, method Method) []Disposal {
+	averageCost, averageFee := p.average()
+	remaining := quantity
+	var disposals []Disposal
+
+	for remaining > dust && len(p.lots) > 0 {
+		index := 0
+		if method == MethodLIFO {
+			index = len(p.lots) - 1
+		}
+		lot := &p.lots[index]
+		matched := math.Min(lot.quantity, remaining)
+
+		cost, lotFee := lot.cost, lot.fee
+		if method == MethodAverage {
+			cost, lotFee = averageCost, averageFee
+		}
+
+		disposal := newDisposal(fill, asset, matched, price, fee)
+		disposal.Acquired = lot.acquired.UTC()
+		disposal.HoldingDays = int(fill.CreatedAt.Sub(lot.acquired).Hours() / 24)
+		if fill.CreatedAt.Sub(lot.acquired) > longTermPeriod {
+			disposal.Term = TermLong
+		}
+		disposal.CostBasis = matched * cost
+		disposal.Fees += matched * lotFee
+		disposal.Gain = disposal.Proceeds - disposal.CostBasis - disposal.Fees
+		disposals = append(disposals, disposal)
+
+		lot.quantity -= matched
+		remaining -= matched
+		if lot.quantity <= dust {
+			p.lots = append(p.lots[:index], p.lots[index+1:]...)
+		}
+		p.reduce(matched)
+	}
+
+	if remaining > dust {
+		disposal := newDisposal(fill, asset, remaining, price, fee)
+		disposal.Unmatched = true
+		disposal.Gain = disposal.Proceeds - disposal.Fees
+		disposals = append(disposals, disposal)
+	}
+
+	return disposals
+}
+
+// average returns the average cost and acquisition fee per unit held in the pool
+func (p *pool) average() (float64, float64) {
+	if p.quantity <= dust {
+		return 0, 0
+	}
+	return p.cost / p.quantity, p.fee / p.quantity
+}
+
+// reduce takes quantity out of the pool totals, lowering its cost and fees in proportion
+func (p *pool) reduce(quantity float64) {
+	if p.quantity <= dust {
+		return
+	}
+	share := math.Min(quantity/p.quantity, 1)
+	p.cost -= p.cost * share
+	p.fee -= p.fee * share
+	p.quantity -= p.quantity * share
+}
+
+// newDisposal starts a disposal of quantity with its share of the proceeds and the sell fee
+func newDisposal(fill *database.Fill, asset string, quantity, price, fee float64) Disposal {
+	return Disposal{
+		Asset:    asset,
+		Exchange: fill.Exchange,
+		Symbol:   fill.Symbol,
+		OrderID:  fill.OrderID,
+		Quantity: quantity,
+		Disposed: fill.CreatedAt.UTC(),
+		Term:     TermShort,
+		Proceeds: quantity * price,
+		Fees:     quantity * fee,
+	}
+}
+
+func (s *Summary) add(disposal Disposal) {
+	s.Disposals++
+	s.Proceeds += disposal.Proceeds
+	s.CostBasis += disposal.CostBasis
+	s.Fees += disposal.Fees
+	s.Gain += disposal.Gain
+	if disposal.Term == TermLong {
+		s.LongTermGain += disposal.Gain
+	} else {
+		s.ShortTermGain += disposal.Gain
+	}
+}
+
+func (r *TaxReport) warn(message string) {
+	r.Warnings = append(r.Warnings, message)
+}
+
+// csvHeader lists the columns of the CSV export. Acquisitions leave the disposal columns empty.
+var csvHeader = []string{
+	"type", "asset", "exchange", "symbol", "order_id", "quantity",
+	"acquired", "disposed", "holding_days", "term",
+	"proceeds", "cost_basis", "fees", "gain", "currency",
+}
+
+// WriteCSV writes acquisitions followed by disposals, one row per lot
+func (r *TaxReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	number := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+	date := func(value time.Time) string {
+		if value.IsZero() {
+			return ""
+		}
+		return value.UTC().Format(time.RFC3339)
+	}
+
+	for _, acquisition := range r.Acquisitions {
+		err := writer.Write([]string{
+			"acquisition", acquisition.Asset, acquisition.Exchange, acquisition.Symbol, acquisition.OrderID, number(acquisition.Quantity),
+			date(acquisition.Date), "", "", "",
+			"", number(acquisition.Cost), number(acquisition.Fee), "", r.BaseCurrency,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, disposal := range r.Disposals {
+		holdingDays := strconv.Itoa(disposal.HoldingDays)
+		if disposal.Unmatched {
+			holdingDays = ""
+		}
+		err := writer.Write([]string{
+			"disposal", disposal.Asset, disposal.Exchange, disposal.Symbol, disposal.OrderID, number(disposal.Quantity),
+			date(disposal.Acquired), date(disposal.Disposed), holdingDays, disposal.Term,
+			number(disposal.Proceeds), number(disposal.CostBasis), number(disposal.Fees), number(disposal.Gain), r.BaseCurrency,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
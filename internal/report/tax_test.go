@@ -0,0 +1,209 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// testFills buys 1 BTC at 100 and 1 BTC at 200, then sells 1 BTC at 300 in the next year
+func testFills() []*database.Fill {
+	return []*database.Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, Fee: 1, CreatedAt: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{Exchange: "bitvavo", OrderID: "2", Symbol: "BTC-USDT", Side: "buy", Quantity: 1, Price: 200, CreatedAt: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Exchange: "bybit", OrderID: "3", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 300, Fee: 2, CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	for input, expected := range map[string]Method{"": MethodFIFO, "FIFO": MethodFIFO, "lifo": MethodLIFO, "avg": MethodAverage, "average": MethodAverage} {
+		if method, err := ParseMethod(input); err != nil || method != expected {
+			t.Errorf("ParseMethod(%q) = %s, %v; want %s", input, method, err, expected)
+		}
+	}
+	if _, err := ParseMethod("hifo"); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
+
+func TestBuildTaxReportMethods(t *testing.T) {
+	tests := []struct {
+		method   Method
+		cost     float64
+		fees     float64
+		term     string
+		acquired time.Time
+	}{
+		// The first lot, held over a year, carries its own fee
+		{MethodFIFO, 100, 3, TermLong, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{MethodLIFO, 200, 2, TermShort, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Average cost and acquisition fee with the holding period of the oldest lot
+		{MethodAverage, 150, 2.5, TermLong, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		report := BuildTaxReport(testFills(), 2026, tt.method, "USDT")
+
+		if len(report.Acquisitions) != 0 || len(report.Disposals) != 1 {
+			t.Fatalf("%s: expected no acquisitions and 1 disposal in 2026, got %d and %d", tt.method, len(report.Acquisitions), len(report.Disposals))
+		}
+		disposal := report.Disposals[0]
+		if disposal.CostBasis != tt.cost || !almostEqual(disposal.Fees, tt.fees) || disposal.Term != tt.term || !disposal.Acquired.Equal(tt.acquired) {
+			t.Errorf("%s: unexpected disposal %+v", tt.method, disposal)
+		}
+		if !almostEqual(disposal.Gain, 300-tt.cost-tt.fees) || !almostEqual(report.Summary.Gain, disposal.Gain) {
+			t.Errorf("%s: expected gain %f, got %f", tt.method, 300-tt.cost-tt.fees, disposal.Gain)
+		}
+	}
+}
+
+func TestBuildTaxReportAverageCost(t *testing.T) {
+	day := func(days int) time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days) }
+	fills := []*database.Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 100, CreatedAt: day(0)},
+		{Exchange: "bybit", OrderID: "2", Symbol: "BTCUSDT", Side: "buy", Quantity: 1, Price: 200, CreatedAt: day(1)},
+		{Exchange: "bybit", OrderID: "3", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 150, CreatedAt: day(2)},
+		{Exchange: "bybit", OrderID: "4", Symbol: "BTCUSDT", Side: "sell", Quantity: 1, Price: 150, CreatedAt: day(3)},
+	}
+
+	report := BuildTaxReport(fills, 2025, MethodAverage, "USDT")
+
+	if len(report.Disposals) != 2 {
+		t.Fatalf("expected 2 disposals, got %d", len(report.Disposals))
+	}
+	// Each sell takes half of the pool, whose average stays at 150
+	for _, disposal := range report.Disposals {
+		if !almostEqual(disposal.CostBasis, 150) {
+			t.Errorf("expected a cost basis of 150 for order %s, got %f", disposal.OrderID, disposal.CostBasis)
+		}
+	}
+	if !almostEqual(report.Summary.CostBasis, 300) || !almostEqual(report.Summary.Gain, 0) {
+		t.Errorf("expected a total cost basis of 300 and no gain, got %f and %f", report.Summary.CostBasis, report.Summary.Gain)
+	}
+}
+
+func TestBuildTaxReportCryptoQuote(t *testing.T) {
+	day := func(days int) time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days) }
+	fills := []*database.Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSD", Side: "buy", Quantity: 1, Price: 20000, CreatedAt: day(0)},
+		// Pays 0.5 BTC worth 20000 USD for ETH, then receives 0.6 BTC worth 30000 USD for it
+		{Exchange: "bybit", OrderID: "2", Symbol: "ETHBTC", Side: "buy", Quantity: 10, Price: 0.05, BaseCurrency: "USD", QuoteRate: 40000, CreatedAt: day(1)},
+		{Exchange: "bybit", OrderID: "3", Symbol: "ETHBTC", Side: "sell", Quantity: 10, Price: 0.06, BaseCurrency: "USD", QuoteRate: 50000, CreatedAt: day(2)},
+		{Exchange: "bybit", OrderID: "4", Symbol: "BTCUSD", Side: "sell", Quantity: 1.1, Price: 50000, CreatedAt: day(3)},
+	}
+
+	report := BuildTaxReport(fills, 2025, MethodFIFO, "USD")
+
+	// BTC spent, ETH sold, and BTC sold from the bought and the received lot
+	expected := []struct {
+		asset string
+		gain  float64
+	}{{"BTC", 10000}, {"ETH", 10000}, {"BTC", 15000}, {"BTC", 0}}
+	if len(report.Disposals) != len(expected) {
+		t.Fatalf("expected %d disposals, got %+v", len(expected), report.Disposals)
+	}
+	for i, disposal := range report.Disposals {
+		if disposal.Asset != expected[i].asset || !almostEqual(disposal.Gain, expected[i].gain) || disposal.Unmatched {
+			t.Errorf("disposal %d: expected a gain of %g on %s, got %+v", i, expected[i].gain, expected[i].asset, disposal)
+		}
+	}
+	if len(report.Acquisitions) != 3 || report.Acquisitions[2].Asset != "BTC" || !almostEqual(report.Acquisitions[2].Cost, 30000) {
+		t.Errorf("expected the received BTC acquired for 30000 USD, got %+v", report.Acquisitions)
+	}
+	// 20000 USD turned into 55000 USD
+	if !almostEqual(report.Summary.Gain, 35000) || len(report.Warnings) != 0 {
+		t.Errorf("expected a total gain of 35000 without warnings, got %f and %v", report.Summary.Gain, report.Warnings)
+	}
+}
+
+func TestBuildTaxReportAcquisitionsAndWarnings(t *testing.T) {
+	fills := append(testFills(),
+		// Valued with the rate recorded at the time of the fill
+		&database.Fill{Exchange: "bitvavo", OrderID: "4", Symbol: "ETH-EUR", Side: "buy", Quantity: 2, Price: 1000, BaseCurrency: "USDT", QuoteRate: 1.1, CreatedAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		// No rate to the base currency
+		&database.Fill{Exchange: "bitvavo", OrderID: "5", Symbol: "ETH-GBP", Side: "buy", Quantity: 1, Price: 800, CreatedAt: time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)},
+		// Sold more than was bought
+		&database.Fill{Exchange: "bybit", OrderID: "6", Symbol: "BTCUSDT", Side: "sell", Quantity: 2.5, Price: 400, CreatedAt: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	// Keep the fills oldest first
+	fills = []*database.Fill{fills[0], fills[1], fills[3], fills[4], fills[5], fills[2]}
+
+	report := BuildTaxReport(fills, 2025, MethodFIFO, "USDT")
+
+	if len(report.Acquisitions) != 3 {
+		t.Fatalf("expected 3 acquisitions in 2025, got %d", len(report.Acquisitions))
+	}
+	if !almostEqual(report.Acquisitions[2].Cost, 2200) {
+		t.Errorf("expected ETH bought for 2200 USDT, got %f", report.Acquisitions[2].Cost)
+	}
+
+	// 1 BTC from each lot, then 0.5 without a recorded buy
+	if len(report.Disposals) != 3 || !report.Disposals[2].Unmatched || report.Disposals[2].CostBasis != 0 {
+		t.Fatalf("expected two matched and one unmatched disposal, got %+v", report.Disposals)
+	}
+	if !almostEqual(report.Summary.Proceeds, 1000) || !almostEqual(report.Summary.CostBasis, 300) {
+		t.Errorf("expected proceeds 1000 and cost basis 300, got %+v", report.Summary)
+	}
+	if len(report.Warnings) != 2 {
+		t.Errorf("expected warnings for the GBP rate and the unmatched sale, got %v", report.Warnings)
+	}
+
+	var buffer bytes.Buffer
+	if err := report.WriteCSV(&buffer); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if len(records) != 1+3+3 || records[0][0] != "type" || records[4][0] != "disposal" || records[4][9] != TermShort {
+		t.Errorf("expected a header, 3 acquisitions and 3 disposals, got %v", records)
+	}
+}
+
+func TestGenerateTaxReport(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	for _, fill := range testFills()[1:] {
+		if err := db.SaveFill(fill); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+	// A filled order from before fills were recorded
+	order := &database.Order{
+		ExchangeOrderID: "1",
+		Exchange:        "bybit",
+		Symbol:          "BTCUSDT",
+		Side:            "Buy",
+		Type:            "limit",
+		Quantity:        1,
+		Price:           100,
+		Status:          "Filled",
+		CreatedAt:       time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:       time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+	}
+	if err := db.SaveOrder(order); err != nil {
+		t.Fatalf("failed to save order: %v", err)
+	}
+
+	report, err := GenerateTaxReport(db, 2026, MethodFIFO, "USDT")
+	if err != nil {
+		t.Fatalf("failed to generate report: %v", err)
+	}
+	if len(report.Disposals) != 1 || report.Disposals[0].CostBasis != 100 {
+		t.Errorf("expected the sale matched against the order history, got %+v", report.Disposals)
+	}
+}
@@ -0,0 +1,10 @@
+package risk
+
+import "github.com/arijanluiken/mercantile/pkg/metrics"
+
+var (
+	riskRejections = metrics.NewCounter("mercantile_risk_rejections_total",
+		"Orders rejected by the risk manager per failed check.", "exchange", "check")
+	portfolioDrawdown = metrics.NewGauge("mercantile_portfolio_drawdown_ratio",
+		"Current drawdown from the flow-adjusted high-water mark, 0.1 is 10%.", "exchange")
+)
@@ -4,16 +4,32 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/audit"
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/internal/notifier"
 	"github.com/arijanluiken/mercantile/internal/settings"
+	"github.com/arijanluiken/mercantile/internal/valuation"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 )
 
+// Checks that can reject an order, reported in OrderValidationResponse.Check
+const (
+	CheckPositionSize         = "position_size"
+	CheckDailyVolume          = "daily_volume"
+	CheckInsufficientCash     = "insufficient_cash"
+	CheckDailyRisk            = "daily_risk"
+	CheckDrawdown             = "drawdown"
+	CheckAccountExposure      = "account_exposure"
+	CheckAccountConcentration = "account_concentration"
+)
+
 // Messages for risk management
 type (
 	// Risk check messages
@@ -23,19 +39,31 @@ type (
 		Side     string
 		Quantity float64
 		Price    float64
+		Strategy string // Strategy that placed the order, empty for manual orders
 	}
 
 	// Risk check response
 	OrderValidationResponse struct {
 		Approved bool     `json:"approved"`
 		Reason   string   `json:"reason,omitempty"`
+		Check    string   `json:"check,omitempty"` // Name of the check that rejected the order
 		Warnings []string `json:"warnings,omitempty"`
 	}
 
-	// Portfolio update for risk calculations
+	// Portfolio update for risk calculations, values are in BaseCurrency
 	UpdatePortfolioValueMsg struct {
+		TotalValue   float64
+		Cash         float64
+		BaseCurrency string
+		Rates        map[string]float64 // asset -> price of one unit in BaseCurrency
+		NetCashFlows float64            // deposits minus withdrawals in BaseCurrency
+	}
+
+	// UpdateAccountExposureMsg carries holdings consolidated across all exchanges for account-wide limits
+	UpdateAccountExposureMsg struct {
+		Exposure   map[string]float64 // asset -> units held on all exchanges
+		Values     map[string]float64 // asset -> value in base currency
 		TotalValue float64
-		Cash       float64
 	}
 
 	// Risk metrics query
@@ -53,8 +81,11 @@ type (
 
 	// Risk configuration messages
 	SetRiskParameterMsg struct {
-		Key   string
-		Value string
+		Key      string
+		Value    string
+		Symbol   string // Sets the parameter for one symbol, only max_position_size allows this
+		Strategy string // Sets the parameter for one strategy, or the symbol traded by it
+		Actor    string // Who made the change, recorded in the settings history
 	}
 
 	GetRiskParameterMsg struct {
@@ -63,6 +94,9 @@ type (
 
 	LoadRiskConfigMsg struct{}
 
+	// ReloadSettingsMsg reloads the risk parameters after the settings changed, it has no response
+	ReloadSettingsMsg struct{}
+
 	// Risk parameter response
 	RiskParameterResponse struct {
 		Parameter string      `json:"parameter"`
@@ -77,6 +111,11 @@ type (
 	SetSettingsActorMsg struct {
 		SettingsPID *actor.PID
 	}
+
+	// UpdateConfigMsg replaces the configuration the limits are read from after a reload
+	UpdateConfigMsg struct {
+		Config *config.Config
+	}
 )
 
 // Risk configuration parameters
@@ -148,22 +187,43 @@ type RiskManagerActor struct {
 	maxDrawdown    float64
 	highWaterMark  float64
 	dailyRiskUsed  float64
+	halted         bool // Drawdown exceeded the limit at the last valuation, so every order is rejected
+
+	// Valuation received from the portfolio actor
+	baseCurrency string
+	rates        map[string]float64 // asset -> price in base currency, used to convert order values
+	valued       bool               // true once a real portfolio value replaced the startup defaults
+
+	// Peak value from portfolio snapshots, so a restart does not reset the drawdown baseline
+	historicalPeak float64
+	netCashFlows   float64 // deposits minus withdrawals the peaks are adjusted for
+
+	// Holdings across all exchanges, from the aggregator
+	accountExposure map[string]float64 // asset -> units
+	accountValues   map[string]float64 // asset -> value in base currency
+	accountValue    float64
 
 	// Actor references
-	settingsPID *actor.PID
-	riskConfig  *RiskConfig
+	settingsPID   *actor.PID
+	riskConfig    *RiskConfig
+	positionSizes map[string]float64 // symbol -> max position size set for that symbol in the settings
+	positionSized bool               // max position size set at global or exchange scope, in riskConfig
 }
 
 // New creates a new risk management actor
 func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolog.Logger) *RiskManagerActor {
 	return &RiskManagerActor{
-		exchangeName: exchangeName,
-		config:       cfg,
-		db:           db,
-		logger:       logger,
-		orderHistory: make([]OrderHistory, 0),
-		dailyVolume:  make(map[string]float64),
-		riskConfig:   defaultRiskConfig(),
+		exchangeName:    exchangeName,
+		config:          cfg,
+		db:              db,
+		logger:          logger,
+		orderHistory:    make([]OrderHistory, 0),
+		dailyVolume:     make(map[string]float64),
+		rates:           make(map[string]float64),
+		accountExposure: make(map[string]float64),
+		accountValues:   make(map[string]float64),
+		riskConfig:      defaultRiskConfig(),
+		positionSizes:   make(map[string]float64),
 	}
 }
 
@@ -180,9 +240,13 @@ func (r *RiskManagerActor) Receive(ctx *actor.Context) {
 	case actor.Stopped:
 		r.onStopped(ctx)
 	case ValidateOrderMsg:
+		mailbox.Received(ctx)
 		r.onValidateOrder(ctx, msg)
 	case UpdatePortfolioValueMsg:
+		mailbox.Received(ctx)
 		r.onUpdatePortfolioValue(ctx, msg)
+	case UpdateAccountExposureMsg:
+		r.onUpdateAccountExposure(ctx, msg)
 	case GetRiskMetricsMsg:
 		r.onGetRiskMetrics(ctx)
 	case SetRiskParameterMsg:
@@ -191,8 +255,12 @@ func (r *RiskManagerActor) Receive(ctx *actor.Context) {
 		r.onGetRiskParameter(ctx, msg)
 	case LoadRiskConfigMsg:
 		r.onLoadRiskConfig(ctx, msg)
+	case ReloadSettingsMsg:
+		r.loadRiskConfigFromSettings(ctx)
 	case SetSettingsActorMsg:
 		r.onSetSettingsActor(ctx, msg)
+	case UpdateConfigMsg:
+		r.onUpdateConfig(msg)
 	case StatusMsg:
 		r.onStatus(ctx)
 	default:
@@ -211,6 +279,7 @@ func (r *RiskManagerActor) onStarted(ctx *actor.Context) {
 	r.portfolioValue = 100000.0 // Default starting portfolio
 	r.cash = 50000.0
 	r.highWaterMark = r.portfolioValue
+	r.loadHistory()
 
 	// Load risk configuration from settings if available
 	if r.settingsPID != nil {
@@ -221,6 +290,101 @@ func (r *RiskManagerActor) onStarted(ctx *actor.Context) {
 	r.schedulePeriodicTasks(ctx)
 }
 
+// onUpdateConfig applies reloaded risk limits, the next valuation re-evaluates the drawdown halt
+func (r *RiskManagerActor) onUpdateConfig(msg UpdateConfigMsg) {
+	if msg.Config == nil {
+		return
+	}
+	r.config = msg.Config
+
+	r.logger.Info().
+		Float64("max_position_size", r.config.Risk.MaxPositionSize).
+		Float64("max_daily_volume", r.config.Risk.MaxDailyVolume).
+		Float64("max_daily_risk", r.config.Risk.MaxDailyRisk).
+		Float64("max_drawdown", r.config.Risk.MaxDrawdown).
+		Msg("Risk limits updated")
+}
+
+// loadHistory seeds the portfolio value and high water mark from stored portfolio snapshots
+func (r *RiskManagerActor) loadHistory() {
+	if r.db == nil {
+		return
+	}
+
+	latest, err := r.db.GetLatestPortfolioSnapshot(r.exchangeName)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to load latest portfolio snapshot")
+		return
+	}
+	if latest == nil {
+		return
+	}
+
+	peak, netCashFlows, err := r.loadPeak(latest.BaseCurrency)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to load peak portfolio value")
+		return
+	}
+
+	r.historicalPeak = peak
+	r.netCashFlows = netCashFlows
+	r.baseCurrency = latest.BaseCurrency
+	r.portfolioValue = latest.TotalValue
+	r.cash = latest.Cash
+	r.highWaterMark = math.Max(peak, latest.TotalValue)
+	r.maxDrawdown = (r.highWaterMark - r.portfolioValue) / r.highWaterMark
+
+	r.logger.Info().
+		Str("base_currency", r.baseCurrency).
+		Float64("portfolio_value", r.portfolioValue).
+		Float64("high_water_mark", r.highWaterMark).
+		Msg("Restored risk baseline from portfolio history")
+}
+
+// loadPeak returns the highest recorded portfolio value with deposits and withdrawals taken out,
+// expressed in today's money by adding back the current net cash flows, together with those flows
+func (r *RiskManagerActor) loadPeak(baseCurrency string) (float64, float64, error) {
+	peak, err := r.db.GetPeakPortfolioValue(r.exchangeName, baseCurrency)
+	if err != nil {
+		return 0, 0, err
+	}
+	netCashFlows, err := r.db.GetNetCashFlows(r.exchangeName, baseCurrency)
+	if err != nil {
+		return 0, 0, err
+	}
+	return peak + netCashFlows, netCashFlows, nil
+}
+
+// applyCashFlows moves the high water mark along with deposits and withdrawals, which change the
+// portfolio value without being a gain or loss. With a portfolio history the peak is rebuilt from it,
+// which also places transfers that were recorded late at the right point in time.
+func (r *RiskManagerActor) applyCashFlows(netCashFlows float64) {
+	delta := netCashFlows - r.netCashFlows
+	r.netCashFlows = netCashFlows
+
+	if r.db != nil {
+		latest, err := r.db.GetLatestPortfolioSnapshot(r.exchangeName)
+		if err != nil {
+			r.logger.Error().Err(err).Msg("Failed to load latest portfolio snapshot")
+		} else if latest != nil && latest.BaseCurrency == r.baseCurrency {
+			peak, _, err := r.loadPeak(r.baseCurrency)
+			if err == nil {
+				r.historicalPeak = peak
+				if r.valued {
+					r.highWaterMark = math.Max(peak, r.portfolioValue)
+				}
+				return
+			}
+			r.logger.Error().Err(err).Msg("Failed to load peak portfolio value")
+		}
+	}
+
+	r.historicalPeak += delta
+	if r.valued {
+		r.highWaterMark += delta
+	}
+}
+
 func (r *RiskManagerActor) onStopped(ctx *actor.Context) {
 	r.logger.Info().
 		Str("exchange", r.exchangeName).
@@ -228,11 +392,12 @@ func (r *RiskManagerActor) onStopped(ctx *actor.Context) {
 }
 
 func (r *RiskManagerActor) onValidateOrder(ctx *actor.Context, msg ValidateOrderMsg) {
+	r.resolvePositionSize(ctx, msg.Strategy, msg.Symbol)
 	response := r.validateOrder(msg)
 
 	if response.Approved {
 		// Record the order in history
-		orderValue := msg.Quantity * msg.Price
+		orderValue := r.orderValue(msg)
 		r.orderHistory = append(r.orderHistory, OrderHistory{
 			Timestamp: time.Now(),
 			Exchange:  msg.Exchange,
@@ -250,6 +415,9 @@ func (r *RiskManagerActor) onValidateOrder(ctx *actor.Context, msg ValidateOrder
 		today := time.Now().Format("2006-01-02")
 		r.dailyVolume[today] += orderValue
 
+		// Count the order against account-wide exposure until the aggregator reports the fill
+		r.recordAccountExposure(msg, orderValue)
+
 		r.logger.Info().
 			Str("exchange", r.exchangeName).
 			Str("symbol", msg.Symbol).
@@ -265,22 +433,59 @@ func (r *RiskManagerActor) onValidateOrder(ctx *actor.Context, msg ValidateOrder
 			Str("side", msg.Side).
 			Float64("quantity", msg.Quantity).
 			Float64("price", msg.Price).
+			Str("check", response.Check).
 			Str("reason", response.Reason).
 			Msg("Order rejected by risk management")
+		riskRejections.With(r.exchangeName, response.Check).Inc()
+
+		notifier.Publish(ctx.Engine(), notifier.Event{
+			Type:     notifier.EventRiskRejected,
+			Severity: notifier.SeverityWarning,
+			Title:    fmt.Sprintf("Order rejected: %s %g %s", msg.Side, msg.Quantity, msg.Symbol),
+			Message:  response.Reason,
+			Exchange: r.exchangeName,
+			Symbol:   msg.Symbol,
+			Fields: map[string]interface{}{
+				"check":    response.Check,
+				"side":     msg.Side,
+				"quantity": msg.Quantity,
+				"price":    msg.Price,
+			},
+		})
 	}
 
+	audit.Record(ctx.Engine(), audit.Entry{
+		Type:     audit.TypeRiskCheck,
+		Exchange: r.exchangeName,
+		Symbol:   msg.Symbol,
+		Strategy: msg.Strategy,
+		Actor:    "risk_manager",
+		Message:  fmt.Sprintf("%s %g %s approved=%t", msg.Side, msg.Quantity, msg.Symbol, response.Approved),
+		Data: map[string]interface{}{
+			"side":     msg.Side,
+			"quantity": msg.Quantity,
+			"price":    msg.Price,
+			"value":    r.orderValue(msg),
+			"approved": response.Approved,
+			"check":    response.Check,
+			"reason":   response.Reason,
+			"warnings": response.Warnings,
+		},
+	})
+
 	ctx.Respond(response)
 }
 
 func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationResponse {
 	var warnings []string
-	orderValue := msg.Quantity * msg.Price
+	orderValue := r.orderValue(msg)
 
 	// Check 1: Position size limit
-	maxPositionValue := r.portfolioValue * r.config.Risk.MaxPositionSize
+	maxPositionValue := r.portfolioValue * r.maxPositionSize(msg.Strategy, msg.Symbol)
 	if orderValue > maxPositionValue {
 		return OrderValidationResponse{
 			Approved: false,
+			Check:    CheckPositionSize,
 			Reason:   fmt.Sprintf("Order value %.2f exceeds max position size limit %.2f", orderValue, maxPositionValue),
 		}
 	}
@@ -293,6 +498,7 @@ func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationRe
 	if todayVolume+orderValue > maxDailyVolume {
 		return OrderValidationResponse{
 			Approved: false,
+			Check:    CheckDailyVolume,
 			Reason:   fmt.Sprintf("Order would exceed daily volume limit. Current: %.2f, Limit: %.2f", todayVolume+orderValue, maxDailyVolume),
 		}
 	}
@@ -301,6 +507,7 @@ func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationRe
 	if msg.Side == "buy" && orderValue > r.cash {
 		return OrderValidationResponse{
 			Approved: false,
+			Check:    CheckInsufficientCash,
 			Reason:   fmt.Sprintf("Insufficient cash. Required: %.2f, Available: %.2f", orderValue, r.cash),
 		}
 	}
@@ -310,6 +517,7 @@ func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationRe
 	if r.dailyRiskUsed+orderValue > maxDailyRisk {
 		return OrderValidationResponse{
 			Approved: false,
+			Check:    CheckDailyRisk,
 			Reason:   fmt.Sprintf("Order would exceed daily risk limit. Current: %.2f, Limit: %.2f", r.dailyRiskUsed+orderValue, maxDailyRisk),
 		}
 	}
@@ -319,10 +527,22 @@ func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationRe
 	if currentDrawdown > r.config.Risk.MaxDrawdown {
 		return OrderValidationResponse{
 			Approved: false,
+			Check:    CheckDrawdown,
 			Reason:   fmt.Sprintf("Current drawdown %.2f%% exceeds maximum allowed %.2f%%", currentDrawdown*100, r.config.Risk.MaxDrawdown*100),
 		}
 	}
 
+	// Check 6: Account-wide limits across all exchanges
+	if msg.Side == "buy" {
+		if check, reason := r.checkAccountLimits(msg, orderValue); reason != "" {
+			return OrderValidationResponse{
+				Approved: false,
+				Check:    check,
+				Reason:   reason,
+			}
+		}
+	}
+
 	// Warning checks
 	if orderValue > maxPositionValue*0.8 {
 		warnings = append(warnings, "Order size is close to position limit")
@@ -342,9 +562,42 @@ func (r *RiskManagerActor) validateOrder(msg ValidateOrderMsg) OrderValidationRe
 	}
 }
 
+// orderValue returns the order notional in the portfolio base currency.
+// Without a rate for the quote asset the notional is used as is.
+func (r *RiskManagerActor) orderValue(msg ValidateOrderMsg) float64 {
+	value := msg.Quantity * msg.Price
+
+	if pair, ok := valuation.ParseSymbol(msg.Symbol); ok {
+		if rate, ok := r.rates[pair.Quote]; ok {
+			return value * rate
+		}
+	}
+	return value
+}
+
 func (r *RiskManagerActor) onUpdatePortfolioValue(ctx *actor.Context, msg UpdatePortfolioValueMsg) {
+	// A peak recorded in another base currency cannot be compared
+	if msg.BaseCurrency != r.baseCurrency {
+		r.historicalPeak = 0
+	}
+
 	r.portfolioValue = msg.TotalValue
 	r.cash = msg.Cash
+	r.baseCurrency = msg.BaseCurrency
+	if msg.Rates != nil {
+		r.rates = msg.Rates
+	}
+	if msg.NetCashFlows != r.netCashFlows {
+		r.applyCashFlows(msg.NetCashFlows)
+	}
+
+	// The first real valuation replaces the startup defaults, which must not count as a drawdown.
+	// The recorded peak is kept so a loss from before a restart still counts.
+	if !r.valued {
+		r.valued = true
+		r.highWaterMark = math.Max(r.historicalPeak, r.portfolioValue)
+		r.maxDrawdown = 0
+	}
 
 	// Update high water mark and drawdown
 	if r.portfolioValue > r.highWaterMark {
@@ -355,9 +608,19 @@ func (r *RiskManagerActor) onUpdatePortfolioValue(ctx *actor.Context, msg Update
 	if currentDrawdown > r.maxDrawdown {
 		r.maxDrawdown = currentDrawdown
 	}
+	portfolioDrawdown.With(r.exchangeName).Set(currentDrawdown)
+
+	// The drawdown check halts all trading, announce when it trips and when it clears
+	if halted := currentDrawdown > r.config.Risk.MaxDrawdown; halted != r.halted {
+		r.halted = halted
+		if ctx != nil {
+			r.publishKillSwitch(ctx, currentDrawdown)
+		}
+	}
 
 	r.logger.Debug().
 		Str("exchange", r.exchangeName).
+		Str("base_currency", r.baseCurrency).
 		Float64("portfolio_value", r.portfolioValue).
 		Float64("cash", r.cash).
 		Float64("high_water_mark", r.highWaterMark).
@@ -366,6 +629,90 @@ func (r *RiskManagerActor) onUpdatePortfolioValue(ctx *actor.Context, msg Update
 		Msg("Portfolio value updated")
 }
 
+// publishKillSwitch notifies that the drawdown limit started or stopped rejecting all orders
+func (r *RiskManagerActor) publishKillSwitch(ctx *actor.Context, drawdown float64) {
+	event := notifier.Event{
+		Type:     notifier.EventKillSwitch,
+		Severity: notifier.SeverityInfo,
+		Title:    fmt.Sprintf("Trading resumed on %s", r.exchangeName),
+		Message:  fmt.Sprintf("Drawdown %.2f%% is back within the %.2f%% limit", drawdown*100, r.config.Risk.MaxDrawdown*100),
+		Exchange: r.exchangeName,
+		Fields: map[string]interface{}{
+			"state":           "reset",
+			"drawdown":        drawdown,
+			"max_drawdown":    r.config.Risk.MaxDrawdown,
+			"high_water_mark": r.highWaterMark,
+			"portfolio_value": r.portfolioValue,
+		},
+	}
+	if r.halted {
+		event.Severity = notifier.SeverityCritical
+		event.Title = fmt.Sprintf("Trading halted on %s", r.exchangeName)
+		event.Message = fmt.Sprintf("Drawdown %.2f%% exceeds the %.2f%% limit, all orders are rejected", drawdown*100, r.config.Risk.MaxDrawdown*100)
+		event.Fields["state"] = "tripped"
+	}
+
+	notifier.Publish(ctx.Engine(), event)
+}
+
+func (r *RiskManagerActor) onUpdateAccountExposure(ctx *actor.Context, msg UpdateAccountExposureMsg) {
+	if msg.Exposure != nil {
+		r.accountExposure = msg.Exposure
+	}
+	if msg.Values != nil {
+		r.accountValues = msg.Values
+	}
+	r.accountValue = msg.TotalValue
+
+	r.logger.Debug().
+		Str("exchange", r.exchangeName).
+		Int("assets", len(r.accountExposure)).
+		Float64("account_value", r.accountValue).
+		Msg("Account exposure updated")
+}
+
+// checkAccountLimits returns the failed check and a rejection reason when a buy would breach an account-wide limit
+func (r *RiskManagerActor) checkAccountLimits(msg ValidateOrderMsg, orderValue float64) (string, string) {
+	limits := r.config.Risk.Account
+
+	pair, ok := valuation.ParseSymbol(msg.Symbol)
+	if !ok {
+		return "", ""
+	}
+
+	if maxExposure := limits.MaxAssetExposure[pair.Base]; maxExposure > 0 {
+		exposure := r.accountExposure[pair.Base] + msg.Quantity
+		if exposure > maxExposure {
+			return CheckAccountExposure, fmt.Sprintf("Order would raise account-wide %s exposure to %.8f, limit %.8f", pair.Base, exposure, maxExposure)
+		}
+	}
+
+	if limits.MaxConcentration > 0 && r.accountValue > 0 && !valuation.IsCash(pair.Base) {
+		concentration := (r.accountValues[pair.Base] + orderValue) / r.accountValue
+		if concentration > limits.MaxConcentration {
+			return CheckAccountConcentration, fmt.Sprintf("Order would raise account-wide %s concentration to %.2f%%, limit %.2f%%", pair.Base, concentration*100, limits.MaxConcentration*100)
+		}
+	}
+
+	return "", ""
+}
+
+// recordAccountExposure applies an approved order to the account-wide exposure
+func (r *RiskManagerActor) recordAccountExposure(msg ValidateOrderMsg, orderValue float64) {
+	pair, ok := valuation.ParseSymbol(msg.Symbol)
+	if !ok {
+		return
+	}
+
+	if msg.Side == "sell" {
+		r.accountExposure[pair.Base] = math.Max(0, r.accountExposure[pair.Base]-msg.Quantity)
+		r.accountValues[pair.Base] = math.Max(0, r.accountValues[pair.Base]-orderValue)
+		return
+	}
+	r.accountExposure[pair.Base] += msg.Quantity
+	r.accountValues[pair.Base] += orderValue
+}
+
 func (r *RiskManagerActor) onGetRiskMetrics(ctx *actor.Context) {
 	positionConcentration := r.calculatePositionConcentration()
 	leverageRatio := r.calculateLeverageRatio()
@@ -387,6 +734,7 @@ func (r *RiskManagerActor) onStatus(ctx *actor.Context) {
 	status := map[string]interface{}{
 		"exchange":        r.exchangeName,
 		"timestamp":       time.Now(),
+		"base_currency":   r.baseCurrency,
 		"portfolio_value": r.portfolioValue,
 		"cash":            r.cash,
 		"max_drawdown":    r.maxDrawdown,
@@ -536,8 +884,11 @@ func (r *RiskManagerActor) onSetRiskParameter(ctx *actor.Context, msg SetRiskPar
 	// Store in settings actor
 	settingKey := fmt.Sprintf("risk.%s", msg.Key)
 	settingMsg := settings.SetSettingMsg{
-		Key:   settingKey,
-		Value: msg.Value,
+		Key:      settingKey,
+		Value:    msg.Value,
+		Strategy: msg.Strategy,
+		Symbol:   msg.Symbol,
+		Actor:    msg.Actor,
 	}
 
 	result, err := ctx.Request(r.settingsPID, settingMsg, 5*time.Second).Result()
@@ -553,11 +904,12 @@ func (r *RiskManagerActor) onSetRiskParameter(ctx *actor.Context, msg SetRiskPar
 		return
 	}
 
-	// Update local config
-	r.updateLocalRiskConfig(msg.Key, msg.Value)
+	// Reload rather than update the local config, a symbol override or inherited value may apply
+	r.loadRiskConfigFromSettings(ctx)
 
 	r.logger.Info().
 		Str("parameter", msg.Key).
+		Str("symbol", msg.Symbol).
 		Interface("value", msg.Value).
 		Msg("Risk parameter updated")
 
@@ -638,34 +990,44 @@ func (r *RiskManagerActor) loadRiskConfigFromSettings(ctx *actor.Context) {
 
 	r.logger.Info().Msg("Loading risk configuration from settings")
 
-	// Load each risk parameter
-	parameters := []string{
-		"max_position_size",
-		"max_daily_loss",
-		"max_portfolio_risk",
-		"max_correlation",
-		"max_leverage",
-		"max_daily_trades",
-		"max_hourly_trades",
-		"var_limit",
-		"max_drawdown_limit",
-		"concentration_limit",
-	}
+	// Load each risk parameter, values not set anywhere are the schema defaults
+	for _, definition := range settings.Definitions() {
+		param, ok := strings.CutPrefix(definition.Key, "risk.")
+		if !ok {
+			continue
+		}
 
-	for _, param := range parameters {
-		settingKey := fmt.Sprintf("risk.%s", param)
-		settingMsg := settings.GetSettingMsg{Key: settingKey}
+		result, err := ctx.Request(r.settingsPID, settings.GetSettingMsg{Key: definition.Key}, 5*time.Second).Result()
+		if err != nil {
+			r.logger.Error().Err(err).Str("parameter", param).Msg("Failed to load risk parameter")
+			continue
+		}
 
+		if settingResp, ok := result.(settings.SettingResponse); ok && settingResp.Typed != nil {
+			r.updateLocalRiskConfig(param, settingResp.Typed)
+			if param == "max_position_size" {
+				r.positionSized = settingResp.Found
+			}
+		}
+	}
+
+	// Position sizes set for a single traded symbol
+	positionSizes := make(map[string]float64)
+	for _, pair := range r.config.Exchanges[r.exchangeName].Pairs {
+		settingMsg := settings.GetSettingMsg{Key: "risk.max_position_size", Symbol: pair.Symbol}
 		result, err := ctx.Request(r.settingsPID, settingMsg, 5*time.Second).Result()
 		if err != nil {
-			r.logger.Error().Err(err).Str("parameter", param).Msg("Failed to load risk parameter")
+			r.logger.Error().Err(err).Str("symbol", pair.Symbol).Msg("Failed to load position size")
 			continue
 		}
 
-		if settingResp, ok := result.(settings.SettingResponse); ok && settingResp.Found {
-			r.updateLocalRiskConfigFromString(param, settingResp.Value)
+		if settingResp, ok := result.(settings.SettingResponse); ok && settingResp.Scope.Symbol != "" {
+			if size, ok := settingResp.Typed.(float64); ok {
+				positionSizes[pair.Symbol] = size
+			}
 		}
 	}
+	r.positionSizes = positionSizes
 
 	r.logger.Info().Msg("Risk configuration loaded from settings")
 }
@@ -781,6 +1143,53 @@ func (r *RiskManagerActor) updateLocalRiskConfigFromString(parameter, value stri
 	}
 }
 
+// maxPositionSize returns the position size limit of an order, set for its strategy, symbol, exchange or
+// globally in the settings, or the limit in config.yaml
+func (r *RiskManagerActor) maxPositionSize(strategy, symbol string) float64 {
+	if size, ok := r.positionSizes[positionSizeKey(strategy, symbol)]; ok {
+		return size
+	}
+	if size, ok := r.positionSizes[symbol]; ok {
+		return size
+	}
+	if r.positionSized {
+		return r.riskConfig.MaxPositionSize
+	}
+	return r.config.Risk.MaxPositionSize
+}
+
+// positionSizeKey keys positionSizes by symbol, or by strategy and symbol for orders of a strategy
+func positionSizeKey(strategy, symbol string) string {
+	if strategy == "" {
+		return symbol
+	}
+	return strategy + ":" + symbol
+}
+
+// resolvePositionSize looks up the position size set for the strategy of an order, or for its symbol
+// within the strategy, so validateOrder applies it. Strategies can be deployed at any time, so they are
+// resolved per order rather than loaded with the settings.
+func (r *RiskManagerActor) resolvePositionSize(ctx *actor.Context, strategy, symbol string) {
+	if r.settingsPID == nil || strategy == "" {
+		return
+	}
+
+	settingMsg := settings.GetSettingMsg{Key: "risk.max_position_size", Strategy: strategy, Symbol: symbol}
+	result, err := ctx.Request(r.settingsPID, settingMsg, 5*time.Second).Result()
+	if err != nil {
+		r.logger.Error().Err(err).Str("strategy", strategy).Str("symbol", symbol).Msg("Failed to load position size")
+		return
+	}
+
+	key := positionSizeKey(strategy, symbol)
+	delete(r.positionSizes, key)
+	if settingResp, ok := result.(settings.SettingResponse); ok && (settingResp.Scope.Strategy != "" || settingResp.Scope.Symbol != "") {
+		if size, ok := settingResp.Typed.(float64); ok {
+			r.positionSizes[key] = size
+		}
+	}
+}
+
 // getLocalRiskParameter gets a risk parameter from local configuration
 func (r *RiskManagerActor) getLocalRiskParameter(parameter string) interface{} {
 	switch parameter {
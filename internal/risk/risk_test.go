@@ -3,11 +3,14 @@ package risk
 import (
 	"math"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/settings"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 )
@@ -284,6 +287,9 @@ func TestValidateOrder(t *testing.T) {
 		if response.Approved {
 			t.Error("expected order to be rejected for exceeding position size limit")
 		}
+		if response.Check != CheckPositionSize {
+			t.Errorf("expected check %s, got %q", CheckPositionSize, response.Check)
+		}
 		if response.Reason == "" {
 			t.Error("expected rejection reason to be provided")
 		}
@@ -325,6 +331,9 @@ func TestValidateOrder(t *testing.T) {
 		if response.Approved {
 			t.Error("expected order to be rejected for exceeding daily risk limit")
 		}
+		if response.Check != CheckDailyRisk {
+			t.Errorf("expected check %s, got %q", CheckDailyRisk, response.Check)
+		}
 		if response.Reason == "" {
 			t.Error("expected rejection reason to be provided")
 		}
@@ -740,4 +749,357 @@ func TestUpdateLocalRiskConfigFromString(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestSymbolPositionSizeFromSettings(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+	cfg := &config.Config{
+		Risk: config.RiskConfig{MaxPositionSize: 0.1, MaxDailyVolume: 2.0, MaxDailyRisk: 0.2, MaxDrawdown: 0.15},
+		Exchanges: map[string]config.ExchangeConfig{
+			"test_exchange": {Enabled: true, Pairs: []config.PairConfig{{Symbol: "BTCUSDT"}, {Symbol: "ETHUSDT"}}},
+		},
+	}
+	symbol := settings.Scope{Exchange: "test_exchange", Symbol: "BTCUSDT"}
+	if _, err := settings.NewStore(db).Set(symbol, "risk.max_position_size", "0.01", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	settingsPID := engine.Spawn(func() actor.Receiver { return settings.New("test_exchange", cfg, db, zerolog.Nop()) }, "settings")
+	riskPID := engine.Spawn(func() actor.Receiver {
+		riskManager := New("test_exchange", cfg, db, zerolog.Nop())
+		riskManager.SetSettingsActor(settingsPID)
+		return riskManager
+	}, "risk")
+	defer func() { <-engine.Poison(riskPID).Done() }()
+	defer func() { <-engine.Poison(settingsPID).Done() }()
+
+	validate := func(symbol string) OrderValidationResponse {
+		t.Helper()
+		msg := ValidateOrderMsg{Exchange: "test_exchange", Symbol: symbol, Side: "buy", Quantity: 1, Price: 5000}
+		response, err := engine.Request(riskPID, msg, 5*time.Second).Result()
+		if err != nil {
+			t.Fatalf("validation request failed: %v", err)
+		}
+		return response.(OrderValidationResponse)
+	}
+
+	// 5000 of a 100000 portfolio exceeds the 1% set for BTCUSDT but not the 10% of config.yaml
+	if response := validate("BTCUSDT"); response.Approved || response.Check != CheckPositionSize {
+		t.Errorf("expected BTCUSDT to be rejected on position size, got %+v", response)
+	}
+	if response := validate("ETHUSDT"); !response.Approved {
+		t.Errorf("expected ETHUSDT to be approved, got %+v", response)
+	}
+
+	setMsg := SetRiskParameterMsg{Key: "max_position_size", Value: "0.02", Symbol: "ETHUSDT", Actor: "test"}
+	if response, err := engine.Request(riskPID, setMsg, 5*time.Second).Result(); err != nil || response != "OK" {
+		t.Fatalf("expected the parameter to be set, got %v (%v)", response, err)
+	}
+	if response := validate("ETHUSDT"); response.Approved {
+		t.Errorf("expected ETHUSDT to be rejected after its limit was set, got %+v", response)
+	}
+}
+
+func TestStrategyPositionSizeFromSettings(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+	cfg := &config.Config{
+		Risk: config.RiskConfig{MaxPositionSize: 0.1, MaxDailyVolume: 2.0, MaxDailyRisk: 0.2, MaxDrawdown: 0.15},
+		Exchanges: map[string]config.ExchangeConfig{
+			"test_exchange": {Enabled: true, Pairs: []config.PairConfig{{Symbol: "BTCUSDT"}}},
+		},
+	}
+	store := settings.NewStore(db)
+	if _, err := store.Set(settings.Scope{Exchange: "test_exchange", Strategy: "sma"}, "risk.max_position_size", "0.01", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set(settings.Scope{Exchange: "test_exchange", Symbol: "SOLUSDT"}, "risk.max_position_size", "0.01", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	settingsPID := engine.Spawn(func() actor.Receiver { return settings.New("test_exchange", cfg, db, zerolog.Nop()) }, "settings")
+	riskPID := engine.Spawn(func() actor.Receiver {
+		riskManager := New("test_exchange", cfg, db, zerolog.Nop())
+		riskManager.SetSettingsActor(settingsPID)
+		return riskManager
+	}, "risk")
+	defer func() { <-engine.Poison(riskPID).Done() }()
+	defer func() { <-engine.Poison(settingsPID).Done() }()
+
+	approved := func(strategy, symbol string) bool {
+		t.Helper()
+		msg := ValidateOrderMsg{Exchange: "test_exchange", Symbol: symbol, Side: "buy", Quantity: 1, Price: 5000, Strategy: strategy}
+		response, err := engine.Request(riskPID, msg, 5*time.Second).Result()
+		if err != nil {
+			t.Fatalf("validation request failed: %v", err)
+		}
+		return response.(OrderValidationResponse).Approved
+	}
+
+	// 5000 of a 100000 portfolio exceeds the 1% set for sma but not the 10% of config.yaml
+	if approved("sma", "ETHUSDT") {
+		t.Error("expected the sma order to be rejected on its strategy limit")
+	}
+	if !approved("", "ETHUSDT") || !approved("rsi", "ETHUSDT") {
+		t.Error("expected orders of other strategies and manual orders to be approved")
+	}
+	// A symbol limit applies to every strategy trading it, including symbols outside the configured pairs
+	if approved("rsi", "SOLUSDT") {
+		t.Error("expected the rsi order to be rejected on the SOLUSDT limit")
+	}
+
+	setMsg := SetRiskParameterMsg{Key: "max_position_size", Value: "0.2", Strategy: "sma", Symbol: "ETHUSDT", Actor: "test"}
+	if response, err := engine.Request(riskPID, setMsg, 5*time.Second).Result(); err != nil || response != "OK" {
+		t.Fatalf("expected the parameter to be set, got %v (%v)", response, err)
+	}
+	if !approved("sma", "ETHUSDT") {
+		t.Error("expected the sma order to be approved after its ETHUSDT limit was raised")
+	}
+}
+
+func TestExchangePositionSizeFromSettings(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+	cfg := &config.Config{
+		Risk: config.RiskConfig{MaxPositionSize: 0.1, MaxDailyVolume: 2.0, MaxDailyRisk: 0.2, MaxDrawdown: 0.15},
+		Exchanges: map[string]config.ExchangeConfig{
+			"test_exchange": {Enabled: true, Pairs: []config.PairConfig{{Symbol: "BTCUSDT"}}},
+		},
+	}
+	store := settings.NewStore(db)
+	if _, err := store.Set(settings.Scope{Exchange: "test_exchange"}, "risk.max_position_size", "0.01", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set(settings.Scope{Exchange: "test_exchange", Symbol: "ETHUSDT"}, "risk.max_position_size", "0.1", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	settingsPID := engine.Spawn(func() actor.Receiver { return settings.New("test_exchange", cfg, db, zerolog.Nop()) }, "settings")
+	riskPID := engine.Spawn(func() actor.Receiver {
+		riskManager := New("test_exchange", cfg, db, zerolog.Nop())
+		riskManager.SetSettingsActor(settingsPID)
+		return riskManager
+	}, "risk")
+	defer func() { <-engine.Poison(riskPID).Done() }()
+	defer func() { <-engine.Poison(settingsPID).Done() }()
+
+	approved := func(symbol string) bool {
+		t.Helper()
+		msg := ValidateOrderMsg{Exchange: "test_exchange", Symbol: symbol, Side: "buy", Quantity: 1, Price: 5000, Strategy: "sma"}
+		response, err := engine.Request(riskPID, msg, 5*time.Second).Result()
+		if err != nil {
+			t.Fatalf("validation request failed: %v", err)
+		}
+		return response.(OrderValidationResponse).Approved
+	}
+
+	// 5000 of a 100000 portfolio exceeds the 1% set for the exchange but not the 10% of config.yaml
+	if approved("BTCUSDT") {
+		t.Error("expected the BTCUSDT order to be rejected on the exchange limit")
+	}
+	if !approved("ETHUSDT") {
+		t.Error("expected the ETHUSDT order to be approved on its symbol limit")
+	}
+
+	setMsg := SetRiskParameterMsg{Key: "max_position_size", Value: "0.2", Actor: "test"}
+	if response, err := engine.Request(riskPID, setMsg, 5*time.Second).Result(); err != nil || response != "OK" {
+		t.Fatalf("expected the parameter to be set, got %v (%v)", response, err)
+	}
+	if !approved("BTCUSDT") {
+		t.Error("expected the BTCUSDT order to be approved after the exchange limit was raised")
+	}
+}
+
+func TestUpdatePortfolioValueUsesValuation(t *testing.T) {
+	riskManager, db := setupTestRiskManager(t)
+	defer db.Close()
+
+	// Startup defaults, replaced by the first real valuation
+	riskManager.portfolioValue = 100000.0
+	riskManager.highWaterMark = 100000.0
+
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{
+		TotalValue:   20000.0,
+		Cash:         10000.0,
+		BaseCurrency: "EUR",
+		Rates:        map[string]float64{"EUR": 1.0, "USDT": 0.9},
+	})
+
+	if riskManager.highWaterMark != 20000.0 || riskManager.maxDrawdown != 0 {
+		t.Errorf("expected first valuation to reset high water mark, got %f (drawdown %f)", riskManager.highWaterMark, riskManager.maxDrawdown)
+	}
+
+	// Later valuations are measured against the high water mark
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 18000.0, Cash: 8000.0, BaseCurrency: "EUR"})
+	if riskManager.maxDrawdown < 0.099 || riskManager.maxDrawdown > 0.101 {
+		t.Errorf("expected 10%% drawdown, got %f", riskManager.maxDrawdown)
+	}
+
+	// A USDT quoted order is converted into the EUR base currency
+	msg := ValidateOrderMsg{Exchange: "test_exchange", Symbol: "BTCUSDT", Side: "buy", Quantity: 0.01, Price: 100000.0}
+	if value := riskManager.orderValue(msg); value != 900.0 {
+		t.Errorf("expected order value 900 EUR, got %f", value)
+	}
+
+	// Without a rate the notional is used as is
+	msg.Symbol = "BTC-GBP"
+	if value := riskManager.orderValue(msg); value != 1000.0 {
+		t.Errorf("expected unconverted order value 1000, got %f", value)
+	}
+}
+
+func TestAccountWideLimits(t *testing.T) {
+	riskManager, db := setupTestRiskManager(t)
+	defer db.Close()
+
+	riskManager.config.Risk.Account = config.AccountRiskConfig{
+		MaxAssetExposure: map[string]float64{"BTC": 1.0},
+		MaxConcentration: 0.5,
+	}
+	riskManager.portfolioValue = 1000000.0
+	riskManager.cash = 1000000.0
+	riskManager.highWaterMark = 1000000.0
+
+	// 0.9 BTC is already held across all exchanges
+	riskManager.onUpdateAccountExposure(nil, UpdateAccountExposureMsg{
+		Exposure:   map[string]float64{"BTC": 0.9, "USDT": 100000},
+		Values:     map[string]float64{"BTC": 45000, "USDT": 100000},
+		TotalValue: 145000,
+	})
+
+	buy := ValidateOrderMsg{Exchange: "test_exchange", Symbol: "BTCUSDT", Side: "buy", Quantity: 0.2, Price: 50000}
+	if response := riskManager.validateOrder(buy); response.Approved || !strings.Contains(response.Reason, "exposure") {
+		t.Errorf("expected buy beyond account-wide BTC exposure to be rejected, got %+v", response)
+	}
+
+	buy.Quantity = 0.05
+	if response := riskManager.validateOrder(buy); !response.Approved {
+		t.Errorf("expected small buy to be approved, got %s", response.Reason)
+	}
+
+	// Sells reduce exposure and are never blocked by account limits
+	sell := ValidateOrderMsg{Exchange: "test_exchange", Symbol: "BTCUSDT", Side: "sell", Quantity: 0.5, Price: 50000}
+	if response := riskManager.validateOrder(sell); !response.Approved {
+		t.Errorf("expected sell to be approved, got %s", response.Reason)
+	}
+	riskManager.recordAccountExposure(sell, 25000)
+	if exposure := riskManager.accountExposure["BTC"]; exposure < 0.3999 || exposure > 0.4001 {
+		t.Errorf("expected exposure 0.4 after selling, got %f", exposure)
+	}
+
+	// Concentration: 20000 BTC + 60000 order out of 145000 exceeds 50%
+	buy = ValidateOrderMsg{Exchange: "test_exchange", Symbol: "BTCUSDT", Side: "buy", Quantity: 0.5, Price: 120000}
+	if response := riskManager.validateOrder(buy); response.Approved || !strings.Contains(response.Reason, "concentration") {
+		t.Errorf("expected buy beyond account-wide concentration to be rejected, got %+v", response)
+	}
+}
+
+func TestHighWaterMarkSeededFromHistory(t *testing.T) {
+	riskManager, db := setupTestRiskManager(t)
+	defer db.Close()
+
+	start := time.Now().Add(-time.Hour)
+	for i, value := range []float64{10000.0, 12000.0, 11000.0} {
+		err := db.SavePortfolioSnapshot(&database.PortfolioSnapshot{
+			Exchange:     "test_exchange",
+			BaseCurrency: "USDT",
+			TotalValue:   value,
+			Cash:         5000.0,
+			CreatedAt:    start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+	}
+
+	riskManager.loadHistory()
+	if riskManager.highWaterMark != 12000.0 || riskManager.portfolioValue != 11000.0 {
+		t.Fatalf("expected baseline from history, got hwm %f value %f", riskManager.highWaterMark, riskManager.portfolioValue)
+	}
+
+	// The first valuation after a restart keeps the recorded peak
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 10800.0, Cash: 5000.0, BaseCurrency: "USDT"})
+	if riskManager.highWaterMark != 12000.0 {
+		t.Errorf("expected high water mark 12000, got %f", riskManager.highWaterMark)
+	}
+	if riskManager.maxDrawdown < 0.099 || riskManager.maxDrawdown > 0.101 {
+		t.Errorf("expected 10%% drawdown from the recorded peak, got %f", riskManager.maxDrawdown)
+	}
+}
+
+func TestHighWaterMarkAdjustedForCashFlows(t *testing.T) {
+	riskManager, db := setupTestRiskManager(t)
+	defer db.Close()
+
+	start := time.Now().Add(-time.Hour)
+	snapshot := func(value float64, at time.Time) {
+		err := db.SavePortfolioSnapshot(&database.PortfolioSnapshot{Exchange: "test_exchange", BaseCurrency: "USDT", TotalValue: value, CreatedAt: at})
+		if err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+	}
+	flow := func(id string, value float64, at time.Time) {
+		_, err := db.SaveCashFlow(&database.CashFlow{Exchange: "test_exchange", ExternalID: id, Asset: "USDT", Amount: value, Value: value, BaseCurrency: "USDT", Source: "manual", CreatedAt: at})
+		if err != nil {
+			t.Fatalf("failed to save cash flow: %v", err)
+		}
+	}
+
+	// A withdrawal of 3000 after the peak of 12000 is not a drawdown
+	snapshot(10000.0, start)
+	snapshot(12000.0, start.Add(time.Minute))
+	flow("withdrawal", -3000.0, start.Add(2*time.Minute))
+	snapshot(9000.0, start.Add(3*time.Minute))
+
+	riskManager.loadHistory()
+	if riskManager.highWaterMark != 9000.0 || riskManager.maxDrawdown != 0 {
+		t.Fatalf("expected high water mark 9000 without drawdown, got hwm %f drawdown %f", riskManager.highWaterMark, riskManager.maxDrawdown)
+	}
+
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 9000.0, BaseCurrency: "USDT", NetCashFlows: -3000.0})
+	if riskManager.highWaterMark != 9000.0 {
+		t.Errorf("expected high water mark 9000, got %f", riskManager.highWaterMark)
+	}
+
+	// A deposit raises the high water mark with the value
+	flow("deposit", 2000.0, time.Now())
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 11000.0, BaseCurrency: "USDT", NetCashFlows: -1000.0})
+	if riskManager.highWaterMark != 11000.0 || riskManager.maxDrawdown != 0 {
+		t.Errorf("expected high water mark 11000 without drawdown, got hwm %f drawdown %f", riskManager.highWaterMark, riskManager.maxDrawdown)
+	}
+
+	// Without a database the high water mark moves by the flow
+	riskManager.db = nil
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 10500.0, BaseCurrency: "USDT", NetCashFlows: -1500.0})
+	if riskManager.highWaterMark != 10500.0 || riskManager.maxDrawdown != 0 {
+		t.Errorf("expected high water mark 10500 without drawdown, got hwm %f drawdown %f", riskManager.highWaterMark, riskManager.maxDrawdown)
+	}
+}
+
+func TestHighWaterMarkIgnoresOtherBaseCurrency(t *testing.T) {
+	riskManager, db := setupTestRiskManager(t)
+	defer db.Close()
+
+	err := db.SavePortfolioSnapshot(&database.PortfolioSnapshot{Exchange: "test_exchange", BaseCurrency: "USDT", TotalValue: 12000.0})
+	if err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+
+	riskManager.loadHistory()
+	riskManager.onUpdatePortfolioValue(nil, UpdatePortfolioValueMsg{TotalValue: 10000.0, BaseCurrency: "EUR"})
+	if riskManager.highWaterMark != 10000.0 || riskManager.maxDrawdown != 0 {
+		t.Errorf("expected a fresh baseline after a base currency change, got hwm %f drawdown %f", riskManager.highWaterMark, riskManager.maxDrawdown)
+	}
+}
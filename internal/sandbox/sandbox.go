@@ -0,0 +1,155 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.starlark.net/starlark"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+// Violation kinds
+const (
+	ViolationSteps   = "step_budget"
+	ViolationTimeout = "timeout"
+	ViolationState   = "state_size"
+)
+
+// Limits bounds a single Starlark execution. Zero disables a limit.
+type Limits struct {
+	MaxSteps        uint64
+	Timeout         time.Duration
+	MaxStateEntries int
+	MaxViolations   int
+}
+
+// NewLimits creates limits from the strategies sandbox configuration
+func NewLimits(cfg config.SandboxConfig) Limits {
+	return Limits{
+		MaxSteps:        cfg.MaxSteps,
+		Timeout:         cfg.Timeout,
+		MaxStateEntries: cfg.MaxStateEntries,
+		MaxViolations:   cfg.MaxViolations,
+	}
+}
+
+// DefaultLimits returns the limits used when no configuration is available
+func DefaultLimits() Limits {
+	return Limits{
+		MaxSteps:        10_000_000,
+		Timeout:         5 * time.Second,
+		MaxStateEntries: 100_000,
+		MaxViolations:   3,
+	}
+}
+
+// ViolationError reports a script that exceeded one of its limits
+type ViolationError struct {
+	Kind string
+	Err  error
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("sandbox violation (%s): %v", e.Kind, e.Err)
+}
+
+func (e *ViolationError) Unwrap() error {
+	return e.Err
+}
+
+// IsViolation reports whether err was caused by a sandbox limit and returns the violation
+func IsViolation(err error) (*ViolationError, bool) {
+	var violation *ViolationError
+	if errors.As(err, &violation) {
+		return violation, true
+	}
+	return nil, false
+}
+
+// Run executes fn with the step budget and timeout applied to thread.
+// Errors caused by either limit are returned as a *ViolationError.
+func Run(thread *starlark.Thread, limits Limits, fn func() error) error {
+	if limits.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(limits.MaxSteps)
+	}
+
+	var timedOut atomic.Bool
+	if limits.Timeout > 0 {
+		timer := time.AfterFunc(limits.Timeout, func() {
+			timedOut.Store(true)
+			thread.Cancel(fmt.Sprintf("execution exceeded %s", limits.Timeout))
+		})
+		defer timer.Stop()
+	}
+
+	err := fn()
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case timedOut.Load():
+		return &ViolationError{Kind: ViolationTimeout, Err: err}
+	case limits.MaxSteps > 0 && thread.ExecutionSteps() >= limits.MaxSteps:
+		return &ViolationError{Kind: ViolationSteps, Err: err}
+	default:
+		return err
+	}
+}
+
+// CheckState returns a *ViolationError when state holds more than maxEntries values
+func CheckState(state starlark.Value, maxEntries int) error {
+	if maxEntries <= 0 || state == nil {
+		return nil
+	}
+
+	if entries := CountEntries(state, maxEntries+1); entries > maxEntries {
+		return &ViolationError{
+			Kind: ViolationState,
+			Err:  fmt.Errorf("state holds more than %d values", maxEntries),
+		}
+	}
+	return nil
+}
+
+// CountEntries counts a value and the elements nested in lists, tuples, dicts and sets.
+// Counting stops once limit is reached so huge states are not walked completely; limit <= 0 counts everything.
+func CountEntries(value starlark.Value, limit int) int {
+	count := 0
+	var walk func(v starlark.Value)
+	walk = func(v starlark.Value) {
+		if limit > 0 && count >= limit {
+			return
+		}
+		count++
+
+		switch x := v.(type) {
+		case *starlark.Dict:
+			for _, item := range x.Items() {
+				walk(item[0])
+				walk(item[1])
+			}
+		case *starlark.List:
+			for i := 0; i < x.Len(); i++ {
+				walk(x.Index(i))
+			}
+		case starlark.Tuple:
+			for _, elem := range x {
+				walk(elem)
+			}
+		case *starlark.Set:
+			iter := x.Iterate()
+			defer iter.Done()
+			var elem starlark.Value
+			for iter.Next(&elem) {
+				walk(elem)
+			}
+		}
+	}
+
+	walk(value)
+	return count
+}
@@ -0,0 +1,117 @@
+package sandbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+const runawayScript = `
+def spin():
+    total = 0
+    for i in range(1000000000):
+        total += i
+    return total
+`
+
+func runSpin(t *testing.T, limits Limits) error {
+	t.Helper()
+
+	thread := &starlark.Thread{Name: "test"}
+	globals, err := starlark.ExecFile(thread, "test.star", runawayScript, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Run(thread, limits, func() error {
+		_, callErr := starlark.Call(thread, globals["spin"], nil, nil)
+		return callErr
+	})
+}
+
+func TestRunStepBudget(t *testing.T) {
+	err := runSpin(t, Limits{MaxSteps: 10000})
+
+	violation, ok := IsViolation(err)
+	if !ok {
+		t.Fatalf("expected a sandbox violation, got %v", err)
+	}
+	if violation.Kind != ViolationSteps {
+		t.Errorf("expected %s violation, got %s", ViolationSteps, violation.Kind)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	start := time.Now()
+	err := runSpin(t, Limits{Timeout: 50 * time.Millisecond})
+
+	violation, ok := IsViolation(err)
+	if !ok {
+		t.Fatalf("expected a sandbox violation, got %v", err)
+	}
+	if violation.Kind != ViolationTimeout {
+		t.Errorf("expected %s violation, got %s", ViolationTimeout, violation.Kind)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("execution was not cancelled promptly, took %s", elapsed)
+	}
+}
+
+func TestRunPassesThroughOtherErrors(t *testing.T) {
+	thread := &starlark.Thread{Name: "test"}
+	scriptErr := errors.New("boom")
+
+	err := Run(thread, DefaultLimits(), func() error { return scriptErr })
+	if !errors.Is(err, scriptErr) {
+		t.Errorf("expected original error, got %v", err)
+	}
+	if _, ok := IsViolation(err); ok {
+		t.Error("expected a plain error not to be reported as a violation")
+	}
+
+	if err := Run(thread, DefaultLimits(), func() error { return nil }); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckState(t *testing.T) {
+	state := starlark.NewDict(2)
+	state.SetKey(starlark.String("prices"), starlark.NewList([]starlark.Value{
+		starlark.Float(1), starlark.Float(2), starlark.Float(3),
+	}))
+
+	// The dict, one key, the list and its three elements
+	if entries := CountEntries(state, 0); entries != 6 {
+		t.Errorf("expected 6 entries, got %d", entries)
+	}
+
+	if err := CheckState(state, 6); err != nil {
+		t.Errorf("unexpected error at the limit: %v", err)
+	}
+
+	violation, ok := IsViolation(CheckState(state, 5))
+	if !ok || violation.Kind != ViolationState {
+		t.Errorf("expected %s violation above the limit, got %v", ViolationState, violation)
+	}
+
+	if err := CheckState(state, 0); err != nil {
+		t.Errorf("expected no limit when max is zero, got %v", err)
+	}
+}
+
+func TestNewLimits(t *testing.T) {
+	limits := NewLimits(config.SandboxConfig{
+		MaxSteps:        100,
+		Timeout:         time.Second,
+		MaxStateEntries: 10,
+		MaxViolations:   2,
+	})
+
+	if limits.MaxSteps != 100 || limits.Timeout != time.Second || limits.MaxStateEntries != 10 || limits.MaxViolations != 2 {
+		t.Errorf("unexpected limits %+v", limits)
+	}
+}
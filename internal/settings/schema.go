@@ -0,0 +1,229 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a setting value
+type Type string
+
+const (
+	TypeFloat  Type = "float"
+	TypeInt    Type = "int"
+	TypeBool   Type = "bool"
+	TypeString Type = "string"
+)
+
+// Level is how specific the scope of a setting is
+type Level string
+
+const (
+	LevelGlobal   Level = "global"
+	LevelExchange Level = "exchange"
+	LevelStrategy Level = "strategy"
+	LevelSymbol   Level = "symbol"
+)
+
+// Definition describes a setting: the type, default, unit and range of its value and the scope levels
+// it may be set at
+type Definition struct {
+	Key         string   `json:"key"`
+	Type        Type     `json:"type"`
+	Default     string   `json:"default"`
+	Unit        string   `json:"unit,omitempty"`
+	Description string   `json:"description"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Levels      []Level  `json:"levels"`
+}
+
+func bound(value float64) *float64 {
+	return &value
+}
+
+var (
+	exchangeLevels = []Level{LevelGlobal, LevelExchange}
+	symbolLevels   = []Level{LevelGlobal, LevelExchange, LevelStrategy, LevelSymbol}
+)
+
+// definitions are the known settings, defaults match defaultRiskConfig of the risk manager.
+// Limits checked per order can be narrowed to a strategy or symbol; limits on the daily or total
+// activity of an account only apply to the whole exchange.
+var definitions = []Definition{
+	{Key: "risk.max_position_size", Type: TypeFloat, Default: "0.1", Unit: "share", Min: bound(0), Max: bound(1), Levels: symbolLevels,
+		Description: "Largest order value as a share of the portfolio value"},
+	{Key: "risk.max_daily_loss", Type: TypeFloat, Default: "1000", Unit: "base currency", Min: bound(0), Levels: exchangeLevels,
+		Description: "Largest loss allowed in one day"},
+	{Key: "risk.max_portfolio_risk", Type: TypeFloat, Default: "0.25", Unit: "share", Min: bound(0), Max: bound(1), Levels: exchangeLevels,
+		Description: "Largest share of the portfolio value at risk"},
+	{Key: "risk.max_correlation", Type: TypeFloat, Default: "0.7", Unit: "share", Min: bound(0), Max: bound(1), Levels: exchangeLevels,
+		Description: "Highest correlation allowed between positions"},
+	{Key: "risk.max_leverage", Type: TypeFloat, Default: "3", Unit: "x", Min: bound(0), Levels: exchangeLevels,
+		Description: "Highest leverage allowed"},
+	{Key: "risk.max_daily_trades", Type: TypeInt, Default: "20", Unit: "trades", Min: bound(0), Levels: exchangeLevels,
+		Description: "Most trades allowed in one day"},
+	{Key: "risk.max_hourly_trades", Type: TypeInt, Default: "5", Unit: "trades", Min: bound(0), Levels: exchangeLevels,
+		Description: "Most trades allowed in one hour"},
+	{Key: "risk.var_limit", Type: TypeFloat, Default: "0.05", Unit: "share", Min: bound(0), Max: bound(1), Levels: exchangeLevels,
+		Description: "Largest 95% value at risk as a share of the portfolio value"},
+	{Key: "risk.max_drawdown_limit", Type: TypeFloat, Default: "0.2", Unit: "share", Min: bound(0), Max: bound(1), Levels: exchangeLevels,
+		Description: "Largest drawdown from the high water mark"},
+	{Key: "risk.concentration_limit", Type: TypeFloat, Default: "0.3", Unit: "share", Min: bound(0), Max: bound(1), Levels: exchangeLevels,
+		Description: "Largest share of the portfolio value in one position"},
+}
+
+// Definitions returns the known settings by key
+func Definitions() []Definition {
+	result := make([]Definition, len(definitions))
+	copy(result, definitions)
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// Lookup returns the definition of a setting
+func Lookup(key string) (Definition, bool) {
+	for _, definition := range definitions {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Parse converts a value to the type of the setting, checking its range. Floats are float64 and ints int.
+func (d Definition) Parse(value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+
+	switch d.Type {
+	case TypeFloat:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%s must be a number, got %q", d.Key, value)
+		}
+		if err := d.checkRange(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case TypeInt:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, got %q", d.Key, value)
+		}
+		if err := d.checkRange(float64(v)); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case TypeBool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", d.Key, value)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+func (d Definition) checkRange(value float64) error {
+	if d.Min != nil && value < *d.Min {
+		return fmt.Errorf("%s must be at least %g, got %g", d.Key, *d.Min, value)
+	}
+	if d.Max != nil && value > *d.Max {
+		return fmt.Errorf("%s must be at most %g, got %g", d.Key, *d.Max, value)
+	}
+	return nil
+}
+
+// Allows reports whether the setting may be set at a scope level
+func (d Definition) Allows(level Level) bool {
+	for _, allowed := range d.Levels {
+		if allowed == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error when value is not a valid value of key at scope
+func Validate(scope Scope, key, value string) error {
+	definition, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	if err := scope.Validate(); err != nil {
+		return err
+	}
+	if !definition.Allows(scope.Level()) {
+		return fmt.Errorf("%s cannot be set per %s", key, scope.Level())
+	}
+	_, err := definition.Parse(value)
+	return err
+}
+
+// Scope is where a setting applies. No exchange is global, an exchange alone covers that exchange
+// account, and a strategy or symbol narrows it to that strategy or symbol on the exchange. A setting
+// that is not set at a scope is inherited from the next wider one: symbol, strategy, exchange, global.
+// A symbol traded by a strategy inherits from the symbol on the exchange before the strategy.
+type Scope struct {
+	Exchange string `json:"exchange,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+}
+
+// Level returns how specific the scope is
+func (s Scope) Level() Level {
+	switch {
+	case s.Symbol != "":
+		return LevelSymbol
+	case s.Strategy != "":
+		return LevelStrategy
+	case s.Exchange != "":
+		return LevelExchange
+	}
+	return LevelGlobal
+}
+
+// Validate returns an error for a strategy or symbol without an exchange
+func (s Scope) Validate() error {
+	if s.Exchange == "" && (s.Strategy != "" || s.Symbol != "") {
+		return fmt.Errorf("a %s scope needs an exchange", s.Level())
+	}
+	return nil
+}
+
+// Chain returns the scope followed by the wider scopes it inherits from, ending with the global scope
+func (s Scope) Chain() []Scope {
+	chain := []Scope{s}
+	if s.Strategy != "" && s.Symbol != "" {
+		chain = append(chain, Scope{Exchange: s.Exchange, Symbol: s.Symbol})
+	}
+	for s != (Scope{}) {
+		switch {
+		case s.Symbol != "":
+			s.Symbol = ""
+		case s.Strategy != "":
+			s.Strategy = ""
+		default:
+			s.Exchange = ""
+		}
+		chain = append(chain, s)
+	}
+	return chain
+}
+
+func (s Scope) String() string {
+	if s == (Scope{}) {
+		return string(LevelGlobal)
+	}
+	parts := []string{s.Exchange}
+	if s.Strategy != "" {
+		parts = append(parts, "strategy="+s.Strategy)
+	}
+	if s.Symbol != "" {
+		parts = append(parts, "symbol="+s.Symbol)
+	}
+	return strings.Join(parts, " ")
+}
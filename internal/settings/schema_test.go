@@ -0,0 +1,82 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefinitionParse(t *testing.T) {
+	size, _ := Lookup("risk.max_position_size")
+	if v, err := size.Parse(" 0.25 "); err != nil || v != 0.25 {
+		t.Errorf("expected 0.25, got %v (%v)", v, err)
+	}
+	for _, value := range []string{"abc", "1.5", "-0.1", "NaN"} {
+		if _, err := size.Parse(value); err == nil {
+			t.Errorf("expected %q to be refused", value)
+		}
+	}
+
+	trades, _ := Lookup("risk.max_daily_trades")
+	if v, err := trades.Parse("30"); err != nil || v != 30 {
+		t.Errorf("expected 30, got %v (%v)", v, err)
+	}
+	if _, err := trades.Parse("2.5"); err == nil {
+		t.Error("expected a fraction to be refused for an int setting")
+	}
+
+	// Every default is a valid value of its setting
+	for _, definition := range Definitions() {
+		if _, err := definition.Parse(definition.Default); err != nil {
+			t.Errorf("invalid default of %s: %v", definition.Key, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope Scope
+		key   string
+		value string
+		valid bool
+	}{
+		{"global", Scope{}, "risk.max_daily_loss", "500", true},
+		{"exchange", Scope{Exchange: "bybit"}, "risk.max_daily_loss", "500", true},
+		{"symbol", Scope{Exchange: "bybit", Symbol: "BTCUSDT"}, "risk.max_position_size", "0.05", true},
+		{"strategy", Scope{Exchange: "bybit", Strategy: "sma"}, "risk.max_position_size", "0.05", true},
+		{"symbol of a strategy", Scope{Exchange: "bybit", Strategy: "sma", Symbol: "BTCUSDT"}, "risk.max_position_size", "0.05", true},
+		{"level not allowed", Scope{Exchange: "bybit", Symbol: "BTCUSDT"}, "risk.max_daily_loss", "500", false},
+		{"strategy not allowed", Scope{Exchange: "bybit", Strategy: "sma"}, "risk.max_daily_loss", "500", false},
+		{"symbol without exchange", Scope{Symbol: "BTCUSDT"}, "risk.max_position_size", "0.05", false},
+		{"unknown key", Scope{}, "risk.unknown", "1", false},
+		{"out of range", Scope{}, "risk.var_limit", "2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.scope, tt.key, tt.value); (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestScopeChain(t *testing.T) {
+	scope := Scope{Exchange: "bybit", Strategy: "sma", Symbol: "BTCUSDT"}
+	expected := []Scope{
+		scope,
+		{Exchange: "bybit", Symbol: "BTCUSDT"},
+		{Exchange: "bybit", Strategy: "sma"},
+		{Exchange: "bybit"},
+		{},
+	}
+	if chain := scope.Chain(); !reflect.DeepEqual(chain, expected) {
+		t.Errorf("expected %v, got %v", expected, chain)
+	}
+	if scope.Level() != LevelSymbol || (Scope{}).Level() != LevelGlobal {
+		t.Errorf("unexpected levels %s and %s", scope.Level(), (Scope{}).Level())
+	}
+	if s := scope.String(); s != "bybit strategy=sma symbol=BTCUSDT" {
+		t.Errorf("unexpected scope string %q", s)
+	}
+}
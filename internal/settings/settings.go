@@ -13,15 +13,31 @@ import (
 
 // Messages for settings actor communication
 type (
-	GetSettingMsg struct{ Key string }
-	SetSettingMsg struct{ Key, Value string }
-	StatusMsg     struct{}
+	// GetSettingMsg resolves a setting for the exchange of the actor, narrowed to a strategy or symbol
+	GetSettingMsg struct {
+		Key      string
+		Strategy string
+		Symbol   string
+	}
+
+	// SetSettingMsg stores a setting for the exchange of the actor, or a strategy or symbol on it
+	SetSettingMsg struct {
+		Key, Value string
+		Strategy   string
+		Symbol     string
+		Actor      string // Who made the change, recorded in the history
+		Note       string
+	}
+
+	StatusMsg struct{}
 
 	// Response message for get operations
 	SettingResponse struct {
 		Key   string
 		Value string
-		Found bool
+		Typed interface{} // Value converted to the type of the setting
+		Scope Scope       // Scope the value is inherited from
+		Found bool        // The setting is set at some scope, otherwise Value is its default
 	}
 )
 
@@ -30,6 +46,7 @@ type SettingsActor struct {
 	exchangeName string
 	config       *config.Config
 	db           *database.DB
+	store        *Store
 	logger       zerolog.Logger
 }
 
@@ -39,6 +56,7 @@ func New(exchangeName string, cfg *config.Config, db *database.DB, logger zerolo
 		exchangeName: exchangeName,
 		config:       cfg,
 		db:           db,
+		store:        NewStore(db),
 		logger:       logger,
 	}
 }
@@ -85,51 +103,50 @@ func (s *SettingsActor) onStatus(ctx *actor.Context) {
 }
 
 func (s *SettingsActor) onGetSetting(ctx *actor.Context, msg GetSettingMsg) {
-	// Try to get setting from database first, fallback to config
-	query := `SELECT value FROM settings WHERE key = ? AND exchange = ?`
-	var value string
-	err := s.db.Conn().QueryRow(query, msg.Key, s.exchangeName).Scan(&value)
-
-	if err == nil {
-		// Found in database
-		ctx.Respond(SettingResponse{
-			Key:   msg.Key,
-			Value: value,
-			Found: true,
-		})
-		return
-	}
-
-	// Not found or error - check if it's a database error or just not found
-	if err.Error() != "no rows in result set" && err.Error() != "sql: no rows in result set" {
+	scope := Scope{Exchange: s.exchangeName, Strategy: msg.Strategy, Symbol: msg.Symbol}
+	value, err := s.store.Get(scope, msg.Key)
+	if err != nil {
 		s.logger.Error().Err(err).Str("key", msg.Key).Msg("Error querying setting")
+		ctx.Respond(SettingResponse{Key: msg.Key})
+		return
 	}
 
 	ctx.Respond(SettingResponse{
 		Key:   msg.Key,
-		Value: "",
-		Found: false,
+		Value: value.Value,
+		Typed: value.Typed,
+		Scope: value.Scope,
+		Found: !value.Default,
 	})
 }
 
 func (s *SettingsActor) onSetSetting(ctx *actor.Context, msg SetSettingMsg) {
-	// Store setting in database
-	query := `INSERT OR REPLACE INTO settings (key, value, exchange, updated_at) VALUES (?, ?, ?, ?)`
-	_, err := s.db.Conn().Exec(query, msg.Key, msg.Value, s.exchangeName, time.Now())
+	actorName := msg.Actor
+	if actorName == "" {
+		actorName = "system"
+	}
 
+	scope := Scope{Exchange: s.exchangeName, Strategy: msg.Strategy, Symbol: msg.Symbol}
+	change, err := s.store.Set(scope, msg.Key, msg.Value, actorName, msg.Note)
 	if err != nil {
 		s.logger.Error().Err(err).
 			Str("key", msg.Key).
 			Str("value", msg.Value).
+			Str("scope", scope.String()).
 			Msg("Failed to store setting")
 		ctx.Respond(fmt.Errorf("failed to store setting: %w", err))
 		return
 	}
 
-	s.logger.Info().
-		Str("key", msg.Key).
-		Str("value", msg.Value).
-		Msg("Setting stored successfully")
+	if change != nil {
+		s.logger.Info().
+			Str("key", msg.Key).
+			Str("value", msg.Value).
+			Str("scope", scope.String()).
+			Str("actor", actorName).
+			Int64("version", change.Version).
+			Msg("Setting stored successfully")
+	}
 
 	ctx.Respond("OK")
 }
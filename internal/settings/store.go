@@ -0,0 +1,358 @@
+package settings
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/database"
+)
+
+// Value is the effective value of a setting at a scope
+type Value struct {
+	Key     string      `json:"key"`
+	Value   string      `json:"value"`
+	Typed   interface{} `json:"typed"`
+	Unit    string      `json:"unit,omitempty"`
+	Scope   Scope       `json:"scope"`             // Scope the value was set at
+	Default bool        `json:"default"`           // No scope sets the setting, Value is its default
+	Version int64       `json:"version,omitempty"` // Version that set the value
+}
+
+// Change is one entry of the setting history. A nil value means the setting was not set.
+type Change struct {
+	Version   int64     `json:"version"`
+	Scope     Scope     `json:"scope"`
+	Key       string    `json:"key"`
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Difference is a setting with different values at two versions, a nil value means not set
+type Difference struct {
+	Scope Scope   `json:"scope"`
+	Key   string  `json:"key"`
+	From  *string `json:"from"`
+	To    *string `json:"to"`
+}
+
+// setting identifies one stored value
+type setting struct {
+	scope Scope
+	key   string
+}
+
+// Store keeps setting values per scope and records every change as a new version, so any earlier
+// version can be compared with or restored
+type Store struct {
+	db *database.DB
+}
+
+// NewStore creates a store on the settings tables of db
+func NewStore(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+// Set validates value against the definition of key and stores it at scope. Setting the current value
+// again records nothing and returns a nil change.
+func (s *Store) Set(scope Scope, key, value, actor, note string) (*Change, error) {
+	if err := Validate(scope, key, value); err != nil {
+		return nil, err
+	}
+	return s.change(setting{scope, key}, &value, actor, note)
+}
+
+// Unset removes the value of key at scope, so it is inherited from a wider scope again
+func (s *Store) Unset(scope Scope, key, actor, note string) (*Change, error) {
+	if _, ok := Lookup(key); !ok {
+		return nil, fmt.Errorf("unknown setting %s", key)
+	}
+	return s.change(setting{scope, key}, nil, actor, note)
+}
+
+func (s *Store) change(target setting, value *string, actor, note string) (*Change, error) {
+	tx, err := s.db.Conn().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	change, err := apply(tx, target, value, actor, note)
+	if err != nil {
+		return nil, err
+	}
+	return change, tx.Commit()
+}
+
+// apply records a change of one setting and stores its new value, it does nothing when the value is unchanged
+func apply(tx *sql.Tx, target setting, value *string, actor, note string) (*Change, error) {
+	var current sql.NullString
+	err := tx.QueryRow(`
+		SELECT value FROM setting_values
+		WHERE exchange = ? AND strategy = ? AND symbol = ? AND key = ?
+	`, target.scope.Exchange, target.scope.Strategy, target.scope.Symbol, target.key).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	old := nullable(current)
+	if equal(old, value) {
+		return nil, nil
+	}
+
+	change := &Change{
+		Scope:     target.scope,
+		Key:       target.key,
+		OldValue:  old,
+		NewValue:  value,
+		Actor:     actor,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+	result, err := tx.Exec(`
+		INSERT INTO setting_history (exchange, strategy, symbol, key, old_value, new_value, actor, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, target.scope.Exchange, target.scope.Strategy, target.scope.Symbol, target.key, old, value, actor, note, change.CreatedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if change.Version, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		_, err = tx.Exec(`
+			DELETE FROM setting_values
+			WHERE exchange = ? AND strategy = ? AND symbol = ? AND key = ?
+		`, target.scope.Exchange, target.scope.Strategy, target.scope.Symbol, target.key)
+	} else {
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO setting_values (exchange, strategy, symbol, key, value, version, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, target.scope.Exchange, target.scope.Strategy, target.scope.Symbol, target.key, *value, change.Version, change.CreatedAt.UTC())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Get returns the value of key at scope, inherited from the closest scope that sets it or the default
+func (s *Store) Get(scope Scope, key string) (Value, error) {
+	definition, ok := Lookup(key)
+	if !ok {
+		return Value{}, fmt.Errorf("unknown setting %s", key)
+	}
+
+	result := Value{Key: key, Value: definition.Default, Unit: definition.Unit, Default: true}
+	for _, candidate := range scope.Chain() {
+		var value string
+		var version int64
+		err := s.db.Conn().QueryRow(`
+			SELECT value, version FROM setting_values
+			WHERE exchange = ? AND strategy = ? AND symbol = ? AND key = ?
+		`, candidate.Exchange, candidate.Strategy, candidate.Symbol, key).Scan(&value, &version)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return Value{}, err
+		}
+		result.Value, result.Scope, result.Default, result.Version = value, candidate, false, version
+		break
+	}
+
+	typed, err := definition.Parse(result.Value)
+	if err != nil {
+		// A value stored before its definition changed, fall back to the default
+		typed, _ = definition.Parse(definition.Default)
+		result.Value, result.Scope, result.Default, result.Version = definition.Default, Scope{}, true, 0
+	}
+	result.Typed = typed
+	return result, nil
+}
+
+// Effective returns the values of all defined settings at scope
+func (s *Store) Effective(scope Scope) ([]Value, error) {
+	var values []Value
+	for _, definition := range Definitions() {
+		value, err := s.Get(scope, definition.Key)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// History returns the changes of key, or of all settings when key is empty, newest first.
+// A limit of 0 returns every change.
+func (s *Store) History(key string, limit int) ([]Change, error) {
+	query := `
+		SELECT version, exchange, strategy, symbol, key, old_value, new_value, actor, note, created_at
+		FROM setting_history
+		WHERE ? = '' OR key = ?
+		ORDER BY version DESC`
+	args := []interface{}{key, key}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]Change, 0)
+	for rows.Next() {
+		var change Change
+		var old, value sql.NullString
+		if err := rows.Scan(&change.Version, &change.Scope.Exchange, &change.Scope.Strategy, &change.Scope.Symbol,
+			&change.Key, &old, &value, &change.Actor, &change.Note, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		change.OldValue, change.NewValue = nullable(old), nullable(value)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// Version returns the latest version, 0 before the first change
+func (s *Store) Version() (int64, error) {
+	var version sql.NullInt64
+	err := s.db.Conn().QueryRow(`SELECT MAX(version) FROM setting_history`).Scan(&version)
+	return version.Int64, err
+}
+
+// Diff returns the settings whose values differ between two versions, version 0 being before any change
+func (s *Store) Diff(from, to int64) ([]Difference, error) {
+	before, err := valuesAt(s.db.Conn(), from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := valuesAt(s.db.Conn(), to)
+	if err != nil {
+		return nil, err
+	}
+
+	differences := make([]Difference, 0)
+	for _, target := range union(before, after) {
+		if !equal(before[target], after[target]) {
+			differences = append(differences, Difference{Scope: target.scope, Key: target.key, From: before[target], To: after[target]})
+		}
+	}
+	return differences, nil
+}
+
+// Rollback restores every setting to its value at version. The restore is recorded as new changes,
+// so it can be rolled back in turn.
+func (s *Store) Rollback(version int64, actor string) ([]Change, error) {
+	latest, err := s.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version < 0 || version > latest {
+		return nil, fmt.Errorf("version %d does not exist, the latest version is %d", version, latest)
+	}
+
+	tx, err := s.db.Conn().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := valuesAt(tx, latest)
+	if err != nil {
+		return nil, err
+	}
+	target, err := valuesAt(tx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+	note := fmt.Sprintf("rollback to version %d", version)
+	for _, id := range union(current, target) {
+		change, err := apply(tx, id, target[id], actor, note)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, tx.Commit()
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// valuesAt replays the history up to version into the values that were set then
+func valuesAt(q querier, version int64) (map[setting]*string, error) {
+	rows, err := q.Query(`
+		SELECT exchange, strategy, symbol, key, new_value
+		FROM setting_history
+		WHERE version <= ?
+		ORDER BY version
+	`, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[setting]*string)
+	for rows.Next() {
+		var target setting
+		var value sql.NullString
+		if err := rows.Scan(&target.scope.Exchange, &target.scope.Strategy, &target.scope.Symbol, &target.key, &value); err != nil {
+			return nil, err
+		}
+		if value.Valid {
+			values[target] = nullable(value)
+		} else {
+			delete(values, target)
+		}
+	}
+	return values, rows.Err()
+}
+
+// union returns the settings of both value sets in a stable order
+func union(a, b map[setting]*string) []setting {
+	seen := make(map[setting]bool)
+	var result []setting
+	for _, values := range []map[setting]*string{a, b} {
+		for target := range values {
+			if !seen[target] {
+				seen[target] = true
+				result = append(result, target)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].key != result[j].key {
+			return result[i].key < result[j].key
+		}
+		return result[i].scope.String() < result[j].scope.String()
+	})
+	return result
+}
+
+func nullable(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
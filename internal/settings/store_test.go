@@ -0,0 +1,126 @@
+package settings
+
+import (
+	"testing"
+)
+
+func TestStoreInheritance(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+	store := NewStore(db)
+
+	symbol := Scope{Exchange: "bybit", Symbol: "BTCUSDT"}
+	value, err := store.Get(symbol, "risk.max_position_size")
+	if err != nil || !value.Default || value.Typed != 0.1 {
+		t.Fatalf("expected the default 0.1, got %+v (%v)", value, err)
+	}
+
+	if _, err := store.Set(Scope{}, "risk.max_position_size", "0.2", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set(Scope{Exchange: "bybit"}, "risk.max_position_size", "0.15", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The symbol inherits from its exchange, another exchange from the global value
+	if value, _ := store.Get(symbol, "risk.max_position_size"); value.Typed != 0.15 || value.Scope != (Scope{Exchange: "bybit"}) {
+		t.Errorf("expected 0.15 from the exchange, got %+v", value)
+	}
+	if value, _ := store.Get(Scope{Exchange: "bitvavo"}, "risk.max_position_size"); value.Typed != 0.2 || value.Default {
+		t.Errorf("expected the global 0.2, got %+v", value)
+	}
+
+	if _, err := store.Set(symbol, "risk.max_position_size", "0.05", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := store.Get(symbol, "risk.max_position_size"); value.Typed != 0.05 || value.Scope != symbol {
+		t.Errorf("expected 0.05 for the symbol, got %+v", value)
+	}
+
+	// Unsetting the symbol falls back to the exchange again
+	if _, err := store.Unset(symbol, "risk.max_position_size", "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := store.Get(symbol, "risk.max_position_size"); value.Typed != 0.15 {
+		t.Errorf("expected 0.15 after unset, got %+v", value)
+	}
+
+	if _, err := store.Set(Scope{}, "risk.max_position_size", "abc", "test", ""); err == nil {
+		t.Error("expected an invalid value to be refused")
+	}
+
+	values, err := store.Effective(Scope{Exchange: "bybit"})
+	if err != nil || len(values) != len(Definitions()) {
+		t.Errorf("expected a value for every setting, got %d (%v)", len(values), err)
+	}
+}
+
+func TestStoreHistoryDiffRollback(t *testing.T) {
+	db := setupTestDatabase(t)
+	defer db.Close()
+	store := NewStore(db)
+	bybit := Scope{Exchange: "bybit"}
+
+	first, err := store.Set(bybit, "risk.max_daily_loss", "500", "alice", "tighter")
+	if err != nil || first == nil || first.OldValue != nil || *first.NewValue != "500" {
+		t.Fatalf("unexpected first change %+v (%v)", first, err)
+	}
+	if _, err := store.Set(bybit, "risk.max_daily_loss", "800", "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set(bybit, "risk.max_daily_trades", "10", "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting the current value again records nothing
+	if change, err := store.Set(bybit, "risk.max_daily_trades", "10", "bob", ""); err != nil || change != nil {
+		t.Errorf("expected no change, got %+v (%v)", change, err)
+	}
+
+	history, err := store.History("risk.max_daily_loss", 0)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected 2 changes, got %+v (%v)", history, err)
+	}
+	latest := history[0]
+	if latest.Actor != "bob" || *latest.OldValue != "500" || *latest.NewValue != "800" || latest.CreatedAt.IsZero() {
+		t.Errorf("unexpected latest change %+v", latest)
+	}
+	if all, _ := store.History("", 1); len(all) != 1 || all[0].Key != "risk.max_daily_trades" {
+		t.Errorf("expected the newest change of all settings, got %+v", all)
+	}
+
+	differences, err := store.Diff(first.Version, first.Version+2)
+	if err != nil || len(differences) != 2 {
+		t.Fatalf("expected 2 differences, got %+v (%v)", differences, err)
+	}
+	loss := differences[0]
+	if loss.Key != "risk.max_daily_loss" || *loss.From != "500" || *loss.To != "800" {
+		t.Errorf("unexpected difference %+v", loss)
+	}
+	if trades := differences[1]; trades.From != nil || *trades.To != "10" {
+		t.Errorf("expected max_daily_trades to be added, got %+v", trades)
+	}
+
+	changes, err := store.Rollback(first.Version, "carol")
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("expected 2 rollback changes, got %+v (%v)", changes, err)
+	}
+	if changes[0].Note != "rollback to version 1" || changes[0].Actor != "carol" {
+		t.Errorf("unexpected rollback change %+v", changes[0])
+	}
+	if value, _ := store.Get(bybit, "risk.max_daily_loss"); value.Value != "500" {
+		t.Errorf("expected 500 after rollback, got %+v", value)
+	}
+	if value, _ := store.Get(bybit, "risk.max_daily_trades"); !value.Default {
+		t.Errorf("expected max_daily_trades to be unset after rollback, got %+v", value)
+	}
+
+	// Nothing differs from the version rolled back to
+	latestVersion, _ := store.Version()
+	if differences, _ := store.Diff(first.Version, latestVersion); len(differences) != 0 {
+		t.Errorf("expected no differences after rollback, got %+v", differences)
+	}
+	if _, err := store.Rollback(latestVersion+1, "carol"); err == nil {
+		t.Error("expected an error for a missing version")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 
+	"github.com/arijanluiken/mercantile/internal/sandbox"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
 )
 
@@ -23,6 +24,8 @@ type KlineObject struct {
 	close     float64
 	volume    float64
 	symbol    string
+	interval  string
+	closed    bool
 }
 
 // String returns the string representation of the KlineObject
@@ -66,6 +69,10 @@ func (k *KlineObject) Attr(name string) (starlark.Value, error) {
 		return starlark.Float(k.volume), nil
 	case "symbol":
 		return starlark.String(k.symbol), nil
+	case "interval":
+		return starlark.String(k.interval), nil
+	case "closed":
+		return starlark.Bool(k.closed), nil
 	default:
 		return nil, fmt.Errorf("kline has no attribute %q", name)
 	}
@@ -73,7 +80,7 @@ func (k *KlineObject) Attr(name string) (starlark.Value, error) {
 
 // AttrNames returns the list of available attributes
 func (k *KlineObject) AttrNames() []string {
-	return []string{"timestamp", "open", "high", "low", "close", "volume", "symbol"}
+	return []string{"timestamp", "open", "high", "low", "close", "volume", "symbol", "interval", "closed"}
 }
 
 func (se *StrategyEngine) setupBuiltins() {
@@ -225,6 +232,10 @@ func (se *StrategyEngine) setupBuiltins() {
 		"crossover":  starlark.NewBuiltin("crossover", se.crossover),
 		"crossunder": starlark.NewBuiltin("crossunder", se.crossunder),
 		"log":        starlark.NewBuiltin("log", se.logFunc),
+		"plot":       starlark.NewBuiltin("plot", se.plotFunc),
+		// Market data access
+		"klines":  starlark.NewBuiltin("klines", se.klinesFunc),
+		"symbols": starlark.NewBuiltin("symbols", se.symbolsFunc),
 	}
 }
 
@@ -272,7 +283,21 @@ func (se *StrategyEngine) starlarkBuiltinRange(thread *starlark.Thread, fn *star
 		return nil, fmt.Errorf("range() takes 1 to 3 arguments")
 	}
 
-	var result []starlark.Value
+	// range() builds a list, refuse lengths that could never be iterated within the step budget
+	var length int64
+	if step > 0 && stop > start {
+		length = (stop - start + step - 1) / step
+	} else if step < 0 && start > stop {
+		length = (start - stop - step - 1) / -step
+	}
+	if maxSteps := se.limits.MaxSteps; maxSteps > 0 && uint64(length) > maxSteps {
+		return nil, &sandbox.ViolationError{
+			Kind: sandbox.ViolationSteps,
+			Err:  fmt.Errorf("range() of %d elements exceeds the step budget of %d", length, maxSteps),
+		}
+	}
+
+	result := make([]starlark.Value, 0, length)
 	if step > 0 {
 		for i := start; i < stop; i += step {
 			result = append(result, starlark.MakeInt64(i))
@@ -288,27 +313,28 @@ func (se *StrategyEngine) starlarkBuiltinRange(thread *starlark.Thread, fn *star
 
 // ExecuteStrategy runs a strategy script with the given context
 func (se *StrategyEngine) ExecuteStrategy(strategyName string, ctx *StrategyContext) (*StrategySignal, error) {
-	// Load strategy script
-	script, err := se.loadStrategy(strategyName)
+	// Use the cached script version so reloads only take effect when swapped in
+	script, err := se.strategySource(strategyName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
 	}
 
-	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s", strategyName),
-	}
-
-	// Set config in thread locals for get_config() function access
-	if ctx.Config != nil {
-		thread.SetLocal("config", se.mapToStarlark(ctx.Config))
-	}
+	// Create Starlark thread with state, config and kline buffers in thread locals
+	thread := se.newThread(strategyName, "execute", ctx)
 
 	// Prepare globals with context data
 	globals := se.prepareGlobals(ctx)
 
 	// Execute strategy
-	result, err := starlark.ExecFile(thread, strategyName, script, globals)
+	var result starlark.StringDict
+	err = sandbox.Run(thread, se.limits, func() error {
+		var execErr error
+		result, execErr = starlark.ExecFile(thread, strategyName, script, globals)
+		return execErr
+	})
+	if err == nil {
+		err = se.checkState(strategyName)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("strategy execution failed: %w", err)
 	}
@@ -366,12 +392,8 @@ func (se *StrategyEngine) prepareGlobals(ctx *StrategyContext) starlark.StringDi
 	globals["exchange"] = starlark.String(ctx.Exchange)
 	globals["config"] = se.mapToStarlark(ctx.Config)
 
-	// Add kline data
-	if len(ctx.Klines) > 0 {
-		globals["klines"] = se.klinesToStarlark(ctx.Klines)
-		// Note: Global price arrays (close, open, high, low, volume) have been removed
-		// Strategies should use callback parameters instead
-	}
+	// Note: Global kline and price arrays have been removed
+	// Strategies should use callback parameters or the klines() builtin instead
 
 	// Add order book data
 	if ctx.OrderBook != nil && len(ctx.OrderBook.Bids) > 0 && len(ctx.OrderBook.Asks) > 0 {
@@ -425,6 +447,16 @@ func (se *StrategyEngine) extractSignal(result starlark.StringDict) (*StrategySi
 
 // ExecuteKlineCallback runs the on_kline callback in a strategy script
 func (se *StrategyEngine) ExecuteKlineCallback(strategyName string, ctx *StrategyContext, kline *exchanges.Kline) (*StrategySignal, error) {
+	return se.executeKlineCallback(strategyName, "on_kline", ctx, kline)
+}
+
+// ExecuteKlineUpdateCallback runs the on_kline_update callback with an in-progress bar
+func (se *StrategyEngine) ExecuteKlineUpdateCallback(strategyName string, ctx *StrategyContext, kline *exchanges.Kline) (*StrategySignal, error) {
+	return se.executeKlineCallback(strategyName, "on_kline_update", ctx, kline)
+}
+
+// executeKlineCallback calls the named kline callback, on_kline or on_kline_update
+func (se *StrategyEngine) executeKlineCallback(strategyName, callback string, ctx *StrategyContext, kline *exchanges.Kline) (*StrategySignal, error) {
 	// Get cached strategy globals
 	_, globals, err := se.getOrLoadStrategy(strategyName)
 	if err != nil {
@@ -432,9 +464,7 @@ func (se *StrategyEngine) ExecuteKlineCallback(strategyName string, ctx *Strateg
 	}
 
 	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s-kline", strategyName),
-	}
+	thread := se.newThread(strategyName, strings.TrimPrefix(callback, "on_"), ctx)
 
 	// Update globals with current context data
 	se.updateGlobalsWithContext(globals, ctx)
@@ -448,16 +478,18 @@ func (se *StrategyEngine) ExecuteKlineCallback(strategyName string, ctx *Strateg
 		close:     kline.Close,
 		volume:    kline.Volume,
 		symbol:    kline.Symbol,
+		interval:  kline.Interval,
+		closed:    kline.IsClosed,
 	}
 	globals["kline"] = klineObj
 
-	// Check if on_kline function exists and call it
-	if onKlineFn, ok := globals["on_kline"]; ok {
+	// Check if the callback function exists and call it
+	if onKlineFn, ok := globals[callback]; ok {
 		if fn, ok := onKlineFn.(*starlark.Function); ok {
 			args := starlark.Tuple{globals["kline"]}
-			signalResult, err := starlark.Call(thread, fn, args, nil)
+			signalResult, err := se.callSandboxed(strategyName, thread, fn, args)
 			if err != nil {
-				return nil, fmt.Errorf("on_kline callback failed: %w", err)
+				return nil, fmt.Errorf("%s callback failed: %w", callback, err)
 			}
 
 			// Extract signal from callback result
@@ -478,11 +510,6 @@ func (se *StrategyEngine) updateGlobalsWithContext(globals starlark.StringDict,
 	globals["exchange"] = starlark.String(ctx.Exchange)
 	globals["config"] = se.mapToStarlark(ctx.Config)
 
-	// Add kline data
-	if len(ctx.Klines) > 0 {
-		globals["klines"] = se.klinesToStarlark(ctx.Klines)
-	}
-
 	// Add order book data
 	if ctx.OrderBook != nil && len(ctx.OrderBook.Bids) > 0 && len(ctx.OrderBook.Asks) > 0 {
 		globals["bid"] = starlark.Float(ctx.OrderBook.Bids[0].Price)
@@ -500,9 +527,7 @@ func (se *StrategyEngine) ExecuteOrderBookCallback(strategyName string, ctx *Str
 	}
 
 	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s-orderbook", strategyName),
-	}
+	thread := se.newThread(strategyName, "orderbook", ctx)
 
 	// Update globals with current context data
 	se.updateGlobalsWithContext(globals, ctx)
@@ -537,7 +562,7 @@ func (se *StrategyEngine) ExecuteOrderBookCallback(strategyName string, ctx *Str
 	if onOrderBookFn, ok := globals["on_orderbook"]; ok {
 		if fn, ok := onOrderBookFn.(*starlark.Function); ok {
 			args := starlark.Tuple{globals["orderbook"]}
-			signalResult, err := starlark.Call(thread, fn, args, nil)
+			signalResult, err := se.callSandboxed(strategyName, thread, fn, args)
 			if err != nil {
 				return nil, fmt.Errorf("on_orderbook callback failed: %w", err)
 			}
@@ -562,9 +587,7 @@ func (se *StrategyEngine) ExecuteTickerCallback(strategyName string, ctx *Strate
 	}
 
 	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s-ticker", strategyName),
-	}
+	thread := se.newThread(strategyName, "ticker", ctx)
 
 	// Update globals with current context data
 	se.updateGlobalsWithContext(globals, ctx)
@@ -581,7 +604,7 @@ func (se *StrategyEngine) ExecuteTickerCallback(strategyName string, ctx *Strate
 	if onTickerFn, ok := globals["on_ticker"]; ok {
 		if fn, ok := onTickerFn.(*starlark.Function); ok {
 			args := starlark.Tuple{globals["ticker"]}
-			signalResult, err := starlark.Call(thread, fn, args, nil)
+			signalResult, err := se.callSandboxed(strategyName, thread, fn, args)
 			if err != nil {
 				return nil, fmt.Errorf("on_ticker callback failed: %w", err)
 			}
@@ -597,6 +620,50 @@ func (se *StrategyEngine) ExecuteTickerCallback(strategyName string, ctx *Strate
 	return se.extractSignal(globals)
 }
 
+// ExecuteBarCallback runs the on_bar callback with one kline per leg sharing the same open time.
+// The callback may return a single signal dict or a list of them to trade several legs at once.
+func (se *StrategyEngine) ExecuteBarCallback(strategyName string, ctx *StrategyContext, bars map[string]*exchanges.Kline) ([]*StrategySignal, error) {
+	// Get cached strategy globals
+	_, globals, err := se.getOrLoadStrategy(strategyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
+	}
+
+	// Create Starlark thread
+	thread := se.newThread(strategyName, "bar", ctx)
+
+	// Update globals with current context data
+	se.updateGlobalsWithContext(globals, ctx)
+
+	// Add bars keyed by leg symbol
+	barsDict := starlark.NewDict(len(bars))
+	for symbol, kline := range bars {
+		barsDict.SetKey(starlark.String(symbol), &KlineObject{
+			timestamp: kline.Timestamp,
+			open:      kline.Open,
+			high:      kline.High,
+			low:       kline.Low,
+			close:     kline.Close,
+			volume:    kline.Volume,
+			symbol:    kline.Symbol,
+			interval:  kline.Interval,
+			closed:    kline.IsClosed,
+		})
+	}
+
+	onBarFn, ok := globals["on_bar"].(*starlark.Function)
+	if !ok {
+		return nil, nil
+	}
+
+	signalResult, err := se.callSandboxed(strategyName, thread, onBarFn, starlark.Tuple{barsDict})
+	if err != nil {
+		return nil, fmt.Errorf("on_bar callback failed: %w", err)
+	}
+
+	return se.extractSignals(signalResult)
+}
+
 // ExecuteStartCallback runs the on_start callback in a strategy script
 func (se *StrategyEngine) ExecuteStartCallback(strategyName string, ctx *StrategyContext) error {
 	// Get cached strategy globals
@@ -606,9 +673,7 @@ func (se *StrategyEngine) ExecuteStartCallback(strategyName string, ctx *Strateg
 	}
 
 	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s-start", strategyName),
-	}
+	thread := se.newThread(strategyName, "start", ctx)
 
 	// Update globals with current context data
 	se.updateGlobalsWithContext(globals, ctx)
@@ -616,7 +681,7 @@ func (se *StrategyEngine) ExecuteStartCallback(strategyName string, ctx *Strateg
 	// Check if on_start function exists and call it
 	if onStartFn, ok := globals["on_start"]; ok {
 		if fn, ok := onStartFn.(*starlark.Function); ok {
-			_, err := starlark.Call(thread, fn, starlark.Tuple{}, nil)
+			_, err := se.callSandboxed(strategyName, thread, fn, starlark.Tuple{})
 			if err != nil {
 				return fmt.Errorf("on_start callback failed: %w", err)
 			}
@@ -636,9 +701,7 @@ func (se *StrategyEngine) ExecuteStopCallback(strategyName string, ctx *Strategy
 	}
 
 	// Create Starlark thread
-	thread := &starlark.Thread{
-		Name: fmt.Sprintf("strategy-%s-stop", strategyName),
-	}
+	thread := se.newThread(strategyName, "stop", ctx)
 
 	// Update globals with current context data
 	se.updateGlobalsWithContext(globals, ctx)
@@ -646,7 +709,7 @@ func (se *StrategyEngine) ExecuteStopCallback(strategyName string, ctx *Strategy
 	// Check if on_stop function exists and call it
 	if onStopFn, ok := globals["on_stop"]; ok {
 		if fn, ok := onStopFn.(*starlark.Function); ok {
-			_, err := starlark.Call(thread, fn, starlark.Tuple{}, nil)
+			_, err := se.callSandboxed(strategyName, thread, fn, starlark.Tuple{})
 			if err != nil {
 				return fmt.Errorf("on_stop callback failed: %w", err)
 			}
@@ -694,9 +757,43 @@ func (se *StrategyEngine) extractSignalFromDict(dict *starlark.Dict) (*StrategyS
 		}
 	}
 
+	if symbol, found, _ := dict.Get(starlark.String("symbol")); found {
+		if s, ok := symbol.(starlark.String); ok {
+			signal.Symbol = string(s)
+		}
+	}
+
 	return signal, nil
 }
 
+// extractSignals extracts signals from a callback result that may be a single dict or a list of dicts
+func (se *StrategyEngine) extractSignals(result starlark.Value) ([]*StrategySignal, error) {
+	switch v := result.(type) {
+	case *starlark.Dict:
+		signal, err := se.extractSignalFromDict(v)
+		if err != nil {
+			return nil, err
+		}
+		return []*StrategySignal{signal}, nil
+	case *starlark.List:
+		signals := make([]*StrategySignal, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			dict, ok := v.Index(i).(*starlark.Dict)
+			if !ok {
+				return nil, fmt.Errorf("signal %d must be a dict, got %s", i, v.Index(i).Type())
+			}
+			signal, err := se.extractSignalFromDict(dict)
+			if err != nil {
+				return nil, err
+			}
+			signals = append(signals, signal)
+		}
+		return signals, nil
+	default:
+		return nil, nil
+	}
+}
+
 // GetStrategyInterval extracts the interval from a strategy script
 func (se *StrategyEngine) GetStrategyInterval(strategyName string) (string, error) {
 	// Load strategy script
@@ -705,6 +802,11 @@ func (se *StrategyEngine) GetStrategyInterval(strategyName string) (string, erro
 		return "", fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
 	}
 
+	return ScriptInterval(scriptContent), nil
+}
+
+// ScriptInterval finds the interval in a strategy's settings() or legacy top-level variable, "1m" if there is none
+func ScriptInterval(scriptContent string) string {
 	// Simple text parsing approach to find interval in settings function
 	lines := strings.Split(scriptContent, "\n")
 	inSettingsFunction := false
@@ -734,7 +836,7 @@ func (se *StrategyEngine) GetStrategyInterval(strategyName string) (string, erro
 				intervalPart = strings.TrimSpace(intervalPart)
 				intervalPart = strings.Trim(intervalPart, `"'`) // Remove quotes
 				if intervalPart != "" {
-					return intervalPart, nil
+					return intervalPart
 				}
 			}
 		}
@@ -748,14 +850,172 @@ func (se *StrategyEngine) GetStrategyInterval(strategyName string) (string, erro
 				intervalPart = strings.TrimSpace(intervalPart)
 				intervalPart = strings.Trim(intervalPart, `"'`) // Remove quotes
 				if intervalPart != "" {
-					return intervalPart, nil
+					return intervalPart
 				}
 			}
 		}
 	}
 
 	// Default interval if not specified in script
-	return "1m", nil
+	return "1m"
+}
+
+// GetStrategyTimeframes returns the intervals a strategy declares in settings() with their buffer depths.
+// "timeframes" may be a dict of interval to depth or a list of intervals using the default depth.
+func (se *StrategyEngine) GetStrategyTimeframes(strategyName string) (map[string]int, error) {
+	settingsDict, err := se.strategySettings(strategyName)
+	if err != nil {
+		return nil, err
+	}
+
+	timeframes := make(map[string]int)
+	if settingsDict == nil {
+		return timeframes, nil
+	}
+
+	value, found, _ := settingsDict.Get(starlark.String("timeframes"))
+	if !found {
+		return timeframes, nil
+	}
+
+	switch declared := value.(type) {
+	case *starlark.Dict:
+		for _, item := range declared.Items() {
+			interval, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("timeframes keys must be interval strings, got %s", item[0].Type())
+			}
+			depth, err := starlark.AsInt32(item[1])
+			if err != nil {
+				return nil, fmt.Errorf("timeframes[%q] depth must be an integer: %w", string(interval), err)
+			}
+			timeframes[string(interval)] = clampBufferDepth(depth)
+		}
+	case *starlark.List:
+		for i := 0; i < declared.Len(); i++ {
+			interval, ok := declared.Index(i).(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("timeframes entries must be interval strings, got %s", declared.Index(i).Type())
+			}
+			timeframes[string(interval)] = DefaultBufferDepth
+		}
+	default:
+		return nil, fmt.Errorf("timeframes must be a dict or list, got %s", value.Type())
+	}
+
+	return timeframes, nil
+}
+
+// GetStrategySettingTypes returns the Starlark type of every key in a strategy's settings(), such as
+// "int", "float" or "string". It is nil for strategies without settings().
+func (se *StrategyEngine) GetStrategySettingTypes(strategyName string) (map[string]string, error) {
+	settingsDict, err := se.strategySettings(strategyName)
+	if err != nil {
+		return nil, err
+	}
+
+	if settingsDict == nil {
+		return nil, nil
+	}
+	types := make(map[string]string)
+	for _, item := range settingsDict.Items() {
+		if key, ok := item[0].(starlark.String); ok {
+			types[string(key)] = item[1].Type()
+		}
+	}
+	return types, nil
+}
+
+// strategySettings calls a strategy's settings(), nil when it defines none or it does not return a dict
+func (se *StrategyEngine) strategySettings(strategyName string) (*starlark.Dict, error) {
+	_, globals, err := se.getOrLoadStrategy(strategyName)
+	if err != nil {
+		return nil, err
+	}
+
+	settingsFn, ok := globals["settings"].(*starlark.Function)
+	if !ok {
+		return nil, nil
+	}
+
+	thread := &starlark.Thread{Name: fmt.Sprintf("strategy-%s-settings", strategyName)}
+	var result starlark.Value
+	err = sandbox.Run(thread, se.limits, func() error {
+		var callErr error
+		result, callErr = starlark.Call(thread, settingsFn, nil, nil)
+		return callErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("settings() failed: %w", err)
+	}
+
+	settingsDict, _ := result.(*starlark.Dict)
+	return settingsDict, nil
+}
+
+// clampBufferDepth keeps a declared buffer depth within supported bounds
+func clampBufferDepth(depth int) int {
+	if depth <= 0 {
+		return DefaultBufferDepth
+	}
+	if depth > MaxBufferDepth {
+		return MaxBufferDepth
+	}
+	return depth
+}
+
+// klinesFunc returns buffered klines for an interval: klines("1h", 200).
+// Without an interval it returns the strategy's primary interval.
+func (se *StrategyEngine) klinesFunc(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var interval, symbol string
+	var limit int
+	if err := starlark.UnpackArgs("klines", args, kwargs, "interval?", &interval, "limit?", &limit, "symbol?", &symbol); err != nil {
+		return nil, err
+	}
+
+	timeframes, _ := thread.Local("timeframes").(map[string][]*KlineData)
+	if symbol != "" {
+		legs, _ := thread.Local("legs").(map[string]map[string][]*KlineData)
+		var exists bool
+		timeframes, exists = legs[symbol]
+		if !exists {
+			return nil, fmt.Errorf("klines(): symbol %q is not one of the strategy's legs", symbol)
+		}
+	}
+
+	var buffer []*KlineData
+	if interval == "" && symbol == "" {
+		buffer, _ = thread.Local("primary_klines").([]*KlineData)
+	} else {
+		if interval == "" {
+			interval, _ = thread.Local("primary_interval").(string)
+		}
+		var exists bool
+		buffer, exists = timeframes[interval]
+		if !exists {
+			return nil, fmt.Errorf("klines(): interval %q is not declared in settings() timeframes", interval)
+		}
+	}
+
+	if limit > 0 && limit < len(buffer) {
+		buffer = buffer[len(buffer)-limit:]
+	}
+
+	return se.klinesToStarlark(buffer), nil
+}
+
+// symbolsFunc returns the legs of the running strategy, primary symbol first
+func (se *StrategyEngine) symbolsFunc(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs("symbols", args, kwargs); err != nil {
+		return nil, err
+	}
+
+	symbols, _ := thread.Local("symbols").([]string)
+	values := make([]starlark.Value, len(symbols))
+	for i, symbol := range symbols {
+		values[i] = starlark.String(symbol)
+	}
+	return starlark.NewList(values), nil
 }
 
 // Technical Indicator Functions
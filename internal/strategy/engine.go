@@ -7,6 +7,7 @@ import (
 	"github.com/rs/zerolog"
 	"go.starlark.net/starlark"
 
+	"github.com/arijanluiken/mercantile/internal/sandbox"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
 )
 
@@ -22,8 +23,12 @@ type StrategyEngine struct {
 	builtin       starlark.StringDict
 	scriptCache   map[string]*starlark.Program
 	globalsCache  map[string]starlark.StringDict // Cache compiled globals for each strategy
+	sourceCache   map[string]string              // Source the cached program was compiled from
+	stateCache    map[string]*starlark.Dict      // set_state() values per strategy, kept across reloads
+	limits        sandbox.Limits                 // Step, time and state limits for every script execution
 	strategyActor interface {
 		addLog(level, message string, context map[string]interface{})
+		recordPlot(name string, timestamp time.Time, value float64)
 	} // Interface to avoid circular import
 }
 
@@ -41,7 +46,11 @@ type KlineData struct {
 type StrategyContext struct {
 	Symbol     string
 	Exchange   string
-	Klines     []*KlineData
+	Interval   string                             // Primary interval, the one Klines belongs to
+	Klines     []*KlineData                       // Buffer for the primary interval
+	Timeframes map[string][]*KlineData            // Buffers for every declared interval, keyed by interval
+	Symbols    []string                           // Every leg of the strategy, primary symbol first
+	Legs       map[string]map[string][]*KlineData // Buffers per leg symbol, then per interval
 	OrderBook  *exchanges.OrderBook
 	Config     map[string]interface{}
 	Balances   []*exchanges.Balance
@@ -56,8 +65,15 @@ type StrategySignal struct {
 	Price    float64
 	Type     string // "market", "limit"
 	Reason   string
+	Symbol   string // Leg to trade, empty for the primary symbol
 }
 
+// Default and maximum number of klines kept per timeframe
+const (
+	DefaultBufferDepth = 100
+	MaxBufferDepth     = 1000
+)
+
 // NewStrategyEngine creates a new strategy engine
 func NewStrategyEngine(logger zerolog.Logger) *StrategyEngine {
 	indicators := &TechnicalIndicators{logger: logger}
@@ -67,27 +83,114 @@ func NewStrategyEngine(logger zerolog.Logger) *StrategyEngine {
 		indicators:   indicators,
 		scriptCache:  make(map[string]*starlark.Program),
 		globalsCache: make(map[string]starlark.StringDict),
+		sourceCache:  make(map[string]string),
+		stateCache:   make(map[string]*starlark.Dict),
+		limits:       sandbox.DefaultLimits(),
 	}
 
 	engine.setupBuiltins()
 	return engine
 }
 
-// SetStrategyActor sets the strategy actor reference for logging
+// SetStrategyActor sets the strategy actor reference for logging and plotting
 func (se *StrategyEngine) SetStrategyActor(actor interface {
 	addLog(level, message string, context map[string]interface{})
+	recordPlot(name string, timestamp time.Time, value float64)
 }) {
 	se.strategyActor = actor
 }
 
+// SetSandboxLimits sets the limits applied to every script execution
+func (se *StrategyEngine) SetSandboxLimits(limits sandbox.Limits) {
+	se.limits = limits
+}
+
+// SandboxLimits returns the limits applied to every script execution
+func (se *StrategyEngine) SandboxLimits() sandbox.Limits {
+	return se.limits
+}
+
+// callSandboxed calls a strategy function within the sandbox limits and checks the state size afterwards
+func (se *StrategyEngine) callSandboxed(strategyName string, thread *starlark.Thread, fn starlark.Callable, args starlark.Tuple) (starlark.Value, error) {
+	var result starlark.Value
+	err := sandbox.Run(thread, se.limits, func() error {
+		var callErr error
+		result, callErr = starlark.Call(thread, fn, args, nil)
+		return callErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := se.checkState(strategyName); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkState verifies the strategy's set_state() values stay within the state size limit
+func (se *StrategyEngine) checkState(strategyName string) error {
+	state, exists := se.stateCache[strategyName]
+	if !exists {
+		return nil
+	}
+	return sandbox.CheckState(state, se.limits.MaxStateEntries)
+}
+
+// newThread creates a Starlark thread carrying the strategy state and execution context in thread locals
+func (se *StrategyEngine) newThread(strategyName, callback string, ctx *StrategyContext) *starlark.Thread {
+	thread := &starlark.Thread{Name: fmt.Sprintf("strategy-%s-%s", strategyName, callback)}
+
+	// State for get_state()/set_state(), shared by every callback of the strategy
+	state, exists := se.stateCache[strategyName]
+	if !exists {
+		state = starlark.NewDict(10)
+		se.stateCache[strategyName] = state
+	}
+	thread.SetLocal("strategy_state", state)
+
+	if ctx == nil {
+		return thread
+	}
+
+	// Config for get_config()
+	if ctx.Config != nil {
+		thread.SetLocal("config", se.mapToStarlark(ctx.Config))
+	}
+
+	// Kline buffers for klines()
+	timeframes := make(map[string][]*KlineData, len(ctx.Timeframes)+1)
+	for interval, buffer := range ctx.Timeframes {
+		timeframes[interval] = buffer
+	}
+	if _, exists := timeframes[ctx.Interval]; !exists && ctx.Interval != "" {
+		timeframes[ctx.Interval] = ctx.Klines
+	}
+	thread.SetLocal("timeframes", timeframes)
+	thread.SetLocal("primary_klines", ctx.Klines)
+	thread.SetLocal("primary_interval", ctx.Interval)
+	thread.SetLocal("legs", ctx.Legs)
+
+	// Legs for symbols(), single-symbol strategies have just their own symbol
+	symbols := ctx.Symbols
+	if len(symbols) == 0 && ctx.Symbol != "" {
+		symbols = []string{ctx.Symbol}
+	}
+	thread.SetLocal("symbols", symbols)
+
+	return thread
+}
+
 // StrategyCallbacks represents which callbacks are available in a strategy
 type StrategyCallbacks struct {
-	HasOnKline     bool
-	HasOnOrderBook bool
-	HasOnTicker    bool
-	HasSettings    bool
-	HasOnStart     bool
-	HasOnStop      bool
+	HasOnKline       bool
+	HasOnKlineUpdate bool
+	HasOnOrderBook   bool
+	HasOnTicker      bool
+	HasOnBar         bool
+	HasSettings      bool
+	HasOnStart       bool
+	HasOnStop        bool
 }
 
 // ValidateCallbacks checks which callbacks are available in a strategy script
@@ -98,6 +201,11 @@ func (se *StrategyEngine) ValidateCallbacks(strategyName string) (*StrategyCallb
 		return nil, fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
 	}
 
+	return se.validateCallbacks(strategyName, script)
+}
+
+// validateCallbacks runs a strategy's source and reports which callbacks it defines
+func (se *StrategyEngine) validateCallbacks(strategyName, script string) (*StrategyCallbacks, error) {
 	// Create Starlark thread
 	thread := &starlark.Thread{
 		Name: fmt.Sprintf("strategy-%s-validate", strategyName),
@@ -111,12 +219,36 @@ func (se *StrategyEngine) ValidateCallbacks(strategyName string) (*StrategyCallb
 	globals["config"] = starlark.NewDict(0)
 
 	// Execute strategy to get function definitions
-	result, err := starlark.ExecFile(thread, strategyName, script, globals)
+	var result starlark.StringDict
+	err := sandbox.Run(thread, se.limits, func() error {
+		var execErr error
+		result, execErr = starlark.ExecFile(thread, scriptFilename(strategyName), script, globals)
+		return execErr
+	})
 	if err != nil {
 		return nil, fmt.Errorf("strategy validation failed: %w", err)
 	}
 
 	// Check which callbacks are defined
+	callbacks := detectCallbacks(result)
+
+	se.logger.Debug().
+		Str("strategy", strategyName).
+		Bool("has_on_kline", callbacks.HasOnKline).
+		Bool("has_on_kline_update", callbacks.HasOnKlineUpdate).
+		Bool("has_on_orderbook", callbacks.HasOnOrderBook).
+		Bool("has_on_ticker", callbacks.HasOnTicker).
+		Bool("has_on_bar", callbacks.HasOnBar).
+		Bool("has_settings", callbacks.HasSettings).
+		Bool("has_on_start", callbacks.HasOnStart).
+		Bool("has_on_stop", callbacks.HasOnStop).
+		Msg("Strategy callbacks validated")
+
+	return callbacks, nil
+}
+
+// detectCallbacks reports which callbacks a strategy's module globals define
+func detectCallbacks(result starlark.StringDict) *StrategyCallbacks {
 	callbacks := &StrategyCallbacks{}
 
 	if onKlineFn, ok := result["on_kline"]; ok {
@@ -125,6 +257,12 @@ func (se *StrategyEngine) ValidateCallbacks(strategyName string) (*StrategyCallb
 		}
 	}
 
+	if onKlineUpdateFn, ok := result["on_kline_update"]; ok {
+		if _, ok := onKlineUpdateFn.(*starlark.Function); ok {
+			callbacks.HasOnKlineUpdate = true
+		}
+	}
+
 	if onOrderBookFn, ok := result["on_orderbook"]; ok {
 		if _, ok := onOrderBookFn.(*starlark.Function); ok {
 			callbacks.HasOnOrderBook = true
@@ -137,6 +275,12 @@ func (se *StrategyEngine) ValidateCallbacks(strategyName string) (*StrategyCallb
 		}
 	}
 
+	if onBarFn, ok := result["on_bar"]; ok {
+		if _, ok := onBarFn.(*starlark.Function); ok {
+			callbacks.HasOnBar = true
+		}
+	}
+
 	if settingsFn, ok := result["settings"]; ok {
 		if _, ok := settingsFn.(*starlark.Function); ok {
 			callbacks.HasSettings = true
@@ -155,17 +299,7 @@ func (se *StrategyEngine) ValidateCallbacks(strategyName string) (*StrategyCallb
 		}
 	}
 
-	se.logger.Debug().
-		Str("strategy", strategyName).
-		Bool("has_on_kline", callbacks.HasOnKline).
-		Bool("has_on_orderbook", callbacks.HasOnOrderBook).
-		Bool("has_on_ticker", callbacks.HasOnTicker).
-		Bool("has_settings", callbacks.HasSettings).
-		Bool("has_on_start", callbacks.HasOnStart).
-		Bool("has_on_stop", callbacks.HasOnStop).
-		Msg("Strategy callbacks validated")
-
-	return callbacks, nil
+	return callbacks
 }
 
 // getOrLoadStrategy loads a strategy script and caches the compiled program and globals
@@ -182,41 +316,100 @@ func (se *StrategyEngine) getOrLoadStrategy(strategyName string) (*starlark.Prog
 		}
 	}
 
-	// Load the script using existing method
+	program, globals, script, err := se.compileStrategy(strategyName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Cache the compiled program and its initial globals
+	se.scriptCache[strategyName] = program
+	se.globalsCache[strategyName] = globals
+	se.sourceCache[strategyName] = script
+
+	se.logger.Debug().
+		Str("strategy", strategyName).
+		Msg("Strategy script loaded and cached")
+
+	// Return a copy of globals to avoid mutation issues
+	globalsCopy := make(starlark.StringDict)
+	for k, v := range globals {
+		globalsCopy[k] = v
+	}
+
+	return program, globalsCopy, nil
+}
+
+// compileStrategy loads a strategy script from disk, compiles it and runs its top level
+// to collect the function definitions
+func (se *StrategyEngine) compileStrategy(strategyName string) (*starlark.Program, starlark.StringDict, string, error) {
 	script, err := se.loadStrategy(strategyName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
+		return nil, nil, "", fmt.Errorf("failed to load strategy %s: %w", strategyName, err)
+	}
+
+	program, globals, err := se.compileSource(strategyName, script)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return program, globals, script, nil
+}
+
+// compileSource compiles strategy source and runs its top level to collect the function definitions
+func (se *StrategyEngine) compileSource(strategyName, script string) (*starlark.Program, starlark.StringDict, error) {
+	_, program, err := starlark.SourceProgram(scriptFilename(strategyName), script, se.builtin.Has)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile strategy %s: %w", strategyName, err)
 	}
 
-	// Create thread for compilation
 	thread := &starlark.Thread{
 		Name: fmt.Sprintf("strategy-%s-init", strategyName),
 	}
 
-	// Prepare basic globals for initial execution
-	globals := make(starlark.StringDict)
-	for k, v := range se.builtin {
-		globals[k] = v
+	var globals starlark.StringDict
+	err = sandbox.Run(thread, se.limits, func() error {
+		var initErr error
+		globals, initErr = program.Init(thread, se.builtin)
+		return initErr
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute strategy %s: %w", strategyName, err)
 	}
 
-	// Execute the script to get function definitions and initial state
-	globals, err = starlark.ExecFile(thread, strategyName+".star", script, globals)
+	// Module globals are shared by every callback, strategies keep mutable state with set_state()
+	globals.Freeze()
+
+	return program, globals, nil
+}
+
+// ReloadStrategy recompiles a strategy script from disk and swaps it into the cache.
+// The running version is kept when the new one fails to compile or defines no callbacks;
+// state stored with set_state() carries over to the new version.
+func (se *StrategyEngine) ReloadStrategy(strategyName string) (*StrategyCallbacks, error) {
+	program, globals, script, err := se.compileStrategy(strategyName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to execute strategy %s: %w", strategyName, err)
+		return nil, err
+	}
+
+	callbacks := detectCallbacks(globals)
+	if !callbacks.HasOnKline && !callbacks.HasOnKlineUpdate && !callbacks.HasOnOrderBook && !callbacks.HasOnTicker && !callbacks.HasOnBar {
+		return nil, fmt.Errorf("strategy %s defines no on_kline, on_kline_update, on_orderbook, on_ticker or on_bar callback", strategyName)
 	}
 
-	// Cache the initial globals
+	se.scriptCache[strategyName] = program
 	se.globalsCache[strategyName] = globals
+	se.sourceCache[strategyName] = script
 
-	se.logger.Debug().
+	se.logger.Info().
 		Str("strategy", strategyName).
-		Msg("Strategy script loaded and cached")
+		Msg("Strategy script reloaded")
 
-	// Return a copy of globals to avoid mutation issues
-	globalsCopy := make(starlark.StringDict)
-	for k, v := range globals {
-		globalsCopy[k] = v
-	}
+	return callbacks, nil
+}
 
-	return nil, globalsCopy, nil
+// strategySource returns the source of the cached strategy version, loading it on first use
+func (se *StrategyEngine) strategySource(strategyName string) (string, error) {
+	if _, _, err := se.getOrLoadStrategy(strategyName); err != nil {
+		return "", err
+	}
+	return se.sourceCache[strategyName], nil
 }
@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.starlark.net/starlark"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+const klineUpdateScript = `
+def on_kline(kline):
+    set_state("closed", get_state("closed", 0) + 1)
+    return {"action": "hold", "reason": "on_kline:" + str(kline.closed)}
+
+def on_kline_update(kline):
+    set_state("updates", get_state("updates", 0) + 1)
+    return {"action": "hold", "reason": "on_kline_update:" + str(kline.closed)}
+`
+
+func TestKlineUpdateCallback(t *testing.T) {
+	writeTestStrategy(t, "test_kline_update", klineUpdateScript)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	callbacks, err := engine.ValidateCallbacks("test_kline_update")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !callbacks.HasOnKline || !callbacks.HasOnKlineUpdate {
+		t.Fatalf("expected on_kline and on_kline_update, got %+v", callbacks)
+	}
+
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1m", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1m", Timestamp: time.Now()}
+
+	signal, err := engine.ExecuteKlineUpdateCallback("test_kline_update", ctx, kline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signal.Reason != "on_kline_update:False" {
+		t.Errorf("unexpected callback result %q", signal.Reason)
+	}
+
+	kline.IsClosed = true
+	signal, err = engine.ExecuteKlineCallback("test_kline_update", ctx, kline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signal.Reason != "on_kline:True" {
+		t.Errorf("unexpected callback result %q", signal.Reason)
+	}
+}
+
+func TestOnKlineDataSeparatesClosedBars(t *testing.T) {
+	writeTestStrategy(t, "test_kline_routing", klineUpdateScript)
+
+	actor := New("test_kline_routing", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+	callbacks, err := actor.engine.ValidateCallbacks("test_kline_routing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actor.callbacks = callbacks
+	actor.interval = "1m"
+	actor.timeframes = map[string]int{"1m": DefaultBufferDepth}
+	actor.initialized = true
+	actor.running = true
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	send := func(open time.Time, close float64, closed bool) {
+		actor.onKlineData(nil, KlineDataMsg{Kline: &exchanges.Kline{
+			Symbol: "BTCUSDT", Interval: "1m", Timestamp: open, Open: 100, High: close, Low: 100, Close: close, IsClosed: closed,
+		}})
+	}
+
+	// Intra-bar ticks and the closing update of one bar, then the first tick of the next
+	send(start, 101, false)
+	send(start, 102, false)
+	send(start, 103, true)
+	send(start.Add(time.Minute), 104, false)
+
+	buffer := actor.primaryKlines()
+	if len(buffer) != 2 {
+		t.Fatalf("expected one buffered kline per open time, got %d", len(buffer))
+	}
+	if buffer[0].Close != 103 || buffer[1].Close != 104 {
+		t.Errorf("expected the latest update per bar, got closes %v and %v", buffer[0].Close, buffer[1].Close)
+	}
+
+	state := actor.engine.stateCache["test_kline_routing"]
+	for key, expected := range map[string]int{"closed": 1, "updates": 3} {
+		value, found, _ := state.Get(starlark.String(key))
+		if !found || value.(starlark.Int).String() != starlark.MakeInt(expected).String() {
+			t.Errorf("expected %s called %d times, got %v", key, expected, value)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package strategy
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/pkg/metrics"
+)
+
+var (
+	callbackDuration = metrics.NewHistogram("mercantile_strategy_callback_duration_seconds",
+		"Strategy callback latency.", nil, "strategy", "symbol", "callback")
+	callbackErrors = metrics.NewCounter("mercantile_strategy_callback_errors_total",
+		"Strategy callbacks that returned an error.", "strategy", "symbol", "callback")
+)
+
+// SendMarketData sends a KlineDataMsg, OrderBookDataMsg or TickerDataMsg to a strategy actor,
+// counting it in the actor's mailbox depth until it is received
+func SendMarketData(engine *actor.Engine, pid *actor.PID, msg interface{}) {
+	mailbox.Send(engine, pid, msg)
+}
+
+// observeCallback records the latency of a callback started at start, and counts and publishes err when set
+func (s *StrategyActor) observeCallback(callback string, start time.Time, err error) {
+	callbackDuration.With(s.strategyName, s.symbol, callback).Observe(time.Since(start).Seconds())
+	if err != nil {
+		callbackErrors.With(s.strategyName, s.symbol, callback).Inc()
+		s.publishCallbackError(callback, err)
+	}
+}
@@ -0,0 +1,136 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestExecuteBarCallback(t *testing.T) {
+	writeTestStrategy(t, "test_pairs_spread", `
+def settings():
+    return {"interval": "1h"}
+
+def on_bar(bars):
+    btc = bars["BTCUSDT"]
+    eth = bars["ETHUSDT"]
+    history = klines(symbol="ETHUSDT", limit=2)
+    if btc.close / eth.close > 10:
+        return [
+            {"action": "sell", "symbol": "BTCUSDT", "quantity": 0.1, "reason": str(len(symbols()))},
+            {"action": "buy", "symbol": "ETHUSDT", "quantity": 1.0, "reason": str(len(history))},
+        ]
+    return {"action": "hold"}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	callbacks, err := engine.ValidateCallbacks("test_pairs_spread")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !callbacks.HasOnBar {
+		t.Fatal("expected on_bar callback to be detected")
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	btc := makeKlines(start, time.Hour, 100, 110, 120)
+	eth := makeKlines(start, time.Hour, 10, 10, 10)
+	ctx := &StrategyContext{
+		Symbol:     "BTCUSDT",
+		Interval:   "1h",
+		Klines:     btc,
+		Timeframes: map[string][]*KlineData{"1h": btc},
+		Symbols:    []string{"BTCUSDT", "ETHUSDT"},
+		Legs: map[string]map[string][]*KlineData{
+			"BTCUSDT": {"1h": btc},
+			"ETHUSDT": {"1h": eth},
+		},
+		Config: map[string]interface{}{},
+	}
+
+	bars := map[string]*exchanges.Kline{
+		"BTCUSDT": {Symbol: "BTCUSDT", Interval: "1h", Close: 120, Timestamp: start},
+		"ETHUSDT": {Symbol: "ETHUSDT", Interval: "1h", Close: 10, Timestamp: start},
+	}
+
+	signals, err := engine.ExecuteBarCallback("test_pairs_spread", ctx, bars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(signals) != 2 {
+		t.Fatalf("expected 2 signals, got %d", len(signals))
+	}
+	if signals[0].Symbol != "BTCUSDT" || signals[0].Action != "sell" || signals[0].Reason != "2" {
+		t.Errorf("unexpected first signal %+v", signals[0])
+	}
+	if signals[1].Symbol != "ETHUSDT" || signals[1].Action != "buy" || signals[1].Reason != "2" {
+		t.Errorf("unexpected second signal %+v", signals[1])
+	}
+}
+
+func TestKlinesBuiltinUnknownSymbol(t *testing.T) {
+	writeTestStrategy(t, "test_unknown_leg", `
+def on_kline(kline):
+    klines(symbol="SOLUSDT")
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	ctx := &StrategyContext{
+		Symbol:   "BTCUSDT",
+		Interval: "1h",
+		Legs:     map[string]map[string][]*KlineData{"BTCUSDT": {}},
+		Config:   map[string]interface{}{},
+	}
+
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1h", Timestamp: time.Now()}
+	if _, err := engine.ExecuteKlineCallback("test_unknown_leg", ctx, kline); err == nil {
+		t.Error("expected error for a symbol that is not a leg")
+	}
+}
+
+func TestStrategyActorLegs(t *testing.T) {
+	actor := New("pairs", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+	actor.SetSymbols([]string{"ETHUSDT", "BTCUSDT", "ETHUSDT"})
+
+	if len(actor.symbols) != 2 || actor.symbols[0] != "BTCUSDT" || actor.symbols[1] != "ETHUSDT" {
+		t.Fatalf("unexpected legs %v", actor.symbols)
+	}
+
+	if symbol, err := actor.signalSymbol(&StrategySignal{}); err != nil || symbol != "BTCUSDT" {
+		t.Errorf("expected primary symbol for signal without symbol, got %q (%v)", symbol, err)
+	}
+	if symbol, err := actor.signalSymbol(&StrategySignal{Symbol: "ETHUSDT"}); err != nil || symbol != "ETHUSDT" {
+		t.Errorf("expected ETHUSDT, got %q (%v)", symbol, err)
+	}
+	if _, err := actor.signalSymbol(&StrategySignal{Symbol: "SOLUSDT"}); err == nil {
+		t.Error("expected error for signal on a symbol that is not a leg")
+	}
+}
+
+func TestLastCompletedBar(t *testing.T) {
+	actor := New("pairs", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+	actor.SetSymbols([]string{"ETHUSDT"})
+	actor.interval = "1h"
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	actor.klineBuffers["BTCUSDT"] = map[string][]*KlineData{"1h": makeKlines(start, time.Hour, 1, 2, 3)}
+
+	// A leg without data holds the bar back
+	if bar := actor.lastCompletedBar(); !bar.IsZero() {
+		t.Errorf("expected no completed bar while a leg has no data, got %v", bar)
+	}
+
+	// ETH lags one bar behind, so only the first bar has completed on both legs
+	actor.klineBuffers["ETHUSDT"] = map[string][]*KlineData{"1h": makeKlines(start, time.Hour, 1, 2)}
+	if bar := actor.lastCompletedBar(); !bar.Equal(start) {
+		t.Errorf("expected completed bar %v, got %v", start, bar)
+	}
+
+	actor.klineBuffers["ETHUSDT"]["1h"] = makeKlines(start, time.Hour, 1, 2, 3)
+	if bar := actor.lastCompletedBar(); !bar.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected completed bar %v, got %v", start.Add(time.Hour), bar)
+	}
+}
@@ -0,0 +1,99 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"go.starlark.net/starlark"
+)
+
+// Messages for querying the series a strategy plotted
+type (
+	GetPlotsMsg      struct{}
+	PlotsResponseMsg struct {
+		Interval string                 // Primary interval the series are keyed by
+		Series   map[string][]PlotPoint // Values per series name, oldest first
+	}
+)
+
+// PlotPoint is the value a strategy plotted for one primary bar
+type PlotPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// plotFunc records a named value for the current primary bar: plot(name, value).
+// Plotting the same series again within a bar replaces its value, None, NaN and infinity leave a gap.
+func (se *StrategyEngine) plotFunc(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "value", &value); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("plot(): name must not be empty")
+	}
+	if value == starlark.None {
+		return starlark.None, nil
+	}
+
+	number, ok := starlark.AsFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("plot(): value for %q must be a number, got %s", name, value.Type())
+	}
+	// Indicators return NaN before they have enough bars, JSON has no encoding for it
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return starlark.None, nil
+	}
+
+	// Plots belong to the latest primary bar, nothing to attach them to before the first kline
+	klines, _ := thread.Local("primary_klines").([]*KlineData)
+	if len(klines) == 0 || se.strategyActor == nil {
+		return starlark.None, nil
+	}
+
+	se.strategyActor.recordPlot(name, klines[len(klines)-1].Timestamp, number)
+	return starlark.None, nil
+}
+
+// recordPlot stores a plotted value, replacing the value of the same bar and trimming to the buffer depth
+func (s *StrategyActor) recordPlot(name string, timestamp time.Time, value float64) {
+	series := s.plots[name]
+	i := sort.Search(len(series), func(i int) bool {
+		return !series[i].Timestamp.Before(timestamp)
+	})
+
+	point := PlotPoint{Timestamp: timestamp, Value: value}
+	switch {
+	case i < len(series) && series[i].Timestamp.Equal(timestamp):
+		series[i] = point
+	case i == len(series):
+		series = append(series, point)
+	default:
+		series = append(series, PlotPoint{})
+		copy(series[i+1:], series[i:])
+		series[i] = point
+	}
+
+	if len(series) > MaxBufferDepth {
+		series = series[len(series)-MaxBufferDepth:]
+	}
+	s.plots[name] = series
+}
+
+// onGetPlots responds with a copy of every plotted series
+func (s *StrategyActor) onGetPlots(ctx *actor.Context) {
+	ctx.Respond(s.plotsResponse())
+}
+
+// plotsResponse copies the plotted series so they can leave the actor
+func (s *StrategyActor) plotsResponse() PlotsResponseMsg {
+	series := make(map[string][]PlotPoint, len(s.plots))
+	for name, points := range s.plots {
+		series[name] = append([]PlotPoint(nil), points...)
+	}
+	return PlotsResponseMsg{Interval: s.interval, Series: series}
+}
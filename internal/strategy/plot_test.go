@@ -0,0 +1,96 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestPlotBuiltin(t *testing.T) {
+	writeTestStrategy(t, "test_plot", `
+def on_kline(kline):
+    closes = [k["close"] for k in klines()]
+    if len(closes) >= 2:
+        plot("sma", sma(closes, 2)[-1])
+    plot("close", kline.close)
+    plot("close", kline.close * 2)
+    plot("gap", None)
+    plot("nan", float("nan"))
+    plot("inf", float("-inf"))
+`)
+
+	actor := New("test_plot", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+	actor.interval = "1h"
+	actor.engine.SetStrategyActor(actor)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	buffer := makeKlines(start, time.Hour, 10, 20)
+	for i, kline := range buffer {
+		actor.klineBuffers["BTCUSDT"] = map[string][]*KlineData{"1h": buffer[:i+1]}
+
+		exchangeKline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1h", Timestamp: kline.Timestamp, Close: kline.Close}
+		if _, err := actor.engine.ExecuteKlineCallback("test_plot", actor.newStrategyContext(), exchangeKline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	plots := actor.plotsResponse()
+	if plots.Interval != "1h" {
+		t.Errorf("expected interval 1h, got %q", plots.Interval)
+	}
+	for _, gap := range []string{"gap", "nan", "inf"} {
+		if _, exists := plots.Series[gap]; exists {
+			t.Errorf("expected %s to leave no point", gap)
+		}
+	}
+
+	closes := plots.Series["close"]
+	if len(closes) != 2 || !closes[1].Timestamp.Equal(start.Add(time.Hour)) || closes[1].Value != 40 {
+		t.Fatalf("expected one point per bar with the last value plotted, got %+v", closes)
+	}
+
+	sma := plots.Series["sma"]
+	if len(sma) != 1 || sma[0].Value != 15 {
+		t.Errorf("expected sma 15 on the second bar only, got %+v", sma)
+	}
+}
+
+func TestPlotBuiltinRejectsNonNumbers(t *testing.T) {
+	writeTestStrategy(t, "test_plot_invalid", `
+def on_kline(kline):
+    plot("label", "buy")
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1h", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1h", Timestamp: time.Now()}
+	if _, err := engine.ExecuteKlineCallback("test_plot_invalid", ctx, kline); err == nil {
+		t.Error("expected error for a non-numeric plot value")
+	}
+}
+
+func TestRecordPlotOrdersAndTrims(t *testing.T) {
+	actor := New("test", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	actor.recordPlot("value", start.Add(2*time.Hour), 3)
+	actor.recordPlot("value", start, 1)
+	actor.recordPlot("value", start.Add(time.Hour), 2)
+
+	series := actor.plots["value"]
+	for i, point := range series {
+		if point.Value != float64(i+1) {
+			t.Fatalf("expected points ordered by time, got %+v", series)
+		}
+	}
+
+	for i := 0; i < MaxBufferDepth+10; i++ {
+		actor.recordPlot("value", start.Add(time.Duration(i)*time.Minute), float64(i))
+	}
+	if len(actor.plots["value"]) != MaxBufferDepth {
+		t.Errorf("expected series trimmed to %d points, got %d", MaxBufferDepth, len(actor.plots["value"]))
+	}
+}
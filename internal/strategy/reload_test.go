@@ -0,0 +1,104 @@
+package strategy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestScriptWatcherScan(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.star")
+	if err := os.WriteFile(existing, []byte("x = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewScriptWatcher(dir, dir, filepath.Join(dir, "missing"))
+	if len(watcher.Dirs()) != 2 {
+		t.Errorf("expected duplicate directories to be dropped, got %v", watcher.Dirs())
+	}
+	if changed := watcher.Scan(); len(changed) != 0 {
+		t.Errorf("expected no changes right after creation, got %v", changed)
+	}
+
+	added := filepath.Join(dir, "added.star")
+	if err := os.WriteFile(added, []byte("x = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(existing, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := watcher.Scan()
+	if len(changed) != 2 || changed[0] != added || changed[1] != existing {
+		t.Errorf("expected [%s %s], got %v", added, existing, changed)
+	}
+	if changed := watcher.Scan(); len(changed) != 0 {
+		t.Errorf("expected changes to be reported once, got %v", changed)
+	}
+
+	if name := ScriptName(existing); name != "existing" {
+		t.Errorf("expected script name 'existing', got %q", name)
+	}
+}
+
+func TestReloadStrategy(t *testing.T) {
+	writeTestStrategy(t, "test_reload", `
+def on_kline(kline):
+    set_state("count", get_state("count", 0) + 1)
+    return {"action": "hold", "reason": "v1:" + str(get_state("count"))}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1m", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1m", Timestamp: time.Now()}
+
+	signal, err := engine.ExecuteKlineCallback("test_reload", ctx, kline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signal.Reason != "v1:1" {
+		t.Fatalf("unexpected result %q", signal.Reason)
+	}
+
+	// The cached version keeps running until the script is reloaded
+	writeTestStrategy(t, "test_reload", `
+def on_kline(kline):
+    set_state("count", get_state("count", 0) + 1)
+    return {"action": "hold", "reason": "v2:" + str(get_state("count"))}
+`)
+	signal, _ = engine.ExecuteKlineCallback("test_reload", ctx, kline)
+	if signal.Reason != "v1:2" {
+		t.Errorf("expected cached version before reload, got %q", signal.Reason)
+	}
+
+	if _, err := engine.ReloadStrategy("test_reload"); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	signal, _ = engine.ExecuteKlineCallback("test_reload", ctx, kline)
+	if signal.Reason != "v2:3" {
+		t.Errorf("expected new version with carried over state, got %q", signal.Reason)
+	}
+
+	// A broken version is rejected and the previous one keeps running
+	writeTestStrategy(t, "test_reload", `
+def on_kline(kline)
+    return {"action": "buy"}
+`)
+	if _, err := engine.ReloadStrategy("test_reload"); err == nil {
+		t.Error("expected reload of invalid script to fail")
+	}
+	signal, _ = engine.ExecuteKlineCallback("test_reload", ctx, kline)
+	if signal.Reason != "v2:4" {
+		t.Errorf("expected previous version after failed reload, got %q", signal.Reason)
+	}
+}
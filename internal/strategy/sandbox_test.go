@@ -0,0 +1,114 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/internal/sandbox"
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func TestSandboxStepBudget(t *testing.T) {
+	writeTestStrategy(t, "test_runaway", `
+def on_kline(kline):
+    total = 0
+    for i in range(50000):
+        for j in range(50000):
+            total += j
+    return {"action": "hold"}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	engine.SetSandboxLimits(sandbox.Limits{MaxSteps: 100000, Timeout: 5 * time.Second})
+
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1m", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1m", Timestamp: time.Now()}
+
+	_, err := engine.ExecuteKlineCallback("test_runaway", ctx, kline)
+	violation, ok := sandbox.IsViolation(err)
+	if !ok {
+		t.Fatalf("expected a sandbox violation, got %v", err)
+	}
+	if violation.Kind != sandbox.ViolationSteps {
+		t.Errorf("expected %s violation, got %s", sandbox.ViolationSteps, violation.Kind)
+	}
+}
+
+func TestSandboxRangeLength(t *testing.T) {
+	writeTestStrategy(t, "test_huge_range", `
+def on_kline(kline):
+    values = range(1000000000)
+    return {"action": "hold"}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	engine.SetSandboxLimits(sandbox.Limits{MaxSteps: 100000})
+
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1m", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1m", Timestamp: time.Now()}
+
+	_, err := engine.ExecuteKlineCallback("test_huge_range", ctx, kline)
+	if _, ok := sandbox.IsViolation(err); !ok {
+		t.Fatalf("expected range() beyond the step budget to be a sandbox violation, got %v", err)
+	}
+}
+
+func TestSandboxStateLimit(t *testing.T) {
+	writeTestStrategy(t, "test_state_growth", `
+def on_kline(kline):
+    history = get_state("history", [])
+    history.append(kline.close)
+    set_state("history", history)
+    return {"action": "hold"}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	engine.SetSandboxLimits(sandbox.Limits{MaxStateEntries: 5})
+
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "1m", Config: map[string]interface{}{}}
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1m", Close: 1, Timestamp: time.Now()}
+
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		_, err = engine.ExecuteKlineCallback("test_state_growth", ctx, kline)
+	}
+
+	violation, ok := sandbox.IsViolation(err)
+	if !ok || violation.Kind != sandbox.ViolationState {
+		t.Fatalf("expected %s violation once state grows past the limit, got %v", sandbox.ViolationState, err)
+	}
+}
+
+func TestRecordViolationDisablesStrategy(t *testing.T) {
+	actor := New("runaway", "BTCUSDT", "bybit", map[string]interface{}{}, nil, nil, zerolog.Nop())
+	actor.engine.SetSandboxLimits(sandbox.Limits{MaxViolations: 2})
+	actor.running = true
+	actor.initialized = true
+
+	// Plain script errors are not counted
+	actor.recordViolation(errPlain, "on_kline")
+	if actor.violations != 0 {
+		t.Fatalf("expected plain errors to be ignored, got %d violations", actor.violations)
+	}
+
+	violation := &sandbox.ViolationError{Kind: sandbox.ViolationTimeout, Err: errPlain}
+	actor.recordViolation(violation, "on_kline")
+	if actor.disabled || !actor.running {
+		t.Fatal("expected strategy to keep running after the first violation")
+	}
+
+	actor.recordViolation(violation, "on_kline")
+	if !actor.disabled || actor.running {
+		t.Fatal("expected strategy to be disabled after reaching the violation limit")
+	}
+
+	logs := actor.logs
+	if len(logs) == 0 || logs[len(logs)-1].Level != "error" {
+		t.Errorf("expected violations to be written to the strategy log, got %v", logs)
+	}
+}
+
+var errPlain = errors.New("script error")
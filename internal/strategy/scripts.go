@@ -0,0 +1,166 @@
+package strategy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultScriptDirs are the directories strategy scripts are loaded from, in lookup order
+var DefaultScriptDirs = []string{"strategy", "strategies"}
+
+// scriptNamePattern restricts script names to what can safely become a file name
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ScriptInfo describes a strategy script on disk
+type ScriptInfo struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// ScriptStore reads and writes strategy scripts. Scripts are looked up in its directories in order,
+// new scripts are created in the first one.
+type ScriptStore struct {
+	dirs []string
+}
+
+// NewScriptStore creates a store for the given directories, DefaultScriptDirs when none are given
+func NewScriptStore(dirs ...string) *ScriptStore {
+	if len(dirs) == 0 {
+		dirs = DefaultScriptDirs
+	}
+	return &ScriptStore{dirs: dirs}
+}
+
+// ValidScriptName reports whether name can be used as a strategy script name
+func ValidScriptName(name string) bool {
+	return scriptNamePattern.MatchString(name)
+}
+
+// scriptFilename is the file name of a strategy script, also used for positions in Starlark errors
+func scriptFilename(name string) string {
+	return name + ".star"
+}
+
+// List returns every script, sorted by name. A script present in several directories is listed once,
+// from the directory it is loaded from.
+func (s *ScriptStore) List() ([]ScriptInfo, error) {
+	scripts := make(map[string]ScriptInfo)
+	for _, dir := range s.dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name := ScriptName(entry.Name())
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".star") || !ValidScriptName(name) {
+				continue
+			}
+			if _, exists := scripts[name]; exists {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			scripts[name] = ScriptInfo{
+				Name:     name,
+				Path:     filepath.Join(dir, entry.Name()),
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+			}
+		}
+	}
+
+	list := make([]ScriptInfo, 0, len(scripts))
+	for _, script := range scripts {
+		list = append(list, script)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Read returns the source of a script, os.ErrNotExist when there is none
+func (s *ScriptStore) Read(name string) (string, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Exists reports whether a script with this name exists
+func (s *ScriptStore) Exists(name string) bool {
+	_, err := s.path(name)
+	return err == nil
+}
+
+// Write replaces the source of a script, or creates it in the first directory. The file is swapped in
+// with a rename, so the script watcher never reloads a partially written script.
+func (s *ScriptStore) Write(name, source string) (string, error) {
+	if !ValidScriptName(name) {
+		return "", fmt.Errorf("invalid script name %q, use letters, digits and underscores", name)
+	}
+
+	path, err := s.path(name)
+	if err != nil {
+		if len(s.dirs) == 0 {
+			return "", fmt.Errorf("no script directory configured")
+		}
+		if err := os.MkdirAll(s.dirs[0], 0755); err != nil {
+			return "", err
+		}
+		path = filepath.Join(s.dirs[0], scriptFilename(name))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+name+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(source); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// path returns the file a script is loaded from
+func (s *ScriptStore) path(name string) (string, error) {
+	if !ValidScriptName(name) {
+		return "", fmt.Errorf("invalid script name %q, use letters, digits and underscores", name)
+	}
+	for _, dir := range s.dirs {
+		path := filepath.Join(dir, scriptFilename(name))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("strategy script %s: %w", name, os.ErrNotExist)
+}
@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptStore(t *testing.T) {
+	primary := t.TempDir()
+	secondary := t.TempDir()
+	store := NewScriptStore(primary, secondary)
+
+	if err := os.WriteFile(filepath.Join(secondary, "existing.star"), []byte("# old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing scripts are updated where they are, new scripts go to the first directory
+	path, err := store.Write("existing", "# new")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != filepath.Join(secondary, "existing.star") {
+		t.Errorf("expected existing script to be updated in place, got %s", path)
+	}
+
+	path, err = store.Write("created", "# created")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != filepath.Join(primary, "created.star") {
+		t.Errorf("expected new script in the first directory, got %s", path)
+	}
+
+	source, err := store.Read("existing")
+	if err != nil || source != "# new" {
+		t.Errorf("expected updated source, got %q (%v)", source, err)
+	}
+	if _, err := store.Read("missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist for a missing script, got %v", err)
+	}
+
+	scripts, err := store.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scripts) != 2 || scripts[0].Name != "created" || scripts[1].Name != "existing" {
+		t.Errorf("expected created and existing scripts, got %+v", scripts)
+	}
+
+	entries, _ := os.ReadDir(primary)
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
+
+func TestValidScriptName(t *testing.T) {
+	for _, name := range []string{"simple_sma", "RSI2", "a"} {
+		if !ValidScriptName(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "../secrets", "sma.star", "my strategy", "a/b"} {
+		if ValidScriptName(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+
+	if _, err := NewScriptStore(t.TempDir()).Write("../escape", "# x"); err == nil {
+		t.Error("expected error for a script name with a path")
+	}
+}
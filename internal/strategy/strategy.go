@@ -2,11 +2,18 @@ package strategy
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 
+	"github.com/arijanluiken/mercantile/internal/audit"
+	"github.com/arijanluiken/mercantile/internal/mailbox"
+	"github.com/arijanluiken/mercantile/internal/notifier"
+	"github.com/arijanluiken/mercantile/internal/sandbox"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
 	"github.com/arijanluiken/mercantile/pkg/exchanges"
@@ -22,10 +29,12 @@ type (
 	StatusMsg          struct{}
 	ExecuteStrategyMsg struct{}
 	GetLogsMsg         struct{ Limit int }
+	ReloadStrategyMsg  struct{} // The strategy script changed on disk
 	LogsResponseMsg    struct{ Logs []StrategyLog }
 	// Message sent from strategy to exchange actor to register subscription preferences
 	StrategySubscriptionMsg struct {
 		Symbol   string
+		Symbols  []string // Every leg of a multi-symbol strategy, routed to the same actor
 		Interval string
 	}
 )
@@ -41,23 +50,30 @@ type StrategyLog struct {
 // StrategyActor executes trading strategies using Starlark
 type StrategyActor struct {
 	strategyName string
-	symbol       string
+	symbol       string   // Primary symbol, also used to key the actor
+	symbols      []string // Every leg the strategy trades, primary symbol first
 	exchangeName string
 	config       map[string]interface{}
 	appConfig    *config.Config
 	db           *database.DB
 	logger       zerolog.Logger
 	running      bool
-	initialized  bool   // Whether strategy has been initialized with historical data
-	interval     string // Interval from strategy script
+	initialized  bool           // Whether strategy has been initialized with historical data
+	interval     string         // Primary interval from strategy script
+	timeframes   map[string]int // Buffer depth per subscribed interval, including the primary one
 
 	// Strategy execution
-	engine      *StrategyEngine
-	klineBuffer []*KlineData
-	orderBook   *exchanges.OrderBook
-	callbacks   *StrategyCallbacks // Cache of available callbacks
+	engine        *StrategyEngine
+	klineBuffers  map[string]map[string][]*KlineData // Kline buffers keyed by symbol, then interval
+	orderBook     *exchanges.OrderBook
+	callbacks     *StrategyCallbacks // Cache of available callbacks
+	lastSyncedBar time.Time          // Open time of the last bar delivered to on_bar
+	reloadPending bool               // A changed script is waiting for the next bar boundary
+	violations    int                // Sandbox violations since the script was loaded
+	disabled      bool               // Disabled after too many sandbox violations
 
 	// Parent actor references
+	actorSystem     *actor.Engine // Set when started, used to publish notifications
 	orderManagerPID *actor.PID
 	riskManagerPID  *actor.PID
 	exchangePID     *actor.PID // Reference to parent exchange actor
@@ -65,6 +81,9 @@ type StrategyActor struct {
 	// Log storage (in-memory circular buffer)
 	logs    []StrategyLog
 	maxLogs int
+
+	// Series recorded with plot(), keyed by name, one point per primary bar
+	plots map[string][]PlotPoint
 }
 
 // New creates a new strategy actor
@@ -72,15 +91,18 @@ func New(strategyName, symbol, exchangeName string, strategyConfig map[string]in
 	return &StrategyActor{
 		strategyName: strategyName,
 		symbol:       symbol,
+		symbols:      []string{symbol},
 		exchangeName: exchangeName,
 		config:       strategyConfig,
 		appConfig:    appConfig,
 		db:           db,
 		logger:       logger,
-		engine:       NewStrategyEngine(logger),
-		klineBuffer:  make([]*KlineData, 0, 100), // Keep last 100 klines
-		logs:         make([]StrategyLog, 0),     // Initialize logs slice
-		maxLogs:      100,                        // Keep last 100 log entries
+		engine:       newSandboxedEngine(appConfig, logger),
+		timeframes:   make(map[string]int),
+		klineBuffers: make(map[string]map[string][]*KlineData),
+		logs:         make([]StrategyLog, 0), // Initialize logs slice
+		maxLogs:      100,                    // Keep last 100 log entries
+		plots:        make(map[string][]PlotPoint),
 	}
 }
 
@@ -91,8 +113,34 @@ func (s *StrategyActor) SetParentActors(orderManagerPID, riskManagerPID, exchang
 	s.exchangePID = exchangePID
 }
 
+// newSandboxedEngine creates a strategy engine using the configured sandbox limits
+func newSandboxedEngine(appConfig *config.Config, logger zerolog.Logger) *StrategyEngine {
+	engine := NewStrategyEngine(logger)
+	if appConfig != nil {
+		engine.SetSandboxLimits(sandbox.NewLimits(appConfig.Strategies.Sandbox))
+	}
+	return engine
+}
+
+// SetSymbols sets the legs of a multi-symbol strategy. The primary symbol always stays first.
+func (s *StrategyActor) SetSymbols(symbols []string) {
+	legs := []string{s.symbol}
+	for _, symbol := range symbols {
+		if symbol != "" && !containsSymbol(legs, symbol) {
+			legs = append(legs, symbol)
+		}
+	}
+	s.symbols = legs
+}
+
 // Receive handles incoming messages
 func (s *StrategyActor) Receive(ctx *actor.Context) {
+	// Market data arrives through SendMarketData, which counted it as queued
+	switch ctx.Message().(type) {
+	case KlineDataMsg, OrderBookDataMsg, TickerDataMsg:
+		mailbox.Received(ctx)
+	}
+
 	switch msg := ctx.Message().(type) {
 	case actor.Started:
 		s.onStarted(ctx)
@@ -116,6 +164,10 @@ func (s *StrategyActor) Receive(ctx *actor.Context) {
 		s.onStatus(ctx)
 	case GetLogsMsg:
 		s.onGetLogs(ctx, msg)
+	case ReloadStrategyMsg:
+		s.onReloadStrategy(ctx)
+	case GetPlotsMsg:
+		s.onGetPlots(ctx)
 	default:
 		// Reduced chattiness - only log unknown message types occasionally
 		s.logger.Info().
@@ -130,6 +182,8 @@ func (s *StrategyActor) onStarted(ctx *actor.Context) {
 		Str("symbol", s.symbol).
 		Msg("Strategy actor started")
 
+	s.actorSystem = ctx.Engine()
+
 	// Set strategy actor reference in engine for logging
 	s.engine.SetStrategyActor(s)
 
@@ -159,7 +213,7 @@ func (s *StrategyActor) onStartStrategy(ctx *actor.Context) {
 
 	// Initialize strategy engine if not already done
 	if s.engine == nil {
-		s.engine = NewStrategyEngine(s.logger)
+		s.engine = newSandboxedEngine(s.appConfig, s.logger)
 	}
 
 	// Validate which callbacks are available in the strategy
@@ -174,8 +228,10 @@ func (s *StrategyActor) onStartStrategy(ctx *actor.Context) {
 	s.logger.Debug().
 		Str("strategy", s.strategyName).
 		Bool("has_on_kline", callbacks.HasOnKline).
+		Bool("has_on_kline_update", callbacks.HasOnKlineUpdate).
 		Bool("has_on_orderbook", callbacks.HasOnOrderBook).
 		Bool("has_on_ticker", callbacks.HasOnTicker).
+		Bool("has_on_bar", callbacks.HasOnBar).
 		Bool("has_on_start", callbacks.HasOnStart).
 		Bool("has_on_stop", callbacks.HasOnStop).
 		Msg("Strategy callbacks validated")
@@ -204,44 +260,49 @@ func (s *StrategyActor) onStartStrategy(ctx *actor.Context) {
 		s.interval = defaultInterval
 	}
 
+	// Collect additional timeframes declared in settings()
+	s.configureTimeframes()
+
 	s.logger.Debug().
 		Str("strategy", s.strategyName).
 		Str("symbol", s.symbol).
 		Str("interval", s.interval).
+		Interface("timeframes", s.timeframes).
 		Msg("Strategy interval extracted from script")
 
-	s.addLog("info", fmt.Sprintf("Strategy %s initializing for %s", s.strategyName, s.symbol), map[string]interface{}{
-		"strategy": s.strategyName,
-		"symbol":   s.symbol,
-		"interval": s.interval,
+	s.addLog("info", fmt.Sprintf("Strategy %s initializing for %s", s.strategyName, strings.Join(s.symbols, ", ")), map[string]interface{}{
+		"strategy":   s.strategyName,
+		"symbol":     s.symbol,
+		"symbols":    s.symbols,
+		"interval":   s.interval,
+		"timeframes": s.timeframes,
 	})
 
 	// Call on_start callback if available
 	if callbacks.HasOnStart {
-		strategyCtx := &StrategyContext{
-			Symbol:    s.symbol,
-			Exchange:  s.exchangeName,
-			Klines:    s.klineBuffer,
-			OrderBook: s.orderBook,
-			Config:    s.config,
-			// TODO: Add balances, positions, open orders from exchange
-		}
+		strategyCtx := s.newStrategyContext()
+		start := time.Now()
 		err := s.engine.ExecuteStartCallback(s.strategyName, strategyCtx)
+		s.observeCallback("on_start", start, err)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("Failed to execute on_start callback")
+			s.recordViolation(err, "on_start")
 		}
 	}
 
-	// Register subscription with exchange actor for efficient routing
+	// Register subscriptions with exchange actor for efficient routing
 	if s.exchangePID != nil {
-		ctx.Send(s.exchangePID, StrategySubscriptionMsg{
-			Symbol:   s.symbol,
-			Interval: s.interval,
-		})
-		s.logger.Debug().
-			Str("symbol", s.symbol).
-			Str("interval", s.interval).
-			Msg("Registered strategy subscription with exchange")
+		for interval := range s.timeframes {
+			ctx.Send(s.exchangePID, StrategySubscriptionMsg{
+				Symbol:   s.symbol,
+				Symbols:  s.symbols,
+				Interval: interval,
+			})
+			s.logger.Debug().
+				Strs("symbols", s.symbols).
+				Str("interval", interval).
+				Msg("Registered strategy subscription with exchange")
+		}
 	}
 
 	// Fetch historical klines to populate initial data before starting
@@ -264,18 +325,14 @@ func (s *StrategyActor) onStopStrategy(ctx *actor.Context) {
 
 	// Call on_stop callback if available
 	if s.callbacks != nil && s.callbacks.HasOnStop {
-		strategyCtx := &StrategyContext{
-			Symbol:    s.symbol,
-			Exchange:  s.exchangeName,
-			Klines:    s.klineBuffer,
-			OrderBook: s.orderBook,
-			Config:    s.config,
-			// TODO: Add balances, positions, open orders from exchange
-		}
+		strategyCtx := s.newStrategyContext()
+		start := time.Now()
 		err := s.engine.ExecuteStopCallback(s.strategyName, strategyCtx)
+		s.observeCallback("on_stop", start, err)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("Failed to execute on_stop callback")
 			s.addLog("error", fmt.Sprintf("Failed to execute on_stop callback: %v", err), nil)
+			s.recordViolation(err, "on_stop")
 		}
 	}
 
@@ -283,12 +340,21 @@ func (s *StrategyActor) onStopStrategy(ctx *actor.Context) {
 }
 
 func (s *StrategyActor) onKlineData(ctx *actor.Context, msg KlineDataMsg) {
-	// Always process klines that match our strategy's symbol and interval
-	if msg.Kline.Symbol != s.symbol || msg.Kline.Interval != s.interval {
+	// Always process klines that match one of our strategy's legs and one of its timeframes
+	depth, subscribed := s.timeframes[msg.Kline.Interval]
+	if !containsSymbol(s.symbols, msg.Kline.Symbol) || !subscribed {
 		// Reduced chattiness - don't log mismatches
 		return
 	}
 
+	// A new primary bar is the boundary at which a changed script is swapped in
+	if s.reloadPending && msg.Kline.Symbol == s.symbol && msg.Kline.Interval == s.interval {
+		primary := s.primaryKlines()
+		if len(primary) == 0 || msg.Kline.Timestamp.After(primary[len(primary)-1].Timestamp) {
+			s.reloadStrategy()
+		}
+	}
+
 	// Convert exchange kline to strategy kline
 	klineData := &KlineData{
 		Timestamp: msg.Kline.Timestamp,
@@ -299,41 +365,44 @@ func (s *StrategyActor) onKlineData(ctx *actor.Context, msg KlineDataMsg) {
 		Volume:    msg.Kline.Volume,
 	}
 
-	// Add to buffer
-	s.klineBuffer = append(s.klineBuffer, klineData)
-
-	// Keep only last 100 klines
-	if len(s.klineBuffer) > 100 {
-		s.klineBuffer = s.klineBuffer[1:]
+	// Add to the buffer for this leg and interval, trimmed to its declared depth
+	buffers := s.klineBuffers[msg.Kline.Symbol]
+	if buffers == nil {
+		buffers = make(map[string][]*KlineData)
+		s.klineBuffers[msg.Kline.Symbol] = buffers
 	}
+	buffers[msg.Kline.Interval] = upsertKline(buffers[msg.Kline.Interval], klineData, depth)
 
 	s.logger.Debug().
 		Str("symbol", msg.Kline.Symbol).
 		Str("interval", msg.Kline.Interval).
 		Time("timestamp", msg.Kline.Timestamp).
 		Float64("close", msg.Kline.Close).
-		Int("buffer_size", len(s.klineBuffer)).
+		Int("buffer_size", len(buffers[msg.Kline.Interval])).
 		Bool("running", s.running).
 		Bool("initialized", s.initialized).
 		Msg("Processing kline data for strategy")
 
 	// If this is the first time we have sufficient historical data, start the strategy
-	if !s.initialized && len(s.klineBuffer) >= 10 { // Require at least 10 klines before starting
+	if !s.initialized && !s.disabled && s.hasWarmupData(10) { // Require at least 10 primary klines per leg before starting
 		s.initialized = true
 		s.running = true
 
+		// Bars seen during warm-up are history, on_bar only receives bars completed from now on
+		s.lastSyncedBar = s.lastCompletedBar()
+
 		s.logger.Info().
 			Str("strategy", s.strategyName).
 			Str("symbol", s.symbol).
 			Str("interval", s.interval).
-			Int("historical_klines", len(s.klineBuffer)).
+			Int("historical_klines", len(s.primaryKlines())).
 			Msg("Strategy initialized with historical data and started")
 
-		s.addLog("info", fmt.Sprintf("Strategy %s started with %d historical klines for %s %s", s.strategyName, len(s.klineBuffer), s.symbol, s.interval), map[string]interface{}{
+		s.addLog("info", fmt.Sprintf("Strategy %s started with %d historical klines for %s %s", s.strategyName, len(s.primaryKlines()), s.symbol, s.interval), map[string]interface{}{
 			"strategy":         s.strategyName,
 			"symbol":           s.symbol,
 			"interval":         s.interval,
-			"historical_count": len(s.klineBuffer),
+			"historical_count": len(s.primaryKlines()),
 		})
 
 		// Start periodic strategy execution (every 30 seconds)
@@ -356,17 +425,24 @@ func (s *StrategyActor) onKlineData(ctx *actor.Context, msg KlineDataMsg) {
 	}
 
 	// Trigger strategy execution with kline callback if we have enough data
-	if len(s.klineBuffer) >= 1 {
+	if len(s.primaryKlines()) >= 1 {
 		s.executeKlineCallback(ctx, msg.Kline)
 	}
+
+	// Multi-symbol strategies get one synchronized callback per completed primary bar
+	if len(s.symbols) > 1 && msg.Kline.Interval == s.interval {
+		s.executeBarCallback(ctx)
+	}
 }
 
 func (s *StrategyActor) onOrderBookData(ctx *actor.Context, msg OrderBookDataMsg) {
-	if !s.running {
+	if !s.running || !containsSymbol(s.symbols, msg.OrderBook.Symbol) {
 		return
 	}
 
-	s.orderBook = msg.OrderBook
+	if msg.OrderBook.Symbol == s.symbol {
+		s.orderBook = msg.OrderBook
+	}
 
 	// Check if order book has at least some data (either bids or asks)
 	if len(msg.OrderBook.Bids) == 0 && len(msg.OrderBook.Asks) == 0 {
@@ -427,13 +503,13 @@ func (s *StrategyActor) onOrderBookData(ctx *actor.Context, msg OrderBookDataMsg
 
 	// Execute strategy with orderbook callback if we have enough data
 	// Strategy can decide how to handle partial order book data
-	if len(s.klineBuffer) >= 1 {
+	if len(s.primaryKlines()) >= 1 {
 		s.executeOrderBookCallback(ctx, msg.OrderBook)
 	}
 }
 
 func (s *StrategyActor) onTickerData(ctx *actor.Context, msg TickerDataMsg) {
-	if !s.running {
+	if !s.running || !containsSymbol(s.symbols, msg.Ticker.Symbol) {
 		return
 	}
 
@@ -454,13 +530,13 @@ func (s *StrategyActor) onTickerData(ctx *actor.Context, msg TickerDataMsg) {
 	}
 
 	// Execute strategy with ticker callback if we have enough data
-	if len(s.klineBuffer) >= 1 {
+	if len(s.primaryKlines()) >= 1 {
 		s.executeTickerCallback(ctx, msg.Ticker)
 	}
 }
 
 func (s *StrategyActor) onExecuteStrategy(ctx *actor.Context) {
-	if !s.running || len(s.klineBuffer) < 1 {
+	if !s.running || len(s.primaryKlines()) < 1 {
 		return
 	}
 
@@ -470,20 +546,16 @@ func (s *StrategyActor) onExecuteStrategy(ctx *actor.Context) {
 		Msg("Executing strategy")
 
 	// Prepare strategy context
-	strategyCtx := &StrategyContext{
-		Symbol:    s.symbol,
-		Exchange:  s.exchangeName,
-		Klines:    s.klineBuffer,
-		OrderBook: s.orderBook,
-		Config:    s.config,
-		// TODO: Add balances, positions, open orders from exchange
-	}
+	strategyCtx := s.newStrategyContext()
 
 	// Execute strategy
+	start := time.Now()
 	signal, err := s.engine.ExecuteStrategy(s.strategyName, strategyCtx)
+	s.observeCallback("execute", start, err)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Strategy execution failed")
 		s.addLog("error", fmt.Sprintf("Strategy execution failed: %v", err), nil)
+		s.recordViolation(err, "execute")
 		return
 	}
 
@@ -503,15 +575,23 @@ func (s *StrategyActor) onExecuteStrategy(ctx *actor.Context) {
 			"reason":   signal.Reason,
 		})
 
+		symbol, err := s.signalSymbol(signal)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("Discarding strategy signal")
+			s.addLog("error", fmt.Sprintf("Discarding signal: %v", err), nil)
+			return
+		}
+
 		// Send order to order manager (if we have reference)
 		if s.orderManagerPID != nil {
 			orderRequest := map[string]interface{}{
-				"symbol":   s.symbol,
+				"symbol":   symbol,
 				"side":     signal.Action,
 				"type":     signal.Type,
 				"quantity": signal.Quantity,
 				"price":    signal.Price,
 				"reason":   signal.Reason,
+				"strategy": s.strategyName,
 			}
 			ctx.Send(s.orderManagerPID, orderRequest)
 		}
@@ -519,7 +599,7 @@ func (s *StrategyActor) onExecuteStrategy(ctx *actor.Context) {
 		// Notify risk manager (if we have reference)
 		if s.riskManagerPID != nil {
 			riskCheck := map[string]interface{}{
-				"symbol":   s.symbol,
+				"symbol":   symbol,
 				"action":   signal.Action,
 				"quantity": signal.Quantity,
 				"price":    signal.Price,
@@ -533,9 +613,13 @@ func (s *StrategyActor) onStatus(ctx *actor.Context) {
 	status := map[string]interface{}{
 		"strategy_name":   s.strategyName,
 		"symbol":          s.symbol,
+		"symbols":         s.symbols,
 		"exchange":        s.exchangeName,
 		"running":         s.running,
-		"klines_buffered": len(s.klineBuffer),
+		"disabled":        s.disabled,
+		"violations":      s.violations,
+		"klines_buffered": len(s.primaryKlines()),
+		"timeframes":      s.bufferSizes(),
 		"has_orderbook":   s.orderBook != nil,
 		"timestamp":       time.Now(),
 	}
@@ -543,41 +627,44 @@ func (s *StrategyActor) onStatus(ctx *actor.Context) {
 	ctx.Respond(status)
 }
 
-// executeKlineCallback executes the strategy using the on_kline callback
+// executeKlineCallback executes the strategy using on_kline for closed bars and on_kline_update for in-progress ones
 func (s *StrategyActor) executeKlineCallback(ctx *actor.Context, kline *exchanges.Kline) {
-	if !s.running {
+	if !s.running || s.callbacks == nil {
 		return
 	}
 
-	// Check if on_kline callback exists before executing
-	if s.callbacks == nil || !s.callbacks.HasOnKline {
-		s.logger.Debug().Msg("Skipping kline callback - not implemented in strategy")
+	callback, execute, implemented := "on_kline", s.engine.ExecuteKlineCallback, s.callbacks.HasOnKline
+	if !kline.IsClosed {
+		callback, execute, implemented = "on_kline_update", s.engine.ExecuteKlineUpdateCallback, s.callbacks.HasOnKlineUpdate
+	}
+
+	// Check if the callback exists before executing
+	if !implemented {
+		s.logger.Debug().Str("callback", callback).Msg("Skipping kline callback - not implemented in strategy")
 		return
 	}
 
 	s.logger.Debug().
 		Str("strategy", s.strategyName).
 		Str("symbol", s.symbol).
+		Str("interval", kline.Interval).
+		Str("callback", callback).
 		Msg("Executing strategy with kline callback")
 
 	// Prepare strategy context
-	strategyCtx := &StrategyContext{
-		Symbol:    s.symbol,
-		Exchange:  s.exchangeName,
-		Klines:    s.klineBuffer,
-		OrderBook: s.orderBook,
-		Config:    s.config,
-		// TODO: Add balances, positions, open orders from exchange
-	}
+	strategyCtx := s.newStrategyContext()
 
 	// Execute strategy with kline callback
-	signal, err := s.engine.ExecuteKlineCallback(s.strategyName, strategyCtx, kline)
+	start := time.Now()
+	signal, err := execute(s.strategyName, strategyCtx, kline)
+	s.observeCallback(callback, start, err)
 	if err != nil {
-		s.logger.Error().Err(err).Msg("Strategy kline callback execution failed")
+		s.logger.Error().Err(err).Str("callback", callback).Msg("Strategy kline callback execution failed")
+		s.recordViolation(err, callback)
 		return
 	}
 
-	s.processStrategySignal(ctx, signal, "kline_callback")
+	s.processStrategySignal(ctx, signal, strings.TrimPrefix(callback, "on_")+"_callback", start)
 }
 
 // executeOrderBookCallback executes the strategy using the on_orderbook callback
@@ -598,23 +685,19 @@ func (s *StrategyActor) executeOrderBookCallback(ctx *actor.Context, orderBook *
 		Msg("Executing strategy with orderbook callback")
 
 	// Prepare strategy context
-	strategyCtx := &StrategyContext{
-		Symbol:    s.symbol,
-		Exchange:  s.exchangeName,
-		Klines:    s.klineBuffer,
-		OrderBook: s.orderBook,
-		Config:    s.config,
-		// TODO: Add balances, positions, open orders from exchange
-	}
+	strategyCtx := s.newStrategyContext()
 
 	// Execute strategy with orderbook callback
+	start := time.Now()
 	signal, err := s.engine.ExecuteOrderBookCallback(s.strategyName, strategyCtx, orderBook)
+	s.observeCallback("on_orderbook", start, err)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Strategy orderbook callback execution failed")
+		s.recordViolation(err, "on_orderbook")
 		return
 	}
 
-	s.processStrategySignal(ctx, signal, "orderbook_callback")
+	s.processStrategySignal(ctx, signal, "orderbook_callback", start)
 }
 
 // executeTickerCallback executes the strategy using the on_ticker callback
@@ -635,29 +718,34 @@ func (s *StrategyActor) executeTickerCallback(ctx *actor.Context, ticker *exchan
 		Msg("Executing strategy with ticker callback")
 
 	// Prepare strategy context
-	strategyCtx := &StrategyContext{
-		Symbol:    s.symbol,
-		Exchange:  s.exchangeName,
-		Klines:    s.klineBuffer,
-		OrderBook: s.orderBook,
-		Config:    s.config,
-		// TODO: Add balances, positions, open orders from exchange
-	}
+	strategyCtx := s.newStrategyContext()
 
 	// Execute strategy with ticker callback
+	start := time.Now()
 	signal, err := s.engine.ExecuteTickerCallback(s.strategyName, strategyCtx, ticker)
+	s.observeCallback("on_ticker", start, err)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Strategy ticker callback execution failed")
+		s.recordViolation(err, "on_ticker")
 		return
 	}
 
-	s.processStrategySignal(ctx, signal, "ticker_callback")
+	s.processStrategySignal(ctx, signal, "ticker_callback", start)
 }
 
-// processStrategySignal processes a strategy signal and sends orders to the order manager
-func (s *StrategyActor) processStrategySignal(ctx *actor.Context, signal *StrategySignal, source string) {
+// processStrategySignal processes a strategy signal and sends orders to the order manager.
+// started is when the callback that produced the signal started.
+func (s *StrategyActor) processStrategySignal(ctx *actor.Context, signal *StrategySignal, source string, started time.Time) {
 	if signal.Action != "hold" {
+		symbol, err := s.signalSymbol(signal)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("source", source).Msg("Discarding strategy signal")
+			s.addLog("error", fmt.Sprintf("Discarding signal from %s: %v", source, err), nil)
+			return
+		}
+
 		s.logger.Info().
+			Str("symbol", symbol).
 			Str("action", signal.Action).
 			Float64("quantity", signal.Quantity).
 			Float64("price", signal.Price).
@@ -666,15 +754,26 @@ func (s *StrategyActor) processStrategySignal(ctx *actor.Context, signal *Strate
 			Str("source", source).
 			Msg("Strategy generated signal")
 
+		audit.Record(s.actorSystem, audit.Entry{
+			Type:     audit.TypeSignal,
+			Exchange: s.exchangeName,
+			Symbol:   symbol,
+			Strategy: s.strategyName,
+			Actor:    "strategy",
+			Message:  fmt.Sprintf("%s %g %s", signal.Action, signal.Quantity, symbol),
+			Data:     s.signalInputs(signal, symbol, source, started),
+		})
+
 		// Send order to order manager (if we have reference)
 		if s.orderManagerPID != nil {
 			orderRequest := map[string]interface{}{
-				"symbol":   s.symbol,
+				"symbol":   symbol,
 				"side":     signal.Action,
 				"type":     signal.Type,
 				"quantity": signal.Quantity,
 				"price":    signal.Price,
 				"reason":   signal.Reason,
+				"strategy": s.strategyName,
 			}
 			ctx.Send(s.orderManagerPID, orderRequest)
 		}
@@ -682,7 +781,7 @@ func (s *StrategyActor) processStrategySignal(ctx *actor.Context, signal *Strate
 		// Notify risk manager (if we have reference)
 		if s.riskManagerPID != nil {
 			riskCheck := map[string]interface{}{
-				"symbol":   s.symbol,
+				"symbol":   symbol,
 				"action":   signal.Action,
 				"quantity": signal.Quantity,
 				"price":    signal.Price,
@@ -693,6 +792,180 @@ func (s *StrategyActor) processStrategySignal(ctx *actor.Context, signal *Strate
 	}
 }
 
+// signalInputs describes a signal with what it was computed from: the last kline of its symbol, the values
+// plotted for the current bar and the messages the script logged during the callback
+func (s *StrategyActor) signalInputs(signal *StrategySignal, symbol, source string, started time.Time) map[string]interface{} {
+	inputs := map[string]interface{}{
+		"action":   signal.Action,
+		"quantity": signal.Quantity,
+		"price":    signal.Price,
+		"type":     signal.Type,
+		"reason":   signal.Reason,
+		"source":   source,
+		"interval": s.interval,
+	}
+
+	if klines := s.klineBuffers[symbol][s.interval]; len(klines) > 0 {
+		kline := klines[len(klines)-1]
+		inputs["kline"] = map[string]interface{}{
+			"timestamp": kline.Timestamp,
+			"open":      kline.Open,
+			"high":      kline.High,
+			"low":       kline.Low,
+			"close":     kline.Close,
+			"volume":    kline.Volume,
+		}
+	}
+
+	if primary := s.primaryKlines(); len(primary) > 0 {
+		bar := primary[len(primary)-1].Timestamp
+		indicators := make(map[string]float64)
+		for name, series := range s.plots {
+			if len(series) == 0 {
+				continue
+			}
+			point := series[len(series)-1]
+			if point.Timestamp.Equal(bar) && !math.IsNaN(point.Value) && !math.IsInf(point.Value, 0) {
+				indicators[name] = point.Value
+			}
+		}
+		if len(indicators) > 0 {
+			inputs["indicators"] = indicators
+		}
+	}
+
+	var logs []string
+	for _, log := range s.logs {
+		if !log.Timestamp.Before(started) {
+			logs = append(logs, log.Message)
+		}
+	}
+	if len(logs) > 0 {
+		inputs["logs"] = logs
+	}
+	return inputs
+}
+
+// onReloadStrategy schedules a script reload. Running strategies swap at the next bar boundary,
+// strategies still warming up swap immediately.
+func (s *StrategyActor) onReloadStrategy(ctx *actor.Context) {
+	s.logger.Info().
+		Str("strategy", s.strategyName).
+		Bool("running", s.running).
+		Msg("Strategy script changed")
+
+	if !s.running {
+		s.reloadStrategy()
+		return
+	}
+
+	s.reloadPending = true
+	s.addLog("info", fmt.Sprintf("Strategy %s changed on disk, reloading at the next bar", s.strategyName), nil)
+}
+
+// reloadStrategy swaps in the current script version, keeping the running one if it is invalid
+func (s *StrategyActor) reloadStrategy() {
+	s.reloadPending = false
+
+	callbacks, err := s.engine.ReloadStrategy(s.strategyName)
+	if err != nil {
+		s.logger.Error().Err(err).Str("strategy", s.strategyName).Msg("Strategy reload failed, keeping previous version")
+		s.addLog("error", fmt.Sprintf("Reload failed, keeping previous version: %v", err), nil)
+		return
+	}
+	s.callbacks = callbacks
+	s.violations = 0
+
+	// A fixed script brings a strategy disabled by the sandbox back
+	if s.disabled {
+		s.disabled = false
+		s.running = s.initialized
+		s.addLog("info", fmt.Sprintf("Strategy %s re-enabled after reload", s.strategyName), nil)
+	}
+
+	s.logger.Info().
+		Str("strategy", s.strategyName).
+		Bool("has_on_kline", callbacks.HasOnKline).
+		Bool("has_on_kline_update", callbacks.HasOnKlineUpdate).
+		Bool("has_on_orderbook", callbacks.HasOnOrderBook).
+		Bool("has_on_ticker", callbacks.HasOnTicker).
+		Bool("has_on_bar", callbacks.HasOnBar).
+		Msg("Strategy reloaded")
+	s.addLog("info", fmt.Sprintf("Strategy %s reloaded", s.strategyName), nil)
+}
+
+// recordViolation counts sandbox violations and disables the strategy once the configured maximum is reached
+func (s *StrategyActor) recordViolation(err error, callback string) {
+	violation, ok := sandbox.IsViolation(err)
+	if !ok {
+		return
+	}
+
+	s.violations++
+	maxViolations := s.engine.SandboxLimits().MaxViolations
+
+	s.logger.Warn().
+		Str("strategy", s.strategyName).
+		Str("callback", callback).
+		Str("kind", violation.Kind).
+		Int("violations", s.violations).
+		Int("max_violations", maxViolations).
+		Msg("Strategy exceeded sandbox limit")
+
+	s.addLog("error", fmt.Sprintf("Sandbox violation in %s: %v", callback, violation), map[string]interface{}{
+		"callback":       callback,
+		"kind":           violation.Kind,
+		"violations":     s.violations,
+		"max_violations": maxViolations,
+	})
+
+	if maxViolations > 0 && s.violations >= maxViolations && !s.disabled {
+		s.disabled = true
+		s.running = false
+
+		s.logger.Error().
+			Str("strategy", s.strategyName).
+			Str("symbol", s.symbol).
+			Int("violations", s.violations).
+			Msg("Strategy disabled after repeated sandbox violations")
+
+		s.addLog("error", fmt.Sprintf("Strategy %s disabled after %d sandbox violations", s.strategyName, s.violations), map[string]interface{}{
+			"violations": s.violations,
+		})
+
+		notifier.Publish(s.actorSystem, notifier.Event{
+			Type:     notifier.EventKillSwitch,
+			Severity: notifier.SeverityCritical,
+			Title:    fmt.Sprintf("Strategy %s disabled on %s", s.strategyName, s.symbol),
+			Message:  fmt.Sprintf("Disabled after %d sandbox violations, the last in %s: %v", s.violations, callback, violation),
+			Exchange: s.exchangeName,
+			Symbol:   s.symbol,
+			Strategy: s.strategyName,
+			Fields: map[string]interface{}{
+				"state":      "tripped",
+				"kind":       violation.Kind,
+				"violations": s.violations,
+			},
+		})
+	}
+}
+
+// publishCallbackError notifies that a strategy callback failed
+func (s *StrategyActor) publishCallbackError(callback string, err error) {
+	notifier.Publish(s.actorSystem, notifier.Event{
+		Type:     notifier.EventStrategyError,
+		Severity: notifier.SeverityWarning,
+		Title:    fmt.Sprintf("Strategy %s failed in %s", s.strategyName, callback),
+		Message:  err.Error(),
+		Exchange: s.exchangeName,
+		Symbol:   s.symbol,
+		Strategy: s.strategyName,
+		Fields: map[string]interface{}{
+			"callback": callback,
+		},
+	})
+}
+
 // onGetLogs handles requests for strategy logs
 func (s *StrategyActor) onGetLogs(ctx *actor.Context, msg GetLogsMsg) {
 	limit := msg.Limit
@@ -731,33 +1004,223 @@ func (s *StrategyActor) addLog(level, message string, context map[string]interfa
 	}
 }
 
-// fetchHistoricalKlines fetches historical kline data from the exchange to populate initial strategy data
+// fetchHistoricalKlines fetches historical kline data from the exchange to seed every timeframe buffer
 func (s *StrategyActor) fetchHistoricalKlines(ctx *actor.Context) {
 	if s.exchangePID == nil {
 		s.logger.Warn().Msg("No exchange actor reference, skipping historical klines fetch")
 		return
 	}
 
-	// Import the message type from exchange package - we need to use a map to avoid import cycle
-	fetchMsg := map[string]interface{}{
-		"type":     "fetch_historical_klines",
-		"symbol":   s.symbol,
-		"interval": s.interval,
-		"limit":    100,
+	for _, symbol := range s.symbols {
+		for interval, depth := range s.timeframes {
+			// Import the message type from exchange package - we need to use a map to avoid import cycle
+			fetchMsg := map[string]interface{}{
+				"type":     "fetch_historical_klines",
+				"symbol":   symbol,
+				"interval": interval,
+				"limit":    depth,
+			}
+
+			// Request the last depth klines for this symbol/interval
+			ctx.Send(s.exchangePID, fetchMsg)
+
+			s.logger.Debug().
+				Str("symbol", symbol).
+				Str("interval", interval).
+				Int("limit", depth).
+				Msg("Requested historical klines from exchange")
+
+			s.addLog("info", fmt.Sprintf("Fetching historical data for %s %s", symbol, interval), map[string]interface{}{
+				"symbol":   symbol,
+				"interval": interval,
+				"limit":    depth,
+			})
+		}
+	}
+}
+
+// configureTimeframes builds the set of subscribed intervals from the primary interval and settings()
+func (s *StrategyActor) configureTimeframes() {
+	s.timeframes = map[string]int{s.interval: DefaultBufferDepth}
+
+	declared, err := s.engine.GetStrategyTimeframes(s.strategyName)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to read strategy timeframes, using primary interval only")
+		s.addLog("warning", fmt.Sprintf("Failed to read strategy timeframes: %v", err), nil)
+		return
+	}
+
+	for interval, depth := range declared {
+		s.timeframes[interval] = depth
+	}
+}
+
+// primaryKlines returns the buffer for the strategy's primary symbol and interval
+func (s *StrategyActor) primaryKlines() []*KlineData {
+	return s.klineBuffers[s.symbol][s.interval]
+}
+
+// hasWarmupData reports whether every leg has at least minimum klines on the primary interval
+func (s *StrategyActor) hasWarmupData(minimum int) bool {
+	for _, symbol := range s.symbols {
+		if len(s.klineBuffers[symbol][s.interval]) < minimum {
+			return false
+		}
+	}
+	return true
+}
+
+// bufferSizes reports how many primary symbol klines are buffered per interval
+func (s *StrategyActor) bufferSizes() map[string]int {
+	sizes := make(map[string]int, len(s.timeframes))
+	for interval := range s.timeframes {
+		sizes[interval] = len(s.klineBuffers[s.symbol][interval])
+	}
+	return sizes
+}
+
+// signalSymbol resolves which leg a signal trades, defaulting to the primary symbol
+func (s *StrategyActor) signalSymbol(signal *StrategySignal) (string, error) {
+	if signal.Symbol == "" {
+		return s.symbol, nil
+	}
+	if !containsSymbol(s.symbols, signal.Symbol) {
+		return "", fmt.Errorf("symbol %s is not one of the strategy's legs %v", signal.Symbol, s.symbols)
+	}
+	return signal.Symbol, nil
+}
+
+// lastCompletedBar returns the newest primary interval open time that every leg has moved past.
+// A bar counts as completed for a leg once a newer bar has started for it.
+func (s *StrategyActor) lastCompletedBar() time.Time {
+	var completed time.Time
+	for i, symbol := range s.symbols {
+		buffer := s.klineBuffers[symbol][s.interval]
+		if len(buffer) < 2 {
+			return time.Time{}
+		}
+		closed := buffer[len(buffer)-2].Timestamp
+		if i == 0 || closed.Before(completed) {
+			completed = closed
+		}
 	}
+	return completed
+}
 
-	// Request last 100 klines for this symbol/interval
-	ctx.Send(s.exchangePID, fetchMsg)
+// executeBarCallback delivers the latest bar completed on every leg to on_bar, once per open time
+func (s *StrategyActor) executeBarCallback(ctx *actor.Context) {
+	if !s.running || s.callbacks == nil || !s.callbacks.HasOnBar {
+		return
+	}
+
+	barTime := s.lastCompletedBar()
+	if barTime.IsZero() || !barTime.After(s.lastSyncedBar) {
+		return
+	}
+	s.lastSyncedBar = barTime
+
+	bars := make(map[string]*exchanges.Kline, len(s.symbols))
+	for _, symbol := range s.symbols {
+		kline := findKline(s.klineBuffers[symbol][s.interval], barTime)
+		if kline == nil {
+			s.logger.Debug().
+				Str("symbol", symbol).
+				Time("bar", barTime).
+				Msg("Leg has no kline for bar, skipping synchronized callback")
+			return
+		}
+		bars[symbol] = &exchanges.Kline{
+			Symbol:    symbol,
+			Interval:  s.interval,
+			Timestamp: kline.Timestamp,
+			Open:      kline.Open,
+			High:      kline.High,
+			Low:       kline.Low,
+			Close:     kline.Close,
+			Volume:    kline.Volume,
+			IsClosed:  true,
+		}
+	}
 
 	s.logger.Debug().
-		Str("symbol", s.symbol).
-		Str("interval", s.interval).
-		Int("limit", 100).
-		Msg("Requested historical klines from exchange")
+		Str("strategy", s.strategyName).
+		Strs("symbols", s.symbols).
+		Time("bar", barTime).
+		Msg("Executing strategy with bar callback")
 
-	s.addLog("info", fmt.Sprintf("Fetching historical data for %s %s", s.symbol, s.interval), map[string]interface{}{
-		"symbol":   s.symbol,
-		"interval": s.interval,
-		"limit":    100,
+	start := time.Now()
+	signals, err := s.engine.ExecuteBarCallback(s.strategyName, s.newStrategyContext(), bars)
+	s.observeCallback("on_bar", start, err)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Strategy bar callback execution failed")
+		s.addLog("error", fmt.Sprintf("Strategy bar callback execution failed: %v", err), nil)
+		s.recordViolation(err, "on_bar")
+		return
+	}
+
+	for _, signal := range signals {
+		s.processStrategySignal(ctx, signal, "bar_callback", start)
+	}
+}
+
+// newStrategyContext builds the execution context from the actor's current state
+func (s *StrategyActor) newStrategyContext() *StrategyContext {
+	return &StrategyContext{
+		Symbol:     s.symbol,
+		Exchange:   s.exchangeName,
+		Interval:   s.interval,
+		Klines:     s.primaryKlines(),
+		Timeframes: s.klineBuffers[s.symbol],
+		Symbols:    s.symbols,
+		Legs:       s.klineBuffers,
+		OrderBook:  s.orderBook,
+		Config:     s.config,
+		// TODO: Add balances, positions, open orders from exchange
+	}
+}
+
+// containsSymbol reports whether symbols includes symbol
+func containsSymbol(symbols []string, symbol string) bool {
+	for _, candidate := range symbols {
+		if candidate == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// findKline returns the kline opened at timestamp from an ordered buffer
+func findKline(buffer []*KlineData, timestamp time.Time) *KlineData {
+	i := sort.Search(len(buffer), func(i int) bool {
+		return !buffer[i].Timestamp.Before(timestamp)
+	})
+	if i < len(buffer) && buffer[i].Timestamp.Equal(timestamp) {
+		return buffer[i]
+	}
+	return nil
+}
+
+// upsertKline inserts a kline in open time order, replacing an existing kline with the same open time,
+// and trims the buffer to depth entries
+func upsertKline(buffer []*KlineData, kline *KlineData, depth int) []*KlineData {
+	i := sort.Search(len(buffer), func(i int) bool {
+		return !buffer[i].Timestamp.Before(kline.Timestamp)
 	})
+
+	switch {
+	case i < len(buffer) && buffer[i].Timestamp.Equal(kline.Timestamp):
+		buffer[i] = kline
+	case i == len(buffer):
+		buffer = append(buffer, kline)
+	default:
+		buffer = append(buffer, nil)
+		copy(buffer[i+1:], buffer[i:])
+		buffer[i] = kline
+	}
+
+	if depth > 0 && len(buffer) > depth {
+		buffer = buffer[len(buffer)-depth:]
+	}
+
+	return buffer
 }
@@ -0,0 +1,176 @@
+package strategy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+func writeTestStrategy(t *testing.T, name, script string) {
+	t.Helper()
+
+	strategyDir := "strategy"
+	if _, err := os.Stat(strategyDir); os.IsNotExist(err) {
+		if err := os.Mkdir(strategyDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	strategyPath := filepath.Join(strategyDir, name+".star")
+	if err := os.WriteFile(strategyPath, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(strategyPath) })
+}
+
+func makeKlines(start time.Time, step time.Duration, closes ...float64) []*KlineData {
+	klines := make([]*KlineData, len(closes))
+	for i, c := range closes {
+		klines[i] = &KlineData{
+			Timestamp: start.Add(time.Duration(i) * step),
+			Open:      c,
+			High:      c,
+			Low:       c,
+			Close:     c,
+			Volume:    1,
+		}
+	}
+	return klines
+}
+
+func TestGetStrategyTimeframes(t *testing.T) {
+	writeTestStrategy(t, "test_timeframes_dict", `
+def settings():
+    return {
+        "interval": "5m",
+        "timeframes": {"1h": 200, "4h": 5000},
+    }
+
+def on_kline(kline):
+    pass
+`)
+	writeTestStrategy(t, "test_timeframes_list", `
+def settings():
+    return {"interval": "5m", "timeframes": ["1h", "4h"]}
+`)
+	writeTestStrategy(t, "test_timeframes_none", `
+def settings():
+    return {"interval": "5m"}
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+
+	timeframes, err := engine.GetStrategyTimeframes("test_timeframes_dict")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeframes["1h"] != 200 {
+		t.Errorf("expected 1h depth 200, got %d", timeframes["1h"])
+	}
+	if timeframes["4h"] != MaxBufferDepth {
+		t.Errorf("expected 4h depth clamped to %d, got %d", MaxBufferDepth, timeframes["4h"])
+	}
+
+	timeframes, err = engine.GetStrategyTimeframes("test_timeframes_list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(timeframes) != 2 || timeframes["1h"] != DefaultBufferDepth || timeframes["4h"] != DefaultBufferDepth {
+		t.Errorf("expected 1h and 4h with default depth, got %v", timeframes)
+	}
+
+	timeframes, err = engine.GetStrategyTimeframes("test_timeframes_none")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(timeframes) != 0 {
+		t.Errorf("expected no extra timeframes, got %v", timeframes)
+	}
+}
+
+func TestKlinesBuiltinAndInterval(t *testing.T) {
+	writeTestStrategy(t, "test_multi_timeframe", `
+def settings():
+    return {"interval": "5m", "timeframes": {"1h": 200}}
+
+def on_kline(kline):
+    hourly = klines("1h", 2)
+    primary = klines()
+    return {
+        "action": "hold",
+        "reason": kline.interval + ":" + str(len(hourly)) + ":" + str(len(primary)) + ":" + str(hourly[-1]["close"]),
+    }
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary := makeKlines(start, 5*time.Minute, 1, 2, 3)
+
+	ctx := &StrategyContext{
+		Symbol:   "BTCUSDT",
+		Exchange: "bybit",
+		Interval: "5m",
+		Klines:   primary,
+		Timeframes: map[string][]*KlineData{
+			"5m": primary,
+			"1h": makeKlines(start, time.Hour, 10, 20, 30),
+		},
+		Config: map[string]interface{}{},
+	}
+
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "1h", Close: 30, Timestamp: start}
+	signal, err := engine.ExecuteKlineCallback("test_multi_timeframe", ctx, kline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signal.Reason != "1h:2:3:30.0" {
+		t.Errorf("unexpected callback result %q", signal.Reason)
+	}
+}
+
+func TestKlinesBuiltinUndeclaredInterval(t *testing.T) {
+	writeTestStrategy(t, "test_undeclared_timeframe", `
+def on_kline(kline):
+    klines("1d")
+`)
+
+	engine := NewStrategyEngine(zerolog.Nop())
+	ctx := &StrategyContext{Symbol: "BTCUSDT", Interval: "5m", Config: map[string]interface{}{}}
+
+	kline := &exchanges.Kline{Symbol: "BTCUSDT", Interval: "5m", Timestamp: time.Now()}
+	if _, err := engine.ExecuteKlineCallback("test_undeclared_timeframe", ctx, kline); err == nil {
+		t.Error("expected error for undeclared interval")
+	}
+}
+
+func TestUpsertKline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buffer []*KlineData
+
+	// Newest first, as some exchanges deliver history
+	history := makeKlines(start, time.Minute, 1, 2, 3, 4)
+	for i := len(history) - 1; i >= 0; i-- {
+		buffer = upsertKline(buffer, history[i], 3)
+	}
+
+	if len(buffer) != 3 {
+		t.Fatalf("expected buffer trimmed to 3, got %d", len(buffer))
+	}
+	for i := 1; i < len(buffer); i++ {
+		if !buffer[i-1].Timestamp.Before(buffer[i].Timestamp) {
+			t.Fatalf("buffer not ordered by open time at index %d", i)
+		}
+	}
+
+	// Same open time replaces the existing kline
+	update := &KlineData{Timestamp: start.Add(3 * time.Minute), Close: 42}
+	buffer = upsertKline(buffer, update, 3)
+	if len(buffer) != 3 || buffer[len(buffer)-1].Close != 42 {
+		t.Errorf("expected last kline replaced with close 42, got %v", buffer[len(buffer)-1].Close)
+	}
+}
@@ -0,0 +1,197 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/arijanluiken/mercantile/pkg/exchanges"
+)
+
+// Stages at which a script can fail validation
+const (
+	StageSyntax  = "syntax"  // The script does not compile
+	StageLoad    = "load"    // Running the top level failed
+	StageRuntime = "runtime" // A callback failed during the dry run
+)
+
+// ScriptError is a validation error with its position in the script, Line is 0 when unknown
+type ScriptError struct {
+	Stage    string `json:"stage"`
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Callback string `json:"callback,omitempty"`
+	Bar      int    `json:"bar,omitempty"` // 1-based dry-run bar the callback failed on
+}
+
+// DryRunResult summarizes a script's callbacks replayed over recent klines
+type DryRunResult struct {
+	Bars    int            `json:"bars"`
+	Signals map[string]int `json:"signals"` // Number of signals per action, hold included
+}
+
+// ValidationResult reports whether a script compiles, which callbacks it defines and how its dry run went
+type ValidationResult struct {
+	Valid     bool               `json:"valid"`
+	Interval  string             `json:"interval,omitempty"`
+	Callbacks *StrategyCallbacks `json:"callbacks,omitempty"`
+	DryRun    *DryRunResult      `json:"dry_run,omitempty"` // nil when no klines were available
+	Errors    []ScriptError      `json:"errors"`
+	Warnings  []string           `json:"warnings,omitempty"`
+}
+
+// ValidateScript checks unsaved strategy source: it must compile, define a trading callback and,
+// when klines are given, replay them through on_kline (or on_bar) without errors. The dry run uses
+// a separate engine with the same sandbox limits, so no state or cached version is touched.
+func (se *StrategyEngine) ValidateScript(strategyName, source, symbol string, klines []*KlineData) *ValidationResult {
+	result := &ValidationResult{
+		Interval: ScriptInterval(source),
+		Errors:   make([]ScriptError, 0),
+	}
+	filename := scriptFilename(strategyName)
+
+	engine := NewStrategyEngine(se.logger)
+	engine.SetSandboxLimits(se.limits)
+
+	program, globals, err := engine.compileSource(strategyName, source)
+	if err != nil {
+		stage := StageLoad
+		if isCompileError(err) {
+			stage = StageSyntax
+		}
+		result.Errors = append(result.Errors, newScriptError(stage, filename, err))
+		return result
+	}
+
+	callbacks, err := engine.validateCallbacks(strategyName, source)
+	if err != nil {
+		result.Errors = append(result.Errors, newScriptError(StageLoad, filename, err))
+		return result
+	}
+	result.Callbacks = callbacks
+
+	if !callbacks.HasOnKline && !callbacks.HasOnKlineUpdate && !callbacks.HasOnOrderBook && !callbacks.HasOnTicker && !callbacks.HasOnBar {
+		result.Errors = append(result.Errors, ScriptError{
+			Stage:   StageLoad,
+			Message: "strategy defines no on_kline, on_kline_update, on_orderbook, on_ticker or on_bar callback",
+		})
+		return result
+	}
+
+	if len(klines) > 0 && (callbacks.HasOnKline || callbacks.HasOnBar) {
+		engine.scriptCache[strategyName] = program
+		engine.globalsCache[strategyName] = globals
+		engine.sourceCache[strategyName] = source
+
+		dryRun, scriptErr := engine.dryRun(strategyName, symbol, result.Interval, callbacks, klines)
+		result.DryRun = dryRun
+		if scriptErr != nil {
+			result.Errors = append(result.Errors, *scriptErr)
+			return result
+		}
+	}
+
+	result.Valid = true
+	return result
+}
+
+// dryRun feeds klines one at a time to on_kline, or to on_bar for strategies without one,
+// stopping at the first error
+func (se *StrategyEngine) dryRun(strategyName, symbol, interval string, callbacks *StrategyCallbacks, klines []*KlineData) (*DryRunResult, *ScriptError) {
+	dryRun := &DryRunResult{Signals: make(map[string]int)}
+	filename := scriptFilename(strategyName)
+
+	for i, data := range klines {
+		buffer := klines[:i+1]
+		ctx := &StrategyContext{
+			Symbol:     symbol,
+			Interval:   interval,
+			Klines:     buffer,
+			Timeframes: map[string][]*KlineData{interval: buffer},
+			Symbols:    []string{symbol},
+			Legs:       map[string]map[string][]*KlineData{symbol: {interval: buffer}},
+			Config:     map[string]interface{}{},
+		}
+		kline := &exchanges.Kline{
+			Symbol:    symbol,
+			Interval:  interval,
+			Timestamp: data.Timestamp,
+			Open:      data.Open,
+			High:      data.High,
+			Low:       data.Low,
+			Close:     data.Close,
+			Volume:    data.Volume,
+			IsClosed:  true,
+		}
+
+		var signals []*StrategySignal
+		var err error
+		callback := "on_kline"
+		if callbacks.HasOnKline {
+			var signal *StrategySignal
+			signal, err = se.ExecuteKlineCallback(strategyName, ctx, kline)
+			if signal != nil {
+				signals = append(signals, signal)
+			}
+		} else {
+			callback = "on_bar"
+			signals, err = se.ExecuteBarCallback(strategyName, ctx, map[string]*exchanges.Kline{symbol: kline})
+		}
+		if err != nil {
+			scriptErr := newScriptError(StageRuntime, filename, err)
+			scriptErr.Callback = callback
+			scriptErr.Bar = i + 1
+			return dryRun, &scriptErr
+		}
+
+		dryRun.Bars++
+		for _, signal := range signals {
+			dryRun.Signals[signal.Action]++
+		}
+	}
+
+	return dryRun, nil
+}
+
+// isCompileError reports whether err comes from parsing or resolving the script
+func isCompileError(err error) bool {
+	var syntaxErr syntax.Error
+	var resolveErrs resolve.ErrorList
+	return errors.As(err, &syntaxErr) || errors.As(err, &resolveErrs)
+}
+
+// newScriptError converts a Starlark error into a ScriptError, taking the position from the
+// syntax error or from the innermost call frame inside the script
+func newScriptError(stage, filename string, err error) ScriptError {
+	scriptErr := ScriptError{Stage: stage, Message: err.Error()}
+
+	var syntaxErr syntax.Error
+	var resolveErrs resolve.ErrorList
+	var evalErr *starlark.EvalError
+	switch {
+	case errors.As(err, &syntaxErr):
+		scriptErr.Message = syntaxErr.Msg
+		scriptErr.Line, scriptErr.Column = int(syntaxErr.Pos.Line), int(syntaxErr.Pos.Col)
+	case errors.As(err, &resolveErrs):
+		scriptErr.Message = resolveErrs[0].Msg
+		scriptErr.Line, scriptErr.Column = int(resolveErrs[0].Pos.Line), int(resolveErrs[0].Pos.Col)
+		if len(resolveErrs) > 1 {
+			scriptErr.Message = fmt.Sprintf("%s (and %d more)", scriptErr.Message, len(resolveErrs)-1)
+		}
+	case errors.As(err, &evalErr):
+		scriptErr.Message = evalErr.Msg
+		for i := 0; i < len(evalErr.CallStack); i++ {
+			frame := evalErr.CallStack.At(i)
+			if frame.Pos.Filename() == filename {
+				scriptErr.Line, scriptErr.Column = int(frame.Pos.Line), int(frame.Pos.Col)
+				break
+			}
+		}
+	}
+
+	return scriptErr
+}
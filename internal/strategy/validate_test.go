@@ -0,0 +1,116 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestValidateScript(t *testing.T) {
+	engine := NewStrategyEngine(zerolog.Nop())
+	klines := makeKlines(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Hour, 10, 20, 30)
+
+	tests := []struct {
+		name   string
+		source string
+		valid  bool
+		stage  string
+		line   int
+		bar    int
+	}{
+		{
+			name: "valid",
+			source: `
+def settings():
+    return {
+        "interval": "1h",
+    }
+
+def on_kline(kline):
+    if kline.close > 15:
+        return {"action": "buy", "quantity": 1.0}
+    return {"action": "hold"}
+`,
+			valid: true,
+		},
+		{
+			name: "syntax error",
+			source: `
+def on_kline(kline):
+    size = 1 +* 2
+    return {"action": "hold"}
+`,
+			stage: StageSyntax,
+			line:  3,
+		},
+		{
+			name: "undefined name",
+			source: `
+def on_kline(kline):
+    return undefined_signal
+`,
+			stage: StageSyntax,
+			line:  3,
+		},
+		{
+			name: "no callbacks",
+			source: `
+def helper():
+    pass
+`,
+			stage: StageLoad,
+		},
+		{
+			name: "runtime error",
+			source: `
+def on_kline(kline):
+    closes = [k["close"] for k in klines()]
+    if len(closes) == 3:
+        return {"action": "buy", "quantity": closes[5]}
+    return {"action": "hold"}
+`,
+			stage: StageRuntime,
+			line:  5,
+			bar:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := engine.ValidateScript("test_validate", tt.source, "BTCUSDT", klines)
+			if result.Valid != tt.valid {
+				t.Fatalf("expected valid=%v, got %+v", tt.valid, result)
+			}
+			if tt.valid {
+				if result.DryRun == nil || result.DryRun.Bars != 3 || result.DryRun.Signals["buy"] != 2 || result.DryRun.Signals["hold"] != 1 {
+					t.Errorf("unexpected dry run %+v", result.DryRun)
+				}
+				if result.Interval != "1h" {
+					t.Errorf("expected interval 1h, got %q", result.Interval)
+				}
+				return
+			}
+
+			if len(result.Errors) != 1 {
+				t.Fatalf("expected one error, got %+v", result.Errors)
+			}
+			scriptErr := result.Errors[0]
+			if scriptErr.Stage != tt.stage || scriptErr.Line != tt.line || scriptErr.Bar != tt.bar {
+				t.Errorf("expected %s error on line %d bar %d, got %+v", tt.stage, tt.line, tt.bar, scriptErr)
+			}
+		})
+	}
+}
+
+func TestValidateScriptWithoutKlines(t *testing.T) {
+	engine := NewStrategyEngine(zerolog.Nop())
+	result := engine.ValidateScript("test_validate", `
+def on_kline(kline):
+    return {"action": "hold"}
+`, "BTCUSDT", nil)
+
+	if !result.Valid || result.DryRun != nil || !result.Callbacks.HasOnKline {
+		t.Errorf("expected a valid script without dry run, got %+v", result)
+	}
+}
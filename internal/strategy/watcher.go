@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScriptPollInterval controls how often script directories are checked for changes
+const ScriptPollInterval = 2 * time.Second
+
+// ScriptWatcher detects changed .star files by polling their modification times
+type ScriptWatcher struct {
+	dirs     []string
+	modTimes map[string]time.Time
+}
+
+// NewScriptWatcher creates a watcher for the given directories and records their current contents,
+// so only changes made after this call are reported
+func NewScriptWatcher(dirs ...string) *ScriptWatcher {
+	w := &ScriptWatcher{
+		modTimes: make(map[string]time.Time),
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		clean := filepath.Clean(dir)
+		if dir == "" || seen[clean] {
+			continue
+		}
+		seen[clean] = true
+		w.dirs = append(w.dirs, clean)
+	}
+
+	w.modTimes = w.snapshot()
+	return w
+}
+
+// Dirs returns the watched directories
+func (w *ScriptWatcher) Dirs() []string {
+	return w.dirs
+}
+
+// Scan returns the .star files that were created or modified since the previous scan, sorted by path
+func (w *ScriptWatcher) Scan() []string {
+	current := w.snapshot()
+
+	var changed []string
+	for path, modTime := range current {
+		if previous, exists := w.modTimes[path]; !exists || !previous.Equal(modTime) {
+			changed = append(changed, path)
+		}
+	}
+
+	w.modTimes = current
+	sort.Strings(changed)
+	return changed
+}
+
+// snapshot collects the modification time of every .star file in the watched directories.
+// Missing directories are skipped, they may be created later.
+func (w *ScriptWatcher) snapshot() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, dir := range w.dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".star") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			modTimes[filepath.Join(dir, entry.Name())] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// ScriptName returns the strategy name for a script path, its file name without the .star extension
+func ScriptName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".star")
+}
@@ -2,20 +2,34 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/arijanluiken/mercantile/internal/aggregator"
 	"github.com/arijanluiken/mercantile/internal/api"
+	"github.com/arijanluiken/mercantile/internal/audit"
+	"github.com/arijanluiken/mercantile/internal/configcheck"
 	"github.com/arijanluiken/mercantile/internal/exchange"
+	"github.com/arijanluiken/mercantile/internal/notifier"
 	"github.com/arijanluiken/mercantile/internal/ui"
 	"github.com/arijanluiken/mercantile/pkg/config"
 	"github.com/arijanluiken/mercantile/pkg/database"
+	"github.com/arijanluiken/mercantile/pkg/vault"
 )
 
+// ConfigPollInterval is how often the configuration file is checked for changes
+const ConfigPollInterval = 2 * time.Second
+
 // Messages for supervisor actor communication
 type (
 	StartMessage     struct{}
@@ -26,6 +40,17 @@ type (
 		Name   string
 		Config map[string]interface{}
 	}
+	ReloadConfigMsg struct{} // Reload the configuration file, sent on SIGHUP
+	CheckConfigMsg  struct{} // Poll the configuration file for changes
+
+	// ShutdownMsg runs the shutdown phases on every exchange until Deadline, responding with a ShutdownReport
+	ShutdownMsg struct {
+		Deadline time.Time
+	}
+	ShutdownReport struct {
+		Results  []exchange.ShutdownPhaseResult
+		TimedOut bool // Phases were skipped or did not respond before the deadline
+	}
 )
 
 // Supervisor manages all other actors in the system
@@ -35,7 +60,27 @@ type Supervisor struct {
 	exchangeActors map[string]*actor.PID
 	apiActor       *actor.PID
 	uiActor        *actor.PID
+	aggregator     *actor.PID
+	notifier       *actor.PID
+	journal        *actor.PID
 	db             *database.DB
+
+	// Unlocked once on start, nil when no passphrase was given and no exchange needs it
+	vault *vault.Vault
+
+	// Set by Start, used by Shutdown from outside the actor system
+	engine *actor.Engine
+	pid    *actor.PID
+
+	// Read by Shutdown, kept in sync with the configuration on every reload
+	shutdownTimeout atomic.Int64
+
+	configPoll   *actor.SendRepeater
+	shuttingDown bool
+
+	// Modification time and size of the configuration file when it was last loaded
+	configModTime time.Time
+	configSize    int64
 }
 
 // New creates a new supervisor actor
@@ -55,7 +100,18 @@ func (s *Supervisor) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := configcheck.Check(cfg); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
 	s.config = cfg
+	s.configModTime, s.configSize = configFileState()
+	s.shutdownTimeout.Store(int64(cfg.Shutdown.Timeout))
+
+	// Exchanges naming an account that cannot be opened are not started
+	s.vault, err = vault.UnlockOnce(cfg)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to unlock vault")
+	}
 
 	// Set global log level based on configuration
 	level, err := zerolog.ParseLevel(cfg.Logging.Level)
@@ -83,9 +139,27 @@ func (s *Supervisor) Start(ctx context.Context) error {
 		return s
 	}, "supervisor")
 
+	s.engine = engine
+	s.pid = supervisorPID
+
 	// Send start message to supervisor
 	engine.Send(supervisorPID, StartMessage{})
 
+	// Reload the configuration on SIGHUP
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				engine.Send(supervisorPID, ReloadConfigMsg{})
+			}
+		}
+	}()
+
 	s.logger.Info().Msg("Supervisor actor system started successfully")
 	return nil
 }
@@ -111,6 +185,12 @@ func (s *Supervisor) Receive(ctx *actor.Context) {
 		s.onRegisterExchange(ctx, msg)
 	case exchange.PortfolioActorCreatedMsg:
 		s.onPortfolioActorCreated(ctx, msg)
+	case CheckConfigMsg:
+		s.onCheckConfig(ctx)
+	case ReloadConfigMsg:
+		s.onReloadConfig(ctx)
+	case ShutdownMsg:
+		s.onShutdown(ctx, msg)
 	default:
 		s.logger.Warn().
 			Str("message_type", fmt.Sprintf("%T", msg)).
@@ -136,16 +216,36 @@ func (s *Supervisor) onInitialized(ctx *actor.Context) {
 func (s *Supervisor) onStart(ctx *actor.Context) {
 	s.logger.Debug().Msg("Starting child actors")
 
+	// Start notifier actor first so it is subscribed to events before the other actors publish them
+	notifierPID := ctx.SpawnChild(func() actor.Receiver {
+		return notifier.New(s.config, s.db, s.logger.With().Str("actor", "notifier").Logger())
+	}, "notifier")
+	s.notifier = notifierPID
+
+	// Start audit journal before the actors whose signals, risk checks and orders it records
+	journalPID := ctx.SpawnChild(func() actor.Receiver {
+		return audit.New(s.config, s.db, s.logger.With().Str("actor", "audit").Logger())
+	}, "audit")
+	s.journal = journalPID
+
+	// Start aggregator actor, it consolidates portfolios and risk across exchanges
+	aggregatorPID := ctx.SpawnChild(func() actor.Receiver {
+		return aggregator.New(s.config, s.logger.With().Str("actor", "aggregator").Logger())
+	}, "aggregator")
+	s.aggregator = aggregatorPID
+
 	// Start API actor
 	apiActorPID := ctx.SpawnChild(func() actor.Receiver {
 		apiActor := api.New(s.config, s.logger.With().Str("actor", "api").Logger())
 		// Pass database connection to API actor
 		if s.db != nil {
 			apiActor.SetDatabase(s.db.Conn())
+			apiActor.SetStore(s.db)
 		}
 		return apiActor
 	}, "api")
 	s.apiActor = apiActorPID
+	ctx.Send(apiActorPID, api.SetAggregatorActorMsg{AggregatorPID: aggregatorPID})
 
 	// Start UI actor
 	uiActorPID := ctx.SpawnChild(func() actor.Receiver {
@@ -160,23 +260,209 @@ func (s *Supervisor) onStart(ctx *actor.Context) {
 			continue
 		}
 
-		// Check if we have API credentials for this exchange
-		var hasCredentials bool
-		switch exchangeName {
-		case "bybit":
-			hasCredentials = s.config.BybitAPIKey != "" && s.config.BybitSecret != ""
-		case "bitvavo":
-			hasCredentials = s.config.BitvavoAPIKey != "" && s.config.BitvavoSecret != ""
+		s.startExchangeActor(ctx, s.vault, exchangeName, map[string]interface{}{
+			"enabled": exchangeConfig.Enabled,
+		})
+	}
+
+	// Apply changes to the configuration file without a restart
+	configPoll := ctx.SendRepeat(ctx.PID(), CheckConfigMsg{}, ConfigPollInterval)
+	s.configPoll = &configPoll
+}
+
+// configFileState returns the modification time and size of the configuration file, zero when it is missing
+func configFileState() (time.Time, int64) {
+	info, err := os.Stat(config.File)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+func (s *Supervisor) onCheckConfig(ctx *actor.Context) {
+	modTime, size := configFileState()
+	if modTime.Equal(s.configModTime) && size == s.configSize {
+		return
+	}
+	s.onReloadConfig(ctx)
+}
+
+// onReloadConfig loads and validates the configuration file and applies it to the running actors.
+// An invalid configuration is rejected as a whole and the running one stays in effect.
+func (s *Supervisor) onReloadConfig(ctx *actor.Context) {
+	if s.shuttingDown {
+		return
+	}
+	s.configModTime, s.configSize = configFileState()
+
+	cfg, err := config.Load()
+	if err == nil {
+		err = configcheck.Check(cfg)
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Rejected configuration change, keeping the running configuration")
+		return
+	}
+
+	for _, section := range config.RestartRequired(s.config, cfg) {
+		s.logger.Warn().Str("section", section).Msg("Configuration change requires a restart to take effect")
+	}
+
+	level, err := zerolog.ParseLevel(cfg.Logging.Level)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
+	// Actors keep reading the previous configuration until they receive the new one
+	s.config = cfg
+	s.shutdownTimeout.Store(int64(cfg.Shutdown.Timeout))
+
+	if s.aggregator != nil {
+		ctx.Send(s.aggregator, aggregator.UpdateConfigMsg{Config: cfg})
+	}
+	for _, pid := range s.exchangeActors {
+		ctx.Send(pid, exchange.ApplyConfigMsg{Config: cfg})
+	}
+
+	// Exchanges enabled since the last load start with the configured strategies
+	for exchangeName, exchangeConfig := range cfg.Exchanges {
+		if _, running := s.exchangeActors[exchangeName]; running || !exchangeConfig.Enabled {
+			continue
 		}
+		s.startExchangeActor(ctx, s.vault, exchangeName, map[string]interface{}{
+			"enabled": exchangeConfig.Enabled,
+		})
+	}
+
+	s.logger.Info().Msg("Configuration reloaded")
+}
+
+// Shutdown stops the bot in order: strategies run on_stop, open orders are left or cancelled according to
+// shutdown.order_policy, orders and portfolios are flushed, exchanges disconnect and finally every actor
+// stops and the database is closed. It returns an error when this did not finish within shutdown.timeout.
+func (s *Supervisor) Shutdown() error {
+	if s.engine == nil {
+		return nil
+	}
+
+	timeout := time.Duration(s.shutdownTimeout.Load())
+	if timeout <= 0 {
+		timeout = config.DefaultShutdownTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	var shutdownErr error
+	response, err := s.engine.Request(s.pid, ShutdownMsg{Deadline: deadline}, timeout).Result()
+	if err != nil {
+		shutdownErr = fmt.Errorf("shutdown phases did not finish within %s: %w", timeout, err)
+	} else if report, ok := response.(ShutdownReport); ok && report.TimedOut {
+		shutdownErr = fmt.Errorf("shutdown phases did not finish within %s", timeout)
+	}
+
+	// Stopping the supervisor stops its children first, then closes the database
+	stopCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	<-s.engine.PoisonCtx(stopCtx, s.pid).Done()
+	if errors.Is(stopCtx.Err(), context.DeadlineExceeded) && shutdownErr == nil {
+		shutdownErr = fmt.Errorf("actors did not stop within %s", timeout)
+	}
+
+	if shutdownErr != nil {
+		s.logger.Error().Err(shutdownErr).Msg("Shutdown timed out")
+		return shutdownErr
+	}
+	s.logger.Info().Dur("timeout", timeout).Msg("Shutdown complete")
+	return nil
+}
+
+// onShutdown runs each shutdown phase on all exchanges at once, moving to the next phase when every
+// exchange completed it. Progress is logged and reported to the health endpoint.
+func (s *Supervisor) onShutdown(ctx *actor.Context, msg ShutdownMsg) {
+	s.shuttingDown = true
+	if s.configPoll != nil {
+		s.configPoll.Stop()
+		s.configPoll = nil
+	}
 
-		if hasCredentials {
-			s.startExchangeActor(ctx, exchangeName, map[string]interface{}{
-				"enabled": exchangeConfig.Enabled,
-			})
-		} else {
-			s.logger.Warn().Str("exchange", exchangeName).Msg("Exchange enabled but missing API credentials")
+	policy := s.config.Shutdown.OrderPolicy
+	if policy == "" {
+		policy = config.OrderPolicyLeave
+	}
+	started := time.Now()
+	s.logger.Info().
+		Str("order_policy", policy).
+		Dur("timeout", time.Until(msg.Deadline)).
+		Int("exchanges", len(s.exchangeActors)).
+		Msg("Shutting down")
+
+	names := make([]string, 0, len(s.exchangeActors))
+	for name := range s.exchangeActors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var report ShutdownReport
+	for _, phase := range exchange.ShutdownPhases {
+		remaining := time.Until(msg.Deadline)
+		if remaining <= 0 {
+			report.TimedOut = true
+			s.logger.Error().Str("phase", phase).Msg("Shutdown deadline passed, skipping remaining phases")
+			break
 		}
+		s.reportShutdown(ctx, phase, started, report.Results)
+		s.logger.Info().Str("phase", phase).Msg("Shutdown phase started")
+
+		// Exchanges get most of the remaining time, so they respond before the request expires
+		phaseMsg := exchange.ShutdownPhaseMsg{Phase: phase, OrderPolicy: policy, Timeout: remaining * 9 / 10}
+		responses := make(map[string]*actor.Response, len(names))
+		for _, name := range names {
+			responses[name] = ctx.Request(s.exchangeActors[name], phaseMsg, remaining)
+		}
+		for _, name := range names {
+			response, err := responses[name].Result()
+			result, ok := response.(exchange.ShutdownPhaseResult)
+			if err != nil || !ok {
+				report.TimedOut = report.TimedOut || err != nil
+				result = exchange.ShutdownPhaseResult{Exchange: name, Phase: phase}
+				if err != nil {
+					result.Errors = []string{err.Error()}
+				} else {
+					result.Errors = []string{fmt.Sprintf("unexpected response %T", response)}
+				}
+			}
+			report.Results = append(report.Results, result)
+
+			event := s.logger.Info()
+			if len(result.Errors) > 0 || len(result.Warnings) > 0 {
+				event = s.logger.Warn().Strs("errors", result.Errors).Strs("warnings", result.Warnings)
+			}
+			event.Str("phase", phase).
+				Str("exchange", name).
+				Interface("detail", result.Detail).
+				Msg("Exchange completed shutdown phase")
+		}
+	}
+
+	s.reportShutdown(ctx, "stopping_actors", started, report.Results)
+	s.logger.Info().
+		Dur("elapsed", time.Since(started)).
+		Bool("timed_out", report.TimedOut).
+		Msg("Shutdown phases completed, stopping actors")
+
+	ctx.Respond(report)
+}
+
+// reportShutdown hands the current shutdown phase to the API actor for the health endpoint
+func (s *Supervisor) reportShutdown(ctx *actor.Context, phase string, started time.Time, results []exchange.ShutdownPhaseResult) {
+	if s.apiActor == nil {
+		return
 	}
+	ctx.Send(s.apiActor, api.ShutdownProgressMsg{
+		Phase:     phase,
+		StartedAt: started,
+		Results:   append([]exchange.ShutdownPhaseResult(nil), results...),
+	})
 }
 
 func (s *Supervisor) onStop(ctx *actor.Context) {
@@ -197,6 +483,21 @@ func (s *Supervisor) onStop(ctx *actor.Context) {
 	if s.uiActor != nil {
 		ctx.Engine().Stop(s.uiActor)
 	}
+
+	// Stop aggregator actor
+	if s.aggregator != nil {
+		ctx.Engine().Stop(s.aggregator)
+	}
+
+	// Stop notifier actor
+	if s.notifier != nil {
+		ctx.Engine().Stop(s.notifier)
+	}
+
+	// Stop audit journal
+	if s.journal != nil {
+		ctx.Engine().Stop(s.journal)
+	}
 }
 
 func (s *Supervisor) onStatus(ctx *actor.Context) {
@@ -205,6 +506,10 @@ func (s *Supervisor) onStatus(ctx *actor.Context) {
 		"exchange_actors": len(s.exchangeActors),
 		"api_actor_alive": s.apiActor != nil,
 		"ui_actor_alive":  s.uiActor != nil,
+		"aggregator":      s.aggregator != nil,
+		"notifier":        s.notifier != nil,
+		"audit":           s.journal != nil,
+		"shutting_down":   s.shuttingDown,
 	}
 
 	s.logger.Info().Interface("status", status).Msg("Supervisor status")
@@ -216,20 +521,34 @@ func (s *Supervisor) onError(ctx *actor.Context, msg ErrorMessage) {
 }
 
 func (s *Supervisor) onRegisterExchange(ctx *actor.Context, msg RegisterExchange) {
-	s.startExchangeActor(ctx, msg.Name, msg.Config)
+	s.startExchangeActor(ctx, s.vault, msg.Name, msg.Config)
 }
 
-func (s *Supervisor) startExchangeActor(ctx *actor.Context, exchangeName string, config map[string]interface{}) {
+// startExchangeActor starts an exchange with the credentials of its account in the unlocked vault v, or those
+// from the environment when it names none. Exchanges without credentials are not started.
+func (s *Supervisor) startExchangeActor(ctx *actor.Context, v *vault.Vault, exchangeName string, config map[string]interface{}) {
+	credentials, err := vault.ForExchange(s.config, v, exchangeName)
+	if err != nil {
+		s.logger.Error().Err(err).Str("exchange", exchangeName).Str("account", s.config.Exchanges[exchangeName].Account).Msg("Failed to load API credentials")
+		return
+	}
+	if !credentials.Valid() {
+		s.logger.Warn().Str("exchange", exchangeName).Msg("Exchange enabled but missing API credentials")
+		return
+	}
+
 	s.logger.Debug().Str("exchange", exchangeName).Msg("Starting exchange actor")
 
 	exchangeActorPID := ctx.SpawnChild(func() actor.Receiver {
-		return exchange.New(
+		exchangeActor := exchange.New(
 			exchangeName,
 			config,
 			s.config,
 			s.db,
 			s.logger.With().Str("actor", "exchange").Str("exchange", exchangeName).Logger(),
 		)
+		exchangeActor.SetCredentials(credentials)
+		return exchangeActor
 	}, "exchange_"+exchangeName)
 
 	s.exchangeActors[exchangeName] = exchangeActorPID
@@ -261,4 +580,13 @@ func (s *Supervisor) onPortfolioActorCreated(ctx *actor.Context, msg exchange.Po
 			PortfolioPID: msg.PortfolioPID,
 		})
 	}
+
+	// Include the exchange in the consolidated portfolio and account-wide limits
+	if s.aggregator != nil {
+		ctx.Send(s.aggregator, aggregator.RegisterExchangeMsg{
+			Exchange:       msg.Exchange,
+			PortfolioPID:   msg.PortfolioPID,
+			RiskManagerPID: msg.RiskManagerPID,
+		})
+	}
 }
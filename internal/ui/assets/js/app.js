@@ -55,6 +55,13 @@ const Utils = {
         return value >= 0 ? 'pnl-positive' : 'pnl-negative';
     },
 
+    // Escape text before inserting it as HTML
+    escapeHtml: (text) => {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    },
+
     // Debounce function for API calls
     debounce: (func, wait) => {
         let timeout;
@@ -83,7 +90,11 @@ const API = {
             });
 
             if (!response.ok) {
-                throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+                // Keep the error body, e.g. the validation errors of a refused script
+                const body = await response.json().catch(() => null);
+                const error = new Error(body?.error || `HTTP ${response.status}: ${response.statusText}`);
+                error.data = body;
+                throw error;
             }
 
             return await response.json();
@@ -115,7 +126,22 @@ const API = {
     // Portfolio
     portfolio: {
         get: () => API.fetch('/portfolio'),
-        summary: () => API.fetch('/portfolio/summary')
+        summary: () => API.fetch('/portfolio/summary'),
+        performance: (query = '') => API.fetch(`/portfolio/performance${query}`)
+    },
+
+    // Chart data
+    chart: (query) => API.fetch(`/chart?${query}`),
+
+    // Strategy scripts
+    scripts: {
+        list: () => API.fetch('/scripts/'),
+        get: (name) => API.fetch(`/scripts/${name}`),
+        create: (script) => API.fetch('/scripts/', { method: 'POST', body: JSON.stringify(script) }),
+        update: (name, script) => API.fetch(`/scripts/${name}`, { method: 'PUT', body: JSON.stringify(script) }),
+        version: (name, version) => API.fetch(`/scripts/${name}/versions/${version}`),
+        validate: (name, request) => API.fetch(`/scripts/${name}/validate`, { method: 'POST', body: JSON.stringify(request) }),
+        deploy: (name, request) => API.fetch(`/scripts/${name}/deploy`, { method: 'POST', body: JSON.stringify(request) })
     }
 };
 
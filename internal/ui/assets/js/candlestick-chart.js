@@ -101,6 +101,11 @@ function createCandlestickChart(ctx, klines, symbol) {
                             return new Date(context[0].parsed.x).toLocaleString();
                         },
                         label: function(context) {
+                            // Indicator lines and trade markers show their own value
+                            if (context.datasetIndex > 0) {
+                                return context.dataset.label + ': ' + context.parsed.y.toFixed(2);
+                            }
+
                             const dataIndex = context.dataIndex;
                             const candle = candlestickData[dataIndex];
                             if (candle) {
@@ -218,6 +223,68 @@ function updateCandlestickChart(chart, klines) {
     chart.update('none'); // Update without animation for better performance
 }
 
+// Colors cycled through for plotted indicator series
+const overlayColors = ['#3742fa', '#ffa502', '#a55eea', '#2f3542', '#1e90ff', '#ff6b81'];
+
+// Function to replace the indicator lines and trade markers drawn over the candles
+function updateChartOverlays(chart, plots, trades) {
+    if (!chart) {
+        console.error('Invalid chart for overlay update');
+        return;
+    }
+
+    // Dataset 0 holds the candles, everything after it is an overlay
+    const datasets = [chart.data.datasets[0]];
+
+    Object.keys(plots || {}).sort().forEach((name, index) => {
+        const color = overlayColors[index % overlayColors.length];
+        datasets.push({
+            label: name,
+            data: plots[name].map(point => ({
+                x: new Date(point.timestamp),
+                y: parseFloat(point.value)
+            })),
+            borderColor: color,
+            backgroundColor: color,
+            borderWidth: 1.5,
+            pointRadius: 0,
+            tension: 0
+        });
+    });
+
+    const markers = side => (trades || [])
+        .filter(trade => trade.side === side)
+        .map(trade => ({
+            x: new Date(trade.timestamp),
+            y: parseFloat(trade.price),
+            quantity: parseFloat(trade.quantity)
+        }));
+
+    datasets.push({
+        type: 'scatter',
+        label: 'Buys',
+        data: markers('buy'),
+        borderColor: '#00a383',
+        backgroundColor: '#00d4aa',
+        pointStyle: 'triangle',
+        pointRadius: 7
+    });
+    datasets.push({
+        type: 'scatter',
+        label: 'Sells',
+        data: markers('sell'),
+        borderColor: '#c0392b',
+        backgroundColor: '#ff4757',
+        pointStyle: 'triangle',
+        rotation: 180,
+        pointRadius: 7
+    });
+
+    chart.data.datasets = datasets;
+    chart.update('none');
+}
+
 // Export functions for global use
 window.createCandlestickChart = createCandlestickChart;
 window.updateCandlestickChart = updateCandlestickChart;
+window.updateChartOverlays = updateChartOverlays;
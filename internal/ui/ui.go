@@ -79,6 +79,18 @@ const baseTemplate = `
                         <span class="nav-label">Strategies</span>
                     </a>
                 </li>
+                <li class="nav-item">
+                    <a href="/chart" class="nav-link {{if eq .CurrentPage "/chart"}}active{{end}}">
+                        <span class="nav-icon">📈</span>
+                        <span class="nav-label">Chart</span>
+                    </a>
+                </li>
+                <li class="nav-item">
+                    <a href="/editor" class="nav-link {{if eq .CurrentPage "/editor"}}active{{end}}">
+                        <span class="nav-icon">📝</span>
+                        <span class="nav-label">Editor</span>
+                    </a>
+                </li>
                 <li class="nav-item">
                     <a href="/portfolio" class="nav-link {{if eq .CurrentPage "/portfolio"}}active{{end}}">
                         <span class="nav-icon">💰</span>
@@ -375,6 +387,20 @@ const portfolioTemplate = baseTemplate + `
             <div class="loading">Loading positions...</div>
         </div>
     </div>
+
+    <div class="card">
+        <h3>Performance (30 days)</h3>
+        <div id="performance-content">
+            <div class="loading">Loading performance...</div>
+        </div>
+    </div>
+
+    <div class="card">
+        <h3>Performance Attribution</h3>
+        <div id="attribution-content">
+            <div class="loading">Loading attribution...</div>
+        </div>
+    </div>
 </div>
 {{end}}
 
@@ -452,8 +478,100 @@ function renderPositions(positions) {
     document.getElementById('positions-content').innerHTML = tableHtml;
 }
 
+async function loadPerformanceData() {
+    try {
+        const report = await MercantileUI.API.portfolio.performance();
+        renderPerformance(report.total);
+        renderAttribution(report);
+    } catch (error) {
+        console.error('Error loading performance data:', error);
+        document.getElementById('performance-content').innerHTML = '<div class="error">Error loading performance</div>';
+        document.getElementById('attribution-content').innerHTML = '';
+    }
+}
+
+function formatRatio(value) {
+    return MercantileUI.Utils.formatNumber(value || 0);
+}
+
+function formatShare(value) {
+    return MercantileUI.Utils.formatPercentage((value || 0) * 100);
+}
+
+function renderPerformance(metrics) {
+    const items = [
+        ['Return (TWR)', formatShare(metrics.total_return), metrics.total_return],
+        ['Return (MWR)', formatShare(metrics.money_weighted_return), metrics.money_weighted_return],
+        ['Annualized Return', formatShare(metrics.annualized_return), metrics.annualized_return],
+        ['Volatility', formatShare(metrics.volatility)],
+        ['Sharpe', formatRatio(metrics.sharpe_ratio)],
+        ['Sortino', formatRatio(metrics.sortino_ratio)],
+        ['Calmar', formatRatio(metrics.calmar_ratio)],
+        ['Max Drawdown', formatShare(-metrics.max_drawdown), -metrics.max_drawdown],
+        ['Drawdown Duration', MercantileUI.Utils.formatNumber(metrics.max_drawdown_days, 1) + ' days'],
+        ['Win Rate', formatShare(metrics.win_rate)],
+        ['Profit Factor', formatRatio(metrics.profit_factor)],
+        ['Avg Win / Loss', MercantileUI.Utils.formatCurrency(metrics.average_win) + ' / ' + MercantileUI.Utils.formatCurrency(metrics.average_loss)],
+        ['Exposure', formatShare(metrics.exposure)],
+        ['Net Deposits', MercantileUI.Utils.formatCurrency(metrics.net_cash_flows)]
+    ];
+
+    document.getElementById('performance-content').innerHTML = '<div class="metrics-grid">' +
+        items.map(([label, value, sign]) => '<div class="metric-card">' +
+            '<div class="metric-value ' + (sign === undefined ? '' : MercantileUI.Utils.getPnLClass(sign)) + '">' + value + '</div>' +
+            '<div class="metric-label">' + label + '</div>' +
+        '</div>').join('') +
+    '</div>';
+}
+
+function renderAttribution(report) {
+    const groups = [['Exchange', report.exchanges], ['Symbol', report.symbols], ['Strategy', report.strategies]];
+    const rows = [];
+    groups.forEach(([kind, metricsByName]) => {
+        Object.keys(metricsByName || {}).sort().forEach(name => {
+            const metrics = metricsByName[name];
+            rows.push('<tr>' +
+                '<td>' + kind + '</td>' +
+                '<td>' + name + '</td>' +
+                '<td class="' + MercantileUI.Utils.getPnLClass(metrics.total_return) + '">' + formatShare(metrics.total_return) + '</td>' +
+                '<td>' + formatRatio(metrics.sharpe_ratio) + '</td>' +
+                '<td>' + formatShare(-metrics.max_drawdown) + '</td>' +
+                '<td>' + metrics.trades + '</td>' +
+                '<td>' + formatShare(metrics.win_rate) + '</td>' +
+                '<td>' + formatRatio(metrics.profit_factor) + '</td>' +
+                '<td class="' + MercantileUI.Utils.getPnLClass(metrics.realized_pnl) + '">' + MercantileUI.Utils.formatCurrency(metrics.realized_pnl) + '</td>' +
+                '<td>' + formatShare(metrics.exposure) + '</td>' +
+            '</tr>');
+        });
+    });
+
+    if (rows.length === 0) {
+        document.getElementById('attribution-content').innerHTML = '<div class="empty-state">No fills recorded yet</div>';
+        return;
+    }
+
+    document.getElementById('attribution-content').innerHTML = '<table class="data-table">' +
+        '<thead>' +
+            '<tr>' +
+                '<th>Group</th>' +
+                '<th>Name</th>' +
+                '<th>Return</th>' +
+                '<th>Sharpe</th>' +
+                '<th>Max DD</th>' +
+                '<th>Trades</th>' +
+                '<th>Win Rate</th>' +
+                '<th>Profit Factor</th>' +
+                '<th>Realized P&L</th>' +
+                '<th>Exposure</th>' +
+            '</tr>' +
+        '</thead>' +
+        '<tbody>' + rows.join('') + '</tbody>' +
+    '</table>';
+}
+
 // Initialize portfolio page
 MercantileUI.AutoRefresh.start('portfolio', loadPortfolioData, 30000);
+MercantileUI.AutoRefresh.start('portfolio-performance', loadPerformanceData, 60000);
 </script>
 {{end}}
 `
@@ -591,10 +709,16 @@ function displayStrategyDetails(strategy) {
     }
 
     // Update actions
-    const actions = strategy.status === 'running' 
+    const chartLink = '/chart?' + new URLSearchParams({
+        exchange: strategy.exchange || '',
+        symbol: strategy.symbol || '',
+        strategy: strategy.name || ''
+    }).toString();
+    const actions = '<a class="btn btn-primary" href="' + chartLink + '">Chart</a> ' +
+        (strategy.status === 'running' 
         ? '<button class="btn btn-warning" onclick="stopStrategy()">Stop</button> ' +
           '<button class="btn btn-secondary" onclick="restartStrategy()">Restart</button>'
-        : '<button class="btn btn-success" onclick="startStrategy()">Start</button>';
+        : '<button class="btn btn-success" onclick="startStrategy()">Start</button>');
     
     document.getElementById('strategy-actions').innerHTML = actions;
 
@@ -732,6 +856,350 @@ MercantileUI.AutoRefresh.start('strategyDetails', loadStrategyDetails, 30000);
 {{end}}
 `
 
+const chartTemplate = baseTemplate + `
+{{define "content"}}
+<div class="page-content">
+    <div class="card">
+        <form class="chart-controls" id="chart-form">
+            <label>Exchange <input type="text" name="exchange" placeholder="bybit" required></label>
+            <label>Symbol <input type="text" name="symbol" placeholder="BTCUSDT" required></label>
+            <label>Interval <input type="text" name="interval" placeholder="strategy interval"></label>
+            <label>Strategy <input type="text" name="strategy" placeholder="optional"></label>
+            <button type="submit" class="btn btn-primary">Show</button>
+        </form>
+    </div>
+
+    <div class="card">
+        <h3 id="chart-title">Chart</h3>
+        <p class="text-muted" id="chart-info">Choose an exchange and symbol, add a strategy to overlay its plots and trades.</p>
+        <div class="chart-container">
+            <canvas id="price-chart"></canvas>
+        </div>
+    </div>
+</div>
+{{end}}
+
+{{define "scripts"}}
+<script src="https://cdn.jsdelivr.net/npm/chart.js@4"></script>
+<script src="https://cdn.jsdelivr.net/npm/chartjs-adapter-date-fns@3/dist/chartjs-adapter-date-fns.bundle.min.js"></script>
+<script src="/assets/js/candlestick-chart.js"></script>
+<script>
+let priceChart = null;
+let chartKey = null;
+
+function chartQuery() {
+    const params = new URLSearchParams();
+    new FormData(document.getElementById('chart-form')).forEach((value, key) => {
+        if (value.trim() !== '') {
+            params.set(key, value.trim());
+        }
+    });
+    return params;
+}
+
+async function loadChart() {
+    const params = chartQuery();
+    if (!params.get('exchange') || !params.get('symbol')) {
+        return;
+    }
+
+    try {
+        const data = await MercantileUI.API.chart(params.toString());
+        displayChart(data);
+    } catch (error) {
+        console.error('Error loading chart:', error);
+        document.getElementById('chart-info').textContent = 'Failed to load chart: ' + error.message;
+    }
+}
+
+function displayChart(data) {
+    document.getElementById('chart-title').textContent = data.symbol + ' • ' + data.interval;
+    document.getElementById('chart-info').textContent = data.exchange +
+        (data.strategy ? ' • ' + data.strategy : '') +
+        ' • ' + data.klines.length + ' candles, ' + data.trades.length + ' trades' +
+        ' • updated ' + new Date().toLocaleTimeString();
+
+    if (data.klines.length === 0) {
+        return;
+    }
+
+    // A different symbol or interval starts a fresh chart, otherwise the existing one is updated in place
+    const key = data.exchange + ':' + data.symbol + ':' + data.interval;
+    if (priceChart === null || chartKey !== key) {
+        if (priceChart !== null) {
+            priceChart.destroy();
+        }
+        priceChart = createCandlestickChart(document.getElementById('price-chart'), data.klines, data.symbol);
+        chartKey = key;
+    } else {
+        updateCandlestickChart(priceChart, data.klines);
+    }
+    updateChartOverlays(priceChart, data.plots, data.trades);
+}
+
+// Prefill the form from the URL, e.g. /chart?exchange=bybit&symbol=BTCUSDT&strategy=sma_crossover
+const initialParams = new URLSearchParams(window.location.search);
+document.querySelectorAll('#chart-form input').forEach(input => {
+    input.value = initialParams.get(input.name) || '';
+});
+
+document.getElementById('chart-form').addEventListener('submit', event => {
+    event.preventDefault();
+    history.replaceState(null, '', '/chart?' + chartQuery().toString());
+    MercantileUI.AutoRefresh.start('chart', loadChart, 15000);
+});
+
+// Initialize chart page, new candles, plots and trades appear on every refresh
+MercantileUI.AutoRefresh.start('chart', loadChart, 15000);
+</script>
+{{end}}
+`
+
+const editorTemplate = baseTemplate + `
+{{define "content"}}
+<div class="page-content editor-layout">
+    <div class="card editor-sidebar">
+        <h3>Scripts</h3>
+        <div id="script-list">Loading...</div>
+        <button type="button" class="btn btn-secondary" onclick="newScript()">New script</button>
+
+        <h3>Versions</h3>
+        <div id="version-list" class="text-muted">Open a script to see its versions.</div>
+    </div>
+
+    <div class="card editor-main">
+        <form class="chart-controls" id="editor-form">
+            <label>Name <input type="text" name="name" placeholder="my_strategy" pattern="[A-Za-z0-9_]+" required></label>
+            <label>Note <input type="text" name="note" placeholder="what changed"></label>
+            <label>Exchange <input type="text" name="exchange" placeholder="bybit"></label>
+            <label>Symbol <input type="text" name="symbol" placeholder="BTCUSDT"></label>
+        </form>
+
+        <textarea id="script-source" class="editor-source" spellcheck="false" placeholder="def on_kline(kline):&#10;    return {&quot;action&quot;: &quot;hold&quot;}"></textarea>
+
+        <div class="editor-actions">
+            <button type="button" class="btn btn-primary" onclick="saveScript()">Save</button>
+            <button type="button" class="btn btn-secondary" onclick="validateScript()">Validate</button>
+            <button type="button" class="btn btn-success" onclick="deployScript()">Deploy</button>
+            <span class="text-muted" id="editor-status"></span>
+        </div>
+
+        <div id="validation-result"></div>
+    </div>
+</div>
+{{end}}
+
+{{define "scripts"}}
+<script>
+// Name of the script as last loaded or saved, null for a new script
+let currentScript = null;
+
+function editorField(name) {
+    return document.querySelector('#editor-form [name="' + name + '"]');
+}
+
+function setStatus(message) {
+    document.getElementById('editor-status').textContent = message;
+}
+
+async function loadScripts() {
+    try {
+        const data = await MercantileUI.API.scripts.list();
+        const list = document.getElementById('script-list');
+        if (data.scripts.length === 0) {
+            list.innerHTML = MercantileUI.Components.empty('No scripts yet');
+            return;
+        }
+        list.innerHTML = '<ul class="editor-list">' + data.scripts.map(script =>
+            '<li><a href="#" data-script="' + script.name + '">' + script.name + '</a></li>'
+        ).join('') + '</ul>';
+        list.querySelectorAll('[data-script]').forEach(link => {
+            link.addEventListener('click', event => {
+                event.preventDefault();
+                openScript(link.dataset.script);
+            });
+        });
+    } catch (error) {
+        document.getElementById('script-list').innerHTML = MercantileUI.Components.error('Failed to load scripts');
+    }
+}
+
+async function openScript(name) {
+    try {
+        const script = await MercantileUI.API.scripts.get(name);
+        currentScript = script.name;
+        editorField('name').value = script.name;
+        editorField('name').readOnly = true;
+        editorField('note').value = '';
+        document.getElementById('script-source').value = script.source;
+        document.getElementById('validation-result').innerHTML = '';
+        displayVersions(script.versions);
+        history.replaceState(null, '', '/editor?script=' + encodeURIComponent(script.name));
+        setStatus('Interval ' + script.interval);
+    } catch (error) {
+        setStatus('Failed to open ' + name + ': ' + error.message);
+    }
+}
+
+function newScript() {
+    currentScript = null;
+    editorField('name').value = '';
+    editorField('name').readOnly = false;
+    document.getElementById('script-source').value = '';
+    document.getElementById('validation-result').innerHTML = '';
+    document.getElementById('version-list').textContent = 'Save the script to create its first version.';
+    history.replaceState(null, '', '/editor');
+    setStatus('');
+}
+
+function displayVersions(versions) {
+    const list = document.getElementById('version-list');
+    if (!versions || versions.length === 0) {
+        list.textContent = 'No saved versions.';
+        return;
+    }
+    list.innerHTML = '<ul class="editor-list">' + versions.map(version =>
+        '<li><a href="#" data-version="' + version.version + '">v' + version.version + '</a> ' +
+        '<span class="text-muted">' + new Date(version.created_at).toLocaleString() +
+        (version.note ? ' • ' + MercantileUI.Utils.escapeHtml(version.note) : '') + '</span></li>'
+    ).join('') + '</ul>';
+    list.querySelectorAll('[data-version]').forEach(link => {
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            loadVersion(link.dataset.version);
+        });
+    });
+}
+
+// Loading a version only fills the editor, saving it makes it the current script again
+async function loadVersion(version) {
+    try {
+        const data = await MercantileUI.API.scripts.version(currentScript, version);
+        document.getElementById('script-source').value = data.source;
+        editorField('note').value = 'Restore v' + data.version;
+        setStatus('Loaded v' + data.version + ', save to restore it');
+    } catch (error) {
+        setStatus('Failed to load version: ' + error.message);
+    }
+}
+
+async function saveScript() {
+    const name = editorField('name').value.trim();
+    const request = {
+        source: document.getElementById('script-source').value,
+        note: editorField('note').value.trim()
+    };
+
+    try {
+        const result = currentScript === null
+            ? await MercantileUI.API.scripts.create({ name: name, ...request })
+            : await MercantileUI.API.scripts.update(currentScript, request);
+        await loadScripts();
+        await openScript(name);
+        displayValidation(result.validation);
+        setStatus(result.version ? 'Saved as v' + result.version.version : 'Saved');
+    } catch (error) {
+        displayValidation(error.data?.validation);
+        setStatus('Save failed: ' + error.message);
+    }
+}
+
+async function validateScript() {
+    const name = editorField('name').value.trim() || 'unsaved';
+    const request = {
+        source: document.getElementById('script-source').value,
+        exchange: editorField('exchange').value.trim(),
+        symbol: editorField('symbol').value.trim()
+    };
+
+    setStatus('Validating...');
+    try {
+        const result = await MercantileUI.API.scripts.validate(name, request);
+        displayValidation(result);
+        setStatus(result.valid ? 'Valid' : 'Invalid');
+    } catch (error) {
+        setStatus('Validation failed: ' + error.message);
+    }
+}
+
+async function deployScript() {
+    if (currentScript === null) {
+        setStatus('Save the script before deploying it');
+        return;
+    }
+    const request = {
+        exchange: editorField('exchange').value.trim(),
+        symbol: editorField('symbol').value.trim()
+    };
+    if (!request.exchange || !request.symbol) {
+        setStatus('Choose an exchange and symbol to deploy to');
+        return;
+    }
+
+    setStatus('Deploying...');
+    try {
+        const result = await MercantileUI.API.scripts.deploy(currentScript, request);
+        displayValidation(result.validation);
+        setStatus('Strategy ' + result.id + ' ' + result.status);
+    } catch (error) {
+        displayValidation(error.data?.validation);
+        setStatus('Deploy failed: ' + error.message);
+    }
+}
+
+function displayValidation(result) {
+    const container = document.getElementById('validation-result');
+    if (!result) {
+        container.innerHTML = '';
+        return;
+    }
+
+    let html = '<div class="validation ' + (result.valid ? 'validation-valid' : 'validation-invalid') + '">';
+    html += '<strong>' + (result.valid ? 'Valid' : 'Invalid') + '</strong>';
+    if (result.dry_run) {
+        const signals = Object.entries(result.dry_run.signals).map(([action, count]) => action + ' ' + count).join(', ');
+        html += ' <span class="text-muted">dry run over ' + result.dry_run.bars + ' bars' + (signals ? ': ' + signals : '') + '</span>';
+    }
+    html += '<ul>';
+    (result.errors || []).forEach(error => {
+        const position = error.line ? 'line ' + error.line + (error.column ? ':' + error.column : '') : error.stage;
+        const context = error.callback ? ' in ' + error.callback + (error.bar ? ' on bar ' + error.bar : '') : '';
+        html += '<li class="validation-error" data-line="' + (error.line || 0) + '"><strong>' + position + '</strong> ' +
+            MercantileUI.Utils.escapeHtml(error.message) + context + '</li>';
+    });
+    (result.warnings || []).forEach(warning => {
+        html += '<li class="text-muted">' + MercantileUI.Utils.escapeHtml(warning) + '</li>';
+    });
+    html += '</ul></div>';
+    container.innerHTML = html;
+
+    container.querySelectorAll('[data-line]').forEach(item => {
+        item.addEventListener('click', () => goToLine(parseInt(item.dataset.line, 10)));
+    });
+}
+
+// goToLine selects a line in the editor, errors are clickable
+function goToLine(line) {
+    if (!line) {
+        return;
+    }
+    const textarea = document.getElementById('script-source');
+    const lines = textarea.value.split('\n');
+    const start = lines.slice(0, line - 1).reduce((offset, text) => offset + text.length + 1, 0);
+    textarea.focus();
+    textarea.setSelectionRange(start, start + (lines[line - 1] || '').length);
+}
+
+// Initialize editor page, /editor?script=name opens a script
+loadScripts();
+const initialScript = new URLSearchParams(window.location.search).get('script');
+if (initialScript) {
+    openScript(initialScript);
+}
+</script>
+{{end}}
+`
+
 // Messages for UI actor communication
 type (
 	StartServerMsg struct{}
@@ -853,6 +1321,8 @@ func (u *UIActor) setupRouter() {
 	r.Get("/dashboard", u.handleDashboard)
 	r.Get("/strategies", u.handleStrategies)
 	r.Get("/strategies/{id}", u.handleStrategyDetails)
+	r.Get("/chart", u.handleChart)
+	r.Get("/editor", u.handleEditor)
 	r.Get("/portfolio", u.handlePortfolio)
 	r.Get("/settings", u.handleSettings)
 
@@ -911,6 +1381,24 @@ func (u *UIActor) handleStrategyDetails(w http.ResponseWriter, r *http.Request)
 	u.renderTemplate(w, strategyDetailsTemplate, data)
 }
 
+func (u *UIActor) handleChart(w http.ResponseWriter, r *http.Request) {
+	data := BasePageData{
+		Title:       "Chart",
+		CurrentPage: "/chart",
+		Subtitle:    "Price Chart",
+	}
+	u.renderTemplate(w, chartTemplate, data)
+}
+
+func (u *UIActor) handleEditor(w http.ResponseWriter, r *http.Request) {
+	data := BasePageData{
+		Title:       "Editor",
+		CurrentPage: "/editor",
+		Subtitle:    "Strategy Editor",
+	}
+	u.renderTemplate(w, editorTemplate, data)
+}
+
 func (u *UIActor) handlePortfolio(w http.ResponseWriter, r *http.Request) {
 	data := BasePageData{
 		Title:       "Portfolio",
@@ -0,0 +1,260 @@
+package valuation
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultBaseCurrency is used when no base currency is configured
+const DefaultBaseCurrency = "USDT"
+
+// stablecoins are treated as 1:1 with the US dollar when no market quotes them directly
+var stablecoins = map[string]bool{
+	"USDT":  true,
+	"USDC":  true,
+	"BUSD":  true,
+	"DAI":   true,
+	"TUSD":  true,
+	"FDUSD": true,
+	"USDP":  true,
+}
+
+// fiatCurrencies count as cash next to stablecoins
+var fiatCurrencies = map[string]bool{
+	"USD": true,
+	"EUR": true,
+	"GBP": true,
+	"CHF": true,
+	"JPY": true,
+	"TRY": true,
+	"BRL": true,
+}
+
+// quoteAssets are matched as suffixes of symbols without a separator, longest first
+var quoteAssets = []string{
+	"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "USDP",
+	"DAI", "EUR", "USD", "GBP", "CHF", "JPY", "TRY", "BRL",
+	"BTC", "ETH", "BNB",
+}
+
+// Pair is a symbol split into its base and quote asset
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+// ParseSymbol splits an exchange symbol such as "BTCUSDT", "BTC-EUR" or "ETH/BTC" into its assets
+func ParseSymbol(symbol string) (Pair, bool) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	for _, separator := range []string{"-", "/", "_"} {
+		if base, quote, found := strings.Cut(symbol, separator); found {
+			if base == "" || quote == "" {
+				return Pair{}, false
+			}
+			return Pair{Base: base, Quote: quote}, true
+		}
+	}
+
+	for _, quote := range quoteAssets {
+		if base, found := strings.CutSuffix(symbol, quote); found && base != "" {
+			return Pair{Base: base, Quote: quote}, true
+		}
+	}
+
+	return Pair{}, false
+}
+
+// IsStablecoin reports whether asset is a dollar stablecoin
+func IsStablecoin(asset string) bool {
+	return stablecoins[strings.ToUpper(asset)]
+}
+
+// IsCash reports whether asset is a fiat currency or a stablecoin
+func IsCash(asset string) bool {
+	asset = strings.ToUpper(asset)
+	return stablecoins[asset] || fiatCurrencies[asset]
+}
+
+// Holding is the amount of a single asset held on an exchange
+type Holding struct {
+	Asset     string
+	Total     float64
+	Available float64
+}
+
+// Valuation is a set of holdings expressed in the base currency
+type Valuation struct {
+	BaseCurrency  string             `json:"base_currency"`
+	TotalValue    float64            `json:"total_value"`
+	AvailableCash float64            `json:"available_cash"`
+	Values        map[string]float64 `json:"values"` // asset -> value in base currency
+	Rates         map[string]float64 `json:"rates"`  // asset -> price of one unit in base currency
+	Unpriced      []string           `json:"unpriced,omitempty"`
+}
+
+// Service converts assets into a base currency using the latest market prices.
+// It is not safe for concurrent use; each actor owns its own instance.
+type Service struct {
+	base   string
+	prices map[string]float64 // symbol -> last price
+	pairs  map[string]Pair    // symbol -> parsed pair
+}
+
+// New creates a valuation service for the given base currency
+func New(baseCurrency string) *Service {
+	baseCurrency = strings.ToUpper(strings.TrimSpace(baseCurrency))
+	if baseCurrency == "" {
+		baseCurrency = DefaultBaseCurrency
+	}
+
+	return &Service{
+		base:   baseCurrency,
+		prices: make(map[string]float64),
+		pairs:  make(map[string]Pair),
+	}
+}
+
+// BaseCurrency returns the currency values are expressed in
+func (s *Service) BaseCurrency() string {
+	return s.base
+}
+
+// UpdatePrice records the last price of a symbol. Unknown symbols and non-positive prices are ignored.
+func (s *Service) UpdatePrice(symbol string, price float64) bool {
+	if price <= 0 {
+		return false
+	}
+
+	pair, ok := s.pairs[symbol]
+	if !ok {
+		if pair, ok = ParseSymbol(symbol); !ok {
+			return false
+		}
+		s.pairs[symbol] = pair
+	}
+
+	s.prices[symbol] = price
+	return true
+}
+
+// Price returns the last recorded price of a symbol
+func (s *Service) Price(symbol string) (float64, bool) {
+	price, ok := s.prices[symbol]
+	return price, ok
+}
+
+// Rate returns the price of one unit of asset in the base currency.
+// Direct and inverse markets are used first, then cross rates through other markets,
+// and only then the stablecoin peg, so a quoted USDT-EUR market wins over the 1:1 assumption.
+func (s *Service) Rate(asset string) (float64, bool) {
+	asset = strings.ToUpper(asset)
+	if asset == s.base {
+		return 1, true
+	}
+
+	graph := s.marketGraph()
+	if rate, ok := findRate(graph, asset, s.base); ok {
+		return rate, true
+	}
+
+	addPegs(graph)
+	return findRate(graph, asset, s.base)
+}
+
+// Convert returns amount of asset expressed in the base currency
+func (s *Service) Convert(asset string, amount float64) (float64, bool) {
+	rate, ok := s.Rate(asset)
+	if !ok {
+		return 0, false
+	}
+	return amount * rate, true
+}
+
+// Value converts holdings into the base currency. Cash is the available amount of fiat and stablecoin holdings.
+// Holdings without a route to the base currency are listed as unpriced and left out of the totals.
+func (s *Service) Value(holdings []Holding) Valuation {
+	valuation := Valuation{
+		BaseCurrency: s.base,
+		Values:       make(map[string]float64),
+		Rates:        make(map[string]float64),
+	}
+
+	for _, holding := range holdings {
+		asset := strings.ToUpper(holding.Asset)
+		if holding.Total == 0 && holding.Available == 0 {
+			continue
+		}
+
+		rate, ok := s.Rate(asset)
+		if !ok {
+			valuation.Unpriced = append(valuation.Unpriced, asset)
+			continue
+		}
+
+		value := holding.Total * rate
+		valuation.Rates[asset] = rate
+		valuation.Values[asset] += value
+		valuation.TotalValue += value
+
+		if IsCash(asset) || asset == s.base {
+			valuation.AvailableCash += holding.Available * rate
+		}
+	}
+
+	sort.Strings(valuation.Unpriced)
+	return valuation
+}
+
+// edge converts one unit of an asset into rate units of another asset
+type edge struct {
+	to   string
+	rate float64
+}
+
+// marketGraph links the assets of every priced symbol in both directions
+func (s *Service) marketGraph() map[string][]edge {
+	graph := make(map[string][]edge)
+	for symbol, price := range s.prices {
+		pair := s.pairs[symbol]
+		graph[pair.Base] = append(graph[pair.Base], edge{to: pair.Quote, rate: price})
+		graph[pair.Quote] = append(graph[pair.Quote], edge{to: pair.Base, rate: 1 / price})
+	}
+	return graph
+}
+
+// addPegs links every stablecoin to USD at 1:1
+func addPegs(graph map[string][]edge) {
+	for coin := range stablecoins {
+		graph[coin] = append(graph[coin], edge{to: "USD", rate: 1})
+		graph["USD"] = append(graph["USD"], edge{to: coin, rate: 1})
+	}
+}
+
+// findRate walks the graph breadth first so the route with the fewest conversions is used.
+// Edges are visited in a fixed order to keep results stable between calls.
+func findRate(graph map[string][]edge, from, to string) (float64, bool) {
+	rates := map[string]float64{from: 1}
+	queue := []string{from}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		edges := graph[current]
+		sort.Slice(edges, func(i, j int) bool { return edges[i].to < edges[j].to })
+
+		for _, e := range edges {
+			if _, seen := rates[e.to]; seen {
+				continue
+			}
+			rates[e.to] = rates[current] * e.rate
+			if e.to == to {
+				return rates[e.to], true
+			}
+			queue = append(queue, e.to)
+		}
+	}
+
+	return 0, false
+}
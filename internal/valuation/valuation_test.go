@@ -0,0 +1,128 @@
+package valuation
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseSymbol(t *testing.T) {
+	tests := []struct {
+		symbol string
+		pair   Pair
+		ok     bool
+	}{
+		{"BTCUSDT", Pair{"BTC", "USDT"}, true},
+		{"BTC-EUR", Pair{"BTC", "EUR"}, true},
+		{"eth/btc", Pair{"ETH", "BTC"}, true},
+		{"USDCUSDT", Pair{"USDC", "USDT"}, true},
+		{"ETHBTC", Pair{"ETH", "BTC"}, true},
+		{"USDT", Pair{}, false},
+		{"BTC-", Pair{}, false},
+		{"FOOBAR", Pair{}, false},
+	}
+
+	for _, tt := range tests {
+		pair, ok := ParseSymbol(tt.symbol)
+		if ok != tt.ok || pair != tt.pair {
+			t.Errorf("ParseSymbol(%q) = %+v, %t; want %+v, %t", tt.symbol, pair, ok, tt.pair, tt.ok)
+		}
+	}
+}
+
+func TestRateDirectAndInverse(t *testing.T) {
+	service := New("EUR")
+	service.UpdatePrice("BTC-EUR", 50000)
+
+	if rate, ok := service.Rate("BTC"); !ok || rate != 50000 {
+		t.Errorf("expected direct rate 50000, got %f (%t)", rate, ok)
+	}
+
+	service = New("BTC")
+	service.UpdatePrice("BTC-EUR", 50000)
+	if rate, ok := service.Rate("EUR"); !ok || !almostEqual(rate, 1.0/50000) {
+		t.Errorf("expected inverse rate, got %f (%t)", rate, ok)
+	}
+}
+
+func TestRateCrossFromTickers(t *testing.T) {
+	// EUR is valued in USDT through BTC, which is quoted in both
+	service := New("USDT")
+	service.UpdatePrice("BTC-EUR", 50000)
+	service.UpdatePrice("BTCUSDT", 55000)
+
+	rate, ok := service.Rate("EUR")
+	if !ok || !almostEqual(rate, 1.1) {
+		t.Errorf("expected EUR rate 1.1 USDT, got %f (%t)", rate, ok)
+	}
+
+	value, ok := service.Convert("EUR", 100)
+	if !ok || !almostEqual(value, 110) {
+		t.Errorf("expected 100 EUR to be 110 USDT, got %f (%t)", value, ok)
+	}
+}
+
+func TestRateStablecoinPeg(t *testing.T) {
+	service := New("USD")
+	service.UpdatePrice("ETHUSDC", 3000)
+
+	if rate, ok := service.Rate("USDT"); !ok || rate != 1 {
+		t.Errorf("expected USDT pegged to USD, got %f (%t)", rate, ok)
+	}
+	if rate, ok := service.Rate("ETH"); !ok || rate != 3000 {
+		t.Errorf("expected ETH valued through the USDC peg, got %f (%t)", rate, ok)
+	}
+
+	// A quoted market takes precedence over the peg
+	service = New("USDT")
+	service.UpdatePrice("USDCUSDT", 0.999)
+	if rate, ok := service.Rate("USDC"); !ok || rate != 0.999 {
+		t.Errorf("expected market rate over peg, got %f (%t)", rate, ok)
+	}
+}
+
+func TestRateUnknownAsset(t *testing.T) {
+	service := New("")
+	if service.BaseCurrency() != DefaultBaseCurrency {
+		t.Errorf("expected default base currency, got %s", service.BaseCurrency())
+	}
+
+	if _, ok := service.Rate("DOGE"); ok {
+		t.Error("expected no rate without a market")
+	}
+	if service.UpdatePrice("BTCUSDT", 0) {
+		t.Error("expected non-positive prices to be ignored")
+	}
+}
+
+func TestValue(t *testing.T) {
+	service := New("EUR")
+	service.UpdatePrice("BTC-EUR", 50000)
+	service.UpdatePrice("USDT-EUR", 0.9)
+
+	valuation := service.Value([]Holding{
+		{Asset: "EUR", Total: 1000, Available: 800},
+		{Asset: "USDT", Total: 500, Available: 500},
+		{Asset: "BTC", Total: 0.1, Available: 0.1},
+		{Asset: "DOGE", Total: 100, Available: 100},
+	})
+
+	if valuation.BaseCurrency != "EUR" {
+		t.Errorf("expected base currency EUR, got %s", valuation.BaseCurrency)
+	}
+	if !almostEqual(valuation.TotalValue, 1000+450+5000) {
+		t.Errorf("expected total value 6450, got %f", valuation.TotalValue)
+	}
+	if !almostEqual(valuation.AvailableCash, 800+450) {
+		t.Errorf("expected available cash 1250, got %f", valuation.AvailableCash)
+	}
+	if len(valuation.Unpriced) != 1 || valuation.Unpriced[0] != "DOGE" {
+		t.Errorf("expected DOGE to be unpriced, got %v", valuation.Unpriced)
+	}
+	if valuation.Rates["USDT"] != 0.9 {
+		t.Errorf("expected USDT rate 0.9, got %f", valuation.Rates["USDT"])
+	}
+}
@@ -12,6 +12,14 @@ import (
 )
 
 func main() {
+	// Subcommands such as "report" run once and exit without starting the bot
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: Could not load .env file: %v", err)
@@ -35,5 +43,18 @@ func main() {
 	<-sigChan
 
 	log.Println("Shutting down trading bot...")
+
+	// A second signal skips the remaining shutdown
+	go func() {
+		<-sigChan
+		log.Println("Received second signal, exiting immediately")
+		os.Exit(1)
+	}()
+
+	err := supervisorActor.Shutdown()
 	cancel()
+	if err != nil {
+		log.Printf("Shutdown incomplete: %v", err)
+		os.Exit(1)
+	}
 }
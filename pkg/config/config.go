@@ -1,7 +1,11 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -10,8 +14,27 @@ import (
 
 // StrategyConfig holds strategy-specific configuration
 type StrategyConfig struct {
-	Name   string                 `yaml:"name"`
-	Config map[string]interface{} `yaml:"config"`
+	Name    string                 `yaml:"name"`
+	Symbols []string               `yaml:"symbols"` // Additional legs for multi-symbol strategies
+	Config  map[string]interface{} `yaml:"config"`
+}
+
+// Legs returns every symbol the strategy trades, starting with the pair's own symbol
+func (s StrategyConfig) Legs(pairSymbol string) []string {
+	legs := []string{pairSymbol}
+	for _, symbol := range s.Symbols {
+		duplicate := false
+		for _, leg := range legs {
+			if leg == symbol {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate && symbol != "" {
+			legs = append(legs, symbol)
+		}
+	}
+	return legs
 }
 
 // PairConfig holds configuration for a trading pair
@@ -20,17 +43,29 @@ type PairConfig struct {
 	Strategies []StrategyConfig `yaml:"strategies"`
 }
 
-// ExchangeConfig holds exchange-specific configuration
+// ExchangeConfig holds the configuration of one exchange account, keyed by a name such as "bybit" or
+// "bybit-hedge". Each account runs its own exchange, order, risk, portfolio and settings actors.
 type ExchangeConfig struct {
 	Enabled bool         `yaml:"enabled"`
+	Type    string       `yaml:"type"`    // Exchange implementation, the name of the entry when empty
+	Account string       `yaml:"account"` // Vault account, the environment credentials when empty
 	Pairs   []PairConfig `yaml:"pairs"`
 }
 
 // StrategiesConfig holds global strategy settings
 type StrategiesConfig struct {
-	Directory       string `yaml:"directory"`
-	DefaultInterval string `yaml:"default_interval"`
-	MaxConcurrent   int    `yaml:"max_concurrent"`
+	Directory       string        `yaml:"directory"`
+	DefaultInterval string        `yaml:"default_interval"`
+	MaxConcurrent   int           `yaml:"max_concurrent"`
+	Sandbox         SandboxConfig `yaml:"sandbox"`
+}
+
+// SandboxConfig limits what a single Starlark callback may consume. Zero disables a limit.
+type SandboxConfig struct {
+	MaxSteps        uint64        `yaml:"max_steps"`         // Execution steps per callback
+	Timeout         time.Duration `yaml:"timeout"`           // Wall-clock time per callback
+	MaxStateEntries int           `yaml:"max_state_entries"` // Values held in set_state(), nested elements included
+	MaxViolations   int           `yaml:"max_violations"`    // Violations before the script is disabled
 }
 
 // RiskConfig holds risk management settings
@@ -41,8 +76,55 @@ type RiskConfig struct {
 	MaxDailyRisk     float64 `yaml:"max_daily_risk"`
 	MaxDrawdown      float64 `yaml:"max_drawdown"`
 	MaxOpenPositions int     `yaml:"max_open_positions"`
+
+	Account AccountRiskConfig `yaml:"account"`
+}
+
+// AccountRiskConfig holds limits enforced across all exchanges together. Zero disables a limit.
+type AccountRiskConfig struct {
+	MaxAssetExposure map[string]float64 `yaml:"max_asset_exposure"` // Asset -> max units held across all exchanges
+	MaxConcentration float64            `yaml:"max_concentration"`  // Max share of the consolidated value in one non-cash asset
+}
+
+// PortfolioConfig holds portfolio valuation settings
+type PortfolioConfig struct {
+	BaseCurrency string `yaml:"base_currency"` // Currency all balances and positions are valued in (USD, EUR, USDT, ...)
 }
 
+// NotificationsConfig holds the channels trading events are delivered to
+type NotificationsConfig struct {
+	DailySummary string                      `yaml:"daily_summary"` // UTC time of day (HH:MM) to send the daily PnL summary, empty disables it
+	Channels     []NotificationChannelConfig `yaml:"channels"`
+}
+
+// NotificationChannelConfig configures one delivery channel
+type NotificationChannelConfig struct {
+	Name       string            `yaml:"name"`
+	Type       string            `yaml:"type"`        // webhook, slack, discord or smtp
+	URL        string            `yaml:"url"`         // Webhook URL for webhook, slack and discord
+	Template   string            `yaml:"template"`    // Go template for the webhook body, the event as JSON when empty
+	Headers    map[string]string `yaml:"headers"`     // Extra HTTP headers for webhooks
+	Events     []string          `yaml:"events"`      // Event types to deliver, all when empty
+	RateLimit  int               `yaml:"rate_limit"`  // Max notifications per minute, zero disables the limit
+	MaxRetries int               `yaml:"max_retries"` // Retries after a failed delivery
+	RetryDelay time.Duration     `yaml:"retry_delay"` // Delay before the first retry, doubled on every further retry
+	Timeout    time.Duration     `yaml:"timeout"`     // Per delivery attempt
+	SMTP       SMTPConfig        `yaml:"smtp"`
+}
+
+// SMTPConfig holds the mail server and addresses of an smtp channel
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"` // Authentication is skipped when empty
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// File is the YAML configuration read on startup and watched for changes while running
+const File = "config.yaml"
+
 // Config holds the application configuration
 type Config struct {
 	Database   DatabaseConfig            `yaml:"database"`
@@ -52,14 +134,23 @@ type Config struct {
 	Exchanges  map[string]ExchangeConfig `yaml:"exchanges"`
 	Strategies StrategiesConfig          `yaml:"strategies"`
 	Risk       RiskConfig                `yaml:"risk"`
+	Portfolio  PortfolioConfig           `yaml:"portfolio"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Vault         VaultConfig         `yaml:"vault"`
+	Audit         AuditConfig         `yaml:"audit"`
+	Shutdown      ShutdownConfig      `yaml:"shutdown"`
+
+	// Environment variables (from .env), never read from YAML
+	BybitAPIKey    string `yaml:"-"`
+	BybitSecret    string `yaml:"-"`
+	BybitTestnet   bool   `yaml:"-"`
+	BitvavoAPIKey  string `yaml:"-"`
+	BitvavoSecret  string `yaml:"-"`
+	BitvavoTestnet bool   `yaml:"-"`
 
-	// Environment variables (from .env)
-	BybitAPIKey    string
-	BybitSecret    string
-	BybitTestnet   bool
-	BitvavoAPIKey  string
-	BitvavoSecret  string
-	BitvavoTestnet bool
+	// Parsed YAML document, used to report the line of a setting
+	node *yaml.Node
 }
 
 type DatabaseConfig struct {
@@ -79,10 +170,56 @@ type LoggingConfig struct {
 	Level string `yaml:"level"`
 }
 
+// ExchangeType returns the exchange implementation, such as "bybit", of the exchange account name
+func (c *Config) ExchangeType(name string) string {
+	if exchangeType := c.Exchanges[name].Type; exchangeType != "" {
+		return exchangeType
+	}
+	return name
+}
+
+// VaultConfig locates the encrypted credential vault. Its passphrase is read from KeyFile, or from
+// VAULT_PASSPHRASE when no key file is set.
+type VaultConfig struct {
+	Path    string `yaml:"path"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// AuditConfig holds where the audit journal is written besides the audit_log table
+type AuditConfig struct {
+	File string `yaml:"file"` // JSON Lines file every entry is appended to, disabled when empty
+}
+
+// Open-order policies applied on shutdown
+const (
+	OrderPolicyLeave               = "leave"                 // Keep every order open on the exchange
+	OrderPolicyCancelAll           = "cancel_all"            // Cancel every open order, stops included
+	OrderPolicyCancelNonProtective = "cancel_non_protective" // Cancel entry orders, keep stop and trailing stop orders
+)
+
+// ShutdownConfig holds how the bot stops on SIGINT or SIGTERM
+type ShutdownConfig struct {
+	OrderPolicy string        `yaml:"order_policy"` // leave, cancel_all or cancel_non_protective, leave when empty
+	Timeout     time.Duration `yaml:"timeout"`      // Time allowed for the whole shutdown, DefaultShutdownTimeout when zero
+}
+
+// DefaultShutdownTimeout bounds a shutdown when no timeout is configured
+const DefaultShutdownTimeout = 30 * time.Second
+
 // Load loads configuration from environment and YAML file
 func Load() (*Config, error) {
+	return LoadFile(File)
+}
+
+// loadEnv reads the .env file into the environment once per process. Reloads only read the YAML
+// file, so values cleared after startup such as VAULT_PASSPHRASE are not set again.
+var loadEnv sync.Once
+
+// LoadFile loads configuration from environment and the YAML file at path. Keys that do not
+// match a setting are rejected with their line number.
+func LoadFile(path string) (*Config, error) {
 	// Load .env file if it exists
-	_ = godotenv.Load()
+	loadEnv.Do(func() { _ = godotenv.Load() })
 
 	config := &Config{
 		Database: DatabaseConfig{
@@ -102,12 +239,32 @@ func Load() (*Config, error) {
 			Directory:       "./strategies",
 			DefaultInterval: "1m",
 			MaxConcurrent:   10,
+			Sandbox: SandboxConfig{
+				MaxSteps:        10_000_000,
+				Timeout:         5 * time.Second,
+				MaxStateEntries: 100_000,
+				MaxViolations:   3,
+			},
 		},
 		Risk: RiskConfig{
 			MaxPositionSize:  0.1,
 			MaxDailyLoss:     1000.0,
 			MaxOpenPositions: 5,
 		},
+		Portfolio: PortfolioConfig{
+			BaseCurrency: "USDT",
+		},
+		Vault: VaultConfig{
+			Path:    getEnvOrDefault("VAULT_PATH", "./mercantile.vault"),
+			KeyFile: os.Getenv("VAULT_KEY_FILE"),
+		},
+		Audit: AuditConfig{
+			File: os.Getenv("AUDIT_FILE"),
+		},
+		Shutdown: ShutdownConfig{
+			OrderPolicy: getEnvOrDefault("SHUTDOWN_ORDER_POLICY", OrderPolicyLeave),
+			Timeout:     time.Duration(getEnvIntOrDefault("SHUTDOWN_TIMEOUT_SECONDS", int(DefaultShutdownTimeout/time.Second))) * time.Second,
+		},
 		Exchanges:      make(map[string]ExchangeConfig),
 		BybitAPIKey:    os.Getenv("BYBIT_API_KEY"),
 		BybitSecret:    os.Getenv("BYBIT_SECRET"),
@@ -118,8 +275,8 @@ func Load() (*Config, error) {
 	}
 
 	// Load YAML config if it exists
-	if data, err := os.ReadFile("config.yaml"); err == nil {
-		if err := yaml.Unmarshal(data, config); err != nil {
+	if data, err := os.ReadFile(path); err == nil {
+		if err := config.decode(data); err != nil {
 			return nil, err
 		}
 	}
@@ -127,6 +284,23 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
+// decode reads YAML into the configuration, keeping the document for line numbers
+func (c *Config) decode(data []byte) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	c.node = &node
+	return nil
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -69,6 +72,9 @@ func TestLoad(t *testing.T) {
 		if config.Strategies.MaxConcurrent != 10 {
 			t.Errorf("expected max concurrent 10, got %d", config.Strategies.MaxConcurrent)
 		}
+		if config.Strategies.Sandbox.MaxSteps == 0 || config.Strategies.Sandbox.Timeout == 0 || config.Strategies.Sandbox.MaxViolations == 0 {
+			t.Errorf("expected sandbox limits enabled by default, got %+v", config.Strategies.Sandbox)
+		}
 		if config.Risk.MaxPositionSize != 0.1 {
 			t.Errorf("expected max position size 0.1, got %f", config.Risk.MaxPositionSize)
 		}
@@ -78,6 +84,9 @@ func TestLoad(t *testing.T) {
 		if config.Risk.MaxOpenPositions != 5 {
 			t.Errorf("expected max open positions 5, got %d", config.Risk.MaxOpenPositions)
 		}
+		if config.Portfolio.BaseCurrency != "USDT" {
+			t.Errorf("expected base currency USDT, got %s", config.Portfolio.BaseCurrency)
+		}
 		if config.BybitTestnet != false {
 			t.Errorf("expected Bybit testnet false, got %t", config.BybitTestnet)
 		}
@@ -335,4 +344,203 @@ func TestParseError(t *testing.T) {
 	if err.Error() != expected {
 		t.Errorf("expected error message '%s', got '%s'", expected, err.Error())
 	}
-}
\ No newline at end of file
+}
+func TestStrategyConfigLegs(t *testing.T) {
+	single := StrategyConfig{Name: "simple_sma"}
+	if legs := single.Legs("BTCUSDT"); len(legs) != 1 || legs[0] != "BTCUSDT" {
+		t.Errorf("expected only the pair symbol, got %v", legs)
+	}
+
+	pairs := StrategyConfig{Name: "pairs", Symbols: []string{"BTCUSDT", "ETHUSDT", ""}}
+	legs := pairs.Legs("BTCUSDT")
+	if len(legs) != 2 || legs[0] != "BTCUSDT" || legs[1] != "ETHUSDT" {
+		t.Errorf("expected [BTCUSDT ETHUSDT], got %v", legs)
+	}
+}
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	return &Config{
+		API:     APIConfig{Port: 8080},
+		UI:      UIConfig{Port: 8081},
+		Logging: LoggingConfig{Level: "info"},
+		Exchanges: map[string]ExchangeConfig{
+			"bybit": {Enabled: true, Pairs: []PairConfig{
+				{Symbol: "BTCUSDT", Strategies: []StrategyConfig{{Name: "simple_sma"}, {Name: "rsi_strategy"}}},
+				{Symbol: "ETHUSDT", Strategies: []StrategyConfig{{Name: "simple_sma"}}},
+			}},
+		},
+		Risk: RiskConfig{MaxPositionSize: 0.1, Account: AccountRiskConfig{MaxAssetExposure: map[string]float64{"BTC": 1}}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	invalid := map[string]func(c *Config){
+		"missing symbol": func(c *Config) {
+			c.Exchanges["bybit"].Pairs[0].Symbol = ""
+		},
+		"duplicate pair": func(c *Config) {
+			c.Exchanges["bybit"].Pairs[1].Symbol = "BTCUSDT"
+		},
+		"missing strategy name": func(c *Config) {
+			c.Exchanges["bybit"].Pairs[0].Strategies[1].Name = ""
+		},
+		"duplicate strategy": func(c *Config) {
+			c.Exchanges["bybit"].Pairs[0].Strategies[1].Name = "simple_sma"
+		},
+		"negative risk": func(c *Config) {
+			c.Risk.MaxDailyLoss = -1
+		},
+		"negative exposure": func(c *Config) {
+			c.Risk.Account.MaxAssetExposure["BTC"] = -1
+		},
+		"position size above the portfolio": func(c *Config) {
+			c.Risk.MaxPositionSize = 10
+		},
+		"unknown log level": func(c *Config) {
+			c.Logging.Level = "verbose"
+		},
+		"port out of range": func(c *Config) {
+			c.API.Port = 70000
+		},
+		"unknown order policy": func(c *Config) {
+			c.Shutdown.OrderPolicy = "cancel_some"
+		},
+		"negative shutdown timeout": func(c *Config) {
+			c.Shutdown.Timeout = -time.Second
+		},
+		"shared environment credentials": func(c *Config) {
+			c.Exchanges["bybit-hedge"] = ExchangeConfig{Enabled: true, Type: "bybit"}
+		},
+		"shared account": func(c *Config) {
+			c.Exchanges["bybit-main"] = ExchangeConfig{Enabled: true, Type: "bybit", Account: "main"}
+			c.Exchanges["bybit-hedge"] = ExchangeConfig{Enabled: true, Type: "bybit", Account: "main"}
+		},
+	}
+	for name, mutate := range invalid {
+		c := validConfig()
+		mutate(c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExchangeAccounts(t *testing.T) {
+	c := validConfig()
+	c.Exchanges["bybit-hedge"] = ExchangeConfig{Enabled: true, Type: "bybit", Account: "hedge"}
+	c.Exchanges["bybit-old"] = ExchangeConfig{Enabled: false, Type: "bybit"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected accounts with their own credentials to be valid, got %v", err)
+	}
+
+	if c.ExchangeType("bybit-hedge") != "bybit" || c.ExchangeType("bybit") != "bybit" {
+		t.Errorf("expected bybit accounts, got %s and %s", c.ExchangeType("bybit-hedge"), c.ExchangeType("bybit"))
+	}
+}
+
+func TestLoadFileReportsLines(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	data := `exchanges:
+  bybit:
+    enabled: true
+    pairs:
+      - symbol: "BTCUSDT"
+        strategies:
+          - name: "simple_sma"
+      - symbol: "BTCUSDT"
+risk:
+  max_drawdown: 1.5
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	err = c.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 validation errors, got %v", err)
+	}
+	if errs[0].Line != 8 || errs[0].Path != "exchanges.bybit.pairs[1].symbol" {
+		t.Errorf("expected the duplicate pair on line 8, got %+v", errs[0])
+	}
+	if errs[1].Line != 10 || errs[1].Path != "risk.max_drawdown" {
+		t.Errorf("expected the drawdown on line 10, got %+v", errs[1])
+	}
+
+	// A missing setting reports its parent
+	if line := c.Line("exchanges.bybit.pairs[0].strategies[0].config.period"); line != 7 {
+		t.Errorf("expected line 7, got %d", line)
+	}
+
+	// Misspelled keys are rejected
+	if err := os.WriteFile(path, []byte("risk:\n  max_drawdwn: 0.2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an unknown field error on line 2, got %v", err)
+	}
+}
+
+func TestLoadFileReadsEnvOnce(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("VAULT_PASSPHRASE=secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAULT_PASSPHRASE", "")
+	os.Unsetenv("VAULT_PASSPHRASE")
+	loadEnv = sync.Once{}
+
+	path := filepath.Join(dir, "config.yaml")
+	if _, err := LoadFile(path); err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if os.Getenv("VAULT_PASSPHRASE") != "secret" {
+		t.Fatal("expected the first load to read .env")
+	}
+
+	// The vault clears the passphrase once unlocked, a reload must not restore it
+	os.Unsetenv("VAULT_PASSPHRASE")
+	if _, err := LoadFile(path); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if passphrase, set := os.LookupEnv("VAULT_PASSPHRASE"); set {
+		t.Errorf("expected the passphrase to stay cleared after a reload, got %q", passphrase)
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := &Config{API: APIConfig{Port: 8080}, Logging: LoggingConfig{Level: "info"}}
+	new := &Config{API: APIConfig{Port: 9090}, Logging: LoggingConfig{Level: "debug"}, Risk: RiskConfig{MaxDrawdown: 0.2}}
+
+	changed := RestartRequired(old, new)
+	if len(changed) != 1 || changed[0] != "api" {
+		t.Errorf("expected only the api section to require a restart, got %v", changed)
+	}
+}
+
+func TestRestartRequiredAccount(t *testing.T) {
+	old := &Config{Exchanges: map[string]ExchangeConfig{
+		"bybit":   {Enabled: true, Account: "main"},
+		"bitvavo": {Enabled: false},
+	}}
+	new := &Config{Exchanges: map[string]ExchangeConfig{
+		"bybit":   {Enabled: true, Account: "hedge"},
+		"bitvavo": {Enabled: true, Account: "main"},
+	}}
+
+	// An exchange that was not running picks up its account when it starts
+	changed := RestartRequired(old, new)
+	if len(changed) != 1 || changed[0] != "exchanges.bybit.account" {
+		t.Errorf("expected the bybit account to require a restart, got %v", changed)
+	}
+}
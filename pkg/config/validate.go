@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem with one setting. Line is its line in the configuration file, 0 when unknown.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors lists every problem found in a configuration
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err returns the errors as an error, nil when there are none
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	sort.SliceStable(e, func(i, j int) bool { return e[i].Line < e[j].Line })
+	return e
+}
+
+// Invalid describes a problem with the setting at path, such as "exchanges.bybit.pairs[0].symbol"
+func (c *Config) Invalid(path, format string, args ...interface{}) ValidationError {
+	return ValidationError{Path: path, Line: c.Line(path), Message: fmt.Sprintf(format, args...)}
+}
+
+// Line returns the line of the setting at path in the loaded file. A setting that is not in the file
+// reports the line of its closest parent, and 0 is returned when no file was loaded.
+func (c *Config) Line(path string) int {
+	if c.node == nil || len(c.node.Content) == 0 {
+		return 0
+	}
+
+	node := c.node.Content[0]
+	line := 0
+	for _, part := range strings.Split(path, ".") {
+		key, index := part, -1
+		if open := strings.Index(part, "["); open >= 0 && strings.HasSuffix(part, "]") {
+			key = part[:open]
+			if i, err := strconv.Atoi(part[open+1 : len(part)-1]); err == nil {
+				index = i
+			}
+		}
+
+		if key != "" {
+			next, keyLine := mappingValue(node, key)
+			if next == nil {
+				return line
+			}
+			node, line = next, keyLine
+		}
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return line
+			}
+			node, line = node.Content[index], node.Content[index].Line
+		}
+	}
+	return line
+}
+
+// mappingValue returns the value of key in a mapping node and the line of the key
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, int) {
+	if node.Kind != yaml.MappingNode {
+		return nil, 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1], node.Content[i].Line
+		}
+	}
+	return nil, 0
+}
+
+// Validate reports every setting that keeps the configuration from being applied as ValidationErrors.
+// Strategy scripts, their parameters and the exchanges themselves are checked by internal/configcheck.
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+
+	names := make([]string, 0, len(c.Exchanges))
+	for name := range c.Exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Accounts of one exchange isolate risk only when they trade with different credentials
+	credentials := make(map[string]string) // type/account -> name
+	for _, name := range names {
+		exchange := c.Exchanges[name]
+		if !exchange.Enabled {
+			continue
+		}
+		key := c.ExchangeType(name) + "/" + exchange.Account
+		if other, exists := credentials[key]; exists {
+			if exchange.Account == "" {
+				errs = append(errs, c.Invalid("exchanges."+name+".account", "uses the environment credentials of %s like %s, name a vault account", c.ExchangeType(name), other))
+			} else {
+				errs = append(errs, c.Invalid("exchanges."+name+".account", "account %s is already used by %s", exchange.Account, other))
+			}
+			continue
+		}
+		credentials[key] = name
+	}
+
+	for _, name := range names {
+		exchange := c.Exchanges[name]
+		pairs := make(map[string]bool)
+		for i, pair := range exchange.Pairs {
+			path := fmt.Sprintf("exchanges.%s.pairs[%d]", name, i)
+			if pair.Symbol == "" {
+				errs = append(errs, c.Invalid(path+".symbol", "symbol is required"))
+			} else if pairs[pair.Symbol] {
+				errs = append(errs, c.Invalid(path+".symbol", "duplicate pair %s", pair.Symbol))
+			}
+			pairs[pair.Symbol] = true
+
+			strategies := make(map[string]bool)
+			for j, strategy := range pair.Strategies {
+				strategyPath := fmt.Sprintf("%s.strategies[%d].name", path, j)
+				if strategy.Name == "" {
+					errs = append(errs, c.Invalid(strategyPath, "name is required"))
+					continue
+				}
+				// Strategies are keyed by name and symbol, so a pair can run each script once
+				if strategies[strategy.Name] {
+					errs = append(errs, c.Invalid(strategyPath, "duplicate strategy %s for %s", strategy.Name, pair.Symbol))
+				}
+				strategies[strategy.Name] = true
+			}
+		}
+	}
+
+	// Limits that are a share of the portfolio value cannot exceed it
+	type limit struct {
+		setting  string
+		value    float64
+		fraction bool
+	}
+	limits := []limit{
+		{"max_position_size", c.Risk.MaxPositionSize, true},
+		{"max_daily_loss", c.Risk.MaxDailyLoss, false},
+		{"max_daily_volume", c.Risk.MaxDailyVolume, false},
+		{"max_daily_risk", c.Risk.MaxDailyRisk, true},
+		{"max_drawdown", c.Risk.MaxDrawdown, true},
+		{"max_open_positions", float64(c.Risk.MaxOpenPositions), false},
+		{"account.max_concentration", c.Risk.Account.MaxConcentration, true},
+	}
+	assets := make([]string, 0, len(c.Risk.Account.MaxAssetExposure))
+	for asset := range c.Risk.Account.MaxAssetExposure {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	for _, asset := range assets {
+		limits = append(limits, limit{"account.max_asset_exposure." + asset, c.Risk.Account.MaxAssetExposure[asset], false})
+	}
+	for _, l := range limits {
+		switch {
+		case l.value < 0:
+			errs = append(errs, c.Invalid("risk."+l.setting, "must not be negative"))
+		case l.fraction && l.value > 1:
+			errs = append(errs, c.Invalid("risk."+l.setting, "%g is a share of the portfolio value and must be at most 1", l.value))
+		}
+	}
+
+	if _, err := zerolog.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, c.Invalid("logging.level", "unknown level %q", c.Logging.Level))
+	}
+	if c.API.Port < 1 || c.API.Port > 65535 {
+		errs = append(errs, c.Invalid("api.port", "port %d is out of range", c.API.Port))
+	}
+	if c.UI.Port < 1 || c.UI.Port > 65535 {
+		errs = append(errs, c.Invalid("ui.port", "port %d is out of range", c.UI.Port))
+	}
+
+	switch c.Shutdown.OrderPolicy {
+	case "", OrderPolicyLeave, OrderPolicyCancelAll, OrderPolicyCancelNonProtective:
+	default:
+		errs = append(errs, c.Invalid("shutdown.order_policy", "unknown policy %q, use %s, %s or %s",
+			c.Shutdown.OrderPolicy, OrderPolicyLeave, OrderPolicyCancelAll, OrderPolicyCancelNonProtective))
+	}
+	if c.Shutdown.Timeout < 0 {
+		errs = append(errs, c.Invalid("shutdown.timeout", "must not be negative"))
+	}
+
+	return errs.Err()
+}
+
+// RestartRequired lists the sections that changed between two configurations but are only read on startup.
+// Exchanges, pairs, strategies, risk limits and the log level are applied while running, the type and account
+// of a running exchange are not.
+func RestartRequired(old, new *Config) []string {
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"database", old.Database, new.Database},
+		{"api", old.API, new.API},
+		{"ui", old.UI, new.UI},
+		{"strategies", old.Strategies, new.Strategies},
+		{"portfolio", old.Portfolio, new.Portfolio},
+		{"notifications", old.Notifications, new.Notifications},
+		{"vault", old.Vault, new.Vault},
+		{"audit", old.Audit, new.Audit},
+	}
+
+	var changed []string
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.new) {
+			changed = append(changed, section.name)
+		}
+	}
+
+	names := make([]string, 0, len(new.Exchanges))
+	for name := range new.Exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		previous, exists := old.Exchanges[name]
+		if !exists || !previous.Enabled {
+			continue
+		}
+		if previous.Type != new.Exchanges[name].Type {
+			changed = append(changed, "exchanges."+name+".type")
+		}
+		if previous.Account != new.Exchanges[name].Account {
+			changed = append(changed, "exchanges."+name+".account")
+		}
+	}
+	return changed
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -30,6 +31,100 @@ type Order struct {
 	UpdatedAt       time.Time
 }
 
+// PortfolioSnapshot is the valued state of one exchange portfolio at a point in time
+type PortfolioSnapshot struct {
+	ID            int64
+	Exchange      string
+	BaseCurrency  string
+	TotalValue    float64
+	Cash          float64
+	UnrealizedPnL float64
+	RealizedPnL   float64
+	Assets        map[string]float64 // asset -> value in base currency
+	Reason        string             // what triggered the snapshot, e.g. "periodic", "trade" or "shutdown"
+	CreatedAt     time.Time
+}
+
+// Fill is an executed order. Strategy is empty for manual orders.
+type Fill struct {
+	ID           int64
+	Exchange     string
+	OrderID      string
+	Symbol       string
+	Side         string
+	Quantity     float64
+	Price        float64
+	Fee          float64 // in the quote asset
+	FeeCurrency  string  // asset the exchange charged the fee in, empty when it was not reported
+	Strategy     string
+	BaseCurrency string
+	QuoteRate    float64 // price of one unit of the quote asset in BaseCurrency when filled, 0 if unknown
+	CreatedAt    time.Time
+}
+
+// CashFlow is a deposit (positive) or withdrawal (negative) that moved money in or out of an exchange
+type CashFlow struct {
+	ID           int64
+	Exchange     string
+	ExternalID   string // exchange transfer ID, or a generated ID for manual entries
+	Asset        string
+	Amount       float64 // signed amount of Asset
+	Value        float64 // signed value in BaseCurrency at the time it was recorded
+	BaseCurrency string
+	Source       string // "exchange" or "manual"
+	Note         string
+	CreatedAt    time.Time
+}
+
+// StrategyVersion is a saved revision of a strategy script, versions count up from 1 per script
+type StrategyVersion struct {
+	ID        int64
+	Name      string
+	Version   int
+	Source    string
+	Note      string
+	CreatedAt time.Time
+}
+
+// AuditEntry is a record of the append-only audit journal
+type AuditEntry struct {
+	ID       int64
+	Time     time.Time
+	Type     string
+	Exchange string
+	Symbol   string
+	Strategy string
+	OrderID  string
+	Actor    string // Who or what caused the entry
+	Message  string
+	Data     string // Details as JSON
+}
+
+// AuditFilter selects audit entries, empty fields and zero times match everything
+type AuditFilter struct {
+	From     time.Time
+	To       time.Time
+	Type     string
+	Exchange string
+	Symbol   string
+	Strategy string
+	OrderID  string
+	Limit    int // Newest entries to return, 0 returns all
+}
+
+// Kline is a closed candle of one symbol and interval on an exchange
+type Kline struct {
+	Exchange string
+	Symbol   string
+	Interval string
+	OpenTime time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+}
+
 // DB represents the database connection
 type DB struct {
 	conn *sql.DB
@@ -186,6 +281,607 @@ func (db *DB) UpdateOrder(order *Order) error {
 	return err
 }
 
+// GetFilledOrders returns orders marked filled whose last update falls between from and to, oldest first
+func (db *DB) GetFilledOrders(from, to time.Time) ([]*Order, error) {
+	query := `
+		SELECT id, order_id, exchange, symbol, side, type, quantity, price, status, created_at, updated_at
+		FROM orders
+		WHERE LOWER(status) = 'filled' AND updated_at >= ? AND updated_at <= ?
+		ORDER BY updated_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*Order
+	for rows.Next() {
+		order := &Order{}
+		err := rows.Scan(
+			&order.ID,
+			&order.ExchangeOrderID,
+			&order.Exchange,
+			&order.Symbol,
+			&order.Side,
+			&order.Type,
+			&order.Quantity,
+			&order.Price,
+			&order.Status,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// SavePortfolioSnapshot stores a portfolio snapshot. Times are stored in UTC so range queries compare correctly.
+func (db *DB) SavePortfolioSnapshot(snapshot *PortfolioSnapshot) error {
+	assets, err := json.Marshal(snapshot.Assets)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot assets: %w", err)
+	}
+
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO portfolio_snapshots (exchange, base_currency, total_balance, cash, unrealized_pl, realized_pl, assets, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		snapshot.Exchange,
+		snapshot.BaseCurrency,
+		snapshot.TotalValue,
+		snapshot.Cash,
+		snapshot.UnrealizedPnL,
+		snapshot.RealizedPnL,
+		string(assets),
+		snapshot.Reason,
+		snapshot.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	snapshot.ID = id
+
+	return nil
+}
+
+// GetPortfolioSnapshots returns snapshots between from and to, oldest first. An empty exchange returns all exchanges.
+func (db *DB) GetPortfolioSnapshots(exchange string, from, to time.Time) ([]*PortfolioSnapshot, error) {
+	query := `
+		SELECT id, exchange, base_currency, total_balance, cash, unrealized_pl, realized_pl, assets, reason, created_at
+		FROM portfolio_snapshots
+		WHERE created_at >= ? AND created_at <= ? AND (? = '' OR exchange = ?)
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, from.UTC(), to.UTC(), exchange, exchange)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []*PortfolioSnapshot
+	for rows.Next() {
+		snapshot := &PortfolioSnapshot{}
+		var assets string
+		err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.Exchange,
+			&snapshot.BaseCurrency,
+			&snapshot.TotalValue,
+			&snapshot.Cash,
+			&snapshot.UnrealizedPnL,
+			&snapshot.RealizedPnL,
+			&assets,
+			&snapshot.Reason,
+			&snapshot.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(assets), &snapshot.Assets); err != nil {
+			snapshot.Assets = make(map[string]float64)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// GetLatestPortfolioSnapshot returns the most recent snapshot of an exchange, or nil if there is none
+func (db *DB) GetLatestPortfolioSnapshot(exchange string) (*PortfolioSnapshot, error) {
+	query := `
+		SELECT id, base_currency, total_balance, cash, unrealized_pl, realized_pl, reason, created_at
+		FROM portfolio_snapshots
+		WHERE exchange = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	snapshot := &PortfolioSnapshot{Exchange: exchange}
+	err := db.conn.QueryRow(query, exchange).Scan(
+		&snapshot.ID,
+		&snapshot.BaseCurrency,
+		&snapshot.TotalValue,
+		&snapshot.Cash,
+		&snapshot.UnrealizedPnL,
+		&snapshot.RealizedPnL,
+		&snapshot.Reason,
+		&snapshot.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// GetPeakPortfolioValue returns the highest total value recorded for an exchange in baseCurrency, or 0 without history.
+// Each value is reduced by the net cash flows up to that snapshot, so deposits do not count as a peak;
+// add the current net cash flows to compare it with today's value.
+func (db *DB) GetPeakPortfolioValue(exchange, baseCurrency string) (float64, error) {
+	query := `
+		SELECT MAX(s.total_balance - (
+			SELECT COALESCE(SUM(f.value), 0) FROM cash_flows f
+			WHERE f.exchange = s.exchange AND f.base_currency = s.base_currency AND f.created_at <= s.created_at
+		))
+		FROM portfolio_snapshots s
+		WHERE s.exchange = ? AND s.base_currency = ?
+	`
+
+	var peak sql.NullFloat64
+	if err := db.conn.QueryRow(query, exchange, baseCurrency).Scan(&peak); err != nil {
+		return 0, err
+	}
+	return peak.Float64, nil
+}
+
+// SaveFill stores a fill. A fill that was already recorded for the same exchange order is ignored.
+func (db *DB) SaveFill(fill *Fill) error {
+	if fill.CreatedAt.IsZero() {
+		fill.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT OR IGNORE INTO fills (exchange, order_id, symbol, side, quantity, price, fee, fee_currency, strategy, base_currency, quote_rate, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := db.conn.Exec(query,
+		fill.Exchange,
+		fill.OrderID,
+		fill.Symbol,
+		fill.Side,
+		fill.Quantity,
+		fill.Price,
+		fill.Fee,
+		fill.FeeCurrency,
+		fill.Strategy,
+		fill.BaseCurrency,
+		fill.QuoteRate,
+		fill.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// GetFills returns fills between from and to, oldest first. An empty exchange returns all exchanges.
+func (db *DB) GetFills(exchange string, from, to time.Time) ([]*Fill, error) {
+	query := `
+		SELECT id, exchange, order_id, symbol, side, quantity, price, fee, fee_currency, strategy, base_currency, quote_rate, created_at
+		FROM fills
+		WHERE created_at >= ? AND created_at <= ? AND (? = '' OR exchange = ?)
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, from.UTC(), to.UTC(), exchange, exchange)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fills []*Fill
+	for rows.Next() {
+		fill := &Fill{}
+		err := rows.Scan(
+			&fill.ID,
+			&fill.Exchange,
+			&fill.OrderID,
+			&fill.Symbol,
+			&fill.Side,
+			&fill.Quantity,
+			&fill.Price,
+			&fill.Fee,
+			&fill.FeeCurrency,
+			&fill.Strategy,
+			&fill.BaseCurrency,
+			&fill.QuoteRate,
+			&fill.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		fills = append(fills, fill)
+	}
+
+	return fills, rows.Err()
+}
+
+// SaveCashFlow stores a cash flow and reports whether it was new. A transfer that was already recorded is ignored.
+func (db *DB) SaveCashFlow(flow *CashFlow) (bool, error) {
+	if flow.CreatedAt.IsZero() {
+		flow.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT OR IGNORE INTO cash_flows (exchange, external_id, asset, amount, value, base_currency, source, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		flow.Exchange,
+		flow.ExternalID,
+		flow.Asset,
+		flow.Amount,
+		flow.Value,
+		flow.BaseCurrency,
+		flow.Source,
+		flow.Note,
+		flow.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	flow.ID = id
+
+	return true, nil
+}
+
+// GetCashFlows returns cash flows between from and to, oldest first. An empty exchange returns all exchanges.
+func (db *DB) GetCashFlows(exchange string, from, to time.Time) ([]*CashFlow, error) {
+	query := `
+		SELECT id, exchange, external_id, asset, amount, value, base_currency, source, note, created_at
+		FROM cash_flows
+		WHERE created_at >= ? AND created_at <= ? AND (? = '' OR exchange = ?)
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, from.UTC(), to.UTC(), exchange, exchange)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flows []*CashFlow
+	for rows.Next() {
+		flow := &CashFlow{}
+		err := rows.Scan(
+			&flow.ID,
+			&flow.Exchange,
+			&flow.ExternalID,
+			&flow.Asset,
+			&flow.Amount,
+			&flow.Value,
+			&flow.BaseCurrency,
+			&flow.Source,
+			&flow.Note,
+			&flow.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, flow)
+	}
+
+	return flows, rows.Err()
+}
+
+// GetNetCashFlows returns the sum of all cash flows of an exchange recorded in baseCurrency
+func (db *DB) GetNetCashFlows(exchange, baseCurrency string) (float64, error) {
+	var total sql.NullFloat64
+	err := db.conn.QueryRow(
+		`SELECT SUM(value) FROM cash_flows WHERE exchange = ? AND base_currency = ?`,
+		exchange, baseCurrency,
+	).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total.Float64, nil
+}
+
+// SaveStrategyVersion stores source as the next version of a strategy script. Source identical to
+// the latest version is not stored again; that version is returned instead.
+func (db *DB) SaveStrategyVersion(name, source, note string) (*StrategyVersion, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	latest := &StrategyVersion{Name: name}
+	err = tx.QueryRow(`
+		SELECT id, version, source, note, created_at
+		FROM strategy_versions
+		WHERE name = ?
+		ORDER BY version DESC
+		LIMIT 1
+	`, name).Scan(&latest.ID, &latest.Version, &latest.Source, &latest.Note, &latest.CreatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && latest.Source == source {
+		return latest, nil
+	}
+
+	version := &StrategyVersion{
+		Name:      name,
+		Version:   latest.Version + 1,
+		Source:    source,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+	result, err := tx.Exec(`
+		INSERT INTO strategy_versions (name, version, source, note, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, version.Name, version.Version, version.Source, version.Note, version.CreatedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	version.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return version, tx.Commit()
+}
+
+// GetStrategyVersions returns the versions of a strategy script, newest first, without their source
+func (db *DB) GetStrategyVersions(name string) ([]*StrategyVersion, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, version, note, created_at
+		FROM strategy_versions
+		WHERE name = ?
+		ORDER BY version DESC
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*StrategyVersion
+	for rows.Next() {
+		version := &StrategyVersion{Name: name}
+		if err := rows.Scan(&version.ID, &version.Version, &version.Note, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// GetStrategyVersion returns one version of a strategy script with its source, or nil if it does not exist
+func (db *DB) GetStrategyVersion(name string, number int) (*StrategyVersion, error) {
+	version := &StrategyVersion{Name: name}
+	err := db.conn.QueryRow(`
+		SELECT id, version, source, note, created_at
+		FROM strategy_versions
+		WHERE name = ? AND version = ?
+	`, name, number).Scan(&version.ID, &version.Version, &version.Source, &version.Note, &version.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// SaveKlines stores klines in one transaction, replacing stored klines with the same open time
+func (db *DB) SaveKlines(klines []*Kline) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO klines (exchange, symbol, interval, open_time, open, high, low, close, volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, kline := range klines {
+		_, err := stmt.Exec(kline.Exchange, kline.Symbol, kline.Interval, kline.OpenTime.UTC(),
+			kline.Open, kline.High, kline.Low, kline.Close, kline.Volume)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetKlines returns the klines that opened between from and to, oldest first
+func (db *DB) GetKlines(exchange, symbol, interval string, from, to time.Time) ([]*Kline, error) {
+	rows, err := db.conn.Query(`
+		SELECT open_time, open, high, low, close, volume
+		FROM klines
+		WHERE exchange = ? AND symbol = ? AND interval = ? AND open_time >= ? AND open_time <= ?
+		ORDER BY open_time ASC
+	`, exchange, symbol, interval, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanKlines(rows, exchange, symbol, interval)
+}
+
+// GetRecentKlines returns the limit most recent klines that opened at or before to, oldest first
+func (db *DB) GetRecentKlines(exchange, symbol, interval string, to time.Time, limit int) ([]*Kline, error) {
+	rows, err := db.conn.Query(`
+		SELECT open_time, open, high, low, close, volume
+		FROM (
+			SELECT open_time, open, high, low, close, volume
+			FROM klines
+			WHERE exchange = ? AND symbol = ? AND interval = ? AND open_time <= ?
+			ORDER BY open_time DESC
+			LIMIT ?
+		)
+		ORDER BY open_time ASC
+	`, exchange, symbol, interval, to.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanKlines(rows, exchange, symbol, interval)
+}
+
+// GetKlineTimes returns the open times of the klines stored between from and to, oldest first
+func (db *DB) GetKlineTimes(exchange, symbol, interval string, from, to time.Time) ([]time.Time, error) {
+	rows, err := db.conn.Query(`
+		SELECT open_time
+		FROM klines
+		WHERE exchange = ? AND symbol = ? AND interval = ? AND open_time >= ? AND open_time <= ?
+		ORDER BY open_time ASC
+	`, exchange, symbol, interval, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var openTime time.Time
+		if err := rows.Scan(&openTime); err != nil {
+			return nil, err
+		}
+		times = append(times, openTime)
+	}
+
+	return times, rows.Err()
+}
+
+func scanKlines(rows *sql.Rows, exchange, symbol, interval string) ([]*Kline, error) {
+	defer rows.Close()
+
+	var klines []*Kline
+	for rows.Next() {
+		kline := &Kline{Exchange: exchange, Symbol: symbol, Interval: interval}
+		if err := rows.Scan(&kline.OpenTime, &kline.Open, &kline.High, &kline.Low, &kline.Close, &kline.Volume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, kline)
+	}
+
+	return klines, rows.Err()
+}
+
+// AppendAuditEntry adds an entry to the audit journal
+func (db *DB) AppendAuditEntry(entry *AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Data == "" {
+		entry.Data = "{}"
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO audit_log (time, type, exchange, symbol, strategy, order_id, actor, message, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.Time.UTC(), entry.Type, entry.Exchange, entry.Symbol, entry.Strategy, entry.OrderID, entry.Actor, entry.Message, entry.Data)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetAuditEntries returns the audit entries matching filter, newest first
+func (db *DB) GetAuditEntries(filter AuditFilter) ([]*AuditEntry, error) {
+	query := `
+		SELECT id, time, type, exchange, symbol, strategy, order_id, actor, message, data
+		FROM audit_log
+		WHERE (? OR time >= ?) AND (? OR time <= ?)
+			AND (? = '' OR type = ?) AND (? = '' OR exchange = ?) AND (? = '' OR symbol = ?)
+			AND (? = '' OR strategy = ?) AND (? = '' OR order_id = ?)
+		ORDER BY id DESC`
+	args := []interface{}{
+		filter.From.IsZero(), filter.From.UTC(),
+		filter.To.IsZero(), filter.To.UTC(),
+		filter.Type, filter.Type,
+		filter.Exchange, filter.Exchange,
+		filter.Symbol, filter.Symbol,
+		filter.Strategy, filter.Strategy,
+		filter.OrderID, filter.OrderID,
+	}
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		entry := &AuditEntry{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Time,
+			&entry.Type,
+			&entry.Exchange,
+			&entry.Symbol,
+			&entry.Strategy,
+			&entry.OrderID,
+			&entry.Actor,
+			&entry.Message,
+			&entry.Data,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
 // Conn returns the underlying database connection
 func (db *DB) Conn() *sql.DB {
 	return db.conn
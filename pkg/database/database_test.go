@@ -449,4 +449,330 @@ func TestConn(t *testing.T) {
 			t.Errorf("expected result 1, got %d", result)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestPortfolioSnapshots(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	if peak, err := db.GetPeakPortfolioValue("bybit", "USDT"); err != nil || peak != 0 {
+		t.Errorf("expected no peak without history, got %f (%v)", peak, err)
+	}
+	if latest, err := db.GetLatestPortfolioSnapshot("bybit"); err != nil || latest != nil {
+		t.Errorf("expected no latest snapshot without history, got %+v (%v)", latest, err)
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	values := []float64{1000, 1200, 1100}
+	for i, value := range values {
+		snapshot := &PortfolioSnapshot{
+			Exchange:     "bybit",
+			BaseCurrency: "USDT",
+			TotalValue:   value,
+			Cash:         500,
+			Assets:       map[string]float64{"USDT": 500, "BTC": value - 500},
+			Reason:       "periodic",
+			// A non-UTC zone must still sort and filter correctly
+			CreatedAt: start.Add(time.Duration(i) * time.Hour).In(time.FixedZone("CET", 3600)),
+		}
+		if err := db.SavePortfolioSnapshot(snapshot); err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+		if snapshot.ID == 0 {
+			t.Error("expected snapshot ID to be set")
+		}
+	}
+	if err := db.SavePortfolioSnapshot(&PortfolioSnapshot{Exchange: "bitvavo", TotalValue: 5000, CreatedAt: start}); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+
+	snapshots, err := db.GetPortfolioSnapshots("bybit", start.Add(30*time.Minute), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].TotalValue != 1200 || snapshots[1].TotalValue != 1100 {
+		t.Fatalf("expected the last two bybit snapshots in order, got %d", len(snapshots))
+	}
+	if snapshots[0].Assets["BTC"] != 700 || snapshots[0].BaseCurrency != "USDT" {
+		t.Errorf("expected asset breakdown to round trip, got %+v", snapshots[0])
+	}
+	if !snapshots[0].CreatedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected created at %v, got %v", start.Add(time.Hour), snapshots[0].CreatedAt)
+	}
+
+	all, err := db.GetPortfolioSnapshots("", start, start.Add(3*time.Hour))
+	if err != nil || len(all) != 4 {
+		t.Errorf("expected 4 snapshots across exchanges, got %d (%v)", len(all), err)
+	}
+
+	if peak, err := db.GetPeakPortfolioValue("bybit", "USDT"); err != nil || peak != 1200 {
+		t.Errorf("expected peak 1200, got %f (%v)", peak, err)
+	}
+	if peak, err := db.GetPeakPortfolioValue("bybit", "EUR"); err != nil || peak != 0 {
+		t.Errorf("expected no peak in another base currency, got %f (%v)", peak, err)
+	}
+	latest, err := db.GetLatestPortfolioSnapshot("bybit")
+	if err != nil || latest == nil || latest.TotalValue != 1100 {
+		t.Errorf("expected latest value 1100, got %+v (%v)", latest, err)
+	}
+}
+
+func TestFills(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fills := []*Fill{
+		{Exchange: "bybit", OrderID: "1", Symbol: "BTCUSDT", Side: "buy", Quantity: 0.1, Price: 40000, Strategy: "sma", CreatedAt: start},
+		{Exchange: "bybit", OrderID: "2", Symbol: "BTCUSDT", Side: "sell", Quantity: 0.1, Price: 41000, Fee: 1.5, Strategy: "sma", CreatedAt: start.Add(time.Hour)},
+		{Exchange: "bitvavo", OrderID: "1", Symbol: "ETH-EUR", Side: "buy", Quantity: 1, Price: 2000, CreatedAt: start.Add(2 * time.Hour)},
+		// The same exchange order is only recorded once
+		{Exchange: "bybit", OrderID: "2", Symbol: "BTCUSDT", Side: "sell", Quantity: 0.1, Price: 41000, CreatedAt: start.Add(3 * time.Hour)},
+	}
+	for _, fill := range fills {
+		if err := db.SaveFill(fill); err != nil {
+			t.Fatalf("failed to save fill: %v", err)
+		}
+	}
+
+	all, err := db.GetFills("", start, start.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get fills: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 fills, got %d", len(all))
+	}
+	if all[1].Strategy != "sma" || all[1].Fee != 1.5 || !all[1].CreatedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected fill to round trip, got %+v", all[1])
+	}
+
+	bybit, err := db.GetFills("bybit", start.Add(30*time.Minute), start.Add(4*time.Hour))
+	if err != nil || len(bybit) != 1 || bybit[0].OrderID != "2" {
+		t.Errorf("expected one bybit fill in range, got %d (%v)", len(bybit), err)
+	}
+}
+
+func TestCashFlows(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	flows := []*CashFlow{
+		{Exchange: "bybit", ExternalID: "deposit:a", Asset: "USDT", Amount: 1000, Value: 1000, BaseCurrency: "USDT", Source: "exchange", CreatedAt: start},
+		{Exchange: "bybit", ExternalID: "withdrawal:b", Asset: "USDT", Amount: -300, Value: -300, BaseCurrency: "USDT", Source: "exchange", CreatedAt: start.Add(time.Hour)},
+		{Exchange: "bitvavo", ExternalID: "manual-1", Asset: "EUR", Amount: 500, Value: 500, BaseCurrency: "EUR", Source: "manual", Note: "bank transfer", CreatedAt: start.Add(2 * time.Hour)},
+	}
+	for _, flow := range flows {
+		inserted, err := db.SaveCashFlow(flow)
+		if err != nil || !inserted {
+			t.Fatalf("failed to save cash flow: %v (inserted %t)", err, inserted)
+		}
+	}
+
+	// The same transfer is only recorded once
+	inserted, err := db.SaveCashFlow(&CashFlow{Exchange: "bybit", ExternalID: "deposit:a", Asset: "USDT", Amount: 1000, Value: 1000, BaseCurrency: "USDT", Source: "exchange"})
+	if err != nil || inserted {
+		t.Errorf("expected duplicate transfer to be ignored, got inserted %t (%v)", inserted, err)
+	}
+
+	all, err := db.GetCashFlows("", start, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get cash flows: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 cash flows, got %d", len(all))
+	}
+	if all[2].Note != "bank transfer" || all[2].Source != "manual" || !all[2].CreatedAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("expected cash flow to round trip, got %+v", all[2])
+	}
+
+	net, err := db.GetNetCashFlows("bybit", "USDT")
+	if err != nil || net != 700 {
+		t.Errorf("expected net cash flows of 700, got %f (%v)", net, err)
+	}
+	if net, _ := db.GetNetCashFlows("bybit", "EUR"); net != 0 {
+		t.Errorf("expected no net cash flows in another base currency, got %f", net)
+	}
+
+	// The peak excludes money that was deposited to reach it
+	snapshots := []*PortfolioSnapshot{
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 900, CreatedAt: start.Add(-time.Hour)},
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1850, CreatedAt: start.Add(30 * time.Minute)},
+		{Exchange: "bybit", BaseCurrency: "USDT", TotalValue: 1500, CreatedAt: start.Add(2 * time.Hour)},
+	}
+	for _, snapshot := range snapshots {
+		if err := db.SavePortfolioSnapshot(snapshot); err != nil {
+			t.Fatalf("failed to save snapshot: %v", err)
+		}
+	}
+
+	// 900 before any flow, 1850 - 1000 after the deposit, 1500 - 700 after the withdrawal
+	peak, err := db.GetPeakPortfolioValue("bybit", "USDT")
+	if err != nil || peak != 900 {
+		t.Errorf("expected flow-adjusted peak of 900, got %f (%v)", peak, err)
+	}
+}
+
+func TestStrategyVersions(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	first, err := db.SaveStrategyVersion("sma", "# v1", "initial")
+	if err != nil || first.Version != 1 {
+		t.Fatalf("expected version 1, got %+v (%v)", first, err)
+	}
+
+	// Saving unchanged source keeps the latest version
+	same, err := db.SaveStrategyVersion("sma", "# v1", "no change")
+	if err != nil || same.Version != 1 || same.Note != "initial" {
+		t.Fatalf("expected the existing version 1, got %+v (%v)", same, err)
+	}
+
+	second, err := db.SaveStrategyVersion("sma", "# v2", "")
+	if err != nil || second.Version != 2 {
+		t.Fatalf("expected version 2, got %+v (%v)", second, err)
+	}
+	if other, err := db.SaveStrategyVersion("rsi", "# v1", ""); err != nil || other.Version != 1 {
+		t.Fatalf("expected versions to count per script, got %+v (%v)", other, err)
+	}
+
+	versions, err := db.GetStrategyVersions("sma")
+	if err != nil {
+		t.Fatalf("failed to get versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 || versions[0].Source != "" {
+		t.Errorf("expected versions 2 and 1 without source, got %+v", versions)
+	}
+
+	version, err := db.GetStrategyVersion("sma", 1)
+	if err != nil || version == nil || version.Source != "# v1" || version.Note != "initial" {
+		t.Errorf("expected version 1 with its source, got %+v (%v)", version, err)
+	}
+	if missing, err := db.GetStrategyVersion("sma", 3); err != nil || missing != nil {
+		t.Errorf("expected no version 3, got %+v (%v)", missing, err)
+	}
+}
+
+func TestKlines(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var klines []*Kline
+	for i := 0; i < 5; i++ {
+		klines = append(klines, &Kline{
+			Exchange: "bybit",
+			Symbol:   "BTCUSDT",
+			Interval: "1h",
+			OpenTime: start.Add(time.Duration(i) * time.Hour).Local(),
+			Close:    float64(100 + i),
+		})
+	}
+	other := &Kline{Exchange: "bybit", Symbol: "BTCUSDT", Interval: "4h", OpenTime: start, Close: 1}
+	if err := db.SaveKlines(append(klines, other)); err != nil {
+		t.Fatalf("failed to save klines: %v", err)
+	}
+
+	// Saving the same open time again replaces the kline
+	if err := db.SaveKlines([]*Kline{{Exchange: "bybit", Symbol: "BTCUSDT", Interval: "1h", OpenTime: start.Add(time.Hour), Close: 111}}); err != nil {
+		t.Fatalf("failed to save kline: %v", err)
+	}
+
+	stored, err := db.GetKlines("bybit", "BTCUSDT", "1h", start.Add(time.Hour), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get klines: %v", err)
+	}
+	if len(stored) != 3 || stored[0].Close != 111 || stored[2].Close != 103 || !stored[0].OpenTime.Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected klines %+v", stored)
+	}
+	if stored[0].Exchange != "bybit" || stored[0].Symbol != "BTCUSDT" || stored[0].Interval != "1h" {
+		t.Errorf("expected the kline's key fields, got %+v", stored[0])
+	}
+
+	recent, err := db.GetRecentKlines("bybit", "BTCUSDT", "1h", start.Add(3*time.Hour), 2)
+	if err != nil {
+		t.Fatalf("failed to get recent klines: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Close != 102 || recent[1].Close != 103 {
+		t.Errorf("expected the 2 klines up to 03:00 oldest first, got %+v", recent)
+	}
+
+	times, err := db.GetKlineTimes("bybit", "BTCUSDT", "1h", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get kline times: %v", err)
+	}
+	if len(times) != 5 || !times[4].Equal(start.Add(4*time.Hour)) {
+		t.Errorf("unexpected open times %v", times)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []*AuditEntry{
+		{Time: start, Type: "signal", Exchange: "bybit", Symbol: "BTCUSDT", Strategy: "sma"},
+		{Time: start.Add(time.Minute), Type: "order_request", Exchange: "bybit", Symbol: "BTCUSDT", Strategy: "sma"},
+		{Time: start.Add(2 * time.Minute), Type: "order_response", Exchange: "bybit", Symbol: "BTCUSDT", OrderID: "42", Data: `{"status":"filled"}`},
+		{Time: start.Add(3 * time.Minute), Type: "signal", Exchange: "bybit", Symbol: "ETHUSDT", Strategy: "rsi"},
+	}
+	for _, entry := range entries {
+		if err := db.AppendAuditEntry(entry); err != nil {
+			t.Fatalf("failed to append audit entry: %v", err)
+		}
+	}
+	if entries[3].ID == 0 {
+		t.Error("expected the entry ID to be set")
+	}
+
+	stored, err := db.GetAuditEntries(AuditFilter{Symbol: "BTCUSDT", From: start.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("failed to get audit entries: %v", err)
+	}
+	if len(stored) != 2 || stored[0].OrderID != "42" || stored[0].Data != `{"status":"filled"}` || stored[1].Data != "{}" {
+		t.Errorf("expected the 2 BTCUSDT entries since 00:01 newest first, got %+v", stored)
+	}
+
+	stored, err = db.GetAuditEntries(AuditFilter{Strategy: "sma", Type: "signal"})
+	if err != nil {
+		t.Fatalf("failed to get audit entries: %v", err)
+	}
+	if len(stored) != 1 || !stored[0].Time.Equal(start) {
+		t.Errorf("expected the sma signal, got %+v", stored)
+	}
+
+	stored, err = db.GetAuditEntries(AuditFilter{To: start.Add(2 * time.Minute), Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to get audit entries: %v", err)
+	}
+	if len(stored) != 1 || stored[0].OrderID != "42" {
+		t.Errorf("expected the newest entry up to 00:02, got %+v", stored)
+	}
+
+	// The journal is append-only
+	if _, err := db.conn.Exec("UPDATE audit_log SET message = 'changed'"); err == nil {
+		t.Error("expected updates to be rejected")
+	}
+	if _, err := db.conn.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("expected deletes to be rejected")
+	}
+}
@@ -0,0 +1,7 @@
+-- Remove the valuation details from portfolio snapshots
+DROP INDEX IF EXISTS idx_portfolio_time;
+
+ALTER TABLE portfolio_snapshots DROP COLUMN reason;
+ALTER TABLE portfolio_snapshots DROP COLUMN assets;
+ALTER TABLE portfolio_snapshots DROP COLUMN cash;
+ALTER TABLE portfolio_snapshots DROP COLUMN base_currency;
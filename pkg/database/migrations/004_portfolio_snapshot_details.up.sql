@@ -0,0 +1,7 @@
+-- Extend portfolio snapshots with the valuation details written by the portfolio actor
+ALTER TABLE portfolio_snapshots ADD COLUMN base_currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE portfolio_snapshots ADD COLUMN cash REAL NOT NULL DEFAULT 0;
+ALTER TABLE portfolio_snapshots ADD COLUMN assets TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE portfolio_snapshots ADD COLUMN reason TEXT NOT NULL DEFAULT 'periodic';
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_time ON portfolio_snapshots(created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_fills_strategy;
+DROP INDEX IF EXISTS idx_fills_time;
+DROP TABLE IF EXISTS fills;
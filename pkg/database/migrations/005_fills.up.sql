@@ -0,0 +1,17 @@
+-- Create fills table for executed orders, attributed to the strategy that placed them
+CREATE TABLE IF NOT EXISTS fills (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    exchange TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    quantity REAL NOT NULL,
+    price REAL NOT NULL,
+    fee REAL NOT NULL DEFAULT 0,
+    strategy TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(exchange, order_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fills_time ON fills(created_at);
+CREATE INDEX IF NOT EXISTS idx_fills_strategy ON fills(strategy);
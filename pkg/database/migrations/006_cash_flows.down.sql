@@ -0,0 +1,3 @@
+-- Drop cash flows table
+DROP INDEX IF EXISTS idx_cash_flows_exchange_time;
+DROP TABLE IF EXISTS cash_flows;
@@ -0,0 +1,16 @@
+-- Create cash flows table for deposits and withdrawals, which are not trading performance
+CREATE TABLE IF NOT EXISTS cash_flows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    exchange TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    asset TEXT NOT NULL,
+    amount REAL NOT NULL,
+    value REAL NOT NULL,
+    base_currency TEXT NOT NULL,
+    source TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(exchange, external_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cash_flows_exchange_time ON cash_flows(exchange, created_at);
@@ -0,0 +1,3 @@
+-- Remove the base currency rate from fills
+ALTER TABLE fills DROP COLUMN quote_rate;
+ALTER TABLE fills DROP COLUMN base_currency;
@@ -0,0 +1,3 @@
+-- Record the rate of the quote asset in the base currency so fills can be valued for reports
+ALTER TABLE fills ADD COLUMN base_currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE fills ADD COLUMN quote_rate REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Drop strategy versions table
+DROP TABLE IF EXISTS strategy_versions;
@@ -0,0 +1,2 @@
+-- Drop klines table
+DROP TABLE IF EXISTS klines;
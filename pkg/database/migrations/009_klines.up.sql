@@ -0,0 +1,13 @@
+-- Create klines table, the local store of closed candles per exchange, symbol and interval
+CREATE TABLE IF NOT EXISTS klines (
+    exchange TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    open_time DATETIME NOT NULL,
+    open REAL NOT NULL,
+    high REAL NOT NULL,
+    low REAL NOT NULL,
+    close REAL NOT NULL,
+    volume REAL NOT NULL,
+    PRIMARY KEY (exchange, symbol, interval, open_time)
+) WITHOUT ROWID;
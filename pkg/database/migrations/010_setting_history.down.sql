@@ -0,0 +1,4 @@
+-- Drop setting values and history
+DROP INDEX IF EXISTS idx_setting_history_key;
+DROP TABLE IF EXISTS setting_history;
+DROP TABLE IF EXISTS setting_values;
@@ -0,0 +1,39 @@
+-- Create setting values per scope, an empty exchange is global and empty strategy and symbol cover the exchange
+CREATE TABLE IF NOT EXISTS setting_values (
+    exchange TEXT NOT NULL DEFAULT '',
+    strategy TEXT NOT NULL DEFAULT '',
+    symbol TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (exchange, strategy, symbol, key)
+);
+
+-- Create setting history, every change with its author. Versions count up across all settings and
+-- a NULL value means the setting was not set
+CREATE TABLE IF NOT EXISTS setting_history (
+    version INTEGER PRIMARY KEY AUTOINCREMENT,
+    exchange TEXT NOT NULL DEFAULT '',
+    strategy TEXT NOT NULL DEFAULT '',
+    symbol TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    actor TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_setting_history_key ON setting_history(key, version);
+
+-- Import the untyped exchange settings as the first versions
+INSERT INTO setting_history (exchange, key, old_value, new_value, actor, note, created_at)
+SELECT COALESCE(exchange, ''), key, NULL, value, 'migration', 'imported from settings', updated_at
+FROM settings
+ORDER BY updated_at, id;
+
+INSERT OR REPLACE INTO setting_values (exchange, strategy, symbol, key, value, version, updated_at)
+SELECT exchange, strategy, symbol, key, new_value, version, created_at
+FROM setting_history
+ORDER BY version;
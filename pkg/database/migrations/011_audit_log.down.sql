@@ -0,0 +1,8 @@
+-- Drop the audit journal
+DROP TRIGGER IF EXISTS audit_log_no_delete;
+DROP TRIGGER IF EXISTS audit_log_no_update;
+DROP INDEX IF EXISTS idx_audit_log_order;
+DROP INDEX IF EXISTS idx_audit_log_strategy;
+DROP INDEX IF EXISTS idx_audit_log_symbol;
+DROP INDEX IF EXISTS idx_audit_log_time;
+DROP TABLE IF EXISTS audit_log;
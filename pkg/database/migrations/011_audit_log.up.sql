@@ -0,0 +1,30 @@
+-- Create the audit journal of signals, risk decisions, orders and operator actions. Data holds the
+-- details of an entry as JSON.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    time DATETIME NOT NULL,
+    type TEXT NOT NULL,
+    exchange TEXT NOT NULL DEFAULT '',
+    symbol TEXT NOT NULL DEFAULT '',
+    strategy TEXT NOT NULL DEFAULT '',
+    order_id TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    data TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log(time);
+CREATE INDEX IF NOT EXISTS idx_audit_log_symbol ON audit_log(symbol, time);
+CREATE INDEX IF NOT EXISTS idx_audit_log_strategy ON audit_log(strategy, time);
+CREATE INDEX IF NOT EXISTS idx_audit_log_order ON audit_log(order_id);
+
+-- The journal is append-only
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit log is append-only');
+END;
@@ -0,0 +1,2 @@
+-- Remove the fee currency from fills
+ALTER TABLE fills DROP COLUMN fee_currency;
@@ -0,0 +1,2 @@
+-- Record the asset the exchange charged the fee of a fill in
+ALTER TABLE fills ADD COLUMN fee_currency TEXT NOT NULL DEFAULT '';
@@ -46,14 +46,15 @@ type BybitWSMessage struct {
 }
 
 type BybitKlineWS struct {
-	Start  int64  `json:"start"`
-	End    int64  `json:"end"`
-	Open   string `json:"open"`
-	High   string `json:"high"`
-	Low    string `json:"low"`
-	Close  string `json:"close"`
-	Volume string `json:"volume"`
-	Symbol string `json:"symbol"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Open    string `json:"open"`
+	High    string `json:"high"`
+	Low     string `json:"low"`
+	Close   string `json:"close"`
+	Volume  string `json:"volume"`
+	Symbol  string `json:"symbol"`
+	Confirm bool   `json:"confirm"` // Set on the last update of a bar, when it closes
 }
 
 type BybitOrderBookWS struct {
@@ -110,8 +111,25 @@ func (b *BybitExchange) Connect(ctx context.Context) error {
 	return nil
 }
 
+// Backoff between WebSocket reconnect attempts
+const (
+	wsReconnectMinDelay = time.Second
+	wsReconnectMaxDelay = 30 * time.Second
+)
+
 // connectWebSocket establishes WebSocket connection
 func (b *BybitExchange) connectWebSocket() error {
+	if err := b.dialWebSocket(); err != nil {
+		return err
+	}
+
+	// Start WebSocket message handler
+	go b.handleWebSocketMessages()
+	return nil
+}
+
+// dialWebSocket opens a new WebSocket connection and stores it
+func (b *BybitExchange) dialWebSocket() error {
 	var wsURL string
 	if b.testnet {
 		wsURL = "wss://stream-testnet.bybit.com/v5/public/spot"
@@ -129,41 +147,120 @@ func (b *BybitExchange) connectWebSocket() error {
 	b.wsConn = conn
 	b.wsConnMu.Unlock()
 
-	// Start WebSocket message handler
-	go b.handleWebSocketMessages()
-
 	b.logger.Debug().Str("url", wsURL).Msg("WebSocket connected")
 	return nil
 }
 
-// handleWebSocketMessages processes incoming WebSocket messages
+// handleWebSocketMessages reads messages until the connection drops, then reconnects until Disconnect is called
 func (b *BybitExchange) handleWebSocketMessages() {
-	defer func() {
+	for {
+		if err := b.readWebSocketMessages(); err != nil {
+			for _, handler := range b.connectionHandlers() {
+				handler.OnDisconnect(b.name, err)
+			}
+		}
+
 		b.wsConnMu.Lock()
 		if b.wsConn != nil {
 			b.wsConn.Close()
 			b.wsConn = nil
 		}
 		b.wsConnMu.Unlock()
-	}()
 
+		if !b.reconnectWebSocket() {
+			return
+		}
+		for _, handler := range b.connectionHandlers() {
+			handler.OnReconnect(b.name)
+		}
+	}
+}
+
+// connectionHandlers returns the distinct subscribers that implement ConnectionHandler
+func (b *BybitExchange) connectionHandlers() []ConnectionHandler {
+	b.subMu.RLock()
+	defer b.subMu.RUnlock()
+
+	var handlers []ConnectionHandler
+	seen := make(map[DataHandler]bool)
+	for _, handler := range b.subscriptions {
+		if seen[handler] {
+			continue
+		}
+		seen[handler] = true
+		if connectionHandler, ok := handler.(ConnectionHandler); ok {
+			handlers = append(handlers, connectionHandler)
+		}
+	}
+	return handlers
+}
+
+// reconnectWebSocket dials with exponential backoff and resubscribes to every topic,
+// returning false when the exchange was disconnected in the meantime
+func (b *BybitExchange) reconnectWebSocket() bool {
+	delay := wsReconnectMinDelay
 	for {
 		select {
 		case <-b.ctx.Done():
-			return
+			return false
+		case <-time.After(delay):
+		}
+
+		if err := b.dialWebSocket(); err != nil {
+			b.logger.Warn().Err(err).Dur("retry_in", delay).Msg("WebSocket reconnect failed")
+			delay *= 2
+			if delay > wsReconnectMaxDelay {
+				delay = wsReconnectMaxDelay
+			}
+			continue
+		}
+		wsReconnects.With(b.name).Inc()
+
+		b.subMu.RLock()
+		topics := make([]string, 0, len(b.subscriptions))
+		for topic := range b.subscriptions {
+			topics = append(topics, topic)
+		}
+		b.subMu.RUnlock()
+
+		for _, topic := range topics {
+			subMsg := map[string]interface{}{
+				"op":   "subscribe",
+				"args": []string{topic},
+			}
+			if err := b.sendWebSocketMessage(subMsg); err != nil {
+				b.logger.Error().Err(err).Str("topic", topic).Msg("Failed to resubscribe")
+			}
+		}
+
+		b.logger.Info().Int("topics", len(topics)).Msg("WebSocket reconnected")
+		return true
+	}
+}
+
+// readWebSocketMessages processes incoming WebSocket messages until a read fails, returning the error,
+// or until the context is cancelled or the connection closed by Disconnect, returning nil
+func (b *BybitExchange) readWebSocketMessages() error {
+	for {
+		select {
+		case <-b.ctx.Done():
+			return nil
 		default:
 			b.wsConnMu.RLock()
 			conn := b.wsConn
 			b.wsConnMu.RUnlock()
 
 			if conn == nil {
-				return
+				return nil
 			}
 
 			_, message, err := conn.ReadMessage()
 			if err != nil {
+				if b.ctx.Err() != nil {
+					return nil
+				}
 				b.logger.Error().Err(err).Msg("WebSocket read error")
-				return
+				return err
 			}
 
 			if err := b.processWebSocketMessage(message); err != nil {
@@ -180,6 +277,12 @@ func (b *BybitExchange) processWebSocketMessage(message []byte) error {
 		return fmt.Errorf("failed to unmarshal WebSocket message: %w", err)
 	}
 
+	topic := wsMsg.Topic
+	if topic == "" {
+		topic = "control" // Subscription acknowledgements and pongs
+	}
+	wsMessages.With(b.name, topic).Inc()
+
 	b.subMu.RLock()
 	handler, exists := b.subscriptions[wsMsg.Topic]
 	b.subMu.RUnlock()
@@ -221,6 +324,7 @@ func (b *BybitExchange) handleKlineMessage(wsMsg BybitWSMessage, handler DataHan
 			Volume:    volume,
 			Timestamp: time.Unix(k.Start/1000, 0),
 			Interval:  b.extractIntervalFromTopic(wsMsg.Topic),
+			IsClosed:  k.Confirm,
 		}
 
 		handler.OnKline(kline)
@@ -406,6 +510,11 @@ func (b *BybitExchange) mapInterval(interval string) string {
 	return intervalMap[interval]
 }
 
+// SupportsInterval reports whether Bybit streams klines of interval
+func (b *BybitExchange) SupportsInterval(interval string) bool {
+	return b.mapInterval(interval) != ""
+}
+
 // reverseMapInterval maps Bybit interval back to common format
 func (b *BybitExchange) reverseMapInterval(bybitInterval string) string {
 	reverseMap := map[string]string{
@@ -530,7 +639,9 @@ func (b *BybitExchange) PlaceOrder(ctx context.Context, order *Order) (*Order, e
 		Msg("Sending order parameters to Bybit API")
 
 	// Use the V5 Order service to place the order
+	start := time.Now()
 	response, err := b.client.V5().Order().CreateOrder(param)
+	b.observeREST("create_order", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).
 			Str("category", string(param.Category)).
@@ -566,7 +677,9 @@ func (b *BybitExchange) CancelOrder(ctx context.Context, symbol, orderID string)
 		OrderID:  &orderID,
 	}
 
+	start := time.Now()
 	_, err := b.client.V5().Order().CancelOrder(param)
+	b.observeREST("cancel_order", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).Msg("Failed to cancel order")
 		return fmt.Errorf("failed to cancel order: %w", err)
@@ -584,16 +697,20 @@ func (b *BybitExchange) GetOrder(ctx context.Context, symbol, orderID string) (*
 		OrderID:  &orderID,
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Order().GetOpenOrders(param)
-	if err != nil {
-		// Try history orders if not found in open orders
+	b.observeREST("get_open_orders", start, err)
+	if err != nil || len(resp.Result.List) == 0 {
+		// Filled and cancelled orders are only listed in the order history
 		historyParam := bybit.V5GetHistoryOrdersParam{
 			Category: bybit.CategoryV5Spot,
 			Symbol:   (*bybit.SymbolV5)(&symbol),
 			OrderID:  &orderID,
 		}
 
+		start = time.Now()
 		resp, err = b.client.V5().Order().GetHistoryOrders(historyParam)
+		b.observeREST("get_history_orders", start, err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get order: %w", err)
 		}
@@ -603,8 +720,44 @@ func (b *BybitExchange) GetOrder(ctx context.Context, symbol, orderID string) (*
 		return nil, fmt.Errorf("order not found")
 	}
 
-	order := resp.Result.List[0]
-	return b.convertV5OrderToOrder(&order), nil
+	order := b.convertV5OrderToOrder(&resp.Result.List[0])
+	if order.Fee > 0 {
+		b.addExecutionFee(order)
+	}
+	return order, nil
+}
+
+// addExecutionFee sums the fees of the order's executions, which unlike the order report their currency
+func (b *BybitExchange) addExecutionFee(order *Order) {
+	symbol := bybit.SymbolV5(order.Symbol)
+	param := bybit.V5GetExecutionParam{
+		Category: bybit.CategoryV5Spot,
+		Symbol:   &symbol,
+		OrderID:  &order.ID,
+	}
+
+	start := time.Now()
+	resp, err := b.client.V5().Execution().GetExecutionList(param)
+	b.observeREST("get_execution_list", start, err)
+	if err != nil {
+		b.logger.Warn().Err(err).Str("order_id", order.ID).Msg("Failed to get executions, fee currency unknown")
+		return
+	}
+
+	fee, currency := 0.0, ""
+	for _, execution := range resp.Result.List {
+		if currency != "" && string(execution.FeeCurrency) != currency {
+			b.logger.Warn().Str("order_id", order.ID).Msg("Executions charged fees in several currencies, fee currency unknown")
+			return
+		}
+		amount, _ := strconv.ParseFloat(execution.ExecFee, 64)
+		fee += amount
+		currency = string(execution.FeeCurrency)
+	}
+	if currency != "" {
+		order.Fee = fee
+		order.FeeCurrency = currency
+	}
 }
 
 // GetOpenOrders retrieves all open orders for a symbol (empty symbol gets all orders)
@@ -618,7 +771,9 @@ func (b *BybitExchange) GetOpenOrders(ctx context.Context, symbol string) ([]*Or
 		param.Symbol = (*bybit.SymbolV5)(&symbol)
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Order().GetOpenOrders(param)
+	b.observeREST("get_open_orders", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get open orders: %w", err)
 	}
@@ -636,6 +791,9 @@ func (b *BybitExchange) convertV5OrderToOrder(v5Order *bybit.V5GetOrder) *Order
 	quantity, _ := strconv.ParseFloat(v5Order.Qty, 64)
 	price, _ := strconv.ParseFloat(v5Order.Price, 64)
 	createdTime, _ := strconv.ParseInt(v5Order.CreatedTime, 10, 64)
+	fee, _ := strconv.ParseFloat(v5Order.CumExecFee, 64)
+	executedQuantity, _ := strconv.ParseFloat(v5Order.CumExecQty, 64)
+	averagePrice, _ := strconv.ParseFloat(v5Order.AvgPrice, 64)
 
 	side := "buy"
 	if v5Order.Side == bybit.SideSell {
@@ -647,26 +805,43 @@ func (b *BybitExchange) convertV5OrderToOrder(v5Order *bybit.V5GetOrder) *Order
 		orderType = "market"
 	}
 
-	status := strings.ToLower(string(v5Order.OrderStatus))
-
 	return &Order{
-		ID:       v5Order.OrderID,
-		Symbol:   string(v5Order.Symbol),
-		Side:     side,
-		Type:     orderType,
-		Quantity: quantity,
-		Price:    price,
-		Status:   status,
-		Time:     time.Unix(createdTime/1000, 0),
+		ID:               v5Order.OrderID,
+		Symbol:           string(v5Order.Symbol),
+		Side:             side,
+		Type:             orderType,
+		Quantity:         quantity,
+		Price:            price,
+		Status:           convertV5OrderStatus(v5Order.OrderStatus),
+		Time:             time.Unix(createdTime/1000, 0),
+		ExecutedQuantity: executedQuantity,
+		AveragePrice:     averagePrice,
+		Fee:              fee, // cumExecFee has no currency, see addExecutionFee
 	}
 }
 
+// convertV5OrderStatus maps a Bybit order status onto the statuses of the order manager,
+// lower casing the ones it has no equivalent for
+func convertV5OrderStatus(status bybit.OrderStatus) string {
+	switch status {
+	case bybit.OrderStatusNew, bybit.OrderStatusCreated, bybit.OrderStatusUntriggered:
+		return "open"
+	case bybit.OrderStatusPartiallyFilled:
+		return "partially_filled"
+	case bybit.OrderStatusCancelled, bybit.OrderStatusDeactivated, "PartiallyFilledCanceled":
+		return "cancelled"
+	}
+	return strings.ToLower(string(status))
+}
+
 // GetBalances retrieves account balances
 func (b *BybitExchange) GetBalances(ctx context.Context) ([]*Balance, error) {
 	var allBalances []*Balance
 
 	// Try UNIFIED account type first (recommended for V5 API)
+	start := time.Now()
 	resp, err := b.client.V5().Account().GetWalletBalance(bybit.AccountTypeV5("UNIFIED"), nil)
+	b.observeREST("get_wallet_balance", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).Msg("Failed to get UNIFIED wallet balance")
 	} else {
@@ -719,6 +894,100 @@ func (b *BybitExchange) GetPositions(ctx context.Context) ([]*Position, error) {
 	return []*Position{}, nil
 }
 
+// transferLookback is the longest period Bybit returns deposit and withdrawal records for in one query
+const transferLookback = 30 * 24 * time.Hour
+
+// GetTransfers retrieves completed deposits and withdrawals since the given time, at most 30 days back
+func (b *BybitExchange) GetTransfers(ctx context.Context, since time.Time) ([]*Transfer, error) {
+	if earliest := time.Now().Add(-transferLookback); since.Before(earliest) {
+		since = earliest
+	}
+	startTime := since.UnixMilli()
+	limit := 50
+
+	var transfers []*Transfer
+
+	var cursor *string
+	for {
+		start := time.Now()
+		resp, err := b.client.V5().Asset().GetDepositRecords(bybit.V5GetDepositRecordsParam{
+			StartTime: &startTime,
+			Limit:     &limit,
+			Cursor:    cursor,
+		})
+		b.observeREST("get_deposit_records", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deposit records: %w", err)
+		}
+
+		for _, row := range resp.Result.Rows {
+			if row.Status != bybit.DepositStatusV5Success {
+				continue
+			}
+			amount, _ := strconv.ParseFloat(row.Amount, 64)
+			fee, _ := strconv.ParseFloat(row.DepositFee, 64)
+			successAt, _ := strconv.ParseInt(row.SuccessAt, 10, 64)
+
+			transfers = append(transfers, &Transfer{
+				ID:        "deposit:" + row.TxID + ":" + row.TxIndex,
+				Type:      TransferDeposit,
+				Asset:     string(row.Coin),
+				Amount:    amount,
+				Fee:       fee,
+				Timestamp: time.UnixMilli(successAt),
+			})
+		}
+
+		if resp.Result.NextPageCursor == "" || len(resp.Result.Rows) < limit {
+			break
+		}
+		next := resp.Result.NextPageCursor
+		cursor = &next
+	}
+
+	withdrawType := bybit.WithdrawTypeAll
+	cursor = nil
+	for {
+		start := time.Now()
+		resp, err := b.client.V5().Asset().GetWithdrawalRecords(bybit.V5GetWithdrawalRecordsParam{
+			WithdrawType: &withdrawType,
+			StartTime:    &startTime,
+			Limit:        &limit,
+			Cursor:       cursor,
+		})
+		b.observeREST("get_withdrawal_records", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get withdrawal records: %w", err)
+		}
+
+		for _, row := range resp.Result.Rows {
+			if row.Status != bybit.WithdrawStatusV5Success {
+				continue
+			}
+			amount, _ := strconv.ParseFloat(row.Amount, 64)
+			fee, _ := strconv.ParseFloat(row.WithdrawFee, 64)
+			updatedTime, _ := strconv.ParseInt(row.UpdatedTime, 10, 64)
+
+			transfers = append(transfers, &Transfer{
+				ID:        "withdrawal:" + row.WithdrawID,
+				Type:      TransferWithdrawal,
+				Asset:     string(row.Coin),
+				Amount:    amount,
+				Fee:       fee,
+				Timestamp: time.UnixMilli(updatedTime),
+			})
+		}
+
+		if resp.Result.NextPageCursor == "" || len(resp.Result.Rows) < limit {
+			break
+		}
+		next := resp.Result.NextPageCursor
+		cursor = &next
+	}
+
+	return transfers, nil
+}
+
 // getUSDIndexPrice fetches the USD index price for a symbol from Bybit testnet
 func (b *BybitExchange) getUSDIndexPrice(symbol string) (float64, error) {
 	if !b.testnet {
@@ -727,7 +996,9 @@ func (b *BybitExchange) getUSDIndexPrice(symbol string) (float64, error) {
 
 	url := fmt.Sprintf("https://api-testnet.bybit.com/v5/market/tickers?category=spot&symbol=%s", symbol)
 
+	start := time.Now()
 	resp, err := http.Get(url)
+	b.observeREST("get_usd_index_price", start, err)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch ticker: %w", err)
 	}
@@ -765,6 +1036,18 @@ func (b *BybitExchange) getUSDIndexPrice(symbol string) (float64, error) {
 
 // GetKlines retrieves historical kline data
 func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval string, limit int) ([]*Kline, error) {
+	return b.getKlines(ctx, symbol, interval, limit, nil, nil)
+}
+
+// GetKlinesRange retrieves up to limit klines that opened between start and end. Unlike GetKlines
+// it never applies the testnet price adjustment, which is based on the current index price.
+func (b *BybitExchange) GetKlinesRange(ctx context.Context, symbol string, interval string, start, end time.Time, limit int) ([]*Kline, error) {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	return b.getKlines(ctx, symbol, interval, limit, &startMs, &endMs)
+}
+
+// getKlines retrieves klines, the most recent ones unless a start and end in milliseconds are given
+func (b *BybitExchange) getKlines(ctx context.Context, symbol string, interval string, limit int, startMs, endMs *int64) ([]*Kline, error) {
 	bybitInterval := b.mapIntervalToV5(interval)
 	if bybitInterval == "" {
 		return nil, fmt.Errorf("unsupported interval: %s", interval)
@@ -775,10 +1058,14 @@ func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval s
 		Category: bybit.CategoryV5Spot,
 		Symbol:   bybit.SymbolV5(symbol),
 		Interval: bybit.Interval(bybitInterval),
+		Start:    startMs,
+		End:      endMs,
 		Limit:    &limit,
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Market().GetKline(param)
+	b.observeREST("get_kline", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get klines: %w", err)
 	}
@@ -786,7 +1073,7 @@ func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval s
 	// Get USD index price for testnet accuracy
 	var usdIndexPrice float64
 	var spotPrice float64
-	if b.testnet && symbol == "BTCUSDT" {
+	if b.testnet && symbol == "BTCUSDT" && startMs == nil {
 		usdIndexPrice, err = b.getUSDIndexPrice(symbol)
 		if err != nil {
 			b.logger.Warn().Err(err).Msg("Failed to get USD index price, using spot prices")
@@ -808,6 +1095,7 @@ func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval s
 		Float64("usd_index_price", usdIndexPrice).
 		Msg("Received spot klines from Bybit API")
 
+	now := time.Now()
 	var klines []*Kline
 	for _, item := range resp.Result.List {
 		open, _ := strconv.ParseFloat(item.Open, 64)
@@ -835,6 +1123,8 @@ func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval s
 				Msg("Applied USD index price ratio to kline data")
 		}
 
+		// The newest kline is usually the bar still in progress
+		openTime := time.Unix(startTime/1000, 0)
 		klines = append(klines, &Kline{
 			Symbol:    symbol,
 			Open:      open,
@@ -842,8 +1132,9 @@ func (b *BybitExchange) GetKlines(ctx context.Context, symbol string, interval s
 			Low:       low,
 			Close:     closePrice,
 			Volume:    volume,
-			Timestamp: time.Unix(startTime/1000, 0),
+			Timestamp: openTime,
 			Interval:  interval,
+			IsClosed:  !klineCloseTime(openTime, bybitInterval).After(now),
 		})
 	}
 
@@ -871,6 +1162,21 @@ func (b *BybitExchange) mapIntervalToV5(interval string) string {
 	return intervalMap[interval]
 }
 
+// klineCloseTime returns when a kline of the given V5 interval that opened at start closes
+func klineCloseTime(start time.Time, v5Interval string) time.Time {
+	start = start.UTC()
+	switch v5Interval {
+	case "D":
+		return start.AddDate(0, 0, 1)
+	case "W":
+		return start.AddDate(0, 0, 7)
+	case "M":
+		return start.AddDate(0, 1, 0)
+	}
+	minutes, _ := strconv.Atoi(v5Interval)
+	return start.Add(time.Duration(minutes) * time.Minute)
+}
+
 // GetOrderBook retrieves order book data
 func (b *BybitExchange) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
 	b.logger.Info().
@@ -884,7 +1190,9 @@ func (b *BybitExchange) GetOrderBook(ctx context.Context, symbol string, limit i
 		Limit:    &limit,
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Market().GetOrderbook(param)
+	b.observeREST("get_orderbook", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).Msg("Failed to get order book")
 		return nil, fmt.Errorf("failed to get order book: %w", err)
@@ -925,7 +1233,9 @@ func (b *BybitExchange) GetTicker(ctx context.Context, symbol string) (*Ticker,
 		Symbol:   (*bybit.SymbolV5)(&symbol),
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Market().GetTickers(param)
+	b.observeREST("get_tickers", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).Msg("Failed to get ticker")
 		return nil, fmt.Errorf("failed to get ticker: %w", err)
@@ -961,7 +1271,9 @@ func (b *BybitExchange) GetExchangeInfo(ctx context.Context) (*ExchangeInfo, err
 		Category: bybit.CategoryV5Spot,
 	}
 
+	start := time.Now()
 	resp, err := b.client.V5().Market().GetInstrumentsInfo(param)
+	b.observeREST("get_instruments_info", start, err)
 	if err != nil {
 		b.logger.Error().Err(err).Msg("Failed to get exchange info")
 		return nil, fmt.Errorf("failed to get exchange info: %w", err)
@@ -997,6 +1309,10 @@ func (b *BybitExchange) GetExchangeInfo(ctx context.Context) (*ExchangeInfo, err
 			MaxPrice:          maxOrderAmt, // Use maximum order amount as max price proxy
 			PricePrecision:    pricePrecision,
 			QuantityPrecision: quantityPrecision,
+			TickSize:          tickSize,
+			StepSize:          basePrecision,
+			MinNotional:       minOrderAmt,
+			MaxNotional:       maxOrderAmt,
 		})
 	}
 
@@ -0,0 +1,102 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hirokisan/bybit/v2"
+	"github.com/rs/zerolog"
+)
+
+// klineRecorder is a DataHandler that keeps the klines it receives
+type klineRecorder struct {
+	klines []*Kline
+}
+
+func (r *klineRecorder) OnKline(kline *Kline)             { r.klines = append(r.klines, kline) }
+func (r *klineRecorder) OnOrderBook(orderBook *OrderBook) {}
+func (r *klineRecorder) OnTicker(ticker *Ticker)          {}
+
+func TestBybitKlineConfirm(t *testing.T) {
+	b := NewBybit("", "", true, zerolog.Nop())
+	recorder := &klineRecorder{}
+
+	// Two intra-bar updates and the closing update of the same bar
+	for _, data := range []string{
+		`[{"start":1709294400000,"end":1709294459999,"open":"100","high":"101","low":"99","close":"100.5","volume":"1","confirm":false}]`,
+		`[{"start":1709294400000,"end":1709294459999,"open":"100","high":"102","low":"99","close":"101.5","volume":"2","confirm":false}]`,
+		`[{"start":1709294400000,"end":1709294459999,"open":"100","high":"102","low":"98","close":"101","volume":"3","confirm":true}]`,
+	} {
+		msg := BybitWSMessage{Topic: "kline.1.BTCUSDT", Data: json.RawMessage(data)}
+		if err := b.handleKlineMessage(msg, recorder); err != nil {
+			t.Fatalf("failed to handle kline message: %v", err)
+		}
+	}
+
+	if len(recorder.klines) != 3 {
+		t.Fatalf("expected 3 klines, got %d", len(recorder.klines))
+	}
+	for i, kline := range recorder.klines {
+		if kline.IsClosed != (i == 2) {
+			t.Errorf("kline %d: expected IsClosed=%v", i, i == 2)
+		}
+		if kline.Symbol != "BTCUSDT" || kline.Interval != "1m" || !kline.Timestamp.Equal(time.UnixMilli(1709294400000)) {
+			t.Errorf("kline %d: unexpected %+v", i, kline)
+		}
+	}
+	if closed := recorder.klines[2]; closed.Close != 101 || closed.Low != 98 || closed.Volume != 3 {
+		t.Errorf("unexpected closed kline %+v", closed)
+	}
+}
+
+func TestKlineCloseTime(t *testing.T) {
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval string
+		expected time.Time
+	}{
+		{"1", start.Add(time.Minute)},
+		{"240", start.Add(4 * time.Hour)},
+		{"D", time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{"W", time.Date(2024, 2, 8, 0, 0, 0, 0, time.UTC)},
+		{"M", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if closes := klineCloseTime(start, tt.interval); !closes.Equal(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.interval, tt.expected, closes)
+		}
+	}
+}
+
+func TestBybitConvertOrder(t *testing.T) {
+	b := NewBybit("", "", true, zerolog.Nop())
+
+	order := b.convertV5OrderToOrder(&bybit.V5GetOrder{
+		OrderID:     "1",
+		Symbol:      bybit.SymbolV5BTCUSDT,
+		Side:        bybit.SideBuy,
+		OrderType:   bybit.OrderTypeMarket,
+		OrderStatus: bybit.OrderStatusFilled,
+		Qty:         "0.1",
+		Price:       "0",
+		CumExecQty:  "0.1",
+		AvgPrice:    "50010.5",
+		CumExecFee:  "0.0001",
+	})
+	if order.Status != "filled" || order.ExecutedQuantity != 0.1 || order.AveragePrice != 50010.5 || order.Fee != 0.0001 {
+		t.Errorf("expected the execution of the filled order, got %+v", order)
+	}
+
+	tests := map[bybit.OrderStatus]string{
+		bybit.OrderStatusNew:             "open",
+		bybit.OrderStatusPartiallyFilled: "partially_filled",
+		"PartiallyFilledCanceled":        "cancelled",
+		bybit.OrderStatusRejected:        "rejected",
+	}
+	for status, expected := range tests {
+		if got := convertV5OrderStatus(status); got != expected {
+			t.Errorf("status %s: expected %s, got %s", status, expected, got)
+		}
+	}
+}
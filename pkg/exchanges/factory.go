@@ -2,6 +2,7 @@ package exchanges
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -39,6 +40,16 @@ func (f *Factory) GetSupportedExchanges() []string {
 	return []string{"bybit", "bitvavo"}
 }
 
+// SupportsInterval reports whether an exchange streams klines of interval, without connecting to it.
+// Exchanges that do not implement IntervalProvider are assumed to stream every time interval.
+func (f *Factory) SupportsInterval(exchangeName, interval string) bool {
+	switch exchangeName {
+	case "bybit":
+		return (&BybitExchange{}).SupportsInterval(interval)
+	}
+	return !strings.Contains(interval, ":")
+}
+
 func (f *Factory) createBybitExchange(config map[string]interface{}) (Exchange, error) {
 	apiKey, ok := config["api_key"].(string)
 	if !ok || apiKey == "" {
@@ -15,6 +15,7 @@ type Kline struct {
 	Close     float64
 	Volume    float64
 	Interval  string
+	IsClosed  bool // The bar is final, false for in-progress updates of the current bar
 }
 
 // OrderBookEntry represents a single order book entry
@@ -41,6 +42,14 @@ type Order struct {
 	Price    float64
 	Status   string
 	Time     time.Time
+
+	// Quantity executed so far and its average price, zero until the order fills
+	ExecutedQuantity float64
+	AveragePrice     float64
+
+	// Fee charged for the executed quantity, in FeeCurrency. An empty FeeCurrency leaves it unknown.
+	Fee         float64
+	FeeCurrency string
 }
 
 // Position represents a trading position
@@ -62,6 +71,22 @@ type Balance struct {
 	Total     float64
 }
 
+// Transfer types
+const (
+	TransferDeposit    = "deposit"
+	TransferWithdrawal = "withdrawal"
+)
+
+// Transfer is a completed deposit or withdrawal of an asset
+type Transfer struct {
+	ID        string
+	Type      string // TransferDeposit or TransferWithdrawal
+	Asset     string
+	Amount    float64 // always positive
+	Fee       float64
+	Timestamp time.Time
+}
+
 // DataHandler is called when data is received from the exchange
 type DataHandler interface {
 	OnKline(kline *Kline)
@@ -69,6 +94,25 @@ type DataHandler interface {
 	OnTicker(ticker *Ticker)
 }
 
+// KlineRangeProvider is optionally implemented by an Exchange that can fetch klines for a time range,
+// which lets the kline store page through history. Klines may be returned in any order.
+type KlineRangeProvider interface {
+	GetKlinesRange(ctx context.Context, symbol string, interval string, start, end time.Time, limit int) ([]*Kline, error)
+}
+
+// IntervalProvider is optionally implemented by an Exchange to report which kline intervals it
+// streams natively. Other intervals are built locally from 1m klines.
+type IntervalProvider interface {
+	SupportsInterval(interval string) bool
+}
+
+// ConnectionHandler is optionally implemented by a DataHandler that wants to know when
+// the market data connection drops and when it is restored
+type ConnectionHandler interface {
+	OnDisconnect(exchange string, err error)
+	OnReconnect(exchange string)
+}
+
 // Ticker represents price ticker information
 type Ticker struct {
 	Symbol    string
@@ -91,6 +135,10 @@ type Symbol struct {
 	MaxPrice          float64
 	PricePrecision    int
 	QuantityPrecision int
+	TickSize          float64 // Minimum price increment
+	StepSize          float64 // Minimum quantity increment (lot step)
+	MinNotional       float64 // Minimum order value in quote asset
+	MaxNotional       float64 // Maximum order value in quote asset
 }
 
 // ExchangeInfo represents exchange information
@@ -129,3 +177,9 @@ type Exchange interface {
 	GetTicker(ctx context.Context, symbol string) (*Ticker, error)
 	GetExchangeInfo(ctx context.Context) (*ExchangeInfo, error)
 }
+
+// TransferProvider is implemented by exchanges that report deposits and withdrawals.
+// Exchanges limit how far back they report; a zero since asks for as much as is available.
+type TransferProvider interface {
+	GetTransfers(ctx context.Context, since time.Time) ([]*Transfer, error)
+}
@@ -0,0 +1,26 @@
+package exchanges
+
+import (
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/metrics"
+)
+
+var (
+	wsMessages = metrics.NewCounter("mercantile_websocket_messages_total",
+		"WebSocket messages received per topic.", "exchange", "topic")
+	wsReconnects = metrics.NewCounter("mercantile_websocket_reconnects_total",
+		"Successful WebSocket reconnects after the connection dropped.", "exchange")
+	restDuration = metrics.NewHistogram("mercantile_rest_request_duration_seconds",
+		"REST request latency per endpoint.", nil, "exchange", "endpoint")
+	restErrors = metrics.NewCounter("mercantile_rest_errors_total",
+		"Failed REST requests per endpoint.", "exchange", "endpoint")
+)
+
+// observeREST records the latency of a REST call started at start and counts it as an error when err is set
+func (b *BybitExchange) observeREST(endpoint string, start time.Time, err error) {
+	restDuration.With(b.name, endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		restErrors.With(b.name, endpoint).Inc()
+	}
+}
@@ -0,0 +1,375 @@
+// Package metrics provides counters, gauges and histograms with labels and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to request and callback latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry the New* functions register with and Handler serves
+var DefaultRegistry = NewRegistry()
+
+// Metric types as written in the exposition format
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry holds metric families by name
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// register adds a family, panicking on a duplicate name like flag definitions do
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.families[f.name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.families[f.name] = f
+}
+
+// Handler serves every metric in the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		r.write(buf)
+		buf.Flush()
+	})
+}
+
+// write outputs all families sorted by name
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// Handler serves the metrics of the default registry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// family is one metric name with a series per combination of label values
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64 // Histograms only
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+func newFamily(r *Registry, name, help, typ string, labels []string, buckets []float64) *family {
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.register(f)
+	return f
+}
+
+// with returns the series for the label values, creating it on first use
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.RLock()
+	s, exists := f.series[key]
+	f.mu.RUnlock()
+	if exists {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, exists := f.series[key]; exists {
+		return s
+	}
+	s = &series{labels: formatLabels(f.labels, values)}
+	if f.typ == typeHistogram {
+		s.counts = make([]uint64, len(f.buckets))
+	}
+	f.series[key] = s
+	return s
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.RLock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.RUnlock()
+
+	if len(all) == 0 {
+		return
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].labels < all[j].labels
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	for _, s := range all {
+		if f.typ != typeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, braces(s.labels), formatValue(s.load()))
+			continue
+		}
+
+		counts, sum, count := s.snapshot()
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, braces(joinLabels(s.labels, `le="`+formatValue(upper)+`"`)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, braces(joinLabels(s.labels, `le="+Inf"`)), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, braces(s.labels), formatValue(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, braces(s.labels), count)
+	}
+}
+
+// series holds the value of one label combination. Counters and gauges keep a float64 in bits,
+// histograms keep per-bucket counts under the mutex.
+type series struct {
+	labels string
+	bits   uint64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (s *series) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+func (s *series) store(v float64) {
+	atomic.StoreUint64(&s.bits, math.Float64bits(v))
+}
+
+func (s *series) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&s.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&s.bits, old, updated) {
+			return
+		}
+	}
+}
+
+func (s *series) observe(buckets []float64, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, upper := range buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (s *series) snapshot() ([]uint64, float64, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.counts...), s.sum, s.count
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct{ family *family }
+
+// Counter only goes up
+type Counter struct{ series *series }
+
+// NewCounter registers a counter with the default registry
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a counter with this registry
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: newFamily(r, name, help, typeCounter, labels, nil)}
+}
+
+// With returns the counter for the label values, in the order the labels were declared
+func (c *CounterVec) With(values ...string) *Counter {
+	return &Counter{series: c.family.with(values)}
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.series.add(1)
+}
+
+// Add adds a non-negative delta, negative deltas are ignored
+func (c *Counter) Add(delta float64) {
+	if delta > 0 {
+		c.series.add(delta)
+	}
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct{ family *family }
+
+// Gauge goes up and down
+type Gauge struct{ series *series }
+
+// NewGauge registers a gauge with the default registry
+func NewGauge(name, help string, labels ...string) *GaugeVec {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewGauge registers a gauge with this registry
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{family: newFamily(r, name, help, typeGauge, labels, nil)}
+}
+
+// With returns the gauge for the label values, in the order the labels were declared
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return &Gauge{series: g.family.with(values)}
+}
+
+// Set replaces the value
+func (g *Gauge) Set(v float64) {
+	g.series.store(v)
+}
+
+// Add adds delta, which may be negative
+func (g *Gauge) Add(delta float64) {
+	g.series.add(delta)
+}
+
+// Inc adds one
+func (g *Gauge) Inc() {
+	g.series.add(1)
+}
+
+// Dec subtracts one
+func (g *Gauge) Dec() {
+	g.series.add(-1)
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct{ family *family }
+
+// Histogram counts observations per bucket
+type Histogram struct {
+	series  *series
+	buckets []float64
+}
+
+// NewHistogram registers a histogram with the default registry, using DefaultBuckets when buckets is nil
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers a histogram with this registry, using DefaultBuckets when buckets is nil
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{family: newFamily(r, name, help, typeHistogram, labels, buckets)}
+}
+
+// With returns the histogram for the label values, in the order the labels were declared
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return &Histogram{series: h.family.with(values), buckets: h.family.buckets}
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64) {
+	h.series.observe(h.buckets, v)
+}
+
+// formatLabels renders label pairs without braces, e.g. exchange="bybit",topic="kline"
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
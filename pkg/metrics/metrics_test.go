@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+	orders := registry.NewCounter("test_orders_total", "Orders placed.", "exchange", "status")
+	value := registry.NewGauge("test_portfolio_value", "Portfolio value.", "exchange")
+	latency := registry.NewHistogram("test_latency_seconds", "Request latency.", []float64{0.1, 1}, "endpoint")
+	registry.NewCounter("test_unused_total", "Never incremented.")
+
+	orders.With("bybit", "filled").Inc()
+	orders.With("bybit", "filled").Add(2)
+	orders.With("bybit", "filled").Add(-5)
+	orders.With("bitvavo", "rejected").Inc()
+	value.With("bybit").Set(1000.5)
+	value.With("bybit").Add(-0.5)
+	latency.With("get_klines").Observe(0.05)
+	latency.With("get_klines").Observe(0.5)
+	latency.With("get_klines").Observe(3)
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	expected := `# HELP test_latency_seconds Request latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{endpoint="get_klines",le="0.1"} 1
+test_latency_seconds_bucket{endpoint="get_klines",le="1"} 2
+test_latency_seconds_bucket{endpoint="get_klines",le="+Inf"} 3
+test_latency_seconds_sum{endpoint="get_klines"} 3.55
+test_latency_seconds_count{endpoint="get_klines"} 3
+# HELP test_orders_total Orders placed.
+# TYPE test_orders_total counter
+test_orders_total{exchange="bitvavo",status="rejected"} 1
+test_orders_total{exchange="bybit",status="filled"} 3
+# HELP test_portfolio_value Portfolio value.
+# TYPE test_portfolio_value gauge
+test_portfolio_value{exchange="bybit"} 1000
+`
+	if w.Body.String() != expected {
+		t.Errorf("unexpected exposition:\n%s\nexpected:\n%s", w.Body.String(), expected)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGauge("test_escaped", "Line one\nline two.", "reason").With(`say "hi"\now`).Set(1)
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, want := range []string{
+		`# HELP test_escaped Line one\nline two.`,
+		`test_escaped{reason="say \"hi\"\\now"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, w.Body.String())
+		}
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_duplicate_total", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate metric name")
+		}
+	}()
+	registry.NewGauge("test_duplicate_total", "Second.")
+}
@@ -0,0 +1,431 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+const (
+	// Version of the vault file format
+	Version = 1
+
+	// Iterations of PBKDF2-SHA256 deriving the encryption key from the passphrase
+	Iterations = 600_000
+
+	kdf     = "pbkdf2-sha256"
+	saltLen = 16
+	keyLen  = 32 // AES-256
+)
+
+var (
+	ErrLocked          = errors.New("vault is locked, set VAULT_PASSPHRASE or vault.key_file")
+	ErrWrongPassphrase = errors.New("wrong vault passphrase or corrupted vault")
+	ErrNotFound        = errors.New("account not found")
+	ErrExists          = errors.New("account already exists")
+)
+
+// Credentials are the API key and secret of one exchange account. They format and marshal with the
+// secret left out, so they can never end up in logs or API responses by accident.
+type Credentials struct {
+	APIKey  string
+	Secret  string
+	Testnet bool
+}
+
+// Valid reports whether both the API key and secret are set
+func (c Credentials) Valid() bool {
+	return c.APIKey != "" && c.Secret != ""
+}
+
+// ExchangeConfig returns the credentials in the form exchanges.Factory expects
+func (c Credentials) ExchangeConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"api_key": c.APIKey,
+		"secret":  c.Secret,
+		"testnet": c.Testnet,
+	}
+}
+
+func (c Credentials) String() string {
+	return fmt.Sprintf("api_key=%s secret=*** testnet=%t", mask(c.APIKey), c.Testnet)
+}
+
+func (c Credentials) GoString() string {
+	return c.String()
+}
+
+func (c Credentials) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"api_key": mask(c.APIKey),
+		"testnet": c.Testnet,
+	})
+}
+
+// mask keeps the last 4 characters of a long key so accounts can be told apart
+func mask(key string) string {
+	if len(key) <= 8 {
+		return "***"
+	}
+	return "***" + key[len(key)-4:]
+}
+
+// Account describes a stored account without its credentials
+type Account struct {
+	Exchange  string    `json:"exchange"`
+	Name      string    `json:"name"`
+	Testnet   bool      `json:"testnet"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // Last rotation
+}
+
+// record is an account as stored in the encrypted payload
+type record struct {
+	Exchange  string    `json:"exchange"`
+	Name      string    `json:"name"`
+	APIKey    string    `json:"api_key"`
+	Secret    string    `json:"secret"`
+	Testnet   bool      `json:"testnet"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// file is the vault on disk, only the KDF parameters are stored in the clear
+type file struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"` // AES-256-GCM sealed JSON list of records
+}
+
+// Vault holds the credentials of named exchange accounts in a file encrypted with AES-256-GCM under a
+// key derived from a passphrase. Changes are kept in memory until Save.
+type Vault struct {
+	path       string
+	key        []byte
+	salt       []byte
+	iterations int
+	accounts   map[string]record // exchange/name -> record
+}
+
+// Create returns a new empty vault for path, written on the first Save. It fails when path exists.
+func Create(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("vault passphrase must not be empty")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("vault %s already exists", path)
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt, Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Vault{
+		path:       path,
+		key:        key,
+		salt:       salt,
+		iterations: Iterations,
+		accounts:   make(map[string]record),
+	}, nil
+}
+
+// Open decrypts the vault at path, returning ErrWrongPassphrase when it cannot be decrypted
+func Open(path, passphrase string) (*Vault, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored file
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid vault %s: %w", path, err)
+	}
+	if stored.Version != Version || stored.KDF != kdf || stored.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported vault %s: version %d, kdf %s", path, stored.Version, stored.KDF)
+	}
+
+	key, err := deriveKey(passphrase, stored.Salt, stored.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vault{
+		path:       path,
+		key:        key,
+		salt:       stored.Salt,
+		iterations: stored.Iterations,
+		accounts:   make(map[string]record),
+	}
+
+	gcm, err := v.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(stored.Nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := gcm.Open(nil, stored.Nonce, stored.Data, v.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	var records []record
+	if err := json.Unmarshal(plaintext, &records); err != nil {
+		return nil, fmt.Errorf("invalid vault %s: %w", path, err)
+	}
+	for _, r := range records {
+		v.accounts[accountKey(r.Exchange, r.Name)] = r
+	}
+	return v, nil
+}
+
+// Unlock opens the configured vault with the passphrase from its key file or VAULT_PASSPHRASE
+func Unlock(cfg config.VaultConfig) (*Vault, error) {
+	passphrase, err := Passphrase(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return Open(cfg.Path, passphrase)
+}
+
+// Passphrase reads the vault passphrase from keyFile, or from VAULT_PASSPHRASE when keyFile is empty
+func Passphrase(keyFile string) (string, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vault key file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv("VAULT_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", ErrLocked
+}
+
+// UnlockOnce opens the configured vault for the lifetime of the process and clears VAULT_PASSPHRASE,
+// so the passphrase does not stay in the environment. When no exchange names an account, a vault that
+// cannot be opened is not an error and nil is returned.
+func UnlockOnce(cfg *config.Config) (*Vault, error) {
+	defer os.Unsetenv("VAULT_PASSPHRASE")
+
+	v, err := Unlock(cfg.Vault)
+	if err != nil {
+		for _, exchange := range cfg.Exchanges {
+			if exchange.Account != "" {
+				return nil, fmt.Errorf("failed to open vault %s: %w", cfg.Vault.Path, err)
+			}
+		}
+		return nil, nil
+	}
+	return v, nil
+}
+
+// ForExchange returns the credentials an exchange account is configured with: those of the account
+// its configuration names in the unlocked vault v, or the environment variables of its exchange type
+// when it names none
+func ForExchange(cfg *config.Config, v *Vault, exchangeName string) (Credentials, error) {
+	exchangeType := cfg.ExchangeType(exchangeName)
+	account := cfg.Exchanges[exchangeName].Account
+	if account == "" {
+		return envCredentials(cfg, exchangeType), nil
+	}
+
+	if v == nil {
+		return Credentials{}, fmt.Errorf("%s account %s: %w", exchangeType, account, ErrLocked)
+	}
+	return v.Get(exchangeType, account)
+}
+
+func envCredentials(cfg *config.Config, exchangeType string) Credentials {
+	switch exchangeType {
+	case "bybit":
+		return Credentials{APIKey: cfg.BybitAPIKey, Secret: cfg.BybitSecret, Testnet: cfg.BybitTestnet}
+	case "bitvavo":
+		return Credentials{APIKey: cfg.BitvavoAPIKey, Secret: cfg.BitvavoSecret, Testnet: cfg.BitvavoTestnet}
+	}
+	return Credentials{}
+}
+
+// Get returns the credentials of an account
+func (v *Vault) Get(exchange, name string) (Credentials, error) {
+	r, exists := v.accounts[accountKey(exchange, name)]
+	if !exists {
+		return Credentials{}, fmt.Errorf("%s account %s: %w", exchange, name, ErrNotFound)
+	}
+	return Credentials{APIKey: r.APIKey, Secret: r.Secret, Testnet: r.Testnet}, nil
+}
+
+// Accounts lists the stored accounts by exchange and name
+func (v *Vault) Accounts() []Account {
+	accounts := make([]Account, 0, len(v.accounts))
+	for _, r := range v.accounts {
+		accounts = append(accounts, Account{
+			Exchange:  r.Exchange,
+			Name:      r.Name,
+			Testnet:   r.Testnet,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+		})
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Exchange != accounts[j].Exchange {
+			return accounts[i].Exchange < accounts[j].Exchange
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts
+}
+
+// Add stores the credentials of a new account
+func (v *Vault) Add(exchange, name string, credentials Credentials) error {
+	if exchange == "" || name == "" {
+		return fmt.Errorf("exchange and account name are required")
+	}
+	if !credentials.Valid() {
+		return fmt.Errorf("api key and secret are required")
+	}
+	key := accountKey(exchange, name)
+	if _, exists := v.accounts[key]; exists {
+		return fmt.Errorf("%s account %s: %w", exchange, name, ErrExists)
+	}
+
+	now := time.Now().UTC()
+	v.accounts[key] = record{
+		Exchange:  exchange,
+		Name:      name,
+		APIKey:    credentials.APIKey,
+		Secret:    credentials.Secret,
+		Testnet:   credentials.Testnet,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return nil
+}
+
+// Rotate replaces the API key and secret of an account, keeping its network
+func (v *Vault) Rotate(exchange, name, apiKey, secret string) error {
+	key := accountKey(exchange, name)
+	r, exists := v.accounts[key]
+	if !exists {
+		return fmt.Errorf("%s account %s: %w", exchange, name, ErrNotFound)
+	}
+	if apiKey == "" || secret == "" {
+		return fmt.Errorf("api key and secret are required")
+	}
+
+	r.APIKey = apiKey
+	r.Secret = secret
+	r.UpdatedAt = time.Now().UTC()
+	v.accounts[key] = r
+	return nil
+}
+
+// Remove deletes an account
+func (v *Vault) Remove(exchange, name string) error {
+	key := accountKey(exchange, name)
+	if _, exists := v.accounts[key]; !exists {
+		return fmt.Errorf("%s account %s: %w", exchange, name, ErrNotFound)
+	}
+	delete(v.accounts, key)
+	return nil
+}
+
+// Save encrypts the accounts with a fresh nonce and replaces the vault file, readable by its owner only
+func (v *Vault) Save() error {
+	records := make([]record, 0, len(v.accounts))
+	for _, r := range v.accounts {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return accountKey(records[i].Exchange, records[i].Name) < accountKey(records[j].Exchange, records[j].Name)
+	})
+	plaintext, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := v.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(file{
+		Version:    Version,
+		KDF:        kdf,
+		Iterations: v.iterations,
+		Salt:       v.salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plaintext, v.additionalData()),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written next to the vault and renamed, so a failed write never leaves a truncated vault
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return nil
+}
+
+func (v *Vault) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the ciphertext to the KDF parameters stored in the clear
+func (v *Vault) additionalData() []byte {
+	return []byte(fmt.Sprintf("mercantile-vault:%d:%s:%d:%x", Version, kdf, v.iterations, v.salt))
+}
+
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, keyLen)
+}
+
+func accountKey(exchange, name string) string {
+	return exchange + "/" + name
+}
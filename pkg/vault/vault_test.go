@@ -0,0 +1,159 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arijanluiken/mercantile/pkg/config"
+)
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vault")
+
+	v, err := Create(path, "passphrase")
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if err := v.Add("bybit", "main", Credentials{APIKey: "main-key", Secret: "main-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Add("bybit", "hedge", Credentials{APIKey: "hedge-key", Secret: "hedge-secret", Testnet: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Add("bybit", "main", Credentials{APIKey: "other", Secret: "other"}); !errors.Is(err, ErrExists) {
+		t.Errorf("expected a duplicate account to fail, got %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "main-secret") || strings.Contains(string(data), "main-key") {
+		t.Error("expected the vault file to be encrypted")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the vault to be readable by its owner only, got %v", info.Mode().Perm())
+	}
+
+	if _, err := Open(path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase to fail, got %v", err)
+	}
+
+	v, err = Open(path, "passphrase")
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	accounts := v.Accounts()
+	if len(accounts) != 2 || accounts[0].Name != "hedge" || !accounts[0].Testnet || accounts[1].Name != "main" {
+		t.Errorf("unexpected accounts %+v", accounts)
+	}
+
+	if err := v.Rotate("bybit", "hedge", "new-key", "new-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Remove("bybit", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Rotate("bybit", "main", "key", "secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected rotating a removed account to fail, got %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = Open(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := v.Get("bybit", "hedge")
+	if err != nil || credentials.APIKey != "new-key" || credentials.Secret != "new-secret" || !credentials.Testnet {
+		t.Errorf("expected rotated testnet credentials, got %v: %v", credentials, err)
+	}
+	if _, err := v.Get("bybit", "main"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the removed account to be gone, got %v", err)
+	}
+}
+
+func TestCredentialsRedacted(t *testing.T) {
+	credentials := Credentials{APIKey: "abcdefgh1234", Secret: "top-secret-value"}
+
+	data, err := json.Marshal(map[string]interface{}{"credentials": credentials})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, formatted := range []string{string(data), credentials.String(), fmt.Sprintf("%v %+v %#v", credentials, credentials, credentials)} {
+		if strings.Contains(formatted, "top-secret-value") || strings.Contains(formatted, "abcdefgh") {
+			t.Errorf("expected credentials to be redacted, got %s", formatted)
+		}
+		if !strings.Contains(formatted, "1234") {
+			t.Errorf("expected the end of the API key to identify the account, got %s", formatted)
+		}
+	}
+}
+
+func TestForExchange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vault")
+	v, err := Create(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Add("bybit", "main", Credentials{APIKey: "vault-key", Secret: "vault-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Exchanges: map[string]config.ExchangeConfig{
+			"bybit":       {Enabled: true, Account: "main"},
+			"bybit-hedge": {Enabled: true, Type: "bybit", Account: "hedge"},
+			"bitvavo":     {Enabled: true},
+		},
+		Vault:         config.VaultConfig{Path: path},
+		BitvavoAPIKey: "env-key",
+		BitvavoSecret: "env-secret",
+	}
+
+	t.Setenv("VAULT_PASSPHRASE", "")
+	if _, err := UnlockOnce(cfg); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected a locked vault without a passphrase, got %v", err)
+	}
+	if _, err := ForExchange(cfg, nil, "bybit"); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected a locked vault without an unlocked vault, got %v", err)
+	}
+
+	t.Setenv("VAULT_PASSPHRASE", "passphrase")
+	unlocked, err := UnlockOnce(cfg)
+	if err != nil {
+		t.Fatalf("failed to unlock vault: %v", err)
+	}
+	if passphrase := os.Getenv("VAULT_PASSPHRASE"); passphrase != "" {
+		t.Error("expected the passphrase to be cleared from the environment once unlocked")
+	}
+
+	credentials, err := ForExchange(cfg, unlocked, "bybit")
+	if err != nil || credentials.APIKey != "vault-key" {
+		t.Errorf("expected the vault account, got %v: %v", credentials, err)
+	}
+	if _, err := ForExchange(cfg, unlocked, "bybit-hedge"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the hedge account to be looked up on bybit, got %v", err)
+	}
+	credentials, err = ForExchange(cfg, nil, "bitvavo")
+	if err != nil || credentials.APIKey != "env-key" || credentials.Secret != "env-secret" {
+		t.Errorf("expected the environment credentials, got %v: %v", credentials, err)
+	}
+
+	// Without accounts the vault is optional
+	if v, err := UnlockOnce(&config.Config{Vault: cfg.Vault}); v != nil || err != nil {
+		t.Errorf("expected no vault and no error without accounts, got %v: %v", v, err)
+	}
+}